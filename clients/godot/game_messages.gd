@@ -0,0 +1,3396 @@
+# Code generated by cmd/clientgen from messagecatalog.go. DO NOT EDIT.
+class_name GameMessages
+
+# The type of every message.
+const AUTHENTICATE := "Authenticate"
+const HEARTBEAT := "Heartbeat"
+const ACK := "Ack"
+const SESSION_SECRET := "SessionSecret"
+const SERVER_INFO_REQUEST := "ServerInfoRequest"
+const SERVER_INFO := "ServerInfo"
+const ADDRESS_ECHO_REQUEST := "AddressEchoRequest"
+const ADDRESS_ECHO := "AddressEcho"
+const PLAYER_JOIN := "PlayerJoin"
+const PLAYER_LEAVE := "PlayerLeave"
+const PLAYER_INDEX := "PlayerIndex"
+const REDIRECT := "Redirect"
+const UPGRADE_REQUIRED := "UpgradeRequired"
+const SERVER_FULL := "ServerFull"
+const KICKED := "Kicked"
+const DISCONNECT := "Disconnect"
+const BATCH := "Batch"
+const PLAYER_MOVE := "PlayerMove"
+const POSITION_CORRECTION := "PositionCorrection"
+const PLAYER_HIDDEN := "PlayerHidden"
+const CHANGE_ZONE := "ChangeZone"
+const ZONE_CHANGED := "ZoneChanged"
+const PLAYER_ACTION := "PlayerAction"
+const USE_ABILITY := "UseAbility"
+const ABILITY_USED := "AbilityUsed"
+const ABILITY_COOLDOWNS := "AbilityCooldowns"
+const PRIVATE_STATE := "PrivateState"
+const PLAYER_PUSHED := "PlayerPushed"
+const PLAYER_DIED := "PlayerDied"
+const PLAYER_RESPAWN := "PlayerRespawn"
+const A_F_K_WARNING := "AFKWarning"
+const PLAYER_A_F_K := "PlayerAFK"
+const GAME_STATE := "GameState"
+const ENTITY_SPAWNED := "EntitySpawned"
+const ENTITY_REMOVED := "EntityRemoved"
+const USE_ITEM := "UseItem"
+const DROP_ITEM := "DropItem"
+const PLAYER_INVENTORY := "PlayerInventory"
+const LEVEL_UP := "LevelUp"
+const MATCH_ENDED := "MatchEnded"
+const SCOREBOARD := "Scoreboard"
+const CREATE_ROOM := "CreateRoom"
+const ROOM_CREATED := "RoomCreated"
+const GAME_MODE_STATE := "GameModeState"
+const GAME_MODE_WON := "GameModeWon"
+const START_VOTE := "StartVote"
+const CAST_VOTE := "CastVote"
+const VOTE_UPDATE := "VoteUpdate"
+const VOTE_ENDED := "VoteEnded"
+const PAUSE_ROOM := "PauseRoom"
+const RESUME_ROOM := "ResumeRoom"
+const ROOM_PAUSED := "RoomPaused"
+const ROOM_RESUMED := "RoomResumed"
+const WORLD_EVENT := "WorldEvent"
+const RULES_CHANGED := "RulesChanged"
+const CHAT := "Chat"
+const MUTED := "Muted"
+const WHISPER := "Whisper"
+const FRIEND_ADD := "FriendAdd"
+const FRIEND_ACCEPT := "FriendAccept"
+const FRIEND_REMOVE := "FriendRemove"
+const FRIEND_LIST_REQUEST := "FriendListRequest"
+const FRIEND_LIST := "FriendList"
+const FRIEND_REQUEST := "FriendRequest"
+const FRIEND_ACCEPTED := "FriendAccepted"
+const FRIEND_ONLINE := "FriendOnline"
+const FRIEND_OFFLINE := "FriendOffline"
+const PARTY_INVITE := "PartyInvite"
+const PARTY_ACCEPT := "PartyAccept"
+const PARTY_LEAVE := "PartyLeave"
+const PARTY_CHAT := "PartyChat"
+const PARTY_INVITED := "PartyInvited"
+const PARTY_UPDATE := "PartyUpdate"
+const PARTY_LEFT := "PartyLeft"
+const GUILD_CREATE := "GuildCreate"
+const GUILD_JOIN := "GuildJoin"
+const GUILD_LEAVE := "GuildLeave"
+const GUILD_PROMOTE := "GuildPromote"
+const GUILD_INFO_REQUEST := "GuildInfoRequest"
+const GUILD_CHAT := "GuildChat"
+const GUILD_UPDATE := "GuildUpdate"
+const GUILD_LEFT := "GuildLeft"
+const MAIL_SEND := "MailSend"
+const MAIL_LIST_REQUEST := "MailListRequest"
+const MAIL_READ := "MailRead"
+const MAIL_DELETE := "MailDelete"
+const MAIL_SENT := "MailSent"
+const MAIL_RECEIVED := "MailReceived"
+const MAIL_LIST := "MailList"
+const PLAYER_STATS_REQUEST := "PlayerStatsRequest"
+const PLAYER_STATS := "PlayerStats"
+const LEADERBOARD_REQUEST := "LeaderboardRequest"
+const LEADERBOARD_RESPONSE := "LeaderboardResponse"
+const GUILD_LEADERBOARD_REQUEST := "GuildLeaderboardRequest"
+const GUILD_LEADERBOARD := "GuildLeaderboard"
+const PRIVACY_SETTINGS := "PrivacySettings"
+const GET_PREFERENCES := "GetPreferences"
+const SET_PREFERENCES := "SetPreferences"
+const PREFERENCES := "Preferences"
+const CONNECTION_QUALITY := "ConnectionQuality"
+const ERROR := "Error"
+const ANNOUNCEMENT := "Announcement"
+const MODERATE := "Moderate"
+const MODERATED := "Moderated"
+const REPORT_PLAYER := "ReportPlayer"
+const REPORT_FILED := "ReportFiled"
+const MAINTENANCE := "Maintenance"
+
+# Binary frames sent to UDP clients with the binary_moves capability, and
+# compressed frames sent to those with the gzip capability.
+const BINARY_FRAME_MAGIC := 0xC1
+const BINARY_KIND_PLAYER_MOVE := 1
+const BINARY_KIND_GAME_STATE := 2
+const BINARY_KIND_COMPRESSED := 3
+const BINARY_COORD_SCALE := 100.0
+
+
+# Decodes the payload of a message from the server as its payload class,
+# or returns null for messages without one.
+static func decode_data(type: String, data: Variant) -> Variant:
+	if typeof(data) != TYPE_DICTIONARY:
+		return null
+	match type:
+		ACK:
+			return AckData.from_dict(data)
+		SESSION_SECRET:
+			return SessionSecretData.from_dict(data)
+		SERVER_INFO:
+			return ServerInfo.from_dict(data)
+		ADDRESS_ECHO:
+			return AddressEchoData.from_dict(data)
+		PLAYER_JOIN:
+			return PlayerJoinData.from_dict(data)
+		PLAYER_LEAVE:
+			return PlayerLeaveData.from_dict(data)
+		PLAYER_INDEX:
+			return PlayerIndexData.from_dict(data)
+		REDIRECT:
+			return RedirectData.from_dict(data)
+		UPGRADE_REQUIRED:
+			return UpgradeRequiredData.from_dict(data)
+		SERVER_FULL:
+			return ServerFullData.from_dict(data)
+		KICKED:
+			return KickedData.from_dict(data)
+		DISCONNECT:
+			return DisconnectData.from_dict(data)
+		BATCH:
+			return BatchData.from_dict(data)
+		PLAYER_MOVE:
+			return PlayerMoveData.from_dict(data)
+		POSITION_CORRECTION:
+			return PositionCorrectionData.from_dict(data)
+		PLAYER_HIDDEN:
+			return PlayerHiddenData.from_dict(data)
+		ZONE_CHANGED:
+			return ZoneChangedData.from_dict(data)
+		ABILITY_USED:
+			return AbilityUsedData.from_dict(data)
+		ABILITY_COOLDOWNS:
+			return AbilityCooldownsData.from_dict(data)
+		PRIVATE_STATE:
+			return PrivateStateData.from_dict(data)
+		PLAYER_PUSHED:
+			return PlayerPushedData.from_dict(data)
+		PLAYER_DIED:
+			return PlayerDiedData.from_dict(data)
+		PLAYER_RESPAWN:
+			return PlayerRespawnData.from_dict(data)
+		A_F_K_WARNING:
+			return AFKWarningData.from_dict(data)
+		PLAYER_A_F_K:
+			return PlayerAFKData.from_dict(data)
+		GAME_STATE:
+			return GameStateData.from_dict(data)
+		ENTITY_SPAWNED:
+			return Entity.from_dict(data)
+		ENTITY_REMOVED:
+			return EntityRemovedData.from_dict(data)
+		PLAYER_INVENTORY:
+			return PlayerInventoryData.from_dict(data)
+		LEVEL_UP:
+			return LevelUpData.from_dict(data)
+		MATCH_ENDED:
+			return MatchEndedData.from_dict(data)
+		SCOREBOARD:
+			return ScoreboardData.from_dict(data)
+		ROOM_CREATED:
+			return RoomCreatedData.from_dict(data)
+		GAME_MODE_STATE:
+			return GameModeStateData.from_dict(data)
+		GAME_MODE_WON:
+			return GameModeWonData.from_dict(data)
+		VOTE_UPDATE:
+			return VoteStatusData.from_dict(data)
+		VOTE_ENDED:
+			return VoteEndedData.from_dict(data)
+		ROOM_PAUSED:
+			return RoomPauseData.from_dict(data)
+		ROOM_RESUMED:
+			return RoomPauseData.from_dict(data)
+		WORLD_EVENT:
+			return WorldEventData.from_dict(data)
+		RULES_CHANGED:
+			return GameRules.from_dict(data)
+		CHAT:
+			return ChatData.from_dict(data)
+		MUTED:
+			return MutedData.from_dict(data)
+		WHISPER:
+			return WhisperData.from_dict(data)
+		FRIEND_LIST:
+			return FriendListData.from_dict(data)
+		FRIEND_REQUEST:
+			return FriendPresenceData.from_dict(data)
+		FRIEND_ACCEPTED:
+			return FriendPresenceData.from_dict(data)
+		FRIEND_ONLINE:
+			return FriendPresenceData.from_dict(data)
+		FRIEND_OFFLINE:
+			return FriendPresenceData.from_dict(data)
+		PARTY_CHAT:
+			return PartyChatData.from_dict(data)
+		PARTY_INVITED:
+			return PartyInvitedData.from_dict(data)
+		PARTY_UPDATE:
+			return PartyUpdateData.from_dict(data)
+		PARTY_LEFT:
+			return PartyLeftData.from_dict(data)
+		GUILD_CHAT:
+			return GuildChatData.from_dict(data)
+		GUILD_UPDATE:
+			return GuildUpdateData.from_dict(data)
+		GUILD_LEFT:
+			return GuildLeftData.from_dict(data)
+		MAIL_SENT:
+			return MailIDData.from_dict(data)
+		MAIL_RECEIVED:
+			return MailData.from_dict(data)
+		MAIL_LIST:
+			return MailData.from_dict(data)
+		PLAYER_STATS:
+			return PlayerStatsData.from_dict(data)
+		LEADERBOARD_RESPONSE:
+			return LeaderboardResponseData.from_dict(data)
+		GUILD_LEADERBOARD:
+			return GuildLeaderboardData.from_dict(data)
+		PRIVACY_SETTINGS:
+			return PrivacySettings.from_dict(data)
+		PREFERENCES:
+			return PreferencesData.from_dict(data)
+		CONNECTION_QUALITY:
+			return ConnectionQualityData.from_dict(data)
+		ERROR:
+			return ErrorData.from_dict(data)
+		ANNOUNCEMENT:
+			return AnnouncementData.from_dict(data)
+		MODERATED:
+			return ModeratedData.from_dict(data)
+		REPORT_FILED:
+			return ReportFiledData.from_dict(data)
+		MAINTENANCE:
+			return MaintenanceData.from_dict(data)
+	return null
+
+
+# Returns the packet inside a compressed frame, or the packet itself if it
+# is not one. Call it on every packet before anything else.
+static func decompress_packet(packet: PackedByteArray) -> PackedByteArray:
+	if packet.size() < 6 or packet[0] != BINARY_FRAME_MAGIC or packet[1] != BINARY_KIND_COMPRESSED:
+		return packet
+	return packet.slice(6).decompress_dynamic(-1, FileAccess.COMPRESSION_GZIP)
+
+
+# Decodes a binary PlayerMove or GameState frame into its kind, sequence (0
+# when unreliable) and players, or returns an empty Dictionary for packets
+# that are not binary frames. All integers are big-endian.
+static func decode_binary_frame(packet: PackedByteArray) -> Dictionary:
+	if packet.size() < 6 or packet[0] != BINARY_FRAME_MAGIC:
+		return {}
+	var stream := StreamPeerBuffer.new()
+	stream.big_endian = true
+	stream.data_array = packet
+	stream.seek(1)
+	var kind := stream.get_u8()
+	var frame := {"kind": kind, "sequence": stream.get_u32(), "players": []}
+	if kind == BINARY_KIND_PLAYER_MOVE and packet.size() >= 16:
+		frame.players.append({"index": stream.get_u16(), "x": stream.get_32() / BINARY_COORD_SCALE, "y": stream.get_32() / BINARY_COORD_SCALE})
+	elif kind == BINARY_KIND_GAME_STATE and packet.size() >= 8:
+		var count := stream.get_u16()
+		if packet.size() < 8 + count * 17:
+			return {}
+		for i in count:
+			frame.players.append({
+				"index": stream.get_u16(),
+				"x": stream.get_32() / BINARY_COORD_SCALE,
+				"y": stream.get_32() / BINARY_COORD_SCALE,
+				"health": stream.get_u8(),
+				"score": stream.get_u32(),
+				"level": stream.get_u16(),
+			})
+	else:
+		return {}
+	return frame
+
+
+static func _map_values(d: Dictionary, convert: Callable) -> Dictionary:
+	var result := {}
+	for key in d:
+		result[key] = convert.call(d[key])
+	return result
+
+
+## AuthenticateData is the first frame of a TCP client when AUTH_REQUIRED is set, carrying a token from /auth/login.
+class AuthenticateData:
+	var token: String = ""
+	## 1 if omitted, see protocol.go. Omitted when unset.
+	var protocol_version: int = 0
+
+	static func from_dict(d: Dictionary) -> AuthenticateData:
+		var m := AuthenticateData.new()
+		if d.has("token"):
+			m.token = d["token"]
+		if d.has("protocol_version"):
+			m.protocol_version = int(d["protocol_version"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["token"] = token
+		if protocol_version != 0:
+			d["protocol_version"] = protocol_version
+		return d
+
+
+class HeartbeatData:
+	## A UUID.
+	var player_id: String = ""
+	var sequence: int = 0
+	## Optional features a UDP client supports. Omitted when unset.
+	var capabilities: Array = []
+	## Sender's clock in ms, echoed in the Ack. Omitted when unset.
+	var sent_at: int = 0
+	## Account token, checked on a client's first Heartbeat. Omitted when unset.
+	var token: String = ""
+	## 1 if omitted, see protocol.go. Omitted when unset.
+	var protocol_version: int = 0
+
+	static func from_dict(d: Dictionary) -> HeartbeatData:
+		var m := HeartbeatData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("sequence"):
+			m.sequence = int(d["sequence"])
+		if d.has("capabilities"):
+			m.capabilities = d["capabilities"]
+		if d.has("sent_at"):
+			m.sent_at = int(d["sent_at"])
+		if d.has("token"):
+			m.token = d["token"]
+		if d.has("protocol_version"):
+			m.protocol_version = int(d["protocol_version"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["sequence"] = sequence
+		if capabilities != []:
+			d["capabilities"] = capabilities
+		if sent_at != 0:
+			d["sent_at"] = sent_at
+		if token != "":
+			d["token"] = token
+		if protocol_version != 0:
+			d["protocol_version"] = protocol_version
+		return d
+
+
+class AckData:
+	var sequence: int = 0
+	## SentAt of the acknowledged Heartbeat. Omitted when unset.
+	var sent_at: int = 0
+
+	static func from_dict(d: Dictionary) -> AckData:
+		var m := AckData.new()
+		if d.has("sequence"):
+			m.sequence = int(d["sequence"])
+		if d.has("sent_at"):
+			m.sent_at = int(d["sent_at"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["sequence"] = sequence
+		if sent_at != 0:
+			d["sent_at"] = sent_at
+		return d
+
+
+## SessionSecretData gives a UDP client the secret it signs its packets with, see transport/udp/udpsign.go.
+class SessionSecretData:
+	## A UUID.
+	var player_id: String = ""
+	## Base64 in JSON, binary in MessagePack.
+	var secret: String = ""
+
+	static func from_dict(d: Dictionary) -> SessionSecretData:
+		var m := SessionSecretData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("secret"):
+			m.secret = d["secret"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["secret"] = secret
+		return d
+
+
+## ServerInfo describes an instance to launchers and server browsers.
+class ServerInfo:
+	var name: String = ""
+	var map: String = ""
+	## Websocket, tcp or udp.
+	var protocol: String = ""
+	var players: int = 0
+	## 0 for unlimited.
+	var max_players: int = 0
+	## GameState snapshots per second, 0 for none.
+	var tick_rate: int = 0
+	var protocol_versions: Array = []
+	var encodings: Array = []
+	## How often UDP clients should send a Heartbeat. Omitted when unset.
+	var keepalive_ms: int = 0
+
+	static func from_dict(d: Dictionary) -> ServerInfo:
+		var m := ServerInfo.new()
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("map"):
+			m.map = d["map"]
+		if d.has("protocol"):
+			m.protocol = d["protocol"]
+		if d.has("players"):
+			m.players = int(d["players"])
+		if d.has("max_players"):
+			m.max_players = int(d["max_players"])
+		if d.has("tick_rate"):
+			m.tick_rate = int(d["tick_rate"])
+		if d.has("protocol_versions"):
+			m.protocol_versions = d["protocol_versions"]
+		if d.has("encodings"):
+			m.encodings = d["encodings"]
+		if d.has("keepalive_ms"):
+			m.keepalive_ms = int(d["keepalive_ms"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["name"] = name
+		d["map"] = map
+		d["protocol"] = protocol
+		d["players"] = players
+		d["max_players"] = max_players
+		d["tick_rate"] = tick_rate
+		d["protocol_versions"] = protocol_versions
+		d["encodings"] = encodings
+		if keepalive_ms != 0:
+			d["keepalive_ms"] = keepalive_ms
+		return d
+
+
+## AddressEchoData tells a UDP client the address and port its packets reach the server from, after any NAT, see transport/udp/keepalive.go.
+class AddressEchoData:
+	var ip: String = ""
+	var port: int = 0
+	## How often to send a Heartbeat to keep the mapping open.
+	var keepalive_ms: int = 0
+
+	static func from_dict(d: Dictionary) -> AddressEchoData:
+		var m := AddressEchoData.new()
+		if d.has("ip"):
+			m.ip = d["ip"]
+		if d.has("port"):
+			m.port = int(d["port"])
+		if d.has("keepalive_ms"):
+			m.keepalive_ms = int(d["keepalive_ms"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["ip"] = ip
+		d["port"] = port
+		d["keepalive_ms"] = keepalive_ms
+		return d
+
+
+class PlayerJoinData:
+	## A UUID.
+	var player_id: String = ""
+	var name: String = ""
+
+	static func from_dict(d: Dictionary) -> PlayerJoinData:
+		var m := PlayerJoinData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		return d
+
+
+class PlayerLeaveData:
+	## A UUID.
+	var player_id: String = ""
+
+	static func from_dict(d: Dictionary) -> PlayerLeaveData:
+		var m := PlayerLeaveData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		return d
+
+
+## PlayerIndexData announces the indexes binary frames refer to players by.
+class PlayerIndexData:
+	var players: Array = []
+
+	static func from_dict(d: Dictionary) -> PlayerIndexData:
+		var m := PlayerIndexData.new()
+		if d.has("players"):
+			m.players = d["players"].map(func(e): return PlayerIndexEntry.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["players"] = players.map(func(e): return e.to_dict())
+		return d
+
+
+class PlayerIndexEntry:
+	var index: int = 0
+	## A UUID.
+	var player_id: String = ""
+	var name: String = ""
+
+	static func from_dict(d: Dictionary) -> PlayerIndexEntry:
+		var m := PlayerIndexEntry.new()
+		if d.has("index"):
+			m.index = int(d["index"])
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["index"] = index
+		d["player_id"] = player_id
+		d["name"] = name
+		return d
+
+
+## RedirectData tells a client which backend instance to reconnect to, carrying the player ID it should reconnect with.
+class RedirectData:
+	var backend: String = ""
+	## A UUID.
+	var player_id: String = ""
+	## Omitted when unset.
+	var room: String = ""
+
+	static func from_dict(d: Dictionary) -> RedirectData:
+		var m := RedirectData.new()
+		if d.has("backend"):
+			m.backend = d["backend"]
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("room"):
+			m.room = d["room"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["backend"] = backend
+		d["player_id"] = player_id
+		if room != "":
+			d["room"] = room
+		return d
+
+
+## UpgradeRequiredData refuses a client whose protocol version the server does not speak.
+class UpgradeRequiredData:
+	var version: int = 0
+	var supported_versions: Array = []
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> UpgradeRequiredData:
+		var m := UpgradeRequiredData.new()
+		if d.has("version"):
+			m.version = int(d["version"])
+		if d.has("supported_versions"):
+			m.supported_versions = d["supported_versions"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["version"] = version
+		d["supported_versions"] = supported_versions
+		d["message"] = message
+		return d
+
+
+## ServerFullData tells a client the server has MaxPlayers playing. Position is its place in the queue, from 1, or 0 if it was refused.
+class ServerFullData:
+	var position: int = 0
+	var max_players: int = 0
+
+	static func from_dict(d: Dictionary) -> ServerFullData:
+		var m := ServerFullData.new()
+		if d.has("position"):
+			m.position = int(d["position"])
+		if d.has("max_players"):
+			m.max_players = int(d["max_players"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["position"] = position
+		d["max_players"] = max_players
+		return d
+
+
+## KickedData tells a player why the server is disconnecting them.
+class KickedData:
+	var reason: String = ""
+
+	static func from_dict(d: Dictionary) -> KickedData:
+		var m := KickedData.new()
+		if d.has("reason"):
+			m.reason = d["reason"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["reason"] = reason
+		return d
+
+
+## DisconnectData is sent before the server closes a connection. Clients branch on Reason, one of the disconnect reasons in disconnect.go, and show Message.
+class DisconnectData:
+	var reason: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> DisconnectData:
+		var m := DisconnectData.new()
+		if d.has("reason"):
+			m.reason = d["reason"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["reason"] = reason
+		d["message"] = message
+		return d
+
+
+## BatchData carries the messages queued for a client during one tick, in order.
+class BatchData:
+	var messages: Array = []
+
+	static func from_dict(d: Dictionary) -> BatchData:
+		var m := BatchData.new()
+		if d.has("messages"):
+			m.messages = d["messages"].map(func(e): return GameMessage.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["messages"] = messages.map(func(e): return e.to_dict())
+		return d
+
+
+class GameMessage:
+	var type: String = ""
+	var data = null
+	## Optional envelope fields for request/response correlation, see rpc.go. Omitted when unset.
+	var id: String = ""
+	## Ms since the epoch. Omitted when unset.
+	var timestamp: int = 0
+	## Omitted when unset.
+	var correlation_id: String = ""
+
+	static func from_dict(d: Dictionary) -> GameMessage:
+		var m := GameMessage.new()
+		if d.has("type"):
+			m.type = d["type"]
+		if d.has("data"):
+			m.data = d["data"]
+		if d.has("id"):
+			m.id = d["id"]
+		if d.has("timestamp"):
+			m.timestamp = int(d["timestamp"])
+		if d.has("correlation_id"):
+			m.correlation_id = d["correlation_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["type"] = type
+		if data != null:
+			d["data"] = data
+		if id != "":
+			d["id"] = id
+		if timestamp != 0:
+			d["timestamp"] = timestamp
+		if correlation_id != "":
+			d["correlation_id"] = correlation_id
+		return d
+
+
+## PlayerMoveData is a position update. A steering player also sends its velocity in units per second, which the server then moves it at.
+class PlayerMoveData:
+	## A UUID.
+	var player_id: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+	## Omitted when unset.
+	var vx: float = 0.0
+	## Omitted when unset.
+	var vy: float = 0.0
+
+	static func from_dict(d: Dictionary) -> PlayerMoveData:
+		var m := PlayerMoveData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("vx"):
+			m.vx = float(d["vx"])
+		if d.has("vy"):
+			m.vy = float(d["vy"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["x"] = x
+		d["y"] = y
+		if vx != 0.0:
+			d["vx"] = vx
+		if vy != 0.0:
+			d["vy"] = vy
+		return d
+
+
+## PositionCorrectionData tells a client where the server placed it after it reported a position outside the world bounds.
+class PositionCorrectionData:
+	## A UUID.
+	var player_id: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+
+	static func from_dict(d: Dictionary) -> PositionCorrectionData:
+		var m := PositionCorrectionData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["x"] = x
+		d["y"] = y
+		return d
+
+
+## PlayerHiddenData tells a player that another player went out of sight, see Visibility. Its position is not sent again until it is back in sight.
+class PlayerHiddenData:
+	## A UUID.
+	var player_id: String = ""
+
+	static func from_dict(d: Dictionary) -> PlayerHiddenData:
+		var m := PlayerHiddenData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		return d
+
+
+## ChangeZoneData asks to move the sender to another zone.
+class ChangeZoneData:
+	var zone: String = ""
+
+	static func from_dict(d: Dictionary) -> ChangeZoneData:
+		var m := ChangeZoneData.new()
+		if d.has("zone"):
+			m.zone = d["zone"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["zone"] = zone
+		return d
+
+
+## ZoneChangedData tells a player the zone they are in and where they arrived, on joining and after each ChangeZone.
+class ZoneChangedData:
+	var zone: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+
+	static func from_dict(d: Dictionary) -> ZoneChangedData:
+		var m := ZoneChangedData.new()
+		if d.has("zone"):
+			m.zone = d["zone"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["zone"] = zone
+		d["x"] = x
+		d["y"] = y
+		return d
+
+
+class PlayerActionData:
+	## A UUID.
+	var player_id: String = ""
+	var action: String = ""
+	var data = null
+
+	static func from_dict(d: Dictionary) -> PlayerActionData:
+		var m := PlayerActionData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("action"):
+			m.action = d["action"]
+		if d.has("data"):
+			m.data = d["data"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["action"] = action
+		if data != null:
+			d["data"] = data
+		return d
+
+
+## UseAbilityData uses an ability, aimed at TargetID for damage and at X, Y for a blast, which defaults to the user's position.
+class UseAbilityData:
+	var ability: String = ""
+	## A UUID. Omitted when unset.
+	var target_id = null
+	## Omitted when unset.
+	var x = null
+	## Omitted when unset.
+	var y = null
+
+	static func from_dict(d: Dictionary) -> UseAbilityData:
+		var m := UseAbilityData.new()
+		if d.has("ability"):
+			m.ability = d["ability"]
+		if d.has("target_id"):
+			m.target_id = d["target_id"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["ability"] = ability
+		if target_id != null:
+			d["target_id"] = target_id
+		if x != null:
+			d["x"] = x
+		if y != null:
+			d["y"] = y
+		return d
+
+
+## AbilityUsedData tells the players in a zone that one of them used an ability at X, Y, its target's position for damage.
+class AbilityUsedData:
+	## A UUID.
+	var player_id: String = ""
+	var ability: String = ""
+	var effect: String = ""
+	## A UUID. Omitted when unset.
+	var target_id = null
+	var x: float = 0.0
+	var y: float = 0.0
+
+	static func from_dict(d: Dictionary) -> AbilityUsedData:
+		var m := AbilityUsedData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("ability"):
+			m.ability = d["ability"]
+		if d.has("effect"):
+			m.effect = d["effect"]
+		if d.has("target_id"):
+			m.target_id = d["target_id"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["ability"] = ability
+		d["effect"] = effect
+		if target_id != null:
+			d["target_id"] = target_id
+		d["x"] = x
+		d["y"] = y
+		return d
+
+
+## AbilityCooldownsData tells a player the abilities of the server and when each is ready for them again. It is sent when they join and after every ability they use.
+class AbilityCooldownsData:
+	var cooldowns: Array = []
+
+	static func from_dict(d: Dictionary) -> AbilityCooldownsData:
+		var m := AbilityCooldownsData.new()
+		if d.has("cooldowns"):
+			m.cooldowns = d["cooldowns"].map(func(e): return AbilityCooldown.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["cooldowns"] = cooldowns.map(func(e): return e.to_dict())
+		return d
+
+
+class AbilityCooldown:
+	var ability: String = ""
+	var cooldown_seconds: float = 0.0
+	## Unix milliseconds, omitted if never used. Omitted when unset.
+	var ready_at: int = 0
+
+	static func from_dict(d: Dictionary) -> AbilityCooldown:
+		var m := AbilityCooldown.new()
+		if d.has("ability"):
+			m.ability = d["ability"]
+		if d.has("cooldown_seconds"):
+			m.cooldown_seconds = float(d["cooldown_seconds"])
+		if d.has("ready_at"):
+			m.ready_at = int(d["ready_at"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["ability"] = ability
+		d["cooldown_seconds"] = cooldown_seconds
+		if ready_at != 0:
+			d["ready_at"] = ready_at
+		return d
+
+
+## PrivateStateData is state only the player it belongs to is sent, see PrivateChannel. Sections left out have not changed since the previous PrivateState; the first after joining has every section.
+class PrivateStateData:
+	## A UUID.
+	var player_id: String = ""
+	## Counts up from 1, so that a stale update can be told apart.
+	var sequence: int = 0
+	## Unix milliseconds.
+	var sent_at: int = 0
+	## Omitted when unset.
+	var health: HealthDetails = null
+	## Omitted when unset.
+	var cooldowns: Array = []
+	## Omitted when unset.
+	var inventory: InventoryDetails = null
+
+	static func from_dict(d: Dictionary) -> PrivateStateData:
+		var m := PrivateStateData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("sequence"):
+			m.sequence = int(d["sequence"])
+		if d.has("sent_at"):
+			m.sent_at = int(d["sent_at"])
+		if d.has("health"):
+			m.health = HealthDetails.from_dict(d["health"])
+		if d.has("cooldowns"):
+			m.cooldowns = d["cooldowns"].map(func(e): return AbilityCooldown.from_dict(e))
+		if d.has("inventory"):
+			m.inventory = InventoryDetails.from_dict(d["inventory"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["sequence"] = sequence
+		d["sent_at"] = sent_at
+		if health != null:
+			d["health"] = health.to_dict()
+		if cooldowns != []:
+			d["cooldowns"] = cooldowns.map(func(e): return e.to_dict())
+		if inventory != null:
+			d["inventory"] = inventory.to_dict()
+		return d
+
+
+## HealthDetails is a player's health with what only they need to know, such as when they come back after dying.
+class HealthDetails:
+	var current: float = 0.0
+	var max: float = 0.0
+	## Unix milliseconds, while dead and respawning. Omitted when unset.
+	var respawn_at: int = 0
+
+	static func from_dict(d: Dictionary) -> HealthDetails:
+		var m := HealthDetails.new()
+		if d.has("current"):
+			m.current = float(d["current"])
+		if d.has("max"):
+			m.max = float(d["max"])
+		if d.has("respawn_at"):
+			m.respawn_at = int(d["respawn_at"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["current"] = current
+		d["max"] = max
+		if respawn_at != 0:
+			d["respawn_at"] = respawn_at
+		return d
+
+
+class InventoryDetails:
+	var items: Array = []
+
+	static func from_dict(d: Dictionary) -> InventoryDetails:
+		var m := InventoryDetails.new()
+		if d.has("items"):
+			m.items = d["items"].map(func(e): return InventoryItem.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["items"] = items.map(func(e): return e.to_dict())
+		return d
+
+
+class InventoryItem:
+	var item_type: String = ""
+	var quantity: int = 0
+
+	static func from_dict(d: Dictionary) -> InventoryItem:
+		var m := InventoryItem.new()
+		if d.has("item_type"):
+			m.item_type = d["item_type"]
+		if d.has("quantity"):
+			m.quantity = int(d["quantity"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["item_type"] = item_type
+		d["quantity"] = quantity
+		return d
+
+
+## PlayerPushedData tells the players in a zone that the server knocked a player back. The player moves at VX, VY, slowing to a stop, and its moves are corrected until it does.
+class PlayerPushedData:
+	## A UUID.
+	var player_id: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+	var vx: float = 0.0
+	var vy: float = 0.0
+
+	static func from_dict(d: Dictionary) -> PlayerPushedData:
+		var m := PlayerPushedData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("vx"):
+			m.vx = float(d["vx"])
+		if d.has("vy"):
+			m.vy = float(d["vy"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["x"] = x
+		d["y"] = y
+		d["vx"] = vx
+		d["vy"] = vy
+		return d
+
+
+## PlayerDiedData tells the players in a zone that one of them was killed.
+class PlayerDiedData:
+	## A UUID.
+	var player_id: String = ""
+	## A UUID.
+	var killer_id: String = ""
+	## Unix milliseconds, omitted if killed players stay dead. Omitted when unset.
+	var respawn_at: int = 0
+
+	static func from_dict(d: Dictionary) -> PlayerDiedData:
+		var m := PlayerDiedData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("killer_id"):
+			m.killer_id = d["killer_id"]
+		if d.has("respawn_at"):
+			m.respawn_at = int(d["respawn_at"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["killer_id"] = killer_id
+		if respawn_at != 0:
+			d["respawn_at"] = respawn_at
+		return d
+
+
+class PlayerRespawnData:
+	## A UUID.
+	var player_id: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+	var health: float = 0.0
+
+	static func from_dict(d: Dictionary) -> PlayerRespawnData:
+		var m := PlayerRespawnData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("health"):
+			m.health = float(d["health"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["x"] = x
+		d["y"] = y
+		d["health"] = health
+		return d
+
+
+## AFKWarningData warns an idle player that they will be removed at RemovalAt unless they act: from the server if Kick is set, otherwise from the match.
+class AFKWarningData:
+	## Unix milliseconds.
+	var removal_at: int = 0
+	var kick: bool = false
+
+	static func from_dict(d: Dictionary) -> AFKWarningData:
+		var m := AFKWarningData.new()
+		if d.has("removal_at"):
+			m.removal_at = int(d["removal_at"])
+		if d.has("kick"):
+			m.kick = d["kick"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["removal_at"] = removal_at
+		d["kick"] = kick
+		return d
+
+
+## PlayerAFKData tells the players in a zone that one of them went idle or came back. Removed is set once an idle player is out of the match.
+class PlayerAFKData:
+	## A UUID.
+	var player_id: String = ""
+	var afk: bool = false
+	## Omitted when unset.
+	var removed: bool = false
+
+	static func from_dict(d: Dictionary) -> PlayerAFKData:
+		var m := PlayerAFKData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("afk"):
+			m.afk = d["afk"]
+		if d.has("removed"):
+			m.removed = d["removed"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["afk"] = afk
+		if removed != false:
+			d["removed"] = removed
+		return d
+
+
+## GameStateData is a snapshot of every player. Tick is the simulation tick it was taken at, omitted by the UDP server which has no simulation loop, and ServerTime the authoritative time in milliseconds; clients interpolate between snapshots by either.
+class GameStateData:
+	var players: Array = []
+	## The NPCs, items and projectiles of the zone. Omitted when unset.
+	var entities: Array = []
+	var timestamp: int = 0
+	## Omitted when unset.
+	var tick: int = 0
+	var server_time: int = 0
+
+	static func from_dict(d: Dictionary) -> GameStateData:
+		var m := GameStateData.new()
+		if d.has("players"):
+			m.players = d["players"].map(func(e): return Player.from_dict(e))
+		if d.has("entities"):
+			m.entities = d["entities"].map(func(e): return Entity.from_dict(e))
+		if d.has("timestamp"):
+			m.timestamp = int(d["timestamp"])
+		if d.has("tick"):
+			m.tick = int(d["tick"])
+		if d.has("server_time"):
+			m.server_time = int(d["server_time"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["players"] = players.map(func(e): return e.to_dict())
+		if entities != []:
+			d["entities"] = entities.map(func(e): return e.to_dict())
+		d["timestamp"] = timestamp
+		if tick != 0:
+			d["tick"] = tick
+		d["server_time"] = server_time
+		return d
+
+
+class Player:
+	## A UUID.
+	var id: String = ""
+	var name: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+	var health: float = 0.0
+	var score: int = 0
+	var xp: int = 0
+	var level: int = 0
+	## Units per second, see MoveTo.
+	var vx: float = 0.0
+	var vy: float = 0.0
+	## See GuildManager. Omitted when unset.
+	var guild_tag: String = ""
+	## Set by the game mode of the player's room. Omitted when unset.
+	var team: String = ""
+	## See Zones. Omitted when unset.
+	var zone: String = ""
+	## Idle, see AFKPolicy. Omitted when unset.
+	var afk: bool = false
+
+	static func from_dict(d: Dictionary) -> Player:
+		var m := Player.new()
+		if d.has("id"):
+			m.id = d["id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("health"):
+			m.health = float(d["health"])
+		if d.has("score"):
+			m.score = int(d["score"])
+		if d.has("xp"):
+			m.xp = int(d["xp"])
+		if d.has("level"):
+			m.level = int(d["level"])
+		if d.has("vx"):
+			m.vx = float(d["vx"])
+		if d.has("vy"):
+			m.vy = float(d["vy"])
+		if d.has("guild_tag"):
+			m.guild_tag = d["guild_tag"]
+		if d.has("team"):
+			m.team = d["team"]
+		if d.has("zone"):
+			m.zone = d["zone"]
+		if d.has("afk"):
+			m.afk = d["afk"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["id"] = id
+		d["name"] = name
+		d["x"] = x
+		d["y"] = y
+		d["health"] = health
+		d["score"] = score
+		d["xp"] = xp
+		d["level"] = level
+		d["vx"] = vx
+		d["vy"] = vy
+		if guild_tag != "":
+			d["guild_tag"] = guild_tag
+		if team != "":
+			d["team"] = team
+		if zone != "":
+			d["zone"] = zone
+		if afk != false:
+			d["afk"] = afk
+		return d
+
+
+## Entity is an object of the world made of optional components, so that snapshots, persistence and zone visibility treat NPCs, items and projectiles alike whatever they are made of.
+class Entity:
+	## A UUID.
+	var id: String = ""
+	var kind: String = ""
+	var zone: String = ""
+	## Omitted when unset.
+	var position: Position = null
+	## Omitted when unset.
+	var health: Health = null
+	## Omitted when unset.
+	var team: Team = null
+	## Omitted when unset.
+	var ownership: Ownership = null
+	## An RFC 3339 time. Removed by then, like a spent projectile. Omitted when unset.
+	var expires_at = null
+
+	static func from_dict(d: Dictionary) -> Entity:
+		var m := Entity.new()
+		if d.has("id"):
+			m.id = d["id"]
+		if d.has("kind"):
+			m.kind = d["kind"]
+		if d.has("zone"):
+			m.zone = d["zone"]
+		if d.has("position"):
+			m.position = Position.from_dict(d["position"])
+		if d.has("health"):
+			m.health = Health.from_dict(d["health"])
+		if d.has("team"):
+			m.team = Team.from_dict(d["team"])
+		if d.has("ownership"):
+			m.ownership = Ownership.from_dict(d["ownership"])
+		if d.has("expires_at"):
+			m.expires_at = d["expires_at"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["id"] = id
+		d["kind"] = kind
+		d["zone"] = zone
+		if position != null:
+			d["position"] = position.to_dict()
+		if health != null:
+			d["health"] = health.to_dict()
+		if team != null:
+			d["team"] = team.to_dict()
+		if ownership != null:
+			d["ownership"] = ownership.to_dict()
+		if expires_at != null:
+			d["expires_at"] = expires_at
+		return d
+
+
+## Position places an entity, which moves at VX, VY units per second.
+class Position:
+	var x: float = 0.0
+	var y: float = 0.0
+	## Omitted when unset.
+	var vx: float = 0.0
+	## Omitted when unset.
+	var vy: float = 0.0
+
+	static func from_dict(d: Dictionary) -> Position:
+		var m := Position.new()
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("vx"):
+			m.vx = float(d["vx"])
+		if d.has("vy"):
+			m.vy = float(d["vy"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["x"] = x
+		d["y"] = y
+		if vx != 0.0:
+			d["vx"] = vx
+		if vy != 0.0:
+			d["vy"] = vy
+		return d
+
+
+class Health:
+	var current: float = 0.0
+	var max: float = 0.0
+
+	static func from_dict(d: Dictionary) -> Health:
+		var m := Health.new()
+		if d.has("current"):
+			m.current = float(d["current"])
+		if d.has("max"):
+			m.max = float(d["max"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["current"] = current
+		d["max"] = max
+		return d
+
+
+class Team:
+	var name: String = ""
+
+	static func from_dict(d: Dictionary) -> Team:
+		var m := Team.new()
+		if d.has("name"):
+			m.name = d["name"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["name"] = name
+		return d
+
+
+## Ownership ties an entity to the player it belongs to, such as the shooter of a projectile.
+class Ownership:
+	## A UUID.
+	var owner_id: String = ""
+
+	static func from_dict(d: Dictionary) -> Ownership:
+		var m := Ownership.new()
+		if d.has("owner_id"):
+			m.owner_id = d["owner_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["owner_id"] = owner_id
+		return d
+
+
+## EntityRemovedData tells the players in a zone that an entity is gone.
+class EntityRemovedData:
+	## A UUID.
+	var entity_id: String = ""
+
+	static func from_dict(d: Dictionary) -> EntityRemovedData:
+		var m := EntityRemovedData.new()
+		if d.has("entity_id"):
+			m.entity_id = d["entity_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["entity_id"] = entity_id
+		return d
+
+
+class UseItemData:
+	var item: String = ""
+
+	static func from_dict(d: Dictionary) -> UseItemData:
+		var m := UseItemData.new()
+		if d.has("item"):
+			m.item = d["item"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["item"] = item
+		return d
+
+
+class DropItemData:
+	var item: String = ""
+	var quantity: int = 0
+
+	static func from_dict(d: Dictionary) -> DropItemData:
+		var m := DropItemData.new()
+		if d.has("item"):
+			m.item = d["item"]
+		if d.has("quantity"):
+			m.quantity = int(d["quantity"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["item"] = item
+		d["quantity"] = quantity
+		return d
+
+
+class PlayerInventoryData:
+	## A UUID.
+	var player_id: String = ""
+	var items: Array = []
+
+	static func from_dict(d: Dictionary) -> PlayerInventoryData:
+		var m := PlayerInventoryData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("items"):
+			m.items = d["items"].map(func(e): return InventoryItem.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["items"] = items.map(func(e): return e.to_dict())
+		return d
+
+
+class LevelUpData:
+	## A UUID.
+	var player_id: String = ""
+	var level: int = 0
+	var xp: int = 0
+
+	static func from_dict(d: Dictionary) -> LevelUpData:
+		var m := LevelUpData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("level"):
+			m.level = int(d["level"])
+		if d.has("xp"):
+			m.xp = int(d["xp"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["level"] = level
+		d["xp"] = xp
+		return d
+
+
+class MatchEndedData:
+	## Omitted when unset.
+	var match_id = null
+	var results: Array = []
+
+	static func from_dict(d: Dictionary) -> MatchEndedData:
+		var m := MatchEndedData.new()
+		if d.has("match_id"):
+			m.match_id = int(d["match_id"])
+		if d.has("results"):
+			m.results = d["results"].map(func(e): return RatingChange.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if match_id != null:
+			d["match_id"] = match_id
+		d["results"] = results.map(func(e): return e.to_dict())
+		return d
+
+
+class RatingChange:
+	## A UUID.
+	var player_id: String = ""
+	var old_rating: int = 0
+	var new_rating: int = 0
+	var placement: int = 0
+	## Omitted when unset.
+	var room: String = ""
+
+	static func from_dict(d: Dictionary) -> RatingChange:
+		var m := RatingChange.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("old_rating"):
+			m.old_rating = int(d["old_rating"])
+		if d.has("new_rating"):
+			m.new_rating = int(d["new_rating"])
+		if d.has("placement"):
+			m.placement = int(d["placement"])
+		if d.has("room"):
+			m.room = d["room"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["old_rating"] = old_rating
+		d["new_rating"] = new_rating
+		d["placement"] = placement
+		if room != "":
+			d["room"] = room
+		return d
+
+
+## ScoreboardData ranks the players of a match. Final is set on the one sent when the match ends.
+class ScoreboardData:
+	## Omitted when unset.
+	var match_id = null
+	var final: bool = false
+	var results: Array = []
+
+	static func from_dict(d: Dictionary) -> ScoreboardData:
+		var m := ScoreboardData.new()
+		if d.has("match_id"):
+			m.match_id = int(d["match_id"])
+		if d.has("final"):
+			m.final = d["final"]
+		if d.has("results"):
+			m.results = d["results"].map(func(e): return MatchResult.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if match_id != null:
+			d["match_id"] = match_id
+		d["final"] = final
+		d["results"] = results.map(func(e): return e.to_dict())
+		return d
+
+
+## MatchResult is a player's line of a scoreboard, and of the final summary saved when a match ends.
+class MatchResult:
+	var rank: int = 0
+	## A UUID.
+	var player_id: String = ""
+	var name: String = ""
+	var score: int = 0
+	var kills: int = 0
+	var deaths: int = 0
+	## Measured for UDP clients only. Omitted when unset.
+	var ping_ms: int = 0
+
+	static func from_dict(d: Dictionary) -> MatchResult:
+		var m := MatchResult.new()
+		if d.has("rank"):
+			m.rank = int(d["rank"])
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("score"):
+			m.score = int(d["score"])
+		if d.has("kills"):
+			m.kills = int(d["kills"])
+		if d.has("deaths"):
+			m.deaths = int(d["deaths"])
+		if d.has("ping_ms"):
+			m.ping_ms = int(d["ping_ms"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["rank"] = rank
+		d["player_id"] = player_id
+		d["name"] = name
+		d["score"] = score
+		d["kills"] = kills
+		d["deaths"] = deaths
+		if ping_ms != 0:
+			d["ping_ms"] = ping_ms
+		return d
+
+
+## CreateRoomData asks to open a new match room playing Mode, or GAME_MODE if empty, and move the sender into it. The sender owns the room and may send PauseRoom and ResumeRoom. TickDivisor slows the room down to run on every TickDivisor-th tick of the game loop, up to 10; 0 runs it every tick.
+class CreateRoomData:
+	## Omitted when unset.
+	var mode: String = ""
+	## Omitted when unset.
+	var tick_divisor: int = 0
+
+	static func from_dict(d: Dictionary) -> CreateRoomData:
+		var m := CreateRoomData.new()
+		if d.has("mode"):
+			m.mode = d["mode"]
+		if d.has("tick_divisor"):
+			m.tick_divisor = int(d["tick_divisor"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if mode != "":
+			d["mode"] = mode
+		if tick_divisor != 0:
+			d["tick_divisor"] = tick_divisor
+		return d
+
+
+class RoomCreatedData:
+	var room: String = ""
+	## Omitted when unset.
+	var mode: String = ""
+
+	static func from_dict(d: Dictionary) -> RoomCreatedData:
+		var m := RoomCreatedData.new()
+		if d.has("room"):
+			m.room = d["room"]
+		if d.has("mode"):
+			m.mode = d["mode"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["room"] = room
+		if mode != "":
+			d["mode"] = mode
+		return d
+
+
+## GameModeStateData tells the members of a room how its round stands. It is sent when a round starts and whenever a score, flag or hill changes.
+class GameModeStateData:
+	var room: String = ""
+	var mode: String = ""
+	## Captures by team in ctf, seconds held by player ID in koth.
+	var scores: Dictionary = {}
+	## Omitted when unset.
+	var flags: Array = []
+	## Omitted when unset.
+	var hill: HillState = null
+
+	static func from_dict(d: Dictionary) -> GameModeStateData:
+		var m := GameModeStateData.new()
+		if d.has("room"):
+			m.room = d["room"]
+		if d.has("mode"):
+			m.mode = d["mode"]
+		if d.has("scores"):
+			m.scores = d["scores"]
+		if d.has("flags"):
+			m.flags = d["flags"].map(func(e): return FlagState.from_dict(e))
+		if d.has("hill"):
+			m.hill = HillState.from_dict(d["hill"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["room"] = room
+		d["mode"] = mode
+		d["scores"] = scores
+		if flags != []:
+			d["flags"] = flags.map(func(e): return e.to_dict())
+		if hill != null:
+			d["hill"] = hill.to_dict()
+		return d
+
+
+## FlagState is a flag of capture the flag, standing at its team's base unless a player carries it.
+class FlagState:
+	var team: String = ""
+	var x: float = 0.0
+	var y: float = 0.0
+	## A UUID. Omitted when unset.
+	var carrier_id = null
+
+	static func from_dict(d: Dictionary) -> FlagState:
+		var m := FlagState.new()
+		if d.has("team"):
+			m.team = d["team"]
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("carrier_id"):
+			m.carrier_id = d["carrier_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["team"] = team
+		d["x"] = x
+		d["y"] = y
+		if carrier_id != null:
+			d["carrier_id"] = carrier_id
+		return d
+
+
+## HillState is the hill of king of the hill, with the player holding it.
+class HillState:
+	var x: float = 0.0
+	var y: float = 0.0
+	var radius: float = 0.0
+	## A UUID. Omitted when unset.
+	var holder_id = null
+
+	static func from_dict(d: Dictionary) -> HillState:
+		var m := HillState.new()
+		if d.has("x"):
+			m.x = float(d["x"])
+		if d.has("y"):
+			m.y = float(d["y"])
+		if d.has("radius"):
+			m.radius = float(d["radius"])
+		if d.has("holder_id"):
+			m.holder_id = d["holder_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["x"] = x
+		d["y"] = y
+		d["radius"] = radius
+		if holder_id != null:
+			d["holder_id"] = holder_id
+		return d
+
+
+## GameModeWonData tells the members of a room who won the round, a team in ctf or a player ID in koth. The next round starts at once.
+class GameModeWonData:
+	var room: String = ""
+	var mode: String = ""
+	var winner: String = ""
+
+	static func from_dict(d: Dictionary) -> GameModeWonData:
+		var m := GameModeWonData.new()
+		if d.has("room"):
+			m.room = d["room"]
+		if d.has("mode"):
+			m.mode = d["mode"]
+		if d.has("winner"):
+			m.winner = d["winner"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["room"] = room
+		d["mode"] = mode
+		d["winner"] = winner
+		return d
+
+
+## StartVoteData asks to start a vote of Type, one of next_map (Target is a zone), kick_player (Target is a player ID) or restart_match (no Target).
+class StartVoteData:
+	var type: String = ""
+	## Omitted when unset.
+	var target: String = ""
+
+	static func from_dict(d: Dictionary) -> StartVoteData:
+		var m := StartVoteData.new()
+		if d.has("type"):
+			m.type = d["type"]
+		if d.has("target"):
+			m.target = d["target"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["type"] = type
+		if target != "":
+			d["target"] = target
+		return d
+
+
+## CastVoteData votes in the running vote. Votes may be changed until it ends.
+class CastVoteData:
+	var yes: bool = false
+
+	static func from_dict(d: Dictionary) -> CastVoteData:
+		var m := CastVoteData.new()
+		if d.has("yes"):
+			m.yes = d["yes"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["yes"] = yes
+		return d
+
+
+## VoteStatusData tells everyone how the running vote stands. It is sent when the vote starts, on every ballot and every few seconds as a countdown.
+class VoteStatusData:
+	var type: String = ""
+	## Omitted when unset.
+	var target: String = ""
+	## A UUID.
+	var started_by: String = ""
+	var yes: int = 0
+	var no: int = 0
+	## Players online, who may all vote.
+	var eligible: int = 0
+	## Unix milliseconds.
+	var ends_at: int = 0
+	var seconds_left: int = 0
+
+	static func from_dict(d: Dictionary) -> VoteStatusData:
+		var m := VoteStatusData.new()
+		if d.has("type"):
+			m.type = d["type"]
+		if d.has("target"):
+			m.target = d["target"]
+		if d.has("started_by"):
+			m.started_by = d["started_by"]
+		if d.has("yes"):
+			m.yes = int(d["yes"])
+		if d.has("no"):
+			m.no = int(d["no"])
+		if d.has("eligible"):
+			m.eligible = int(d["eligible"])
+		if d.has("ends_at"):
+			m.ends_at = int(d["ends_at"])
+		if d.has("seconds_left"):
+			m.seconds_left = int(d["seconds_left"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["type"] = type
+		if target != "":
+			d["target"] = target
+		d["started_by"] = started_by
+		d["yes"] = yes
+		d["no"] = no
+		d["eligible"] = eligible
+		d["ends_at"] = ends_at
+		d["seconds_left"] = seconds_left
+		return d
+
+
+## VoteEndedData tells everyone the outcome of a vote, which is enforced at once if it passed.
+class VoteEndedData:
+	var type: String = ""
+	## Omitted when unset.
+	var target: String = ""
+	var yes: int = 0
+	var no: int = 0
+	var eligible: int = 0
+	var passed: bool = false
+
+	static func from_dict(d: Dictionary) -> VoteEndedData:
+		var m := VoteEndedData.new()
+		if d.has("type"):
+			m.type = d["type"]
+		if d.has("target"):
+			m.target = d["target"]
+		if d.has("yes"):
+			m.yes = int(d["yes"])
+		if d.has("no"):
+			m.no = int(d["no"])
+		if d.has("eligible"):
+			m.eligible = int(d["eligible"])
+		if d.has("passed"):
+			m.passed = d["passed"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["type"] = type
+		if target != "":
+			d["target"] = target
+		d["yes"] = yes
+		d["no"] = no
+		d["eligible"] = eligible
+		d["passed"] = passed
+		return d
+
+
+## RoomPauseData names the room a RoomPaused or RoomResumed message is about.
+class RoomPauseData:
+	var room: String = ""
+
+	static func from_dict(d: Dictionary) -> RoomPauseData:
+		var m := RoomPauseData.new()
+		if d.has("room"):
+			m.room = d["room"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["room"] = room
+		return d
+
+
+## WorldEventData announces a world event; State is "started" or "ended".
+class WorldEventData:
+	var state: String = ""
+	var type: String = ""
+	var description: String = ""
+	var points_multiplier: int = 0
+	## Omitted when unset.
+	var bonus_item: String = ""
+	## An RFC 3339 time.
+	var started_at: String = ""
+	## An RFC 3339 time.
+	var ends_at: String = ""
+
+	static func from_dict(d: Dictionary) -> WorldEventData:
+		var m := WorldEventData.new()
+		if d.has("state"):
+			m.state = d["state"]
+		if d.has("type"):
+			m.type = d["type"]
+		if d.has("description"):
+			m.description = d["description"]
+		if d.has("points_multiplier"):
+			m.points_multiplier = int(d["points_multiplier"])
+		if d.has("bonus_item"):
+			m.bonus_item = d["bonus_item"]
+		if d.has("started_at"):
+			m.started_at = d["started_at"]
+		if d.has("ends_at"):
+			m.ends_at = d["ends_at"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["state"] = state
+		d["type"] = type
+		d["description"] = description
+		d["points_multiplier"] = points_multiplier
+		if bonus_item != "":
+			d["bonus_item"] = bonus_item
+		d["started_at"] = started_at
+		d["ends_at"] = ends_at
+		return d
+
+
+## WorldEvent is a running world event.
+class WorldEvent:
+	var type: String = ""
+	var description: String = ""
+	var points_multiplier: int = 0
+	## Omitted when unset.
+	var bonus_item: String = ""
+	## An RFC 3339 time.
+	var started_at: String = ""
+	## An RFC 3339 time.
+	var ends_at: String = ""
+
+	static func from_dict(d: Dictionary) -> WorldEvent:
+		var m := WorldEvent.new()
+		if d.has("type"):
+			m.type = d["type"]
+		if d.has("description"):
+			m.description = d["description"]
+		if d.has("points_multiplier"):
+			m.points_multiplier = int(d["points_multiplier"])
+		if d.has("bonus_item"):
+			m.bonus_item = d["bonus_item"]
+		if d.has("started_at"):
+			m.started_at = d["started_at"]
+		if d.has("ends_at"):
+			m.ends_at = d["ends_at"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["type"] = type
+		d["description"] = description
+		d["points_multiplier"] = points_multiplier
+		if bonus_item != "":
+			d["bonus_item"] = bonus_item
+		d["started_at"] = started_at
+		d["ends_at"] = ends_at
+		return d
+
+
+## GameRules are the tunable numbers of the game. Fields missing from the rules file keep their defaults.
+class GameRules:
+	var pickup_score: int = 0
+	var attack_damage: float = 0.0
+	## 0 leaves killed players dead.
+	var respawn_seconds: float = 0.0
+	## Health players come back with.
+	var respawn_health: float = 0.0
+	## Awarded to the killer.
+	var kill_score: int = 0
+	## Taken from the killed player, down to zero.
+	var death_score_penalty: int = 0
+	## Cap on steered velocities, in units per second.
+	var max_move_speed: float = 0.0
+	## Impulse an attack gives its target, 0 for none.
+	var knockback_speed: float = 0.0
+	## Votes configures each type of vote players may start. A type given in the rules file replaces its default rule as a whole.
+	var votes: Dictionary = {}
+
+	static func from_dict(d: Dictionary) -> GameRules:
+		var m := GameRules.new()
+		if d.has("pickup_score"):
+			m.pickup_score = int(d["pickup_score"])
+		if d.has("attack_damage"):
+			m.attack_damage = float(d["attack_damage"])
+		if d.has("respawn_seconds"):
+			m.respawn_seconds = float(d["respawn_seconds"])
+		if d.has("respawn_health"):
+			m.respawn_health = float(d["respawn_health"])
+		if d.has("kill_score"):
+			m.kill_score = int(d["kill_score"])
+		if d.has("death_score_penalty"):
+			m.death_score_penalty = int(d["death_score_penalty"])
+		if d.has("max_move_speed"):
+			m.max_move_speed = float(d["max_move_speed"])
+		if d.has("knockback_speed"):
+			m.knockback_speed = float(d["knockback_speed"])
+		if d.has("votes"):
+			m.votes = GameMessages._map_values(d["votes"], func(e): return VoteRule.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["pickup_score"] = pickup_score
+		d["attack_damage"] = attack_damage
+		d["respawn_seconds"] = respawn_seconds
+		d["respawn_health"] = respawn_health
+		d["kill_score"] = kill_score
+		d["death_score_penalty"] = death_score_penalty
+		d["max_move_speed"] = max_move_speed
+		d["knockback_speed"] = knockback_speed
+		d["votes"] = GameMessages._map_values(votes, func(e): return e.to_dict())
+		return d
+
+
+## VoteRule configures a type of vote. A vote passes if at least Quorum of the players online voted and at least Threshold of those votes are yes.
+class VoteRule:
+	## 0 disables the type.
+	var duration_seconds: float = 0.0
+	## Share of online players who must vote.
+	var quorum: float = 0.0
+	## Share of votes cast that must be yes.
+	var threshold: float = 0.0
+
+	static func from_dict(d: Dictionary) -> VoteRule:
+		var m := VoteRule.new()
+		if d.has("duration_seconds"):
+			m.duration_seconds = float(d["duration_seconds"])
+		if d.has("quorum"):
+			m.quorum = float(d["quorum"])
+		if d.has("threshold"):
+			m.threshold = float(d["threshold"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["duration_seconds"] = duration_seconds
+		d["quorum"] = quorum
+		d["threshold"] = threshold
+		return d
+
+
+class ChatData:
+	## A UUID.
+	var player_id: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> ChatData:
+		var m := ChatData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["message"] = message
+		return d
+
+
+## MutedData tells a player they may not chat until Until, or that their mute was lifted when Until is 0.
+class MutedData:
+	## Unix milliseconds. Omitted when unset.
+	var until: int = 0
+	## Omitted when unset.
+	var reason: String = ""
+
+	static func from_dict(d: Dictionary) -> MutedData:
+		var m := MutedData.new()
+		if d.has("until"):
+			m.until = int(d["until"])
+		if d.has("reason"):
+			m.reason = d["reason"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if until != 0:
+			d["until"] = until
+		if reason != "":
+			d["reason"] = reason
+		return d
+
+
+## WhisperRequestData is sent by a client to whisper to another player.
+class WhisperRequestData:
+	## A UUID.
+	var target_id: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> WhisperRequestData:
+		var m := WhisperRequestData.new()
+		if d.has("target_id"):
+			m.target_id = d["target_id"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["target_id"] = target_id
+		d["message"] = message
+		return d
+
+
+## WhisperData is delivered to the whisper's recipient.
+class WhisperData:
+	## A UUID.
+	var player_id: String = ""
+	var name: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> WhisperData:
+		var m := WhisperData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		d["message"] = message
+		return d
+
+
+## FriendData is the payload of FriendAdd, FriendAccept and FriendRemove.
+class FriendData:
+	## A UUID.
+	var friend_id: String = ""
+
+	static func from_dict(d: Dictionary) -> FriendData:
+		var m := FriendData.new()
+		if d.has("friend_id"):
+			m.friend_id = d["friend_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["friend_id"] = friend_id
+		return d
+
+
+class FriendListData:
+	var friends: Array = []
+
+	static func from_dict(d: Dictionary) -> FriendListData:
+		var m := FriendListData.new()
+		if d.has("friends"):
+			m.friends = d["friends"].map(func(e): return FriendEntry.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["friends"] = friends.map(func(e): return e.to_dict())
+		return d
+
+
+class FriendEntry:
+	var player_id: String = ""
+	var name: String = ""
+	## "pending" or "accepted".
+	var status: String = ""
+	## True if the other player sent the request.
+	var incoming: bool = false
+	var online: bool = false
+
+	static func from_dict(d: Dictionary) -> FriendEntry:
+		var m := FriendEntry.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("status"):
+			m.status = d["status"]
+		if d.has("incoming"):
+			m.incoming = d["incoming"]
+		if d.has("online"):
+			m.online = d["online"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		d["status"] = status
+		d["incoming"] = incoming
+		d["online"] = online
+		return d
+
+
+## Friendship is a friend relation as seen from one player.
+class Friendship:
+	var player_id: String = ""
+	var name: String = ""
+	## "pending" or "accepted".
+	var status: String = ""
+	## True if the other player sent the request.
+	var incoming: bool = false
+
+	static func from_dict(d: Dictionary) -> Friendship:
+		var m := Friendship.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("status"):
+			m.status = d["status"]
+		if d.has("incoming"):
+			m.incoming = d["incoming"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		d["status"] = status
+		d["incoming"] = incoming
+		return d
+
+
+## FriendPresenceData is sent with FriendOnline, FriendOffline, FriendRequest and FriendAccepted notifications.
+class FriendPresenceData:
+	## A UUID.
+	var player_id: String = ""
+	## Omitted when unset.
+	var name: String = ""
+
+	static func from_dict(d: Dictionary) -> FriendPresenceData:
+		var m := FriendPresenceData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		if name != "":
+			d["name"] = name
+		return d
+
+
+class PartyInviteData:
+	## A UUID.
+	var target_id: String = ""
+
+	static func from_dict(d: Dictionary) -> PartyInviteData:
+		var m := PartyInviteData.new()
+		if d.has("target_id"):
+			m.target_id = d["target_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["target_id"] = target_id
+		return d
+
+
+class PartyAcceptData:
+	var party_id: String = ""
+
+	static func from_dict(d: Dictionary) -> PartyAcceptData:
+		var m := PartyAcceptData.new()
+		if d.has("party_id"):
+			m.party_id = d["party_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["party_id"] = party_id
+		return d
+
+
+class PartyChatData:
+	## Omitted when unset.
+	var party_id: String = ""
+	## A UUID.
+	var player_id: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> PartyChatData:
+		var m := PartyChatData.new()
+		if d.has("party_id"):
+			m.party_id = d["party_id"]
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if party_id != "":
+			d["party_id"] = party_id
+		d["player_id"] = player_id
+		d["message"] = message
+		return d
+
+
+## PartyInvitedData is sent to a player invited to a party.
+class PartyInvitedData:
+	var party_id: String = ""
+	## A UUID.
+	var player_id: String = ""
+	var name: String = ""
+
+	static func from_dict(d: Dictionary) -> PartyInvitedData:
+		var m := PartyInvitedData.new()
+		if d.has("party_id"):
+			m.party_id = d["party_id"]
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["party_id"] = party_id
+		d["player_id"] = player_id
+		d["name"] = name
+		return d
+
+
+class PartyUpdateData:
+	var party_id: String = ""
+	## A UUID.
+	var leader_id: String = ""
+	var members: Array = []
+	## Omitted when unset.
+	var room: String = ""
+
+	static func from_dict(d: Dictionary) -> PartyUpdateData:
+		var m := PartyUpdateData.new()
+		if d.has("party_id"):
+			m.party_id = d["party_id"]
+		if d.has("leader_id"):
+			m.leader_id = d["leader_id"]
+		if d.has("members"):
+			m.members = d["members"]
+		if d.has("room"):
+			m.room = d["room"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["party_id"] = party_id
+		d["leader_id"] = leader_id
+		d["members"] = members
+		if room != "":
+			d["room"] = room
+		return d
+
+
+class PartyLeftData:
+	var party_id: String = ""
+
+	static func from_dict(d: Dictionary) -> PartyLeftData:
+		var m := PartyLeftData.new()
+		if d.has("party_id"):
+			m.party_id = d["party_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["party_id"] = party_id
+		return d
+
+
+## GuildCreateData creates a guild led by the sender.
+class GuildCreateData:
+	var name: String = ""
+	var tag: String = ""
+
+	static func from_dict(d: Dictionary) -> GuildCreateData:
+		var m := GuildCreateData.new()
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("tag"):
+			m.tag = d["tag"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["name"] = name
+		d["tag"] = tag
+		return d
+
+
+class GuildJoinData:
+	var tag: String = ""
+
+	static func from_dict(d: Dictionary) -> GuildJoinData:
+		var m := GuildJoinData.new()
+		if d.has("tag"):
+			m.tag = d["tag"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["tag"] = tag
+		return d
+
+
+## GuildPromoteData makes a member an officer, or an officer the leader.
+class GuildPromoteData:
+	## A UUID.
+	var player_id: String = ""
+
+	static func from_dict(d: Dictionary) -> GuildPromoteData:
+		var m := GuildPromoteData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		return d
+
+
+class GuildChatData:
+	## Omitted when unset.
+	var guild_id: String = ""
+	## A UUID.
+	var player_id: String = ""
+	## Omitted when unset.
+	var name: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> GuildChatData:
+		var m := GuildChatData.new()
+		if d.has("guild_id"):
+			m.guild_id = d["guild_id"]
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if guild_id != "":
+			d["guild_id"] = guild_id
+		d["player_id"] = player_id
+		if name != "":
+			d["name"] = name
+		d["message"] = message
+		return d
+
+
+## GuildUpdateData is the player's guild and its members, sent when either changes and in reply to GuildInfoRequest.
+class GuildUpdateData:
+	var guild_id: String = ""
+	var name: String = ""
+	var tag: String = ""
+	## An RFC 3339 time.
+	var created_at: String = ""
+	var members: Array = []
+
+	static func from_dict(d: Dictionary) -> GuildUpdateData:
+		var m := GuildUpdateData.new()
+		if d.has("guild_id"):
+			m.guild_id = d["guild_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("tag"):
+			m.tag = d["tag"]
+		if d.has("created_at"):
+			m.created_at = d["created_at"]
+		if d.has("members"):
+			m.members = d["members"].map(func(e): return GuildMemberEntry.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["guild_id"] = guild_id
+		d["name"] = name
+		d["tag"] = tag
+		d["created_at"] = created_at
+		d["members"] = members.map(func(e): return e.to_dict())
+		return d
+
+
+## Guild is a persistent group of players. Its tag is shown next to its members' names.
+class Guild:
+	var guild_id: String = ""
+	var name: String = ""
+	var tag: String = ""
+	## An RFC 3339 time.
+	var created_at: String = ""
+
+	static func from_dict(d: Dictionary) -> Guild:
+		var m := Guild.new()
+		if d.has("guild_id"):
+			m.guild_id = d["guild_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("tag"):
+			m.tag = d["tag"]
+		if d.has("created_at"):
+			m.created_at = d["created_at"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["guild_id"] = guild_id
+		d["name"] = name
+		d["tag"] = tag
+		d["created_at"] = created_at
+		return d
+
+
+class GuildMemberEntry:
+	var player_id: String = ""
+	var name: String = ""
+	var rank: String = ""
+	## An RFC 3339 time.
+	var joined_at: String = ""
+	var online: bool = false
+
+	static func from_dict(d: Dictionary) -> GuildMemberEntry:
+		var m := GuildMemberEntry.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("rank"):
+			m.rank = d["rank"]
+		if d.has("joined_at"):
+			m.joined_at = d["joined_at"]
+		if d.has("online"):
+			m.online = d["online"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		d["rank"] = rank
+		d["joined_at"] = joined_at
+		d["online"] = online
+		return d
+
+
+class GuildMember:
+	var player_id: String = ""
+	var name: String = ""
+	var rank: String = ""
+	## An RFC 3339 time.
+	var joined_at: String = ""
+
+	static func from_dict(d: Dictionary) -> GuildMember:
+		var m := GuildMember.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("rank"):
+			m.rank = d["rank"]
+		if d.has("joined_at"):
+			m.joined_at = d["joined_at"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		d["rank"] = rank
+		d["joined_at"] = joined_at
+		return d
+
+
+## GuildLeftData tells a player they are no longer in a guild. It is also the reply to GuildInfoRequest from a player in none, with no guild ID.
+class GuildLeftData:
+	## Omitted when unset.
+	var guild_id: String = ""
+
+	static func from_dict(d: Dictionary) -> GuildLeftData:
+		var m := GuildLeftData.new()
+		if d.has("guild_id"):
+			m.guild_id = d["guild_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if guild_id != "":
+			d["guild_id"] = guild_id
+		return d
+
+
+class MailSendData:
+	## A UUID.
+	var recipient_id: String = ""
+	var subject: String = ""
+	var body: String = ""
+
+	static func from_dict(d: Dictionary) -> MailSendData:
+		var m := MailSendData.new()
+		if d.has("recipient_id"):
+			m.recipient_id = d["recipient_id"]
+		if d.has("subject"):
+			m.subject = d["subject"]
+		if d.has("body"):
+			m.body = d["body"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["recipient_id"] = recipient_id
+		d["subject"] = subject
+		d["body"] = body
+		return d
+
+
+## MailIDData names one mail in MailRead and MailDelete requests, and the mail a MailSent confirms.
+class MailIDData:
+	var mail_id: int = 0
+
+	static func from_dict(d: Dictionary) -> MailIDData:
+		var m := MailIDData.new()
+		if d.has("mail_id"):
+			m.mail_id = int(d["mail_id"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["mail_id"] = mail_id
+		return d
+
+
+## MailData is sent with MailReceived, the mail that arrived while the player was away or just now, and MailList, the whole mailbox.
+class MailData:
+	var mail: Array = []
+
+	static func from_dict(d: Dictionary) -> MailData:
+		var m := MailData.new()
+		if d.has("mail"):
+			m.mail = d["mail"].map(func(e): return Mail.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["mail"] = mail.map(func(e): return e.to_dict())
+		return d
+
+
+## Mail is a message a player sent another, who need not be online.
+class Mail:
+	var mail_id: int = 0
+	var sender_id: String = ""
+	var sender_name: String = ""
+	var recipient_id: String = ""
+	var subject: String = ""
+	var body: String = ""
+	## An RFC 3339 time.
+	var sent_at: String = ""
+	## An RFC 3339 time. Omitted when unset.
+	var read_at = null
+
+	static func from_dict(d: Dictionary) -> Mail:
+		var m := Mail.new()
+		if d.has("mail_id"):
+			m.mail_id = int(d["mail_id"])
+		if d.has("sender_id"):
+			m.sender_id = d["sender_id"]
+		if d.has("sender_name"):
+			m.sender_name = d["sender_name"]
+		if d.has("recipient_id"):
+			m.recipient_id = d["recipient_id"]
+		if d.has("subject"):
+			m.subject = d["subject"]
+		if d.has("body"):
+			m.body = d["body"]
+		if d.has("sent_at"):
+			m.sent_at = d["sent_at"]
+		if d.has("read_at"):
+			m.read_at = d["read_at"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["mail_id"] = mail_id
+		d["sender_id"] = sender_id
+		d["sender_name"] = sender_name
+		d["recipient_id"] = recipient_id
+		d["subject"] = subject
+		d["body"] = body
+		d["sent_at"] = sent_at
+		if read_at != null:
+			d["read_at"] = read_at
+		return d
+
+
+class PlayerStatsRequestData:
+	## A UUID.
+	var player_id: String = ""
+
+	static func from_dict(d: Dictionary) -> PlayerStatsRequestData:
+		var m := PlayerStatsRequestData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		return d
+
+
+class PlayerStatsData:
+	## A UUID.
+	var player_id: String = ""
+	var kills: int = 0
+	var deaths: int = 0
+	var assists: int = 0
+	var items_collected: int = 0
+	var playtime_seconds: int = 0
+
+	static func from_dict(d: Dictionary) -> PlayerStatsData:
+		var m := PlayerStatsData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("kills"):
+			m.kills = int(d["kills"])
+		if d.has("deaths"):
+			m.deaths = int(d["deaths"])
+		if d.has("assists"):
+			m.assists = int(d["assists"])
+		if d.has("items_collected"):
+			m.items_collected = int(d["items_collected"])
+		if d.has("playtime_seconds"):
+			m.playtime_seconds = int(d["playtime_seconds"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["kills"] = kills
+		d["deaths"] = deaths
+		d["assists"] = assists
+		d["items_collected"] = items_collected
+		d["playtime_seconds"] = playtime_seconds
+		return d
+
+
+class LeaderboardRequestData:
+	## Daily, weekly, monthly or all.
+	var window: String = ""
+	## Omitted when unset.
+	var limit: int = 0
+
+	static func from_dict(d: Dictionary) -> LeaderboardRequestData:
+		var m := LeaderboardRequestData.new()
+		if d.has("window"):
+			m.window = d["window"]
+		if d.has("limit"):
+			m.limit = int(d["limit"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["window"] = window
+		if limit != 0:
+			d["limit"] = limit
+		return d
+
+
+class LeaderboardResponseData:
+	var window: String = ""
+	var period_start: String = ""
+	var entries: Array = []
+
+	static func from_dict(d: Dictionary) -> LeaderboardResponseData:
+		var m := LeaderboardResponseData.new()
+		if d.has("window"):
+			m.window = d["window"]
+		if d.has("period_start"):
+			m.period_start = d["period_start"]
+		if d.has("entries"):
+			m.entries = d["entries"].map(func(e): return WindowedLeaderboardEntry.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["window"] = window
+		d["period_start"] = period_start
+		d["entries"] = entries.map(func(e): return e.to_dict())
+		return d
+
+
+class WindowedLeaderboardEntry:
+	var rank: int = 0
+	var player_id: String = ""
+	var name: String = ""
+	var score: int = 0
+
+	static func from_dict(d: Dictionary) -> WindowedLeaderboardEntry:
+		var m := WindowedLeaderboardEntry.new()
+		if d.has("rank"):
+			m.rank = int(d["rank"])
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("score"):
+			m.score = int(d["score"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["rank"] = rank
+		d["player_id"] = player_id
+		d["name"] = name
+		d["score"] = score
+		return d
+
+
+class WindowedScore:
+	var player_id: String = ""
+	var name: String = ""
+	var score: int = 0
+
+	static func from_dict(d: Dictionary) -> WindowedScore:
+		var m := WindowedScore.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("score"):
+			m.score = int(d["score"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["name"] = name
+		d["score"] = score
+		return d
+
+
+class GuildLeaderboardRequestData:
+	## Omitted when unset.
+	var limit: int = 0
+
+	static func from_dict(d: Dictionary) -> GuildLeaderboardRequestData:
+		var m := GuildLeaderboardRequestData.new()
+		if d.has("limit"):
+			m.limit = int(d["limit"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if limit != 0:
+			d["limit"] = limit
+		return d
+
+
+class GuildLeaderboardData:
+	var entries: Array = []
+
+	static func from_dict(d: Dictionary) -> GuildLeaderboardData:
+		var m := GuildLeaderboardData.new()
+		if d.has("entries"):
+			m.entries = d["entries"].map(func(e): return GuildLeaderboardEntry.from_dict(e))
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["entries"] = entries.map(func(e): return e.to_dict())
+		return d
+
+
+class GuildLeaderboardEntry:
+	var rank: int = 0
+	var guild_id: String = ""
+	var name: String = ""
+	var tag: String = ""
+	var members: int = 0
+	var score: int = 0
+
+	static func from_dict(d: Dictionary) -> GuildLeaderboardEntry:
+		var m := GuildLeaderboardEntry.new()
+		if d.has("rank"):
+			m.rank = int(d["rank"])
+		if d.has("guild_id"):
+			m.guild_id = d["guild_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("tag"):
+			m.tag = d["tag"]
+		if d.has("members"):
+			m.members = int(d["members"])
+		if d.has("score"):
+			m.score = int(d["score"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["rank"] = rank
+		d["guild_id"] = guild_id
+		d["name"] = name
+		d["tag"] = tag
+		d["members"] = members
+		d["score"] = score
+		return d
+
+
+## GuildScore is a guild's standing on the guild leaderboard, the sum of its members' scores.
+class GuildScore:
+	var guild_id: String = ""
+	var name: String = ""
+	var tag: String = ""
+	var members: int = 0
+	var score: int = 0
+
+	static func from_dict(d: Dictionary) -> GuildScore:
+		var m := GuildScore.new()
+		if d.has("guild_id"):
+			m.guild_id = d["guild_id"]
+		if d.has("name"):
+			m.name = d["name"]
+		if d.has("tag"):
+			m.tag = d["tag"]
+		if d.has("members"):
+			m.members = int(d["members"])
+		if d.has("score"):
+			m.score = int(d["score"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["guild_id"] = guild_id
+		d["name"] = name
+		d["tag"] = tag
+		d["members"] = members
+		d["score"] = score
+		return d
+
+
+## PrivacySettingsData is the payload of a PrivacySettings request. Only the flags that are set are changed; an empty request reads the settings.
+class PrivacySettingsData:
+	## Omitted when unset.
+	var no_chat_log = null
+	## Omitted when unset.
+	var no_ip_storage = null
+	## Omitted when unset.
+	var anonymize_events = null
+
+	static func from_dict(d: Dictionary) -> PrivacySettingsData:
+		var m := PrivacySettingsData.new()
+		if d.has("no_chat_log"):
+			m.no_chat_log = d["no_chat_log"]
+		if d.has("no_ip_storage"):
+			m.no_ip_storage = d["no_ip_storage"]
+		if d.has("anonymize_events"):
+			m.anonymize_events = d["anonymize_events"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if no_chat_log != null:
+			d["no_chat_log"] = no_chat_log
+		if no_ip_storage != null:
+			d["no_ip_storage"] = no_ip_storage
+		if anonymize_events != null:
+			d["anonymize_events"] = anonymize_events
+		return d
+
+
+## PrivacySettings are a player's choices about the data stored about them. Database methods enforce them when writing, so every caller honours them.
+class PrivacySettings:
+	var no_chat_log: bool = false
+	var no_ip_storage: bool = false
+	var anonymize_events: bool = false
+
+	static func from_dict(d: Dictionary) -> PrivacySettings:
+		var m := PrivacySettings.new()
+		if d.has("no_chat_log"):
+			m.no_chat_log = d["no_chat_log"]
+		if d.has("no_ip_storage"):
+			m.no_ip_storage = d["no_ip_storage"]
+		if d.has("anonymize_events"):
+			m.anonymize_events = d["anonymize_events"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["no_chat_log"] = no_chat_log
+		d["no_ip_storage"] = no_ip_storage
+		d["anonymize_events"] = anonymize_events
+		return d
+
+
+## SetPreferencesData sets and removes preferences by name, leaving the player's other preferences as they are.
+class SetPreferencesData:
+	## Omitted when unset.
+	var preferences: Dictionary = {}
+	## Omitted when unset.
+	var remove: Array = []
+
+	static func from_dict(d: Dictionary) -> SetPreferencesData:
+		var m := SetPreferencesData.new()
+		if d.has("preferences"):
+			m.preferences = d["preferences"]
+		if d.has("remove"):
+			m.remove = d["remove"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if preferences != {}:
+			d["preferences"] = preferences
+		if remove != []:
+			d["remove"] = remove
+		return d
+
+
+## PreferencesData is every preference the player saved, by name.
+class PreferencesData:
+	var preferences: Dictionary = {}
+
+	static func from_dict(d: Dictionary) -> PreferencesData:
+		var m := PreferencesData.new()
+		if d.has("preferences"):
+			m.preferences = d["preferences"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["preferences"] = preferences
+		return d
+
+
+## ConnectionQualityData reports a UDP client's link over the last interval. PacketLoss covers packets from the client, RetransmitRate packets to it.
+class ConnectionQualityData:
+	var ping_ms: float = 0.0
+	var jitter_ms: float = 0.0
+	var packet_loss: float = 0.0
+	var retransmit_rate: float = 0.0
+	var degraded: bool = false
+
+	static func from_dict(d: Dictionary) -> ConnectionQualityData:
+		var m := ConnectionQualityData.new()
+		if d.has("ping_ms"):
+			m.ping_ms = float(d["ping_ms"])
+		if d.has("jitter_ms"):
+			m.jitter_ms = float(d["jitter_ms"])
+		if d.has("packet_loss"):
+			m.packet_loss = float(d["packet_loss"])
+		if d.has("retransmit_rate"):
+			m.retransmit_rate = float(d["retransmit_rate"])
+		if d.has("degraded"):
+			m.degraded = d["degraded"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["ping_ms"] = ping_ms
+		d["jitter_ms"] = jitter_ms
+		d["packet_loss"] = packet_loss
+		d["retransmit_rate"] = retransmit_rate
+		d["degraded"] = degraded
+		return d
+
+
+class ErrorData:
+	## Set for rejected input, see InputError. Omitted when unset.
+	var code: String = ""
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> ErrorData:
+		var m := ErrorData.new()
+		if d.has("code"):
+			m.code = d["code"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if code != "":
+			d["code"] = code
+		d["message"] = message
+		return d
+
+
+## AnnouncementData is an operator message shown to every player.
+class AnnouncementData:
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> AnnouncementData:
+		var m := AnnouncementData.new()
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["message"] = message
+		return d
+
+
+## ModerateData is a staff command against another player: mute them from chat for DurationSeconds (10 minutes if 0), unmute them or kick them. The sender's account role must allow it.
+class ModerateData:
+	## Mute, unmute or kick.
+	var action: String = ""
+	## A UUID.
+	var player_id: String = ""
+	## Omitted when unset.
+	var duration_seconds: int = 0
+	## Omitted when unset.
+	var reason: String = ""
+
+	static func from_dict(d: Dictionary) -> ModerateData:
+		var m := ModerateData.new()
+		if d.has("action"):
+			m.action = d["action"]
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("duration_seconds"):
+			m.duration_seconds = int(d["duration_seconds"])
+		if d.has("reason"):
+			m.reason = d["reason"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["action"] = action
+		d["player_id"] = player_id
+		if duration_seconds != 0:
+			d["duration_seconds"] = duration_seconds
+		if reason != "":
+			d["reason"] = reason
+		return d
+
+
+## ModeratedData confirms a Moderate command.
+class ModeratedData:
+	var action: String = ""
+	## A UUID.
+	var player_id: String = ""
+	## Unix milliseconds, end of a mute. Omitted when unset.
+	var until: int = 0
+
+	static func from_dict(d: Dictionary) -> ModeratedData:
+		var m := ModeratedData.new()
+		if d.has("action"):
+			m.action = d["action"]
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("until"):
+			m.until = int(d["until"])
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["action"] = action
+		d["player_id"] = player_id
+		if until != 0:
+			d["until"] = until
+		return d
+
+
+## ReportPlayerData reports another player to staff, with an optional excerpt of the chat that prompted it.
+class ReportPlayerData:
+	## A UUID.
+	var player_id: String = ""
+	var reason: String = ""
+	## Omitted when unset.
+	var chat_excerpt: String = ""
+
+	static func from_dict(d: Dictionary) -> ReportPlayerData:
+		var m := ReportPlayerData.new()
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		if d.has("reason"):
+			m.reason = d["reason"]
+		if d.has("chat_excerpt"):
+			m.chat_excerpt = d["chat_excerpt"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["player_id"] = player_id
+		d["reason"] = reason
+		if chat_excerpt != "":
+			d["chat_excerpt"] = chat_excerpt
+		return d
+
+
+## ReportFiledData confirms a ReportPlayer message.
+class ReportFiledData:
+	var report_id: int = 0
+	## A UUID.
+	var player_id: String = ""
+
+	static func from_dict(d: Dictionary) -> ReportFiledData:
+		var m := ReportFiledData.new()
+		if d.has("report_id"):
+			m.report_id = int(d["report_id"])
+		if d.has("player_id"):
+			m.player_id = d["player_id"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		d["report_id"] = report_id
+		d["player_id"] = player_id
+		return d
+
+
+## MaintenanceData counts down to a scheduled restart, or tells players it was cancelled. Message is the notice to show.
+class MaintenanceData:
+	## Unix seconds. Omitted when unset.
+	var restart_at: int = 0
+	## Omitted when unset.
+	var seconds_left: int = 0
+	## Omitted when unset.
+	var cancelled: bool = false
+	var message: String = ""
+
+	static func from_dict(d: Dictionary) -> MaintenanceData:
+		var m := MaintenanceData.new()
+		if d.has("restart_at"):
+			m.restart_at = int(d["restart_at"])
+		if d.has("seconds_left"):
+			m.seconds_left = int(d["seconds_left"])
+		if d.has("cancelled"):
+			m.cancelled = d["cancelled"]
+		if d.has("message"):
+			m.message = d["message"]
+		return m
+
+	func to_dict() -> Dictionary:
+		var d := {}
+		if restart_at != 0:
+			d["restart_at"] = restart_at
+		if seconds_left != 0:
+			d["seconds_left"] = seconds_left
+		if cancelled != false:
+			d["cancelled"] = cancelled
+		d["message"] = message
+		return d
@@ -0,0 +1,2175 @@
+// Code generated by cmd/clientgen from messagecatalog.go. DO NOT EDIT.
+
+using System;
+using System.Collections.Generic;
+using System.IO;
+using System.IO.Compression;
+using Newtonsoft.Json;
+using Newtonsoft.Json.Linq;
+
+namespace GameServer.Messages
+{
+    /// <summary>The type of every message.</summary>
+    public static class MessageTypes
+    {
+        public const string Authenticate = "Authenticate";
+        public const string Heartbeat = "Heartbeat";
+        public const string Ack = "Ack";
+        public const string SessionSecret = "SessionSecret";
+        public const string ServerInfoRequest = "ServerInfoRequest";
+        public const string ServerInfo = "ServerInfo";
+        public const string AddressEchoRequest = "AddressEchoRequest";
+        public const string AddressEcho = "AddressEcho";
+        public const string PlayerJoin = "PlayerJoin";
+        public const string PlayerLeave = "PlayerLeave";
+        public const string PlayerIndex = "PlayerIndex";
+        public const string Redirect = "Redirect";
+        public const string UpgradeRequired = "UpgradeRequired";
+        public const string ServerFull = "ServerFull";
+        public const string Kicked = "Kicked";
+        public const string Disconnect = "Disconnect";
+        public const string Batch = "Batch";
+        public const string PlayerMove = "PlayerMove";
+        public const string PositionCorrection = "PositionCorrection";
+        public const string PlayerHidden = "PlayerHidden";
+        public const string ChangeZone = "ChangeZone";
+        public const string ZoneChanged = "ZoneChanged";
+        public const string PlayerAction = "PlayerAction";
+        public const string UseAbility = "UseAbility";
+        public const string AbilityUsed = "AbilityUsed";
+        public const string AbilityCooldowns = "AbilityCooldowns";
+        public const string PrivateState = "PrivateState";
+        public const string PlayerPushed = "PlayerPushed";
+        public const string PlayerDied = "PlayerDied";
+        public const string PlayerRespawn = "PlayerRespawn";
+        public const string AFKWarning = "AFKWarning";
+        public const string PlayerAFK = "PlayerAFK";
+        public const string GameState = "GameState";
+        public const string EntitySpawned = "EntitySpawned";
+        public const string EntityRemoved = "EntityRemoved";
+        public const string UseItem = "UseItem";
+        public const string DropItem = "DropItem";
+        public const string PlayerInventory = "PlayerInventory";
+        public const string LevelUp = "LevelUp";
+        public const string MatchEnded = "MatchEnded";
+        public const string Scoreboard = "Scoreboard";
+        public const string CreateRoom = "CreateRoom";
+        public const string RoomCreated = "RoomCreated";
+        public const string GameModeState = "GameModeState";
+        public const string GameModeWon = "GameModeWon";
+        public const string StartVote = "StartVote";
+        public const string CastVote = "CastVote";
+        public const string VoteUpdate = "VoteUpdate";
+        public const string VoteEnded = "VoteEnded";
+        public const string PauseRoom = "PauseRoom";
+        public const string ResumeRoom = "ResumeRoom";
+        public const string RoomPaused = "RoomPaused";
+        public const string RoomResumed = "RoomResumed";
+        public const string WorldEvent = "WorldEvent";
+        public const string RulesChanged = "RulesChanged";
+        public const string Chat = "Chat";
+        public const string Muted = "Muted";
+        public const string Whisper = "Whisper";
+        public const string FriendAdd = "FriendAdd";
+        public const string FriendAccept = "FriendAccept";
+        public const string FriendRemove = "FriendRemove";
+        public const string FriendListRequest = "FriendListRequest";
+        public const string FriendList = "FriendList";
+        public const string FriendRequest = "FriendRequest";
+        public const string FriendAccepted = "FriendAccepted";
+        public const string FriendOnline = "FriendOnline";
+        public const string FriendOffline = "FriendOffline";
+        public const string PartyInvite = "PartyInvite";
+        public const string PartyAccept = "PartyAccept";
+        public const string PartyLeave = "PartyLeave";
+        public const string PartyChat = "PartyChat";
+        public const string PartyInvited = "PartyInvited";
+        public const string PartyUpdate = "PartyUpdate";
+        public const string PartyLeft = "PartyLeft";
+        public const string GuildCreate = "GuildCreate";
+        public const string GuildJoin = "GuildJoin";
+        public const string GuildLeave = "GuildLeave";
+        public const string GuildPromote = "GuildPromote";
+        public const string GuildInfoRequest = "GuildInfoRequest";
+        public const string GuildChat = "GuildChat";
+        public const string GuildUpdate = "GuildUpdate";
+        public const string GuildLeft = "GuildLeft";
+        public const string MailSend = "MailSend";
+        public const string MailListRequest = "MailListRequest";
+        public const string MailRead = "MailRead";
+        public const string MailDelete = "MailDelete";
+        public const string MailSent = "MailSent";
+        public const string MailReceived = "MailReceived";
+        public const string MailList = "MailList";
+        public const string PlayerStatsRequest = "PlayerStatsRequest";
+        public const string PlayerStats = "PlayerStats";
+        public const string LeaderboardRequest = "LeaderboardRequest";
+        public const string LeaderboardResponse = "LeaderboardResponse";
+        public const string GuildLeaderboardRequest = "GuildLeaderboardRequest";
+        public const string GuildLeaderboard = "GuildLeaderboard";
+        public const string PrivacySettings = "PrivacySettings";
+        public const string GetPreferences = "GetPreferences";
+        public const string SetPreferences = "SetPreferences";
+        public const string Preferences = "Preferences";
+        public const string ConnectionQuality = "ConnectionQuality";
+        public const string Error = "Error";
+        public const string Announcement = "Announcement";
+        public const string Moderate = "Moderate";
+        public const string Moderated = "Moderated";
+        public const string ReportPlayer = "ReportPlayer";
+        public const string ReportFiled = "ReportFiled";
+        public const string Maintenance = "Maintenance";
+
+        /// <summary>The payload class of each message the server sends.</summary>
+        public static readonly Dictionary<string, Type> FromServer = new Dictionary<string, Type>
+        {
+            { Ack, typeof(AckData) },
+            { SessionSecret, typeof(SessionSecretData) },
+            { ServerInfo, typeof(ServerInfo) },
+            { AddressEcho, typeof(AddressEchoData) },
+            { PlayerJoin, typeof(PlayerJoinData) },
+            { PlayerLeave, typeof(PlayerLeaveData) },
+            { PlayerIndex, typeof(PlayerIndexData) },
+            { Redirect, typeof(RedirectData) },
+            { UpgradeRequired, typeof(UpgradeRequiredData) },
+            { ServerFull, typeof(ServerFullData) },
+            { Kicked, typeof(KickedData) },
+            { Disconnect, typeof(DisconnectData) },
+            { Batch, typeof(BatchData) },
+            { PlayerMove, typeof(PlayerMoveData) },
+            { PositionCorrection, typeof(PositionCorrectionData) },
+            { PlayerHidden, typeof(PlayerHiddenData) },
+            { ZoneChanged, typeof(ZoneChangedData) },
+            { AbilityUsed, typeof(AbilityUsedData) },
+            { AbilityCooldowns, typeof(AbilityCooldownsData) },
+            { PrivateState, typeof(PrivateStateData) },
+            { PlayerPushed, typeof(PlayerPushedData) },
+            { PlayerDied, typeof(PlayerDiedData) },
+            { PlayerRespawn, typeof(PlayerRespawnData) },
+            { AFKWarning, typeof(AFKWarningData) },
+            { PlayerAFK, typeof(PlayerAFKData) },
+            { GameState, typeof(GameStateData) },
+            { EntitySpawned, typeof(Entity) },
+            { EntityRemoved, typeof(EntityRemovedData) },
+            { PlayerInventory, typeof(PlayerInventoryData) },
+            { LevelUp, typeof(LevelUpData) },
+            { MatchEnded, typeof(MatchEndedData) },
+            { Scoreboard, typeof(ScoreboardData) },
+            { RoomCreated, typeof(RoomCreatedData) },
+            { GameModeState, typeof(GameModeStateData) },
+            { GameModeWon, typeof(GameModeWonData) },
+            { VoteUpdate, typeof(VoteStatusData) },
+            { VoteEnded, typeof(VoteEndedData) },
+            { RoomPaused, typeof(RoomPauseData) },
+            { RoomResumed, typeof(RoomPauseData) },
+            { WorldEvent, typeof(WorldEventData) },
+            { RulesChanged, typeof(GameRules) },
+            { Chat, typeof(ChatData) },
+            { Muted, typeof(MutedData) },
+            { Whisper, typeof(WhisperData) },
+            { FriendList, typeof(FriendListData) },
+            { FriendRequest, typeof(FriendPresenceData) },
+            { FriendAccepted, typeof(FriendPresenceData) },
+            { FriendOnline, typeof(FriendPresenceData) },
+            { FriendOffline, typeof(FriendPresenceData) },
+            { PartyChat, typeof(PartyChatData) },
+            { PartyInvited, typeof(PartyInvitedData) },
+            { PartyUpdate, typeof(PartyUpdateData) },
+            { PartyLeft, typeof(PartyLeftData) },
+            { GuildChat, typeof(GuildChatData) },
+            { GuildUpdate, typeof(GuildUpdateData) },
+            { GuildLeft, typeof(GuildLeftData) },
+            { MailSent, typeof(MailIDData) },
+            { MailReceived, typeof(MailData) },
+            { MailList, typeof(MailData) },
+            { PlayerStats, typeof(PlayerStatsData) },
+            { LeaderboardResponse, typeof(LeaderboardResponseData) },
+            { GuildLeaderboard, typeof(GuildLeaderboardData) },
+            { PrivacySettings, typeof(PrivacySettings) },
+            { Preferences, typeof(PreferencesData) },
+            { ConnectionQuality, typeof(ConnectionQualityData) },
+            { Error, typeof(ErrorData) },
+            { Announcement, typeof(AnnouncementData) },
+            { Moderated, typeof(ModeratedData) },
+            { ReportFiled, typeof(ReportFiledData) },
+            { Maintenance, typeof(MaintenanceData) },
+        };
+
+        /// <summary>The payload class of each message the client sends.</summary>
+        public static readonly Dictionary<string, Type> FromClient = new Dictionary<string, Type>
+        {
+            { Authenticate, typeof(AuthenticateData) },
+            { Heartbeat, typeof(HeartbeatData) },
+            { Ack, typeof(AckData) },
+            { PlayerMove, typeof(PlayerMoveData) },
+            { ChangeZone, typeof(ChangeZoneData) },
+            { PlayerAction, typeof(PlayerActionData) },
+            { UseAbility, typeof(UseAbilityData) },
+            { UseItem, typeof(UseItemData) },
+            { DropItem, typeof(DropItemData) },
+            { CreateRoom, typeof(CreateRoomData) },
+            { StartVote, typeof(StartVoteData) },
+            { CastVote, typeof(CastVoteData) },
+            { Chat, typeof(ChatData) },
+            { Whisper, typeof(WhisperRequestData) },
+            { FriendAdd, typeof(FriendData) },
+            { FriendAccept, typeof(FriendData) },
+            { FriendRemove, typeof(FriendData) },
+            { PartyInvite, typeof(PartyInviteData) },
+            { PartyAccept, typeof(PartyAcceptData) },
+            { PartyChat, typeof(PartyChatData) },
+            { GuildCreate, typeof(GuildCreateData) },
+            { GuildJoin, typeof(GuildJoinData) },
+            { GuildPromote, typeof(GuildPromoteData) },
+            { GuildChat, typeof(GuildChatData) },
+            { MailSend, typeof(MailSendData) },
+            { MailRead, typeof(MailIDData) },
+            { MailDelete, typeof(MailIDData) },
+            { PlayerStatsRequest, typeof(PlayerStatsRequestData) },
+            { LeaderboardRequest, typeof(LeaderboardRequestData) },
+            { GuildLeaderboardRequest, typeof(GuildLeaderboardRequestData) },
+            { PrivacySettings, typeof(PrivacySettingsData) },
+            { SetPreferences, typeof(SetPreferencesData) },
+            { Moderate, typeof(ModerateData) },
+            { ReportPlayer, typeof(ReportPlayerData) },
+        };
+    }
+
+    /// <summary>AuthenticateData is the first frame of a TCP client when AUTH_REQUIRED is set, carrying a token from /auth/login.</summary>
+    [Serializable]
+    public partial class AuthenticateData
+    {
+        [JsonProperty("token")]
+        public string Token;
+
+        /// <summary>1 if omitted, see protocol.go. Omitted when unset.</summary>
+        [JsonProperty("protocol_version", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public int ProtocolVersion;
+    }
+
+    [Serializable]
+    public partial class HeartbeatData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("sequence")]
+        public uint Sequence;
+
+        /// <summary>Optional features a UDP client supports. Omitted when unset.</summary>
+        [JsonProperty("capabilities", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public List<string> Capabilities;
+
+        /// <summary>Sender's clock in ms, echoed in the Ack. Omitted when unset.</summary>
+        [JsonProperty("sent_at", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long SentAt;
+
+        /// <summary>Account token, checked on a client's first Heartbeat. Omitted when unset.</summary>
+        [JsonProperty("token", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Token;
+
+        /// <summary>1 if omitted, see protocol.go. Omitted when unset.</summary>
+        [JsonProperty("protocol_version", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public int ProtocolVersion;
+    }
+
+    [Serializable]
+    public partial class AckData
+    {
+        [JsonProperty("sequence")]
+        public uint Sequence;
+
+        /// <summary>SentAt of the acknowledged Heartbeat. Omitted when unset.</summary>
+        [JsonProperty("sent_at", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long SentAt;
+    }
+
+    /// <summary>SessionSecretData gives a UDP client the secret it signs its packets with, see transport/udp/udpsign.go.</summary>
+    [Serializable]
+    public partial class SessionSecretData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Base64 in JSON, binary in MessagePack.</summary>
+        [JsonProperty("secret")]
+        public string Secret;
+    }
+
+    /// <summary>ServerInfo describes an instance to launchers and server browsers.</summary>
+    [Serializable]
+    public partial class ServerInfo
+    {
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("map")]
+        public string Map;
+
+        /// <summary>Websocket, tcp or udp.</summary>
+        [JsonProperty("protocol")]
+        public string Protocol;
+
+        [JsonProperty("players")]
+        public int Players;
+
+        /// <summary>0 for unlimited.</summary>
+        [JsonProperty("max_players")]
+        public int MaxPlayers;
+
+        /// <summary>GameState snapshots per second, 0 for none.</summary>
+        [JsonProperty("tick_rate")]
+        public int TickRate;
+
+        [JsonProperty("protocol_versions")]
+        public List<int> ProtocolVersions;
+
+        [JsonProperty("encodings")]
+        public List<string> Encodings;
+
+        /// <summary>How often UDP clients should send a Heartbeat. Omitted when unset.</summary>
+        [JsonProperty("keepalive_ms", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long KeepaliveMs;
+    }
+
+    /// <summary>AddressEchoData tells a UDP client the address and port its packets reach the server from, after any NAT, see transport/udp/keepalive.go.</summary>
+    [Serializable]
+    public partial class AddressEchoData
+    {
+        [JsonProperty("ip")]
+        public string Ip;
+
+        [JsonProperty("port")]
+        public int Port;
+
+        /// <summary>How often to send a Heartbeat to keep the mapping open.</summary>
+        [JsonProperty("keepalive_ms")]
+        public long KeepaliveMs;
+    }
+
+    [Serializable]
+    public partial class PlayerJoinData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+    }
+
+    [Serializable]
+    public partial class PlayerLeaveData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+    }
+
+    /// <summary>PlayerIndexData announces the indexes binary frames refer to players by.</summary>
+    [Serializable]
+    public partial class PlayerIndexData
+    {
+        [JsonProperty("players")]
+        public List<PlayerIndexEntry> Players;
+    }
+
+    [Serializable]
+    public partial class PlayerIndexEntry
+    {
+        [JsonProperty("index")]
+        public ushort Index;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+    }
+
+    /// <summary>RedirectData tells a client which backend instance to reconnect to, carrying the player ID it should reconnect with.</summary>
+    [Serializable]
+    public partial class RedirectData
+    {
+        [JsonProperty("backend")]
+        public string Backend;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("room", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Room;
+    }
+
+    /// <summary>UpgradeRequiredData refuses a client whose protocol version the server does not speak.</summary>
+    [Serializable]
+    public partial class UpgradeRequiredData
+    {
+        [JsonProperty("version")]
+        public int Version;
+
+        [JsonProperty("supported_versions")]
+        public List<int> SupportedVersions;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>ServerFullData tells a client the server has MaxPlayers playing. Position is its place in the queue, from 1, or 0 if it was refused.</summary>
+    [Serializable]
+    public partial class ServerFullData
+    {
+        [JsonProperty("position")]
+        public int Position;
+
+        [JsonProperty("max_players")]
+        public int MaxPlayers;
+    }
+
+    /// <summary>KickedData tells a player why the server is disconnecting them.</summary>
+    [Serializable]
+    public partial class KickedData
+    {
+        [JsonProperty("reason")]
+        public string Reason;
+    }
+
+    /// <summary>DisconnectData is sent before the server closes a connection. Clients branch on Reason, one of the disconnect reasons in disconnect.go, and show Message.</summary>
+    [Serializable]
+    public partial class DisconnectData
+    {
+        [JsonProperty("reason")]
+        public string Reason;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>BatchData carries the messages queued for a client during one tick, in order.</summary>
+    [Serializable]
+    public partial class BatchData
+    {
+        [JsonProperty("messages")]
+        public List<GameMessage> Messages;
+    }
+
+    [Serializable]
+    public partial class GameMessage
+    {
+        [JsonProperty("type")]
+        public string Type;
+
+        [JsonProperty("data")]
+        public JToken Data;
+
+        /// <summary>Optional envelope fields for request/response correlation, see rpc.go. Omitted when unset.</summary>
+        [JsonProperty("id", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Id;
+
+        /// <summary>Ms since the epoch. Omitted when unset.</summary>
+        [JsonProperty("timestamp", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long Timestamp;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("correlation_id", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string CorrelationId;
+
+        /// <summary>Decodes the payload as T.</summary>
+        public T DataAs<T>()
+        {
+            return Data == null ? default : Data.ToObject<T>();
+        }
+
+        /// <summary>Decodes the payload of a message from the server as its payload class, or returns null for messages without one.</summary>
+        public object DecodeData()
+        {
+            return Data != null && MessageTypes.FromServer.TryGetValue(Type, out var type) ? Data.ToObject(type) : null;
+        }
+
+        /// <summary>Returns a message of a type with a payload.</summary>
+        public static GameMessage Create(string type, object data = null)
+        {
+            return new GameMessage { Type = type, Data = data == null ? null : JToken.FromObject(data) };
+        }
+    }
+
+    /// <summary>PlayerMoveData is a position update. A steering player also sends its velocity in units per second, which the server then moves it at.</summary>
+    [Serializable]
+    public partial class PlayerMoveData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("vx", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public float Vx;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("vy", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public float Vy;
+    }
+
+    /// <summary>PositionCorrectionData tells a client where the server placed it after it reported a position outside the world bounds.</summary>
+    [Serializable]
+    public partial class PositionCorrectionData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+    }
+
+    /// <summary>PlayerHiddenData tells a player that another player went out of sight, see Visibility. Its position is not sent again until it is back in sight.</summary>
+    [Serializable]
+    public partial class PlayerHiddenData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+    }
+
+    /// <summary>ChangeZoneData asks to move the sender to another zone.</summary>
+    [Serializable]
+    public partial class ChangeZoneData
+    {
+        [JsonProperty("zone")]
+        public string Zone;
+    }
+
+    /// <summary>ZoneChangedData tells a player the zone they are in and where they arrived, on joining and after each ChangeZone.</summary>
+    [Serializable]
+    public partial class ZoneChangedData
+    {
+        [JsonProperty("zone")]
+        public string Zone;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+    }
+
+    [Serializable]
+    public partial class PlayerActionData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("action")]
+        public string Action;
+
+        [JsonProperty("data")]
+        public JToken Data;
+    }
+
+    /// <summary>UseAbilityData uses an ability, aimed at TargetID for damage and at X, Y for a blast, which defaults to the user's position.</summary>
+    [Serializable]
+    public partial class UseAbilityData
+    {
+        [JsonProperty("ability")]
+        public string Ability;
+
+        /// <summary>A UUID. Omitted when unset.</summary>
+        [JsonProperty("target_id", NullValueHandling = NullValueHandling.Ignore)]
+        public string? TargetId;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("x", NullValueHandling = NullValueHandling.Ignore)]
+        public float? X;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("y", NullValueHandling = NullValueHandling.Ignore)]
+        public float? Y;
+    }
+
+    /// <summary>AbilityUsedData tells the players in a zone that one of them used an ability at X, Y, its target's position for damage.</summary>
+    [Serializable]
+    public partial class AbilityUsedData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("ability")]
+        public string Ability;
+
+        [JsonProperty("effect")]
+        public string Effect;
+
+        /// <summary>A UUID. Omitted when unset.</summary>
+        [JsonProperty("target_id", NullValueHandling = NullValueHandling.Ignore)]
+        public string? TargetId;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+    }
+
+    /// <summary>AbilityCooldownsData tells a player the abilities of the server and when each is ready for them again. It is sent when they join and after every ability they use.</summary>
+    [Serializable]
+    public partial class AbilityCooldownsData
+    {
+        [JsonProperty("cooldowns")]
+        public List<AbilityCooldown> Cooldowns;
+    }
+
+    [Serializable]
+    public partial class AbilityCooldown
+    {
+        [JsonProperty("ability")]
+        public string Ability;
+
+        [JsonProperty("cooldown_seconds")]
+        public double CooldownSeconds;
+
+        /// <summary>Unix milliseconds, omitted if never used. Omitted when unset.</summary>
+        [JsonProperty("ready_at", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long ReadyAt;
+    }
+
+    /// <summary>PrivateStateData is state only the player it belongs to is sent, see PrivateChannel. Sections left out have not changed since the previous PrivateState; the first after joining has every section.</summary>
+    [Serializable]
+    public partial class PrivateStateData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Counts up from 1, so that a stale update can be told apart.</summary>
+        [JsonProperty("sequence")]
+        public ulong Sequence;
+
+        /// <summary>Unix milliseconds.</summary>
+        [JsonProperty("sent_at")]
+        public long SentAt;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("health", NullValueHandling = NullValueHandling.Ignore)]
+        public HealthDetails Health;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("cooldowns", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public List<AbilityCooldown> Cooldowns;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("inventory", NullValueHandling = NullValueHandling.Ignore)]
+        public InventoryDetails Inventory;
+    }
+
+    /// <summary>HealthDetails is a player's health with what only they need to know, such as when they come back after dying.</summary>
+    [Serializable]
+    public partial class HealthDetails
+    {
+        [JsonProperty("current")]
+        public float Current;
+
+        [JsonProperty("max")]
+        public float Max;
+
+        /// <summary>Unix milliseconds, while dead and respawning. Omitted when unset.</summary>
+        [JsonProperty("respawn_at", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long RespawnAt;
+    }
+
+    [Serializable]
+    public partial class InventoryDetails
+    {
+        [JsonProperty("items")]
+        public List<InventoryItem> Items;
+    }
+
+    [Serializable]
+    public partial class InventoryItem
+    {
+        [JsonProperty("item_type")]
+        public string ItemType;
+
+        [JsonProperty("quantity")]
+        public long Quantity;
+    }
+
+    /// <summary>PlayerPushedData tells the players in a zone that the server knocked a player back. The player moves at VX, VY, slowing to a stop, and its moves are corrected until it does.</summary>
+    [Serializable]
+    public partial class PlayerPushedData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        [JsonProperty("vx")]
+        public float Vx;
+
+        [JsonProperty("vy")]
+        public float Vy;
+    }
+
+    /// <summary>PlayerDiedData tells the players in a zone that one of them was killed.</summary>
+    [Serializable]
+    public partial class PlayerDiedData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("killer_id")]
+        public string KillerId;
+
+        /// <summary>Unix milliseconds, omitted if killed players stay dead. Omitted when unset.</summary>
+        [JsonProperty("respawn_at", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long RespawnAt;
+    }
+
+    [Serializable]
+    public partial class PlayerRespawnData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        [JsonProperty("health")]
+        public float Health;
+    }
+
+    /// <summary>AFKWarningData warns an idle player that they will be removed at RemovalAt unless they act: from the server if Kick is set, otherwise from the match.</summary>
+    [Serializable]
+    public partial class AFKWarningData
+    {
+        /// <summary>Unix milliseconds.</summary>
+        [JsonProperty("removal_at")]
+        public long RemovalAt;
+
+        [JsonProperty("kick")]
+        public bool Kick;
+    }
+
+    /// <summary>PlayerAFKData tells the players in a zone that one of them went idle or came back. Removed is set once an idle player is out of the match.</summary>
+    [Serializable]
+    public partial class PlayerAFKData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("afk")]
+        public bool Afk;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("removed", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public bool Removed;
+    }
+
+    /// <summary>GameStateData is a snapshot of every player. Tick is the simulation tick it was taken at, omitted by the UDP server which has no simulation loop, and ServerTime the authoritative time in milliseconds; clients interpolate between snapshots by either.</summary>
+    [Serializable]
+    public partial class GameStateData
+    {
+        [JsonProperty("players")]
+        public List<Player> Players;
+
+        /// <summary>The NPCs, items and projectiles of the zone. Omitted when unset.</summary>
+        [JsonProperty("entities", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public List<Entity> Entities;
+
+        [JsonProperty("timestamp")]
+        public long Timestamp;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("tick", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public ulong Tick;
+
+        [JsonProperty("server_time")]
+        public long ServerTime;
+    }
+
+    [Serializable]
+    public partial class Player
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("id")]
+        public string Id;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        [JsonProperty("health")]
+        public float Health;
+
+        [JsonProperty("score")]
+        public uint Score;
+
+        [JsonProperty("xp")]
+        public long Xp;
+
+        [JsonProperty("level")]
+        public int Level;
+
+        /// <summary>Units per second, see MoveTo.</summary>
+        [JsonProperty("vx")]
+        public float Vx;
+
+        [JsonProperty("vy")]
+        public float Vy;
+
+        /// <summary>See GuildManager. Omitted when unset.</summary>
+        [JsonProperty("guild_tag", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string GuildTag;
+
+        /// <summary>Set by the game mode of the player's room. Omitted when unset.</summary>
+        [JsonProperty("team", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Team;
+
+        /// <summary>See Zones. Omitted when unset.</summary>
+        [JsonProperty("zone", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Zone;
+
+        /// <summary>Idle, see AFKPolicy. Omitted when unset.</summary>
+        [JsonProperty("afk", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public bool Afk;
+    }
+
+    /// <summary>Entity is an object of the world made of optional components, so that snapshots, persistence and zone visibility treat NPCs, items and projectiles alike whatever they are made of.</summary>
+    [Serializable]
+    public partial class Entity
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("id")]
+        public string Id;
+
+        [JsonProperty("kind")]
+        public string Kind;
+
+        [JsonProperty("zone")]
+        public string Zone;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("position", NullValueHandling = NullValueHandling.Ignore)]
+        public Position Position;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("health", NullValueHandling = NullValueHandling.Ignore)]
+        public Health Health;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("team", NullValueHandling = NullValueHandling.Ignore)]
+        public Team Team;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("ownership", NullValueHandling = NullValueHandling.Ignore)]
+        public Ownership Ownership;
+
+        /// <summary>An RFC 3339 time. Removed by then, like a spent projectile. Omitted when unset.</summary>
+        [JsonProperty("expires_at", NullValueHandling = NullValueHandling.Ignore)]
+        public string? ExpiresAt;
+    }
+
+    /// <summary>Position places an entity, which moves at VX, VY units per second.</summary>
+    [Serializable]
+    public partial class Position
+    {
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("vx", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public float Vx;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("vy", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public float Vy;
+    }
+
+    [Serializable]
+    public partial class Health
+    {
+        [JsonProperty("current")]
+        public float Current;
+
+        [JsonProperty("max")]
+        public float Max;
+    }
+
+    [Serializable]
+    public partial class Team
+    {
+        [JsonProperty("name")]
+        public string Name;
+    }
+
+    /// <summary>Ownership ties an entity to the player it belongs to, such as the shooter of a projectile.</summary>
+    [Serializable]
+    public partial class Ownership
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("owner_id")]
+        public string OwnerId;
+    }
+
+    /// <summary>EntityRemovedData tells the players in a zone that an entity is gone.</summary>
+    [Serializable]
+    public partial class EntityRemovedData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("entity_id")]
+        public string EntityId;
+    }
+
+    [Serializable]
+    public partial class UseItemData
+    {
+        [JsonProperty("item")]
+        public string Item;
+    }
+
+    [Serializable]
+    public partial class DropItemData
+    {
+        [JsonProperty("item")]
+        public string Item;
+
+        [JsonProperty("quantity")]
+        public long Quantity;
+    }
+
+    [Serializable]
+    public partial class PlayerInventoryData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("items")]
+        public List<InventoryItem> Items;
+    }
+
+    [Serializable]
+    public partial class LevelUpData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("level")]
+        public int Level;
+
+        [JsonProperty("xp")]
+        public long Xp;
+    }
+
+    [Serializable]
+    public partial class MatchEndedData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("match_id", NullValueHandling = NullValueHandling.Ignore)]
+        public long? MatchId;
+
+        [JsonProperty("results")]
+        public List<RatingChange> Results;
+    }
+
+    [Serializable]
+    public partial class RatingChange
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("old_rating")]
+        public long OldRating;
+
+        [JsonProperty("new_rating")]
+        public long NewRating;
+
+        [JsonProperty("placement")]
+        public int Placement;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("room", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Room;
+    }
+
+    /// <summary>ScoreboardData ranks the players of a match. Final is set on the one sent when the match ends.</summary>
+    [Serializable]
+    public partial class ScoreboardData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("match_id", NullValueHandling = NullValueHandling.Ignore)]
+        public long? MatchId;
+
+        [JsonProperty("final")]
+        public bool Final;
+
+        [JsonProperty("results")]
+        public List<MatchResult> Results;
+    }
+
+    /// <summary>MatchResult is a player's line of a scoreboard, and of the final summary saved when a match ends.</summary>
+    [Serializable]
+    public partial class MatchResult
+    {
+        [JsonProperty("rank")]
+        public int Rank;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("score")]
+        public long Score;
+
+        [JsonProperty("kills")]
+        public long Kills;
+
+        [JsonProperty("deaths")]
+        public long Deaths;
+
+        /// <summary>Measured for UDP clients only. Omitted when unset.</summary>
+        [JsonProperty("ping_ms", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long PingMs;
+    }
+
+    /// <summary>CreateRoomData asks to open a new match room playing Mode, or GAME_MODE if empty, and move the sender into it. The sender owns the room and may send PauseRoom and ResumeRoom. TickDivisor slows the room down to run on every TickDivisor-th tick of the game loop, up to 10; 0 runs it every tick.</summary>
+    [Serializable]
+    public partial class CreateRoomData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("mode", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Mode;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("tick_divisor", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public int TickDivisor;
+    }
+
+    [Serializable]
+    public partial class RoomCreatedData
+    {
+        [JsonProperty("room")]
+        public string Room;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("mode", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Mode;
+    }
+
+    /// <summary>GameModeStateData tells the members of a room how its round stands. It is sent when a round starts and whenever a score, flag or hill changes.</summary>
+    [Serializable]
+    public partial class GameModeStateData
+    {
+        [JsonProperty("room")]
+        public string Room;
+
+        [JsonProperty("mode")]
+        public string Mode;
+
+        /// <summary>Captures by team in ctf, seconds held by player ID in koth.</summary>
+        [JsonProperty("scores")]
+        public Dictionary<string, long> Scores;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("flags", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public List<FlagState> Flags;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("hill", NullValueHandling = NullValueHandling.Ignore)]
+        public HillState Hill;
+    }
+
+    /// <summary>FlagState is a flag of capture the flag, standing at its team's base unless a player carries it.</summary>
+    [Serializable]
+    public partial class FlagState
+    {
+        [JsonProperty("team")]
+        public string Team;
+
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        /// <summary>A UUID. Omitted when unset.</summary>
+        [JsonProperty("carrier_id", NullValueHandling = NullValueHandling.Ignore)]
+        public string? CarrierId;
+    }
+
+    /// <summary>HillState is the hill of king of the hill, with the player holding it.</summary>
+    [Serializable]
+    public partial class HillState
+    {
+        [JsonProperty("x")]
+        public float X;
+
+        [JsonProperty("y")]
+        public float Y;
+
+        [JsonProperty("radius")]
+        public float Radius;
+
+        /// <summary>A UUID. Omitted when unset.</summary>
+        [JsonProperty("holder_id", NullValueHandling = NullValueHandling.Ignore)]
+        public string? HolderId;
+    }
+
+    /// <summary>GameModeWonData tells the members of a room who won the round, a team in ctf or a player ID in koth. The next round starts at once.</summary>
+    [Serializable]
+    public partial class GameModeWonData
+    {
+        [JsonProperty("room")]
+        public string Room;
+
+        [JsonProperty("mode")]
+        public string Mode;
+
+        [JsonProperty("winner")]
+        public string Winner;
+    }
+
+    /// <summary>StartVoteData asks to start a vote of Type, one of next_map (Target is a zone), kick_player (Target is a player ID) or restart_match (no Target).</summary>
+    [Serializable]
+    public partial class StartVoteData
+    {
+        [JsonProperty("type")]
+        public string Type;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("target", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Target;
+    }
+
+    /// <summary>CastVoteData votes in the running vote. Votes may be changed until it ends.</summary>
+    [Serializable]
+    public partial class CastVoteData
+    {
+        [JsonProperty("yes")]
+        public bool Yes;
+    }
+
+    /// <summary>VoteStatusData tells everyone how the running vote stands. It is sent when the vote starts, on every ballot and every few seconds as a countdown.</summary>
+    [Serializable]
+    public partial class VoteStatusData
+    {
+        [JsonProperty("type")]
+        public string Type;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("target", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Target;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("started_by")]
+        public string StartedBy;
+
+        [JsonProperty("yes")]
+        public int Yes;
+
+        [JsonProperty("no")]
+        public int No;
+
+        /// <summary>Players online, who may all vote.</summary>
+        [JsonProperty("eligible")]
+        public int Eligible;
+
+        /// <summary>Unix milliseconds.</summary>
+        [JsonProperty("ends_at")]
+        public long EndsAt;
+
+        [JsonProperty("seconds_left")]
+        public int SecondsLeft;
+    }
+
+    /// <summary>VoteEndedData tells everyone the outcome of a vote, which is enforced at once if it passed.</summary>
+    [Serializable]
+    public partial class VoteEndedData
+    {
+        [JsonProperty("type")]
+        public string Type;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("target", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Target;
+
+        [JsonProperty("yes")]
+        public int Yes;
+
+        [JsonProperty("no")]
+        public int No;
+
+        [JsonProperty("eligible")]
+        public int Eligible;
+
+        [JsonProperty("passed")]
+        public bool Passed;
+    }
+
+    /// <summary>RoomPauseData names the room a RoomPaused or RoomResumed message is about.</summary>
+    [Serializable]
+    public partial class RoomPauseData
+    {
+        [JsonProperty("room")]
+        public string Room;
+    }
+
+    /// <summary>WorldEventData announces a world event; State is "started" or "ended".</summary>
+    [Serializable]
+    public partial class WorldEventData
+    {
+        [JsonProperty("state")]
+        public string State;
+
+        [JsonProperty("type")]
+        public string Type;
+
+        [JsonProperty("description")]
+        public string Description;
+
+        [JsonProperty("points_multiplier")]
+        public long PointsMultiplier;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("bonus_item", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string BonusItem;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("started_at")]
+        public string StartedAt;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("ends_at")]
+        public string EndsAt;
+    }
+
+    /// <summary>WorldEvent is a running world event.</summary>
+    [Serializable]
+    public partial class WorldEvent
+    {
+        [JsonProperty("type")]
+        public string Type;
+
+        [JsonProperty("description")]
+        public string Description;
+
+        [JsonProperty("points_multiplier")]
+        public long PointsMultiplier;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("bonus_item", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string BonusItem;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("started_at")]
+        public string StartedAt;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("ends_at")]
+        public string EndsAt;
+    }
+
+    /// <summary>GameRules are the tunable numbers of the game. Fields missing from the rules file keep their defaults.</summary>
+    [Serializable]
+    public partial class GameRules
+    {
+        [JsonProperty("pickup_score")]
+        public long PickupScore;
+
+        [JsonProperty("attack_damage")]
+        public float AttackDamage;
+
+        /// <summary>0 leaves killed players dead.</summary>
+        [JsonProperty("respawn_seconds")]
+        public double RespawnSeconds;
+
+        /// <summary>Health players come back with.</summary>
+        [JsonProperty("respawn_health")]
+        public float RespawnHealth;
+
+        /// <summary>Awarded to the killer.</summary>
+        [JsonProperty("kill_score")]
+        public long KillScore;
+
+        /// <summary>Taken from the killed player, down to zero.</summary>
+        [JsonProperty("death_score_penalty")]
+        public long DeathScorePenalty;
+
+        /// <summary>Cap on steered velocities, in units per second.</summary>
+        [JsonProperty("max_move_speed")]
+        public float MaxMoveSpeed;
+
+        /// <summary>Impulse an attack gives its target, 0 for none.</summary>
+        [JsonProperty("knockback_speed")]
+        public float KnockbackSpeed;
+
+        /// <summary>Votes configures each type of vote players may start. A type given in the rules file replaces its default rule as a whole.</summary>
+        [JsonProperty("votes")]
+        public Dictionary<string, VoteRule> Votes;
+    }
+
+    /// <summary>VoteRule configures a type of vote. A vote passes if at least Quorum of the players online voted and at least Threshold of those votes are yes.</summary>
+    [Serializable]
+    public partial class VoteRule
+    {
+        /// <summary>0 disables the type.</summary>
+        [JsonProperty("duration_seconds")]
+        public double DurationSeconds;
+
+        /// <summary>Share of online players who must vote.</summary>
+        [JsonProperty("quorum")]
+        public double Quorum;
+
+        /// <summary>Share of votes cast that must be yes.</summary>
+        [JsonProperty("threshold")]
+        public double Threshold;
+    }
+
+    [Serializable]
+    public partial class ChatData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>MutedData tells a player they may not chat until Until, or that their mute was lifted when Until is 0.</summary>
+    [Serializable]
+    public partial class MutedData
+    {
+        /// <summary>Unix milliseconds. Omitted when unset.</summary>
+        [JsonProperty("until", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long Until;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("reason", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Reason;
+    }
+
+    /// <summary>WhisperRequestData is sent by a client to whisper to another player.</summary>
+    [Serializable]
+    public partial class WhisperRequestData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("target_id")]
+        public string TargetId;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>WhisperData is delivered to the whisper's recipient.</summary>
+    [Serializable]
+    public partial class WhisperData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>FriendData is the payload of FriendAdd, FriendAccept and FriendRemove.</summary>
+    [Serializable]
+    public partial class FriendData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("friend_id")]
+        public string FriendId;
+    }
+
+    [Serializable]
+    public partial class FriendListData
+    {
+        [JsonProperty("friends")]
+        public List<FriendEntry> Friends;
+    }
+
+    [Serializable]
+    public partial class FriendEntry
+    {
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        /// <summary>"pending" or "accepted".</summary>
+        [JsonProperty("status")]
+        public string Status;
+
+        /// <summary>True if the other player sent the request.</summary>
+        [JsonProperty("incoming")]
+        public bool Incoming;
+
+        [JsonProperty("online")]
+        public bool Online;
+    }
+
+    /// <summary>Friendship is a friend relation as seen from one player.</summary>
+    [Serializable]
+    public partial class Friendship
+    {
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        /// <summary>"pending" or "accepted".</summary>
+        [JsonProperty("status")]
+        public string Status;
+
+        /// <summary>True if the other player sent the request.</summary>
+        [JsonProperty("incoming")]
+        public bool Incoming;
+    }
+
+    /// <summary>FriendPresenceData is sent with FriendOnline, FriendOffline, FriendRequest and FriendAccepted notifications.</summary>
+    [Serializable]
+    public partial class FriendPresenceData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("name", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Name;
+    }
+
+    [Serializable]
+    public partial class PartyInviteData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("target_id")]
+        public string TargetId;
+    }
+
+    [Serializable]
+    public partial class PartyAcceptData
+    {
+        [JsonProperty("party_id")]
+        public string PartyId;
+    }
+
+    [Serializable]
+    public partial class PartyChatData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("party_id", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string PartyId;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>PartyInvitedData is sent to a player invited to a party.</summary>
+    [Serializable]
+    public partial class PartyInvitedData
+    {
+        [JsonProperty("party_id")]
+        public string PartyId;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+    }
+
+    [Serializable]
+    public partial class PartyUpdateData
+    {
+        [JsonProperty("party_id")]
+        public string PartyId;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("leader_id")]
+        public string LeaderId;
+
+        [JsonProperty("members")]
+        public List<string> Members;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("room", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Room;
+    }
+
+    [Serializable]
+    public partial class PartyLeftData
+    {
+        [JsonProperty("party_id")]
+        public string PartyId;
+    }
+
+    /// <summary>GuildCreateData creates a guild led by the sender.</summary>
+    [Serializable]
+    public partial class GuildCreateData
+    {
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("tag")]
+        public string Tag;
+    }
+
+    [Serializable]
+    public partial class GuildJoinData
+    {
+        [JsonProperty("tag")]
+        public string Tag;
+    }
+
+    /// <summary>GuildPromoteData makes a member an officer, or an officer the leader.</summary>
+    [Serializable]
+    public partial class GuildPromoteData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+    }
+
+    [Serializable]
+    public partial class GuildChatData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("guild_id", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string GuildId;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("name", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Name;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>GuildUpdateData is the player's guild and its members, sent when either changes and in reply to GuildInfoRequest.</summary>
+    [Serializable]
+    public partial class GuildUpdateData
+    {
+        [JsonProperty("guild_id")]
+        public string GuildId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("tag")]
+        public string Tag;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("created_at")]
+        public string CreatedAt;
+
+        [JsonProperty("members")]
+        public List<GuildMemberEntry> Members;
+    }
+
+    /// <summary>Guild is a persistent group of players. Its tag is shown next to its members' names.</summary>
+    [Serializable]
+    public partial class Guild
+    {
+        [JsonProperty("guild_id")]
+        public string GuildId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("tag")]
+        public string Tag;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("created_at")]
+        public string CreatedAt;
+    }
+
+    [Serializable]
+    public partial class GuildMemberEntry
+    {
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("rank")]
+        public string Rank;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("joined_at")]
+        public string JoinedAt;
+
+        [JsonProperty("online")]
+        public bool Online;
+    }
+
+    [Serializable]
+    public partial class GuildMember
+    {
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("rank")]
+        public string Rank;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("joined_at")]
+        public string JoinedAt;
+    }
+
+    /// <summary>GuildLeftData tells a player they are no longer in a guild. It is also the reply to GuildInfoRequest from a player in none, with no guild ID.</summary>
+    [Serializable]
+    public partial class GuildLeftData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("guild_id", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string GuildId;
+    }
+
+    [Serializable]
+    public partial class MailSendData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("recipient_id")]
+        public string RecipientId;
+
+        [JsonProperty("subject")]
+        public string Subject;
+
+        [JsonProperty("body")]
+        public string Body;
+    }
+
+    /// <summary>MailIDData names one mail in MailRead and MailDelete requests, and the mail a MailSent confirms.</summary>
+    [Serializable]
+    public partial class MailIDData
+    {
+        [JsonProperty("mail_id")]
+        public long MailId;
+    }
+
+    /// <summary>MailData is sent with MailReceived, the mail that arrived while the player was away or just now, and MailList, the whole mailbox.</summary>
+    [Serializable]
+    public partial class MailData
+    {
+        [JsonProperty("mail")]
+        public List<Mail> Mail;
+    }
+
+    /// <summary>Mail is a message a player sent another, who need not be online.</summary>
+    [Serializable]
+    public partial class Mail
+    {
+        [JsonProperty("mail_id")]
+        public long MailId;
+
+        [JsonProperty("sender_id")]
+        public string SenderId;
+
+        [JsonProperty("sender_name")]
+        public string SenderName;
+
+        [JsonProperty("recipient_id")]
+        public string RecipientId;
+
+        [JsonProperty("subject")]
+        public string Subject;
+
+        [JsonProperty("body")]
+        public string Body;
+
+        /// <summary>An RFC 3339 time.</summary>
+        [JsonProperty("sent_at")]
+        public string SentAt;
+
+        /// <summary>An RFC 3339 time. Omitted when unset.</summary>
+        [JsonProperty("read_at", NullValueHandling = NullValueHandling.Ignore)]
+        public string? ReadAt;
+    }
+
+    [Serializable]
+    public partial class PlayerStatsRequestData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+    }
+
+    [Serializable]
+    public partial class PlayerStatsData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("kills")]
+        public long Kills;
+
+        [JsonProperty("deaths")]
+        public long Deaths;
+
+        [JsonProperty("assists")]
+        public long Assists;
+
+        [JsonProperty("items_collected")]
+        public long ItemsCollected;
+
+        [JsonProperty("playtime_seconds")]
+        public long PlaytimeSeconds;
+    }
+
+    [Serializable]
+    public partial class LeaderboardRequestData
+    {
+        /// <summary>Daily, weekly, monthly or all.</summary>
+        [JsonProperty("window")]
+        public string Window;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("limit", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public int Limit;
+    }
+
+    [Serializable]
+    public partial class LeaderboardResponseData
+    {
+        [JsonProperty("window")]
+        public string Window;
+
+        [JsonProperty("period_start")]
+        public string PeriodStart;
+
+        [JsonProperty("entries")]
+        public List<WindowedLeaderboardEntry> Entries;
+    }
+
+    [Serializable]
+    public partial class WindowedLeaderboardEntry
+    {
+        [JsonProperty("rank")]
+        public int Rank;
+
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("score")]
+        public long Score;
+    }
+
+    [Serializable]
+    public partial class WindowedScore
+    {
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("score")]
+        public long Score;
+    }
+
+    [Serializable]
+    public partial class GuildLeaderboardRequestData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("limit", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public int Limit;
+    }
+
+    [Serializable]
+    public partial class GuildLeaderboardData
+    {
+        [JsonProperty("entries")]
+        public List<GuildLeaderboardEntry> Entries;
+    }
+
+    [Serializable]
+    public partial class GuildLeaderboardEntry
+    {
+        [JsonProperty("rank")]
+        public int Rank;
+
+        [JsonProperty("guild_id")]
+        public string GuildId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("tag")]
+        public string Tag;
+
+        [JsonProperty("members")]
+        public int Members;
+
+        [JsonProperty("score")]
+        public long Score;
+    }
+
+    /// <summary>GuildScore is a guild's standing on the guild leaderboard, the sum of its members' scores.</summary>
+    [Serializable]
+    public partial class GuildScore
+    {
+        [JsonProperty("guild_id")]
+        public string GuildId;
+
+        [JsonProperty("name")]
+        public string Name;
+
+        [JsonProperty("tag")]
+        public string Tag;
+
+        [JsonProperty("members")]
+        public int Members;
+
+        [JsonProperty("score")]
+        public long Score;
+    }
+
+    /// <summary>PrivacySettingsData is the payload of a PrivacySettings request. Only the flags that are set are changed; an empty request reads the settings.</summary>
+    [Serializable]
+    public partial class PrivacySettingsData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("no_chat_log", NullValueHandling = NullValueHandling.Ignore)]
+        public bool? NoChatLog;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("no_ip_storage", NullValueHandling = NullValueHandling.Ignore)]
+        public bool? NoIpStorage;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("anonymize_events", NullValueHandling = NullValueHandling.Ignore)]
+        public bool? AnonymizeEvents;
+    }
+
+    /// <summary>PrivacySettings are a player's choices about the data stored about them. Database methods enforce them when writing, so every caller honours them.</summary>
+    [Serializable]
+    public partial class PrivacySettings
+    {
+        [JsonProperty("no_chat_log")]
+        public bool NoChatLog;
+
+        [JsonProperty("no_ip_storage")]
+        public bool NoIpStorage;
+
+        [JsonProperty("anonymize_events")]
+        public bool AnonymizeEvents;
+    }
+
+    /// <summary>SetPreferencesData sets and removes preferences by name, leaving the player's other preferences as they are.</summary>
+    [Serializable]
+    public partial class SetPreferencesData
+    {
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("preferences", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public Dictionary<string, string> Preferences;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("remove", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public List<string> Remove;
+    }
+
+    /// <summary>PreferencesData is every preference the player saved, by name.</summary>
+    [Serializable]
+    public partial class PreferencesData
+    {
+        [JsonProperty("preferences")]
+        public Dictionary<string, string> Preferences;
+    }
+
+    /// <summary>ConnectionQualityData reports a UDP client's link over the last interval. PacketLoss covers packets from the client, RetransmitRate packets to it.</summary>
+    [Serializable]
+    public partial class ConnectionQualityData
+    {
+        [JsonProperty("ping_ms")]
+        public double PingMs;
+
+        [JsonProperty("jitter_ms")]
+        public double JitterMs;
+
+        [JsonProperty("packet_loss")]
+        public double PacketLoss;
+
+        [JsonProperty("retransmit_rate")]
+        public double RetransmitRate;
+
+        [JsonProperty("degraded")]
+        public bool Degraded;
+    }
+
+    [Serializable]
+    public partial class ErrorData
+    {
+        /// <summary>Set for rejected input, see InputError. Omitted when unset.</summary>
+        [JsonProperty("code", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Code;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>AnnouncementData is an operator message shown to every player.</summary>
+    [Serializable]
+    public partial class AnnouncementData
+    {
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>ModerateData is a staff command against another player: mute them from chat for DurationSeconds (10 minutes if 0), unmute them or kick them. The sender's account role must allow it.</summary>
+    [Serializable]
+    public partial class ModerateData
+    {
+        /// <summary>Mute, unmute or kick.</summary>
+        [JsonProperty("action")]
+        public string Action;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("duration_seconds", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public int DurationSeconds;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("reason", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string Reason;
+    }
+
+    /// <summary>ModeratedData confirms a Moderate command.</summary>
+    [Serializable]
+    public partial class ModeratedData
+    {
+        [JsonProperty("action")]
+        public string Action;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        /// <summary>Unix milliseconds, end of a mute. Omitted when unset.</summary>
+        [JsonProperty("until", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long Until;
+    }
+
+    /// <summary>ReportPlayerData reports another player to staff, with an optional excerpt of the chat that prompted it.</summary>
+    [Serializable]
+    public partial class ReportPlayerData
+    {
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+
+        [JsonProperty("reason")]
+        public string Reason;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("chat_excerpt", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public string ChatExcerpt;
+    }
+
+    /// <summary>ReportFiledData confirms a ReportPlayer message.</summary>
+    [Serializable]
+    public partial class ReportFiledData
+    {
+        [JsonProperty("report_id")]
+        public long ReportId;
+
+        /// <summary>A UUID.</summary>
+        [JsonProperty("player_id")]
+        public string PlayerId;
+    }
+
+    /// <summary>MaintenanceData counts down to a scheduled restart, or tells players it was cancelled. Message is the notice to show.</summary>
+    [Serializable]
+    public partial class MaintenanceData
+    {
+        /// <summary>Unix seconds. Omitted when unset.</summary>
+        [JsonProperty("restart_at", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long RestartAt;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("seconds_left", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public long SecondsLeft;
+
+        /// <summary>Omitted when unset.</summary>
+        [JsonProperty("cancelled", DefaultValueHandling = DefaultValueHandling.Ignore)]
+        public bool Cancelled;
+
+        [JsonProperty("message")]
+        public string Message;
+    }
+
+    /// <summary>A player in a binary PlayerMove or GameState frame.</summary>
+    public struct BinaryPlayer
+    {
+        /// <summary>The index announced in a PlayerIndex message.</summary>
+        public ushort Index;
+        public float X;
+        public float Y;
+        /// <summary>Only in GameState frames.</summary>
+        public byte Health;
+        public uint Score;
+        public ushort Level;
+    }
+
+    /// <summary>Decodes the binary PlayerMove and GameState frames sent to UDP clients with the binary_moves capability, and the compressed frames sent to those with the gzip capability. All integers are big-endian.</summary>
+    public static class BinaryFrames
+    {
+        public const byte Magic = 0xC1;
+        public const byte KindPlayerMove = 1;
+        public const byte KindGameState = 2;
+        /// <summary>A gzipped packet, sent to UDP clients with the gzip capability.</summary>
+        public const byte KindCompressed = 3;
+        public const float CoordScale = 100;
+
+        /// <summary>Reports whether a packet is a binary frame rather than JSON or MessagePack.</summary>
+        public static bool IsBinaryFrame(byte[] packet)
+        {
+            return packet.Length >= 6 && packet[0] == Magic;
+        }
+
+        /// <summary>Returns the packet inside a compressed frame, or the packet itself if it is not one. Call it on every packet before anything else.</summary>
+        public static byte[] Decompress(byte[] packet)
+        {
+            if (!IsBinaryFrame(packet) || packet[1] != KindCompressed)
+            {
+                return packet;
+            }
+            using (var input = new MemoryStream(packet, 6, packet.Length - 6))
+            using (var gzip = new GZipStream(input, CompressionMode.Decompress))
+            using (var output = new MemoryStream())
+            {
+                gzip.CopyTo(output);
+                return output.ToArray();
+            }
+        }
+
+        /// <summary>Decodes a frame into its kind, sequence (0 when unreliable) and players. A PlayerMove frame has one player.</summary>
+        public static bool TryDecode(byte[] packet, out byte kind, out uint sequence, out List<BinaryPlayer> players)
+        {
+            kind = 0;
+            sequence = 0;
+            players = new List<BinaryPlayer>();
+            if (!IsBinaryFrame(packet))
+            {
+                return false;
+            }
+            kind = packet[1];
+            sequence = ReadUInt32(packet, 2);
+
+            if (kind == KindPlayerMove)
+            {
+                if (packet.Length < 16)
+                {
+                    return false;
+                }
+                players.Add(new BinaryPlayer { Index = ReadUInt16(packet, 6), X = ReadCoord(packet, 8), Y = ReadCoord(packet, 12) });
+                return true;
+            }
+            if (kind != KindGameState || packet.Length < 8)
+            {
+                return false;
+            }
+
+            int count = ReadUInt16(packet, 6);
+            if (packet.Length < 8 + count * 17)
+            {
+                return false;
+            }
+            for (int i = 0, at = 8; i < count; i++, at += 17)
+            {
+                players.Add(new BinaryPlayer
+                {
+                    Index = ReadUInt16(packet, at),
+                    X = ReadCoord(packet, at + 2),
+                    Y = ReadCoord(packet, at + 6),
+                    Health = packet[at + 10],
+                    Score = ReadUInt32(packet, at + 11),
+                    Level = ReadUInt16(packet, at + 15),
+                });
+            }
+            return true;
+        }
+
+        static ushort ReadUInt16(byte[] b, int at)
+        {
+            return (ushort)(b[at] << 8 | b[at + 1]);
+        }
+
+        static uint ReadUInt32(byte[] b, int at)
+        {
+            return (uint)(b[at] << 24 | b[at + 1] << 16 | b[at + 2] << 8 | b[at + 3]);
+        }
+
+        static float ReadCoord(byte[] b, int at)
+        {
+            return (int)ReadUInt32(b, at) / CoordScale;
+        }
+    }
+}
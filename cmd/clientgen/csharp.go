@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// writeCSharp emits classes for Unity with the Newtonsoft JSON package
+// (com.unity.nuget.newtonsoft-json), which Unity ships.
+func writeCSharp(buf *bytes.Buffer, protocol *Protocol, namespace string) {
+	fmt.Fprint(buf, "// Code generated by cmd/clientgen from messagecatalog.go. DO NOT EDIT.\n\n")
+	fmt.Fprint(buf, "using System;\nusing System.Collections.Generic;\nusing System.IO;\nusing System.IO.Compression;\nusing Newtonsoft.Json;\nusing Newtonsoft.Json.Linq;\n\n")
+	fmt.Fprintf(buf, "namespace %s\n{\n", namespace)
+
+	fmt.Fprint(buf, "    /// <summary>The type of every message.</summary>\n")
+	fmt.Fprint(buf, "    public static class MessageTypes\n    {\n")
+	for _, messageType := range protocol.MessageTypes() {
+		fmt.Fprintf(buf, "        public const string %s = %q;\n", messageType, messageType)
+	}
+	fmt.Fprint(buf, "\n        /// <summary>The payload class of each message the server sends.</summary>\n")
+	writeCSharpPayloads(buf, "FromServer", protocol.SentBy("server"))
+	fmt.Fprint(buf, "\n        /// <summary>The payload class of each message the client sends.</summary>\n")
+	writeCSharpPayloads(buf, "FromClient", protocol.SentBy("client"))
+	fmt.Fprint(buf, "    }\n")
+
+	for _, s := range protocol.Structs {
+		fmt.Fprint(buf, "\n")
+		writeCSharpDoc(buf, "    ", s.Doc)
+		fmt.Fprintf(buf, "    [Serializable]\n    public partial class %s\n    {\n", s.Name)
+		for i, field := range s.Fields {
+			if i > 0 {
+				fmt.Fprint(buf, "\n")
+			}
+			writeCSharpDoc(buf, "        ", fieldDoc(field))
+			switch {
+			case field.Optional:
+				fmt.Fprintf(buf, "        [JsonProperty(%q, NullValueHandling = NullValueHandling.Ignore)]\n", field.Name)
+			case field.OmitEmpty:
+				fmt.Fprintf(buf, "        [JsonProperty(%q, DefaultValueHandling = DefaultValueHandling.Ignore)]\n", field.Name)
+			default:
+				fmt.Fprintf(buf, "        [JsonProperty(%q)]\n", field.Name)
+			}
+			fmt.Fprintf(buf, "        public %s %s;\n", csharpType(field), csharpName(field.Name, s.Name))
+		}
+		if s.Name == "GameMessage" {
+			fmt.Fprint(buf, csharpGameMessageHelpers)
+		}
+		fmt.Fprint(buf, "    }\n")
+	}
+
+	fmt.Fprint(buf, "\n")
+	fmt.Fprintf(buf, csharpBinaryFrames, protocol.Binary.Magic, protocol.Binary.KindPlayerMove, protocol.Binary.KindGameState, protocol.Binary.KindCompressed, protocol.Binary.CoordScale)
+	fmt.Fprint(buf, "}\n")
+}
+
+func writeCSharpPayloads(buf *bytes.Buffer, name string, messages []Message) {
+	fmt.Fprintf(buf, "        public static readonly Dictionary<string, Type> %s = new Dictionary<string, Type>\n        {\n", name)
+	for _, message := range messages {
+		if message.Data != "" {
+			fmt.Fprintf(buf, "            { %s, typeof(%s) },\n", message.Type, message.Data)
+		}
+	}
+	fmt.Fprint(buf, "        };\n")
+}
+
+func writeCSharpDoc(buf *bytes.Buffer, indent, doc string) {
+	if doc == "" {
+		return
+	}
+	doc = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(doc)
+	fmt.Fprintf(buf, "%s/// <summary>%s</summary>\n", indent, doc)
+}
+
+func csharpType(field Field) string {
+	t := csharpTypeRef(field.Type)
+	if field.Optional && field.Type.Kind != kindStruct && field.Type.Kind != kindString {
+		return t + "?"
+	}
+	return t
+}
+
+func csharpTypeRef(t TypeRef) string {
+	switch t.Kind {
+	case kindString, kindUUID, kindTime, kindBytes:
+		return "string"
+	case kindBool:
+		return "bool"
+	case kindInt:
+		return "int"
+	case kindInt64:
+		return "long"
+	case kindUint16:
+		return "ushort"
+	case kindUint32:
+		return "uint"
+	case kindUint64:
+		return "ulong"
+	case kindFloat:
+		return "float"
+	case kindDouble:
+		return "double"
+	case kindAny:
+		return "JToken"
+	case kindList:
+		return "List<" + csharpTypeRef(*t.Elem) + ">"
+	case kindMap:
+		return "Dictionary<string, " + csharpTypeRef(*t.Elem) + ">"
+	}
+	return t.Struct
+}
+
+// csharpName turns a JSON key such as player_id into PlayerId. Members may
+// not be named after their class.
+func csharpName(key, class string) string {
+	var name strings.Builder
+	for _, part := range strings.Split(key, "_") {
+		if part != "" {
+			name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	if name.String() == class {
+		name.WriteString("Value")
+	}
+	return name.String()
+}
+
+// fieldDoc documents a field, noting how types without a C# or GDScript
+// counterpart are encoded.
+func fieldDoc(field Field) string {
+	var notes []string
+	switch field.Type.Kind {
+	case kindUUID:
+		notes = append(notes, "A UUID.")
+	case kindTime:
+		notes = append(notes, "An RFC 3339 time.")
+	case kindBytes:
+		notes = append(notes, "Base64 in JSON, binary in MessagePack.")
+	}
+	if field.Doc != "" {
+		doc := strings.ToUpper(field.Doc[:1]) + field.Doc[1:]
+		if !strings.HasSuffix(doc, ".") {
+			doc += "."
+		}
+		notes = append(notes, doc)
+	}
+	if field.Optional || field.OmitEmpty {
+		notes = append(notes, "Omitted when unset.")
+	}
+	return strings.Join(notes, " ")
+}
+
+const csharpGameMessageHelpers = `
+        /// <summary>Decodes the payload as T.</summary>
+        public T DataAs<T>()
+        {
+            return Data == null ? default : Data.ToObject<T>();
+        }
+
+        /// <summary>Decodes the payload of a message from the server as its payload class, or returns null for messages without one.</summary>
+        public object DecodeData()
+        {
+            return Data != null && MessageTypes.FromServer.TryGetValue(Type, out var type) ? Data.ToObject(type) : null;
+        }
+
+        /// <summary>Returns a message of a type with a payload.</summary>
+        public static GameMessage Create(string type, object data = null)
+        {
+            return new GameMessage { Type = type, Data = data == null ? null : JToken.FromObject(data) };
+        }
+`
+
+const csharpBinaryFrames = `    /// <summary>A player in a binary PlayerMove or GameState frame.</summary>
+    public struct BinaryPlayer
+    {
+        /// <summary>The index announced in a PlayerIndex message.</summary>
+        public ushort Index;
+        public float X;
+        public float Y;
+        /// <summary>Only in GameState frames.</summary>
+        public byte Health;
+        public uint Score;
+        public ushort Level;
+    }
+
+    /// <summary>Decodes the binary PlayerMove and GameState frames sent to UDP clients with the binary_moves capability, and the compressed frames sent to those with the gzip capability. All integers are big-endian.</summary>
+    public static class BinaryFrames
+    {
+        public const byte Magic = 0x%02X;
+        public const byte KindPlayerMove = %d;
+        public const byte KindGameState = %d;
+        /// <summary>A gzipped packet, sent to UDP clients with the gzip capability.</summary>
+        public const byte KindCompressed = %d;
+        public const float CoordScale = %d;
+
+        /// <summary>Reports whether a packet is a binary frame rather than JSON or MessagePack.</summary>
+        public static bool IsBinaryFrame(byte[] packet)
+        {
+            return packet.Length >= 6 && packet[0] == Magic;
+        }
+
+        /// <summary>Returns the packet inside a compressed frame, or the packet itself if it is not one. Call it on every packet before anything else.</summary>
+        public static byte[] Decompress(byte[] packet)
+        {
+            if (!IsBinaryFrame(packet) || packet[1] != KindCompressed)
+            {
+                return packet;
+            }
+            using (var input = new MemoryStream(packet, 6, packet.Length - 6))
+            using (var gzip = new GZipStream(input, CompressionMode.Decompress))
+            using (var output = new MemoryStream())
+            {
+                gzip.CopyTo(output);
+                return output.ToArray();
+            }
+        }
+
+        /// <summary>Decodes a frame into its kind, sequence (0 when unreliable) and players. A PlayerMove frame has one player.</summary>
+        public static bool TryDecode(byte[] packet, out byte kind, out uint sequence, out List<BinaryPlayer> players)
+        {
+            kind = 0;
+            sequence = 0;
+            players = new List<BinaryPlayer>();
+            if (!IsBinaryFrame(packet))
+            {
+                return false;
+            }
+            kind = packet[1];
+            sequence = ReadUInt32(packet, 2);
+
+            if (kind == KindPlayerMove)
+            {
+                if (packet.Length < 16)
+                {
+                    return false;
+                }
+                players.Add(new BinaryPlayer { Index = ReadUInt16(packet, 6), X = ReadCoord(packet, 8), Y = ReadCoord(packet, 12) });
+                return true;
+            }
+            if (kind != KindGameState || packet.Length < 8)
+            {
+                return false;
+            }
+
+            int count = ReadUInt16(packet, 6);
+            if (packet.Length < 8 + count * 17)
+            {
+                return false;
+            }
+            for (int i = 0, at = 8; i < count; i++, at += 17)
+            {
+                players.Add(new BinaryPlayer
+                {
+                    Index = ReadUInt16(packet, at),
+                    X = ReadCoord(packet, at + 2),
+                    Y = ReadCoord(packet, at + 6),
+                    Health = packet[at + 10],
+                    Score = ReadUInt32(packet, at + 11),
+                    Level = ReadUInt16(packet, at + 15),
+                });
+            }
+            return true;
+        }
+
+        static ushort ReadUInt16(byte[] b, int at)
+        {
+            return (ushort)(b[at] << 8 | b[at + 1]);
+        }
+
+        static uint ReadUInt32(byte[] b, int at)
+        {
+            return (uint)(b[at] << 24 | b[at + 1] << 16 | b[at + 2] << 8 | b[at + 3]);
+        }
+
+        static float ReadCoord(byte[] b, int at)
+        {
+            return (int)ReadUInt32(b, at) / CoordScale;
+        }
+    }
+`
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// writeGDScript emits a Godot 4 script with a class per payload. Each class
+// converts from and to the Dictionary that JSON.parse_string returns and
+// JSON.stringify takes.
+func writeGDScript(buf *bytes.Buffer, protocol *Protocol) {
+	fmt.Fprint(buf, "# Code generated by cmd/clientgen from messagecatalog.go. DO NOT EDIT.\n")
+	fmt.Fprint(buf, "class_name GameMessages\n\n")
+
+	fmt.Fprint(buf, "# The type of every message.\n")
+	for _, messageType := range protocol.MessageTypes() {
+		fmt.Fprintf(buf, "const %s := %q\n", gdscriptConst(messageType), messageType)
+	}
+
+	fmt.Fprint(buf, "\n# Binary frames sent to UDP clients with the binary_moves capability, and\n")
+	fmt.Fprint(buf, "# compressed frames sent to those with the gzip capability.\n")
+	fmt.Fprintf(buf, "const BINARY_FRAME_MAGIC := 0x%02X\n", protocol.Binary.Magic)
+	fmt.Fprintf(buf, "const BINARY_KIND_PLAYER_MOVE := %d\n", protocol.Binary.KindPlayerMove)
+	fmt.Fprintf(buf, "const BINARY_KIND_GAME_STATE := %d\n", protocol.Binary.KindGameState)
+	fmt.Fprintf(buf, "const BINARY_KIND_COMPRESSED := %d\n", protocol.Binary.KindCompressed)
+	fmt.Fprintf(buf, "const BINARY_COORD_SCALE := %d.0\n", protocol.Binary.CoordScale)
+
+	fmt.Fprint(buf, "\n\n# Decodes the payload of a message from the server as its payload class,\n")
+	fmt.Fprint(buf, "# or returns null for messages without one.\n")
+	fmt.Fprint(buf, "static func decode_data(type: String, data: Variant) -> Variant:\n")
+	fmt.Fprint(buf, "\tif typeof(data) != TYPE_DICTIONARY:\n\t\treturn null\n")
+	fmt.Fprint(buf, "\tmatch type:\n")
+	for _, message := range protocol.SentBy("server") {
+		if message.Data != "" {
+			fmt.Fprintf(buf, "\t\t%s:\n\t\t\treturn %s.from_dict(data)\n", gdscriptConst(message.Type), message.Data)
+		}
+	}
+	fmt.Fprint(buf, "\treturn null\n")
+	fmt.Fprint(buf, gdscriptBinaryFrames)
+
+	for _, s := range protocol.Structs {
+		fmt.Fprint(buf, "\n\n")
+		writeGDScriptDoc(buf, "", s.Doc)
+		fmt.Fprintf(buf, "class %s:\n", s.Name)
+		for _, field := range s.Fields {
+			writeGDScriptDoc(buf, "\t", fieldDoc(field))
+			if t := gdscriptType(field); t != "" {
+				fmt.Fprintf(buf, "\tvar %s: %s = %s\n", field.Name, t, gdscriptZero(field))
+			} else {
+				fmt.Fprintf(buf, "\tvar %s = null\n", field.Name)
+			}
+		}
+
+		fmt.Fprintf(buf, "\n\tstatic func from_dict(d: Dictionary) -> %s:\n", s.Name)
+		fmt.Fprintf(buf, "\t\tvar m := %s.new()\n", s.Name)
+		for _, field := range s.Fields {
+			fmt.Fprintf(buf, "\t\tif d.has(%q):\n", field.Name)
+			fmt.Fprintf(buf, "\t\t\tm.%s = %s\n", field.Name, gdscriptFrom(field.Type, fmt.Sprintf("d[%q]", field.Name)))
+		}
+		fmt.Fprint(buf, "\t\treturn m\n")
+
+		fmt.Fprint(buf, "\n\tfunc to_dict() -> Dictionary:\n")
+		fmt.Fprint(buf, "\t\tvar d := {}\n")
+		for _, field := range s.Fields {
+			value := gdscriptTo(field.Type, field.Name)
+			if field.Optional || field.OmitEmpty || gdscriptZero(field) == "null" {
+				fmt.Fprintf(buf, "\t\tif %s != %s:\n\t\t\td[%q] = %s\n", field.Name, gdscriptZero(field), field.Name, value)
+			} else {
+				fmt.Fprintf(buf, "\t\td[%q] = %s\n", field.Name, value)
+			}
+		}
+		fmt.Fprint(buf, "\t\treturn d\n")
+	}
+}
+
+func writeGDScriptDoc(buf *bytes.Buffer, indent, doc string) {
+	if doc != "" {
+		fmt.Fprintf(buf, "%s## %s\n", indent, doc)
+	}
+}
+
+// gdscriptConst turns a message type such as PlayerJoin into PLAYER_JOIN.
+func gdscriptConst(name string) string {
+	var constant strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			constant.WriteByte('_')
+		}
+		constant.WriteRune(r)
+	}
+	return strings.ToUpper(constant.String())
+}
+
+// gdscriptType returns the static type of a field, or "" for fields that
+// may be null.
+func gdscriptType(field Field) string {
+	if field.Optional && field.Type.Kind != kindStruct {
+		return ""
+	}
+	switch field.Type.Kind {
+	case kindString, kindUUID, kindTime, kindBytes:
+		return "String"
+	case kindBool:
+		return "bool"
+	case kindInt, kindInt64, kindUint16, kindUint32, kindUint64:
+		return "int"
+	case kindFloat, kindDouble:
+		return "float"
+	case kindList:
+		return "Array"
+	case kindMap:
+		return "Dictionary"
+	case kindAny:
+		return ""
+	}
+	return field.Type.Struct
+}
+
+func gdscriptZero(field Field) string {
+	switch gdscriptType(field) {
+	case "String":
+		return `""`
+	case "bool":
+		return "false"
+	case "int":
+		return "0"
+	case "float":
+		return "0.0"
+	case "Array":
+		return "[]"
+	case "Dictionary":
+		return "{}"
+	}
+	return "null"
+}
+
+// gdscriptFrom converts a decoded JSON value. JSON numbers decode as floats.
+func gdscriptFrom(t TypeRef, value string) string {
+	switch t.Kind {
+	case kindInt, kindInt64, kindUint16, kindUint32, kindUint64:
+		return "int(" + value + ")"
+	case kindFloat, kindDouble:
+		return "float(" + value + ")"
+	case kindStruct:
+		return t.Struct + ".from_dict(" + value + ")"
+	case kindList:
+		if t.Elem.Kind == kindStruct || t.Elem.Kind == kindList {
+			return value + ".map(func(e): return " + gdscriptFrom(*t.Elem, "e") + ")"
+		}
+	case kindMap:
+		if t.Elem.Kind == kindStruct {
+			return "GameMessages._map_values(" + value + ", func(e): return " + gdscriptFrom(*t.Elem, "e") + ")"
+		}
+	}
+	return value
+}
+
+func gdscriptTo(t TypeRef, value string) string {
+	switch t.Kind {
+	case kindStruct:
+		return value + ".to_dict()"
+	case kindList:
+		if t.Elem.Kind == kindStruct || t.Elem.Kind == kindList {
+			return value + ".map(func(e): return " + gdscriptTo(*t.Elem, "e") + ")"
+		}
+	case kindMap:
+		if t.Elem.Kind == kindStruct {
+			return "GameMessages._map_values(" + value + ", func(e): return " + gdscriptTo(*t.Elem, "e") + ")"
+		}
+	}
+	return value
+}
+
+const gdscriptBinaryFrames = `
+
+# Returns the packet inside a compressed frame, or the packet itself if it
+# is not one. Call it on every packet before anything else.
+static func decompress_packet(packet: PackedByteArray) -> PackedByteArray:
+	if packet.size() < 6 or packet[0] != BINARY_FRAME_MAGIC or packet[1] != BINARY_KIND_COMPRESSED:
+		return packet
+	return packet.slice(6).decompress_dynamic(-1, FileAccess.COMPRESSION_GZIP)
+
+
+# Decodes a binary PlayerMove or GameState frame into its kind, sequence (0
+# when unreliable) and players, or returns an empty Dictionary for packets
+# that are not binary frames. All integers are big-endian.
+static func decode_binary_frame(packet: PackedByteArray) -> Dictionary:
+	if packet.size() < 6 or packet[0] != BINARY_FRAME_MAGIC:
+		return {}
+	var stream := StreamPeerBuffer.new()
+	stream.big_endian = true
+	stream.data_array = packet
+	stream.seek(1)
+	var kind := stream.get_u8()
+	var frame := {"kind": kind, "sequence": stream.get_u32(), "players": []}
+	if kind == BINARY_KIND_PLAYER_MOVE and packet.size() >= 16:
+		frame.players.append({"index": stream.get_u16(), "x": stream.get_32() / BINARY_COORD_SCALE, "y": stream.get_32() / BINARY_COORD_SCALE})
+	elif kind == BINARY_KIND_GAME_STATE and packet.size() >= 8:
+		var count := stream.get_u16()
+		if packet.size() < 8 + count * 17:
+			return {}
+		for i in count:
+			frame.players.append({
+				"index": stream.get_u16(),
+				"x": stream.get_32() / BINARY_COORD_SCALE,
+				"y": stream.get_32() / BINARY_COORD_SCALE,
+				"health": stream.get_u8(),
+				"score": stream.get_u32(),
+				"level": stream.get_u16(),
+			})
+	else:
+		return {}
+	return frame
+
+
+static func _map_values(d: Dictionary, convert: Callable) -> Dictionary:
+	var result := {}
+	for key in d:
+		result[key] = convert.call(d[key])
+	return result
+`
@@ -0,0 +1,59 @@
+// Command clientgen generates the message classes of Unity (C#) and Godot
+// (GDScript) clients, and a message reference, from the message catalog in
+// messagecatalog.go and the payload types it names, so that clients keep the
+// server's field names. It reads the source of package game, and of package
+// store next to it, rather than importing them, and is run by go generate in
+// game/, or from the repository root:
+//
+//	go run ./cmd/clientgen -dir game -lang csharp -out clients/unity/GameMessages.cs
+//	go run ./cmd/clientgen -dir game -lang gdscript -out clients/godot/game_messages.gd
+//	go run ./cmd/clientgen -dir game -lang markdown -out clients/MESSAGES.md
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	lang := flag.String("lang", "csharp", "csharp, gdscript or markdown")
+	dir := flag.String("dir", ".", "directory of package game")
+	out := flag.String("out", "", "file to write, standard output if empty")
+	namespace := flag.String("namespace", "GameServer.Messages", "C# namespace of the generated classes")
+	flag.Parse()
+
+	protocol, err := loadProtocol(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clientgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	switch *lang {
+	case "csharp":
+		writeCSharp(&buf, protocol, *namespace)
+	case "gdscript":
+		writeGDScript(&buf, protocol)
+	case "markdown":
+		writeMarkdown(&buf, protocol)
+	default:
+		fmt.Fprintf(os.Stderr, "clientgen: unknown language %q, expected csharp, gdscript or markdown\n", *lang)
+		os.Exit(2)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "clientgen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "clientgen: %v\n", err)
+		os.Exit(1)
+	}
+}
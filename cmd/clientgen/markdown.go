@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// writeMarkdown emits a reference of every message and payload.
+func writeMarkdown(buf *bytes.Buffer, protocol *Protocol) {
+	fmt.Fprint(buf, "<!-- Code generated by cmd/clientgen from messagecatalog.go. DO NOT EDIT. -->\n\n")
+	fmt.Fprint(buf, "# Message reference\n\n")
+	fmt.Fprint(buf, "Every message is a `GameMessage` whose `type` names the message and whose `data` is its payload, ")
+	fmt.Fprint(buf, "encoded as JSON or MessagePack with the same keys. ")
+	fmt.Fprint(buf, "Unity and Godot classes for these payloads are in `unity/` and `godot/`.\n")
+
+	for _, message := range protocol.Messages {
+		if message.Section != "" {
+			fmt.Fprintf(buf, "\n## %s\n", message.Section)
+		}
+		fmt.Fprintf(buf, "\n### %s\n\n", message.Type)
+		fmt.Fprintf(buf, "Sent by the %s.", describeSender(message.Sender))
+		if message.Data == "" {
+			fmt.Fprint(buf, " No payload.\n")
+			continue
+		}
+		fmt.Fprintf(buf, " Payload: [`%s`](%s).\n", message.Data, payloadAnchor(protocol, message.Data))
+	}
+
+	fmt.Fprint(buf, "\n## Payloads\n")
+	for _, s := range protocol.Structs {
+		fmt.Fprintf(buf, "\n### %s\n\n", s.Name)
+		if s.Doc != "" {
+			fmt.Fprintf(buf, "%s\n\n", s.Doc)
+		}
+		fmt.Fprint(buf, "| Field | Type | Notes |\n|---|---|---|\n")
+		for _, field := range s.Fields {
+			fmt.Fprintf(buf, "| `%s` | %s | %s |\n", field.Name, markdownType(protocol, field.Type), strings.ReplaceAll(fieldDoc(field), "|", `\|`))
+		}
+	}
+
+	fmt.Fprint(buf, "\n## Binary frames\n\n")
+	fmt.Fprint(buf, "UDP clients that send the `binary_moves` capability in their first Heartbeat receive PlayerMove and GameState ")
+	fmt.Fprint(buf, "as fixed-layout binary frames. Players are referred to by the indexes of PlayerIndex messages and coordinates ")
+	fmt.Fprintf(buf, "are fixed point, %d steps per unit. All integers are big-endian.\n\n", protocol.Binary.CoordScale)
+	fmt.Fprint(buf, "| Part | Layout |\n|---|---|\n")
+	fmt.Fprintf(buf, "| Header | magic u8 (0x%02X), kind u8, sequence u32 (0 when unreliable) |\n", protocol.Binary.Magic)
+	fmt.Fprintf(buf, "| PlayerMove (kind %d) | index u16, x i32, y i32 |\n", protocol.Binary.KindPlayerMove)
+	fmt.Fprintf(buf, "| GameState (kind %d) | count u16, then per player: index u16, x i32, y i32, health u8, score u32, level u16 |\n", protocol.Binary.KindGameState)
+	fmt.Fprintf(buf, "| Compressed (kind %d) | the gzip of a JSON or MessagePack packet, whose sequence the header repeats |\n", protocol.Binary.KindCompressed)
+	fmt.Fprint(buf, "\nUDP clients that send the `gzip` capability receive large packets, such as the GameState of a big lobby, ")
+	fmt.Fprint(buf, "as compressed frames, and handle the packet inside as if it had arrived as is. ")
+	fmt.Fprint(buf, "WebSocket clients get the same from permessage-deflate.\n")
+}
+
+func describeSender(sender string) string {
+	if sender == "both" {
+		return "client and the server"
+	}
+	return sender
+}
+
+func markdownType(protocol *Protocol, t TypeRef) string {
+	switch t.Kind {
+	case kindUUID:
+		return "UUID string"
+	case kindTime:
+		return "time string"
+	case kindBytes:
+		return "bytes"
+	case kindList:
+		return "list of " + markdownType(protocol, *t.Elem)
+	case kindMap:
+		return "map of " + markdownType(protocol, *t.Elem)
+	case kindStruct:
+		return fmt.Sprintf("[`%s`](%s)", t.Struct, payloadAnchor(protocol, t.Struct))
+	}
+	return t.Kind
+}
+
+// payloadAnchor links to the heading of a payload. GitHub numbers the anchors
+// of repeated headings, and a payload named after its message comes second.
+func payloadAnchor(protocol *Protocol, name string) string {
+	for _, messageType := range protocol.MessageTypes() {
+		if messageType == name {
+			return "#" + strings.ToLower(name) + "-1"
+		}
+	}
+	return "#" + strings.ToLower(name)
+}
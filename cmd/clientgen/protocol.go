@@ -0,0 +1,545 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Kinds of field types, named after the Go types they come from.
+const (
+	kindString = "string"
+	kindBool   = "bool"
+	kindInt    = "int"    // int and int32
+	kindInt64  = "int64"  // int64 and time.Duration
+	kindUint16 = "uint16" // uint8 and uint16
+	kindUint32 = "uint32"
+	kindUint64 = "uint64"
+	kindFloat  = "float32"
+	kindDouble = "float64"
+	kindUUID   = "uuid"  // a UUID string
+	kindTime   = "time"  // an RFC 3339 string
+	kindBytes  = "bytes" // base64 in JSON, binary in MessagePack
+	kindAny    = "any"
+	kindList   = "list"
+	kindMap    = "map" // string keys
+	kindStruct = "struct"
+)
+
+// TypeRef is the type of a field.
+type TypeRef struct {
+	Kind   string
+	Elem   *TypeRef // of lists and maps
+	Struct string   // name of a struct
+}
+
+// Field is a field of a payload as it appears in JSON.
+type Field struct {
+	Name      string // the JSON key
+	Type      TypeRef
+	Optional  bool // a pointer, omitted when unset
+	OmitEmpty bool
+	Doc       string
+}
+
+// Struct is a payload type or a type payloads contain.
+type Struct struct {
+	Name   string
+	Doc    string
+	Fields []Field
+}
+
+// Message is an entry of the message catalog.
+type Message struct {
+	Type    string
+	Data    string // payload struct, empty for none
+	Sender  string // "client", "server" or "both"
+	Section string // heading of the catalog group it starts, if any
+}
+
+// BinaryFrames are the constants of the binary frame layout in
+// binarymove.go.
+type BinaryFrames struct {
+	Magic          int64
+	KindPlayerMove int64
+	KindGameState  int64
+	KindCompressed int64
+	CoordScale     int64
+}
+
+// Protocol is everything the generators emit.
+type Protocol struct {
+	Messages []Message
+	Structs  []*Struct // in the order they are first referenced
+	Binary   BinaryFrames
+}
+
+// MessageTypes returns the distinct message types in catalog order.
+func (p *Protocol) MessageTypes() []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, message := range p.Messages {
+		if !seen[message.Type] {
+			seen[message.Type] = true
+			types = append(types, message.Type)
+		}
+	}
+	return types
+}
+
+// SentBy returns the messages a sender sends, "client" or "server".
+func (p *Protocol) SentBy(sender string) []Message {
+	var messages []Message
+	for _, message := range p.Messages {
+		if message.Sender == sender || message.Sender == "both" {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// The binary frame layout the generated decoders implement, checked against
+// the sizes in binarymove.go.
+const (
+	binaryHeaderSize = 1 + 1 + 4             // magic, kind, sequence
+	binaryMoveSize   = 2 + 4 + 4             // index, x, y
+	binaryPlayerSize = 2 + 4 + 4 + 1 + 4 + 2 // index, x, y, health, score, level
+)
+
+// loader reads the declarations of package game.
+type loader struct {
+	fset     *token.FileSet
+	files    []*ast.File
+	types    map[string]*ast.TypeSpec
+	docs     map[string]string
+	consts   map[string]ast.Expr
+	protocol *Protocol
+	structs  map[string]*Struct
+}
+
+func loadProtocol(dir string) (*Protocol, error) {
+	l := &loader{
+		fset:     token.NewFileSet(),
+		types:    make(map[string]*ast.TypeSpec),
+		docs:     make(map[string]string),
+		consts:   make(map[string]ast.Expr),
+		protocol: &Protocol{},
+		structs:  make(map[string]*Struct),
+	}
+	notTest := func(info os.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }
+	packages, err := parser.ParseDir(l.fset, dir, notTest, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", dir, err)
+	}
+	pkg, ok := packages["game"]
+	if !ok {
+		return nil, fmt.Errorf("no package game in %s", dir)
+	}
+	for _, file := range pkg.Files {
+		l.files = append(l.files, file)
+		l.collect(file)
+	}
+
+	// Payloads also carry the records of package store, next to game
+	storeDir := filepath.Join(dir, "..", "store")
+	packages, err = parser.ParseDir(l.fset, storeDir, notTest, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", storeDir, err)
+	}
+	storePkg, ok := packages["store"]
+	if !ok {
+		return nil, fmt.Errorf("no package store in %s", storeDir)
+	}
+	for _, file := range storePkg.Files {
+		if err := l.collectTypes(file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.loadCatalog(); err != nil {
+		return nil, err
+	}
+	if err := l.loadBinaryFrames(); err != nil {
+		return nil, err
+	}
+	return l.protocol, nil
+}
+
+// collect records the type and constant declarations of a file.
+func (l *loader) collect(file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				l.types[spec.Name.Name] = spec
+				doc := spec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				l.docs[spec.Name.Name] = cleanDoc(doc.Text())
+			case *ast.ValueSpec:
+				if gen.Tok != token.CONST {
+					continue
+				}
+				for i, name := range spec.Names {
+					if i < len(spec.Values) {
+						l.consts[name.Name] = spec.Values[i]
+					}
+				}
+			}
+		}
+	}
+}
+
+// collectTypes records the type declarations of a file of package store.
+// Generated classes are named after the types alone, so they must not clash
+// with those of package game.
+func (l *loader) collectTypes(file *ast.File) error {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if _, ok := l.types[spec.Name.Name]; ok {
+				return fmt.Errorf("type store.%s has the name of a type of package game", spec.Name.Name)
+			}
+			l.types[spec.Name.Name] = spec
+			doc := spec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			l.docs[spec.Name.Name] = cleanDoc(doc.Text())
+		}
+	}
+	return nil
+}
+
+// loadCatalog reads messageCatalog and every type its payloads use.
+func (l *loader) loadCatalog() error {
+	for _, file := range l.files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value := spec.(*ast.ValueSpec)
+				if len(value.Names) != 1 || value.Names[0].Name != "messageCatalog" || len(value.Values) != 1 {
+					continue
+				}
+				catalog, ok := value.Values[0].(*ast.CompositeLit)
+				if !ok {
+					return fmt.Errorf("messageCatalog is not a composite literal")
+				}
+				return l.readCatalog(file, catalog)
+			}
+		}
+	}
+	return fmt.Errorf("messageCatalog not found")
+}
+
+func (l *loader) readCatalog(file *ast.File, catalog *ast.CompositeLit) error {
+	// Comments heading a group of entries name its section
+	sections := make(map[int]string)
+	for _, group := range file.Comments {
+		if group.Pos() > catalog.Lbrace && group.End() < catalog.Rbrace {
+			sections[l.fset.Position(group.End()).Line+1] = cleanDoc(group.Text())
+		}
+	}
+
+	for _, elt := range catalog.Elts {
+		entry, ok := elt.(*ast.CompositeLit)
+		if !ok || len(entry.Elts) != 3 {
+			return fmt.Errorf("%s: catalog entries must be {type, payload, sender}", l.fset.Position(elt.Pos()))
+		}
+		messageType, err := l.stringValue(entry.Elts[0])
+		if err != nil {
+			return err
+		}
+		sender, err := l.stringValue(entry.Elts[2])
+		if err != nil {
+			return err
+		}
+
+		message := Message{Type: messageType, Sender: sender, Section: sections[l.fset.Position(entry.Pos()).Line]}
+		switch data := entry.Elts[1].(type) {
+		case *ast.Ident:
+			if data.Name != "nil" {
+				return fmt.Errorf("%s: payload of %s must be a composite literal or nil", l.fset.Position(data.Pos()), messageType)
+			}
+		case *ast.CompositeLit:
+			name, ok := typeName(data.Type)
+			if !ok {
+				return fmt.Errorf("%s: payload of %s must be a struct of the package or store", l.fset.Position(data.Pos()), messageType)
+			}
+			if _, err := l.loadStruct(name); err != nil {
+				return fmt.Errorf("payload of %s: %w", messageType, err)
+			}
+			message.Data = name
+		default:
+			return fmt.Errorf("%s: payload of %s must be a composite literal or nil", l.fset.Position(data.Pos()), messageType)
+		}
+		l.protocol.Messages = append(l.protocol.Messages, message)
+	}
+	return nil
+}
+
+// stringValue evaluates a string literal or a string constant.
+func (l *loader) stringValue(expr ast.Expr) (string, error) {
+	if ident, ok := expr.(*ast.Ident); ok {
+		value, ok := l.consts[ident.Name]
+		if !ok {
+			return "", fmt.Errorf("%s: %s is not a constant", l.fset.Position(expr.Pos()), ident.Name)
+		}
+		expr = value
+	}
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", fmt.Errorf("%s: expected a string", l.fset.Position(expr.Pos()))
+	}
+	return strconv.Unquote(lit.Value)
+}
+
+// intValue evaluates an integer constant.
+func (l *loader) intValue(name string) (int64, error) {
+	value, ok := l.consts[name]
+	if !ok {
+		return 0, fmt.Errorf("constant %s not found", name)
+	}
+	lit, ok := value.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0, fmt.Errorf("constant %s is not an integer literal", name)
+	}
+	return strconv.ParseInt(lit.Value, 0, 64)
+}
+
+func (l *loader) loadBinaryFrames() error {
+	values := make(map[string]int64)
+	for _, name := range []string{"binaryFrameMagic", "binaryKindPlayerMove", "binaryKindGameState", "binaryKindCompressed", "binaryCoordScale", "binaryFrameHeaderSize", "binaryMoveSize", "binaryPlayerSize"} {
+		value, err := l.intValue(name)
+		if err != nil {
+			return err
+		}
+		values[name] = value
+	}
+
+	// The decoders are written for this layout
+	if values["binaryFrameHeaderSize"] != binaryHeaderSize || values["binaryMoveSize"] != binaryMoveSize || values["binaryPlayerSize"] != binaryPlayerSize {
+		return fmt.Errorf("the binary frame layout in binarymove.go changed; update the decoders of cmd/clientgen")
+	}
+	l.protocol.Binary = BinaryFrames{
+		Magic:          values["binaryFrameMagic"],
+		KindPlayerMove: values["binaryKindPlayerMove"],
+		KindGameState:  values["binaryKindGameState"],
+		KindCompressed: values["binaryKindCompressed"],
+		CoordScale:     values["binaryCoordScale"],
+	}
+	return nil
+}
+
+// loadStruct reads a struct type and the types it uses, once.
+func (l *loader) loadStruct(name string) (*Struct, error) {
+	if s, ok := l.structs[name]; ok {
+		return s, nil
+	}
+	spec, ok := l.types[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s not found", name)
+	}
+	structType, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a struct", name)
+	}
+
+	s := &Struct{Name: name, Doc: l.docs[name]}
+	l.structs[name] = s
+	l.protocol.Structs = append(l.protocol.Structs, s)
+	fields, err := l.loadFields(structType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	s.Fields = fields
+	return s, nil
+}
+
+// loadFields returns the fields of a struct as encoding/json sees them,
+// with embedded structs flattened.
+func (l *loader) loadFields(structType *ast.StructType) ([]Field, error) {
+	var fields []Field
+	for _, astField := range structType.Fields.List {
+		var tag reflect.StructTag
+		if astField.Tag != nil {
+			unquoted, err := strconv.Unquote(astField.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(unquoted)
+		}
+		jsonTag, hasTag := tag.Lookup("json")
+		name, options, _ := strings.Cut(jsonTag, ",")
+		if name == "-" {
+			continue
+		}
+
+		if len(astField.Names) == 0 && !hasTag {
+			embedded, ok := typeName(astField.Type)
+			if !ok {
+				return nil, fmt.Errorf("embedded field %s is not a struct of the package or store", exprString(astField.Type))
+			}
+			s, err := l.loadStruct(embedded)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, s.Fields...)
+			continue
+		}
+
+		for _, ident := range astField.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			field := Field{Name: name, OmitEmpty: strings.Contains(options, "omitempty")}
+			if field.Name == "" {
+				field.Name = ident.Name
+			}
+			fieldType := astField.Type
+			if star, ok := fieldType.(*ast.StarExpr); ok {
+				field.Optional = true
+				fieldType = star.X
+			}
+			ref, err := l.typeRef(fieldType)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", ident.Name, err)
+			}
+			field.Type = ref
+
+			doc := astField.Doc
+			if doc == nil {
+				doc = astField.Comment
+			}
+			field.Doc = cleanDoc(doc.Text())
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func (l *loader) typeRef(expr ast.Expr) (TypeRef, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return TypeRef{Kind: kindString}, nil
+		case "bool":
+			return TypeRef{Kind: kindBool}, nil
+		case "int", "int32":
+			return TypeRef{Kind: kindInt}, nil
+		case "int64":
+			return TypeRef{Kind: kindInt64}, nil
+		case "uint8", "byte", "uint16":
+			return TypeRef{Kind: kindUint16}, nil
+		case "uint32":
+			return TypeRef{Kind: kindUint32}, nil
+		case "uint64":
+			return TypeRef{Kind: kindUint64}, nil
+		case "float32":
+			return TypeRef{Kind: kindFloat}, nil
+		case "float64":
+			return TypeRef{Kind: kindDouble}, nil
+		}
+		spec, ok := l.types[t.Name]
+		if !ok {
+			return TypeRef{}, fmt.Errorf("type %s not found", t.Name)
+		}
+		if _, ok := spec.Type.(*ast.StructType); !ok {
+			// A named basic type is encoded as its underlying type
+			return l.typeRef(spec.Type)
+		}
+		if _, err := l.loadStruct(t.Name); err != nil {
+			return TypeRef{}, err
+		}
+		return TypeRef{Kind: kindStruct, Struct: t.Name}, nil
+
+	case *ast.SelectorExpr:
+		switch exprString(t) {
+		case "uuid.UUID":
+			return TypeRef{Kind: kindUUID}, nil
+		case "time.Time":
+			return TypeRef{Kind: kindTime}, nil
+		case "time.Duration":
+			return TypeRef{Kind: kindInt64}, nil
+		}
+		if name, ok := typeName(t); ok {
+			return l.typeRef(ast.NewIdent(name))
+		}
+
+	case *ast.ArrayType:
+		if t.Len == nil {
+			if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+				return TypeRef{Kind: kindBytes}, nil
+			}
+			elem, err := l.typeRef(t.Elt)
+			if err != nil {
+				return TypeRef{}, err
+			}
+			return TypeRef{Kind: kindList, Elem: &elem}, nil
+		}
+
+	case *ast.MapType:
+		if key, ok := t.Key.(*ast.Ident); ok && key.Name == "string" {
+			elem, err := l.typeRef(t.Value)
+			if err != nil {
+				return TypeRef{}, err
+			}
+			return TypeRef{Kind: kindMap, Elem: &elem}, nil
+		}
+
+	case *ast.InterfaceType:
+		return TypeRef{Kind: kindAny}, nil
+	}
+	return TypeRef{}, fmt.Errorf("unsupported type %s", exprString(expr))
+}
+
+// typeName returns the name of a type of package game or store.
+func typeName(expr ast.Expr) (string, bool) {
+	if sel, ok := expr.(*ast.SelectorExpr); ok && exprString(sel.X) == "store" {
+		return sel.Sel.Name, true
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name, true
+	}
+	return "", false
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.ArrayType:
+		return "[]" + exprString(e.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(e.Key) + "]" + exprString(e.Value)
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// cleanDoc joins the lines of a comment into one paragraph.
+func cleanDoc(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
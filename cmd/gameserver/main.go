@@ -0,0 +1,27 @@
+// Command gameserver runs the game server, configured by environment
+// variables; see Config in package game for the settings. It is run from the
+// repository root, where the migrations are:
+//
+//	go run ./cmd/gameserver
+//	PROTOCOL=udp PORT=8081 go run ./cmd/gameserver
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/server"
+)
+
+func init() {
+	// Set up logging
+	logrus.SetLevel(logrus.InfoLevel)
+	logrus.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+}
+
+func main() {
+	// Get configuration from environment variables
+	server.Run(game.LoadConfig())
+}
@@ -0,0 +1,544 @@
+// Command loadtest replays traffic recorded in a server's event log against a
+// test server, so that capacity planning uses the message mix and pacing of
+// real players instead of uniform synthetic load.
+//
+// Every player in the recorded window is replayed by its own connection,
+// which joins, moves, chats, attacks and picks up items when the recording
+// says it did, sped up by -speed.
+//
+//	go run ./cmd/loadtest -db game.db -since 1h -addr localhost:8080
+//	go run ./cmd/loadtest -db game.db -protocol udp -speed 10 -max-players 200
+package main
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type gameMessage struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type udpPacket struct {
+	Sequence  uint32      `json:"sequence"`
+	Timestamp int64       `json:"timestamp"`
+	Message   gameMessage `json:"message"`
+	Reliable  bool        `json:"reliable"`
+}
+
+// recordedEvent is one replayable row of the player_events table.
+type recordedEvent struct {
+	Type   string
+	Data   gameMessage
+	Offset time.Duration // since the first event of the recording
+}
+
+// replayedTypes are the events that correspond to a client message or
+// connection change; the rest are derived by the server.
+var replayedTypes = []string{"join", "leave", "move", "chat", "attack", "pickup"}
+
+// loadStats is shared by every replayed player.
+type loadStats struct {
+	connected int64
+	failed    int64
+	sent      int64
+	received  int64
+	errors    int64
+
+	mu     sync.Mutex
+	byType map[string]int64
+}
+
+func (s *loadStats) countSent(messageType string) {
+	atomic.AddInt64(&s.sent, 1)
+	s.mu.Lock()
+	s.byType[messageType]++
+	s.mu.Unlock()
+}
+
+// client is a connected replay player.
+type client interface {
+	PlayerID() uuid.UUID
+	Send(msg gameMessage) error
+	Close() error
+}
+
+func main() {
+	protocol := flag.String("protocol", getEnv("PROTOCOL", "websocket"), "websocket, udp or tcp")
+	addr := flag.String("addr", "localhost:"+getEnv("PORT", "8080"), "server host:port")
+	dbPath := flag.String("db", "game.db", "SQLite database holding the recorded event log")
+	since := flag.Duration("since", time.Hour, "replay events recorded within this long before the latest event")
+	speed := flag.Float64("speed", 1, "replay speed multiplier")
+	maxPlayers := flag.Int("max-players", 0, "replay at most this many players, 0 for all")
+	flag.Parse()
+
+	if *speed <= 0 {
+		fmt.Fprintln(os.Stderr, "FAIL: -speed must be positive")
+		os.Exit(1)
+	}
+	switch *protocol {
+	case "websocket", "udp", "tcp":
+	default:
+		fmt.Fprintf(os.Stderr, "FAIL: unknown protocol %q\n", *protocol)
+		os.Exit(1)
+	}
+
+	recordings, err := loadRecordings(*dbPath, *since, *maxPlayers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL: %v\n", err)
+		os.Exit(1)
+	}
+	if len(recordings) == 0 {
+		fmt.Fprintln(os.Stderr, "FAIL: no replayable events in the recorded window")
+		os.Exit(1)
+	}
+
+	var total int
+	var length time.Duration
+	for _, events := range recordings {
+		total += len(events)
+		if last := events[len(events)-1].Offset; last > length {
+			length = last
+		}
+	}
+	fmt.Printf("Replaying %d events of %d players spanning %s at %gx against %s (%s)\n",
+		total, len(recordings), length, *speed, *addr, *protocol)
+
+	stats := &loadStats{byType: make(map[string]int64)}
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for _, events := range recordings {
+		wg.Add(1)
+		go func(events []recordedEvent) {
+			defer wg.Done()
+			replayPlayer(*protocol, *addr, events, start, *speed, stats)
+		}(events)
+	}
+	wg.Wait()
+
+	report(stats, time.Since(start))
+}
+
+// loadRecordings reads the replayable events of the recorded window grouped
+// by player. Anonymized events cannot be attributed to a player and are
+// skipped.
+func loadRecordings(dbPath string, since time.Duration, maxPlayers int) ([][]recordedEvent, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(replayedTypes)), ", ")
+	query := `
+		SELECT player_id, event_type, event_data, timestamp
+		FROM player_events
+		WHERE timestamp >= (SELECT datetime(MAX(timestamp), ?) FROM player_events)
+			AND player_id != ? AND event_type IN (` + placeholders + `)
+		ORDER BY id
+	`
+	args := []interface{}{fmt.Sprintf("-%d seconds", int64(since.Seconds())), uuid.Nil.String()}
+	for _, eventType := range replayedTypes {
+		args = append(args, eventType)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		eventType string
+		data      gameMessage
+		timestamp time.Time
+	}
+	byPlayer := make(map[string][]row)
+	var order []string
+	var first time.Time
+	for rows.Next() {
+		var playerID, eventType string
+		var eventData sql.NullString
+		var timestamp time.Time
+		if err := rows.Scan(&playerID, &eventType, &eventData, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		var data gameMessage
+		if eventData.Valid {
+			json.Unmarshal([]byte(eventData.String), &data)
+		}
+
+		if _, seen := byPlayer[playerID]; !seen {
+			if maxPlayers > 0 && len(order) == maxPlayers {
+				continue
+			}
+			order = append(order, playerID)
+		}
+		byPlayer[playerID] = append(byPlayer[playerID], row{eventType, data, timestamp})
+		if first.IsZero() || timestamp.Before(first) {
+			first = timestamp
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	// Timestamps have one second resolution, so a player's events within the
+	// same second are spread evenly across it
+	recordings := make([][]recordedEvent, 0, len(order))
+	for _, playerID := range order {
+		playerRows := byPlayer[playerID]
+		events := make([]recordedEvent, 0, len(playerRows))
+		for i := 0; i < len(playerRows); {
+			j := i
+			for j < len(playerRows) && playerRows[j].timestamp.Equal(playerRows[i].timestamp) {
+				j++
+			}
+			base := playerRows[i].timestamp.Sub(first)
+			for k := i; k < j; k++ {
+				spread := time.Duration(k-i) * time.Second / time.Duration(j-i)
+				events = append(events, recordedEvent{
+					Type:   playerRows[k].eventType,
+					Data:   playerRows[k].data,
+					Offset: base + spread,
+				})
+			}
+			i = j
+		}
+		recordings = append(recordings, events)
+	}
+	return recordings, nil
+}
+
+// replayPlayer connects at the player's first event and sends each recorded
+// action at its scaled offset, reconnecting after a recorded leave.
+func replayPlayer(protocol, addr string, events []recordedEvent, start time.Time, speed float64, stats *loadStats) {
+	var c client
+	defer func() {
+		if c != nil {
+			c.Close()
+		}
+	}()
+
+	for _, event := range events {
+		if wait := time.Until(start.Add(time.Duration(float64(event.Offset) / speed))); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		if event.Type == "leave" {
+			if c != nil {
+				c.Close()
+				c = nil
+			}
+			continue
+		}
+
+		if c == nil {
+			var err error
+			if c, err = connect(protocol, addr, stats); err != nil {
+				atomic.AddInt64(&stats.failed, 1)
+				return
+			}
+			atomic.AddInt64(&stats.connected, 1)
+		}
+		if event.Type == "join" {
+			continue
+		}
+
+		msg := replayMessage(event, c.PlayerID())
+		if err := c.Send(msg); err != nil {
+			atomic.AddInt64(&stats.errors, 1)
+			continue
+		}
+		stats.countSent(msg.Type)
+	}
+}
+
+// replayMessage rebuilds the client message behind a recorded event for the
+// replaying player.
+func replayMessage(event recordedEvent, playerID uuid.UUID) gameMessage {
+	pid := playerID.String()
+	switch event.Type {
+	case "move":
+		return gameMessage{Type: "PlayerMove", Data: map[string]interface{}{
+			"player_id": pid, "x": event.Data.Data["x"], "y": event.Data.Data["y"],
+		}}
+	case "chat":
+		text, _ := event.Data.Data["message"].(string)
+		if text == "" {
+			// Players who opted out of chat logging leave no content
+			text = "replayed chat"
+		}
+		return gameMessage{Type: "Chat", Data: map[string]interface{}{"player_id": pid, "message": text}}
+	default:
+		return gameMessage{Type: "PlayerAction", Data: map[string]interface{}{"player_id": pid, "action": event.Type}}
+	}
+}
+
+func report(stats *loadStats, elapsed time.Duration) {
+	fmt.Printf("Finished in %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("  connections  %d (%d failed)\n", stats.connected, stats.failed)
+	fmt.Printf("  sent         %d (%.1f/s, %d errors)\n", stats.sent, float64(stats.sent)/elapsed.Seconds(), stats.errors)
+	fmt.Printf("  received     %d (%.1f/s)\n", stats.received, float64(stats.received)/elapsed.Seconds())
+
+	types := make([]string, 0, len(stats.byType))
+	for messageType := range stats.byType {
+		types = append(types, messageType)
+	}
+	sort.Strings(types)
+	for _, messageType := range types {
+		fmt.Printf("  %-20s %d\n", messageType, stats.byType[messageType])
+	}
+}
+
+func connect(protocol, addr string, stats *loadStats) (client, error) {
+	switch protocol {
+	case "udp":
+		return connectUDP(addr, stats)
+	case "tcp":
+		return connectTCP(addr, stats)
+	default:
+		return connectWebSocket(addr, stats)
+	}
+}
+
+const connectTimeout = 5 * time.Second
+
+// streamClient is a WebSocket or TCP client; the server assigns the player
+// ID and announces it in the first PlayerJoin.
+type streamClient struct {
+	playerID uuid.UUID
+	send     func(msg gameMessage) error
+	close    func() error
+	mu       sync.Mutex
+}
+
+func (c *streamClient) PlayerID() uuid.UUID { return c.playerID }
+
+func (c *streamClient) Send(msg gameMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.send(msg)
+}
+
+func (c *streamClient) Close() error { return c.close() }
+
+// awaitJoin reads messages until PlayerJoin and then drains the rest in the
+// background, counting them.
+func awaitJoin(receive func() (gameMessage, error), stats *loadStats) (uuid.UUID, error) {
+	for {
+		msg, err := receive()
+		if err != nil {
+			return uuid.Nil, err
+		}
+		atomic.AddInt64(&stats.received, 1)
+		if msg.Type != "PlayerJoin" {
+			continue
+		}
+
+		playerID, err := uuid.Parse(fmt.Sprint(msg.Data["player_id"]))
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("invalid player_id in PlayerJoin: %v", msg.Data["player_id"])
+		}
+		go func() {
+			for {
+				if _, err := receive(); err != nil {
+					return
+				}
+				atomic.AddInt64(&stats.received, 1)
+			}
+		}()
+		return playerID, nil
+	}
+}
+
+func connectWebSocket(addr string, stats *loadStats) (client, error) {
+	u := url.URL{Scheme: "ws", Host: addr, Path: "/"}
+	dialer := websocket.Dialer{HandshakeTimeout: connectTimeout}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	receive := func() (gameMessage, error) {
+		var msg gameMessage
+		err := conn.ReadJSON(&msg)
+		return msg, err
+	}
+	playerID, err := awaitJoin(receive, stats)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &streamClient{
+		playerID: playerID,
+		send:     func(msg gameMessage) error { return conn.WriteJSON(msg) },
+		close:    conn.Close,
+	}, nil
+}
+
+// connectTCP speaks JSON frames prefixed with a 4-byte big-endian length.
+func connectTCP(addr string, stats *loadStats) (client, error) {
+	conn, err := net.DialTimeout("tcp", addr, connectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	receive := func() (gameMessage, error) {
+		var header [4]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return gameMessage{}, err
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return gameMessage{}, err
+		}
+		var msg gameMessage
+		err := json.Unmarshal(frame, &msg)
+		return msg, err
+	}
+	playerID, err := awaitJoin(receive, stats)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	send := func(msg gameMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		frame := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(frame, uint32(len(data)))
+		copy(frame[4:], data)
+		_, err = conn.Write(frame)
+		return err
+	}
+
+	return &streamClient{playerID: playerID, send: send, close: conn.Close}, nil
+}
+
+// udpClient picks its own player ID, registers with a heartbeat and keeps
+// sending heartbeats so that the server does not time it out.
+type udpClient struct {
+	conn     *net.UDPConn
+	playerID uuid.UUID
+	sequence uint32
+	done     chan struct{}
+	once     sync.Once
+}
+
+func connectUDP(addr string, stats *loadStats) (client, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &udpClient{conn: conn, playerID: uuid.New(), done: make(chan struct{})}
+	if err := c.heartbeat(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go c.receive(stats)
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.heartbeat()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+func (c *udpClient) PlayerID() uuid.UUID { return c.playerID }
+
+func (c *udpClient) heartbeat() error {
+	sequence := atomic.AddUint32(&c.sequence, 1)
+	return c.write(udpPacket{
+		Sequence:  sequence,
+		Timestamp: time.Now().UnixMilli(),
+		Message:   gameMessage{Type: "Heartbeat", Data: map[string]interface{}{"player_id": c.playerID.String(), "sequence": sequence}},
+	})
+}
+
+func (c *udpClient) Send(msg gameMessage) error {
+	return c.write(udpPacket{
+		Sequence:  atomic.AddUint32(&c.sequence, 1),
+		Timestamp: time.Now().UnixMilli(),
+		Message:   msg,
+		Reliable:  true,
+	})
+}
+
+func (c *udpClient) write(packet udpPacket) error {
+	data, err := json.Marshal(packet)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// receive counts incoming packets and acknowledges reliable ones so that the
+// server does not keep retransmitting them.
+func (c *udpClient) receive(stats *loadStats) {
+	buf := make([]byte, 65535)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(&stats.received, 1)
+
+		var packet udpPacket
+		if err := json.Unmarshal(buf[:n], &packet); err != nil || !packet.Reliable {
+			continue
+		}
+		c.write(udpPacket{
+			Timestamp: time.Now().UnixMilli(),
+			Message:   gameMessage{Type: "Ack", Data: map[string]interface{}{"sequence": packet.Sequence}},
+		})
+	}
+}
+
+func (c *udpClient) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,423 @@
+// Command smoketest performs a join/move/chat/action round trip against a
+// running game server and exits non-zero if any step fails.
+//
+//	go run ./cmd/smoketest -protocol websocket -addr localhost:8080
+//	PROTOCOL=udp PORT=8081 go run ./cmd/smoketest
+//	go run ./cmd/smoketest -protocol tcp -addr localhost:8082
+//	go run ./cmd/smoketest -protocol udp -encoding msgpack
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type gameMessage struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type udpPacket struct {
+	Sequence  uint32      `json:"sequence"`
+	Timestamp int64       `json:"timestamp"`
+	Message   gameMessage `json:"message"`
+	Reliable  bool        `json:"reliable"`
+}
+
+// codec is the wire encoding of WebSocket and UDP messages.
+type codec struct {
+	msgpack bool
+}
+
+func (c codec) marshal(v interface{}) ([]byte, error) {
+	if !c.msgpack {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	err := encoder.Encode(v)
+	return buf.Bytes(), err
+}
+
+func (c codec) unmarshal(data []byte, v interface{}) error {
+	if c.msgpack {
+		// Decoded through JSON so that numbers compare as float64 either way
+		var generic interface{}
+		if err := msgpack.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		var err error
+		if data, err = json.Marshal(generic); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// transport abstracts the wire protocols so the scenario is shared.
+type transport interface {
+	Send(msg gameMessage) error
+	Receive(deadline time.Time) (gameMessage, error)
+	Close() error
+}
+
+func main() {
+	protocol := flag.String("protocol", getEnv("PROTOCOL", "websocket"), "websocket, udp or tcp")
+	addr := flag.String("addr", "localhost:"+getEnv("PORT", "8080"), "server host:port")
+	encoding := flag.String("encoding", "json", "json or msgpack (websocket and udp only)")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout per step")
+	flag.Parse()
+
+	if err := run(*protocol, *addr, *encoding, *timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("PASS: all smoke test steps succeeded")
+}
+
+func run(protocol, addr, encoding string, timeout time.Duration) error {
+	var t transport
+	var playerID uuid.UUID
+	var err error
+
+	var c codec
+	switch encoding {
+	case "json":
+	case "msgpack":
+		if protocol == "tcp" {
+			return fmt.Errorf("the tcp protocol only supports json")
+		}
+		c.msgpack = true
+	default:
+		return fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	switch protocol {
+	case "websocket":
+		t, playerID, err = connectWebSocket(addr, c, timeout)
+	case "udp":
+		t, playerID, err = connectUDP(addr, c, timeout)
+	case "tcp":
+		t, playerID, err = connectTCP(addr, timeout)
+	default:
+		return fmt.Errorf("unknown protocol %q", protocol)
+	}
+	if err != nil {
+		return fmt.Errorf("join: %w", err)
+	}
+	defer t.Close()
+	step("join", "player %s", playerID)
+
+	pid := playerID.String()
+
+	if err := t.Send(gameMessage{Type: "PlayerMove", Data: map[string]interface{}{"player_id": pid, "x": 12.5, "y": -3.0}}); err != nil {
+		return fmt.Errorf("move: %w", err)
+	}
+	if protocol != "udp" {
+		// The sender sees its own position in the follow-up GameState broadcast
+		if _, err := waitFor(t, timeout, func(m gameMessage) bool {
+			return m.Type == "GameState" && hasPlayerAt(m, pid, 12.5, -3.0)
+		}); err != nil {
+			return fmt.Errorf("move: %w", err)
+		}
+	} else if err := waitForAck(t, timeout); err != nil {
+		return fmt.Errorf("move: %w", err)
+	}
+	step("move", "position acknowledged")
+
+	chatText := "smoketest " + uuid.NewString()[:8]
+	if err := t.Send(gameMessage{Type: "Chat", Data: map[string]interface{}{"player_id": pid, "message": chatText}}); err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+	if protocol != "udp" {
+		if _, err := waitFor(t, timeout, func(m gameMessage) bool {
+			return m.Type == "Chat" && m.Data["message"] == chatText
+		}); err != nil {
+			return fmt.Errorf("chat: %w", err)
+		}
+	} else if err := waitForAck(t, timeout); err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+	step("chat", "message delivered")
+
+	if err := t.Send(gameMessage{Type: "PlayerAction", Data: map[string]interface{}{"player_id": pid, "action": "pickup"}}); err != nil {
+		return fmt.Errorf("action: %w", err)
+	}
+	if protocol == "udp" {
+		// UDP packets are handled concurrently, so wait until the pickup is applied
+		if err := waitForAck(t, timeout); err != nil {
+			return fmt.Errorf("action: %w", err)
+		}
+	}
+	if err := t.Send(gameMessage{Type: "PlayerStatsRequest", Data: map[string]interface{}{"player_id": pid}}); err != nil {
+		return fmt.Errorf("action: %w", err)
+	}
+	stats, err := waitFor(t, timeout, func(m gameMessage) bool {
+		return m.Type == "PlayerStats"
+	})
+	if err != nil {
+		return fmt.Errorf("action: %w", err)
+	}
+	if items, _ := stats.Data["items_collected"].(float64); items < 1 {
+		return fmt.Errorf("action: expected items_collected >= 1 after pickup, got %v", stats.Data["items_collected"])
+	}
+	step("action", "pickup recorded in player stats")
+
+	return nil
+}
+
+func step(name, format string, args ...interface{}) {
+	fmt.Printf("ok   %-7s %s\n", name, fmt.Sprintf(format, args...))
+}
+
+func waitFor(t transport, timeout time.Duration, match func(gameMessage) bool) (gameMessage, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := t.Receive(deadline)
+		if err != nil {
+			return gameMessage{}, err
+		}
+		if msg.Type == "Error" {
+			return msg, fmt.Errorf("server error: %v", msg.Data["message"])
+		}
+		if match(msg) {
+			return msg, nil
+		}
+	}
+}
+
+func waitForAck(t transport, timeout time.Duration) error {
+	_, err := waitFor(t, timeout, func(m gameMessage) bool { return m.Type == "Ack" })
+	return err
+}
+
+func hasPlayerAt(m gameMessage, playerID string, x, y float64) bool {
+	players, _ := m.Data["players"].([]interface{})
+	for _, p := range players {
+		player, _ := p.(map[string]interface{})
+		if player["id"] == playerID && player["x"] == x && player["y"] == y {
+			return true
+		}
+	}
+	return false
+}
+
+type wsTransport struct {
+	conn  *websocket.Conn
+	codec codec
+}
+
+func connectWebSocket(addr string, c codec, timeout time.Duration) (transport, uuid.UUID, error) {
+	u := url.URL{Scheme: "ws", Host: addr, Path: "/"}
+	if c.msgpack {
+		u.RawQuery = "encoding=msgpack"
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	t := &wsTransport{conn: conn, codec: c}
+
+	// The server assigns the player ID and announces it in the first PlayerJoin
+	join, err := waitFor(t, timeout, func(m gameMessage) bool { return m.Type == "PlayerJoin" })
+	if err != nil {
+		conn.Close()
+		return nil, uuid.Nil, err
+	}
+	playerIDStr, _ := join.Data["player_id"].(string)
+	playerID, err := uuid.Parse(playerIDStr)
+	if err != nil {
+		conn.Close()
+		return nil, uuid.Nil, fmt.Errorf("invalid player_id in PlayerJoin: %q", playerIDStr)
+	}
+
+	return t, playerID, nil
+}
+
+func (t *wsTransport) Send(msg gameMessage) error {
+	data, err := t.codec.marshal(msg)
+	if err != nil {
+		return err
+	}
+	if t.codec.msgpack {
+		return t.conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t *wsTransport) Receive(deadline time.Time) (gameMessage, error) {
+	t.conn.SetReadDeadline(deadline)
+	_, data, err := t.conn.ReadMessage()
+	if err != nil {
+		return gameMessage{}, err
+	}
+	var msg gameMessage
+	err = t.codec.unmarshal(data, &msg)
+	return msg, err
+}
+
+func (t *wsTransport) Close() error {
+	return t.conn.Close()
+}
+
+// tcpTransport frames each JSON message with a 4-byte big-endian length.
+type tcpTransport struct {
+	conn net.Conn
+}
+
+func connectTCP(addr string, timeout time.Duration) (transport, uuid.UUID, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	t := &tcpTransport{conn: conn}
+
+	// As with WebSocket, the server announces the assigned ID in PlayerJoin
+	join, err := waitFor(t, timeout, func(m gameMessage) bool { return m.Type == "PlayerJoin" })
+	if err != nil {
+		conn.Close()
+		return nil, uuid.Nil, err
+	}
+	playerIDStr, _ := join.Data["player_id"].(string)
+	playerID, err := uuid.Parse(playerIDStr)
+	if err != nil {
+		conn.Close()
+		return nil, uuid.Nil, fmt.Errorf("invalid player_id in PlayerJoin: %q", playerIDStr)
+	}
+
+	return t, playerID, nil
+}
+
+func (t *tcpTransport) Send(msg gameMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = t.conn.Write(frame)
+	return err
+}
+
+func (t *tcpTransport) Receive(deadline time.Time) (gameMessage, error) {
+	t.conn.SetReadDeadline(deadline)
+
+	var header [4]byte
+	if _, err := io.ReadFull(t.conn, header[:]); err != nil {
+		return gameMessage{}, err
+	}
+	frame := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(t.conn, frame); err != nil {
+		return gameMessage{}, err
+	}
+
+	var msg gameMessage
+	err := json.Unmarshal(frame, &msg)
+	return msg, err
+}
+
+func (t *tcpTransport) Close() error {
+	return t.conn.Close()
+}
+
+type udpTransport struct {
+	conn     *net.UDPConn
+	codec    codec
+	sequence uint32
+}
+
+func connectUDP(addr string, c codec, timeout time.Duration) (transport, uuid.UUID, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	t := &udpTransport{conn: conn, codec: c}
+	playerID := uuid.New()
+
+	// The first heartbeat registers the client
+	if err := t.Send(gameMessage{Type: "Heartbeat", Data: map[string]interface{}{"player_id": playerID.String(), "sequence": 1}}); err != nil {
+		conn.Close()
+		return nil, uuid.Nil, err
+	}
+	if err := waitForAck(t, timeout); err != nil {
+		conn.Close()
+		return nil, uuid.Nil, err
+	}
+
+	return t, playerID, nil
+}
+
+func (t *udpTransport) Send(msg gameMessage) error {
+	t.sequence++
+	data, err := t.codec.marshal(udpPacket{
+		Sequence:  t.sequence,
+		Timestamp: time.Now().UnixMilli(),
+		Message:   msg,
+		Reliable:  true,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = t.conn.Write(data)
+	return err
+}
+
+// Receive returns the next message, acknowledging reliable packets so the
+// server does not keep retransmitting them.
+func (t *udpTransport) Receive(deadline time.Time) (gameMessage, error) {
+	buf := make([]byte, 65535)
+	for {
+		t.conn.SetReadDeadline(deadline)
+		n, err := t.conn.Read(buf)
+		if err != nil {
+			return gameMessage{}, err
+		}
+
+		var packet udpPacket
+		if err := t.codec.unmarshal(buf[:n], &packet); err != nil {
+			continue
+		}
+
+		if packet.Reliable {
+			ack, _ := t.codec.marshal(udpPacket{
+				Timestamp: time.Now().UnixMilli(),
+				Message:   gameMessage{Type: "Ack", Data: map[string]interface{}{"sequence": packet.Sequence}},
+			})
+			t.conn.Write(ack)
+		}
+		return packet.Message, nil
+	}
+}
+
+func (t *udpTransport) Close() error {
+	return t.conn.Close()
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
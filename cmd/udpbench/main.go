@@ -0,0 +1,53 @@
+// Command udpbench measures how fast the UDP server's read loop drains a
+// loopback socket, once per UDP_READ_BATCH size given, and prints the rate
+// each handled datagrams at, to compare batched reads with reading one
+// datagram per system call. Datagrams are sent in bursts that the socket
+// buffer holds until the reader starts, so lost ones mean the host caps
+// the buffer below -burst datagrams:
+//
+//	go run ./cmd/udpbench
+//	go run ./cmd/udpbench -batch 1,64 -packets 1000000 -burst 4096
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/transport/udp"
+)
+
+func main() {
+	batches := flag.String("batch", "1,8,32,128", "comma-separated read batch sizes to compare")
+	packets := flag.Int("packets", 200000, "datagrams sent per run")
+	burst := flag.Int("burst", 2048, "datagrams sent before each read")
+	size := flag.Int("size", 64, "bytes per datagram")
+	flag.Parse()
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	fmt.Printf("%-6s %10s %10s %8s %12s\n", "batch", "received", "lost", "loss", "datagrams/s")
+	for _, field := range strings.Split(*batches, ",") {
+		batch, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || batch < 1 {
+			fmt.Fprintf(os.Stderr, "invalid batch size %q\n", field)
+			os.Exit(2)
+		}
+
+		result, err := udp.MeasureUDPReads(batch, *packets, *burst, *size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "batch %d: %v\n", batch, err)
+			os.Exit(1)
+		}
+		lost := result.Sent - result.Received
+		rate := 0.0
+		if result.Elapsed > 0 {
+			rate = float64(result.Received) / result.Elapsed.Seconds()
+		}
+		fmt.Printf("%-6d %10d %10d %7.1f%% %12.0f\n", batch, result.Received, lost, 100*float64(lost)/float64(result.Sent), rate)
+	}
+}
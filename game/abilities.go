@@ -0,0 +1,192 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const errorCodeInvalidAbility = "invalid_ability"
+
+// Effects of abilities.
+const (
+	AbilityDamage = "damage" // hurts the target player by Amount
+	AbilityHeal   = "heal"   // restores Amount health to the user
+	AbilityBlast  = "blast"  // pushes everyone within Radius of a point away at Amount units per second
+)
+
+// Ability is something players use with UseAbility, limited by a cooldown
+// the server keeps per player.
+type Ability struct {
+	Name            string  `json:"name"`
+	CooldownSeconds float64 `json:"cooldown_seconds"`
+	Cost            float32 `json:"cost,omitempty"`   // health the user spends, which may not kill them
+	Range           float32 `json:"range,omitempty"`  // how far from the user the target may be, 0 for any distance
+	Effect          string  `json:"effect"`           // damage, heal or blast
+	Amount          float32 `json:"amount"`           // damage dealt, health restored or blast speed
+	Radius          float32 `json:"radius,omitempty"` // of a blast
+}
+
+func (a Ability) cooldown() time.Duration {
+	return time.Duration(a.CooldownSeconds * float64(time.Second))
+}
+
+func (a Ability) validate() error {
+	switch {
+	case a.Name == "" || len(a.Name) > maxZoneNameLength:
+		return fmt.Errorf("ability name %q must be 1 to %d characters", a.Name, maxZoneNameLength)
+	case a.CooldownSeconds < 0:
+		return fmt.Errorf("cooldown_seconds of ability %s must not be negative, got %g", a.Name, a.CooldownSeconds)
+	case a.Cost < 0 || a.Cost >= MaxHealth:
+		return fmt.Errorf("cost of ability %s must be at least 0 and below %g, got %g", a.Name, float32(MaxHealth), a.Cost)
+	case a.Range < 0:
+		return fmt.Errorf("range of ability %s must not be negative, got %g", a.Name, a.Range)
+	case a.Amount <= 0:
+		return fmt.Errorf("amount of ability %s must be positive, got %g", a.Name, a.Amount)
+	}
+	switch a.Effect {
+	case AbilityDamage, AbilityHeal:
+	case AbilityBlast:
+		if a.Radius <= 0 {
+			return fmt.Errorf("radius of blast ability %s must be positive, got %g", a.Name, a.Radius)
+		}
+	default:
+		return fmt.Errorf("effect of ability %s must be %s, %s or %s, got %q", a.Name, AbilityDamage, AbilityHeal, AbilityBlast, a.Effect)
+	}
+	return nil
+}
+
+func defaultAbilities() []Ability {
+	return []Ability{
+		{Name: "strike", CooldownSeconds: 3, Range: 100, Effect: AbilityDamage, Amount: 25},
+		{Name: "mend", CooldownSeconds: 10, Effect: AbilityHeal, Amount: 30},
+		{Name: "shockwave", CooldownSeconds: 8, Cost: 5, Range: 300, Effect: AbilityBlast, Amount: 400, Radius: 150},
+	}
+}
+
+// LoadAbilities reads and validates a JSON list of abilities. An empty path
+// yields the default abilities.
+func LoadAbilities(path string) ([]Ability, error) {
+	if path == "" {
+		return defaultAbilities(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read abilities file: %w", err)
+	}
+	var abilities []Ability
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&abilities); err != nil {
+		return nil, fmt.Errorf("failed to parse abilities file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(abilities))
+	for _, ability := range abilities {
+		if err := ability.validate(); err != nil {
+			return nil, err
+		}
+		if seen[ability.Name] {
+			return nil, fmt.Errorf("ability %s is listed twice", ability.Name)
+		}
+		seen[ability.Name] = true
+	}
+	return abilities, nil
+}
+
+// Abilities are the abilities of a server.
+type Abilities struct {
+	abilities []Ability
+}
+
+// NewAbilities loads ABILITIES_FILE, which the startup checks have
+// validated.
+func NewAbilities(config *Config) *Abilities {
+	abilities, err := LoadAbilities(config.AbilitiesFile)
+	if err != nil {
+		logrus.Errorf("Failed to load abilities, using the defaults: %v", err)
+		abilities = defaultAbilities()
+	}
+	return &Abilities{abilities: abilities}
+}
+
+func (ab *Abilities) Lookup(name string) (Ability, bool) {
+	for _, ability := range ab.abilities {
+		if ability.Name == name {
+			return ability, true
+		}
+	}
+	return Ability{}, false
+}
+
+// Check validates a use of an ability by a user, both as seen at the time
+// of use, and returns the ability with the point it is aimed at. target
+// looks up the target player as seen at the same time.
+func (ab *Abilities) Check(user Player, data UseAbilityData, target func(playerID uuid.UUID) (Player, bool)) (Ability, float32, float32, error) {
+	ability, ok := ab.Lookup(data.Ability)
+	if !ok {
+		return Ability{}, 0, 0, &InputError{Code: errorCodeInvalidAbility, Message: fmt.Sprintf("there is no ability named %q", data.Ability)}
+	}
+
+	x, y := user.X, user.Y
+	switch ability.Effect {
+	case AbilityDamage:
+		if data.TargetID == nil || *data.TargetID == user.ID {
+			return Ability{}, 0, 0, &InputError{Code: errorCodeInvalidAbility, Message: ability.Name + " needs another player as its target"}
+		}
+		targetPlayer, exists := target(*data.TargetID)
+		if !exists || targetPlayer.Zone != user.Zone || targetPlayer.Health <= 0 {
+			return Ability{}, 0, 0, &InputError{Code: errorCodeInvalidAbility, Message: "the target is not a living player in your zone"}
+		}
+		x, y = targetPlayer.X, targetPlayer.Y
+	case AbilityBlast:
+		if data.X != nil && data.Y != nil {
+			x, y = *data.X, *data.Y
+		}
+	}
+
+	if ability.Range > 0 && !withinRadius(user, x, y, ability.Range) {
+		return Ability{}, 0, 0, &InputError{Code: errorCodeInvalidAbility, Message: fmt.Sprintf("the target of %s is out of range", ability.Name)}
+	}
+	return ability, x, y, nil
+}
+
+// Cooldowns returns when each ability is ready again for a player.
+func (ab *Abilities) Cooldowns(p Player) []AbilityCooldown {
+	cooldowns := make([]AbilityCooldown, 0, len(ab.abilities))
+	for _, ability := range ab.abilities {
+		cooldown := AbilityCooldown{Ability: ability.Name, CooldownSeconds: ability.CooldownSeconds}
+		if readyAt, cooling := p.cooldowns[ability.Name]; cooling {
+			cooldown.ReadyAt = readyAt.UnixMilli()
+		}
+		cooldowns = append(cooldowns, cooldown)
+	}
+	return cooldowns
+}
+
+// Spend starts the cooldown of an ability and takes its cost from the
+// player's health. It fails if the ability is still cooling down or the
+// cost would kill the player.
+func (p *Player) Spend(ability Ability, now time.Time) error {
+	if readyAt, cooling := p.cooldowns[ability.Name]; cooling && now.Before(readyAt) {
+		seconds := math.Ceil(readyAt.Sub(now).Seconds())
+		return &InputError{Code: errorCodeInvalidAbility, Message: fmt.Sprintf("%s is ready again in %g seconds", ability.Name, seconds)}
+	}
+	if p.Health <= ability.Cost {
+		return &InputError{Code: errorCodeInvalidAbility, Message: fmt.Sprintf("you do not have the %g health %s costs", ability.Cost, ability.Name)}
+	}
+
+	if p.cooldowns == nil {
+		p.cooldowns = make(map[string]time.Time)
+	}
+	p.cooldowns[ability.Name] = now.Add(ability.cooldown())
+	p.Health -= ability.Cost
+	return nil
+}
@@ -0,0 +1,232 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"online-server-go/store"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+var (
+	errInvalidAccount     = errors.New("invalid account")
+	errInvalidCredentials = errors.New("invalid username or password")
+	errInvalidToken       = errors.New("invalid or expired token")
+	errAuthRequired       = errors.New("an account token is required to play")
+	ErrAccountOnline      = errors.New("this account is already playing")
+	errAccountMismatch    = errors.New("player_id does not match the account token")
+	errAccountPlayer      = errors.New("this player belongs to an account; connect with its token")
+	ErrPlayerOnline       = errors.New("this player is already connected from another address")
+)
+
+// AuthSession is a logged-in account and the token that authenticates it.
+type AuthSession struct {
+	store.Account
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Accounts registers users and logs them in with a password or an OAuth
+// provider. Each account owns one player ID, so a logged-in user keeps their
+// name, score and progression across connections. Tokens are stored hashed.
+type Accounts struct {
+	database  store.Store
+	tokenTTL  time.Duration
+	required  bool
+	providers map[string]OAuthProvider
+
+	mu     sync.Mutex
+	states map[string]oauthState // pending OAuth logins by state parameter
+}
+
+func NewAccounts(database store.Store, config *Config) (*Accounts, error) {
+	providers, err := LoadOAuthProviders(config.OAuthProvidersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := &Accounts{
+		database:  database,
+		tokenTTL:  config.AuthTokenTTL,
+		required:  config.AuthRequired,
+		providers: make(map[string]OAuthProvider, len(providers)),
+		states:    make(map[string]oauthState),
+	}
+	for _, provider := range providers {
+		accounts.providers[provider.Name] = provider
+	}
+	return accounts, nil
+}
+
+// Required reports whether connections must present an account token.
+func (a *Accounts) Required() bool {
+	return a != nil && a.required
+}
+
+// Register creates a password account and logs it in.
+func (a *Accounts) Register(ctx context.Context, username, password string) (*AuthSession, error) {
+	if err := ValidatePlayerName(username); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidAccount, err)
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, fmt.Errorf("%w: password must be %d to %d bytes", errInvalidAccount, minPasswordLength, maxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	account := &store.Account{ID: uuid.New(), Username: username, PasswordHash: string(hash)}
+	if err := a.database.CreateAccount(ctx, account, nil); err != nil {
+		return nil, storeInputError(err)
+	}
+	metrics.Inc("accounts_registered")
+	return a.issueToken(ctx, account)
+}
+
+// Login checks a password and issues a new token.
+func (a *Accounts) Login(ctx context.Context, username, password string) (*AuthSession, error) {
+	account, err := a.database.GetAccountByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.PasswordHash == "" {
+		metrics.Inc("auth_login_failures")
+		return nil, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)); err != nil {
+		metrics.Inc("auth_login_failures")
+		return nil, errInvalidCredentials
+	}
+	return a.issueToken(ctx, account)
+}
+
+// Authenticate returns the account a token belongs to.
+func (a *Accounts) Authenticate(ctx context.Context, token string) (*store.Account, error) {
+	if token == "" {
+		return nil, errInvalidToken
+	}
+	account, err := a.database.GetAccountByToken(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, errInvalidToken
+	}
+	return account, nil
+}
+
+// Logout revokes a token.
+func (a *Accounts) Logout(ctx context.Context, token string) error {
+	return a.database.DeleteAuthToken(ctx, hashToken(token))
+}
+
+// AuthenticateConnection checks the token a game connection presented. It
+// returns nil for an anonymous connection, which is refused with
+// errAuthRequired when AUTH_REQUIRED is set.
+func (a *Accounts) AuthenticateConnection(ctx context.Context, token string) (*store.Account, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if token == "" {
+		if a.required {
+			return nil, errAuthRequired
+		}
+		return nil, nil
+	}
+	return a.Authenticate(ctx, token)
+}
+
+// AuthorizePlayerID checks a player ID a client chose itself: a logged-in
+// client may only use its account's, and an anonymous one none that belongs
+// to an account.
+func (a *Accounts) AuthorizePlayerID(ctx context.Context, account *store.Account, playerID uuid.UUID) error {
+	if a == nil {
+		return nil
+	}
+	if account != nil {
+		if playerID != account.ID {
+			return errAccountMismatch
+		}
+		return nil
+	}
+
+	owner, err := a.database.GetAccount(ctx, playerID)
+	if err != nil {
+		return err
+	}
+	if owner != nil {
+		return errAccountPlayer
+	}
+	return nil
+}
+
+// AuthErrorMessage is what a connection refused by AuthenticateConnection is
+// told. Database errors are logged rather than shown.
+func AuthErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, errInvalidToken), errors.Is(err, errAuthRequired), errors.Is(err, ErrAccountOnline),
+		errors.Is(err, errAccountMismatch), errors.Is(err, errAccountPlayer), errors.Is(err, ErrPlayerOnline):
+		return err.Error()
+	}
+	logrus.Errorf("Failed to authenticate connection: %v", err)
+	return "failed to authenticate"
+}
+
+// LoadPlayer gives a connecting player its account's name and stored score.
+func (a *Accounts) LoadPlayer(ctx context.Context, account *store.Account, player *Player) {
+	player.Name = account.Username
+
+	stored, err := a.database.GetPlayer(ctx, account.ID)
+	if err != nil {
+		logrus.Errorf("Failed to load player for account %s: %v", account.Username, err)
+		return
+	}
+	if stored != nil && stored.Score > int64(player.Score) {
+		player.Score = uint32(stored.Score)
+	}
+}
+
+func (a *Accounts) issueToken(ctx context.Context, account *store.Account) (*AuthSession, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(a.tokenTTL).UTC().Truncate(time.Second)
+	if err := a.database.CreateAuthToken(ctx, hashToken(token), account.ID, expiresAt); err != nil {
+		return nil, err
+	}
+	metrics.Inc("auth_tokens_issued")
+	return &AuthSession{Account: *account, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken is what the database stores, so that a leaked table does not
+// log anyone in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,588 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// GameAdmin is implemented by both transports for operator tooling.
+type GameAdmin interface {
+	OnlinePlayer(playerID uuid.UUID) (Player, bool)
+	OnlinePlayers() []Player
+	Kick(playerID uuid.UUID, reason, message string) bool
+	BroadcastAll(message *GameMessage)
+	GetClientCount() int
+	PauseRoom(roomID string, paused bool) error
+	Rooms() []RoomInfo
+	Maintenance() *Maintenance
+	Entities() *EntityRegistry
+	AntiCheat() *AntiCheat
+	Persistence() *PersistQueue
+	Bandwidth() []PlayerBandwidth
+	Explode(zone string, x, y, radius, speed float32) int
+}
+
+// AdminHandler serves operator endpoints under /admin/. Every request must
+// carry the configured ADMIN_TOKEN, or the token of an account whose role
+// grants the endpoint's permission.
+type AdminHandler struct {
+	staff    *StaffAuthorizer
+	database store.Store
+	events   *WorldEvents
+	rules    *Rules
+	game     GameAdmin
+}
+
+func NewAdminHandler(staff *StaffAuthorizer, database store.Store, events *WorldEvents, rules *Rules, game GameAdmin) *AdminHandler {
+	return &AdminHandler{staff: staff, database: database, events: events, rules: rules, game: game}
+}
+
+func (admin *AdminHandler) Register(mux *http.ServeMux) {
+	if !admin.staff.Enabled() {
+		logrus.Warn("ADMIN_TOKEN is not set; admin API is disabled")
+		return
+	}
+	if admin.staff.adminToken == "" {
+		logrus.Warn("ADMIN_TOKEN is not set; admin API only accepts staff accounts")
+	}
+
+	mux.HandleFunc("/admin/traces", admin.authorize(PermManageServer, admin.handleListTraces))
+	mux.HandleFunc("/admin/trace", admin.authorize(PermManageServer, admin.handleGetTrace))
+	mux.HandleFunc("/admin/trace/start", admin.authorize(PermManageServer, admin.handleStartTrace))
+	mux.HandleFunc("/admin/trace/stop", admin.authorize(PermManageServer, admin.handleStopTrace))
+	mux.HandleFunc("/admin/events", admin.authorize(PermViewServer, admin.handleListEvents))
+	mux.HandleFunc("/admin/events/start", admin.authorize(PermManageServer, admin.handleStartEvent))
+	mux.HandleFunc("/admin/events/stop", admin.authorize(PermManageServer, admin.handleStopEvent))
+	mux.HandleFunc("/admin/rules", admin.authorize(PermViewServer, admin.handleGetRules))
+	mux.HandleFunc("/admin/rules/reload", admin.authorize(PermManageServer, admin.handleReloadRules))
+	mux.HandleFunc("/admin/players/invalidate", admin.authorize(PermManageServer, admin.handleInvalidatePlayer))
+	mux.HandleFunc("/admin/privacy", admin.authorize(PermManageServer, admin.handleGetPrivacy))
+	mux.HandleFunc("/admin/connections", admin.authorize(PermViewServer, admin.handleListConnections))
+	mux.HandleFunc("/admin/bandwidth", admin.authorize(PermViewServer, admin.handleListBandwidth))
+	mux.HandleFunc("/admin/metrics", admin.authorize(PermViewServer, admin.handleMetrics))
+	mux.HandleFunc("/admin/suspects", admin.authorize(PermViewServer, admin.handleListSuspects))
+	mux.HandleFunc("/admin/rooms", admin.authorize(PermViewServer, admin.handleListRooms))
+	mux.HandleFunc("/admin/rooms/pause", admin.authorize(PermManageServer, admin.handlePauseRoom(true)))
+	mux.HandleFunc("/admin/rooms/resume", admin.authorize(PermManageServer, admin.handlePauseRoom(false)))
+	mux.HandleFunc("/admin/maintenance", admin.authorize(PermViewServer, admin.handleGetMaintenance))
+	mux.HandleFunc("/admin/maintenance/schedule", admin.authorize(PermManageServer, admin.handleScheduleMaintenance))
+	mux.HandleFunc("/admin/maintenance/cancel", admin.authorize(PermManageServer, admin.handleCancelMaintenance))
+	mux.HandleFunc("/admin/audit", admin.authorize(PermManageServer, admin.handleListAudit))
+	mux.HandleFunc("/admin/roles", admin.authorize(PermManageRoles, admin.handleSetRole))
+	mux.HandleFunc("/admin/reports", admin.authorize(PermReports, admin.handleListReports))
+	mux.HandleFunc("/admin/reports/resolve", admin.authorize(PermReports, admin.handleResolveReport))
+}
+
+// authorize accepts "Authorization: Bearer <token>" or "X-Admin-Token: <token>"
+// holding perm.
+func (admin *AdminHandler) authorize(perm Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Admin-Token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		staff, err := admin.staff.Authorize(r.Context(), token, perm)
+		switch {
+		case errors.Is(err, ErrStaffUnauthenticated):
+			metrics.Inc("admin_unauthorized")
+			WriteJSONError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		case errors.Is(err, ErrStaffForbidden):
+			metrics.Inc("admin_forbidden")
+			WriteJSONError(w, http.StatusForbidden, err.Error())
+			return
+		case err != nil:
+			logrus.Errorf("Failed to authorize admin request: %v", err)
+			WriteJSONError(w, http.StatusInternalServerError, "failed to authorize")
+			return
+		}
+		if staff.Name == "" {
+			staff.Name = httpActor(r)
+		}
+		next(w, r.WithContext(WithStaff(r.Context(), staff)))
+	}
+}
+
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		WriteJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// traceTarget reads ?player_id= or ?room= into a tracer target key.
+func traceTarget(r *http.Request) (string, error) {
+	query := r.URL.Query()
+	if playerIDStr := query.Get("player_id"); playerIDStr != "" {
+		playerID, err := uuid.Parse(playerIDStr)
+		if err != nil {
+			return "", fmt.Errorf("player_id must be a valid UUID")
+		}
+		return PlayerTraceTarget(playerID), nil
+	}
+	if room := query.Get("room"); room != "" {
+		return RoomTraceTarget(room), nil
+	}
+	return "", fmt.Errorf("player_id or room is required")
+}
+
+func (admin *AdminHandler) handleListTraces(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	WriteJSON(w, http.StatusOK, DefaultPacketTracer.List())
+}
+
+func (admin *AdminHandler) handleStartTrace(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	target, err := traceTarget(r)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	capacity, _ := strconv.Atoi(r.URL.Query().Get("capacity"))
+	rate, _ := strconv.Atoi(r.URL.Query().Get("rate"))
+
+	trace, err := DefaultPacketTracer.Start(target, capacity, rate)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logrus.Infof("Packet trace started for %s (capacity %d, %d/s)", target, trace.Capacity, trace.Rate)
+	WriteJSON(w, http.StatusOK, trace)
+}
+
+func (admin *AdminHandler) handleStopTrace(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	target, err := traceTarget(r)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !DefaultPacketTracer.Stop(target) {
+		WriteJSONError(w, http.StatusNotFound, "no active trace for target")
+		return
+	}
+
+	logrus.Infof("Packet trace stopped for %s", target)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetTrace downloads the buffered entries of an active trace.
+func (admin *AdminHandler) handleGetTrace(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	target, err := traceTarget(r)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trace, entries, exists := DefaultPacketTracer.Snapshot(target)
+	if !exists {
+		WriteJSONError(w, http.StatusNotFound, "no active trace for target")
+		return
+	}
+
+	filename := strings.ReplaceAll(target, ":", "-") + ".json"
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	WriteJSON(w, http.StatusOK, struct {
+		*Trace
+		Entries []TraceEntry `json:"entries"`
+	}{trace, entries})
+}
+
+func (admin *AdminHandler) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	WriteJSON(w, http.StatusOK, admin.events.Active())
+}
+
+// handleStartEvent starts ?type= for ?duration=, defaulting to the event's
+// own duration. Starting a running event extends it.
+func (admin *AdminHandler) handleStartEvent(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var duration time.Duration
+	if durationStr := r.URL.Query().Get("duration"); durationStr != "" {
+		var err error
+		if duration, err = time.ParseDuration(durationStr); err != nil {
+			WriteJSONError(w, http.StatusBadRequest, "duration must be a Go duration such as 5m")
+			return
+		}
+	}
+
+	event, err := admin.events.Start(r.URL.Query().Get("type"), duration)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	admin.audit(r, auditStartEvent, r.URL.Query().Get("type"))
+	WriteJSON(w, http.StatusOK, event)
+}
+
+func (admin *AdminHandler) handleStopEvent(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if !admin.events.Stop(r.URL.Query().Get("type")) {
+		WriteJSONError(w, http.StatusNotFound, "no running world event of that type")
+		return
+	}
+	admin.audit(r, auditStopEvent, r.URL.Query().Get("type"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (admin *AdminHandler) handleGetRules(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	WriteJSON(w, http.StatusOK, admin.rules.Current())
+}
+
+// handleReloadRules rereads RULES_FILE, like SIGHUP, and returns the rules
+// in force. Invalid rules are rejected and the previous ones kept.
+func (admin *AdminHandler) handleReloadRules(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	rules, err := admin.rules.Reload()
+	if err != nil {
+		logrus.Errorf("Failed to reload game rules: %v", err)
+		WriteJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	admin.audit(r, auditReloadRules, "")
+	WriteJSON(w, http.StatusOK, rules)
+}
+
+// handleInvalidatePlayer drops ?player_id= from the player cache after its
+// row was edited directly in the database. Without player_id the whole cache
+// is dropped.
+func (admin *AdminHandler) handleInvalidatePlayer(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	playerID := uuid.Nil
+	if playerIDStr := r.URL.Query().Get("player_id"); playerIDStr != "" {
+		var err error
+		if playerID, err = uuid.Parse(playerIDStr); err != nil {
+			WriteJSONError(w, http.StatusBadRequest, "player_id must be a valid UUID")
+			return
+		}
+	}
+
+	admin.database.InvalidatePlayer(playerID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetPrivacy returns the privacy settings of ?player_id= for data
+// protection requests.
+func (admin *AdminHandler) handleGetPrivacy(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	playerID, err := uuid.Parse(r.URL.Query().Get("player_id"))
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "player_id must be a valid UUID")
+		return
+	}
+
+	settings, err := admin.database.GetPrivacySettings(r.Context(), playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load privacy settings: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load privacy settings")
+		return
+	}
+	WriteJSON(w, http.StatusOK, settings)
+}
+
+// handleListConnections returns the last measured link quality of every
+// connected UDP player.
+func (admin *AdminHandler) handleListConnections(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	connections, ok := admin.game.(ConnectionQualitySource)
+	if !ok {
+		WriteJSONError(w, http.StatusNotFound, "connection quality is only measured with PROTOCOL=udp")
+		return
+	}
+	WriteJSON(w, http.StatusOK, connections.ConnectionQualities())
+}
+
+// handleListBandwidth returns the traffic of every connected player, the
+// heaviest first.
+func (admin *AdminHandler) handleListBandwidth(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	bandwidth := admin.game.Bandwidth()
+	sort.Slice(bandwidth, func(i, j int) bool {
+		return bandwidth[i].BytesOut+bandwidth[i].BytesIn > bandwidth[j].BytesOut+bandwidth[j].BytesIn
+	})
+	WriteJSON(w, http.StatusOK, bandwidth)
+}
+
+// handleMetrics serves every metric, including the traffic per protocol, for
+// Prometheus to scrape with the admin token as its bearer token.
+func (admin *AdminHandler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := metrics.WritePrometheus(w); err != nil {
+		logrus.Debugf("Failed to write metrics: %v", err)
+	}
+}
+
+// handleListSuspects returns the players the anti-cheat flagged, the most
+// suspected first.
+func (admin *AdminHandler) handleListSuspects(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	anticheat := admin.game.AntiCheat()
+	if anticheat == nil {
+		WriteJSONError(w, http.StatusNotFound, "the anti-cheat is disabled with ANTICHEAT_FLAG_SCORE=0")
+		return
+	}
+	suspects := anticheat.Suspects()
+	if suspects == nil {
+		suspects = []SuspectInfo{}
+	}
+	WriteJSON(w, http.StatusOK, suspects)
+}
+
+func (admin *AdminHandler) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	rooms := admin.game.Rooms()
+	if rooms == nil {
+		rooms = []RoomInfo{}
+	}
+	WriteJSON(w, http.StatusOK, rooms)
+}
+
+// handlePauseRoom pauses or resumes ?room=.
+func (admin *AdminHandler) handlePauseRoom(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		roomID := r.URL.Query().Get("room")
+		switch err := admin.game.PauseRoom(roomID, paused); err {
+		case nil:
+			action := auditResumeRoom
+			if paused {
+				action = auditPauseRoom
+			}
+			admin.audit(r, action, roomID)
+			w.WriteHeader(http.StatusNoContent)
+		case errRoomNotFound:
+			WriteJSONError(w, http.StatusNotFound, err.Error())
+		default:
+			WriteJSONError(w, http.StatusConflict, err.Error())
+		}
+	}
+}
+
+func (admin *AdminHandler) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	info, scheduled := admin.game.Maintenance().Scheduled()
+	if !scheduled {
+		WriteJSONError(w, http.StatusNotFound, "no restart is scheduled")
+		return
+	}
+	WriteJSON(w, http.StatusOK, info)
+}
+
+// handleScheduleMaintenance schedules a restart ?at= a delay such as 15m or
+// an RFC 3339 time, with an optional ?message= for players. Scheduling again
+// moves the restart.
+func (admin *AdminHandler) handleScheduleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	restartAt, err := ParseRestartTime(r.URL.Query().Get("at"), time.Now())
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	maintenance := admin.game.Maintenance()
+	if err := maintenance.Schedule(restartAt, r.URL.Query().Get("message")); err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	info, _ := maintenance.Scheduled()
+	admin.audit(r, auditScheduleRestart, restartAt.UTC().Format(time.RFC3339))
+	WriteJSON(w, http.StatusOK, info)
+}
+
+func (admin *AdminHandler) handleCancelMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if !admin.game.Maintenance().Cancel() {
+		WriteJSONError(w, http.StatusNotFound, "no restart is scheduled")
+		return
+	}
+	admin.audit(r, auditCancelRestart, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// audit records an admin action taken through the HTTP API, with the
+// optional ?reason= of the request.
+func (admin *AdminHandler) audit(r *http.Request, action, target string) {
+	RecordAdminAction(r.Context(), admin.database, StaffFrom(r.Context()).Name, action, target, r.URL.Query().Get("reason"))
+}
+
+// handleListAudit returns the admin actions matching ?actor=, ?action=,
+// ?target= and ?since=, the latest first, up to ?limit=.
+func (admin *AdminHandler) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	entries, err := admin.database.GetAdminAudit(r.Context(), filter)
+	if err != nil {
+		logrus.Errorf("Failed to query admin audit: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to query the audit log")
+		return
+	}
+	if entries == nil {
+		entries = []store.AuditEntry{}
+	}
+	WriteJSON(w, http.StatusOK, entries)
+}
+
+// handleSetRole gives the account of ?player_id= the ?role=.
+func (admin *AdminHandler) handleSetRole(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	accountID, err := uuid.Parse(r.URL.Query().Get("player_id"))
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "player_id must be a valid UUID")
+		return
+	}
+	role, err := ParseRole(r.URL.Query().Get("role"))
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	found, err := admin.database.SetAccountRole(r.Context(), accountID, role)
+	if err != nil {
+		logrus.Errorf("Failed to set the role of %s: %v", accountID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to set role")
+		return
+	}
+	if !found {
+		WriteJSONError(w, http.StatusNotFound, "account not found")
+		return
+	}
+	RecordAdminAction(r.Context(), admin.database, StaffFrom(r.Context()).Name, auditSetRole, accountID.String(), role)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListReports returns the player reports matching ?player_id=,
+// ?open=true and ?escalated=true, the latest first, up to ?limit=.
+func (admin *AdminHandler) handleListReports(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reports, err := admin.database.GetReports(r.Context(), filter)
+	if err != nil {
+		logrus.Errorf("Failed to query reports: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to query reports")
+		return
+	}
+	if reports == nil {
+		reports = []store.PlayerReport{}
+	}
+	WriteJSON(w, http.StatusOK, reports)
+}
+
+// handleResolveReport closes the open report ?id= with the ?resolution=
+// taken.
+func (admin *AdminHandler) handleResolveReport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	reportID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "id must be a report ID")
+		return
+	}
+	resolution := strings.TrimSpace(r.URL.Query().Get("resolution"))
+	if resolution == "" {
+		WriteJSONError(w, http.StatusBadRequest, "resolution is required")
+		return
+	}
+
+	actor := StaffFrom(r.Context()).Name
+	resolved, err := admin.database.ResolveReport(r.Context(), reportID, actor, resolution)
+	if err != nil {
+		logrus.Errorf("Failed to resolve report %d: %v", reportID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to resolve report")
+		return
+	}
+	if !resolved {
+		WriteJSONError(w, http.StatusNotFound, "no open report with this id")
+		return
+	}
+	RecordAdminAction(r.Context(), admin.database, actor, auditResolveReport, strconv.FormatInt(reportID, 10), resolution)
+	w.WriteHeader(http.StatusNoContent)
+}
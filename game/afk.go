@@ -0,0 +1,119 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// AFKCheckInterval is how often players are checked for being idle.
+const AFKCheckInterval = time.Second
+
+// What a transport does with a player after an AFK check.
+type AFKStep int
+
+const (
+	afkNone   AFKStep = iota
+	AFKWarn           // idle for AFK_WARN_AFTER: warned and shown as AFK
+	AFKRemove         // idle for AFK_TIMEOUT: out of the match, or the server with AFK_KICK
+)
+
+// AFKPolicy decides when idle players are warned and removed. Only
+// meaningful input keeps a player active; heartbeats, acks and requests a
+// client may send on its own do not. Without matches, players idle for
+// AFK_TIMEOUT are only shown as AFK unless AFK_KICK is set.
+//
+// A nil *AFKPolicy, as with AFK_TIMEOUT set to 0, leaves idle players alone.
+type AFKPolicy struct {
+	warnAfter time.Duration
+	timeout   time.Duration
+	kick      bool
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewAFKPolicy(config *Config) *AFKPolicy {
+	if config.AFKTimeout <= 0 {
+		return nil
+	}
+	return &AFKPolicy{
+		warnAfter: config.AFKWarnAfter,
+		timeout:   config.AFKTimeout,
+		kick:      config.AFKKick,
+		next:      time.Now().Add(AFKCheckInterval),
+	}
+}
+
+// Due reports whether players are due a check at now, and if so schedules
+// the next one.
+func (a *AFKPolicy) Due(now time.Time) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Before(a.next) {
+		return false
+	}
+	a.next = now.Add(AFKCheckInterval)
+	return true
+}
+
+// Kick reports whether players idle for AFK_TIMEOUT are disconnected rather
+// than taken out of the match.
+func (a *AFKPolicy) Kick() bool {
+	return a != nil && a.kick
+}
+
+// Check marks a player AFK once they have been idle for long enough and
+// returns what the transport must do about it. A player is checked from
+// their first check on.
+func (a *AFKPolicy) Check(p *Player, now time.Time) AFKStep {
+	if a == nil {
+		return afkNone
+	}
+	if p.lastInput.IsZero() {
+		p.lastInput = now
+		return afkNone
+	}
+
+	idle := now.Sub(p.lastInput)
+	switch {
+	case idle >= a.timeout && !p.afkRemoved:
+		p.AFK = true
+		p.afkRemoved = true
+		return AFKRemove
+	case idle >= a.warnAfter && !p.AFK:
+		p.AFK = true
+		return AFKWarn
+	}
+	return afkNone
+}
+
+// RemovalAt is when a player stops being idle for too long.
+func (a *AFKPolicy) RemovalAt(p Player) time.Time {
+	return p.lastInput.Add(a.timeout)
+}
+
+// MarkActive records meaningful input from the player at now. It reports
+// whether they were AFK and whether they had been taken out of the match,
+// which they then rejoin.
+func (p *Player) MarkActive(now time.Time) (wasAFK, rejoin bool) {
+	wasAFK, rejoin = p.AFK, p.afkRemoved
+	p.lastInput = now
+	p.AFK = false
+	p.afkRemoved = false
+	return wasAFK, rejoin
+}
+
+// MeaningfulInput reports whether a message type shows that the player is
+// at the keyboard.
+func MeaningfulInput(messageType string) bool {
+	switch messageType {
+	case "PlayerMove", "PlayerAction", "UseAbility", "UseItem", "DropItem", "ChangeZone",
+		"Chat", "Whisper", "PartyChat", "GuildChat", "StartVote", "CastVote", "CreateRoom",
+		"PauseRoom", "ResumeRoom":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,440 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// What the anti-cheat does to players whose suspicion reaches
+// ANTICHEAT_ACTION_SCORE, besides flagging them to admins.
+const (
+	AntiCheatFlagOnly  = ""          // nothing more
+	AntiCheatShadowBan = "shadowban" // their attacks, pickups and abilities stop having an effect, and their chat only reaches themselves
+	AntiCheatKick      = "kick"      // they are disconnected
+)
+
+// ParseAntiCheatAction checks ANTICHEAT_ACTION.
+func ParseAntiCheatAction(action string) (string, error) {
+	switch action {
+	case AntiCheatFlagOnly, "none":
+		return AntiCheatFlagOnly, nil
+	case AntiCheatShadowBan, AntiCheatKick:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown anti-cheat action %q, expected \"none\", \"shadowban\" or \"kick\"", action)
+}
+
+const (
+	// AntiCheatSweepInterval is how often suspicion decays and the kicks
+	// the anti-cheat decided on are carried out.
+	AntiCheatSweepInterval = time.Second
+
+	// suspicionDecayPerMinute is the suspicion a player loses every minute,
+	// so that the odd false positive fades.
+	suspicionDecayPerMinute = 10
+)
+
+// Suspicion is what a detector holds against a player for one event.
+type Suspicion struct {
+	PlayerID  uuid.UUID
+	Heuristic string  // the detector's name
+	Points    float64 // added to the player's suspicion score
+	Detail    string
+}
+
+// CheatDetector is a heuristic of the anti-cheat. It watches the game
+// events and returns the suspicions an event raises. Observe is called with
+// the anti-cheat's lock held, one event at a time.
+type CheatDetector interface {
+	Observe(event GameEvent, now time.Time) []Suspicion
+	// Forget drops what the detector keeps about a player who left.
+	Forget(playerID uuid.UUID)
+}
+
+// SuspectInfo is a flagged player as listed to admins.
+type SuspectInfo struct {
+	PlayerID     uuid.UUID `json:"player_id"`
+	Score        float64   `json:"score"`
+	FlaggedAt    time.Time `json:"flagged_at"`
+	ShadowBanned bool      `json:"shadow_banned,omitempty"`
+	Reasons      []string  `json:"reasons"` // the latest suspicions, oldest first
+}
+
+// maxSuspectReasons is how many of a suspect's latest suspicions are kept.
+const maxSuspectReasons = 10
+
+type suspect struct {
+	score     float64
+	updated   time.Time
+	flaggedAt time.Time // zero until the score reached ANTICHEAT_FLAG_SCORE
+	shadowBan bool
+	reasons   []string
+}
+
+// decay lowers the score for the time since it was last updated.
+func (s *suspect) decay(now time.Time) {
+	if elapsed := now.Sub(s.updated); elapsed > 0 {
+		s.score = math.Max(0, s.score-elapsed.Minutes()*suspicionDecayPerMinute)
+	}
+	s.updated = now
+}
+
+// AntiCheat scores players on heuristics fed by the event bus. Players
+// whose suspicion reaches ANTICHEAT_FLAG_SCORE are flagged to admins on
+// /admin/suspects, and those reaching ANTICHEAT_ACTION_SCORE are shadow
+// banned or kicked as ANTICHEAT_ACTION says. Scores outlive disconnects, so
+// reconnecting does not clear them, and decay over time.
+//
+// A nil *AntiCheat, with ANTICHEAT_FLAG_SCORE set to 0, suspects nobody.
+type AntiCheat struct {
+	flagScore   float64
+	actionScore float64
+	action      string
+
+	mu        sync.Mutex
+	detectors []CheatDetector
+	suspects  map[uuid.UUID]*suspect
+	kicks     []uuid.UUID // decided on, carried out by the transport's sweep
+}
+
+// NewAntiCheat returns the anti-cheat with the built-in detectors. The
+// startup checks have validated the config.
+func NewAntiCheat(config *Config, rules *Rules, world WorldBounds) *AntiCheat {
+	if config.AntiCheatFlagScore <= 0 {
+		return nil
+	}
+	action, _ := ParseAntiCheatAction(config.AntiCheatAction)
+	ac := &AntiCheat{
+		flagScore:   config.AntiCheatFlagScore,
+		actionScore: config.AntiCheatActionScore,
+		action:      action,
+		suspects:    make(map[uuid.UUID]*suspect),
+	}
+	ac.Register(newActionRateDetector(config.AntiCheatMaxActionRate))
+	ac.Register(newTeleportDetector(rules, world))
+	ac.Register(newAccuracyDetector())
+	ac.Register(newPickupDetector())
+	return ac
+}
+
+// Register adds a detector, such as one from a plugin.
+func (ac *AntiCheat) Register(detector CheatDetector) {
+	if ac == nil {
+		return
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.detectors = append(ac.detectors, detector)
+}
+
+// HandleEvent is the anti-cheat's subscription to the event bus.
+func (ac *AntiCheat) HandleEvent(ctx context.Context, event GameEvent) {
+	now := time.Now()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	for _, detector := range ac.detectors {
+		for _, suspicion := range detector.Observe(event, now) {
+			ac.suspectLocked(suspicion, now)
+		}
+	}
+}
+
+func (ac *AntiCheat) suspectLocked(suspicion Suspicion, now time.Time) {
+	s, exists := ac.suspects[suspicion.PlayerID]
+	if !exists {
+		s = &suspect{updated: now}
+		ac.suspects[suspicion.PlayerID] = s
+	}
+	s.decay(now)
+	s.score += suspicion.Points
+	s.reasons = append(s.reasons, suspicion.Heuristic+": "+suspicion.Detail)
+	if len(s.reasons) > maxSuspectReasons {
+		s.reasons = s.reasons[len(s.reasons)-maxSuspectReasons:]
+	}
+	metrics.Inc("anticheat_suspicions_" + suspicion.Heuristic)
+
+	if s.flaggedAt.IsZero() && s.score >= ac.flagScore {
+		s.flaggedAt = now
+		metrics.Inc("anticheat_flagged")
+		logrus.Warnf("Anti-cheat flagged player %s with suspicion %.0f: %s", suspicion.PlayerID, s.score, suspicion.Detail)
+	}
+	if ac.actionScore <= 0 || s.score < ac.actionScore {
+		return
+	}
+	switch ac.action {
+	case AntiCheatShadowBan:
+		if !s.shadowBan {
+			s.shadowBan = true
+			logrus.Warnf("Anti-cheat shadow banned player %s", suspicion.PlayerID)
+		}
+	case AntiCheatKick:
+		ac.kicks = append(ac.kicks, suspicion.PlayerID)
+		// Start over, so that the player is kicked once per offence
+		s.score = 0
+	}
+}
+
+// ShadowBanned reports whether a player's actions are to be silently
+// ignored.
+func (ac *AntiCheat) ShadowBanned(playerID uuid.UUID) bool {
+	if ac == nil {
+		return false
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	s, exists := ac.suspects[playerID]
+	return exists && s.shadowBan
+}
+
+// Sweep decays every score, drops the players who are no longer suspected
+// and returns the players to kick.
+func (ac *AntiCheat) Sweep(now time.Time) []uuid.UUID {
+	if ac == nil {
+		return nil
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	for playerID, s := range ac.suspects {
+		s.decay(now)
+		if s.score == 0 && !s.shadowBan {
+			delete(ac.suspects, playerID)
+		}
+	}
+	kicks := ac.kicks
+	ac.kicks = nil
+	return kicks
+}
+
+// Suspects returns the flagged players, the most suspected first.
+func (ac *AntiCheat) Suspects() []SuspectInfo {
+	if ac == nil {
+		return nil
+	}
+	now := time.Now()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	var suspects []SuspectInfo
+	for playerID, s := range ac.suspects {
+		if s.flaggedAt.IsZero() {
+			continue
+		}
+		s.decay(now)
+		suspects = append(suspects, SuspectInfo{
+			PlayerID:     playerID,
+			Score:        s.score,
+			FlaggedAt:    s.flaggedAt,
+			ShadowBanned: s.shadowBan,
+			Reasons:      append([]string(nil), s.reasons...),
+		})
+	}
+	sort.Slice(suspects, func(i, j int) bool { return suspects[i].Score > suspects[j].Score })
+	return suspects
+}
+
+// Forget drops what the detectors keep about a player who left. Their
+// score is kept until it decays.
+func (ac *AntiCheat) Forget(playerID uuid.UUID) {
+	if ac == nil {
+		return
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	for _, detector := range ac.detectors {
+		detector.Forget(playerID)
+	}
+}
+
+// actionRateDetector suspects players who act more often in a second than
+// a person can.
+type actionRateDetector struct {
+	max     int
+	windows map[uuid.UUID]*rateWindow
+}
+
+type rateWindow struct {
+	start   time.Time
+	actions int
+}
+
+func newActionRateDetector(max int) *actionRateDetector {
+	return &actionRateDetector{max: max, windows: make(map[uuid.UUID]*rateWindow)}
+}
+
+func (d *actionRateDetector) Observe(event GameEvent, now time.Time) []Suspicion {
+	acted, ok := event.(PlayerActed)
+	if !ok || d.max <= 0 {
+		return nil
+	}
+	window, exists := d.windows[acted.PlayerID]
+	if !exists || now.Sub(window.start) >= time.Second {
+		window = &rateWindow{start: now}
+		d.windows[acted.PlayerID] = window
+	}
+	window.actions++
+	// Once per window, when the rate is first exceeded
+	if window.actions != d.max+1 {
+		return nil
+	}
+	return []Suspicion{{
+		PlayerID:  acted.PlayerID,
+		Heuristic: "action_rate",
+		Points:    20,
+		Detail:    fmt.Sprintf("more than %d actions in a second", d.max),
+	}}
+}
+
+func (d *actionRateDetector) Forget(playerID uuid.UUID) {
+	delete(d.windows, playerID)
+}
+
+const (
+	// teleportTolerance and teleportSlack allow for jitter and clock skew
+	// on top of the distance a player can cover at max_move_speed.
+	teleportTolerance = 1.5
+	teleportSlack     = 50
+)
+
+// teleportDetector suspects players whose moves cover more ground since
+// their last one than max_move_speed allows. Moves are measured from where
+// the server had the player, so respawns and pushes do not count.
+type teleportDetector struct {
+	rules    *Rules
+	world    WorldBounds
+	lastMove map[uuid.UUID]time.Time
+}
+
+func newTeleportDetector(rules *Rules, world WorldBounds) *teleportDetector {
+	return &teleportDetector{rules: rules, world: world, lastMove: make(map[uuid.UUID]time.Time)}
+}
+
+func (d *teleportDetector) Observe(event GameEvent, now time.Time) []Suspicion {
+	moved, ok := event.(PlayerMoved)
+	if !ok {
+		return nil
+	}
+	last, seen := d.lastMove[moved.PlayerID]
+	d.lastMove[moved.PlayerID] = now
+	if !seen {
+		return nil
+	}
+	// A player who stood still for a while still cannot jump
+	elapsed := math.Min(now.Sub(last).Seconds(), 1)
+	allowed := float64(d.rules.Current().MaxMoveSpeed)*elapsed*teleportTolerance + teleportSlack
+	distance := math.Sqrt(float64(d.world.DistanceSq(moved.FromX, moved.FromY, moved.X, moved.Y)))
+	if distance <= allowed {
+		return nil
+	}
+	return []Suspicion{{
+		PlayerID:  moved.PlayerID,
+		Heuristic: "teleport",
+		Points:    25,
+		Detail:    fmt.Sprintf("moved %.0f units where %.0f were possible", distance, allowed),
+	}}
+}
+
+func (d *teleportDetector) Forget(playerID uuid.UUID) {
+	delete(d.lastMove, playerID)
+}
+
+// accuracySample is how many attacks in a row must all land to be
+// suspected as aimbotting.
+const accuracySample = 30
+
+// accuracyDetector suspects players whose attacks never miss.
+type accuracyDetector struct {
+	streaks map[uuid.UUID]int
+}
+
+func newAccuracyDetector() *accuracyDetector {
+	return &accuracyDetector{streaks: make(map[uuid.UUID]int)}
+}
+
+func (d *accuracyDetector) Observe(event GameEvent, now time.Time) []Suspicion {
+	acted, ok := event.(PlayerActed)
+	if !ok || acted.Action != "attack" {
+		return nil
+	}
+	if !acted.Hit {
+		delete(d.streaks, acted.PlayerID)
+		return nil
+	}
+	d.streaks[acted.PlayerID]++
+	if d.streaks[acted.PlayerID] < accuracySample {
+		return nil
+	}
+	delete(d.streaks, acted.PlayerID)
+	return []Suspicion{{
+		PlayerID:  acted.PlayerID,
+		Heuristic: "accuracy",
+		Points:    30,
+		Detail:    fmt.Sprintf("%d attacks in a row hit", accuracySample),
+	}}
+}
+
+func (d *accuracyDetector) Forget(playerID uuid.UUID) {
+	delete(d.streaks, playerID)
+}
+
+// pickupMemory is how long a picked up entity is remembered.
+const pickupMemory = time.Minute
+
+// pickupDetector suspects players picking up an entity that was already
+// picked up, by themselves or anyone else.
+type pickupDetector struct {
+	claimed map[uuid.UUID]time.Time // entity ID to when it was picked up
+}
+
+func newPickupDetector() *pickupDetector {
+	return &pickupDetector{claimed: make(map[uuid.UUID]time.Time)}
+}
+
+func (d *pickupDetector) Observe(event GameEvent, now time.Time) []Suspicion {
+	picked, ok := event.(ItemPickedUp)
+	if !ok || picked.EntityID == nil {
+		return nil
+	}
+	for entityID, at := range d.claimed {
+		if now.Sub(at) >= pickupMemory {
+			delete(d.claimed, entityID)
+		}
+	}
+	if _, taken := d.claimed[*picked.EntityID]; !taken {
+		d.claimed[*picked.EntityID] = now
+		return nil
+	}
+	return []Suspicion{{
+		PlayerID:  picked.PlayerID,
+		Heuristic: "duplicate_pickup",
+		Points:    40,
+		Detail:    fmt.Sprintf("picked up entity %s again", picked.EntityID),
+	}}
+}
+
+func (d *pickupDetector) Forget(playerID uuid.UUID) {}
+
+// PickupEntity reads the optional "entity_id" of pickup action data.
+func PickupEntity(data interface{}) *uuid.UUID {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	text, ok := fields["entity_id"].(string)
+	if !ok {
+		return nil
+	}
+	entityID, err := uuid.Parse(text)
+	if err != nil {
+		return nil
+	}
+	return &entityID
+}
@@ -0,0 +1,178 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const (
+	defaultAPILimit = 10
+	maxAPILimit     = 100
+)
+
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+	Score    int64  `json:"score"`
+	Rating   int64  `json:"rating"`
+	Bucket   string `json:"bucket"`
+}
+
+// APIHandler serves the read-only REST API under /api/.
+type APIHandler struct {
+	database     store.Store
+	leaderboards *Leaderboards
+}
+
+func NewAPIHandler(database store.Store) *APIHandler {
+	return &APIHandler{
+		database:     database,
+		leaderboards: NewLeaderboards(database),
+	}
+}
+
+func (api *APIHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/leaderboard", api.handleLeaderboard)
+	mux.HandleFunc("/api/leaderboard/", api.handleWindowedLeaderboard)
+	mux.HandleFunc("/api/rating-history", api.handleRatingHistory)
+	mux.HandleFunc("/api/servers", api.handleServers)
+	mux.HandleFunc("/api/guilds/leaderboard", api.handleGuildLeaderboard)
+	mux.HandleFunc("/api/matches/results", api.handleMatchResults)
+}
+
+// handleLeaderboard lists players ordered by ?sort=score|rating (default rating).
+func (api *APIHandler) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit := parseLimit(r)
+
+	var players []store.DBPlayer
+	var err error
+	switch r.URL.Query().Get("sort") {
+	case "", "rating":
+		players, err = api.database.GetTopRatedPlayers(r.Context(), limit)
+	case "score":
+		players, err = api.database.GetTopPlayers(r.Context(), limit)
+	default:
+		WriteJSONError(w, http.StatusBadRequest, "sort must be \"rating\" or \"score\"")
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to load leaderboard: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load leaderboard")
+		return
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(players))
+	for i, player := range players {
+		entries = append(entries, LeaderboardEntry{
+			Rank:     i + 1,
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Score:    player.Score,
+			Rating:   player.Rating,
+			Bucket:   RatingBucket(player.Rating),
+		})
+	}
+
+	WriteJSON(w, http.StatusOK, entries)
+}
+
+// handleWindowedLeaderboard serves /api/leaderboard/{daily,weekly,monthly,all}.
+func (api *APIHandler) handleWindowedLeaderboard(w http.ResponseWriter, r *http.Request) {
+	window := strings.TrimPrefix(r.URL.Path, "/api/leaderboard/")
+	if _, err := leaderboardPeriod(window, time.Now()); err != nil {
+		WriteJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response, err := api.leaderboards.Get(r.Context(), window, parseLimit(r))
+	if err != nil {
+		logrus.Errorf("Failed to load %s leaderboard: %v", window, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load leaderboard")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, response)
+}
+
+func (api *APIHandler) handleRatingHistory(w http.ResponseWriter, r *http.Request) {
+	playerID, err := uuid.Parse(r.URL.Query().Get("player_id"))
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "player_id must be a valid UUID")
+		return
+	}
+
+	history, err := api.database.GetRatingHistory(r.Context(), playerID, parseLimit(r))
+	if err != nil {
+		logrus.Errorf("Failed to load rating history: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load rating history")
+		return
+	}
+	if history == nil {
+		history = []store.RatingHistoryEntry{}
+	}
+
+	WriteJSON(w, http.StatusOK, history)
+}
+
+// handleServers lists the health of every instance sharing the database.
+func (api *APIHandler) handleServers(w http.ResponseWriter, r *http.Request) {
+	instances, err := api.database.GetServerInstances(r.Context())
+	if err != nil {
+		logrus.Errorf("Failed to load server instances: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load server instances")
+		return
+	}
+	if instances == nil {
+		instances = []store.ServerInstance{}
+	}
+
+	for i := range instances {
+		instances[i].Healthy = time.Since(instances[i].LastHeartbeat) < serverStaleAfter
+	}
+
+	WriteJSON(w, http.StatusOK, instances)
+}
+
+// handleGuildLeaderboard ranks guilds by the sum of their members' scores.
+func (api *APIHandler) handleGuildLeaderboard(w http.ResponseWriter, r *http.Request) {
+	scores, err := api.database.GetGuildLeaderboard(r.Context(), parseLimit(r))
+	if err != nil {
+		logrus.Errorf("Failed to load guild leaderboard: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load guild leaderboard")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, guildLeaderboardEntries(scores))
+}
+
+func parseLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultAPILimit
+	}
+	if limit > maxAPILimit {
+		return maxAPILimit
+	}
+	return limit
+}
+
+func WriteJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.Errorf("Failed to write JSON response: %v", err)
+	}
+}
+
+func WriteJSONError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, ErrorData{Message: message})
+}
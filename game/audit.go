@@ -0,0 +1,107 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"online-server-go/store"
+)
+
+// Admin actions recorded in admin_audit.
+const (
+	AuditKick            = "kick"
+	AuditBan             = "ban"
+	AuditUnban           = "unban"
+	AuditBroadcast       = "broadcast"
+	auditReloadRules     = "reload_rules"
+	auditStartEvent      = "start_event"
+	auditStopEvent       = "stop_event"
+	auditPauseRoom       = "pause_room"
+	auditResumeRoom      = "resume_room"
+	auditScheduleRestart = "schedule_restart"
+	auditCancelRestart   = "cancel_restart"
+	auditSetRole         = "set_role"
+	auditEscalateReports = "escalate_reports"
+	auditResolveReport   = "resolve_report"
+)
+
+// maxAuditEntries bounds a query of the audit log.
+const maxAuditEntries = 500
+
+// parseAuditFilter reads ?actor=, ?action=, ?target=, ?since= (RFC 3339)
+// and ?limit= of an audit query.
+func parseAuditFilter(r *http.Request) (store.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := store.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  100,
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return store.AuditFilter{}, fmt.Errorf("since must be an RFC 3339 time")
+		}
+		filter.Since = t
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditEntries {
+			return store.AuditFilter{}, fmt.Errorf("limit must be between 1 and %d", maxAuditEntries)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// The admin token is shared, so operators name themselves with
+// "X-Admin-Actor" (HTTP) or "x-admin-actor" (gRPC metadata). Without it the
+// actor is the caller's address.
+const adminActorHeader = "X-Admin-Actor"
+
+// httpActor names the operator behind an admin HTTP request.
+func httpActor(r *http.Request) string {
+	if actor := r.Header.Get(adminActorHeader); actor != "" {
+		return actor
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// RPCActor names the operator behind an admin RPC.
+func RPCActor(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-admin-actor"); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// RecordAdminAction writes an admin action to the audit log. A failure is
+// logged rather than undoing the action.
+func RecordAdminAction(ctx context.Context, database store.Store, actor, action, target, reason string) {
+	entry := store.AuditEntry{Actor: actor, Action: action, Target: target, Reason: reason}
+	if err := database.RecordAdminAction(ctx, entry); err != nil {
+		logrus.Errorf("Failed to record admin action %s by %s: %v", action, actor, err)
+		return
+	}
+	logrus.Infof("Admin %s: %s %s", actor, action, target)
+}
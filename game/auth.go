@@ -0,0 +1,207 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// maxAuthRequestBytes bounds register and login request bodies.
+const maxAuthRequestBytes = 4096
+
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AuthHandler serves account registration and login under /auth/.
+type AuthHandler struct {
+	accounts *Accounts
+}
+
+func NewAuthHandler(accounts *Accounts) *AuthHandler {
+	return &AuthHandler{accounts: accounts}
+}
+
+func (auth *AuthHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/auth/register", auth.handleRegister)
+	mux.HandleFunc("/auth/login", auth.handleLogin)
+	mux.HandleFunc("/auth/logout", auth.handleLogout)
+	mux.HandleFunc("/auth/me", auth.handleMe)
+	mux.HandleFunc("/auth/me/preferences", auth.handleGetPreferences)
+	mux.HandleFunc("/auth/me/preferences/set", auth.handleSetPreferences)
+	mux.HandleFunc("/auth/me/mail", auth.handleGetMail)
+	mux.HandleFunc("/auth/me/mail/read", auth.handleUpdateMail)
+	mux.HandleFunc("/auth/me/mail/delete", auth.handleUpdateMail)
+	mux.HandleFunc("/auth/oauth/", auth.handleOAuth)
+}
+
+// writeInputError answers with the code of an InputError in err, if any.
+func writeInputError(w http.ResponseWriter, status int, err error) {
+	response := ErrorData{Message: err.Error()}
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		response.Code = inputErr.Code
+	}
+	WriteJSON(w, status, response)
+}
+
+// handleRegister creates an account from {"username", "password"}.
+func (auth *AuthHandler) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	credentials, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := auth.accounts.Register(r.Context(), credentials.Username, credentials.Password)
+	switch {
+	case errors.Is(err, errInvalidAccount):
+		writeInputError(w, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, errUsernameTaken):
+		writeInputError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		logrus.Errorf("Failed to register account: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to register account")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, session)
+}
+
+func (auth *AuthHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	credentials, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := auth.accounts.Login(r.Context(), credentials.Username, credentials.Password)
+	if errors.Is(err, errInvalidCredentials) {
+		WriteJSONError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to log in: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to log in")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, session)
+}
+
+func (auth *AuthHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if _, ok := auth.authenticate(w, r); !ok {
+		return
+	}
+
+	if err := auth.accounts.Logout(r.Context(), bearerToken(r)); err != nil {
+		logrus.Errorf("Failed to log out: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to log out")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMe returns the account the bearer token belongs to.
+func (auth *AuthHandler) handleMe(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	account, ok := auth.authenticate(w, r)
+	if !ok {
+		return
+	}
+	WriteJSON(w, http.StatusOK, account)
+}
+
+// handleOAuth serves /auth/oauth/{provider}, which redirects to the
+// provider, and /auth/oauth/{provider}/callback, where the provider
+// redirects back and a token is issued.
+func (auth *AuthHandler) handleOAuth(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/auth/oauth/")
+	providerName, callback := strings.CutSuffix(path, "/callback")
+	if !callback {
+		url, ok := auth.accounts.StartOAuth(providerName)
+		if !ok {
+			WriteJSONError(w, http.StatusNotFound, "unknown OAuth provider")
+			return
+		}
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	if _, exists := auth.accounts.providers[providerName]; !exists {
+		WriteJSONError(w, http.StatusNotFound, "unknown OAuth provider")
+		return
+	}
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		WriteJSONError(w, http.StatusUnauthorized, "OAuth login failed: "+reason)
+		return
+	}
+
+	session, err := auth.accounts.FinishOAuth(r.Context(), providerName, query.Get("state"), query.Get("code"))
+	if errors.Is(err, errInvalidOAuthState) {
+		WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to finish %s login: %v", providerName, err)
+		WriteJSONError(w, http.StatusBadGateway, "OAuth login failed")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, session)
+}
+
+func (auth *AuthHandler) authenticate(w http.ResponseWriter, r *http.Request) (*store.Account, bool) {
+	account, err := auth.accounts.Authenticate(r.Context(), bearerToken(r))
+	if errors.Is(err, errInvalidToken) {
+		WriteJSONError(w, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		logrus.Errorf("Failed to authenticate: %v", err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to authenticate")
+		return nil, false
+	}
+	return account, true
+}
+
+func readCredentials(w http.ResponseWriter, r *http.Request) (credentialsRequest, bool) {
+	var credentials credentialsRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAuthRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&credentials); err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "body must be {\"username\", \"password\"}")
+		return credentials, false
+	}
+	return credentials, true
+}
+
+func bearerToken(r *http.Request) string {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+	return token
+}
@@ -0,0 +1,141 @@
+package game
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// bandwidthWindow is the span BANDWIDTH_CAP is measured over.
+	bandwidthWindow = time.Second
+	// bandwidthDropFactor is how far over the cap a client may go in one
+	// window, with its unreliable updates already thinned, before it is
+	// dropped.
+	bandwidthDropFactor = 2
+)
+
+// BandwidthStats counts the traffic of one connection.
+type BandwidthStats struct {
+	BytesIn     int64 `json:"bytes_in"`
+	BytesOut    int64 `json:"bytes_out"`
+	MessagesIn  int64 `json:"messages_in"`
+	MessagesOut int64 `json:"messages_out"`
+	Thinned     int64 `json:"thinned"` // unreliable updates skipped over the cap
+}
+
+// PlayerBandwidth is the traffic of a connected player for the admin API.
+type PlayerBandwidth struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+	Protocol string    `json:"protocol"`
+	BandwidthStats
+}
+
+// Bandwidth accounts for the traffic of one connection, in the per-client
+// stats of the admin API and in per-protocol counters. With a cap, in bytes
+// sent per second, the client's unreliable updates are thinned once it has
+// been sent the cap within a window, and it is dropped if it goes
+// bandwidthDropFactor over the cap regardless. It is safe for concurrent
+// use.
+type Bandwidth struct {
+	protocol string
+
+	mu          sync.Mutex
+	limit       int64 // bytes per second, 0 for no cap
+	stats       BandwidthStats
+	windowStart time.Time
+	windowBytes int64
+	exceeded    bool
+}
+
+func NewBandwidth(protocol string, bytesPerSecond int) *Bandwidth {
+	return &Bandwidth{protocol: protocol, limit: int64(bytesPerSecond)}
+}
+
+// SetCap changes the cap, 0 for none.
+func (b *Bandwidth) SetCap(bytesPerSecond int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = int64(bytesPerSecond)
+}
+
+// Received counts a message from the client.
+func (b *Bandwidth) Received(size int) {
+	b.mu.Lock()
+	b.stats.BytesIn += int64(size)
+	b.stats.MessagesIn++
+	b.mu.Unlock()
+
+	metrics.Add(b.protocol+"_bytes_in", int64(size))
+	metrics.Inc(b.protocol + "_messages_in")
+}
+
+// Thinning reports whether unreliable updates to the client are skipped at
+// now, because it has been sent its cap within the current window.
+func (b *Bandwidth) Thinning(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 {
+		return false
+	}
+	b.rollLocked(now)
+	return b.windowBytes >= b.limit
+}
+
+// Thinned counts unreliable updates skipped while thinning.
+func (b *Bandwidth) Thinned(messages int) {
+	b.mu.Lock()
+	b.stats.Thinned += int64(messages)
+	b.mu.Unlock()
+
+	metrics.Add("bandwidth_thinned", int64(messages))
+}
+
+// Sent counts a frame of one or more messages sent to the client. It
+// reports true once, when the client first goes bandwidthDropFactor over
+// its cap and must be dropped.
+func (b *Bandwidth) Sent(size, messages int, now time.Time) bool {
+	metrics.Add(b.protocol+"_bytes_out", int64(size))
+	metrics.Add(b.protocol+"_messages_out", int64(messages))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.BytesOut += int64(size)
+	b.stats.MessagesOut += int64(messages)
+	if b.limit <= 0 || b.exceeded {
+		return false
+	}
+
+	b.rollLocked(now)
+	b.windowBytes += int64(size)
+	if b.windowBytes <= bandwidthDropFactor*b.limit {
+		return false
+	}
+	b.exceeded = true
+	metrics.Inc("bandwidth_drops")
+	return true
+}
+
+// Exceeded reports whether the client went over its cap far enough to be
+// dropped.
+func (b *Bandwidth) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+func (b *Bandwidth) Stats() BandwidthStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// rollLocked starts a new window once the current one has passed.
+func (b *Bandwidth) rollLocked(now time.Time) {
+	if now.Sub(b.windowStart) >= bandwidthWindow {
+		b.windowStart = now
+		b.windowBytes = 0
+	}
+}
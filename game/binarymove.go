@@ -0,0 +1,189 @@
+package game
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// UDP clients that send the "binary_moves" capability in their first
+// Heartbeat receive PlayerMove and GameState as fixed-layout binary frames
+// instead of JSON or MessagePack. Players are referred to by a small index
+// announced in reliable PlayerIndex messages, and coordinates are quantized
+// to binaryCoordScale steps per unit. All integers are big-endian:
+//
+//	frame     magic u8 (0xC1), kind u8, sequence u32 (0 when unreliable)
+//	PlayerMove  index u16, x i32, y i32
+//	GameState   count u16, then per player: index u16, x i32, y i32,
+//	            health u8, score u32, level u16
+//
+// 0xC1 is never used by MessagePack and cannot start JSON, so clients tell
+// binary frames apart by their first byte. Reliable frames are acknowledged
+// with an Ack like any other packet. A frame may arrive before the
+// PlayerIndex message for one of its players; clients skip unknown indexes.
+// Compressed frames, kind 3, carry a gzipped packet; see transport/udp/compression.go.
+const (
+	CapabilityBinaryMoves = "binary_moves"
+
+	binaryFrameMagic     byte = 0xC1
+	binaryKindPlayerMove byte = 1
+	binaryKindGameState  byte = 2
+	binaryKindCompressed byte = 3
+
+	binaryFrameHeaderSize = 6
+	binaryMoveSize        = 10
+	binaryPlayerSize      = 17
+
+	binaryCoordScale = 100
+)
+
+// quantizeCoord converts a coordinate to fixed point, saturating at the
+// range of an int32.
+func quantizeCoord(v float32) int32 {
+	q := math.Round(float64(v) * binaryCoordScale)
+	if q > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if q < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(q)
+}
+
+func clampUint8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= math.MaxUint8 {
+		return math.MaxUint8
+	}
+	return uint8(math.Round(float64(v)))
+}
+
+func appendBinaryHeader(buf []byte, kind byte, sequence uint32) []byte {
+	buf = append(buf, binaryFrameMagic, kind)
+	return binary.BigEndian.AppendUint32(buf, sequence)
+}
+
+// EncodeBinaryMove encodes an unreliable PlayerMove frame.
+func EncodeBinaryMove(index uint16, x, y float32) []byte {
+	buf := make([]byte, 0, binaryFrameHeaderSize+binaryMoveSize)
+	buf = appendBinaryHeader(buf, binaryKindPlayerMove, 0)
+	buf = binary.BigEndian.AppendUint16(buf, index)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(quantizeCoord(x)))
+	return binary.BigEndian.AppendUint32(buf, uint32(quantizeCoord(y)))
+}
+
+// EncodeBinaryGameState encodes a GameState frame of every player that has
+// an index.
+func EncodeBinaryGameState(sequence uint32, players []Player, indexes *PlayerIndexTable) []byte {
+	buf := make([]byte, 0, binaryFrameHeaderSize+2+len(players)*binaryPlayerSize)
+	buf = appendBinaryHeader(buf, binaryKindGameState, sequence)
+	countAt := len(buf)
+	buf = append(buf, 0, 0)
+
+	var count uint16
+	for _, player := range players {
+		index, ok := indexes.Lookup(player.ID)
+		if !ok {
+			continue
+		}
+		buf = binary.BigEndian.AppendUint16(buf, index)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(quantizeCoord(player.X)))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(quantizeCoord(player.Y)))
+		buf = append(buf, clampUint8(player.Health))
+		buf = binary.BigEndian.AppendUint32(buf, player.Score)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(player.Level))
+		count++
+	}
+	binary.BigEndian.PutUint16(buf[countAt:], count)
+	return buf
+}
+
+// PlayerIndexTable assigns connected players the small indexes binary frames
+// refer to them by. Indexes are handed out round-robin so that a released
+// index is not reused while late packets may still carry it.
+type PlayerIndexTable struct {
+	mu      sync.RWMutex
+	indexes map[uuid.UUID]uint16
+	used    map[uint16]bool
+	next    uint16
+}
+
+func NewPlayerIndexTable() *PlayerIndexTable {
+	return &PlayerIndexTable{
+		indexes: make(map[uuid.UUID]uint16),
+		used:    make(map[uint16]bool),
+	}
+}
+
+// Assign returns the player's index, assigning one if needed. It reports
+// false when every index is taken; such players are sent as JSON.
+func (t *PlayerIndexTable) Assign(playerID uuid.UUID) (uint16, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index, ok := t.indexes[playerID]; ok {
+		return index, true
+	}
+	if len(t.used) > math.MaxUint16 {
+		return 0, false
+	}
+	for t.used[t.next] {
+		t.next++
+	}
+	index := t.next
+	t.next++
+	t.indexes[playerID] = index
+	t.used[index] = true
+	return index, true
+}
+
+func (t *PlayerIndexTable) Release(playerID uuid.UUID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index, ok := t.indexes[playerID]; ok {
+		delete(t.indexes, playerID)
+		delete(t.used, index)
+	}
+}
+
+func (t *PlayerIndexTable) Lookup(playerID uuid.UUID) (uint16, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	index, ok := t.indexes[playerID]
+	return index, ok
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	},
+}
+
+// CompressPacket wraps a serialized packet in a compressed frame, the way
+// the UDP transport sends large packets to clients that take them. The frame
+// is not built in a pooled buffer, since reliable frames are kept for
+// retransmission.
+func CompressPacket(packet []byte, sequence uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(binaryFrameHeaderSize + len(packet)/2)
+	buf.Write(appendBinaryHeader(buf.AvailableBuffer(), binaryKindCompressed, sequence))
+
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(packet); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// queuePollInterval is how often a queued client's position is checked.
+	queuePollInterval = time.Second
+	// queueUpdateInterval is the longest a queued client goes without a
+	// ServerFull message, which also notices clients that went away.
+	queueUpdateInterval = 10 * time.Second
+)
+
+// ErrServerFull refuses a client when the queue is full too.
+var ErrServerFull = errors.New("server is full")
+
+// Capacity bounds the concurrent players of a game server to MAX_PLAYERS.
+// WebSocket and TCP clients over the limit wait in a queue of up to
+// MAX_QUEUED_PLAYERS and are sent ServerFull messages with their position
+// until a slot frees. UDP clients are refused instead, since they keep
+// sending heartbeats until they get in. A nil *Capacity admits everyone.
+type Capacity struct {
+	max      int
+	maxQueue int
+
+	mu      sync.Mutex
+	active  int
+	waiting []chan struct{} // a freed slot is handed to waiting[0] by closing it
+}
+
+// NewCapacity returns nil when maxPlayers is 0.
+func NewCapacity(maxPlayers, maxQueued int) *Capacity {
+	if maxPlayers <= 0 {
+		return nil
+	}
+	return &Capacity{max: maxPlayers, maxQueue: maxQueued}
+}
+
+// TryAcquire takes a slot if one is free and nobody is queued for it.
+func (c *Capacity) TryAcquire() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.acquireLocked() {
+		metrics.Inc("players_refused")
+		return false
+	}
+	return true
+}
+
+func (c *Capacity) acquireLocked() bool {
+	if c.active >= c.max || len(c.waiting) > 0 {
+		return false
+	}
+	c.active++
+	metrics.SetGauge("players_admitted", float64(c.active))
+	return true
+}
+
+// Release frees a slot taken by TryAcquire or Admit, handing it to the first
+// queued client.
+func (c *Capacity) Release() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.waiting) > 0 {
+		close(c.waiting[0])
+		c.waiting = c.waiting[1:]
+		metrics.SetGauge("players_queued", float64(len(c.waiting)))
+		return
+	}
+	c.active--
+	metrics.SetGauge("players_admitted", float64(c.active))
+}
+
+// Max returns how many players are admitted at once.
+func (c *Capacity) Max() int {
+	return c.max
+}
+
+// Admit takes a slot for a connection, queueing it while the server is
+// full. It returns ErrServerFull, after sending ServerFull, when the queue
+// is full too and an error when the client went away; the caller then
+// closes the connection.
+func (c *Capacity) Admit(conn ClientConn, clientAddr string, protocolVersion int) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.acquireLocked() {
+		c.mu.Unlock()
+		return nil
+	}
+	if len(c.waiting) >= c.maxQueue {
+		c.mu.Unlock()
+		metrics.Inc("players_refused")
+		logrus.Infof("Refusing %s: server and queue are full", clientAddr)
+		if err := WriteMessageFor(conn, protocolVersion, NewServerFullMessage(0, c.max)); err != nil {
+			logrus.Errorf("Failed to send ServerFull to %s: %v", clientAddr, err)
+		}
+		return ErrServerFull
+	}
+	ready := make(chan struct{})
+	c.waiting = append(c.waiting, ready)
+	metrics.SetGauge("players_queued", float64(len(c.waiting)))
+	c.mu.Unlock()
+	metrics.Inc("players_queued_total")
+
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+	var sentPosition int
+	var sentAt time.Time
+	for {
+		if position := c.position(ready); position > 0 && (position != sentPosition || time.Since(sentAt) >= queueUpdateInterval) {
+			if err := WriteMessageFor(conn, protocolVersion, NewServerFullMessage(position, c.max)); err != nil {
+				logrus.Infof("Queued client %s went away: %v", clientAddr, err)
+				c.leave(ready)
+				return err
+			}
+			sentPosition, sentAt = position, time.Now()
+		}
+
+		select {
+		case <-ready:
+			logrus.Infof("Admitted queued client %s", clientAddr)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// position is where ready is in the queue, from 1, or 0 once it has a slot.
+func (c *Capacity) position(ready chan struct{}) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, waiting := range c.waiting {
+		if waiting == ready {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// leave takes a client out of the queue, giving back the slot it was handed
+// if it left just as one freed.
+func (c *Capacity) leave(ready chan struct{}) {
+	c.mu.Lock()
+	for i, waiting := range c.waiting {
+		if waiting == ready {
+			c.waiting = append(c.waiting[:i], c.waiting[i+1:]...)
+			metrics.SetGauge("players_queued", float64(len(c.waiting)))
+			c.mu.Unlock()
+			return
+		}
+	}
+	c.mu.Unlock()
+	c.Release()
+}
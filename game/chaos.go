@@ -0,0 +1,154 @@
+package game
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+)
+
+// ChaosDriverName is the sqlite driver the database is opened with. It
+// behaves exactly like sqlite3 until chaos injects database errors.
+const ChaosDriverName = "sqlite3_chaos"
+
+var errChaosDB = errors.New("chaos: injected database error")
+
+// Chaos injects faults at configured probabilities so that the reliability
+// layer and client reconnect logic can be exercised in staging. A nil *Chaos
+// injects nothing.
+type Chaos struct {
+	dbErrorRate    float64
+	latency        time.Duration
+	latencyRate    float64
+	dropRate       float64
+	disconnectRate float64
+}
+
+// ActiveChaos is set by server.Run once the server has started, so that
+// migrations and startup housekeeping never see injected faults.
+var ActiveChaos *Chaos
+
+// NewChaos returns nil when every fault rate is zero.
+func NewChaos(config *Config) *Chaos {
+	if config.ChaosDBErrorRate == 0 && config.ChaosLatencyRate == 0 &&
+		config.ChaosDropRate == 0 && config.ChaosDisconnectRate == 0 {
+		return nil
+	}
+
+	logrus.Warnf("Chaos enabled: db_errors=%.3f latency=%.3f (up to %s) dropped_broadcasts=%.3f disconnects=%.3f",
+		config.ChaosDBErrorRate, config.ChaosLatencyRate, config.ChaosLatency, config.ChaosDropRate, config.ChaosDisconnectRate)
+	return &Chaos{
+		dbErrorRate:    config.ChaosDBErrorRate,
+		latency:        config.ChaosLatency,
+		latencyRate:    config.ChaosLatencyRate,
+		dropRate:       config.ChaosDropRate,
+		disconnectRate: config.ChaosDisconnectRate,
+	}
+}
+
+// roll reports whether a fault with the given probability happens and counts
+// it if so.
+func (c *Chaos) roll(rate float64, metric string) bool {
+	if rand.Float64() >= rate {
+		return false
+	}
+	metrics.Inc(metric)
+	return true
+}
+
+// DBError returns an error for a database call that should fail.
+func (c *Chaos) DBError() error {
+	if c != nil && c.roll(c.dbErrorRate, "chaos_db_errors") {
+		return errChaosDB
+	}
+	return nil
+}
+
+// Delay sleeps for a random time up to the configured latency.
+func (c *Chaos) Delay() {
+	if c == nil || c.latency <= 0 || !c.roll(c.latencyRate, "chaos_delays") {
+		return
+	}
+	time.Sleep(time.Duration(rand.Int63n(int64(c.latency)) + 1))
+}
+
+// DropBroadcast reports whether one recipient of a broadcast should be
+// skipped.
+func (c *Chaos) DropBroadcast() bool {
+	return c != nil && c.roll(c.dropRate, "chaos_dropped_broadcasts")
+}
+
+// Disconnect reports whether the client whose message was just handled should
+// be disconnected.
+func (c *Chaos) Disconnect() bool {
+	return c != nil && c.roll(c.disconnectRate, "chaos_disconnects")
+}
+
+func init() {
+	sql.Register(ChaosDriverName, chaosDriver{})
+}
+
+// chaosDriver wraps the sqlite3 driver and fails queries and statements when
+// ActiveChaos says so.
+type chaosDriver struct{}
+
+func (chaosDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosConn{conn: conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type chaosConn struct {
+	conn *sqlite3.SQLiteConn
+}
+
+func (c *chaosConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *chaosConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := ActiveChaos.DBError(); err != nil {
+		return nil, err
+	}
+	return c.conn.PrepareContext(ctx, query)
+}
+
+func (c *chaosConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *chaosConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *chaosConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := ActiveChaos.DBError(); err != nil {
+		return nil, err
+	}
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *chaosConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *chaosConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ActiveChaos.DBError(); err != nil {
+		return nil, err
+	}
+	return c.conn.ExecContext(ctx, query, args)
+}
+
+func (c *chaosConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ActiveChaos.DBError(); err != nil {
+		return nil, err
+	}
+	return c.conn.QueryContext(ctx, query, args)
+}
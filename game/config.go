@@ -0,0 +1,354 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	Port         string
+	BindHosts    string // comma-separated addresses PORT is listened on, empty for all, see transport/listen.go
+	Protocol     string
+	DatabaseURL  string
+	CrashDumpDir string
+	TLSCertFile  string
+	TLSKeyFile   string
+	AdminToken   string
+	GRPCPort     string // admin gRPC API port, empty to disable
+	APIPort      string // HTTP API port with PROTOCOL=tcp, which uses PORT for the game
+	Console      bool   // read operator commands from stdin, see server/console.go
+	ConsolePort  string // localhost port serving the console over telnet, empty to disable
+	OTLPEndpoint string // OpenTelemetry collector receiving traces, empty to disable, see telemetry.go
+	ServerID     string // attributes sessions, events and matches to this instance across restarts
+	ServerName   string // shown in server browsers, SERVER_ID if empty
+	MapName      string // shown in server browsers
+	TenantsFile  string // JSON list of further games hosted by the process, see server/tenants.go
+
+	TLSAutocertDomains  string // comma-separated hosts to get Let's Encrypt certificates for
+	TLSAutocertCacheDir string // where obtained certificates are kept across restarts
+	TLSAutocertEmail    string // contact for Let's Encrypt expiry notices, optional
+	TLSAutocertHTTPPort string // port answering HTTP-01 challenges, empty for TLS-ALPN-01 on PORT only
+	TrustedProxies      string // comma-separated proxy IPs and CIDRs whose X-Forwarded-For is believed
+	AllowedOrigins      string // comma-separated origins allowed to open WebSockets, see OriginPolicy
+
+	HTTPReadTimeout  time.Duration // bound on reading an HTTP request, 0 for none
+	HTTPWriteTimeout time.Duration // bound on writing an HTTP response, 0 for none; WebSockets are exempt
+	HTTPIdleTimeout  time.Duration // how long an idle keep-alive connection is kept, 0 for HTTP_READ_TIMEOUT
+
+	MinProtocolVersion int // oldest client protocol version accepted, see protocol.go
+
+	MaxPlayers       int // concurrent players, 0 for unlimited
+	MaxQueuedPlayers int // WebSocket and TCP clients waiting for a slot when full
+	BandwidthCap     int // bytes per second sent to each client before its updates are thinned, 0 for no cap; see bandwidth.go
+
+	SendQueueSize     int           // frames a WebSocket or TCP client may have waiting to be written
+	SlowClientTimeout time.Duration // how long a client's send queue may stay full before it is disconnected
+
+	MaxMessageSize   int // bytes of a WebSocket message or TCP frame; see limits.go
+	MaxMessageDepth  int // how deeply the data of a client message may nest
+	MaxMessageValues int // values the data of a client message may hold
+
+	WSCompression        bool // negotiate permessage-deflate with WebSocket clients; see transport/udp/compression.go
+	CompressionThreshold int  // bytes from which messages to clients are compressed, 0 for never
+
+	MatchDuration time.Duration
+	MatchRoomSize int
+	GameMode      string // objective of match rooms, "ctf" or "koth", empty for none; see gamemode.go
+
+	Deterministic bool // draw all simulation randomness from SEED, see simrand.go
+	Seed          int  // seed of the first match with DETERMINISTIC
+
+	ScoreboardInterval time.Duration // time between Scoreboard broadcasts during a match, 0 for the final one only
+
+	AFKWarnAfter time.Duration // idle time before a player is warned and shown as AFK
+	AFKTimeout   time.Duration // idle time before a player is taken out of the match, 0 to never
+	AFKKick      bool          // disconnect players idle for AFK_TIMEOUT instead
+
+	WorldEventInterval time.Duration // time between scheduled world events, 0 for admin-only events
+
+	WorldSnapshotInterval time.Duration // time between saves of the world state, 0 to keep none
+
+	DBWriteBudget  int           // database writes per second shared by all subsystems, 0 for unlimited
+	DBQueryTimeout time.Duration // bound on each database query and write, 0 for none
+
+	SnapshotRate int // GameState broadcasts per second with PROTOCOL=websocket or tcp
+
+	WorldWidth  float64 // playfield size from the origin, 0 for an unbounded axis
+	WorldHeight float64
+	WorldEdge   string // "clamp" (default) or "wrap"
+	ZonesFile   string // JSON list of zones players move between, empty for one zone named MAP_NAME
+
+	VisibilityRange float64 // how far players see other players and entities, 0 for the whole zone; see visibility.go
+
+	AntiCheatFlagScore     float64 // suspicion at which a player is flagged to admins, 0 to disable the anti-cheat; see anticheat.go
+	AntiCheatActionScore   float64 // suspicion at which ANTICHEAT_ACTION is taken, 0 to never
+	AntiCheatAction        string  // "none" (default), "shadowban" or "kick"
+	AntiCheatMaxActionRate int     // actions per second a player may make before being suspected, 0 for any
+
+	ReportsPerHour       int           // reports a player may file per hour, 0 for any; see reports.go
+	ReportEscalateCount  int           // players reporting someone within REPORT_ESCALATE_WINDOW to escalate their reports, 0 to never
+	ReportEscalateWindow time.Duration // how recent those reports must be
+
+	RulesFile     string // JSON game rules, reloaded on SIGHUP; empty for the defaults
+	AbilitiesFile string // JSON list of abilities, empty for the defaults; see abilities.go
+	PluginDir     string // directory of Go plugins with game hooks, see plugins.go
+
+	AuthRequired       bool          // reject connections without an account token
+	AuthTokenTTL       time.Duration // lifetime of login tokens
+	OAuthProvidersFile string        // JSON list of OAuth login providers, empty for none
+
+	UDPWorkers    int    // goroutines handling UDP packets
+	UDPQueueSize  int    // packets waiting for a worker before the drop policy applies
+	UDPDropPolicy string // "newest" (default) or "oldest"
+	UDPSockets    int    // sockets per bind address, sharing the port with SO_REUSEPORT when above 1
+	UDPReadBatch  int    // datagrams read per system call, 1 to read them one at a time, see transport/udp/udpbatch.go
+	UDPSenders    int    // goroutines writing datagrams to clients, see transport/udp/udpsender.go
+	UDPSendQueue  int    // datagrams waiting for each sender before new ones are dropped
+
+	UDPClientTimeout     time.Duration // silence after which a UDP client is removed
+	UDPKeepaliveInterval time.Duration // how often UDP clients are told to send a Heartbeat, see transport/udp/keepalive.go
+
+	UDPEncryption        string // "off" (default), "optional" or "required", see UDPEncryption
+	UDPEncryptionKeyFile string // the server's X25519 key, created if missing
+	UDPRequireSignatures bool   // drop unsigned packets of registered UDP clients, see transport/udp/udpsign.go
+
+	ReplicationRole   string // "", "primary" or "standby"
+	ReplicationAddr   string // listen address on a primary, primary address on a standby
+	ReplicationSecret string // shared by a primary and its standbys, see transport/peerauth.go
+
+	ShardBackends string // comma-separated backend addresses for consistent-hash routing
+	ShardSelf     string // this instance's entry in ShardBackends
+
+	RPCPeers  string // comma-separated cluster RPC addresses of all instances
+	RPCSelf   string // this instance's entry in RPCPeers
+	RPCSecret string // shared by all instances of RPCPeers, see transport/peerauth.go
+
+	ServerProfile    string   // "full" (default) or "relay", which disables every optional feature
+	DisabledFeatures string   // comma-separated features to turn off on top of the profile
+	Features         Features // resolved from ServerProfile and DisabledFeatures at startup
+
+	// Fault injection for staging, as probabilities between 0 and 1
+	ChaosDBErrorRate    float64       // database calls that fail
+	ChaosLatency        time.Duration // upper bound of injected handler latency
+	ChaosLatencyRate    float64       // handled messages that are delayed
+	ChaosDropRate       float64       // broadcast recipients that are skipped
+	ChaosDisconnectRate float64       // handled messages after which the client is disconnected
+}
+
+func LoadConfig() *Config {
+	return LoadConfigFrom(os.Getenv)
+}
+
+// LoadConfigFrom reads the settings from env, which returns "" for those unset.
+func LoadConfigFrom(env func(key string) string) *Config {
+	return &Config{
+		Port:         getEnv(env, "PORT", "8080"),
+		BindHosts:    env("BIND_ADDRESSES"),
+		Protocol:     getEnv(env, "PROTOCOL", "websocket"),
+		DatabaseURL:  getEnv(env, "DATABASE_URL", "sqlite:game.db"),
+		CrashDumpDir: getEnv(env, "CRASH_DUMP_DIR", "crash_dumps"),
+		TLSCertFile:  env("TLS_CERT_FILE"),
+		TLSKeyFile:   env("TLS_KEY_FILE"),
+		AdminToken:   env("ADMIN_TOKEN"),
+		GRPCPort:     env("GRPC_PORT"),
+		APIPort:      env("API_PORT"),
+		Console:      getEnvBool(env, "CONSOLE", false),
+		ConsolePort:  env("CONSOLE_PORT"),
+		OTLPEndpoint: getEnv(env, "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", env("OTEL_EXPORTER_OTLP_ENDPOINT")),
+		ServerID:     getEnv(env, "SERVER_ID", defaultServerID(env)),
+		ServerName:   env("SERVER_NAME"),
+		MapName:      getEnv(env, "MAP_NAME", "default"),
+		TenantsFile:  env("TENANTS_FILE"),
+
+		TLSAutocertDomains:  env("TLS_AUTOCERT_DOMAINS"),
+		TLSAutocertCacheDir: getEnv(env, "TLS_AUTOCERT_CACHE_DIR", "autocert_cache"),
+		TLSAutocertEmail:    env("TLS_AUTOCERT_EMAIL"),
+		TLSAutocertHTTPPort: env("TLS_AUTOCERT_HTTP_PORT"),
+		TrustedProxies:      env("TRUSTED_PROXIES"),
+		AllowedOrigins:      env("ALLOWED_ORIGINS"),
+
+		HTTPReadTimeout:  getEnvDuration(env, "HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPWriteTimeout: getEnvDuration(env, "HTTP_WRITE_TIMEOUT", 30*time.Second),
+		HTTPIdleTimeout:  getEnvDuration(env, "HTTP_IDLE_TIMEOUT", 2*time.Minute),
+
+		MinProtocolVersion: getEnvInt(env, "MIN_PROTOCOL_VERSION", LegacyProtocolVersion),
+
+		MaxPlayers:       getEnvInt(env, "MAX_PLAYERS", 0),
+		MaxQueuedPlayers: getEnvInt(env, "MAX_QUEUED_PLAYERS", 100),
+		BandwidthCap:     getEnvInt(env, "BANDWIDTH_CAP", 0),
+
+		SendQueueSize:     getEnvInt(env, "SEND_QUEUE_SIZE", 1024),
+		SlowClientTimeout: getEnvDuration(env, "SLOW_CLIENT_TIMEOUT", 5*time.Second),
+
+		MaxMessageSize:   getEnvInt(env, "MAX_MESSAGE_SIZE", 64*1024),
+		MaxMessageDepth:  getEnvInt(env, "MAX_MESSAGE_DEPTH", 8),
+		MaxMessageValues: getEnvInt(env, "MAX_MESSAGE_VALUES", 1024),
+
+		WSCompression:        getEnvBool(env, "WS_COMPRESSION", true),
+		CompressionThreshold: getEnvInt(env, "COMPRESSION_THRESHOLD", 1024),
+
+		MatchDuration: getEnvDuration(env, "MATCH_DURATION", 10*time.Minute),
+		MatchRoomSize: getEnvInt(env, "MATCH_ROOM_SIZE", 16),
+		GameMode:      env("GAME_MODE"),
+
+		Deterministic: getEnvBool(env, "DETERMINISTIC", false),
+		Seed:          getEnvInt(env, "SEED", 1),
+
+		ScoreboardInterval: getEnvDuration(env, "SCOREBOARD_INTERVAL", 30*time.Second),
+
+		AFKWarnAfter: getEnvDuration(env, "AFK_WARN_AFTER", 2*time.Minute),
+		AFKTimeout:   getEnvDuration(env, "AFK_TIMEOUT", 5*time.Minute),
+		AFKKick:      getEnvBool(env, "AFK_KICK", false),
+
+		WorldEventInterval: getEnvDuration(env, "WORLD_EVENT_INTERVAL", 30*time.Minute),
+
+		WorldSnapshotInterval: getEnvDuration(env, "WORLD_SNAPSHOT_INTERVAL", time.Minute),
+
+		DBWriteBudget:  getEnvInt(env, "DB_WRITE_BUDGET", 1000),
+		DBQueryTimeout: getEnvDuration(env, "DB_QUERY_TIMEOUT", 5*time.Second),
+
+		SnapshotRate: getEnvInt(env, "SNAPSHOT_RATE", 20),
+
+		WorldWidth:  getEnvFloat(env, "WORLD_WIDTH", 0),
+		WorldHeight: getEnvFloat(env, "WORLD_HEIGHT", 0),
+		WorldEdge:   env("WORLD_EDGE"),
+		ZonesFile:   env("ZONES_FILE"),
+
+		VisibilityRange: getEnvFloat(env, "VISIBILITY_RANGE", 0),
+
+		AntiCheatFlagScore:     getEnvFloat(env, "ANTICHEAT_FLAG_SCORE", 100),
+		AntiCheatActionScore:   getEnvFloat(env, "ANTICHEAT_ACTION_SCORE", 200),
+		AntiCheatAction:        env("ANTICHEAT_ACTION"),
+		AntiCheatMaxActionRate: getEnvInt(env, "ANTICHEAT_MAX_ACTIONS_PER_SECOND", 20),
+
+		ReportsPerHour:       getEnvInt(env, "REPORTS_PER_HOUR", 5),
+		ReportEscalateCount:  getEnvInt(env, "REPORT_ESCALATE_COUNT", 3),
+		ReportEscalateWindow: getEnvDuration(env, "REPORT_ESCALATE_WINDOW", 10*time.Minute),
+
+		RulesFile:     env("RULES_FILE"),
+		AbilitiesFile: env("ABILITIES_FILE"),
+		PluginDir:     env("PLUGIN_DIR"),
+
+		AuthRequired:       getEnvBool(env, "AUTH_REQUIRED", false),
+		AuthTokenTTL:       getEnvDuration(env, "AUTH_TOKEN_TTL", 30*24*time.Hour),
+		OAuthProvidersFile: env("OAUTH_PROVIDERS_FILE"),
+
+		UDPWorkers:    getEnvInt(env, "UDP_WORKERS", 64),
+		UDPQueueSize:  getEnvInt(env, "UDP_QUEUE_SIZE", 4096),
+		UDPDropPolicy: env("UDP_DROP_POLICY"),
+		UDPSockets:    getEnvInt(env, "UDP_SOCKETS", 1),
+		UDPReadBatch:  getEnvInt(env, "UDP_READ_BATCH", 32),
+		UDPSenders:    getEnvInt(env, "UDP_SENDERS", 4),
+		UDPSendQueue:  getEnvInt(env, "UDP_SEND_QUEUE", 4096),
+
+		UDPClientTimeout:     getEnvDuration(env, "UDP_CLIENT_TIMEOUT", 30*time.Second),
+		UDPKeepaliveInterval: getEnvDuration(env, "UDP_KEEPALIVE_INTERVAL", 10*time.Second),
+
+		UDPEncryption:        env("UDP_ENCRYPTION"),
+		UDPEncryptionKeyFile: getEnv(env, "UDP_ENCRYPTION_KEY_FILE", defaultUDPKeyFile()),
+		UDPRequireSignatures: getEnvBool(env, "UDP_REQUIRE_SIGNATURES", false),
+
+		ReplicationRole:   env("REPLICATION_ROLE"),
+		ReplicationAddr:   env("REPLICATION_ADDR"),
+		ReplicationSecret: env("REPLICATION_SECRET"),
+
+		ShardBackends: env("SHARD_BACKENDS"),
+		ShardSelf:     env("SHARD_SELF"),
+
+		RPCPeers:  env("RPC_PEERS"),
+		RPCSelf:   env("RPC_SELF"),
+		RPCSecret: env("RPC_SECRET"),
+
+		ServerProfile:    env("SERVER_PROFILE"),
+		DisabledFeatures: env("DISABLED_FEATURES"),
+
+		ChaosDBErrorRate:    getEnvFloat(env, "CHAOS_DB_ERROR_RATE", 0),
+		ChaosLatency:        getEnvDuration(env, "CHAOS_LATENCY", 500*time.Millisecond),
+		ChaosLatencyRate:    getEnvFloat(env, "CHAOS_LATENCY_RATE", 0),
+		ChaosDropRate:       getEnvFloat(env, "CHAOS_DROP_BROADCAST_RATE", 0),
+		ChaosDisconnectRate: getEnvFloat(env, "CHAOS_DISCONNECT_RATE", 0),
+	}
+}
+
+// defaultServerID is the host name and port, which stay the same when the
+// server is restarted on the same machine.
+func defaultServerID(env func(key string) string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	return hostname + ":" + getEnv(env, "PORT", "8080")
+}
+
+// defaultUDPKeyFile is in the user's config directory, so that a server run
+// from a checkout does not create its private key in the working tree.
+func defaultUDPKeyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "online-server-go", "udp_server.key")
+}
+
+func getEnv(env func(key string) string, key, fallback string) string {
+	if value := env(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(env func(key string) string, key string, fallback time.Duration) time.Duration {
+	value := env(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Warnf("Invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return duration
+}
+
+func getEnvInt(env func(key string) string, key string, fallback int) int {
+	value := env(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.Warnf("Invalid integer %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvFloat(env func(key string) string, key string, fallback float64) float64 {
+	value := env(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logrus.Warnf("Invalid number %q for %s, using %g", value, key, fallback)
+		return fallback
+	}
+	return f
+}
+
+func getEnvBool(env func(key string) string, key string, fallback bool) bool {
+	value := env(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Invalid boolean %q for %s, using %t", value, key, fallback)
+		return fallback
+	}
+	return b
+}
@@ -0,0 +1,28 @@
+package game
+
+// ClientConn is the connection behind a client of package ws. WebSocket and
+// plain TCP clients share the same handlers and differ only in framing.
+type ClientConn interface {
+	// ReadFrame blocks until the next complete message arrives.
+	ReadFrame() ([]byte, error)
+	// WriteFrame sends one message. It is only called from the write pump.
+	WriteFrame(data []byte) error
+	// WriteClose tells the peer the connection is closing, with an optional
+	// reason. It may be called concurrently with WriteFrame.
+	WriteClose(reason string) error
+	Close() error
+	// Protocol names the transport in sessions, traces and metrics.
+	Protocol() string
+	// Encoding is the wire format of every frame on the connection.
+	Encoding() Encoding
+}
+
+// WriteMessage encodes and sends a single message outside the write pump,
+// for connections that are rejected before they join.
+func WriteMessage(conn ClientConn, message GameMessage) error {
+	data, err := conn.Encoding().Marshal(message)
+	if err != nil {
+		return err
+	}
+	return conn.WriteFrame(data)
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A connection is degraded while its ping or either loss rate exceeds these.
+const (
+	degradedPing = 300 * time.Millisecond
+	degradedLoss = 0.05
+)
+
+// ConnectionQuality measures a UDP client's link between two reports. Ping
+// comes from heartbeat timestamps echoed in acks, which stay unambiguous even
+// when the heartbeat had to be resent. Packet loss is the share of the
+// client's sequences that never arrived, and the retransmit rate the share
+// of reliable packets the server had to resend. The zero value is ready to
+// use; it is not safe for concurrent use.
+type ConnectionQuality struct {
+	ping   time.Duration
+	jitter time.Duration
+	pinged bool
+
+	baseline    uint32 // highest sequence at the last report
+	based       bool
+	received    int
+	reliable    int
+	retransmits int
+
+	last ConnectionQualityData
+}
+
+// OnPing adds a heartbeat round trip, smoothed like the RTT estimator.
+func (q *ConnectionQuality) OnPing(rtt time.Duration) {
+	if !q.pinged {
+		q.ping = rtt
+		q.jitter = rtt / 2
+		q.pinged = true
+		return
+	}
+	delta := q.ping - rtt
+	if delta < 0 {
+		delta = -delta
+	}
+	q.jitter = (3*q.jitter + delta) / 4
+	q.ping = (7*q.ping + rtt) / 8
+}
+
+// OnReceive counts a new sequenced packet from the client.
+func (q *ConnectionQuality) OnReceive(sequence uint32) {
+	if !q.based {
+		q.baseline = sequence - 1
+		q.based = true
+	}
+	q.received++
+}
+
+func (q *ConnectionQuality) OnReliableSent() {
+	q.reliable++
+}
+
+func (q *ConnectionQuality) OnRetransmits(count int) {
+	q.retransmits += count
+}
+
+// Report closes the interval ending at the client's highest sequence and
+// returns its figures, which Last keeps until the next report.
+func (q *ConnectionQuality) Report(highest uint32) ConnectionQualityData {
+	report := ConnectionQualityData{
+		PingMs:   float64(q.ping) / float64(time.Millisecond),
+		JitterMs: float64(q.jitter) / float64(time.Millisecond),
+	}
+
+	// Packets reordered across a report count in the next interval, so
+	// loss is clamped rather than exact
+	if expected := int32(highest - q.baseline); q.based && expected > 0 {
+		report.PacketLoss = clampRatio(1 - float64(q.received)/float64(expected))
+		q.baseline = highest
+	}
+	if q.reliable > 0 {
+		report.RetransmitRate = clampRatio(float64(q.retransmits) / float64(q.reliable))
+	}
+	report.Degraded = q.ping > degradedPing || report.PacketLoss > degradedLoss || report.RetransmitRate > degradedLoss
+
+	q.received = 0
+	q.reliable = 0
+	q.retransmits = 0
+	q.last = report
+	return report
+}
+
+// Last returns the most recent report.
+func (q *ConnectionQuality) Last() ConnectionQualityData {
+	return q.last
+}
+
+func clampRatio(ratio float64) float64 {
+	if ratio < 0 {
+		return 0
+	}
+	if ratio > 1 {
+		return 1
+	}
+	return ratio
+}
+
+// ConnectionQualitySource lists the measured link quality of connected
+// players for the admin API.
+type ConnectionQualitySource interface {
+	ConnectionQualities() []PlayerConnectionQuality
+}
+
+type PlayerConnectionQuality struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+	Addr     string    `json:"addr"`
+	ConnectionQualityData
+}
@@ -0,0 +1,176 @@
+package game
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	flagPickupRadius  = 40
+	flagCaptureRadius = 60
+	ctfCaptureLimit   = 3
+	ctfCapturePoints  = 50
+)
+
+var ctfTeams = []string{"red", "blue"}
+
+type ctfFlag struct {
+	team    string
+	x, y    float32   // the team's base, where the flag stands when not carried
+	carrier uuid.UUID // uuid.Nil while the flag is at its base
+}
+
+// captureTheFlag splits a room into two teams, each with a flag at its base
+// on opposite ends of the field. Touching the other team's flag picks it
+// up; bringing it to your own base while your flag is there scores a
+// capture. A carried flag goes back to its base when its carrier dies,
+// leaves or drops it with the drop_flag action.
+type captureTheFlag struct {
+	field    ModeField
+	flags    []*ctfFlag
+	captures map[string]int64
+	teams    map[uuid.UUID]string
+}
+
+func newCaptureTheFlag(field ModeField) GameMode {
+	return &captureTheFlag{field: field, teams: make(map[uuid.UUID]string)}
+}
+
+func (m *captureTheFlag) Init(arena ModeArena) {
+	m.flags = []*ctfFlag{
+		{team: ctfTeams[0], x: m.field.Width * 0.1, y: m.field.Height / 2},
+		{team: ctfTeams[1], x: m.field.Width * 0.9, y: m.field.Height / 2},
+	}
+	m.captures = make(map[string]int64, len(ctfTeams))
+	for _, team := range ctfTeams {
+		m.captures[team] = 0
+	}
+	m.announce(arena)
+}
+
+func (m *captureTheFlag) OnTick(arena ModeArena, now time.Time) {
+	players := make(map[uuid.UUID]Player)
+	for _, player := range arena.Players() {
+		players[player.ID] = player
+	}
+	changed := m.assignTeams(arena, players)
+
+	for _, flag := range m.flags {
+		if flag.carrier == uuid.Nil {
+			for _, player := range players {
+				if player.Health > 0 && m.teams[player.ID] != flag.team && withinRadius(player, flag.x, flag.y, flagPickupRadius) {
+					flag.carrier = player.ID
+					changed = true
+					break
+				}
+			}
+			continue
+		}
+
+		carrier, present := players[flag.carrier]
+		if !present || carrier.Health <= 0 {
+			flag.carrier = uuid.Nil
+			changed = true
+			continue
+		}
+		home := m.flagOf(m.teams[carrier.ID])
+		if home.carrier == uuid.Nil && withinRadius(carrier, home.x, home.y, flagCaptureRadius) {
+			flag.carrier = uuid.Nil
+			m.captures[home.team]++
+			arena.AwardPoints(carrier.ID, ctfCapturePoints, "flag_capture")
+			changed = true
+		}
+	}
+
+	if changed {
+		m.announce(arena)
+	}
+}
+
+// assignTeams puts players new to the round on the smaller team and forgets
+// those who left the field. It reports whether the teams changed.
+func (m *captureTheFlag) assignTeams(arena ModeArena, players map[uuid.UUID]Player) bool {
+	changed := false
+	for playerID := range m.teams {
+		if _, present := players[playerID]; !present {
+			delete(m.teams, playerID)
+			changed = true
+		}
+	}
+
+	sizes := make(map[string]int, len(ctfTeams))
+	for _, team := range m.teams {
+		sizes[team]++
+	}
+	for playerID := range players {
+		if _, assigned := m.teams[playerID]; assigned {
+			continue
+		}
+		team := ctfTeams[0]
+		for _, other := range ctfTeams[1:] {
+			if sizes[other] < sizes[team] {
+				team = other
+			}
+		}
+		m.teams[playerID] = team
+		sizes[team]++
+		arena.SetTeam(playerID, team)
+		changed = true
+	}
+	return changed
+}
+
+func (m *captureTheFlag) OnPlayerAction(arena ModeArena, player Player, action string, data interface{}) bool {
+	if action != "drop_flag" {
+		return false
+	}
+	for _, flag := range m.flags {
+		if flag.carrier == player.ID {
+			flag.carrier = uuid.Nil
+			m.announce(arena)
+		}
+	}
+	return true
+}
+
+func (m *captureTheFlag) CheckWin() (string, bool) {
+	for _, team := range ctfTeams {
+		if m.captures[team] >= ctfCaptureLimit {
+			return team, true
+		}
+	}
+	return "", false
+}
+
+func (m *captureTheFlag) flagOf(team string) *ctfFlag {
+	for _, flag := range m.flags {
+		if flag.team == team {
+			return flag
+		}
+	}
+	return m.flags[0]
+}
+
+func (m *captureTheFlag) announce(arena ModeArena) {
+	flags := make([]FlagState, 0, len(m.flags))
+	for _, flag := range m.flags {
+		state := FlagState{Team: flag.team, X: flag.x, Y: flag.y}
+		if flag.carrier != uuid.Nil {
+			carrier := flag.carrier
+			state.CarrierID = &carrier
+		}
+		flags = append(flags, state)
+	}
+	scores := make(map[string]int64, len(m.captures))
+	for team, captures := range m.captures {
+		scores[team] = captures
+	}
+	stateMessage := NewGameModeStateMessage(GameModeStateData{
+		Room:   arena.Room(),
+		Mode:   "ctf",
+		Scores: scores,
+		Flags:  flags,
+	})
+	arena.Announce(&stateMessage)
+}
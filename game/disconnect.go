@@ -0,0 +1,56 @@
+package game
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// Reasons the server disconnects a player, sent in the reason field of
+// Disconnect messages and recorded as disconnect events in player_events.
+const (
+	DisconnectKicked      = "kicked"
+	DisconnectBanned      = "banned"
+	DisconnectTimeout     = "timeout"
+	DisconnectServerFull  = "server_full"
+	DisconnectShutdown    = "shutdown"
+	DisconnectMaintenance = "maintenance"
+	DisconnectAFK         = "afk"
+	DisconnectCheating    = "cheating"
+	DisconnectBandwidth   = "bandwidth"
+	DisconnectSlow        = "slow"
+	DisconnectTooLarge    = errorCodeMessageTooLarge
+)
+
+// Reasons a WebSocket or TCP connection ends without a Disconnect message,
+// counted with the others in the disconnects_<reason> metrics.
+const (
+	DisconnectLeft  = "left"  // the client closed the connection
+	DisconnectError = "error" // handling its message panicked
+	DisconnectChaos = "chaos" // dropped by chaos testing
+)
+
+// DisconnectGrace is how long a disconnected client's writer has to send
+// its last messages before the connection is closed regardless.
+const DisconnectGrace = 2 * time.Second
+
+// LogDisconnect records why the server disconnected a player.
+func LogDisconnect(ctx context.Context, database store.Store, playerID uuid.UUID, sessionID *int64, message *GameMessage) {
+	if err := database.LogEvent(ctx, playerID, sessionID, "disconnect", message); err != nil {
+		logrus.Errorf("Failed to log disconnect event: %v", err)
+	}
+}
+
+// RefuseConnection tells a WebSocket or TCP client that has not joined why
+// it is turned away and records it. The caller closes the connection.
+func RefuseConnection(ctx context.Context, database store.Store, conn ClientConn, version int, playerID uuid.UUID, reason, text string) {
+	message := NewDisconnectMessage(reason, text)
+	if err := WriteMessageFor(conn, version, message); err != nil {
+		logrus.Errorf("Failed to send Disconnect to %s: %v", playerID, err)
+	}
+	LogDisconnect(ctx, database, playerID, nil, &message)
+}
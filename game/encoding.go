@@ -0,0 +1,349 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoding is the wire format a client exchanges messages in. JSON is the
+// default; MessagePack carries the same GameMessage structure in roughly half
+// the bytes.
+type Encoding int
+
+const (
+	EncodingJSON Encoding = iota
+	EncodingMsgpack
+)
+
+// ParseEncoding parses the encoding a client asked for. An empty name means
+// JSON.
+func ParseEncoding(name string) (Encoding, error) {
+	switch name {
+	case "", "json":
+		return EncodingJSON, nil
+	case "msgpack":
+		return EncodingMsgpack, nil
+	default:
+		return EncodingJSON, fmt.Errorf("unknown encoding %q, expected \"json\" or \"msgpack\"", name)
+	}
+}
+
+// detectEncoding tells the encoding of a message from its first byte. Every
+// message is a JSON object or a MessagePack map, and no MessagePack map
+// starts with '{'.
+func detectEncoding(data []byte) Encoding {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return EncodingJSON
+	}
+	return EncodingMsgpack
+}
+
+func (e Encoding) String() string {
+	if e == EncodingMsgpack {
+		return "msgpack"
+	}
+	return "json"
+}
+
+// Marshal encodes v. MessagePack output has exactly the structure of the
+// JSON output, so struct tags, omitempty and UUIDs as strings all carry over
+// and clients decode both formats with the same schema.
+func (e Encoding) Marshal(v interface{}) ([]byte, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := e.MarshalTo(buf, v); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// MarshalTo appends the encoding of v to buf, so callers that only write the
+// result out can reuse a pooled buffer.
+func (e Encoding) MarshalTo(buf *bytes.Buffer, v interface{}) error {
+	if e == EncodingJSON {
+		return marshalJSONTo(buf, v)
+	}
+
+	scratch := GetBuffer()
+	defer PutBuffer(scratch)
+	if err := marshalJSONTo(scratch, v); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(scratch)
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+
+	encoder := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(encoder)
+	encoder.Reset(buf)
+	encoder.UseCompactInts(true)
+	encoder.UseCompactFloats(true)
+	return encoder.Encode(compactNumbers(generic))
+}
+
+// marshalJSONTo writes what json.Marshal would return.
+func marshalJSONTo(buf *bytes.Buffer, v interface{}) error {
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+	// Encode terminates every value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// maxPooledBuffer keeps the occasional huge message (a full GameState) from
+// pinning its buffer in the pool.
+const maxPooledBuffer = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func GetBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// PutBuffer returns a buffer to the pool. Nothing may keep its bytes.
+func PutBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// Unmarshal decodes data into v. MessagePack is decoded into the same
+// generic values JSON produces, so handlers that read map[string]interface{}
+// payloads work unchanged.
+func (e Encoding) Unmarshal(data []byte, v interface{}) error {
+	if e == EncodingJSON {
+		return json.Unmarshal(data, v)
+	}
+
+	raw, err := msgpackToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func msgpackToJSON(data []byte) ([]byte, error) {
+	if err := checkMsgpack(data); err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := msgpack.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// maxMsgpackDepth bounds how deeply MessagePack maps and arrays may nest,
+// like encoding/json bounds JSON.
+const maxMsgpackDepth = 10000
+
+// checkMsgpack walks the MessagePack value at the start of data without
+// decoding it. It fails if a map, array, string or binary claims more
+// elements or bytes than are left, since the decoder allocates by the
+// claimed length before reading: a 31-byte packet claiming a map of two
+// billion entries would otherwise allocate gigabytes.
+func checkMsgpack(data []byte) error {
+	_, err := skipMsgpack(data, 0)
+	return err
+}
+
+// skipMsgpack returns what follows the value at the start of data.
+func skipMsgpack(data []byte, depth int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("msgpack: truncated value")
+	}
+	if depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("msgpack: values nested deeper than %d", maxMsgpackDepth)
+	}
+
+	code, rest := data[0], data[1:]
+	switch {
+	case code <= 0x7f || code >= 0xe0 || code == 0xc0 || code == 0xc2 || code == 0xc3:
+		return rest, nil
+	case code <= 0x8f:
+		return skipMsgpackElements(rest, 2*int(code&0x0f), depth)
+	case code <= 0x9f:
+		return skipMsgpackElements(rest, int(code&0x0f), depth)
+	case code <= 0xbf:
+		return skipMsgpackBytes(rest, int(code&0x1f))
+	}
+
+	switch code {
+	case 0xcc, 0xd0:
+		return skipMsgpackBytes(rest, 1)
+	case 0xcd, 0xd1:
+		return skipMsgpackBytes(rest, 2)
+	case 0xca, 0xce, 0xd2:
+		return skipMsgpackBytes(rest, 4)
+	case 0xcb, 0xcf, 0xd3:
+		return skipMsgpackBytes(rest, 8)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext: type and 1 to 16 bytes
+		return skipMsgpackBytes(rest, 1+1<<(code-0xd4))
+	case 0xc4, 0xd9: // bin8, str8
+		return skipMsgpackSized(rest, 1, 0)
+	case 0xc5, 0xda:
+		return skipMsgpackSized(rest, 2, 0)
+	case 0xc6, 0xdb:
+		return skipMsgpackSized(rest, 4, 0)
+	case 0xc7: // ext8: length, type and bytes
+		return skipMsgpackSized(rest, 1, 1)
+	case 0xc8:
+		return skipMsgpackSized(rest, 2, 1)
+	case 0xc9:
+		return skipMsgpackSized(rest, 4, 1)
+	case 0xdc, 0xdd, 0xde, 0xdf: // array16, array32, map16, map32
+		width := 2
+		if code == 0xdd || code == 0xdf {
+			width = 4
+		}
+		n, rest, err := msgpackLength(rest, width)
+		if err != nil {
+			return nil, err
+		}
+		if code >= 0xde {
+			n *= 2
+		}
+		return skipMsgpackElements(rest, n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: invalid code %x", code)
+}
+
+// skipMsgpackElements skips n values, each at least a byte long.
+func skipMsgpackElements(data []byte, n, depth int) ([]byte, error) {
+	if n > len(data) {
+		return nil, fmt.Errorf("msgpack: %d elements claimed with %d bytes left", n, len(data))
+	}
+	var err error
+	for i := 0; i < n; i++ {
+		if data, err = skipMsgpack(data, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// skipMsgpackSized skips a length of width bytes, extra bytes and as many
+// bytes as the length gives.
+func skipMsgpackSized(data []byte, width, extra int) ([]byte, error) {
+	n, rest, err := msgpackLength(data, width)
+	if err != nil {
+		return nil, err
+	}
+	return skipMsgpackBytes(rest, extra+n)
+}
+
+func skipMsgpackBytes(data []byte, n int) ([]byte, error) {
+	if n > len(data) {
+		return nil, fmt.Errorf("msgpack: %d bytes claimed with %d left", n, len(data))
+	}
+	return data[n:], nil
+}
+
+// msgpackLength reads a big-endian length of width bytes.
+func msgpackLength(data []byte, width int) (int, []byte, error) {
+	if width > len(data) {
+		return 0, nil, errors.New("msgpack: truncated length")
+	}
+	n := 0
+	for _, b := range data[:width] {
+		n = n<<8 | int(b)
+	}
+	return n, data[width:], nil
+}
+
+// compactNumbers replaces JSON numbers with integers where possible so that
+// MessagePack can use its short integer forms.
+func compactNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			v[key] = compactNumbers(inner)
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = compactNumbers(inner)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// BroadcastPayload encodes a message at most once per encoding however many
+// clients it is sent to.
+type BroadcastPayload struct {
+	Message GameMessage
+
+	mu      sync.Mutex
+	encoded map[Encoding][]byte
+}
+
+func NewBroadcastPayload(message *GameMessage) *BroadcastPayload {
+	return &BroadcastPayload{Message: *message}
+}
+
+// Encoded returns the message in encoding e. The result is shared, so it
+// must not be modified.
+func (b *BroadcastPayload) Encoded(e Encoding) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if data, ok := b.encoded[e]; ok {
+		return data, nil
+	}
+	data, err := e.Marshal(&b.Message)
+	if err != nil {
+		return nil, err
+	}
+	if b.encoded == nil {
+		b.encoded = make(map[Encoding][]byte, 1)
+	}
+	b.encoded[e] = data
+	return data, nil
+}
+
+// readablePayload renders a message as JSON for traces and logs whatever
+// encoding it arrived in.
+func readablePayload(payload []byte) []byte {
+	if len(payload) >= 2 && payload[0] == binaryFrameMagic {
+		return []byte(fmt.Sprintf(`{"binary_frame":%d,"bytes":%d}`, payload[1], len(payload)))
+	}
+	if detectEncoding(payload) == EncodingJSON {
+		return payload
+	}
+	if data, err := msgpackToJSON(payload); err == nil {
+		return data
+	}
+	return payload
+}
+
+// CoalesceKey identifies messages that only the latest of matters.
+func CoalesceKey(message *GameMessage) string {
+	switch message.Type {
+	case "GameState":
+		return "GameState"
+	case "PlayerMove":
+		if data, ok := message.Data.(PlayerMoveData); ok {
+			return "PlayerMove:" + data.PlayerID.String()
+		}
+	}
+	return ""
+}
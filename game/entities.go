@@ -0,0 +1,267 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of entities. Players are not kept in the registry but are presented
+// as entities of kind EntityPlayer by Player.Entity.
+const (
+	EntityPlayer     = "player"
+	EntityNPC        = "npc"
+	EntityItem       = "item"
+	EntityProjectile = "projectile"
+)
+
+var entityKinds = map[string]bool{
+	EntityNPC:        true,
+	EntityItem:       true,
+	EntityProjectile: true,
+}
+
+// Entity is an object of the world made of optional components, so that
+// snapshots, persistence and zone visibility treat NPCs, items and
+// projectiles alike whatever they are made of.
+type Entity struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	Zone      string     `json:"zone"`
+	Position  *Position  `json:"position,omitempty"`
+	Health    *Health    `json:"health,omitempty"`
+	Team      *Team      `json:"team,omitempty"`
+	Ownership *Ownership `json:"ownership,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // removed by then, like a spent projectile
+
+	movedAt time.Time // when Position was last set, see SnapshotAt
+}
+
+// Position places an entity, which moves at VX, VY units per second.
+type Position struct {
+	X  float32 `json:"x"`
+	Y  float32 `json:"y"`
+	VX float32 `json:"vx,omitempty"`
+	VY float32 `json:"vy,omitempty"`
+}
+
+type Health struct {
+	Current float32 `json:"current"`
+	Max     float32 `json:"max"`
+}
+
+type Team struct {
+	Name string `json:"name"`
+}
+
+// Ownership ties an entity to the player it belongs to, such as the shooter
+// of a projectile.
+type Ownership struct {
+	OwnerID uuid.UUID `json:"owner_id"`
+}
+
+// Entity presents the player as an entity.
+func (p Player) Entity() Entity {
+	entity := Entity{
+		ID:       p.ID,
+		Kind:     EntityPlayer,
+		Zone:     p.Zone,
+		Position: &Position{X: p.X, Y: p.Y, VX: p.VX, VY: p.VY},
+		Health:   &Health{Current: p.Health, Max: MaxHealth},
+	}
+	if p.Team != "" {
+		entity.Team = &Team{Name: p.Team}
+	}
+	return entity
+}
+
+// SnapshotAt returns the entity as sent in a snapshot taken at now, moved
+// along its velocity. It reports false if the entity has expired or moved
+// off a playfield that does not wrap.
+func (e Entity) SnapshotAt(world WorldBounds, now time.Time) (Entity, bool) {
+	if e.ExpiresAt != nil && !now.Before(*e.ExpiresAt) {
+		return Entity{}, false
+	}
+	if e.Position == nil {
+		return e, true
+	}
+
+	position := *e.Position
+	if position.VX != 0 || position.VY != 0 {
+		elapsed := float32(now.Sub(e.movedAt).Seconds())
+		x, y := position.X+position.VX*elapsed, position.Y+position.VY*elapsed
+		if !world.Wrap && !world.Contains(x, y) {
+			return Entity{}, false
+		}
+		position.X, position.Y = world.Place(x, y)
+	}
+	e.Position = &position
+	return e, true
+}
+
+// Moving reports whether a snapshot shows the entity in motion.
+func (e Entity) Moving() bool {
+	return e.Position != nil && (e.Position.VX != 0 || e.Position.VY != 0)
+}
+
+// EntityRegistry holds the NPCs, items and projectiles of a game, shared by
+// its transports. Each spawn and removal is announced to the players of the
+// entity's zone through the attached function.
+type EntityRegistry struct {
+	world  WorldBounds
+	random *SimRand
+
+	mu       sync.RWMutex
+	entities map[uuid.UUID]*Entity
+	changed  func(zone string, message *GameMessage)
+}
+
+func NewEntityRegistry(world WorldBounds, random *SimRand) *EntityRegistry {
+	return &EntityRegistry{
+		world:    world,
+		random:   random,
+		entities: make(map[uuid.UUID]*Entity),
+	}
+}
+
+// Attach sets how spawns and removals reach the players of a zone. The
+// function must take its own locks.
+func (er *EntityRegistry) Attach(changed func(zone string, message *GameMessage)) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	er.changed = changed
+}
+
+// Spawn adds an entity under a new ID and returns it.
+func (er *EntityRegistry) Spawn(entity Entity) (Entity, error) {
+	if !entityKinds[entity.Kind] {
+		return Entity{}, fmt.Errorf("unknown entity kind %q", entity.Kind)
+	}
+	if entity.Zone == "" {
+		return Entity{}, fmt.Errorf("zone is required")
+	}
+
+	now := time.Now()
+	entity.ID = er.random.NewID()
+	entity.movedAt = now
+
+	er.mu.Lock()
+	expired := er.pruneLocked(now)
+	er.entities[entity.ID] = &entity
+	changed := er.changed
+	er.mu.Unlock()
+
+	er.announceRemoved(changed, expired)
+	if changed != nil {
+		spawned := NewEntitySpawnedMessage(entity)
+		changed(entity.Zone, &spawned)
+	}
+	return entity, nil
+}
+
+// Remove takes an entity out of the world. It reports false if there is no
+// such entity.
+func (er *EntityRegistry) Remove(id uuid.UUID) bool {
+	er.mu.Lock()
+	entity, exists := er.entities[id]
+	delete(er.entities, id)
+	changed := er.changed
+	er.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	er.announceRemoved(changed, []Entity{*entity})
+	return true
+}
+
+// Prune removes the entities that have expired or left the playfield.
+func (er *EntityRegistry) Prune(now time.Time) {
+	er.mu.Lock()
+	expired := er.pruneLocked(now)
+	changed := er.changed
+	er.mu.Unlock()
+
+	er.announceRemoved(changed, expired)
+}
+
+func (er *EntityRegistry) pruneLocked(now time.Time) []Entity {
+	var expired []Entity
+	for id, entity := range er.entities {
+		if _, ok := entity.SnapshotAt(er.world, now); !ok {
+			expired = append(expired, *entity)
+			delete(er.entities, id)
+		}
+	}
+	return expired
+}
+
+func (er *EntityRegistry) announceRemoved(changed func(zone string, message *GameMessage), entities []Entity) {
+	if changed == nil {
+		return
+	}
+	for _, entity := range entities {
+		removed := NewEntityRemovedMessage(entity.ID)
+		changed(entity.Zone, &removed)
+	}
+}
+
+// InZone returns the entities of a zone as seen at now.
+func (er *EntityRegistry) InZone(zone string, now time.Time) []Entity {
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+
+	var entities []Entity
+	for _, entity := range er.entities {
+		if entity.Zone != zone {
+			continue
+		}
+		if snapshot, ok := entity.SnapshotAt(er.world, now); ok {
+			entities = append(entities, snapshot)
+		}
+	}
+	return entities
+}
+
+// All returns every entity as seen at now, ordered by zone and kind.
+func (er *EntityRegistry) All(now time.Time) []Entity {
+	er.mu.RLock()
+	entities := make([]Entity, 0, len(er.entities))
+	for _, entity := range er.entities {
+		if snapshot, ok := entity.SnapshotAt(er.world, now); ok {
+			entities = append(entities, snapshot)
+		}
+	}
+	er.mu.RUnlock()
+
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].Zone != entities[j].Zone {
+			return entities[i].Zone < entities[j].Zone
+		}
+		return entities[i].Kind < entities[j].Kind
+	})
+	return entities
+}
+
+// Restore replaces the entities with those of a saved world, moving from
+// now, and returns how many were restored.
+func (er *EntityRegistry) Restore(entities []Entity, now time.Time) int {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	er.entities = make(map[uuid.UUID]*Entity, len(entities))
+	for _, entity := range entities {
+		if !entityKinds[entity.Kind] {
+			continue
+		}
+		entity := entity
+		entity.movedAt = now
+		if _, ok := entity.SnapshotAt(er.world, now); ok {
+			er.entities[entity.ID] = &entity
+		}
+	}
+	return len(er.entities)
+}
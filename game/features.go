@@ -0,0 +1,73 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Features selects which optional subsystems run, so the same binary can
+// serve as a minimal relay or a full game server.
+type Features struct {
+	Chat            bool // Chat, Whisper, PartyChat and GuildChat messages
+	MovePersistence bool // saving positions and logging move events
+	NPCs            bool // server-driven NPCs in the game loop
+	Matchmaking     bool // match rooms, timed matches and rating updates
+}
+
+var featureNames = []string{"chat", "move_persistence", "npcs", "matchmaking"}
+
+// ParseFeatures starts from a profile ("full" or "relay") and turns off each
+// feature named in the comma-separated disabled list.
+func ParseFeatures(profile, disabled string) (Features, error) {
+	var features Features
+	switch profile {
+	case "", "full":
+		features = Features{Chat: true, MovePersistence: true, NPCs: true, Matchmaking: true}
+	case "relay":
+		features = Features{}
+	default:
+		return Features{}, fmt.Errorf("SERVER_PROFILE must be \"full\" or \"relay\", got %q", profile)
+	}
+
+	for _, name := range strings.Split(disabled, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "chat":
+			features.Chat = false
+		case "move_persistence":
+			features.MovePersistence = false
+		case "npcs":
+			features.NPCs = false
+		case "matchmaking":
+			features.Matchmaking = false
+		default:
+			return Features{}, fmt.Errorf("unknown feature %q in DISABLED_FEATURES (known: %s)", name, strings.Join(featureNames, ", "))
+		}
+	}
+	return features, nil
+}
+
+// Allows reports whether a client message type is served with the current
+// features.
+func (f Features) Allows(messageType string) bool {
+	switch messageType {
+	case "Chat", "Whisper", "PartyChat", "GuildChat":
+		return f.Chat
+	case "CreateRoom", "PauseRoom", "ResumeRoom":
+		return f.Matchmaking
+	}
+	return true
+}
+
+func (f Features) String() string {
+	var enabled []string
+	for i, on := range []bool{f.Chat, f.MovePersistence, f.NPCs, f.Matchmaking} {
+		if on {
+			enabled = append(enabled, featureNames[i])
+		}
+	}
+	if len(enabled) == 0 {
+		return "none"
+	}
+	return strings.Join(enabled, ",")
+}
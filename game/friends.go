@@ -0,0 +1,162 @@
+package game
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// PlayerDirectory lets transport-independent subsystems find and message
+// connected players.
+type PlayerDirectory interface {
+	IsOnline(playerID uuid.UUID) bool
+	SendToPlayer(playerID uuid.UUID, message *GameMessage) bool
+}
+
+// FriendManager implements the friend list flows and presence notifications
+// shared by the WebSocket and UDP servers.
+type FriendManager struct {
+	database store.Store
+}
+
+func NewFriendManager(database store.Store) *FriendManager {
+	return &FriendManager{database: database}
+}
+
+func isFriendMessage(messageType string) bool {
+	switch messageType {
+	case "FriendAdd", "FriendAccept", "FriendRemove", "FriendListRequest":
+		return true
+	}
+	return false
+}
+
+// HandleMessage processes a friend-related message from playerID, replying
+// and notifying other players through dir.
+func (fm *FriendManager) HandleMessage(ctx context.Context, playerID uuid.UUID, playerName string, message *GameMessage, dir PlayerDirectory) {
+	if message.Type == "FriendListRequest" {
+		fm.sendFriendList(ctx, playerID, dir)
+		return
+	}
+
+	var data FriendData
+	if err := DecodeMessageData(message.Data, &data); err != nil || data.FriendID == uuid.Nil {
+		fm.sendError(playerID, "friend_id is required", dir)
+		return
+	}
+	if data.FriendID == playerID {
+		fm.sendError(playerID, "You cannot add yourself as a friend", dir)
+		return
+	}
+
+	switch message.Type {
+	case "FriendAdd":
+		friend, err := fm.database.GetPlayer(ctx, data.FriendID)
+		if err != nil {
+			logrus.Errorf("Failed to look up player %s: %v", data.FriendID, err)
+			fm.sendError(playerID, "Failed to add friend", dir)
+			return
+		}
+		if friend == nil {
+			fm.sendError(playerID, "Unknown player", dir)
+			return
+		}
+
+		status, err := fm.database.CreateFriendRequest(ctx, playerID, data.FriendID)
+		if err != nil {
+			logrus.Errorf("Failed to create friend request: %v", err)
+			fm.sendError(playerID, "Failed to add friend", dir)
+			return
+		}
+
+		if status == "accepted" {
+			fm.notifyAccepted(playerID, playerName, data.FriendID, dir)
+		} else {
+			requestMsg := NewFriendPresenceMessage("FriendRequest", playerID, playerName)
+			dir.SendToPlayer(data.FriendID, &requestMsg)
+		}
+
+	case "FriendAccept":
+		accepted, err := fm.database.AcceptFriendRequest(ctx, data.FriendID, playerID)
+		if err != nil {
+			logrus.Errorf("Failed to accept friend request: %v", err)
+			fm.sendError(playerID, "Failed to accept friend request", dir)
+			return
+		}
+		if !accepted {
+			fm.sendError(playerID, "No pending friend request from that player", dir)
+			return
+		}
+		fm.notifyAccepted(playerID, playerName, data.FriendID, dir)
+
+	case "FriendRemove":
+		if err := fm.database.DeleteFriendship(ctx, playerID, data.FriendID); err != nil {
+			logrus.Errorf("Failed to remove friend: %v", err)
+			fm.sendError(playerID, "Failed to remove friend", dir)
+			return
+		}
+	}
+
+	fm.sendFriendList(ctx, playerID, dir)
+}
+
+// notifyAccepted tells the original requester that playerID accepted, and
+// exchanges online presence if both are connected.
+func (fm *FriendManager) notifyAccepted(playerID uuid.UUID, playerName string, friendID uuid.UUID, dir PlayerDirectory) {
+	acceptedMsg := NewFriendPresenceMessage("FriendAccepted", playerID, playerName)
+	if dir.SendToPlayer(friendID, &acceptedMsg) {
+		onlineMsg := NewFriendPresenceMessage("FriendOnline", friendID, "")
+		dir.SendToPlayer(playerID, &onlineMsg)
+	}
+}
+
+func (fm *FriendManager) sendFriendList(ctx context.Context, playerID uuid.UUID, dir PlayerDirectory) {
+	friends, err := fm.database.GetFriends(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load friends for %s: %v", playerID, err)
+		fm.sendError(playerID, "Failed to load friend list", dir)
+		return
+	}
+
+	entries := make([]FriendEntry, 0, len(friends))
+	for _, friend := range friends {
+		entry := FriendEntry{Friendship: friend}
+		if friend.Status == "accepted" {
+			if friendID, err := uuid.Parse(friend.FriendID); err == nil {
+				entry.Online = dir.IsOnline(friendID)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	listMsg := NewFriendListMessage(entries)
+	dir.SendToPlayer(playerID, &listMsg)
+}
+
+func (fm *FriendManager) sendError(playerID uuid.UUID, text string, dir PlayerDirectory) {
+	errorMsg := NewErrorMessage(text)
+	dir.SendToPlayer(playerID, &errorMsg)
+}
+
+// NotifyPresence pushes FriendOnline or FriendOffline to every connected
+// friend of playerID.
+func (fm *FriendManager) NotifyPresence(ctx context.Context, playerID uuid.UUID, playerName string, online bool, dir PlayerDirectory) {
+	friendIDs, err := fm.database.GetAcceptedFriendIDs(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load friends for presence of %s: %v", playerID, err)
+		return
+	}
+
+	messageType := "FriendOffline"
+	if online {
+		messageType = "FriendOnline"
+	}
+	presenceMsg := NewFriendPresenceMessage(messageType, playerID, playerName)
+
+	for _, friendID := range friendIDs {
+		dir.SendToPlayer(friendID, &presenceMsg)
+	}
+}
@@ -0,0 +1,283 @@
+package game
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// GameEvent is something that happened in a game which subsystems outside
+// the game logic react to, such as saving it or counting it. Subscribers
+// tell the events apart with a type switch.
+type GameEvent interface {
+	// EventName names the event in metrics and logs.
+	EventName() string
+}
+
+// PlayerJoined is published once a player has joined the game.
+type PlayerJoined struct {
+	Player    Player
+	SessionID *int64
+}
+
+// PlayerLeft is published once a player has been removed from the game.
+type PlayerLeft struct {
+	PlayerID  uuid.UUID
+	SessionID *int64
+}
+
+// PlayerMoved is published for every accepted move, at its corrected
+// position.
+type PlayerMoved struct {
+	PlayerID     uuid.UUID
+	SessionID    *int64
+	X, Y         float32
+	VX, VY       float32
+	FromX, FromY float32 // where the server had the player when the move arrived
+	// Logged is whether the move is recorded in player_events. UDP records
+	// one move in ten to keep the log small.
+	Logged bool
+}
+
+// PlayerActed is published for every attack, pickup and use of an ability
+// a player makes, whether or not it had an effect.
+type PlayerActed struct {
+	PlayerID  uuid.UUID
+	SessionID *int64
+	Action    string // "attack", "pickup" or "ability"
+	Hit       bool   // whether an attack, or an ability aimed at a player, did damage
+}
+
+// ItemPickedUp is published for every pickup, naming the entity picked up
+// if the client did.
+type ItemPickedUp struct {
+	PlayerID  uuid.UUID
+	SessionID *int64
+	EntityID  *uuid.UUID
+}
+
+// ChatSent is published for every chat message broadcast, after it was
+// sanitized.
+type ChatSent struct {
+	PlayerID  uuid.UUID
+	SessionID *int64
+	Message   string
+}
+
+// ScoreChanged is published when a player scores.
+type ScoreChanged struct {
+	PlayerID  uuid.UUID
+	SessionID *int64
+	Score     uint32 // the new score
+	Points    int64
+	Reason    string // the action scored, logged as the event type
+}
+
+// MatchEnded is published when a timed match ends. The results have been
+// saved by then.
+type MatchEnded struct {
+	MatchID *int64
+	Results []store.RatingChange
+}
+
+func (PlayerJoined) EventName() string { return "player_joined" }
+func (PlayerLeft) EventName() string   { return "player_left" }
+func (PlayerMoved) EventName() string  { return "player_moved" }
+func (PlayerActed) EventName() string  { return "player_acted" }
+func (ItemPickedUp) EventName() string { return "item_picked_up" }
+func (ChatSent) EventName() string     { return "chat_sent" }
+func (ScoreChanged) EventName() string { return "score_changed" }
+func (MatchEnded) EventName() string   { return "match_ended" }
+
+// EventHandler reacts to a game event.
+type EventHandler func(ctx context.Context, event GameEvent)
+
+// EventBus delivers the events a game publishes to every subscriber.
+// Handlers run on the publisher's goroutine, in the order they subscribed,
+// often with the game's lock held, so they must not call back into the game
+// and must not wait on the database: those that write go through a
+// PersistQueue. A nil *EventBus drops every event.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers []EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe adds a handler for every event published from now on.
+func (b *EventBus) Subscribe(handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish hands an event to every subscriber.
+func (b *EventBus) Publish(ctx context.Context, event GameEvent) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
+
+// persistQueueSize bounds the writes waiting for a game's persistence
+// goroutine.
+const persistQueueSize = 4096
+
+// PersistQueue runs the database writes of a game on a goroutine of its
+// own, in the order they were queued. Handlers run with the game's lock
+// held, and a write may wait on the single database writer's commit or on
+// the write budget, so writing inline would stall every client of the game.
+// When the queue is full, new writes are dropped and counted rather than
+// waited for, as are writes queued after Close. Writes keep the values of the context they were queued with
+// but not its cancellation, since the handler has returned by the time
+// they run.
+type PersistQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	writes chan persistWrite
+	done   chan struct{}
+}
+
+type persistWrite struct {
+	ctx context.Context
+	fn  func(ctx context.Context)
+}
+
+func NewPersistQueue() *PersistQueue {
+	q := &PersistQueue{
+		writes: make(chan persistWrite, persistQueueSize),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Go queues fn to run with a context carrying the values of ctx.
+func (q *PersistQueue) Go(ctx context.Context, fn func(ctx context.Context)) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		metrics.Inc("persist_queue_drops")
+		logrus.Warn("Persistence queue is closed, dropping a write")
+		return
+	}
+	select {
+	case q.writes <- persistWrite{ctx: context.WithoutCancel(ctx), fn: fn}:
+	default:
+		metrics.Inc("persist_queue_drops")
+		logrus.Warn("Persistence queue is full, dropping a write")
+	}
+}
+
+// Handler returns an event handler that runs handler on the queue.
+func (q *PersistQueue) Handler(handler EventHandler) EventHandler {
+	return func(ctx context.Context, event GameEvent) {
+		q.Go(ctx, func(ctx context.Context) { handler(ctx, event) })
+	}
+}
+
+// Close stops taking writes and waits for those already queued to finish,
+// so that the database can be closed after it returns.
+func (q *PersistQueue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.writes)
+	}
+	q.mu.Unlock()
+	<-q.done
+}
+
+func (q *PersistQueue) run() {
+	defer close(q.done)
+	for write := range q.writes {
+		metrics.SetGauge("persist_queue_depth", float64(len(q.writes)))
+		write.fn(write.ctx)
+	}
+}
+
+// NewGameEventBus returns the bus of a game with the subscribers every game
+// has: persistence, leaderboards, metrics and the anti-cheat, if enabled.
+// Persistence and leaderboards write on persist.
+func NewGameEventBus(database store.Store, features Features, persist *PersistQueue, leaderboards *Leaderboards, anticheat *AntiCheat) *EventBus {
+	bus := NewEventBus()
+	bus.Subscribe(persist.Handler(persistEvents(database, features)))
+	bus.Subscribe(persist.Handler(leaderboards.HandleEvent))
+	bus.Subscribe(countEvent)
+	if anticheat != nil {
+		bus.Subscribe(anticheat.HandleEvent)
+	}
+	return bus
+}
+
+// persistEvents saves players, positions, scores and chat and records the
+// events in player_events.
+func persistEvents(database store.Store, features Features) EventHandler {
+	logEvent := func(ctx context.Context, playerID uuid.UUID, sessionID *int64, eventType string, message interface{}) {
+		if err := database.LogEvent(ctx, playerID, sessionID, eventType, message); err != nil {
+			logrus.Errorf("Failed to log %s event: %v", eventType, err)
+		}
+	}
+
+	return func(ctx context.Context, event GameEvent) {
+		switch event := event.(type) {
+		case PlayerJoined:
+			player := store.PlayerState{ID: event.Player.ID, Name: event.Player.Name, X: event.Player.X, Y: event.Player.Y, Health: event.Player.Health, Score: event.Player.Score}
+			if err := database.CreateOrUpdatePlayer(ctx, player); err != nil {
+				logrus.Errorf("Failed to save player to database: %v", err)
+			}
+			joinMsg := NewPlayerJoinMessage(event.Player.ID, event.Player.Name)
+			logEvent(ctx, event.Player.ID, event.SessionID, "join", &joinMsg)
+
+		case PlayerLeft:
+			leaveMsg := NewPlayerLeaveMessage(event.PlayerID)
+			logEvent(ctx, event.PlayerID, event.SessionID, "leave", &leaveMsg)
+
+		case PlayerMoved:
+			if !features.MovePersistence {
+				return
+			}
+			if err := database.UpdatePlayerPosition(ctx, event.PlayerID, event.X, event.Y); err != nil {
+				logrus.Errorf("Failed to update player position in database: %v", err)
+			}
+			if event.Logged {
+				moveMsg := NewPlayerMoveMessage(event.PlayerID, event.X, event.Y, event.VX, event.VY)
+				logEvent(ctx, event.PlayerID, event.SessionID, "move", &moveMsg)
+			}
+
+		case ChatSent:
+			if err := database.SaveChatMessage(ctx, event.PlayerID, event.SessionID, event.Message); err != nil {
+				logrus.Errorf("Failed to save chat message to database: %v", err)
+			}
+			chatMsg := NewChatMessage(event.PlayerID, event.Message)
+			logEvent(ctx, event.PlayerID, event.SessionID, "chat", &chatMsg)
+
+		case ScoreChanged:
+			if err := database.UpdatePlayerScore(ctx, event.PlayerID, event.Score); err != nil {
+				logrus.Errorf("Failed to update player score in database: %v", err)
+			}
+			logEvent(ctx, event.PlayerID, event.SessionID, event.Reason, nil)
+		}
+	}
+}
+
+// countEvent counts every event in the metrics, and the points scored.
+// Points lost to penalties are not subtracted.
+func countEvent(ctx context.Context, event GameEvent) {
+	metrics.Inc("game_events_" + event.EventName())
+	if scored, ok := event.(ScoreChanged); ok && scored.Points > 0 {
+		metrics.Add("game_points_scored", scored.Points)
+	}
+}
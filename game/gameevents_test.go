@@ -0,0 +1,36 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPersistQueueClose checks that Close waits for the queued writes and
+// that writes queued after it are dropped.
+func TestPersistQueueClose(t *testing.T) {
+	q := NewPersistQueue()
+	var ran []int
+	for i := 0; i < 10; i++ {
+		i := i
+		q.Go(context.Background(), func(ctx context.Context) {
+			time.Sleep(time.Millisecond)
+			ran = append(ran, i)
+		})
+	}
+	q.Close()
+	if len(ran) != 10 {
+		t.Fatalf("%d writes ran before Close returned, want 10", len(ran))
+	}
+	for i, n := range ran {
+		if n != i {
+			t.Fatalf("writes ran in the order %v", ran)
+		}
+	}
+
+	q.Go(context.Background(), func(ctx context.Context) { ran = append(ran, -1) })
+	q.Close()
+	if len(ran) != 10 {
+		t.Errorf("a write queued after Close ran")
+	}
+}
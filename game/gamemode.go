@@ -0,0 +1,193 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const errorCodeInvalidGameMode = "invalid_game_mode"
+
+// ModeTickInterval is how often the UDP server, which has no game loop,
+// plays the game modes.
+const ModeTickInterval = 100 * time.Millisecond
+
+// defaultFieldSize stands in for an unbounded axis of the playfield when
+// placing flags and hills.
+const defaultFieldSize = 1000
+
+// GameMode is the objective played in a room. Its methods are called one at
+// a time, each with the room as the mode sees it at that moment.
+type GameMode interface {
+	// Init starts a round with every score at zero.
+	Init(arena ModeArena)
+	// OnTick advances the round to now.
+	OnTick(arena ModeArena, now time.Time)
+	// OnPlayerAction handles a PlayerAction that attack and pickup do not
+	// cover, reporting false if the mode does not know the action.
+	OnPlayerAction(arena ModeArena, player Player, action string, data interface{}) bool
+	// CheckWin returns the team or player that has won the round, if any.
+	CheckWin() (string, bool)
+}
+
+// ModeArena is a room as its game mode sees it, provided by the transport.
+type ModeArena interface {
+	Room() string
+	// Players returns the members of the room on the mode's field, as of
+	// now.
+	Players() []Player
+	SetTeam(playerID uuid.UUID, team string)
+	AwardPoints(playerID uuid.UUID, points int64, reason string)
+	// Announce sends a message to every member of the room.
+	Announce(message *GameMessage)
+}
+
+// ModeField is where modes are played: the zone new players start in,
+// spanning the playfield.
+type ModeField struct {
+	Zone   string
+	Width  float32
+	Height float32
+}
+
+func NewModeField(config *Config, zones *Zones) ModeField {
+	world := NewWorldBounds(config)
+	field := ModeField{Zone: zones.zones[0].Name, Width: world.Width, Height: world.Height}
+	if field.Width == 0 {
+		field.Width = defaultFieldSize
+	}
+	if field.Height == 0 {
+		field.Height = defaultFieldSize
+	}
+	return field
+}
+
+var gameModes = map[string]func(field ModeField) GameMode{
+	"ctf":  newCaptureTheFlag,
+	"koth": newKingOfTheHill,
+}
+
+// ParseGameMode checks the name of a game mode. An empty name plays no mode.
+func ParseGameMode(name string) error {
+	if _, known := gameModes[name]; known || name == "" {
+		return nil
+	}
+	names := make([]string, 0, len(gameModes))
+	for known := range gameModes {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown game mode %q (known: %s)", name, strings.Join(names, ", "))
+}
+
+type roomMode struct {
+	name string
+	mode GameMode
+}
+
+// RoomModes runs the game mode of each room, GAME_MODE unless the room was
+// opened with CreateRoom. Without matchmaking every player is in the
+// default room, which plays GAME_MODE.
+type RoomModes struct {
+	field       ModeField
+	defaultMode string
+
+	mu    sync.Mutex
+	rooms map[string]*roomMode
+}
+
+func NewRoomModes(config *Config, field ModeField) *RoomModes {
+	return &RoomModes{
+		field:       field,
+		defaultMode: config.GameMode,
+		rooms:       make(map[string]*roomMode),
+	}
+}
+
+// Field returns where the modes are played.
+func (rm *RoomModes) Field() ModeField {
+	return rm.field
+}
+
+// Rooms lists the rooms that may play a mode.
+func (rm *RoomModes) Rooms(matchmaker *Matchmaker) []RoomInfo {
+	if matchmaker == nil {
+		return []RoomInfo{{ID: DefaultRoom, Mode: rm.defaultMode}}
+	}
+	return matchmaker.Rooms()
+}
+
+// Tick runs the mode of each room that runs on tick, starting it in rooms
+// new to it and forgetting rooms that have closed. A round that has been won
+// is announced and the next one starts at once.
+func (rm *RoomModes) Tick(rooms []RoomInfo, tick uint64, arena func(room string) ModeArena, now time.Time) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	playing := make(map[string]bool, len(rooms))
+	for _, room := range rooms {
+		if room.Mode == "" {
+			continue
+		}
+		playing[room.ID] = true
+		if !room.Ticks(tick) {
+			continue
+		}
+
+		roomArena := arena(room.ID)
+		running, exists := rm.rooms[room.ID]
+		if !exists || running.name != room.Mode {
+			running = &roomMode{name: room.Mode, mode: gameModes[room.Mode](rm.field)}
+			rm.rooms[room.ID] = running
+			running.mode.Init(roomArena)
+			logrus.Infof("Room %s is playing %s", room.ID, room.Mode)
+		}
+
+		running.mode.OnTick(roomArena, now)
+		if winner, won := running.mode.CheckWin(); won {
+			logrus.Infof("%s won the %s round in room %s", winner, room.Mode, room.ID)
+			wonMessage := NewGameModeWonMessage(room.ID, room.Mode, winner)
+			roomArena.Announce(&wonMessage)
+			running.mode.Init(roomArena)
+		}
+	}
+
+	for roomID := range rm.rooms {
+		if !playing[roomID] {
+			delete(rm.rooms, roomID)
+		}
+	}
+}
+
+// PlayerAction offers an action to the mode of the arena's room, reporting
+// false if the room plays no mode or the mode does not know the action.
+func (rm *RoomModes) PlayerAction(arena ModeArena, player Player, action string, data interface{}) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	running, exists := rm.rooms[arena.Room()]
+	if !exists {
+		return false
+	}
+	return running.mode.OnPlayerAction(arena, player, action, data)
+}
+
+// RoomOf returns the room a player plays in, the default room without
+// matchmaking.
+func RoomOf(matchmaker *Matchmaker, playerID uuid.UUID) string {
+	if matchmaker == nil {
+		return DefaultRoom
+	}
+	room, _ := matchmaker.RoomOf(playerID)
+	return room
+}
+
+func withinRadius(p Player, x, y, radius float32) bool {
+	dx, dy := p.X-x, p.Y-y
+	return dx*dx+dy*dy <= radius*radius
+}
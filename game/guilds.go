@@ -0,0 +1,396 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// Limits on guilds. Tags are stored upper case.
+const (
+	minGuildNameLength = 3
+	maxGuildNameLength = 24
+	minGuildTagLength  = 2
+	maxGuildTagLength  = 5
+	maxGuildSize       = 50
+)
+
+const (
+	errorCodeInvalidGuild = "invalid_guild"
+	errorCodeGuildTaken   = "guild_taken"
+)
+
+// ValidateGuildName checks the name of a new guild.
+func ValidateGuildName(name string) error {
+	if len(name) < minGuildNameLength || len(name) > maxGuildNameLength {
+		return &InputError{Code: errorCodeInvalidGuild, Message: fmt.Sprintf("guild names must be %d to %d characters", minGuildNameLength, maxGuildNameLength)}
+	}
+	for _, r := range name {
+		if !isNameRune(r) && r != ' ' {
+			return &InputError{Code: errorCodeInvalidGuild, Message: "guild names may only contain letters, digits, underscores and spaces"}
+		}
+	}
+	return nil
+}
+
+// ValidateGuildTag checks the tag of a new guild.
+func ValidateGuildTag(tag string) error {
+	if len(tag) < minGuildTagLength || len(tag) > maxGuildTagLength {
+		return &InputError{Code: errorCodeInvalidGuild, Message: fmt.Sprintf("guild tags must be %d to %d characters", minGuildTagLength, maxGuildTagLength)}
+	}
+	for _, r := range tag {
+		if !isNameRune(r) || r == '_' {
+			return &InputError{Code: errorCodeInvalidGuild, Message: "guild tags may only contain letters and digits"}
+		}
+	}
+	return nil
+}
+
+// GuildManager implements guild membership, ranks and guild chat for the
+// WebSocket and UDP servers. Guilds are stored, so they outlive connections,
+// and a member's tag is shown in their Player data.
+type GuildManager struct {
+	database store.Store
+
+	mu sync.Mutex // serializes membership changes on this server
+}
+
+func NewGuildManager(database store.Store) *GuildManager {
+	return &GuildManager{database: database}
+}
+
+// Load gives a joining player their guild's tag.
+func (gm *GuildManager) Load(ctx context.Context, player *Player) {
+	guild, _, err := gm.database.GetPlayerGuild(ctx, player.ID)
+	if err != nil {
+		logrus.Errorf("Failed to load guild of %s: %v", player.ID, err)
+		return
+	}
+	player.GuildTag = ""
+	if guild != nil {
+		player.GuildTag = guild.Tag
+	}
+}
+
+// HandleMessage processes a guild message from playerID, replying and
+// notifying other members through dir. setTag changes the tag shown with
+// the player when they create, join or leave a guild.
+func (gm *GuildManager) HandleMessage(ctx context.Context, playerID uuid.UUID, playerName string, message *GameMessage, setTag func(tag string), dir PlayerDirectory) {
+	switch message.Type {
+	case "GuildCreate":
+		var data GuildCreateData
+		if err := DecodeMessageData(message.Data, &data); err != nil {
+			gm.sendError(playerID, "name and tag are required", dir)
+			return
+		}
+		gm.create(ctx, playerID, strings.TrimSpace(data.Name), strings.ToUpper(strings.TrimSpace(data.Tag)), setTag, dir)
+
+	case "GuildJoin":
+		var data GuildJoinData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.Tag == "" {
+			gm.sendError(playerID, "tag is required", dir)
+			return
+		}
+		gm.join(ctx, playerID, strings.TrimSpace(data.Tag), setTag, dir)
+
+	case "GuildLeave":
+		gm.leave(ctx, playerID, setTag, dir)
+
+	case "GuildPromote":
+		var data GuildPromoteData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.PlayerID == uuid.Nil {
+			gm.sendError(playerID, "player_id is required", dir)
+			return
+		}
+		gm.promote(ctx, playerID, data.PlayerID, dir)
+
+	case "GuildInfoRequest":
+		guild, _, err := gm.database.GetPlayerGuild(ctx, playerID)
+		if err != nil {
+			logrus.Errorf("Failed to load guild of %s: %v", playerID, err)
+			gm.sendError(playerID, "Failed to load guild", dir)
+			return
+		}
+		if guild == nil {
+			leftMsg := NewGuildLeftMessage("")
+			dir.SendToPlayer(playerID, &leftMsg)
+			return
+		}
+		gm.sendUpdate(ctx, guild, []uuid.UUID{playerID}, dir)
+
+	case "GuildChat":
+		var data GuildChatData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.Message == "" {
+			gm.sendError(playerID, "message is required", dir)
+			return
+		}
+		text, err := SanitizeChat(data.Message)
+		if err != nil {
+			errorMsg := NewInputErrorMessage(err)
+			dir.SendToPlayer(playerID, &errorMsg)
+			return
+		}
+		gm.chat(ctx, playerID, playerName, text, dir)
+
+	case "GuildLeaderboardRequest":
+		var data GuildLeaderboardRequestData
+		if err := DecodeMessageData(message.Data, &data); err != nil {
+			gm.sendError(playerID, "Invalid guild leaderboard request", dir)
+			return
+		}
+		if data.Limit <= 0 || data.Limit > maxAPILimit {
+			data.Limit = defaultAPILimit
+		}
+		scores, err := gm.database.GetGuildLeaderboard(ctx, data.Limit)
+		if err != nil {
+			logrus.Errorf("Failed to load guild leaderboard: %v", err)
+			gm.sendError(playerID, "Failed to load guild leaderboard", dir)
+			return
+		}
+		leaderboardMsg := NewGuildLeaderboardMessage(guildLeaderboardEntries(scores))
+		dir.SendToPlayer(playerID, &leaderboardMsg)
+	}
+}
+
+func (gm *GuildManager) create(ctx context.Context, playerID uuid.UUID, name, tag string, setTag func(tag string), dir PlayerDirectory) {
+	if err := ValidateGuildName(name); err != nil {
+		gm.sendInputError(playerID, err, dir)
+		return
+	}
+	if err := ValidateGuildTag(tag); err != nil {
+		gm.sendInputError(playerID, err, dir)
+		return
+	}
+
+	guild := &store.Guild{ID: uuid.New().String(), Name: name, Tag: tag}
+	gm.mu.Lock()
+	err := storeInputError(gm.database.CreateGuild(ctx, guild, playerID))
+	gm.mu.Unlock()
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		gm.sendInputError(playerID, err, dir)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to create guild: %v", err)
+		gm.sendError(playerID, "Failed to create guild", dir)
+		return
+	}
+
+	setTag(guild.Tag)
+	gm.broadcastUpdate(ctx, guild, dir)
+}
+
+func (gm *GuildManager) join(ctx context.Context, playerID uuid.UUID, tag string, setTag func(tag string), dir PlayerDirectory) {
+	guild, err := gm.database.GetGuildByTag(ctx, tag)
+	if err != nil {
+		logrus.Errorf("Failed to look up guild %s: %v", tag, err)
+		gm.sendError(playerID, "Failed to join guild", dir)
+		return
+	}
+	if guild == nil {
+		gm.sendError(playerID, "Unknown guild", dir)
+		return
+	}
+
+	gm.mu.Lock()
+	members, err := gm.database.GetGuildMembers(ctx, guild.ID)
+	if err == nil && len(members) >= maxGuildSize {
+		gm.mu.Unlock()
+		gm.sendError(playerID, "That guild is full", dir)
+		return
+	}
+	if err == nil {
+		err = storeInputError(gm.database.AddGuildMember(ctx, guild.ID, playerID))
+	}
+	gm.mu.Unlock()
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		gm.sendInputError(playerID, err, dir)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to join guild %s: %v", guild.ID, err)
+		gm.sendError(playerID, "Failed to join guild", dir)
+		return
+	}
+
+	logrus.Infof("Player %s joined guild %s", playerID, guild.ID)
+	setTag(guild.Tag)
+	gm.broadcastUpdate(ctx, guild, dir)
+}
+
+func (gm *GuildManager) leave(ctx context.Context, playerID uuid.UUID, setTag func(tag string), dir PlayerDirectory) {
+	gm.mu.Lock()
+	guild, _, err := gm.database.GetPlayerGuild(ctx, playerID)
+	if err == nil && guild != nil {
+		_, err = gm.database.LeaveGuild(ctx, playerID)
+	}
+	gm.mu.Unlock()
+	if err != nil {
+		logrus.Errorf("Failed to leave guild: %v", err)
+		gm.sendError(playerID, "Failed to leave guild", dir)
+		return
+	}
+	if guild == nil {
+		gm.sendError(playerID, "You are not in a guild", dir)
+		return
+	}
+
+	logrus.Infof("Player %s left guild %s", playerID, guild.ID)
+	setTag("")
+	leftMsg := NewGuildLeftMessage(guild.ID)
+	dir.SendToPlayer(playerID, &leftMsg)
+	gm.broadcastUpdate(ctx, guild, dir)
+}
+
+// promote makes a member an officer. Promoting an officer hands them the
+// leadership, and the leader becomes an officer.
+func (gm *GuildManager) promote(ctx context.Context, playerID, targetID uuid.UUID, dir PlayerDirectory) {
+	if targetID == playerID {
+		gm.sendError(playerID, "You cannot promote yourself", dir)
+		return
+	}
+
+	gm.mu.Lock()
+	guild, errText, err := gm.promoteLocked(ctx, playerID, targetID)
+	gm.mu.Unlock()
+	if err != nil {
+		logrus.Errorf("Failed to promote guild member: %v", err)
+		gm.sendError(playerID, "Failed to promote guild member", dir)
+		return
+	}
+	if errText != "" {
+		gm.sendError(playerID, errText, dir)
+		return
+	}
+
+	gm.broadcastUpdate(ctx, guild, dir)
+}
+
+// promoteLocked requires gm.mu to be held by the caller. It returns the text
+// of an error to show the player if the promotion is not allowed.
+func (gm *GuildManager) promoteLocked(ctx context.Context, playerID, targetID uuid.UUID) (*store.Guild, string, error) {
+	guild, rank, err := gm.database.GetPlayerGuild(ctx, playerID)
+	if err != nil {
+		return nil, "", err
+	}
+	if guild == nil {
+		return nil, "You are not in a guild", nil
+	}
+	if rank != store.GuildRankLeader {
+		return nil, "Only the guild leader can promote members", nil
+	}
+
+	targetGuild, targetRank, err := gm.database.GetPlayerGuild(ctx, targetID)
+	if err != nil {
+		return nil, "", err
+	}
+	if targetGuild == nil || targetGuild.ID != guild.ID {
+		return nil, "That player is not in your guild", nil
+	}
+
+	ranks := map[uuid.UUID]string{targetID: store.GuildRankOfficer}
+	if targetRank == store.GuildRankOfficer {
+		ranks = map[uuid.UUID]string{targetID: store.GuildRankLeader, playerID: store.GuildRankOfficer}
+	}
+	if err := gm.database.SetGuildRanks(ctx, guild.ID, ranks); err != nil {
+		return nil, "", err
+	}
+	logrus.Infof("Player %s promoted %s to %s in guild %s", playerID, targetID, ranks[targetID], guild.ID)
+	return guild, "", nil
+}
+
+func (gm *GuildManager) chat(ctx context.Context, playerID uuid.UUID, playerName, text string, dir PlayerDirectory) {
+	guild, _, err := gm.database.GetPlayerGuild(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load guild of %s: %v", playerID, err)
+		gm.sendError(playerID, "Failed to send guild chat", dir)
+		return
+	}
+	if guild == nil {
+		gm.sendError(playerID, "You are not in a guild", dir)
+		return
+	}
+
+	memberIDs, _, err := gm.members(ctx, guild.ID, dir)
+	if err != nil {
+		logrus.Errorf("Failed to load members of guild %s: %v", guild.ID, err)
+		gm.sendError(playerID, "Failed to send guild chat", dir)
+		return
+	}
+
+	chatMsg := NewGuildChatMessage(guild.ID, playerID, playerName, text)
+	for _, memberID := range memberIDs {
+		dir.SendToPlayer(memberID, &chatMsg)
+	}
+}
+
+// members returns the IDs of a guild's members and their entries in a
+// GuildUpdate.
+func (gm *GuildManager) members(ctx context.Context, guildID string, dir PlayerDirectory) ([]uuid.UUID, []GuildMemberEntry, error) {
+	members, err := gm.database.GetGuildMembers(ctx, guildID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	memberIDs := make([]uuid.UUID, 0, len(members))
+	entries := make([]GuildMemberEntry, 0, len(members))
+	for _, member := range members {
+		memberID, err := uuid.Parse(member.PlayerID)
+		if err != nil {
+			continue
+		}
+		memberIDs = append(memberIDs, memberID)
+		entries = append(entries, GuildMemberEntry{GuildMember: member, Online: dir.IsOnline(memberID)})
+	}
+	return memberIDs, entries, nil
+}
+
+// broadcastUpdate sends the guild's members to every member.
+func (gm *GuildManager) broadcastUpdate(ctx context.Context, guild *store.Guild, dir PlayerDirectory) {
+	gm.sendUpdate(ctx, guild, nil, dir)
+}
+
+// sendUpdate sends the guild's members to recipients, or to every member
+// if recipients is nil.
+func (gm *GuildManager) sendUpdate(ctx context.Context, guild *store.Guild, recipients []uuid.UUID, dir PlayerDirectory) {
+	memberIDs, entries, err := gm.members(ctx, guild.ID, dir)
+	if err != nil {
+		logrus.Errorf("Failed to load members of guild %s: %v", guild.ID, err)
+		return
+	}
+	if recipients == nil {
+		recipients = memberIDs
+	}
+
+	update := NewGuildUpdateMessage(*guild, entries)
+	for _, recipient := range recipients {
+		dir.SendToPlayer(recipient, &update)
+	}
+}
+
+func guildLeaderboardEntries(scores []store.GuildScore) []GuildLeaderboardEntry {
+	entries := make([]GuildLeaderboardEntry, 0, len(scores))
+	for i, score := range scores {
+		entries = append(entries, GuildLeaderboardEntry{Rank: i + 1, GuildScore: score})
+	}
+	return entries
+}
+
+func (gm *GuildManager) sendInputError(playerID uuid.UUID, err error, dir PlayerDirectory) {
+	errorMsg := NewInputErrorMessage(err)
+	dir.SendToPlayer(playerID, &errorMsg)
+}
+
+func (gm *GuildManager) sendError(playerID uuid.UUID, text string, dir PlayerDirectory) {
+	errorMsg := NewErrorMessage(text)
+	dir.SendToPlayer(playerID, &errorMsg)
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"online-server-go/store"
+)
+
+// Limits on what players type. Account usernames name their player in game.
+// Guests are named Player_ and the start of their ID, a prefix usernames may
+// not take so that no account passes for a guest.
+const (
+	minNameLength   = 3
+	maxNameLength   = 24
+	guestNamePrefix = "player_"
+	maxChatLength   = 256 // characters, after control characters are stripped
+)
+
+// Codes of rejected input, sent in the code field of Error messages.
+const (
+	errorCodeInvalidName = "invalid_name"
+	errorCodeNameTaken   = "name_taken"
+	errorCodeInvalidChat = "invalid_chat"
+	errorCodeChatTooLong = "chat_too_long"
+	errorCodeMuted       = "muted"
+)
+
+// InputError rejects a name or chat message. Clients branch on Code and show
+// Message.
+type InputError struct {
+	Code    string
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return e.Message
+}
+
+// The store's errors rejecting what a player asked for, as the InputErrors
+// the player is shown.
+var (
+	errUsernameTaken  error = &InputError{Code: errorCodeNameTaken, Message: store.ErrUsernameTaken.Error()}
+	errGuildTaken     error = &InputError{Code: errorCodeGuildTaken, Message: store.ErrGuildTaken.Error()}
+	errAlreadyInGuild error = &InputError{Code: errorCodeInvalidGuild, Message: store.ErrAlreadyInGuild.Error()}
+)
+
+// storeInputError returns the InputError shown for err from the store, or
+// err itself if it does not reject the player's input.
+func storeInputError(err error) error {
+	switch {
+	case errors.Is(err, store.ErrUsernameTaken):
+		return errUsernameTaken
+	case errors.Is(err, store.ErrGuildTaken):
+		return errGuildTaken
+	case errors.Is(err, store.ErrAlreadyInGuild):
+		return errAlreadyInGuild
+	}
+	return err
+}
+
+// ValidatePlayerName checks a name a player chose.
+func ValidatePlayerName(name string) error {
+	if len(name) < minNameLength || len(name) > maxNameLength {
+		return &InputError{Code: errorCodeInvalidName, Message: fmt.Sprintf("name must be %d to %d characters", minNameLength, maxNameLength)}
+	}
+	for _, r := range name {
+		if !isNameRune(r) {
+			return &InputError{Code: errorCodeInvalidName, Message: "name may only contain letters, digits and underscores"}
+		}
+	}
+	if strings.HasPrefix(strings.ToLower(name), guestNamePrefix) {
+		return &InputError{Code: errorCodeInvalidName, Message: "names starting with Player_ are reserved for guests"}
+	}
+	return nil
+}
+
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
+
+// SanitizeChat checks a chat, whisper or party message and strips its
+// control characters, returning the text to store and deliver.
+func SanitizeChat(text string) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", &InputError{Code: errorCodeInvalidChat, Message: "message is not valid UTF-8"}
+	}
+
+	text = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || isBidiControl(r) {
+			return -1
+		}
+		return r
+	}, text))
+	if text == "" {
+		return "", &InputError{Code: errorCodeInvalidChat, Message: "message is empty"}
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		return "", &InputError{Code: errorCodeChatTooLong, Message: fmt.Sprintf("message is longer than %d characters", maxChatLength)}
+	}
+	return text, nil
+}
+
+// isBidiControl reports the embedding, override and isolate characters,
+// which can make a message render as something other than what was sent.
+func isBidiControl(r rune) bool {
+	return r >= '\u202a' && r <= '\u202e' || r >= '\u2066' && r <= '\u2069'
+}
+
+// NewInputErrorMessage tells a player why their input was rejected.
+func NewInputErrorMessage(err error) GameMessage {
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		return NewErrorCodeMessage(inputErr.Code, inputErr.Message)
+	}
+	return NewErrorMessage(err.Error())
+}
@@ -0,0 +1,41 @@
+package game
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const serverHeartbeatInterval = 15 * time.Second
+
+// serverStaleAfter is how long an instance may miss heartbeats before
+// /api/servers reports it unhealthy.
+const serverStaleAfter = 3 * serverHeartbeatInterval
+
+// StartServerHeartbeat records this instance in the shared database
+// periodically so that operators can see every instance's health from any
+// one of them.
+func StartServerHeartbeat(database store.Store, protocol string, game GameAdmin) {
+	startedAt := time.Now()
+	record := func() {
+		if err := database.RecordServerHeartbeat(context.Background(), protocol, startedAt, game.GetClientCount()); err != nil {
+			logrus.Errorf("Failed to record server heartbeat: %v", err)
+		}
+	}
+
+	record()
+	go func() {
+		ticker := time.NewTicker(serverHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				record()
+			}
+		}
+	}()
+}
@@ -0,0 +1,167 @@
+package game
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const defaultPickupItem = "coin"
+
+type ItemDefinition struct {
+	MaxStack int64
+	Heal     float32 // health restored by UseItem; zero means the item cannot be used
+}
+
+var itemDefinitions = map[string]ItemDefinition{
+	"coin":          {MaxStack: 9999},
+	"bandage":       {MaxStack: 20, Heal: 10},
+	"health_potion": {MaxStack: 10, Heal: 25},
+}
+
+// HealFunc restores health to the player using an item. It returns the new
+// health and false if the player cannot be healed (dead or at full health).
+type HealFunc func(amount float32) (float32, bool)
+
+// InventoryManager validates and persists inventory changes for both
+// transports and keeps clients updated with PlayerInventory messages and
+// their private state.
+type InventoryManager struct {
+	database store.Store
+	events   *WorldEvents
+	private  *PrivateChannel
+}
+
+func NewInventoryManager(database store.Store, events *WorldEvents, private *PrivateChannel) *InventoryManager {
+	return &InventoryManager{database: database, events: events, private: private}
+}
+
+// Pickup adds the item named in pickup action data ("item", defaulting to a
+// coin) to the player's inventory, along with any bonus items from running
+// world events.
+func (im *InventoryManager) Pickup(ctx context.Context, playerID uuid.UUID, data interface{}, dir PlayerDirectory) {
+	itemType := defaultPickupItem
+	if fields, ok := data.(map[string]interface{}); ok {
+		if item, ok := fields["item"].(string); ok && item != "" {
+			itemType = item
+		}
+	}
+
+	definition, known := itemDefinitions[itemType]
+	if !known {
+		im.sendError(playerID, "Unknown item", dir)
+		return
+	}
+
+	if err := im.database.AddInventoryItem(ctx, playerID, itemType, 1, definition.MaxStack); err != nil {
+		logrus.Errorf("Failed to add %s to inventory of %s: %v", itemType, playerID, err)
+		return
+	}
+	for _, bonus := range im.events.BonusItems() {
+		if err := im.database.AddInventoryItem(ctx, playerID, bonus, 1, itemDefinitions[bonus].MaxStack); err != nil {
+			logrus.Errorf("Failed to add bonus %s to inventory of %s: %v", bonus, playerID, err)
+		}
+	}
+	im.SendInventory(ctx, playerID, dir)
+}
+
+// HandleMessage processes UseItem and DropItem from playerID.
+func (im *InventoryManager) HandleMessage(ctx context.Context, playerID uuid.UUID, message *GameMessage, heal HealFunc, dir PlayerDirectory) {
+	switch message.Type {
+	case "UseItem":
+		var data UseItemData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.Item == "" {
+			im.sendError(playerID, "item is required", dir)
+			return
+		}
+		im.useItem(ctx, playerID, data.Item, heal, dir)
+
+	case "DropItem":
+		var data DropItemData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.Item == "" {
+			im.sendError(playerID, "item is required", dir)
+			return
+		}
+		if data.Quantity == 0 {
+			data.Quantity = 1
+		}
+		if data.Quantity < 0 {
+			im.sendError(playerID, "quantity must be positive", dir)
+			return
+		}
+		im.dropItem(ctx, playerID, data.Item, data.Quantity, dir)
+	}
+}
+
+func (im *InventoryManager) useItem(ctx context.Context, playerID uuid.UUID, itemType string, heal HealFunc, dir PlayerDirectory) {
+	definition, known := itemDefinitions[itemType]
+	if !known {
+		im.sendError(playerID, "Unknown item", dir)
+		return
+	}
+	if definition.Heal <= 0 {
+		im.sendError(playerID, "That item cannot be used", dir)
+		return
+	}
+
+	removed, err := im.database.RemoveInventoryItem(ctx, playerID, itemType, 1)
+	if err != nil {
+		logrus.Errorf("Failed to use %s for %s: %v", itemType, playerID, err)
+		im.sendError(playerID, "Failed to use item", dir)
+		return
+	}
+	if !removed {
+		im.sendError(playerID, "You do not have that item", dir)
+		return
+	}
+
+	newHealth, healed := heal(definition.Heal)
+	if !healed {
+		// Refund the item; it had no effect
+		if err := im.database.AddInventoryItem(ctx, playerID, itemType, 1, definition.MaxStack); err != nil {
+			logrus.Errorf("Failed to refund %s to %s: %v", itemType, playerID, err)
+		}
+		im.sendError(playerID, "You cannot use that item right now", dir)
+		return
+	}
+
+	logrus.Infof("Player %s used %s, health: %.0f", playerID, itemType, newHealth)
+	im.SendInventory(ctx, playerID, dir)
+}
+
+func (im *InventoryManager) dropItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity int64, dir PlayerDirectory) {
+	removed, err := im.database.RemoveInventoryItem(ctx, playerID, itemType, quantity)
+	if err != nil {
+		logrus.Errorf("Failed to drop %s for %s: %v", itemType, playerID, err)
+		im.sendError(playerID, "Failed to drop item", dir)
+		return
+	}
+	if !removed {
+		im.sendError(playerID, "You do not have enough of that item", dir)
+		return
+	}
+
+	im.SendInventory(ctx, playerID, dir)
+}
+
+// SendInventory sends the player's full inventory in a PlayerInventory message.
+func (im *InventoryManager) SendInventory(ctx context.Context, playerID uuid.UUID, dir PlayerDirectory) {
+	items, err := im.database.GetInventory(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load inventory for %s: %v", playerID, err)
+		im.sendError(playerID, "Failed to load inventory", dir)
+		return
+	}
+
+	inventoryMsg := NewPlayerInventoryMessage(playerID, items)
+	dir.SendToPlayer(playerID, &inventoryMsg)
+	im.private.SetInventory(playerID, items)
+}
+
+func (im *InventoryManager) sendError(playerID uuid.UUID, text string, dir PlayerDirectory) {
+	errorMsg := NewErrorMessage(text)
+	dir.SendToPlayer(playerID, &errorMsg)
+}
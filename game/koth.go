@@ -0,0 +1,104 @@
+package game
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	hillRadiusFraction  = 0.1 // of the shorter side of the field
+	kothHoldTarget      = 60 * time.Second
+	kothPointsPerSecond = 1
+)
+
+// kingOfTheHill puts a hill in the middle of the field. A living player
+// alone on it holds it, scoring every second; the first to have held it for
+// kothHoldTarget in all wins the round.
+type kingOfTheHill struct {
+	x, y, radius float32
+
+	holder   uuid.UUID // uuid.Nil while the hill is empty or contested
+	held     map[uuid.UUID]time.Duration
+	lastTick time.Time
+}
+
+func newKingOfTheHill(field ModeField) GameMode {
+	radius := field.Width
+	if field.Height < radius {
+		radius = field.Height
+	}
+	return &kingOfTheHill{
+		x:      field.Width / 2,
+		y:      field.Height / 2,
+		radius: radius * hillRadiusFraction,
+	}
+}
+
+func (m *kingOfTheHill) Init(arena ModeArena) {
+	m.holder = uuid.Nil
+	m.held = make(map[uuid.UUID]time.Duration)
+	m.lastTick = time.Time{}
+	m.announce(arena)
+}
+
+func (m *kingOfTheHill) OnTick(arena ModeArena, now time.Time) {
+	holder, onHill := uuid.Nil, 0
+	for _, player := range arena.Players() {
+		if player.Health > 0 && withinRadius(player, m.x, m.y, m.radius) {
+			holder = player.ID
+			onHill++
+		}
+	}
+	if onHill != 1 {
+		holder = uuid.Nil
+	}
+
+	changed := holder != m.holder
+	if holder != uuid.Nil && holder == m.holder && !m.lastTick.IsZero() {
+		before := m.held[holder] / time.Second
+		m.held[holder] += now.Sub(m.lastTick)
+		if seconds := int64(m.held[holder]/time.Second - before); seconds > 0 {
+			arena.AwardPoints(holder, seconds*kothPointsPerSecond, "hill_hold")
+			changed = true
+		}
+	}
+	m.holder = holder
+	m.lastTick = now
+
+	if changed {
+		m.announce(arena)
+	}
+}
+
+func (m *kingOfTheHill) OnPlayerAction(arena ModeArena, player Player, action string, data interface{}) bool {
+	return false
+}
+
+func (m *kingOfTheHill) CheckWin() (string, bool) {
+	for playerID, held := range m.held {
+		if held >= kothHoldTarget {
+			return playerID.String(), true
+		}
+	}
+	return "", false
+}
+
+func (m *kingOfTheHill) announce(arena ModeArena) {
+	scores := make(map[string]int64, len(m.held))
+	for playerID, held := range m.held {
+		scores[playerID.String()] = int64(held / time.Second)
+	}
+	hill := HillState{X: m.x, Y: m.y, Radius: m.radius}
+	if m.holder != uuid.Nil {
+		holder := m.holder
+		hill.HolderID = &holder
+	}
+	stateMessage := NewGameModeStateMessage(GameModeStateData{
+		Room:   arena.Room(),
+		Mode:   "koth",
+		Scores: scores,
+		Hill:   &hill,
+	})
+	arena.Announce(&stateMessage)
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+var leaderboardWindows = []string{"daily", "weekly", "monthly", "all"}
+
+// leaderboardPeriod returns the window containing t. Windows are aligned to
+// UTC days, Monday-start weeks and calendar months.
+func leaderboardPeriod(window string, t time.Time) (store.LeaderboardPeriod, error) {
+	t = t.UTC()
+	switch window {
+	case "daily":
+		return store.LeaderboardPeriod{Period: window, Start: t.Format("2006-01-02")}, nil
+	case "weekly":
+		monday := t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return store.LeaderboardPeriod{Period: window, Start: monday.Format("2006-01-02")}, nil
+	case "monthly":
+		return store.LeaderboardPeriod{Period: window, Start: t.Format("2006-01")}, nil
+	case "all":
+		return store.LeaderboardPeriod{Period: window, Start: "all"}, nil
+	}
+	return store.LeaderboardPeriod{}, fmt.Errorf("window must be one of daily, weekly, monthly or all, got %q", window)
+}
+
+// Leaderboards keeps daily, weekly, monthly and all-time score rollups, so
+// each window is read with a single indexed query.
+type Leaderboards struct {
+	database store.Store
+}
+
+func NewLeaderboards(database store.Store) *Leaderboards {
+	return &Leaderboards{database: database}
+}
+
+// RecordPoints credits points to the player in every current window.
+func (lb *Leaderboards) RecordPoints(ctx context.Context, playerID uuid.UUID, points int64) {
+	now := time.Now()
+	periods := make([]store.LeaderboardPeriod, 0, len(leaderboardWindows))
+	for _, window := range leaderboardWindows {
+		period, _ := leaderboardPeriod(window, now)
+		periods = append(periods, period)
+	}
+
+	if err := lb.database.AddLeaderboardPoints(ctx, playerID, points, periods); err != nil {
+		logrus.Errorf("Failed to record leaderboard points for %s: %v", playerID, err)
+	}
+}
+
+// HandleEvent credits the points of every ScoreChanged event.
+func (lb *Leaderboards) HandleEvent(ctx context.Context, event GameEvent) {
+	if scored, ok := event.(ScoreChanged); ok {
+		lb.RecordPoints(ctx, scored.PlayerID, scored.Points)
+	}
+}
+
+// Get returns the current standings of a window.
+func (lb *Leaderboards) Get(ctx context.Context, window string, limit int) (*LeaderboardResponseData, error) {
+	period, err := leaderboardPeriod(window, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	scores, err := lb.database.GetWindowedLeaderboard(ctx, period, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]WindowedLeaderboardEntry, 0, len(scores))
+	for i, score := range scores {
+		entries = append(entries, WindowedLeaderboardEntry{
+			Rank:          i + 1,
+			WindowedScore: score,
+		})
+	}
+
+	return &LeaderboardResponseData{
+		Window:      window,
+		PeriodStart: period.Start,
+		Entries:     entries,
+	}, nil
+}
+
+// HandleRequest answers a LeaderboardRequest message.
+func (lb *Leaderboards) HandleRequest(ctx context.Context, playerID uuid.UUID, message *GameMessage, dir PlayerDirectory) {
+	var data LeaderboardRequestData
+	if err := DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := NewErrorMessage("Invalid leaderboard request")
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+	if data.Window == "" {
+		data.Window = "daily"
+	}
+	if data.Limit <= 0 || data.Limit > maxAPILimit {
+		data.Limit = defaultAPILimit
+	}
+
+	if _, err := leaderboardPeriod(data.Window, time.Now()); err != nil {
+		errorMsg := NewErrorMessage(err.Error())
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	response, err := lb.Get(ctx, data.Window, data.Limit)
+	if err != nil {
+		logrus.Errorf("Failed to load %s leaderboard: %v", data.Window, err)
+		errorMsg := NewErrorMessage("Failed to load leaderboard")
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	responseMsg := NewLeaderboardResponseMessage(response)
+	dir.SendToPlayer(playerID, &responseMsg)
+}
@@ -0,0 +1,87 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Codes of messages refused for their size or shape, sent in the code field
+// of Error messages and as the reason of Disconnect messages.
+const (
+	errorCodeMessageTooLarge   = "message_too_large"
+	errorCodeMessageTooComplex = "message_too_complex"
+)
+
+// ErrMessageTooLarge is returned by ReadFrame for a WebSocket message or TCP
+// frame longer than MAX_MESSAGE_SIZE. The connection cannot be read past it,
+// so the client is disconnected.
+var ErrMessageTooLarge error = &InputError{Code: errorCodeMessageTooLarge, Message: "message exceeds the size limit"}
+
+// MessageLimits bounds what a client may send in one message. Size bounds
+// the encoded message on WebSocket and TCP, where a message can be as long
+// as the client likes; UDP datagrams are already bounded by the read
+// buffer. Depth and Values bound the decoded data on every transport, so
+// that a message within the size limit cannot hand handlers a structure
+// nested thousands deep or holding thousands of values.
+type MessageLimits struct {
+	Size   int
+	Depth  int
+	Values int
+}
+
+func NewMessageLimits(config *Config) MessageLimits {
+	return MessageLimits{Size: config.MaxMessageSize, Depth: config.MaxMessageDepth, Values: config.MaxMessageValues}
+}
+
+// Check returns an InputError if the data of message nests deeper than
+// Depth or holds more than Values values, counting maps and arrays as
+// values too.
+func (l MessageLimits) Check(message *GameMessage) error {
+	values := 0
+	if !l.walk(message.Data, 0, &values) {
+		metrics.Inc("messages_rejected_" + errorCodeMessageTooComplex)
+		return &InputError{Code: errorCodeMessageTooComplex, Message: fmt.Sprintf("message data may nest %d deep and hold %d values", l.Depth, l.Values)}
+	}
+	return nil
+}
+
+// walk counts the values of data into values and reports whether it stayed
+// within the limits.
+func (l MessageLimits) walk(data interface{}, depth int, values *int) bool {
+	*values++
+	if *values > l.Values {
+		return false
+	}
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		if depth >= l.Depth {
+			return false
+		}
+		for _, inner := range v {
+			if !l.walk(inner, depth+1, values) {
+				return false
+			}
+		}
+	case []interface{}:
+		if depth >= l.Depth {
+			return false
+		}
+		for _, inner := range v {
+			if !l.walk(inner, depth+1, values) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TooLarge reports whether a ReadFrame error is a message over the size
+// limit, counting it if so.
+func TooLarge(err error) bool {
+	if !errors.Is(err, ErrMessageTooLarge) {
+		return false
+	}
+	metrics.Inc("messages_rejected_" + errorCodeMessageTooLarge)
+	return true
+}
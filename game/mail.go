@@ -0,0 +1,261 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// Limits on mail. A full mailbox refuses new mail until its owner deletes
+// some.
+const (
+	maxMailSubjectLength = 64   // characters
+	maxMailBodyLength    = 1000 // characters
+	maxMailbox           = 100
+)
+
+const errorCodeInvalidMail = "invalid_mail"
+
+// SanitizeMail checks the subject and body of mail and strips their control
+// characters, keeping line breaks in the body.
+func SanitizeMail(subject, body string) (string, string, error) {
+	if !utf8.ValidString(subject) || !utf8.ValidString(body) {
+		return "", "", &InputError{Code: errorCodeInvalidMail, Message: "mail is not valid UTF-8"}
+	}
+
+	subject = strings.TrimSpace(stripControl(subject, false))
+	body = strings.TrimSpace(stripControl(body, true))
+
+	if body == "" {
+		return "", "", &InputError{Code: errorCodeInvalidMail, Message: "mail body is empty"}
+	}
+	if utf8.RuneCountInString(subject) > maxMailSubjectLength {
+		return "", "", &InputError{Code: errorCodeInvalidMail, Message: fmt.Sprintf("mail subject is longer than %d characters", maxMailSubjectLength)}
+	}
+	if utf8.RuneCountInString(body) > maxMailBodyLength {
+		return "", "", &InputError{Code: errorCodeInvalidMail, Message: fmt.Sprintf("mail body is longer than %d characters", maxMailBodyLength)}
+	}
+	return subject, body, nil
+}
+
+func stripControl(text string, keepNewlines bool) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' && keepNewlines {
+			return r
+		}
+		if unicode.IsControl(r) || isBidiControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// Mailbox lets players send mail to each other whether or not the recipient
+// is online. Mail that arrives while a player is away is delivered in a
+// MailReceived when they next join.
+type Mailbox struct {
+	database store.Store
+}
+
+func NewMailbox(database store.Store) *Mailbox {
+	return &Mailbox{database: database}
+}
+
+// HandleMessage processes a mail message from playerID, replying and
+// delivering mail through dir.
+func (mb *Mailbox) HandleMessage(ctx context.Context, playerID uuid.UUID, message *GameMessage, dir PlayerDirectory) {
+	switch message.Type {
+	case "MailSend":
+		var data MailSendData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.RecipientID == uuid.Nil {
+			mb.sendError(playerID, "recipient_id is required", dir)
+			return
+		}
+		mb.send(ctx, playerID, data, dir)
+
+	case "MailListRequest":
+		mb.sendList(ctx, playerID, dir)
+
+	case "MailRead", "MailDelete":
+		var data MailIDData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.MailID == 0 {
+			mb.sendError(playerID, "mail_id is required", dir)
+			return
+		}
+
+		var found bool
+		var err error
+		if message.Type == "MailRead" {
+			found, err = mb.database.MarkMailRead(ctx, playerID, data.MailID)
+		} else {
+			found, err = mb.database.DeleteMail(ctx, playerID, data.MailID)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to update mail %d of %s: %v", data.MailID, playerID, err)
+			mb.sendError(playerID, "Failed to update mail", dir)
+			return
+		}
+		if !found {
+			mb.sendError(playerID, "No such mail", dir)
+			return
+		}
+		mb.sendList(ctx, playerID, dir)
+	}
+}
+
+func (mb *Mailbox) send(ctx context.Context, playerID uuid.UUID, data MailSendData, dir PlayerDirectory) {
+	if data.RecipientID == playerID {
+		mb.sendError(playerID, "You cannot send mail to yourself", dir)
+		return
+	}
+	subject, body, err := SanitizeMail(data.Subject, data.Body)
+	if err != nil {
+		errorMsg := NewInputErrorMessage(err)
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	recipient, err := mb.database.GetPlayer(ctx, data.RecipientID)
+	if err != nil {
+		logrus.Errorf("Failed to look up player %s: %v", data.RecipientID, err)
+		mb.sendError(playerID, "Failed to send mail", dir)
+		return
+	}
+	if recipient == nil {
+		mb.sendError(playerID, "Unknown player", dir)
+		return
+	}
+
+	mail := &store.Mail{
+		SenderID:    playerID.String(),
+		RecipientID: data.RecipientID.String(),
+		Subject:     subject,
+		Body:        body,
+	}
+	sent, err := mb.database.SendMail(ctx, mail, maxMailbox)
+	if err != nil {
+		logrus.Errorf("Failed to send mail: %v", err)
+		mb.sendError(playerID, "Failed to send mail", dir)
+		return
+	}
+	if !sent {
+		mb.sendError(playerID, "That player's mailbox is full", dir)
+		return
+	}
+
+	sentMsg := NewMailSentMessage(mail.ID)
+	dir.SendToPlayer(playerID, &sentMsg)
+
+	if sender, err := mb.database.GetPlayer(ctx, playerID); err == nil && sender != nil {
+		mail.SenderName = sender.Name
+	}
+	receivedMsg := NewMailMessage("MailReceived", []store.Mail{*mail})
+	if dir.SendToPlayer(data.RecipientID, &receivedMsg) {
+		if err := mb.database.MarkMailDelivered(ctx, mail.ID); err != nil {
+			logrus.Errorf("Failed to mark mail %d delivered: %v", mail.ID, err)
+		}
+	}
+}
+
+func (mb *Mailbox) sendList(ctx context.Context, playerID uuid.UUID, dir PlayerDirectory) {
+	mail, err := mb.database.GetMail(ctx, playerID, maxMailbox)
+	if err != nil {
+		logrus.Errorf("Failed to load mail of %s: %v", playerID, err)
+		mb.sendError(playerID, "Failed to load mail", dir)
+		return
+	}
+
+	listMsg := NewMailMessage("MailList", mail)
+	dir.SendToPlayer(playerID, &listMsg)
+}
+
+// Deliver sends a joining player the mail that arrived while they were
+// away.
+func (mb *Mailbox) Deliver(ctx context.Context, playerID uuid.UUID, dir PlayerDirectory) {
+	mail, err := mb.database.TakeUndeliveredMail(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load undelivered mail of %s: %v", playerID, err)
+		return
+	}
+	if len(mail) == 0 {
+		return
+	}
+
+	receivedMsg := NewMailMessage("MailReceived", mail)
+	dir.SendToPlayer(playerID, &receivedMsg)
+}
+
+func (mb *Mailbox) sendError(playerID uuid.UUID, text string, dir PlayerDirectory) {
+	errorMsg := NewErrorMessage(text)
+	dir.SendToPlayer(playerID, &errorMsg)
+}
+
+// handleGetMail returns the mailbox of the account the bearer token belongs
+// to, newest first.
+func (auth *AuthHandler) handleGetMail(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	account, ok := auth.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	mail, err := auth.accounts.database.GetMail(r.Context(), account.ID, maxMailbox)
+	if err != nil {
+		logrus.Errorf("Failed to load mail of %s: %v", account.ID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load mail")
+		return
+	}
+	if mail == nil {
+		mail = []store.Mail{}
+	}
+	WriteJSON(w, http.StatusOK, MailData{Mail: mail})
+}
+
+// handleUpdateMail serves /auth/me/mail/read and /auth/me/mail/delete,
+// which take {"mail_id"}.
+func (auth *AuthHandler) handleUpdateMail(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	account, ok := auth.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var data MailIDData
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAuthRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil || data.MailID == 0 {
+		WriteJSONError(w, http.StatusBadRequest, "body must be {\"mail_id\"}")
+		return
+	}
+
+	var found bool
+	var err error
+	if strings.HasSuffix(r.URL.Path, "/read") {
+		found, err = auth.accounts.database.MarkMailRead(r.Context(), account.ID, data.MailID)
+	} else {
+		found, err = auth.accounts.database.DeleteMail(r.Context(), account.ID, data.MailID)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to update mail %d of %s: %v", data.MailID, account.ID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to update mail")
+		return
+	}
+	if !found {
+		WriteJSONError(w, http.StatusNotFound, "no such mail")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,176 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maintenanceLockout is how long before a scheduled restart new connections
+// are refused.
+const maintenanceLockout = 5 * time.Minute
+
+// maintenanceCountdown lists the times before a restart at which players
+// are reminded of it, besides when it is scheduled.
+var maintenanceCountdown = []time.Duration{
+	time.Hour, 30 * time.Minute, 15 * time.Minute, 10 * time.Minute, 5 * time.Minute,
+	2 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second,
+}
+
+// Shutdown ends the process for a scheduled restart. server.Run replaces it
+// with one that first disconnects the players of every hosted game and saves
+// it.
+var Shutdown = func(message string) { os.Exit(0) }
+
+var errRestartInPast = errors.New("restart time must be in the future")
+
+// ParseRestartTime reads when to restart, either a delay such as 15m or an
+// RFC 3339 time.
+func ParseRestartTime(value string, now time.Time) (time.Time, error) {
+	if delay, err := time.ParseDuration(value); err == nil {
+		return now.Add(delay), nil
+	}
+	restartAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("restart time must be a delay such as 15m or an RFC 3339 time")
+	}
+	return restartAt, nil
+}
+
+// Maintenance restarts the server at a time an operator schedules from the
+// console or the admin API. Players are sent Maintenance messages counting
+// down to it, new connections are refused for the last maintenanceLockout
+// and at the restart every player is disconnected, the database flushed and
+// the process exits for its supervisor to start it again.
+type Maintenance struct {
+	game GameAdmin
+
+	mu        sync.Mutex
+	restartAt time.Time // zero when no restart is scheduled
+	message   string
+	cancel    chan struct{}
+}
+
+// MaintenanceInfo describes a scheduled restart.
+type MaintenanceInfo struct {
+	RestartAt   time.Time `json:"restart_at"`
+	Message     string    `json:"message,omitempty"`
+	SecondsLeft int64     `json:"seconds_left"`
+	Refusing    bool      `json:"refusing_connections"`
+}
+
+func NewMaintenance(game GameAdmin) *Maintenance {
+	return &Maintenance{game: game}
+}
+
+// Schedule restarts the server at restartAt, replacing the restart
+// scheduled before if any. The message is shown to players along with the
+// countdown.
+func (m *Maintenance) Schedule(restartAt time.Time, message string) error {
+	if !restartAt.After(time.Now()) {
+		return errRestartInPast
+	}
+
+	m.mu.Lock()
+	if m.cancel != nil {
+		close(m.cancel)
+	}
+	cancel := make(chan struct{})
+	m.restartAt, m.message, m.cancel = restartAt, message, cancel
+	m.mu.Unlock()
+
+	logrus.Warnf("Server restart scheduled at %s", restartAt.Format(time.RFC3339))
+	go m.countdown(restartAt, message, cancel)
+	return nil
+}
+
+// Cancel calls off the scheduled restart, returning false if there was none.
+func (m *Maintenance) Cancel() bool {
+	m.mu.Lock()
+	if m.cancel == nil {
+		m.mu.Unlock()
+		return false
+	}
+	close(m.cancel)
+	m.restartAt, m.message, m.cancel = time.Time{}, "", nil
+	m.mu.Unlock()
+
+	logrus.Warn("Scheduled server restart cancelled")
+	cancelled := NewMaintenanceCancelledMessage()
+	m.game.BroadcastAll(&cancelled)
+	return true
+}
+
+// Scheduled returns the scheduled restart, if any.
+func (m *Maintenance) Scheduled() (MaintenanceInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.restartAt.IsZero() {
+		return MaintenanceInfo{}, false
+	}
+	left := time.Until(m.restartAt)
+	return MaintenanceInfo{
+		RestartAt:   m.restartAt,
+		Message:     m.message,
+		SecondsLeft: int64(left.Seconds()),
+		Refusing:    left <= maintenanceLockout,
+	}, true
+}
+
+// Refusal returns why new connections are refused, or "" while they are
+// accepted.
+func (m *Maintenance) Refusal() string {
+	info, scheduled := m.Scheduled()
+	if !scheduled || !info.Refusing {
+		return ""
+	}
+	return fmt.Sprintf("server is restarting for maintenance at %s", info.RestartAt.UTC().Format("15:04 MST"))
+}
+
+// countdown reminds players of the restart and carries it out, unless
+// cancel is closed first.
+func (m *Maintenance) countdown(restartAt time.Time, message string, cancel chan struct{}) {
+	m.announce(restartAt, message)
+	for _, before := range maintenanceCountdown {
+		// Reminders due right away would repeat the first one
+		wait := time.Until(restartAt.Add(-before))
+		if wait < time.Second {
+			continue
+		}
+		select {
+		case <-time.After(wait):
+			m.announce(restartAt, message)
+		case <-cancel:
+			return
+		}
+	}
+
+	select {
+	case <-time.After(time.Until(restartAt)):
+	case <-cancel:
+		return
+	}
+	logrus.Warn("Restarting for scheduled maintenance")
+	Shutdown("server is restarting for maintenance")
+}
+
+func (m *Maintenance) announce(restartAt time.Time, message string) {
+	notice := NewMaintenanceMessage(restartAt, message)
+	m.game.BroadcastAll(&notice)
+}
+
+// describeCountdown words the time left before a restart for players.
+func describeCountdown(left time.Duration) string {
+	if left >= time.Minute {
+		minutes := int(left.Round(time.Minute) / time.Minute)
+		if minutes == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return fmt.Sprintf("%d seconds", int(left.Round(time.Second)/time.Second))
+}
@@ -0,0 +1,273 @@
+package game
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+type matchParticipant struct {
+	room   string
+	score  int64
+	kills  int64
+	deaths int64
+}
+
+// MatchScore is a participant's tally in a match.
+type MatchScore struct {
+	PlayerID uuid.UUID
+	Score    int64
+	Kills    int64
+	Deaths   int64
+}
+
+// MatchTracker runs back-to-back timed matches over the shared world. Points
+// and kills earned during a match decide placements within each match room,
+// which feed the rating system when the match ends.
+//
+// A nil *MatchTracker runs no matches.
+type MatchTracker struct {
+	database store.Store
+	protocol string
+	duration time.Duration
+	random   *SimRand
+
+	mu           sync.Mutex
+	matchID      *int64
+	startedAt    time.Time
+	participants map[uuid.UUID]*matchParticipant
+}
+
+func NewMatchTracker(database store.Store, protocol string, duration time.Duration, random *SimRand) *MatchTracker {
+	mt := &MatchTracker{
+		database: database,
+		protocol: protocol,
+		duration: duration,
+		random:   random,
+
+		participants: make(map[uuid.UUID]*matchParticipant),
+	}
+	mt.start(context.Background())
+	return mt
+}
+
+// start records the match the current participants are playing. Points
+// scored before it returns count towards that match.
+func (mt *MatchTracker) start(ctx context.Context) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	seed := mt.random.Reseed()
+	if id, err := mt.database.CreateMatch(ctx, mt.protocol, seed); err != nil {
+		logrus.Errorf("Failed to create match: %v", err)
+	} else {
+		mt.matchID = &id
+	}
+	mt.startedAt = time.Now()
+
+	logrus.Infof("Match %s started (%s, seed %d)", formatMatchID(mt.matchID), mt.duration, seed)
+}
+
+func (mt *MatchTracker) participant(playerID uuid.UUID) *matchParticipant {
+	p, exists := mt.participants[playerID]
+	if !exists {
+		p = &matchParticipant{}
+		mt.participants[playerID] = p
+	}
+	return p
+}
+
+// AddParticipant enters a player into the current match in the given room.
+// Calling it again moves the player, keeping points earned so far.
+func (mt *MatchTracker) AddParticipant(playerID uuid.UUID, room string) {
+	if mt == nil {
+		return
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.participant(playerID).room = room
+}
+
+// RemoveParticipant takes a player out of the current match along with
+// their tally.
+func (mt *MatchTracker) RemoveParticipant(playerID uuid.UUID) {
+	if mt == nil {
+		return
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	delete(mt.participants, playerID)
+}
+
+func (mt *MatchTracker) AddPoints(playerID uuid.UUID, points int64) {
+	if mt == nil {
+		return
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.participant(playerID).score += points
+}
+
+func (mt *MatchTracker) RecordKill(playerID uuid.UUID) {
+	if mt == nil {
+		return
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.participant(playerID).kills++
+}
+
+func (mt *MatchTracker) RecordDeath(playerID uuid.UUID) {
+	if mt == nil {
+		return
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.participant(playerID).deaths++
+}
+
+// Scores returns the tallies of the current match.
+func (mt *MatchTracker) Scores() []MatchScore {
+	if mt == nil {
+		return nil
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return matchScores(mt.participants)
+}
+
+func matchScores(participants map[uuid.UUID]*matchParticipant) []MatchScore {
+	scores := make([]MatchScore, 0, len(participants))
+	for playerID, p := range participants {
+		scores = append(scores, MatchScore{PlayerID: playerID, Score: p.score, Kills: p.kills, Deaths: p.deaths})
+	}
+	return scores
+}
+
+func (mt *MatchTracker) CurrentMatchID() *int64 {
+	if mt == nil {
+		return nil
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return mt.matchID
+}
+
+// Expired reports whether the current match has run its full duration.
+func (mt *MatchTracker) Expired() bool {
+	if mt == nil {
+		return false
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return time.Since(mt.startedAt) >= mt.duration
+}
+
+// EndAndRestart closes the current match, applies rating changes, and
+// immediately starts the next match. It returns the match ID, the rating
+// changes ordered by room, then placement, and the final tallies.
+func (mt *MatchTracker) EndAndRestart(ctx context.Context) (*int64, []store.RatingChange, []MatchScore) {
+	if mt == nil {
+		return nil, nil, nil
+	}
+	// Detach the ended match so that the game keeps scoring into the next
+	// one while this one is ranked
+	mt.mu.Lock()
+	matchID := mt.matchID
+	participants := mt.participants
+	scores := matchScores(participants)
+	mt.matchID = nil
+	mt.startedAt = time.Now()
+	mt.participants = make(map[uuid.UUID]*matchParticipant)
+	mt.mu.Unlock()
+
+	changes := mt.finish(ctx, matchID, participants)
+	mt.start(ctx)
+	return matchID, changes, scores
+}
+
+func (mt *MatchTracker) finish(ctx context.Context, matchID *int64, participants map[uuid.UUID]*matchParticipant) []store.RatingChange {
+	if matchID != nil {
+		if err := mt.database.EndMatch(ctx, *matchID, len(participants)); err != nil {
+			logrus.Errorf("Failed to end match %s: %v", formatMatchID(matchID), err)
+		}
+	}
+
+	rooms := make(map[string]map[uuid.UUID]*matchParticipant)
+	for playerID, p := range participants {
+		if rooms[p.room] == nil {
+			rooms[p.room] = make(map[uuid.UUID]*matchParticipant)
+		}
+		rooms[p.room][playerID] = p
+	}
+	roomIDs := make([]string, 0, len(rooms))
+	for room := range rooms {
+		roomIDs = append(roomIDs, room)
+	}
+	sort.Strings(roomIDs)
+
+	changes := []store.RatingChange{}
+	for _, room := range roomIDs {
+		changes = append(changes, mt.rankRoom(ctx, room, rooms[room])...)
+	}
+	if len(changes) > 0 {
+		if err := mt.database.ApplyRatingChanges(ctx, matchID, changes); err != nil {
+			logrus.Errorf("Failed to apply rating changes: %v", err)
+		}
+	}
+
+	logrus.Infof("Match %s ended with %d participants", formatMatchID(matchID), len(participants))
+	return changes
+}
+
+// rankRoom places the participants of one room and computes their rating
+// changes.
+func (mt *MatchTracker) rankRoom(ctx context.Context, room string, participants map[uuid.UUID]*matchParticipant) []store.RatingChange {
+	standings := make([]MatchStanding, 0, len(participants))
+	for playerID := range participants {
+		rating, err := mt.database.GetPlayerRating(ctx, playerID)
+		if err != nil {
+			logrus.Errorf("Failed to load rating for %s: %v", playerID, err)
+			rating = store.DefaultRating
+		}
+		standings = append(standings, MatchStanding{PlayerID: playerID, Rating: rating})
+	}
+
+	// Rank by match score, then kills; equal results share a placement
+	better := func(a, b *matchParticipant) bool {
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.kills > b.kills
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		return better(participants[standings[i].PlayerID], participants[standings[j].PlayerID])
+	})
+	for i := range standings {
+		if i > 0 && !better(participants[standings[i-1].PlayerID], participants[standings[i].PlayerID]) {
+			standings[i].Placement = standings[i-1].Placement
+		} else {
+			standings[i].Placement = i + 1
+		}
+	}
+
+	changes := ComputeRatingChanges(standings)
+	for i := range changes {
+		changes[i].Room = room
+	}
+	return changes
+}
+
+func formatMatchID(matchID *int64) string {
+	if matchID == nil {
+		return "<unsaved>"
+	}
+	return strconv.FormatInt(*matchID, 10)
+}
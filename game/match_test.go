@@ -0,0 +1,66 @@
+package game
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"online-server-go/store"
+)
+
+// TestEndAndRestartWhileScoring ends matches while players keep scoring, as
+// the game loop does without holding its own lock, and checks that every
+// point lands in exactly one match.
+func TestEndAndRestartWhileScoring(t *testing.T) {
+	mt := NewMatchTracker(slowEndStore{store.NewMemoryStore()}, "udp", time.Minute, NewSimRand(&Config{}))
+	playerID := uuid.New()
+	mt.AddParticipant(playerID, "lobby")
+
+	stop := make(chan struct{})
+	var points atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			mt.AddPoints(playerID, 1)
+			mt.RecordKill(playerID)
+			points.Add(1)
+		}
+	}()
+
+	var scored int64
+	for ends := 0; ends < 50; ends++ {
+		_, _, scores := mt.EndAndRestart(context.Background())
+		for _, score := range scores {
+			scored += score.Score
+		}
+	}
+	close(stop)
+	wg.Wait()
+	for _, score := range mt.Scores() {
+		scored += score.Score
+	}
+	if scored != points.Load() {
+		t.Errorf("scored %d points over all matches, want %d", scored, points.Load())
+	}
+}
+
+// slowEndStore takes a while to end a match, as a database under load does.
+type slowEndStore struct {
+	store.Store
+}
+
+func (s slowEndStore) EndMatch(ctx context.Context, matchID int64, playerCount int) error {
+	time.Sleep(time.Millisecond)
+	return s.Store.EndMatch(ctx, matchID, playerCount)
+}
@@ -0,0 +1,317 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+type matchRoom struct {
+	bucket  string
+	mode    string
+	members map[uuid.UUID]struct{}
+	paused  bool
+	owner   uuid.UUID // the player who created the room, nil if matchmaking opened it
+	divisor int       // the room runs on every divisor-th tick of the game loop
+}
+
+// maxTickDivisor bounds how much slower than the game loop a room may run.
+const maxTickDivisor = 10
+
+const errorCodeInvalidTickDivisor = "invalid_tick_divisor"
+
+var (
+	errMatchmakingDisabled = errors.New("matchmaking is disabled on this server")
+	errRoomNotFound        = errors.New("no such room")
+)
+
+// RoomInfo describes a match room for the admin API.
+type RoomInfo struct {
+	ID          string     `json:"id"`
+	Bucket      string     `json:"bucket"`
+	Mode        string     `json:"mode,omitempty"`
+	Members     int        `json:"members"`
+	Paused      bool       `json:"paused"`
+	Owner       *uuid.UUID `json:"owner,omitempty"`
+	TickDivisor int        `json:"tick_divisor"`
+}
+
+// Ticks reports whether the room runs on tick of the game loop: it is not
+// paused and tick falls on its divisor.
+func (r RoomInfo) Ticks(tick uint64) bool {
+	return !r.Paused && tick%uint64(max(r.TickDivisor, 1)) == 0
+}
+
+// Matchmaker places players into match rooms by rating bucket. Rooms fill
+// up to roomSize, except that party members always follow their leader into
+// the leader's room. Rooms play GAME_MODE unless opened with another mode
+// by CreateRoom.
+//
+// A nil *Matchmaker keeps everyone in the default room.
+type Matchmaker struct {
+	database    store.Store
+	roomSize    int
+	defaultMode string
+
+	mu         sync.Mutex
+	rooms      map[string]*matchRoom
+	playerRoom map[uuid.UUID]string
+	nextRoom   int
+}
+
+func NewMatchmaker(database store.Store, roomSize int, defaultMode string) *Matchmaker {
+	return &Matchmaker{
+		database:    database,
+		roomSize:    roomSize,
+		defaultMode: defaultMode,
+		rooms:       make(map[string]*matchRoom),
+		playerRoom:  make(map[uuid.UUID]string),
+	}
+}
+
+// Assign places a player into the fullest open room of their rating bucket,
+// opening a new room if none has space, and returns the room ID. Paused
+// rooms take no new players. Players that already have a room keep it.
+func (mm *Matchmaker) Assign(ctx context.Context, playerID uuid.UUID) string {
+	if mm == nil {
+		return DefaultRoom
+	}
+	rating, err := mm.database.GetPlayerRating(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load rating for %s: %v", playerID, err)
+		rating = store.DefaultRating
+	}
+	bucket := RatingBucket(rating)
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if roomID, exists := mm.playerRoom[playerID]; exists {
+		return roomID
+	}
+
+	var best string
+	for roomID, room := range mm.rooms {
+		if room.bucket != bucket || room.paused || len(room.members) >= mm.roomSize {
+			continue
+		}
+		if best == "" || len(room.members) > len(mm.rooms[best].members) {
+			best = roomID
+		}
+	}
+	if best == "" {
+		best = mm.openRoom(bucket, mm.defaultMode)
+	}
+
+	mm.join(playerID, best)
+	return best
+}
+
+// Create opens a room in the player's rating bucket playing mode, GAME_MODE
+// if empty, and moves the player into it. The player owns the room, which
+// runs on every divisor-th tick of the game loop, every tick if divisor is 0.
+// It returns the room and its mode.
+func (mm *Matchmaker) Create(ctx context.Context, playerID uuid.UUID, mode string, divisor int) (string, string, error) {
+	if mm == nil {
+		return "", "", errMatchmakingDisabled
+	}
+	if mode == "" {
+		mode = mm.defaultMode
+	}
+	if err := ParseGameMode(mode); err != nil {
+		return "", "", &InputError{Code: errorCodeInvalidGameMode, Message: err.Error()}
+	}
+	if divisor == 0 {
+		divisor = 1
+	}
+	if divisor < 1 || divisor > maxTickDivisor {
+		return "", "", &InputError{Code: errorCodeInvalidTickDivisor, Message: fmt.Sprintf("tick_divisor must be between 1 and %d", maxTickDivisor)}
+	}
+	rating, err := mm.database.GetPlayerRating(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load rating for %s: %v", playerID, err)
+		rating = store.DefaultRating
+	}
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.leave(playerID)
+	roomID := mm.openRoom(RatingBucket(rating), mode)
+	mm.rooms[roomID].owner = playerID
+	mm.rooms[roomID].divisor = divisor
+	mm.join(playerID, roomID)
+	return roomID, mode, nil
+}
+
+// PlaceWith moves playerID into the room of anchorID, regardless of room
+// size, and returns the room ID.
+func (mm *Matchmaker) PlaceWith(ctx context.Context, playerID, anchorID uuid.UUID) string {
+	if mm == nil {
+		return DefaultRoom
+	}
+	roomID := mm.Assign(ctx, anchorID)
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if current, exists := mm.playerRoom[playerID]; exists {
+		if current == roomID {
+			return roomID
+		}
+		mm.leave(playerID)
+	}
+	mm.join(playerID, roomID)
+	return roomID
+}
+
+// Remove drops a player from their room; empty rooms are closed.
+func (mm *Matchmaker) Remove(playerID uuid.UUID) {
+	if mm == nil {
+		return
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.leave(playerID)
+}
+
+// RoomOf returns the room a player is currently placed in.
+func (mm *Matchmaker) RoomOf(playerID uuid.UUID) (string, bool) {
+	if mm == nil {
+		return "", false
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	roomID, exists := mm.playerRoom[playerID]
+	return roomID, exists
+}
+
+// SetPaused pauses or resumes a room and returns its members.
+func (mm *Matchmaker) SetPaused(roomID string, paused bool) ([]uuid.UUID, error) {
+	if mm == nil {
+		return nil, errMatchmakingDisabled
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	room, exists := mm.rooms[roomID]
+	if !exists {
+		return nil, errRoomNotFound
+	}
+	room.paused = paused
+
+	members := make([]uuid.UUID, 0, len(room.members))
+	for playerID := range room.members {
+		members = append(members, playerID)
+	}
+	return members, nil
+}
+
+// OwnedRoom returns the room a player is in if they own it.
+func (mm *Matchmaker) OwnedRoom(playerID uuid.UUID) (string, bool) {
+	if mm == nil {
+		return "", false
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	roomID, exists := mm.playerRoom[playerID]
+	return roomID, exists && mm.rooms[roomID].owner == playerID
+}
+
+// Frozen returns the players whose rooms do not run on tick, because they
+// are paused or tick less often, or nil if every room runs.
+func (mm *Matchmaker) Frozen(tick uint64) map[uuid.UUID]bool {
+	if mm == nil {
+		return nil
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	var frozen map[uuid.UUID]bool
+	for _, room := range mm.rooms {
+		if !room.paused && tick%uint64(room.divisor) == 0 {
+			continue
+		}
+		if frozen == nil {
+			frozen = make(map[uuid.UUID]bool)
+		}
+		for playerID := range room.members {
+			frozen[playerID] = true
+		}
+	}
+	return frozen
+}
+
+// Paused reports whether a player's room is paused.
+func (mm *Matchmaker) Paused(playerID uuid.UUID) bool {
+	if mm == nil {
+		return false
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	roomID, exists := mm.playerRoom[playerID]
+	return exists && mm.rooms[roomID].paused
+}
+
+// Rooms lists the open rooms by ID.
+func (mm *Matchmaker) Rooms() []RoomInfo {
+	if mm == nil {
+		return nil
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	rooms := make([]RoomInfo, 0, len(mm.rooms))
+	for roomID, room := range mm.rooms {
+		info := RoomInfo{ID: roomID, Bucket: room.bucket, Mode: room.mode, Members: len(room.members), Paused: room.paused, TickDivisor: room.divisor}
+		if room.owner != uuid.Nil {
+			owner := room.owner
+			info.Owner = &owner
+		}
+		rooms = append(rooms, info)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms
+}
+
+func (mm *Matchmaker) openRoom(bucket, mode string) string {
+	mm.nextRoom++
+	roomID := fmt.Sprintf("room-%d", mm.nextRoom)
+	mm.rooms[roomID] = &matchRoom{
+		bucket:  bucket,
+		mode:    mode,
+		members: make(map[uuid.UUID]struct{}),
+		divisor: 1,
+	}
+	logrus.Infof("Opened match room %s for rating bucket %s", roomID, bucket)
+	return roomID
+}
+
+func (mm *Matchmaker) join(playerID uuid.UUID, roomID string) {
+	mm.rooms[roomID].members[playerID] = struct{}{}
+	mm.playerRoom[playerID] = roomID
+}
+
+func (mm *Matchmaker) leave(playerID uuid.UUID) {
+	roomID, exists := mm.playerRoom[playerID]
+	if !exists {
+		return
+	}
+	delete(mm.playerRoom, playerID)
+
+	room := mm.rooms[roomID]
+	delete(room.members, playerID)
+	if len(room.members) == 0 {
+		delete(mm.rooms, roomID)
+		logrus.Infof("Closed empty match room %s", roomID)
+	}
+}
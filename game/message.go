@@ -0,0 +1,1461 @@
+// Package game is the game of the server, whatever transport its players
+// connect over: the messages of the protocol and their encodings, Config,
+// and the rules and subsystems the transports share, from matches and
+// abilities to guilds, mail and the HTTP APIs of accounts and operators.
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"online-server-go/store"
+)
+
+type GameMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+
+	// Optional envelope fields for request/response correlation, see rpc.go
+	ID            string `json:"id,omitempty"`
+	Timestamp     int64  `json:"timestamp,omitempty"` // ms since the epoch
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+type PlayerJoinData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+}
+
+type PlayerLeaveData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+// PlayerMoveData is a position update. A steering player also sends its
+// velocity in units per second, which the server then moves it at.
+type PlayerMoveData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	X        float32   `json:"x"`
+	Y        float32   `json:"y"`
+	VX       float32   `json:"vx,omitempty"`
+	VY       float32   `json:"vy,omitempty"`
+}
+
+// PositionCorrectionData tells a client where the server placed it after it
+// reported a position outside the world bounds.
+type PositionCorrectionData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	X        float32   `json:"x"`
+	Y        float32   `json:"y"`
+}
+
+// ChangeZoneData asks to move the sender to another zone.
+type ChangeZoneData struct {
+	Zone string `json:"zone"`
+}
+
+// ZoneChangedData tells a player the zone they are in and where they
+// arrived, on joining and after each ChangeZone.
+type ZoneChangedData struct {
+	Zone string  `json:"zone"`
+	X    float32 `json:"x"`
+	Y    float32 `json:"y"`
+}
+
+// PlayerPushedData tells the players in a zone that the server knocked a
+// player back. The player moves at VX, VY, slowing to a stop, and its moves
+// are corrected until it does.
+type PlayerPushedData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	X        float32   `json:"x"`
+	Y        float32   `json:"y"`
+	VX       float32   `json:"vx"`
+	VY       float32   `json:"vy"`
+}
+
+// CreateRoomData asks to open a new match room playing Mode, or GAME_MODE if
+// empty, and move the sender into it. The sender owns the room and may send
+// PauseRoom and ResumeRoom. TickDivisor slows the room down to run on every
+// TickDivisor-th tick of the game loop, up to 10; 0 runs it every tick.
+type CreateRoomData struct {
+	Mode        string `json:"mode,omitempty"`
+	TickDivisor int    `json:"tick_divisor,omitempty"`
+}
+
+type RoomCreatedData struct {
+	Room string `json:"room"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// GameModeStateData tells the members of a room how its round stands. It is
+// sent when a round starts and whenever a score, flag or hill changes.
+type GameModeStateData struct {
+	Room   string           `json:"room"`
+	Mode   string           `json:"mode"`
+	Scores map[string]int64 `json:"scores"` // captures by team in ctf, seconds held by player ID in koth
+	Flags  []FlagState      `json:"flags,omitempty"`
+	Hill   *HillState       `json:"hill,omitempty"`
+}
+
+// FlagState is a flag of capture the flag, standing at its team's base
+// unless a player carries it.
+type FlagState struct {
+	Team      string     `json:"team"`
+	X         float32    `json:"x"`
+	Y         float32    `json:"y"`
+	CarrierID *uuid.UUID `json:"carrier_id,omitempty"`
+}
+
+// HillState is the hill of king of the hill, with the player holding it.
+type HillState struct {
+	X        float32    `json:"x"`
+	Y        float32    `json:"y"`
+	Radius   float32    `json:"radius"`
+	HolderID *uuid.UUID `json:"holder_id,omitempty"`
+}
+
+// GameModeWonData tells the members of a room who won the round, a team in
+// ctf or a player ID in koth. The next round starts at once.
+type GameModeWonData struct {
+	Room   string `json:"room"`
+	Mode   string `json:"mode"`
+	Winner string `json:"winner"`
+}
+
+// StartVoteData asks to start a vote of Type, one of next_map (Target is a
+// zone), kick_player (Target is a player ID) or restart_match (no Target).
+type StartVoteData struct {
+	Type   string `json:"type"`
+	Target string `json:"target,omitempty"`
+}
+
+// CastVoteData votes in the running vote. Votes may be changed until it
+// ends.
+type CastVoteData struct {
+	Yes bool `json:"yes"`
+}
+
+// VoteStatusData tells everyone how the running vote stands. It is sent when
+// the vote starts, on every ballot and every few seconds as a countdown.
+type VoteStatusData struct {
+	Type        string    `json:"type"`
+	Target      string    `json:"target,omitempty"`
+	StartedBy   uuid.UUID `json:"started_by"`
+	Yes         int       `json:"yes"`
+	No          int       `json:"no"`
+	Eligible    int       `json:"eligible"` // players online, who may all vote
+	EndsAt      int64     `json:"ends_at"`  // unix milliseconds
+	SecondsLeft int       `json:"seconds_left"`
+}
+
+// VoteEndedData tells everyone the outcome of a vote, which is enforced at
+// once if it passed.
+type VoteEndedData struct {
+	Type     string `json:"type"`
+	Target   string `json:"target,omitempty"`
+	Yes      int    `json:"yes"`
+	No       int    `json:"no"`
+	Eligible int    `json:"eligible"`
+	Passed   bool   `json:"passed"`
+}
+
+// ModerateData is a staff command against another player: mute them from
+// chat for DurationSeconds (10 minutes if 0), unmute them or kick them. The
+// sender's account role must allow it.
+type ModerateData struct {
+	Action          string    `json:"action"` // mute, unmute or kick
+	PlayerID        uuid.UUID `json:"player_id"`
+	DurationSeconds int       `json:"duration_seconds,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+}
+
+// ModeratedData confirms a Moderate command.
+type ModeratedData struct {
+	Action   string    `json:"action"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Until    int64     `json:"until,omitempty"` // unix milliseconds, end of a mute
+}
+
+// MutedData tells a player they may not chat until Until, or that their mute
+// was lifted when Until is 0.
+type MutedData struct {
+	Until  int64  `json:"until,omitempty"` // unix milliseconds
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReportPlayerData reports another player to staff, with an optional
+// excerpt of the chat that prompted it.
+type ReportPlayerData struct {
+	PlayerID    uuid.UUID `json:"player_id"`
+	Reason      string    `json:"reason"`
+	ChatExcerpt string    `json:"chat_excerpt,omitempty"`
+}
+
+// ReportFiledData confirms a ReportPlayer message.
+type ReportFiledData struct {
+	ReportID int64     `json:"report_id"`
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+// UseAbilityData uses an ability, aimed at TargetID for damage and at X, Y
+// for a blast, which defaults to the user's position.
+type UseAbilityData struct {
+	Ability  string     `json:"ability"`
+	TargetID *uuid.UUID `json:"target_id,omitempty"`
+	X        *float32   `json:"x,omitempty"`
+	Y        *float32   `json:"y,omitempty"`
+}
+
+// AbilityUsedData tells the players in a zone that one of them used an
+// ability at X, Y, its target's position for damage.
+type AbilityUsedData struct {
+	PlayerID uuid.UUID  `json:"player_id"`
+	Ability  string     `json:"ability"`
+	Effect   string     `json:"effect"`
+	TargetID *uuid.UUID `json:"target_id,omitempty"`
+	X        float32    `json:"x"`
+	Y        float32    `json:"y"`
+}
+
+// AbilityCooldownsData tells a player the abilities of the server and when
+// each is ready for them again. It is sent when they join and after every
+// ability they use.
+type AbilityCooldownsData struct {
+	Cooldowns []AbilityCooldown `json:"cooldowns"`
+}
+
+type AbilityCooldown struct {
+	Ability         string  `json:"ability"`
+	CooldownSeconds float64 `json:"cooldown_seconds"`
+	ReadyAt         int64   `json:"ready_at,omitempty"` // unix milliseconds, omitted if never used
+}
+
+// PrivateStateData is state only the player it belongs to is sent, see
+// PrivateChannel. Sections left out have not changed since the previous
+// PrivateState; the first after joining has every section.
+type PrivateStateData struct {
+	PlayerID  uuid.UUID         `json:"player_id"`
+	Sequence  uint64            `json:"sequence"` // counts up from 1, so that a stale update can be told apart
+	SentAt    int64             `json:"sent_at"`  // unix milliseconds
+	Health    *HealthDetails    `json:"health,omitempty"`
+	Cooldowns []AbilityCooldown `json:"cooldowns,omitempty"`
+	Inventory *InventoryDetails `json:"inventory,omitempty"`
+}
+
+type InventoryDetails struct {
+	Items []store.InventoryItem `json:"items"`
+}
+
+// HealthDetails is a player's health with what only they need to know, such
+// as when they come back after dying.
+type HealthDetails struct {
+	Current   float32 `json:"current"`
+	Max       float32 `json:"max"`
+	RespawnAt int64   `json:"respawn_at,omitempty"` // unix milliseconds, while dead and respawning
+}
+
+// AFKWarningData warns an idle player that they will be removed at
+// RemovalAt unless they act: from the server if Kick is set, otherwise from
+// the match.
+type AFKWarningData struct {
+	RemovalAt int64 `json:"removal_at"` // unix milliseconds
+	Kick      bool  `json:"kick"`
+}
+
+// PlayerHiddenData tells a player that another player went out of sight,
+// see Visibility. Its position is not sent again until it is back in sight.
+type PlayerHiddenData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+// PlayerAFKData tells the players in a zone that one of them went idle or
+// came back. Removed is set once an idle player is out of the match.
+type PlayerAFKData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	AFK      bool      `json:"afk"`
+	Removed  bool      `json:"removed,omitempty"`
+}
+
+// PlayerDiedData tells the players in a zone that one of them was killed.
+type PlayerDiedData struct {
+	PlayerID  uuid.UUID `json:"player_id"`
+	KillerID  uuid.UUID `json:"killer_id"`
+	RespawnAt int64     `json:"respawn_at,omitempty"` // unix milliseconds, omitted if killed players stay dead
+}
+
+type PlayerRespawnData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	X        float32   `json:"x"`
+	Y        float32   `json:"y"`
+	Health   float32   `json:"health"`
+}
+
+type PlayerActionData struct {
+	PlayerID uuid.UUID   `json:"player_id"`
+	Action   string      `json:"action"`
+	Data     interface{} `json:"data"`
+}
+
+// GameStateData is a snapshot of every player. Tick is the simulation tick it
+// was taken at, omitted by the UDP server which has no simulation loop, and
+// ServerTime the authoritative time in milliseconds; clients interpolate
+// between snapshots by either.
+type GameStateData struct {
+	Players    []Player `json:"players"`
+	Entities   []Entity `json:"entities,omitempty"` // the NPCs, items and projectiles of the zone
+	Timestamp  int64    `json:"timestamp"`
+	Tick       uint64   `json:"tick,omitempty"`
+	ServerTime int64    `json:"server_time"`
+}
+
+// EntityRemovedData tells the players in a zone that an entity is gone.
+type EntityRemovedData struct {
+	EntityID uuid.UUID `json:"entity_id"`
+}
+
+type ChatData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Message  string    `json:"message"`
+}
+
+type ErrorData struct {
+	Code    string `json:"code,omitempty"` // set for rejected input, see InputError
+	Message string `json:"message"`
+}
+
+type HeartbeatData struct {
+	PlayerID     uuid.UUID `json:"player_id"`
+	Sequence     uint32    `json:"sequence"`
+	Capabilities []string  `json:"capabilities,omitempty"` // optional features a UDP client supports
+	SentAt       int64     `json:"sent_at,omitempty"`      // sender's clock in ms, echoed in the Ack
+	Token        string    `json:"token,omitempty"`        // account token, checked on a client's first Heartbeat
+
+	ProtocolVersion int `json:"protocol_version,omitempty"` // 1 if omitted, see protocol.go
+}
+
+// AuthenticateData is the first frame of a TCP client when AUTH_REQUIRED
+// is set, carrying a token from /auth/login.
+type AuthenticateData struct {
+	Token           string `json:"token"`
+	ProtocolVersion int    `json:"protocol_version,omitempty"` // 1 if omitted, see protocol.go
+}
+
+type AckData struct {
+	Sequence uint32 `json:"sequence"`
+	SentAt   int64  `json:"sent_at,omitempty"` // SentAt of the acknowledged Heartbeat
+}
+
+type PlayerStatsRequestData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+type PlayerStatsData struct {
+	PlayerID        uuid.UUID `json:"player_id"`
+	Kills           int64     `json:"kills"`
+	Deaths          int64     `json:"deaths"`
+	Assists         int64     `json:"assists"`
+	ItemsCollected  int64     `json:"items_collected"`
+	PlaytimeSeconds int64     `json:"playtime_seconds"`
+}
+
+type MatchEndedData struct {
+	MatchID *int64               `json:"match_id,omitempty"`
+	Results []store.RatingChange `json:"results"`
+}
+
+// ScoreboardData ranks the players of a match. Final is set on the one sent
+// when the match ends.
+type ScoreboardData struct {
+	MatchID *int64              `json:"match_id,omitempty"`
+	Final   bool                `json:"final"`
+	Results []store.MatchResult `json:"results"`
+}
+
+// FriendData is the payload of FriendAdd, FriendAccept and FriendRemove.
+type FriendData struct {
+	FriendID uuid.UUID `json:"friend_id"`
+}
+
+type FriendListData struct {
+	Friends []FriendEntry `json:"friends"`
+}
+
+type FriendEntry struct {
+	store.Friendship
+	Online bool `json:"online"`
+}
+
+// FriendPresenceData is sent with FriendOnline, FriendOffline, FriendRequest
+// and FriendAccepted notifications.
+type FriendPresenceData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name,omitempty"`
+}
+
+type PartyInviteData struct {
+	TargetID uuid.UUID `json:"target_id"`
+}
+
+type PartyAcceptData struct {
+	PartyID string `json:"party_id"`
+}
+
+type PartyChatData struct {
+	PartyID  string    `json:"party_id,omitempty"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Message  string    `json:"message"`
+}
+
+// PartyInvitedData is sent to a player invited to a party.
+type PartyInvitedData struct {
+	PartyID  string    `json:"party_id"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+}
+
+type PartyUpdateData struct {
+	PartyID  string      `json:"party_id"`
+	LeaderID uuid.UUID   `json:"leader_id"`
+	Members  []uuid.UUID `json:"members"`
+	Room     string      `json:"room,omitempty"`
+}
+
+type PartyLeftData struct {
+	PartyID string `json:"party_id"`
+}
+
+// GuildCreateData creates a guild led by the sender.
+type GuildCreateData struct {
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+}
+
+type GuildJoinData struct {
+	Tag string `json:"tag"`
+}
+
+// GuildPromoteData makes a member an officer, or an officer the leader.
+type GuildPromoteData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+type GuildChatData struct {
+	GuildID  string    `json:"guild_id,omitempty"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name,omitempty"`
+	Message  string    `json:"message"`
+}
+
+// GuildUpdateData is the player's guild and its members, sent when either
+// changes and in reply to GuildInfoRequest.
+type GuildUpdateData struct {
+	store.Guild
+	Members []GuildMemberEntry `json:"members"`
+}
+
+type GuildMemberEntry struct {
+	store.GuildMember
+	Online bool `json:"online"`
+}
+
+// GuildLeftData tells a player they are no longer in a guild. It is also
+// the reply to GuildInfoRequest from a player in none, with no guild ID.
+type GuildLeftData struct {
+	GuildID string `json:"guild_id,omitempty"`
+}
+
+type GuildLeaderboardRequestData struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+type GuildLeaderboardData struct {
+	Entries []GuildLeaderboardEntry `json:"entries"`
+}
+
+type GuildLeaderboardEntry struct {
+	Rank int `json:"rank"`
+	store.GuildScore
+}
+
+type MailSendData struct {
+	RecipientID uuid.UUID `json:"recipient_id"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body"`
+}
+
+// MailIDData names one mail in MailRead and MailDelete requests, and the
+// mail a MailSent confirms.
+type MailIDData struct {
+	MailID int64 `json:"mail_id"`
+}
+
+// MailData is sent with MailReceived, the mail that arrived while the
+// player was away or just now, and MailList, the whole mailbox.
+type MailData struct {
+	Mail []store.Mail `json:"mail"`
+}
+
+// RedirectData tells a client which backend instance to reconnect to,
+// carrying the player ID it should reconnect with.
+type RedirectData struct {
+	Backend  string    `json:"backend"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Room     string    `json:"room,omitempty"`
+}
+
+type UseItemData struct {
+	Item string `json:"item"`
+}
+
+type DropItemData struct {
+	Item     string `json:"item"`
+	Quantity int64  `json:"quantity"`
+}
+
+type PlayerInventoryData struct {
+	PlayerID uuid.UUID             `json:"player_id"`
+	Items    []store.InventoryItem `json:"items"`
+}
+
+type LevelUpData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Level    int       `json:"level"`
+	XP       int64     `json:"xp"`
+}
+
+// WhisperRequestData is sent by a client to whisper to another player.
+type WhisperRequestData struct {
+	TargetID uuid.UUID `json:"target_id"`
+	Message  string    `json:"message"`
+}
+
+// WhisperData is delivered to the whisper's recipient.
+type WhisperData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+	Message  string    `json:"message"`
+}
+
+type LeaderboardRequestData struct {
+	Window string `json:"window"` // daily, weekly, monthly or all
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type WindowedLeaderboardEntry struct {
+	Rank int `json:"rank"`
+	store.WindowedScore
+}
+
+type LeaderboardResponseData struct {
+	Window      string                     `json:"window"`
+	PeriodStart string                     `json:"period_start"`
+	Entries     []WindowedLeaderboardEntry `json:"entries"`
+}
+
+// AnnouncementData is an operator message shown to every player.
+type AnnouncementData struct {
+	Message string `json:"message"`
+}
+
+// KickedData tells a player why the server is disconnecting them.
+type KickedData struct {
+	Reason string `json:"reason"`
+}
+
+// DisconnectData is sent before the server closes a connection. Clients
+// branch on Reason, one of the disconnect reasons in disconnect.go, and show
+// Message.
+type DisconnectData struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// MaintenanceData counts down to a scheduled restart, or tells players it
+// was cancelled. Message is the notice to show.
+type MaintenanceData struct {
+	RestartAt   int64  `json:"restart_at,omitempty"` // Unix seconds
+	SecondsLeft int64  `json:"seconds_left,omitempty"`
+	Cancelled   bool   `json:"cancelled,omitempty"`
+	Message     string `json:"message"`
+}
+
+// PrivacySettingsData is the payload of a PrivacySettings request. Only the
+// flags that are set are changed; an empty request reads the settings.
+type PrivacySettingsData struct {
+	NoChatLog       *bool `json:"no_chat_log,omitempty"`
+	NoIPStorage     *bool `json:"no_ip_storage,omitempty"`
+	AnonymizeEvents *bool `json:"anonymize_events,omitempty"`
+}
+
+// SetPreferencesData sets and removes preferences by name, leaving the
+// player's other preferences as they are.
+type SetPreferencesData struct {
+	Preferences map[string]string `json:"preferences,omitempty"`
+	Remove      []string          `json:"remove,omitempty"`
+}
+
+// PreferencesData is every preference the player saved, by name.
+type PreferencesData struct {
+	Preferences map[string]string `json:"preferences"`
+}
+
+// PlayerIndexData announces the indexes binary frames refer to players by.
+type PlayerIndexData struct {
+	Players []PlayerIndexEntry `json:"players"`
+}
+
+type PlayerIndexEntry struct {
+	Index    uint16    `json:"index"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+}
+
+// BatchData carries the messages queued for a client during one tick, in
+// order.
+type BatchData struct {
+	Messages []GameMessage `json:"messages"`
+}
+
+// SessionEndData records why a session was closed in the event log.
+type SessionEndData struct {
+	SessionID int64  `json:"session_id"`
+	Reason    string `json:"reason"`
+}
+
+// ConnectionQualityData reports a UDP client's link over the last interval.
+// PacketLoss covers packets from the client, RetransmitRate packets to it.
+type ConnectionQualityData struct {
+	PingMs         float64 `json:"ping_ms"`
+	JitterMs       float64 `json:"jitter_ms"`
+	PacketLoss     float64 `json:"packet_loss"`
+	RetransmitRate float64 `json:"retransmit_rate"`
+	Degraded       bool    `json:"degraded"`
+}
+
+// UpgradeRequiredData refuses a client whose protocol version the server
+// does not speak.
+type UpgradeRequiredData struct {
+	Version           int    `json:"version"`
+	SupportedVersions []int  `json:"supported_versions"`
+	Message           string `json:"message"`
+}
+
+// ServerFullData tells a client the server has MaxPlayers playing. Position
+// is its place in the queue, from 1, or 0 if it was refused.
+type ServerFullData struct {
+	Position   int `json:"position"`
+	MaxPlayers int `json:"max_players"`
+}
+
+// SessionSecretData gives a UDP client the secret it signs its packets
+// with, see transport/udp/udpsign.go.
+type SessionSecretData struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Secret   []byte    `json:"secret"`
+}
+
+// AddressEchoData tells a UDP client the address and port its packets reach
+// the server from, after any NAT, see transport/udp/keepalive.go.
+type AddressEchoData struct {
+	IP          string `json:"ip"`
+	Port        int    `json:"port"`
+	KeepaliveMs int64  `json:"keepalive_ms"` // how often to send a Heartbeat to keep the mapping open
+}
+
+// RoomPauseData names the room a RoomPaused or RoomResumed message is about.
+type RoomPauseData struct {
+	Room string `json:"room"`
+}
+
+// WorldEventData announces a world event; State is "started" or "ended".
+type WorldEventData struct {
+	State string `json:"state"`
+	WorldEvent
+}
+
+type Player struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	X      float32   `json:"x"`
+	Y      float32   `json:"y"`
+	Health float32   `json:"health"`
+	Score  uint32    `json:"score"`
+	XP     int64     `json:"xp"`
+	Level  int       `json:"level"`
+	VX     float32   `json:"vx"` // units per second, see MoveTo
+	VY     float32   `json:"vy"`
+
+	GuildTag string `json:"guild_tag,omitempty"` // see GuildManager
+	Team     string `json:"team,omitempty"`      // set by the game mode of the player's room
+	Zone     string `json:"zone,omitempty"`      // see Zones
+	AFK      bool   `json:"afk,omitempty"`       // idle, see AFKPolicy
+
+	movedAt  time.Time
+	steered  bool    // moved by the game loop at VX, VY between inputs
+	pushVX   float32 // impulse on top of VX, VY, see Push
+	pushVY   float32
+	pushedAt time.Time
+	diedAt   time.Time // when health last reached zero, see Respawn
+
+	cooldowns  map[string]time.Time // when each ability used is ready again, see Spend
+	lastInput  time.Time            // last meaningful input, see AFKPolicy
+	afkRemoved bool                 // taken out of the match for being idle
+}
+
+func NewPlayer(id uuid.UUID, name string) *Player {
+	return &Player{
+		ID:     id,
+		Name:   name,
+		X:      0.0,
+		Y:      0.0,
+		Health: 100.0,
+		Score:  0,
+		XP:     0,
+		Level:  1,
+	}
+}
+
+type UDPPacket struct {
+	Sequence  uint32      `json:"sequence"`
+	Timestamp int64       `json:"timestamp"`
+	Message   GameMessage `json:"message"`
+	Reliable  bool        `json:"reliable"`
+	Encoding  Encoding    `json:"-"` // wire format the packet arrived in or is sent in
+}
+
+func NewUDPPacket(sequence uint32, message GameMessage, reliable bool) *UDPPacket {
+	return &UDPPacket{
+		Sequence:  sequence,
+		Timestamp: time.Now().UnixMilli(),
+		Message:   message,
+		Reliable:  reliable,
+	}
+}
+
+func (p *UDPPacket) Serialize() ([]byte, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := p.SerializeTo(buf); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// SerializeTo appends the packet to buf.
+func (p *UDPPacket) SerializeTo(buf *bytes.Buffer) error {
+	message := GetBuffer()
+	defer PutBuffer(message)
+	if err := p.Encoding.MarshalTo(message, &p.Message); err != nil {
+		return err
+	}
+	return p.Encoding.EncodeUDPPacket(buf, p.Sequence, p.Timestamp, message.Bytes(), p.Reliable)
+}
+
+// EncodeUDPPacket appends a packet around a message that is already encoded
+// in e, so that a broadcast encodes its message once for all recipients. The
+// output is what marshalling the UDPPacket would produce.
+func (e Encoding) EncodeUDPPacket(buf *bytes.Buffer, sequence uint32, timestamp int64, message []byte, reliable bool) error {
+	if e == EncodingJSON {
+		var scratch [20]byte
+		buf.WriteString(`{"sequence":`)
+		buf.Write(strconv.AppendUint(scratch[:0], uint64(sequence), 10))
+		buf.WriteString(`,"timestamp":`)
+		buf.Write(strconv.AppendInt(scratch[:0], timestamp, 10))
+		buf.WriteString(`,"message":`)
+		buf.Write(message)
+		buf.WriteString(`,"reliable":`)
+		buf.WriteString(strconv.FormatBool(reliable))
+		buf.WriteByte('}')
+		return nil
+	}
+
+	encoder := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(encoder)
+	encoder.Reset(buf)
+	encoder.EncodeMapLen(4)
+	encoder.EncodeString("sequence")
+	encoder.EncodeUint(uint64(sequence))
+	encoder.EncodeString("timestamp")
+	encoder.EncodeInt(timestamp)
+	encoder.EncodeString("message")
+	buf.Write(message)
+	encoder.EncodeString("reliable")
+	return encoder.EncodeBool(reliable)
+}
+
+// DeserializeUDPPacket decodes a JSON or MessagePack packet. Clients choose
+// an encoding by sending their packets in it.
+func DeserializeUDPPacket(data []byte) (*UDPPacket, error) {
+	packet := UDPPacket{Encoding: detectEncoding(data)}
+	err := packet.Encoding.Unmarshal(data, &packet)
+	return &packet, err
+}
+
+// DecodeMessageData converts a generically decoded message payload into a
+// typed struct.
+func DecodeMessageData(data interface{}, out interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func NewPlayerJoinMessage(playerID uuid.UUID, name string) GameMessage {
+	return GameMessage{
+		Type: "PlayerJoin",
+		Data: PlayerJoinData{
+			PlayerID: playerID,
+			Name:     name,
+		},
+	}
+}
+
+func NewPlayerLeaveMessage(playerID uuid.UUID) GameMessage {
+	return GameMessage{
+		Type: "PlayerLeave",
+		Data: PlayerLeaveData{
+			PlayerID: playerID,
+		},
+	}
+}
+
+func NewPlayerMoveMessage(playerID uuid.UUID, x, y, vx, vy float32) GameMessage {
+	return GameMessage{
+		Type: "PlayerMove",
+		Data: PlayerMoveData{
+			PlayerID: playerID,
+			X:        x,
+			Y:        y,
+			VX:       vx,
+			VY:       vy,
+		},
+	}
+}
+
+func NewPlayerActionMessage(playerID uuid.UUID, action string, data interface{}) GameMessage {
+	return GameMessage{
+		Type: "PlayerAction",
+		Data: PlayerActionData{
+			PlayerID: playerID,
+			Action:   action,
+			Data:     data,
+		},
+	}
+}
+
+func NewGameStateMessage(players []Player, entities []Entity, tick uint64, now time.Time) GameMessage {
+	return GameMessage{
+		Type: "GameState",
+		Data: GameStateData{
+			Players:    players,
+			Entities:   entities,
+			Timestamp:  now.Unix(),
+			Tick:       tick,
+			ServerTime: now.UnixMilli(),
+		},
+	}
+}
+
+func NewEntitySpawnedMessage(entity Entity) GameMessage {
+	return GameMessage{
+		Type: "EntitySpawned",
+		Data: entity,
+	}
+}
+
+func NewEntityRemovedMessage(entityID uuid.UUID) GameMessage {
+	return GameMessage{
+		Type: "EntityRemoved",
+		Data: EntityRemovedData{EntityID: entityID},
+	}
+}
+
+func NewZoneChangedMessage(zone string, x, y float32) GameMessage {
+	return GameMessage{
+		Type: "ZoneChanged",
+		Data: ZoneChangedData{
+			Zone: zone,
+			X:    x,
+			Y:    y,
+		},
+	}
+}
+
+func NewPositionCorrectionMessage(playerID uuid.UUID, x, y float32) GameMessage {
+	return GameMessage{
+		Type: "PositionCorrection",
+		Data: PositionCorrectionData{
+			PlayerID: playerID,
+			X:        x,
+			Y:        y,
+		},
+	}
+}
+
+func NewChatMessage(playerID uuid.UUID, message string) GameMessage {
+	return GameMessage{
+		Type: "Chat",
+		Data: ChatData{
+			PlayerID: playerID,
+			Message:  message,
+		},
+	}
+}
+
+func NewErrorMessage(message string) GameMessage {
+	return GameMessage{
+		Type: "Error",
+		Data: ErrorData{
+			Message: message,
+		},
+	}
+}
+
+// NewErrorCodeMessage is an Error clients can tell apart by its code.
+func NewErrorCodeMessage(code, message string) GameMessage {
+	return GameMessage{
+		Type: "Error",
+		Data: ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+func NewHeartbeatMessage(playerID uuid.UUID, sequence uint32, sentAt int64) GameMessage {
+	return GameMessage{
+		Type: "Heartbeat",
+		Data: HeartbeatData{
+			PlayerID: playerID,
+			Sequence: sequence,
+			SentAt:   sentAt,
+		},
+	}
+}
+
+func NewAckMessage(sequence uint32, sentAt int64) GameMessage {
+	return GameMessage{
+		Type: "Ack",
+		Data: AckData{
+			Sequence: sequence,
+			SentAt:   sentAt,
+		},
+	}
+}
+
+func NewPlayerStatsMessage(playerID uuid.UUID, stats *store.PlayerStats) GameMessage {
+	return GameMessage{
+		Type: "PlayerStats",
+		Data: PlayerStatsData{
+			PlayerID:        playerID,
+			Kills:           stats.Kills,
+			Deaths:          stats.Deaths,
+			Assists:         stats.Assists,
+			ItemsCollected:  stats.ItemsCollected,
+			PlaytimeSeconds: stats.PlaytimeSeconds,
+		},
+	}
+}
+
+func NewMatchEndedMessage(matchID *int64, results []store.RatingChange) GameMessage {
+	return GameMessage{
+		Type: "MatchEnded",
+		Data: MatchEndedData{
+			MatchID: matchID,
+			Results: results,
+		},
+	}
+}
+
+func NewScoreboardMessage(matchID *int64, final bool, results []store.MatchResult) GameMessage {
+	return GameMessage{
+		Type: "Scoreboard",
+		Data: ScoreboardData{
+			MatchID: matchID,
+			Final:   final,
+			Results: results,
+		},
+	}
+}
+
+func NewFriendListMessage(friends []FriendEntry) GameMessage {
+	return GameMessage{
+		Type: "FriendList",
+		Data: FriendListData{
+			Friends: friends,
+		},
+	}
+}
+
+func NewFriendPresenceMessage(messageType string, playerID uuid.UUID, name string) GameMessage {
+	return GameMessage{
+		Type: messageType,
+		Data: FriendPresenceData{
+			PlayerID: playerID,
+			Name:     name,
+		},
+	}
+}
+func NewPartyInvitedMessage(partyID string, inviterID uuid.UUID, inviterName string) GameMessage {
+	return GameMessage{
+		Type: "PartyInvited",
+		Data: PartyInvitedData{
+			PartyID:  partyID,
+			PlayerID: inviterID,
+			Name:     inviterName,
+		},
+	}
+}
+
+func NewPartyUpdateMessage(partyID string, leaderID uuid.UUID, members []uuid.UUID, room string) GameMessage {
+	return GameMessage{
+		Type: "PartyUpdate",
+		Data: PartyUpdateData{
+			PartyID:  partyID,
+			LeaderID: leaderID,
+			Members:  members,
+			Room:     room,
+		},
+	}
+}
+
+func NewPartyLeftMessage(partyID string) GameMessage {
+	return GameMessage{
+		Type: "PartyLeft",
+		Data: PartyLeftData{
+			PartyID: partyID,
+		},
+	}
+}
+
+func NewPartyChatMessage(partyID string, playerID uuid.UUID, message string) GameMessage {
+	return GameMessage{
+		Type: "PartyChat",
+		Data: PartyChatData{
+			PartyID:  partyID,
+			PlayerID: playerID,
+			Message:  message,
+		},
+	}
+}
+
+func NewRedirectMessage(backend string, playerID uuid.UUID, room string) GameMessage {
+	return GameMessage{
+		Type: "Redirect",
+		Data: RedirectData{
+			Backend:  backend,
+			PlayerID: playerID,
+			Room:     room,
+		},
+	}
+}
+
+func NewPlayerInventoryMessage(playerID uuid.UUID, items []store.InventoryItem) GameMessage {
+	return GameMessage{
+		Type: "PlayerInventory",
+		Data: PlayerInventoryData{
+			PlayerID: playerID,
+			Items:    items,
+		},
+	}
+}
+
+func NewLevelUpMessage(playerID uuid.UUID, level int, xp int64) GameMessage {
+	return GameMessage{
+		Type: "LevelUp",
+		Data: LevelUpData{
+			PlayerID: playerID,
+			Level:    level,
+			XP:       xp,
+		},
+	}
+}
+
+func NewWhisperMessage(senderID uuid.UUID, senderName, message string) GameMessage {
+	return GameMessage{
+		Type: "Whisper",
+		Data: WhisperData{
+			PlayerID: senderID,
+			Name:     senderName,
+			Message:  message,
+		},
+	}
+}
+
+func NewLeaderboardResponseMessage(response *LeaderboardResponseData) GameMessage {
+	return GameMessage{
+		Type: "LeaderboardResponse",
+		Data: response,
+	}
+}
+
+func NewWorldEventMessage(state string, event WorldEvent) GameMessage {
+	return GameMessage{
+		Type: "WorldEvent",
+		Data: WorldEventData{
+			State:      state,
+			WorldEvent: event,
+		},
+	}
+}
+
+func NewSessionEndMessage(sessionID int64, reason string) GameMessage {
+	return GameMessage{
+		Type: "SessionEnd",
+		Data: SessionEndData{
+			SessionID: sessionID,
+			Reason:    reason,
+		},
+	}
+}
+
+func NewAnnouncementMessage(message string) GameMessage {
+	return GameMessage{
+		Type: "Announcement",
+		Data: AnnouncementData{Message: message},
+	}
+}
+
+func NewKickedMessage(reason string) GameMessage {
+	return GameMessage{
+		Type: "Kicked",
+		Data: KickedData{Reason: reason},
+	}
+}
+
+func NewDisconnectMessage(reason, message string) GameMessage {
+	return GameMessage{
+		Type: "Disconnect",
+		Data: DisconnectData{Reason: reason, Message: message},
+	}
+}
+
+// NewMaintenanceMessage reminds players of a restart, adding the
+// operator's note if any.
+func NewMaintenanceMessage(restartAt time.Time, note string) GameMessage {
+	left := time.Until(restartAt)
+	notice := fmt.Sprintf("The server restarts for maintenance in %s", describeCountdown(left))
+	if note != "" {
+		notice += ": " + note
+	}
+	return GameMessage{
+		Type: "Maintenance",
+		Data: MaintenanceData{
+			RestartAt:   restartAt.Unix(),
+			SecondsLeft: int64(left.Round(time.Second).Seconds()),
+			Message:     notice,
+		},
+	}
+}
+
+func NewMaintenanceCancelledMessage() GameMessage {
+	return GameMessage{
+		Type: "Maintenance",
+		Data: MaintenanceData{Cancelled: true, Message: "The scheduled maintenance restart was cancelled"},
+	}
+}
+
+func NewPrivacySettingsMessage(settings store.PrivacySettings) GameMessage {
+	return GameMessage{
+		Type: "PrivacySettings",
+		Data: settings,
+	}
+}
+
+func NewGuildUpdateMessage(guild store.Guild, members []GuildMemberEntry) GameMessage {
+	return GameMessage{
+		Type: "GuildUpdate",
+		Data: GuildUpdateData{Guild: guild, Members: members},
+	}
+}
+
+func NewGuildLeftMessage(guildID string) GameMessage {
+	return GameMessage{
+		Type: "GuildLeft",
+		Data: GuildLeftData{GuildID: guildID},
+	}
+}
+
+func NewGuildChatMessage(guildID string, playerID uuid.UUID, name, message string) GameMessage {
+	return GameMessage{
+		Type: "GuildChat",
+		Data: GuildChatData{
+			GuildID:  guildID,
+			PlayerID: playerID,
+			Name:     name,
+			Message:  message,
+		},
+	}
+}
+
+func NewGuildLeaderboardMessage(entries []GuildLeaderboardEntry) GameMessage {
+	return GameMessage{
+		Type: "GuildLeaderboard",
+		Data: GuildLeaderboardData{Entries: entries},
+	}
+}
+
+func NewMailMessage(messageType string, mail []store.Mail) GameMessage {
+	if mail == nil {
+		mail = []store.Mail{}
+	}
+	return GameMessage{
+		Type: messageType,
+		Data: MailData{Mail: mail},
+	}
+}
+
+func NewMailSentMessage(mailID int64) GameMessage {
+	return GameMessage{
+		Type: "MailSent",
+		Data: MailIDData{MailID: mailID},
+	}
+}
+
+func NewPreferencesMessage(preferences map[string]string) GameMessage {
+	return GameMessage{
+		Type: "Preferences",
+		Data: PreferencesData{Preferences: preferences},
+	}
+}
+
+func NewBatchMessage(messages []GameMessage) GameMessage {
+	return GameMessage{
+		Type: "Batch",
+		Data: BatchData{Messages: messages},
+	}
+}
+
+func NewPlayerIndexMessage(entries []PlayerIndexEntry) GameMessage {
+	return GameMessage{
+		Type: "PlayerIndex",
+		Data: PlayerIndexData{Players: entries},
+	}
+}
+
+func NewConnectionQualityMessage(report ConnectionQualityData) GameMessage {
+	return GameMessage{
+		Type: "ConnectionQuality",
+		Data: report,
+	}
+}
+
+// NewPlayerPushedMessage announces the push a player has been given, with
+// its position and velocity as of now.
+func NewPlayerPushedMessage(player Player, now time.Time) GameMessage {
+	snapshot := player.SnapshotAt(now)
+	return GameMessage{
+		Type: "PlayerPushed",
+		Data: PlayerPushedData{
+			PlayerID: player.ID,
+			X:        snapshot.X,
+			Y:        snapshot.Y,
+			VX:       snapshot.pushVX,
+			VY:       snapshot.pushVY,
+		},
+	}
+}
+
+func NewRoomCreatedMessage(room, mode string) GameMessage {
+	return GameMessage{
+		Type: "RoomCreated",
+		Data: RoomCreatedData{Room: room, Mode: mode},
+	}
+}
+
+func NewGameModeStateMessage(state GameModeStateData) GameMessage {
+	return GameMessage{
+		Type: "GameModeState",
+		Data: state,
+	}
+}
+
+func NewGameModeWonMessage(room, mode, winner string) GameMessage {
+	return GameMessage{
+		Type: "GameModeWon",
+		Data: GameModeWonData{Room: room, Mode: mode, Winner: winner},
+	}
+}
+
+func NewVoteUpdateMessage(status VoteStatusData) GameMessage {
+	return GameMessage{
+		Type: "VoteUpdate",
+		Data: status,
+	}
+}
+
+func NewVoteEndedMessage(ended VoteEndedData) GameMessage {
+	return GameMessage{
+		Type: "VoteEnded",
+		Data: ended,
+	}
+}
+
+func NewAbilityUsedMessage(playerID uuid.UUID, ability Ability, targetID *uuid.UUID, x, y float32) GameMessage {
+	return GameMessage{
+		Type: "AbilityUsed",
+		Data: AbilityUsedData{PlayerID: playerID, Ability: ability.Name, Effect: ability.Effect, TargetID: targetID, X: x, Y: y},
+	}
+}
+
+func NewModeratedMessage(action string, playerID uuid.UUID, until time.Time) GameMessage {
+	data := ModeratedData{Action: action, PlayerID: playerID}
+	if !until.IsZero() {
+		data.Until = until.UnixMilli()
+	}
+	return GameMessage{
+		Type: "Moderated",
+		Data: data,
+	}
+}
+
+func NewMutedMessage(until time.Time, reason string) GameMessage {
+	data := MutedData{Reason: reason}
+	if !until.IsZero() {
+		data.Until = until.UnixMilli()
+	}
+	return GameMessage{
+		Type: "Muted",
+		Data: data,
+	}
+}
+
+func NewReportFiledMessage(reportID int64, playerID uuid.UUID) GameMessage {
+	return GameMessage{
+		Type: "ReportFiled",
+		Data: ReportFiledData{ReportID: reportID, PlayerID: playerID},
+	}
+}
+
+func NewAbilityCooldownsMessage(cooldowns []AbilityCooldown) GameMessage {
+	return GameMessage{
+		Type: "AbilityCooldowns",
+		Data: AbilityCooldownsData{Cooldowns: cooldowns},
+	}
+}
+
+func NewPrivateStateMessage(state PrivateStateData) GameMessage {
+	return GameMessage{
+		Type: "PrivateState",
+		Data: state,
+	}
+}
+
+func NewPlayerHiddenMessage(playerID uuid.UUID) GameMessage {
+	return GameMessage{
+		Type: "PlayerHidden",
+		Data: PlayerHiddenData{PlayerID: playerID},
+	}
+}
+
+func NewAFKWarningMessage(removalAt time.Time, kick bool) GameMessage {
+	return GameMessage{
+		Type: "AFKWarning",
+		Data: AFKWarningData{RemovalAt: removalAt.UnixMilli(), Kick: kick},
+	}
+}
+
+func NewPlayerAFKMessage(playerID uuid.UUID, afk, removed bool) GameMessage {
+	return GameMessage{
+		Type: "PlayerAFK",
+		Data: PlayerAFKData{PlayerID: playerID, AFK: afk, Removed: removed},
+	}
+}
+
+func NewPlayerDiedMessage(playerID, killerID uuid.UUID, respawnAt time.Time) GameMessage {
+	data := PlayerDiedData{PlayerID: playerID, KillerID: killerID}
+	if !respawnAt.IsZero() {
+		data.RespawnAt = respawnAt.UnixMilli()
+	}
+	return GameMessage{
+		Type: "PlayerDied",
+		Data: data,
+	}
+}
+
+// NewPlayerRespawnMessage announces a player back at their spawn point.
+func NewPlayerRespawnMessage(player Player) GameMessage {
+	return GameMessage{
+		Type: "PlayerRespawn",
+		Data: PlayerRespawnData{
+			PlayerID: player.ID,
+			X:        player.X,
+			Y:        player.Y,
+			Health:   player.Health,
+		},
+	}
+}
+
+func NewRulesChangedMessage(rules GameRules) GameMessage {
+	return GameMessage{
+		Type: "RulesChanged",
+		Data: rules,
+	}
+}
+
+func NewUpgradeRequiredMessage(version int, supported []int) GameMessage {
+	return GameMessage{
+		Type: "UpgradeRequired",
+		Data: UpgradeRequiredData{
+			Version:           version,
+			SupportedVersions: supported,
+			Message:           fmt.Sprintf("protocol version %d is not supported; this server speaks %s", version, describeVersions(supported)),
+		},
+	}
+}
+
+func NewServerFullMessage(position, maxPlayers int) GameMessage {
+	return GameMessage{
+		Type: "ServerFull",
+		Data: ServerFullData{Position: position, MaxPlayers: maxPlayers},
+	}
+}
+
+func NewServerInfoMessage(info ServerInfo) GameMessage {
+	return GameMessage{
+		Type: "ServerInfo",
+		Data: info,
+	}
+}
+
+func NewAddressEchoMessage(addr *net.UDPAddr, keepalive time.Duration) GameMessage {
+	return GameMessage{
+		Type: "AddressEcho",
+		Data: AddressEchoData{IP: addr.IP.String(), Port: addr.Port, KeepaliveMs: keepalive.Milliseconds()},
+	}
+}
+
+func NewSessionSecretMessage(playerID uuid.UUID, secret []byte) GameMessage {
+	return GameMessage{
+		Type: "SessionSecret",
+		Data: SessionSecretData{PlayerID: playerID, Secret: secret},
+	}
+}
+
+func NewRoomPauseMessage(roomID string, paused bool) GameMessage {
+	messageType := "RoomResumed"
+	if paused {
+		messageType = "RoomPaused"
+	}
+	return GameMessage{
+		Type: messageType,
+		Data: RoomPauseData{Room: roomID},
+	}
+}
+
+// PausableMessage reports whether a message acts on the game world and is
+// therefore refused while the sender's room is paused.
+func PausableMessage(messageType string) bool {
+	switch messageType {
+	case "PlayerMove", "PlayerAction", "UseAbility", "UseItem", "DropItem", "ChangeZone":
+		return true
+	}
+	return false
+}
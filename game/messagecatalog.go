@@ -0,0 +1,157 @@
+package game
+
+//go:generate go run ../cmd/clientgen -lang csharp -out ../clients/unity/GameMessages.cs
+//go:generate go run ../cmd/clientgen -lang gdscript -out ../clients/godot/game_messages.gd
+//go:generate go run ../cmd/clientgen -lang markdown -out ../clients/MESSAGES.md
+
+import "online-server-go/store"
+
+// Who sends a message type.
+const (
+	fromClient = "client"
+	fromServer = "server"
+	fromBoth   = "both"
+)
+
+// MessageSpec describes a message type and its payload.
+type MessageSpec struct {
+	Type   string
+	Data   interface{} // a zero payload, nil for messages without one
+	Sender string
+}
+
+// messageCatalog lists every message clients send or receive. cmd/clientgen
+// reads it to generate the Unity and Godot message classes and the message
+// reference in clients/, so a message added here reaches every client after
+// go generate. Types whose payload differs by direction are listed twice.
+var messageCatalog = []MessageSpec{
+	// Joining and leaving
+	{"Authenticate", AuthenticateData{}, fromClient},
+	{"Heartbeat", HeartbeatData{}, fromClient},
+	{"Ack", AckData{}, fromBoth},
+	{"SessionSecret", SessionSecretData{}, fromServer},
+	{"ServerInfoRequest", nil, fromClient},
+	{"ServerInfo", ServerInfo{}, fromServer},
+	{"AddressEchoRequest", nil, fromClient},
+	{"AddressEcho", AddressEchoData{}, fromServer},
+	{"PlayerJoin", PlayerJoinData{}, fromServer},
+	{"PlayerLeave", PlayerLeaveData{}, fromServer},
+	{"PlayerIndex", PlayerIndexData{}, fromServer},
+	{"Redirect", RedirectData{}, fromServer},
+	{"UpgradeRequired", UpgradeRequiredData{}, fromServer},
+	{"ServerFull", ServerFullData{}, fromServer},
+	{"Kicked", KickedData{}, fromServer},
+	{"Disconnect", DisconnectData{}, fromServer},
+	{"Batch", BatchData{}, fromServer},
+
+	// Gameplay
+	{"PlayerMove", PlayerMoveData{}, fromBoth},
+	{"PositionCorrection", PositionCorrectionData{}, fromServer},
+	{"PlayerHidden", PlayerHiddenData{}, fromServer},
+	{"ChangeZone", ChangeZoneData{}, fromClient},
+	{"ZoneChanged", ZoneChangedData{}, fromServer},
+	{"PlayerAction", PlayerActionData{}, fromClient},
+	{"UseAbility", UseAbilityData{}, fromClient},
+	{"AbilityUsed", AbilityUsedData{}, fromServer},
+	{"AbilityCooldowns", AbilityCooldownsData{}, fromServer},
+	{"PrivateState", PrivateStateData{}, fromServer},
+	{"PlayerPushed", PlayerPushedData{}, fromServer},
+	{"PlayerDied", PlayerDiedData{}, fromServer},
+	{"PlayerRespawn", PlayerRespawnData{}, fromServer},
+	{"AFKWarning", AFKWarningData{}, fromServer},
+	{"PlayerAFK", PlayerAFKData{}, fromServer},
+	{"GameState", GameStateData{}, fromServer},
+	{"EntitySpawned", Entity{}, fromServer},
+	{"EntityRemoved", EntityRemovedData{}, fromServer},
+	{"UseItem", UseItemData{}, fromClient},
+	{"DropItem", DropItemData{}, fromClient},
+	{"PlayerInventory", PlayerInventoryData{}, fromServer},
+	{"LevelUp", LevelUpData{}, fromServer},
+	{"MatchEnded", MatchEndedData{}, fromServer},
+	{"Scoreboard", ScoreboardData{}, fromServer},
+	{"CreateRoom", CreateRoomData{}, fromClient},
+	{"RoomCreated", RoomCreatedData{}, fromServer},
+	{"GameModeState", GameModeStateData{}, fromServer},
+	{"GameModeWon", GameModeWonData{}, fromServer},
+	{"StartVote", StartVoteData{}, fromClient},
+	{"CastVote", CastVoteData{}, fromClient},
+	{"VoteUpdate", VoteStatusData{}, fromServer},
+	{"VoteEnded", VoteEndedData{}, fromServer},
+	{"PauseRoom", nil, fromClient},
+	{"ResumeRoom", nil, fromClient},
+	{"RoomPaused", RoomPauseData{}, fromServer},
+	{"RoomResumed", RoomPauseData{}, fromServer},
+	{"WorldEvent", WorldEventData{}, fromServer},
+	{"RulesChanged", GameRules{}, fromServer},
+
+	// Social
+	{"Chat", ChatData{}, fromBoth},
+	{"Muted", MutedData{}, fromServer},
+	{"Whisper", WhisperRequestData{}, fromClient},
+	{"Whisper", WhisperData{}, fromServer},
+	{"FriendAdd", FriendData{}, fromClient},
+	{"FriendAccept", FriendData{}, fromClient},
+	{"FriendRemove", FriendData{}, fromClient},
+	{"FriendListRequest", nil, fromClient},
+	{"FriendList", FriendListData{}, fromServer},
+	{"FriendRequest", FriendPresenceData{}, fromServer},
+	{"FriendAccepted", FriendPresenceData{}, fromServer},
+	{"FriendOnline", FriendPresenceData{}, fromServer},
+	{"FriendOffline", FriendPresenceData{}, fromServer},
+	{"PartyInvite", PartyInviteData{}, fromClient},
+	{"PartyAccept", PartyAcceptData{}, fromClient},
+	{"PartyLeave", nil, fromClient},
+	{"PartyChat", PartyChatData{}, fromBoth},
+	{"PartyInvited", PartyInvitedData{}, fromServer},
+	{"PartyUpdate", PartyUpdateData{}, fromServer},
+	{"PartyLeft", PartyLeftData{}, fromServer},
+	{"GuildCreate", GuildCreateData{}, fromClient},
+	{"GuildJoin", GuildJoinData{}, fromClient},
+	{"GuildLeave", nil, fromClient},
+	{"GuildPromote", GuildPromoteData{}, fromClient},
+	{"GuildInfoRequest", nil, fromClient},
+	{"GuildChat", GuildChatData{}, fromBoth},
+	{"GuildUpdate", GuildUpdateData{}, fromServer},
+	{"GuildLeft", GuildLeftData{}, fromServer},
+	{"MailSend", MailSendData{}, fromClient},
+	{"MailListRequest", nil, fromClient},
+	{"MailRead", MailIDData{}, fromClient},
+	{"MailDelete", MailIDData{}, fromClient},
+	{"MailSent", MailIDData{}, fromServer},
+	{"MailReceived", MailData{}, fromServer},
+	{"MailList", MailData{}, fromServer},
+
+	// Stats and settings
+	{"PlayerStatsRequest", PlayerStatsRequestData{}, fromClient},
+	{"PlayerStats", PlayerStatsData{}, fromServer},
+	{"LeaderboardRequest", LeaderboardRequestData{}, fromClient},
+	{"LeaderboardResponse", LeaderboardResponseData{}, fromServer},
+	{"GuildLeaderboardRequest", GuildLeaderboardRequestData{}, fromClient},
+	{"GuildLeaderboard", GuildLeaderboardData{}, fromServer},
+	{"PrivacySettings", PrivacySettingsData{}, fromClient},
+	{"PrivacySettings", store.PrivacySettings{}, fromServer},
+	{"GetPreferences", nil, fromClient},
+	{"SetPreferences", SetPreferencesData{}, fromClient},
+	{"Preferences", PreferencesData{}, fromServer},
+	{"ConnectionQuality", ConnectionQualityData{}, fromServer},
+
+	// Operator notices
+	{"Error", ErrorData{}, fromServer},
+	{"Announcement", AnnouncementData{}, fromServer},
+	{"Moderate", ModerateData{}, fromClient},
+	{"Moderated", ModeratedData{}, fromServer},
+	{"ReportPlayer", ReportPlayerData{}, fromClient},
+	{"ReportFiled", ReportFiledData{}, fromServer},
+	{"Maintenance", MaintenanceData{}, fromServer},
+}
+
+// ClientMessages returns the specs of the message types clients send.
+func ClientMessages() []MessageSpec {
+	var specs []MessageSpec
+	for _, spec := range messageCatalog {
+		if spec.Sender != fromServer {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
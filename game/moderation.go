@@ -0,0 +1,175 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const (
+	defaultMuteDuration = 10 * time.Minute
+	maxMuteDuration     = 7 * 24 * time.Hour
+)
+
+// Actions of a Moderate command.
+const (
+	ModerateMute   = "mute"
+	ModerateUnmute = "unmute"
+	ModerateKick   = "kick"
+)
+
+// roleRanks orders the roles, so that staff can only moderate players of a
+// lower role than their own.
+var roleRanks = map[string]int{RolePlayer: 0, RoleModerator: 1, RoleAdmin: 2, RoleOwner: 3}
+
+// Moderation is a checked Moderate command.
+type Moderation struct {
+	Action string
+	Actor  string // the moderator's username
+	Target uuid.UUID
+	Until  time.Time // end of a mute
+	Reason string
+}
+
+// CheckModeration validates a Moderate command sent by a player and checks
+// that the role of their account allows it over the target's.
+func CheckModeration(ctx context.Context, database store.Store, moderatorID uuid.UUID, message *GameMessage, now time.Time) (Moderation, error) {
+	var data ModerateData
+	if err := DecodeMessageData(message.Data, &data); err != nil {
+		return Moderation{}, errors.New("invalid Moderate data")
+	}
+
+	var perm Permission
+	reason := data.Reason
+	switch data.Action {
+	case ModerateMute:
+		perm = PermMute
+		if reason == "" {
+			reason = "Muted by a moderator"
+		}
+	case ModerateUnmute:
+		perm = PermMute
+	case ModerateKick:
+		perm = PermKick
+		if reason == "" {
+			reason = "Kicked by a moderator"
+		}
+	default:
+		return Moderation{}, fmt.Errorf("action must be %s, %s or %s", ModerateMute, ModerateUnmute, ModerateKick)
+	}
+	if data.PlayerID == uuid.Nil {
+		return Moderation{}, errors.New("player_id is required")
+	}
+	if data.PlayerID == moderatorID {
+		return Moderation{}, errors.New("you cannot moderate yourself")
+	}
+
+	moderator, err := staffAccount(ctx, database, moderatorID)
+	if err != nil {
+		return Moderation{}, err
+	}
+	if moderator == nil || !RoleAllows(moderator.Role, perm) {
+		return Moderation{}, ErrStaffForbidden
+	}
+	target, err := staffAccount(ctx, database, data.PlayerID)
+	if err != nil {
+		return Moderation{}, err
+	}
+	if target != nil && moderator.Role != RoleOwner && roleRanks[target.Role] >= roleRanks[moderator.Role] {
+		return Moderation{}, errors.New("you cannot moderate staff of your own role or above")
+	}
+
+	moderation := Moderation{Action: data.Action, Actor: moderator.Username, Target: data.PlayerID, Reason: reason}
+	if data.Action == ModerateMute {
+		duration := time.Duration(data.DurationSeconds) * time.Second
+		switch {
+		case duration < 0 || duration > maxMuteDuration:
+			return Moderation{}, fmt.Errorf("duration_seconds must be between 0 and %d", int(maxMuteDuration.Seconds()))
+		case duration == 0:
+			duration = defaultMuteDuration
+		}
+		moderation.Until = now.Add(duration)
+	}
+	return moderation, nil
+}
+
+// staffAccount loads the account of a player, nil for guests. A failure is
+// logged and shown to the moderator as a generic error.
+func staffAccount(ctx context.Context, database store.Store, playerID uuid.UUID) (*store.Account, error) {
+	account, err := database.GetAccount(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load the account of %s for moderation: %v", playerID, err)
+		return nil, errors.New("failed to check roles")
+	}
+	return account, nil
+}
+
+// Record writes a moderation to the audit log, under the action of its
+// command.
+func (m Moderation) Record(ctx context.Context, database store.Store) {
+	RecordAdminAction(ctx, database, m.Actor, m.Action, m.Target.String(), m.Reason)
+}
+
+// Reply confirms a moderation to the moderator.
+func (m Moderation) Reply(request *GameMessage) GameMessage {
+	return NewReply(request, NewModeratedMessage(m.Action, m.Target, m.Until))
+}
+
+// Mutes holds the players moderators muted from chat. Mutes are kept in
+// memory, so a restart lifts them.
+type Mutes struct {
+	mu    sync.Mutex
+	muted map[uuid.UUID]time.Time // until when
+}
+
+func NewMutes() *Mutes {
+	return &Mutes{muted: make(map[uuid.UUID]time.Time)}
+}
+
+// Mute silences a player until the given time.
+func (m *Mutes) Mute(playerID uuid.UUID, until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.muted[playerID] = until
+}
+
+// Unmute lifts a player's mute and reports whether they were muted.
+func (m *Mutes) Unmute(playerID uuid.UUID, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, muted := m.muted[playerID]
+	delete(m.muted, playerID)
+	return muted && now.Before(until)
+}
+
+// Check rejects chat from a muted player with an InputError.
+func (m *Mutes) Check(playerID uuid.UUID, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, muted := m.muted[playerID]
+	if !muted {
+		return nil
+	}
+	if !now.Before(until) {
+		delete(m.muted, playerID)
+		return nil
+	}
+	return &InputError{Code: errorCodeMuted, Message: fmt.Sprintf("you are muted until %s", until.UTC().Format(time.RFC3339))}
+}
+
+// ChatMessage reports whether a client message type is chat, which muted
+// players may not send.
+func ChatMessage(messageType string) bool {
+	switch messageType {
+	case "Chat", "Whisper", "PartyChat", "GuildChat":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package game
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// A player who has not moved for velocityStaleAfter is reported at rest,
+	// so that clients stop extrapolating.
+	velocityStaleAfter = 250 * time.Millisecond
+	// minVelocityInterval keeps moves arriving in a burst from producing
+	// huge velocities.
+	minVelocityInterval = 10 * time.Millisecond
+	// An impulse dies away at pushDecay per second, so a push at speed v
+	// carries a player v/pushDecay units in all. It is over once slower
+	// than minPushSpeed.
+	pushDecay    = 6
+	minPushSpeed = 5
+)
+
+// MoveTo updates the position and the velocity clients extrapolate with. The
+// velocity is smoothed over consecutive moves.
+func (p *Player) MoveTo(x, y float32, now time.Time) {
+	elapsed := now.Sub(p.movedAt)
+	if p.movedAt.IsZero() || elapsed >= velocityStaleAfter {
+		p.VX, p.VY = 0, 0
+	} else {
+		if elapsed < minVelocityInterval {
+			elapsed = minVelocityInterval
+		}
+		seconds := float32(elapsed.Seconds())
+		p.VX = (p.VX + (x-p.X)/seconds) / 2
+		p.VY = (p.VY + (y-p.Y)/seconds) / 2
+	}
+
+	p.X, p.Y = x, y
+	p.movedAt = now
+	p.steered = false
+}
+
+// Steer sets the position and a velocity the server keeps the player moving
+// at until the next input, so that positions stay consistent while a
+// client's updates are sparse. A zero velocity stops the player. Velocities
+// faster than maxSpeed are scaled down to it.
+func (p *Player) Steer(x, y, vx, vy, maxSpeed float32, now time.Time) {
+	if speed := float32(math.Hypot(float64(vx), float64(vy))); speed > maxSpeed {
+		vx *= maxSpeed / speed
+		vy *= maxSpeed / speed
+	}
+
+	p.X, p.Y = x, y
+	p.VX, p.VY = vx, vy
+	p.movedAt = now
+	p.steered = vx != 0 || vy != 0
+}
+
+// Advance moves a steered or pushed player to where its velocity has taken
+// it by now.
+func (p *Player) Advance(now time.Time) {
+	p.SettlePush(now)
+	// The game loop simulates up to a step behind the inputs stamping movedAt
+	if !p.steered || !now.After(p.movedAt) {
+		return
+	}
+	seconds := float32(now.Sub(p.movedAt).Seconds())
+	p.X += p.VX * seconds
+	p.Y += p.VY * seconds
+	p.movedAt = now
+}
+
+// Push gives a player an impulse, a velocity the server moves it at on top
+// of its own movement that dies away within a second. Its client's moves
+// are corrected rather than trusted until the push is over.
+func (p *Player) Push(vx, vy float32, now time.Time) {
+	p.SettlePush(now)
+	p.pushVX += vx
+	p.pushVY += vy
+	p.pushedAt = now
+}
+
+// Pushed reports whether an impulse is still moving the player.
+func (p *Player) Pushed() bool {
+	return p.pushVX != 0 || p.pushVY != 0
+}
+
+// SettlePush moves a pushed player as far as its impulse has carried it by
+// now.
+func (p *Player) SettlePush(now time.Time) {
+	if !p.Pushed() || !now.After(p.pushedAt) {
+		return
+	}
+	decay := math.Exp(-pushDecay * now.Sub(p.pushedAt).Seconds())
+	travelled := float32((1 - decay) / pushDecay)
+	p.X += p.pushVX * travelled
+	p.Y += p.pushVY * travelled
+	p.pushVX *= float32(decay)
+	p.pushVY *= float32(decay)
+	if math.Hypot(float64(p.pushVX), float64(p.pushVY)) < minPushSpeed {
+		p.pushVX, p.pushVY = 0, 0
+	}
+	p.pushedAt = now
+}
+
+// ExplosionPush is the impulse an explosion of speed at x, y gives a player,
+// away from the centre and weaker towards the edge of radius. It reports
+// false if the player is out of reach.
+func ExplosionPush(p Player, x, y, radius, speed float32) (float32, float32, bool) {
+	dx, dy := float64(p.X-x), float64(p.Y-y)
+	distance := math.Hypot(dx, dy)
+	if distance >= float64(radius) {
+		return 0, 0, false
+	}
+	if distance == 0 {
+		dx, distance = 1, 1
+	}
+	scale := float64(speed) * (1 - distance/float64(radius)) / distance
+	return float32(dx * scale), float32(dy * scale), true
+}
+
+// KnockbackPush is the impulse of speed an attacker at x, y gives a target,
+// directly away from the attacker.
+func KnockbackPush(target Player, x, y, speed float32) (float32, float32) {
+	dx, dy := float64(target.X-x), float64(target.Y-y)
+	distance := math.Hypot(dx, dy)
+	if distance == 0 || speed <= 0 {
+		return 0, 0
+	}
+	scale := float64(speed) / distance
+	return float32(dx * scale), float32(dy * scale)
+}
+
+// Teleport places a player at rest at a position.
+func (p *Player) Teleport(x, y float32, now time.Time) {
+	p.X, p.Y = x, y
+	p.VX, p.VY = 0, 0
+	p.pushVX, p.pushVY = 0, 0
+	p.movedAt = now
+	p.steered = false
+}
+
+// Stop halts a player where it is by now.
+func (p *Player) Stop(now time.Time) {
+	p.Advance(now)
+	p.VX, p.VY = 0, 0
+	p.steered = false
+}
+
+// SnapshotAt returns the player as sent in a snapshot taken at now. Steered
+// and pushed players are extrapolated to now; others are at rest once their
+// moves stop. The velocity shown includes any push.
+func (p Player) SnapshotAt(now time.Time) Player {
+	p.SettlePush(now)
+	if p.steered {
+		p.Advance(now)
+	} else if now.Sub(p.movedAt) >= velocityStaleAfter {
+		p.VX, p.VY = 0, 0
+	}
+	p.VX += p.pushVX
+	p.VY += p.pushVY
+	return p
+}
+
+// Moving reports whether a snapshot shows the player in motion.
+func (p Player) Moving() bool {
+	return p.VX != 0 || p.VY != 0
+}
+
+// MoveVelocity reads the optional velocity of a PlayerMove. steering is true
+// when the move carries one, even if it is zero.
+func MoveVelocity(data map[string]interface{}) (vx, vy float32, steering bool) {
+	x, hasX := data["vx"].(float64)
+	y, hasY := data["vy"].(float64)
+	return float32(x), float32(y), hasX || hasY
+}
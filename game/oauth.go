@@ -0,0 +1,238 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"online-server-go/store"
+)
+
+const (
+	// oauthStateTTL is how long a user has to finish logging in with a
+	// provider.
+	oauthStateTTL = 10 * time.Minute
+	// maxUsernameAttempts bounds the suffixes tried when an OAuth user's
+	// name is taken.
+	maxUsernameAttempts = 5
+)
+
+var (
+	// providerNamePattern keeps provider names safe in URL paths.
+	providerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,24}$`)
+
+	errInvalidOAuthState = errors.New("OAuth login expired or was not started here")
+)
+
+// OAuthProvider is an OAuth 2.0 login provider from OAUTH_PROVIDERS_FILE.
+// The user info endpoint must return a JSON object holding the user's ID in
+// SubjectField and a suggested username in NameField.
+type OAuthProvider struct {
+	Name         string   `json:"name"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	AuthURL      string   `json:"auth_url"`
+	TokenURL     string   `json:"token_url"`
+	UserInfoURL  string   `json:"userinfo_url"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	SubjectField string   `json:"subject_field"` // default "sub"
+	NameField    string   `json:"name_field"`    // default "preferred_username"
+}
+
+type oauthState struct {
+	provider  string
+	expiresAt time.Time
+}
+
+// LoadOAuthProviders reads and validates the providers file. An empty path
+// yields no providers.
+func LoadOAuthProviders(path string) ([]OAuthProvider, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OAuth providers file: %w", err)
+	}
+	var providers []OAuthProvider
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&providers); err != nil {
+		return nil, fmt.Errorf("failed to parse OAuth providers file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(providers))
+	for i := range providers {
+		provider := &providers[i]
+		switch {
+		case !providerNamePattern.MatchString(provider.Name):
+			return nil, fmt.Errorf("provider name %q must be 3 to 24 letters, digits or underscores", provider.Name)
+		case seen[provider.Name]:
+			return nil, fmt.Errorf("provider %s is listed twice", provider.Name)
+		case provider.ClientID == "" || provider.AuthURL == "" || provider.TokenURL == "" ||
+			provider.UserInfoURL == "" || provider.RedirectURL == "":
+			return nil, fmt.Errorf("provider %s needs client_id, auth_url, token_url, userinfo_url and redirect_url", provider.Name)
+		}
+		seen[provider.Name] = true
+		if provider.SubjectField == "" {
+			provider.SubjectField = "sub"
+		}
+		if provider.NameField == "" {
+			provider.NameField = "preferred_username"
+		}
+	}
+	return providers, nil
+}
+
+func (p OAuthProvider) config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Endpoint:     oauth2.Endpoint{AuthURL: p.AuthURL, TokenURL: p.TokenURL},
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+	}
+}
+
+// StartOAuth returns the provider URL to send the user to.
+func (a *Accounts) StartOAuth(providerName string) (string, bool) {
+	provider, exists := a.providers[providerName]
+	if !exists {
+		return "", false
+	}
+
+	state := uuid.New().String()
+	now := time.Now()
+	a.mu.Lock()
+	for pending, s := range a.states {
+		if now.After(s.expiresAt) {
+			delete(a.states, pending)
+		}
+	}
+	a.states[state] = oauthState{provider: providerName, expiresAt: now.Add(oauthStateTTL)}
+	a.mu.Unlock()
+
+	return provider.config().AuthCodeURL(state), true
+}
+
+// FinishOAuth exchanges the code the provider redirected back with, then
+// logs in the account linked to the provider's user, creating it on first
+// login.
+func (a *Accounts) FinishOAuth(ctx context.Context, providerName, state, code string) (*AuthSession, error) {
+	a.mu.Lock()
+	pending, exists := a.states[state]
+	delete(a.states, state)
+	a.mu.Unlock()
+	if !exists || pending.provider != providerName || time.Now().After(pending.expiresAt) {
+		return nil, errInvalidOAuthState
+	}
+	provider := a.providers[providerName]
+
+	token, err := provider.config().Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange OAuth code: %w", err)
+	}
+	subject, name, err := provider.userInfo(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := store.AccountIdentity{Provider: providerName, Subject: subject}
+	account, err := a.database.GetAccountByIdentity(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		if account, err = a.createOAuthAccount(ctx, identity, name); err != nil {
+			return nil, err
+		}
+	}
+	return a.issueToken(ctx, account)
+}
+
+// createOAuthAccount names the account after the provider's user, adding a
+// random suffix while the name is taken.
+func (a *Accounts) createOAuthAccount(ctx context.Context, identity store.AccountIdentity, name string) (*store.Account, error) {
+	base := sanitizeUsername(name)
+	username := base
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		account := &store.Account{ID: uuid.New(), Username: username}
+		err := storeInputError(a.database.CreateAccount(ctx, account, &identity))
+		if err == nil {
+			metrics.Inc("accounts_registered")
+			return account, nil
+		}
+		if !errors.Is(err, errUsernameTaken) {
+			return nil, err
+		}
+		if len(base) > 19 {
+			base = base[:19]
+		}
+		username = base + "_" + uuid.New().String()[:4]
+	}
+	return nil, errUsernameTaken
+}
+
+// userInfo fetches the provider's ID and suggested name for the user.
+func (p OAuthProvider) userInfo(ctx context.Context, token *oauth2.Token) (string, string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UserInfoURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create user info request: %w", err)
+	}
+	response, err := p.config().Client(ctx, token).Do(request)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch OAuth user info: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to fetch OAuth user info: %s", response.Status)
+	}
+
+	var info map[string]interface{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber() // numeric IDs must not turn into floats
+	if err := decoder.Decode(&info); err != nil {
+		return "", "", fmt.Errorf("failed to parse OAuth user info: %w", err)
+	}
+
+	subject, ok := info[p.SubjectField]
+	if !ok || subject == nil || fmt.Sprint(subject) == "" {
+		return "", "", fmt.Errorf("OAuth user info has no %q field", p.SubjectField)
+	}
+	name, _ := info[p.NameField].(string)
+	return fmt.Sprint(subject), name, nil
+}
+
+// sanitizeUsername turns a provider's user name into a valid username, see
+// ValidatePlayerName.
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		case r == '-' || r == '.' || r == ' ':
+			b.WriteRune('_')
+		}
+		if b.Len() == maxNameLength {
+			break
+		}
+	}
+	username := b.String()
+	if ValidatePlayerName(username) != nil {
+		username = "user_" + uuid.New().String()[:8]
+	}
+	return username
+}
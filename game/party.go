@@ -0,0 +1,280 @@
+package game
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const maxPartySize = 8
+
+type Party struct {
+	ID       string
+	LeaderID uuid.UUID
+	Members  []uuid.UUID // in join order; the next leader is the earliest member
+}
+
+// PartyManager implements party invites, membership and party chat. Party
+// members are always placed into their leader's match room.
+type PartyManager struct {
+	matchmaker *Matchmaker
+	matches    *MatchTracker
+
+	mu       sync.Mutex
+	parties  map[string]*Party
+	memberOf map[uuid.UUID]string
+	invites  map[uuid.UUID]map[string]uuid.UUID // invitee -> party ID -> inviter
+}
+
+func NewPartyManager(matchmaker *Matchmaker, matches *MatchTracker) *PartyManager {
+	return &PartyManager{
+		matchmaker: matchmaker,
+		matches:    matches,
+		parties:    make(map[string]*Party),
+		memberOf:   make(map[uuid.UUID]string),
+		invites:    make(map[uuid.UUID]map[string]uuid.UUID),
+	}
+}
+
+// HandleMessage processes a party message from playerID, replying and
+// notifying other members through dir.
+func (pm *PartyManager) HandleMessage(ctx context.Context, playerID uuid.UUID, playerName string, message *GameMessage, dir PlayerDirectory) {
+	switch message.Type {
+	case "PartyInvite":
+		var data PartyInviteData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.TargetID == uuid.Nil {
+			pm.sendError(playerID, "target_id is required", dir)
+			return
+		}
+		pm.invite(playerID, playerName, data.TargetID, dir)
+
+	case "PartyAccept":
+		var data PartyAcceptData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.PartyID == "" {
+			pm.sendError(playerID, "party_id is required", dir)
+			return
+		}
+		pm.accept(ctx, playerID, data.PartyID, dir)
+
+	case "PartyLeave":
+		if !pm.Leave(playerID, dir) {
+			pm.sendError(playerID, "You are not in a party", dir)
+		}
+
+	case "PartyChat":
+		var data PartyChatData
+		if err := DecodeMessageData(message.Data, &data); err != nil || data.Message == "" {
+			pm.sendError(playerID, "message is required", dir)
+			return
+		}
+		text, err := SanitizeChat(data.Message)
+		if err != nil {
+			errorMsg := NewInputErrorMessage(err)
+			dir.SendToPlayer(playerID, &errorMsg)
+			return
+		}
+		pm.chat(playerID, text, dir)
+	}
+}
+
+func (pm *PartyManager) invite(playerID uuid.UUID, playerName string, targetID uuid.UUID, dir PlayerDirectory) {
+	if targetID == playerID {
+		pm.sendError(playerID, "You cannot invite yourself", dir)
+		return
+	}
+	if !dir.IsOnline(targetID) {
+		pm.sendError(playerID, "Player is not online", dir)
+		return
+	}
+
+	pm.mu.Lock()
+	party := pm.partyOf(playerID)
+	created := party == nil
+	if created {
+		party = &Party{
+			ID:       uuid.New().String(),
+			LeaderID: playerID,
+			Members:  []uuid.UUID{playerID},
+		}
+		pm.parties[party.ID] = party
+		pm.memberOf[playerID] = party.ID
+	}
+
+	var errText string
+	switch {
+	case party.LeaderID != playerID:
+		errText = "Only the party leader can invite players"
+	case pm.memberOf[targetID] == party.ID:
+		errText = "Player is already in your party"
+	case len(party.Members) >= maxPartySize:
+		errText = "Your party is full"
+	}
+	if errText == "" {
+		if pm.invites[targetID] == nil {
+			pm.invites[targetID] = make(map[string]uuid.UUID)
+		}
+		pm.invites[targetID][party.ID] = playerID
+	}
+	update := pm.updateMessage(party)
+	pm.mu.Unlock()
+
+	if errText != "" {
+		pm.sendError(playerID, errText, dir)
+		return
+	}
+
+	if created {
+		logrus.Infof("Player %s created party %s", playerID, party.ID)
+		dir.SendToPlayer(playerID, &update)
+	}
+	inviteMsg := NewPartyInvitedMessage(party.ID, playerID, playerName)
+	dir.SendToPlayer(targetID, &inviteMsg)
+}
+
+func (pm *PartyManager) accept(ctx context.Context, playerID uuid.UUID, partyID string, dir PlayerDirectory) {
+	pm.mu.Lock()
+	_, invited := pm.invites[playerID][partyID]
+	party, exists := pm.parties[partyID]
+	if !invited || !exists {
+		pm.mu.Unlock()
+		pm.sendError(playerID, "No pending invite to that party", dir)
+		return
+	}
+	if len(party.Members) >= maxPartySize {
+		pm.mu.Unlock()
+		pm.sendError(playerID, "That party is full", dir)
+		return
+	}
+	delete(pm.invites[playerID], partyID)
+	pm.mu.Unlock()
+
+	// Joining a party leaves any previous one
+	pm.Leave(playerID, dir)
+
+	pm.mu.Lock()
+	if _, exists := pm.parties[partyID]; !exists || len(party.Members) >= maxPartySize {
+		pm.mu.Unlock()
+		pm.sendError(playerID, "That party is no longer available", dir)
+		return
+	}
+	party.Members = append(party.Members, playerID)
+	pm.memberOf[playerID] = partyID
+	leaderID := party.LeaderID
+	pm.mu.Unlock()
+
+	room := pm.matchmaker.PlaceWith(ctx, playerID, leaderID)
+	pm.matches.AddParticipant(playerID, room)
+	logrus.Infof("Player %s joined party %s in room %s", playerID, partyID, room)
+
+	pm.broadcastUpdate(partyID, dir)
+}
+
+func (pm *PartyManager) chat(playerID uuid.UUID, text string, dir PlayerDirectory) {
+	pm.mu.Lock()
+	party := pm.partyOf(playerID)
+	var members []uuid.UUID
+	if party != nil {
+		members = append(members, party.Members...)
+	}
+	pm.mu.Unlock()
+
+	if party == nil {
+		pm.sendError(playerID, "You are not in a party", dir)
+		return
+	}
+
+	chatMsg := NewPartyChatMessage(party.ID, playerID, text)
+	for _, memberID := range members {
+		dir.SendToPlayer(memberID, &chatMsg)
+	}
+}
+
+// Leave removes playerID from their party and drops their pending invites.
+// The earliest remaining member takes over as leader, and a party left with
+// a single member is disbanded. It reports whether the player was in a party.
+func (pm *PartyManager) Leave(playerID uuid.UUID, dir PlayerDirectory) bool {
+	pm.mu.Lock()
+	delete(pm.invites, playerID)
+
+	party := pm.partyOf(playerID)
+	if party == nil {
+		pm.mu.Unlock()
+		return false
+	}
+
+	delete(pm.memberOf, playerID)
+	for i, memberID := range party.Members {
+		if memberID == playerID {
+			party.Members = append(party.Members[:i], party.Members[i+1:]...)
+			break
+		}
+	}
+
+	var disbanded []uuid.UUID
+	if len(party.Members) < 2 {
+		disbanded = party.Members
+		for _, memberID := range disbanded {
+			delete(pm.memberOf, memberID)
+		}
+		for _, pending := range pm.invites {
+			delete(pending, party.ID)
+		}
+		delete(pm.parties, party.ID)
+	} else if party.LeaderID == playerID {
+		party.LeaderID = party.Members[0]
+	}
+	pm.mu.Unlock()
+
+	leftMsg := NewPartyLeftMessage(party.ID)
+	dir.SendToPlayer(playerID, &leftMsg)
+
+	if disbanded != nil {
+		logrus.Infof("Party %s disbanded", party.ID)
+		for _, memberID := range disbanded {
+			dir.SendToPlayer(memberID, &leftMsg)
+		}
+	} else {
+		pm.broadcastUpdate(party.ID, dir)
+	}
+	return true
+}
+
+// partyOf requires pm.mu to be held by the caller.
+func (pm *PartyManager) partyOf(playerID uuid.UUID) *Party {
+	partyID, exists := pm.memberOf[playerID]
+	if !exists {
+		return nil
+	}
+	return pm.parties[partyID]
+}
+
+// updateMessage requires pm.mu to be held by the caller.
+func (pm *PartyManager) updateMessage(party *Party) GameMessage {
+	room, _ := pm.matchmaker.RoomOf(party.LeaderID)
+	members := make([]uuid.UUID, len(party.Members))
+	copy(members, party.Members)
+	return NewPartyUpdateMessage(party.ID, party.LeaderID, members, room)
+}
+
+func (pm *PartyManager) broadcastUpdate(partyID string, dir PlayerDirectory) {
+	pm.mu.Lock()
+	party, exists := pm.parties[partyID]
+	if !exists {
+		pm.mu.Unlock()
+		return
+	}
+	update := pm.updateMessage(party)
+	members := append([]uuid.UUID(nil), party.Members...)
+	pm.mu.Unlock()
+
+	for _, memberID := range members {
+		dir.SendToPlayer(memberID, &update)
+	}
+}
+
+func (pm *PartyManager) sendError(playerID uuid.UUID, text string, dir PlayerDirectory) {
+	errorMsg := NewErrorMessage(text)
+	dir.SendToPlayer(playerID, &errorMsg)
+}
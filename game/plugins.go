@@ -0,0 +1,230 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/gameplugin"
+)
+
+// pluginActionQueue is how many calls hooks may have made on the server
+// before further calls are dropped.
+const pluginActionQueue = 256
+
+// LoadedPlugin is a Go plugin loaded from PLUGIN_DIR.
+type LoadedPlugin struct {
+	Name  string
+	hooks *gameplugin.Hooks
+}
+
+// LoadPlugins opens every .so file in dir, in name order. Each must export
+// a gameplugin.Hooks variable named Hooks.
+func LoadPlugins(dir string) ([]LoadedPlugin, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read PLUGIN_DIR: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %w", err)
+	}
+	sort.Strings(paths)
+
+	plugins := make([]LoadedPlugin, 0, len(paths))
+	for _, path := range paths {
+		opened, err := plugin.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load plugin %s: %w", path, err)
+		}
+		symbol, err := opened.Lookup("Hooks")
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s does not export Hooks: %w", path, err)
+		}
+		hooks, ok := symbol.(*gameplugin.Hooks)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s exports Hooks as a %T, not a gameplugin.Hooks", path, symbol)
+		}
+		plugins = append(plugins, LoadedPlugin{Name: strings.TrimSuffix(filepath.Base(path), ".so"), hooks: hooks})
+	}
+	return plugins, nil
+}
+
+// pluginHost is the game the plugins customize.
+type pluginHost interface {
+	GameAdmin
+	SendToPlayer(playerID uuid.UUID, message *GameMessage) bool
+}
+
+// Plugins calls the hooks of the loaded plugins. Hooks that panic are
+// logged and skipped, so a broken plugin cannot take the server down. A nil
+// *Plugins has no hooks.
+type Plugins struct {
+	plugins []LoadedPlugin
+	game    pluginHost
+	actions chan func()
+}
+
+// NewPlugins returns nil when no plugins are loaded.
+func NewPlugins(plugins []LoadedPlugin) *Plugins {
+	if len(plugins) == 0 {
+		return nil
+	}
+	return &Plugins{plugins: plugins, actions: make(chan func(), pluginActionQueue)}
+}
+
+// Attach sets the game the hooks act on and starts calling OnTick.
+func (p *Plugins) Attach(game pluginHost) {
+	if p == nil {
+		return
+	}
+	p.game = game
+	go p.runActions()
+	go p.runTicks()
+}
+
+// HandleEvent calls OnPlayerJoin for every PlayerJoined event.
+func (p *Plugins) HandleEvent(ctx context.Context, event GameEvent) {
+	joined, ok := event.(PlayerJoined)
+	if p == nil || !ok {
+		return
+	}
+	player := pluginPlayer(joined.Player)
+	p.each("OnPlayerJoin", func(hooks *gameplugin.Hooks) {
+		if hooks.OnPlayerJoin != nil {
+			hooks.OnPlayerJoin(p, player)
+		}
+	})
+}
+
+// Action reports whether the plugins let a player carry out an action.
+func (p *Plugins) Action(player Player, action string) bool {
+	if p == nil {
+		return true
+	}
+	allowed := true
+	p.each("OnAction", func(hooks *gameplugin.Hooks) {
+		if allowed && hooks.OnAction != nil {
+			allowed = hooks.OnAction(p, pluginPlayer(player), action)
+		}
+	})
+	return allowed
+}
+
+// Chat returns the chat message to send as the plugins rewrote it, and
+// false if one of them dropped it.
+func (p *Plugins) Chat(player Player, message string) (string, bool) {
+	if p == nil {
+		return message, true
+	}
+	send := true
+	p.each("OnChat", func(hooks *gameplugin.Hooks) {
+		if send && hooks.OnChat != nil {
+			message, send = hooks.OnChat(p, pluginPlayer(player), message)
+		}
+	})
+	if !send {
+		return "", false
+	}
+	// Plugins may not slip past the chat rules
+	message, err := SanitizeChat(message)
+	return message, err == nil
+}
+
+func (p *Plugins) runTicks() {
+	ticker := time.NewTicker(SimulationTick)
+	defer ticker.Stop()
+	var tick uint64
+	for {
+		select {
+		case <-ticker.C:
+			tick++
+			p.each("OnTick", func(hooks *gameplugin.Hooks) {
+				if hooks.OnTick != nil {
+					hooks.OnTick(p, tick)
+				}
+			})
+		}
+	}
+}
+
+// each calls a hook of every plugin, recovering from panics.
+func (p *Plugins) each(hook string, call func(hooks *gameplugin.Hooks)) {
+	for _, loaded := range p.plugins {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					metrics.Inc("plugin_panics")
+					logrus.Errorf("Plugin %s panicked in %s: %v", loaded.Name, hook, r)
+				}
+			}()
+			call(loaded.hooks)
+		}()
+	}
+}
+
+// runActions carries out the calls hooks made on the server. They are
+// queued because hooks often run while the game is locked.
+func (p *Plugins) runActions() {
+	for action := range p.actions {
+		action()
+	}
+}
+
+func (p *Plugins) queue(action func()) {
+	select {
+	case p.actions <- action:
+	default:
+		metrics.Inc("plugin_actions_dropped")
+		logrus.Warn("Plugin action queue is full; dropping a call")
+	}
+}
+
+// Announce implements gameplugin.Server.
+func (p *Plugins) Announce(message string) {
+	p.queue(func() {
+		announcement := NewAnnouncementMessage(message)
+		p.game.BroadcastAll(&announcement)
+	})
+}
+
+// Tell implements gameplugin.Server.
+func (p *Plugins) Tell(playerID, message string) {
+	p.queue(func() {
+		if id, err := uuid.Parse(playerID); err == nil {
+			announcement := NewAnnouncementMessage(message)
+			p.game.SendToPlayer(id, &announcement)
+		}
+	})
+}
+
+// Kick implements gameplugin.Server.
+func (p *Plugins) Kick(playerID, message string) {
+	p.queue(func() {
+		if id, err := uuid.Parse(playerID); err == nil {
+			p.game.Kick(id, DisconnectKicked, message)
+		}
+	})
+}
+
+func pluginPlayer(player Player) gameplugin.Player {
+	return gameplugin.Player{
+		ID:     player.ID.String(),
+		Name:   player.Name,
+		X:      player.X,
+		Y:      player.Y,
+		Health: player.Health,
+		Score:  player.Score,
+		Level:  player.Level,
+	}
+}
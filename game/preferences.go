@@ -0,0 +1,185 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// Limits on the preferences a player saves, such as a name color or their
+// control settings. Values are opaque to the server.
+const (
+	maxPreferences           = 32
+	maxPreferenceNameLength  = 64
+	maxPreferenceValueLength = 1024 // bytes
+	maxPreferencesBytes      = 8192 // names and values together
+)
+
+const errorCodeInvalidPreference = "invalid_preference"
+
+// mergePreferences applies a SetPreferences request to the player's saved
+// preferences and checks the result against the limits.
+func mergePreferences(current map[string]string, data SetPreferencesData) (map[string]string, error) {
+	merged := make(map[string]string, len(current)+len(data.Preferences))
+	for name, value := range current {
+		merged[name] = value
+	}
+	for _, name := range data.Remove {
+		delete(merged, name)
+	}
+	for name, value := range data.Preferences {
+		if err := validatePreference(name, value); err != nil {
+			return nil, err
+		}
+		merged[name] = value
+	}
+
+	if len(merged) > maxPreferences {
+		return nil, &InputError{Code: errorCodeInvalidPreference, Message: fmt.Sprintf("at most %d preferences may be saved", maxPreferences)}
+	}
+	size := 0
+	for name, value := range merged {
+		size += len(name) + len(value)
+	}
+	if size > maxPreferencesBytes {
+		return nil, &InputError{Code: errorCodeInvalidPreference, Message: fmt.Sprintf("preferences may total at most %d bytes", maxPreferencesBytes)}
+	}
+	return merged, nil
+}
+
+func validatePreference(name, value string) error {
+	if name == "" || len(name) > maxPreferenceNameLength {
+		return &InputError{Code: errorCodeInvalidPreference, Message: fmt.Sprintf("preference names must be 1 to %d characters", maxPreferenceNameLength)}
+	}
+	for _, r := range name {
+		if !isNameRune(r) && r != '.' && r != '-' {
+			return &InputError{Code: errorCodeInvalidPreference, Message: "preference names may only contain letters, digits, underscores, dots and dashes"}
+		}
+	}
+	if len(value) > maxPreferenceValueLength {
+		return &InputError{Code: errorCodeInvalidPreference, Message: fmt.Sprintf("preference %s is longer than %d bytes", name, maxPreferenceValueLength)}
+	}
+	if !utf8.ValidString(value) {
+		return &InputError{Code: errorCodeInvalidPreference, Message: fmt.Sprintf("preference %s is not valid UTF-8", name)}
+	}
+	return nil
+}
+
+// updatePreferences applies a SetPreferences request and returns the
+// player's resulting preferences.
+func updatePreferences(ctx context.Context, database store.Store, playerID uuid.UUID, data SetPreferencesData) (map[string]string, error) {
+	current, err := database.GetPreferences(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := mergePreferences(current, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SetPreferences(ctx, playerID, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// HandlePreferences answers GetPreferences and SetPreferences with the
+// player's preferences.
+func HandlePreferences(ctx context.Context, playerID uuid.UUID, message *GameMessage, database store.Store, dir PlayerDirectory) {
+	if message.Type != "SetPreferences" {
+		preferences, err := database.GetPreferences(ctx, playerID)
+		if err != nil {
+			logrus.Errorf("Failed to load preferences of %s: %v", playerID, err)
+			errorMsg := NewErrorMessage("Failed to load preferences")
+			dir.SendToPlayer(playerID, &errorMsg)
+			return
+		}
+		preferencesMsg := NewPreferencesMessage(preferences)
+		dir.SendToPlayer(playerID, &preferencesMsg)
+		return
+	}
+
+	var data SetPreferencesData
+	if err := DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := NewErrorMessage("Invalid preferences")
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	preferences, err := updatePreferences(ctx, database, playerID, data)
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		errorMsg := NewInputErrorMessage(err)
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to save preferences of %s: %v", playerID, err)
+		errorMsg := NewErrorMessage("Failed to save preferences")
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	preferencesMsg := NewPreferencesMessage(preferences)
+	dir.SendToPlayer(playerID, &preferencesMsg)
+}
+
+// handleGetPreferences returns the preferences of the account the bearer
+// token belongs to.
+func (auth *AuthHandler) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	account, ok := auth.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	preferences, err := auth.accounts.database.GetPreferences(r.Context(), account.ID)
+	if err != nil {
+		logrus.Errorf("Failed to load preferences of %s: %v", account.ID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load preferences")
+		return
+	}
+	WriteJSON(w, http.StatusOK, PreferencesData{Preferences: preferences})
+}
+
+// handleSetPreferences applies {"preferences", "remove"} like a
+// SetPreferences message and returns the resulting preferences.
+func (auth *AuthHandler) handleSetPreferences(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	account, ok := auth.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var data SetPreferencesData
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxPreferencesBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "body must be {\"preferences\", \"remove\"}")
+		return
+	}
+
+	preferences, err := updatePreferences(r.Context(), auth.accounts.database, account.ID, data)
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		writeInputError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to save preferences of %s: %v", account.ID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to save preferences")
+		return
+	}
+	WriteJSON(w, http.StatusOK, PreferencesData{Preferences: preferences})
+}
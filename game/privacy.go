@@ -0,0 +1,52 @@
+package game
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// HandlePrivacySettings updates the flags set in a PrivacySettings request
+// and replies with the player's resulting settings.
+func HandlePrivacySettings(ctx context.Context, playerID uuid.UUID, message *GameMessage, database store.Store, dir PlayerDirectory) {
+	var data PrivacySettingsData
+	if err := DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := NewErrorMessage("Invalid privacy settings")
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	settings, err := database.GetPrivacySettings(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load privacy settings of %s: %v", playerID, err)
+		errorMsg := NewErrorMessage("Failed to load privacy settings")
+		dir.SendToPlayer(playerID, &errorMsg)
+		return
+	}
+
+	if data.NoChatLog != nil || data.NoIPStorage != nil || data.AnonymizeEvents != nil {
+		if data.NoChatLog != nil {
+			settings.NoChatLog = *data.NoChatLog
+		}
+		if data.NoIPStorage != nil {
+			settings.NoIPStorage = *data.NoIPStorage
+		}
+		if data.AnonymizeEvents != nil {
+			settings.AnonymizeEvents = *data.AnonymizeEvents
+		}
+
+		if err := database.SetPrivacySettings(ctx, playerID, settings); err != nil {
+			logrus.Errorf("Failed to save privacy settings of %s: %v", playerID, err)
+			errorMsg := NewErrorMessage("Failed to save privacy settings")
+			dir.SendToPlayer(playerID, &errorMsg)
+			return
+		}
+		logrus.Infof("Player %s updated privacy settings: %+v", playerID, settings)
+	}
+
+	settingsMsg := NewPrivacySettingsMessage(settings)
+	dir.SendToPlayer(playerID, &settingsMsg)
+}
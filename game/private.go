@@ -0,0 +1,111 @@
+package game
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"online-server-go/store"
+)
+
+// PrivateStateInterval is how often the UDP server, which has no game loop,
+// sends players their private state.
+const PrivateStateInterval = 200 * time.Millisecond
+
+// PrivateChannel keeps the state only its owner may see, such as exact
+// health, cooldowns and inventory, apart from the public GameState. Each
+// player is sent a PrivateState message with the sections that changed
+// since the last one, and nobody else ever is.
+type PrivateChannel struct {
+	rules     *Rules
+	abilities *Abilities
+
+	mu      sync.Mutex
+	players map[uuid.UUID]*privateState
+}
+
+type privateState struct {
+	sent      PrivateStateData  // every section as last sent
+	inventory *InventoryDetails // set since the last update, nil if unchanged
+}
+
+func NewPrivateChannel(rules *Rules, abilities *Abilities) *PrivateChannel {
+	return &PrivateChannel{
+		rules:     rules,
+		abilities: abilities,
+		players:   make(map[uuid.UUID]*privateState),
+	}
+}
+
+func (pc *PrivateChannel) state(playerID uuid.UUID) *privateState {
+	state, exists := pc.players[playerID]
+	if !exists {
+		state = &privateState{sent: PrivateStateData{PlayerID: playerID}}
+		pc.players[playerID] = state
+	}
+	return state
+}
+
+// SetInventory queues a player's inventory for their next update.
+func (pc *PrivateChannel) SetInventory(playerID uuid.UUID, items []store.InventoryItem) {
+	if items == nil {
+		items = []store.InventoryItem{}
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.state(playerID).inventory = &InventoryDetails{Items: items}
+}
+
+// Update returns the PrivateState message due to a player, built from the
+// player as of now, or false if nothing changed since the last one. Its
+// cooldowns are read from the player, which the caller must hold still.
+func (pc *PrivateChannel) Update(p Player, now time.Time) (GameMessage, bool) {
+	health := &HealthDetails{Current: p.Health, Max: MaxHealth}
+	if p.Health <= 0 {
+		if respawnAt := pc.rules.Current().RespawnAt(p.diedAt); !respawnAt.IsZero() {
+			health.RespawnAt = respawnAt.UnixMilli()
+		}
+	}
+	cooldowns := pc.abilities.Cooldowns(p)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	state := pc.state(p.ID)
+
+	update := PrivateStateData{PlayerID: p.ID}
+	changed := false
+	if !reflect.DeepEqual(health, state.sent.Health) {
+		update.Health = health
+		state.sent.Health = health
+		changed = true
+	}
+	if !reflect.DeepEqual(cooldowns, state.sent.Cooldowns) {
+		update.Cooldowns = cooldowns
+		state.sent.Cooldowns = cooldowns
+		changed = true
+	}
+	if state.inventory != nil {
+		update.Inventory = state.inventory
+		state.sent.Inventory = state.inventory
+		state.inventory = nil
+		changed = true
+	}
+	if !changed {
+		return GameMessage{}, false
+	}
+
+	state.sent.Sequence++
+	update.Sequence = state.sent.Sequence
+	update.SentAt = now.UnixMilli()
+	return NewPrivateStateMessage(update), true
+}
+
+// Forget drops the state of a player who left, so that a rejoin starts
+// with a full update.
+func (pc *PrivateChannel) Forget(playerID uuid.UUID) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.players, playerID)
+}
@@ -0,0 +1,89 @@
+package game
+
+import (
+	"context"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// xpPerLevelStep scales the level curve: reaching level n takes
+// xpPerLevelStep*(n-1)^2 total XP.
+const xpPerLevelStep = 100
+
+func LevelForXP(xp int64) int {
+	if xp <= 0 {
+		return 1
+	}
+	level := int(math.Sqrt(float64(xp)/xpPerLevelStep)) + 1
+	// Correct for floating point error at exact level boundaries
+	for int64(level)*int64(level)*xpPerLevelStep <= xp {
+		level++
+	}
+	for level > 1 && int64(level-1)*int64(level-1)*xpPerLevelStep > xp {
+		level--
+	}
+	return level
+}
+
+// Progression grants XP for player actions according to the xp_rules table
+// and persists XP and levels on the players table.
+type Progression struct {
+	database store.Store
+	rules    map[string]int64
+}
+
+func NewProgression(database store.Store) *Progression {
+	rules, err := database.GetXPRules(context.Background())
+	if err != nil {
+		logrus.Errorf("Failed to load XP rules, using defaults: %v", err)
+		rules = store.DefaultXPRules
+	} else if len(rules) == 0 {
+		logrus.Warn("No XP rules configured, using defaults")
+		rules = store.DefaultXPRules
+	}
+	return &Progression{database: database, rules: rules}
+}
+
+// XPFor returns the XP granted for an action, zero if it has no rule.
+func (p *Progression) XPFor(action string) int64 {
+	return p.rules[action]
+}
+
+// Load restores a joining player's XP and level from the database unless the
+// player already carries more progress, e.g. after a replication failover.
+func (p *Progression) Load(ctx context.Context, player *Player) {
+	xp, level, err := p.database.GetPlayerProgress(ctx, player.ID)
+	if err != nil {
+		logrus.Errorf("Failed to load progress for %s: %v", player.ID, err)
+		return
+	}
+	if xp > player.XP {
+		player.XP = xp
+		player.Level = level
+	}
+}
+
+// Award grants the XP for action through addXP, which applies it to the
+// in-memory player and returns the new total with the level before and
+// after. It returns the new level and whether the player levelled up.
+func (p *Progression) Award(ctx context.Context, playerID uuid.UUID, action string, addXP func(amount int64) (int64, int, int)) (int, bool) {
+	amount := p.XPFor(action)
+	if amount <= 0 {
+		return 0, false
+	}
+
+	xp, oldLevel, newLevel := addXP(amount)
+	if err := p.database.UpdatePlayerProgress(ctx, playerID, xp, newLevel); err != nil {
+		logrus.Errorf("Failed to save progress for %s: %v", playerID, err)
+	}
+
+	if newLevel > oldLevel {
+		logrus.Infof("Player %s reached level %d", playerID, newLevel)
+		return newLevel, true
+	}
+	return newLevel, false
+}
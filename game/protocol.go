@@ -0,0 +1,122 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Game protocol versions. Clients declare theirs when they connect:
+// WebSocket clients with ?protocol_version=, UDP clients in their Heartbeat
+// and TCP clients in their Authenticate frame. Clients that declare none
+// speak version 1.
+//
+// Version 2 added ServerFull, SessionSecret, UpgradeRequired, Disconnect,
+// Maintenance and the code of Error messages. Version 1 clients are sent these translated by
+// DowngradeMessage until MIN_PROTOCOL_VERSION retires version 1. Clients
+// outside the supported versions are refused with UpgradeRequired.
+const (
+	LegacyProtocolVersion  = 1
+	CurrentProtocolVersion = 2
+)
+
+// supportedProtocolVersions lists the versions from minVersion to the
+// current one.
+func supportedProtocolVersions(minVersion int) []int {
+	var versions []int
+	for version := minVersion; version <= CurrentProtocolVersion; version++ {
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// describeVersions names supported versions in UpgradeRequired messages.
+func describeVersions(versions []int) string {
+	if len(versions) == 1 {
+		return fmt.Sprintf("version %d", versions[0])
+	}
+	return fmt.Sprintf("versions %d to %d", versions[0], versions[len(versions)-1])
+}
+
+// ParseProtocolVersion reads a declared version, which is 1 when empty and
+// 0, a version never supported, when malformed.
+func ParseProtocolVersion(value string) int {
+	if value == "" {
+		return LegacyProtocolVersion
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0
+	}
+	return version
+}
+
+// CheckProtocolVersion returns the UpgradeRequired message refusing a
+// client, or nil if its version is supported.
+func CheckProtocolVersion(version, minVersion int) *GameMessage {
+	if version >= minVersion && version <= CurrentProtocolVersion {
+		return nil
+	}
+	metrics.Inc("protocol_version_rejected")
+	message := NewUpgradeRequiredMessage(version, supportedProtocolVersions(minVersion))
+	return &message
+}
+
+// DowngradeMessage translates a message for a client speaking an older
+// version. It returns nil for messages the client has no equivalent of.
+func DowngradeMessage(message *GameMessage, version int) *GameMessage {
+	if version >= CurrentProtocolVersion {
+		return message
+	}
+
+	var downgraded GameMessage
+	switch data := message.Data.(type) {
+	case ServerFullData:
+		if data.Position == 0 {
+			downgraded = NewErrorMessage("server is full")
+		} else {
+			downgraded = NewErrorMessage(fmt.Sprintf("server is full; you are number %d in the queue", data.Position))
+		}
+	case UpgradeRequiredData:
+		downgraded = NewErrorMessage(data.Message)
+	case DisconnectData:
+		// The ServerFull before it already tells the client
+		if data.Reason == DisconnectServerFull {
+			return nil
+		}
+		downgraded = NewKickedMessage(data.Message)
+	case MaintenanceData:
+		downgraded = NewAnnouncementMessage(data.Message)
+	case ErrorData:
+		if data.Code == "" {
+			return message
+		}
+		downgraded = NewErrorMessage(data.Message)
+	case SessionSecretData:
+		return nil
+	default:
+		return message
+	}
+	downgraded.ID, downgraded.Timestamp, downgraded.CorrelationID = message.ID, message.Timestamp, message.CorrelationID
+	return &downgraded
+}
+
+// DowngradePayload is DowngradeMessage for a payload that may be shared.
+func DowngradePayload(payload *BroadcastPayload, version int) *BroadcastPayload {
+	message := DowngradeMessage(&payload.Message, version)
+	switch message {
+	case nil:
+		return nil
+	case &payload.Message:
+		return payload
+	}
+	return NewBroadcastPayload(message)
+}
+
+// WriteMessageFor sends a single message to a connection speaking version.
+func WriteMessageFor(conn ClientConn, version int, message GameMessage) error {
+	downgraded := DowngradeMessage(&message, version)
+	if downgraded == nil {
+		return nil
+	}
+	return WriteMessage(conn, *downgraded)
+}
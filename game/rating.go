@@ -0,0 +1,78 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"online-server-go/store"
+)
+
+const (
+	ratingKFactor     = 32.0
+	ratingBucketWidth = 200
+)
+
+// MatchStanding is one participant's final position in a match.
+type MatchStanding struct {
+	PlayerID  uuid.UUID
+	Rating    int64
+	Placement int // 1 is first; equal placements are ties
+}
+
+// expectedScore is the ELO probability that a player rated a beats one rated b.
+func expectedScore(a, b int64) float64 {
+	return 1.0 / (1.0 + math.Pow(10, float64(b-a)/400.0))
+}
+
+// ComputeRatingChanges applies a multiplayer ELO update: each participant is
+// scored pairwise against every other participant and the K factor is split
+// across opponents so free-for-all matches move ratings as much as duels.
+func ComputeRatingChanges(standings []MatchStanding) []store.RatingChange {
+	changes := make([]store.RatingChange, 0, len(standings))
+	if len(standings) < 2 {
+		return changes
+	}
+
+	k := ratingKFactor / float64(len(standings)-1)
+	for i, player := range standings {
+		delta := 0.0
+		for j, opponent := range standings {
+			if i == j {
+				continue
+			}
+
+			actual := 0.5
+			if player.Placement < opponent.Placement {
+				actual = 1.0
+			} else if player.Placement > opponent.Placement {
+				actual = 0.0
+			}
+			delta += k * (actual - expectedScore(player.Rating, opponent.Rating))
+		}
+
+		changes = append(changes, store.RatingChange{
+			PlayerID:  player.PlayerID,
+			OldRating: player.Rating,
+			NewRating: player.Rating + int64(math.Round(delta)),
+			Placement: player.Placement,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Placement < changes[j].Placement
+	})
+	return changes
+}
+
+// RatingBucket groups ratings into fixed-width bands used as matchmaking
+// buckets, e.g. 1200-1399 -> "1200-1399".
+func RatingBucket(rating int64) string {
+	if rating < 0 {
+		rating = 0
+	}
+	low := (rating / ratingBucketWidth) * ratingBucketWidth
+	return fmt.Sprintf("%d-%d", low, low+ratingBucketWidth-1)
+}
@@ -0,0 +1,138 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxDumpPayloadBytes = 1024
+
+// Keys whose values are never written to logs or crash dumps.
+var redactedKeys = map[string]bool{
+	"message":  true,
+	"password": true,
+	"token":    true,
+	"secret":   true,
+}
+
+var crashDumpDir = "crash_dumps"
+
+func SetCrashDumpDir(dir string) {
+	crashDumpDir = dir
+}
+
+// RecoverHandler must be deferred directly around per-message handling.
+// On panic it logs the redacted payload and stack, writes a crash dump,
+// increments the handler_panics metric and calls onPanic so the caller can
+// disconnect the offending client.
+func RecoverHandler(transport, clientKey string, payload []byte, onPanic func()) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	redacted := RedactPayload(payload)
+
+	metrics.Inc("handler_panics")
+	metrics.Inc("handler_panics_" + transport)
+
+	logrus.Errorf("Recovered panic in %s handler for %s: %v (payload: %s)", transport, clientKey, r, redacted)
+
+	if path, err := writeCrashDump(transport, clientKey, r, redacted, stack); err != nil {
+		logrus.Errorf("Failed to write crash dump: %v", err)
+	} else if path != "" {
+		logrus.Errorf("Crash dump written to %s", path)
+	}
+
+	if onPanic != nil {
+		onPanic()
+	}
+}
+
+func writeCrashDump(transport, clientKey string, panicValue interface{}, payload string, stack []byte) (string, error) {
+	if crashDumpDir == "" {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(crashDumpDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create crash dump directory: %w", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("panic-%s-%s.log", transport, now.Format("20060102-150405.000000000"))
+	path := filepath.Join(crashDumpDir, name)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&sb, "transport: %s\n", transport)
+	fmt.Fprintf(&sb, "client: %s\n", clientKey)
+	fmt.Fprintf(&sb, "panic: %v\n", panicValue)
+	fmt.Fprintf(&sb, "payload: %s\n\n", payload)
+	sb.Write(stack)
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write crash dump: %w", err)
+	}
+
+	return path, nil
+}
+
+// RedactPayload masks sensitive values in a JSON or MessagePack payload and
+// truncates the result so it is safe to log.
+func RedactPayload(payload []byte) string {
+	payload = readablePayload(payload)
+	var decoded interface{}
+	out := string(payload)
+	if err := json.Unmarshal(payload, &decoded); err == nil {
+		if data, err := json.Marshal(redactValue(decoded)); err == nil {
+			out = string(data)
+		}
+	} else {
+		out = fmt.Sprintf("%q", payload)
+	}
+
+	if len(out) > maxDumpPayloadBytes {
+		out = out[:maxDumpPayloadBytes] + fmt.Sprintf("...(%d bytes truncated)", len(out)-maxDumpPayloadBytes)
+	}
+	return out
+}
+
+// RedactMessage is RedactPayload for a decoded message.
+func RedactMessage(message *GameMessage) string {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return message.Type
+	}
+	return RedactPayload(data)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if redactedKeys[strings.ToLower(key)] {
+				if _, isString := inner.(string); isString {
+					v[key] = "[REDACTED]"
+					continue
+				}
+			}
+			v[key] = redactValue(inner)
+		}
+		return v
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+		return v
+	default:
+		return value
+	}
+}
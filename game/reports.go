@@ -0,0 +1,182 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const (
+	maxReportReasonLength = 200  // characters
+	maxChatExcerptLength  = 1000 // characters
+	maxReportEntries      = 500
+)
+
+// reportsActor is the actor of escalations in the audit log.
+const reportsActor = "reports"
+
+// parseReportFilter reads ?player_id=, ?open=, ?escalated= and ?limit= of a
+// report query.
+func parseReportFilter(r *http.Request) (store.ReportFilter, error) {
+	query := r.URL.Query()
+	filter := store.ReportFilter{
+		Open:      query.Get("open") == "true",
+		Escalated: query.Get("escalated") == "true",
+		Limit:     100,
+	}
+	if playerID := query.Get("player_id"); playerID != "" {
+		id, err := uuid.Parse(playerID)
+		if err != nil {
+			return store.ReportFilter{}, errors.New("player_id must be a valid UUID")
+		}
+		filter.ReportedID = id
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxReportEntries {
+			return store.ReportFilter{}, fmt.Errorf("limit must be between 1 and %d", maxReportEntries)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// Reports files the reports players send in game. Each player may file
+// REPORTS_PER_HOUR reports, and a player reported by
+// REPORT_ESCALATE_COUNT different players within REPORT_ESCALATE_WINDOW has
+// their open reports escalated for staff to look at first.
+type Reports struct {
+	database       store.Store
+	perHour        int
+	escalateCount  int
+	escalateWindow time.Duration
+
+	mu    sync.Mutex
+	filed map[uuid.UUID][]time.Time // by reporter, within the last hour
+}
+
+func NewReports(database store.Store, config *Config) *Reports {
+	return &Reports{
+		database:       database,
+		perHour:        config.ReportsPerHour,
+		escalateCount:  config.ReportEscalateCount,
+		escalateWindow: config.ReportEscalateWindow,
+		filed:          make(map[uuid.UUID][]time.Time),
+	}
+}
+
+// HandleMessage files a ReportPlayer message and confirms it with
+// ReportFiled.
+func (r *Reports) HandleMessage(ctx context.Context, reporterID uuid.UUID, message *GameMessage, dir PlayerDirectory) {
+	var data ReportPlayerData
+	if err := DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := NewErrorMessage("invalid ReportPlayer data")
+		dir.SendToPlayer(reporterID, &errorMsg)
+		return
+	}
+
+	report, err := r.File(ctx, reporterID, data, time.Now())
+	if err != nil {
+		errorMsg := NewErrorMessage(err.Error())
+		dir.SendToPlayer(reporterID, &errorMsg)
+		return
+	}
+	filed := NewReportFiledMessage(report.ID, report.ReportedID)
+	dir.SendToPlayer(reporterID, &filed)
+}
+
+// File checks and stores a report, then escalates the reported player's
+// reports if they are drawing them quickly.
+func (r *Reports) File(ctx context.Context, reporterID uuid.UUID, data ReportPlayerData, now time.Time) (*store.PlayerReport, error) {
+	reason := strings.TrimSpace(data.Reason)
+	switch {
+	case data.PlayerID == uuid.Nil:
+		return nil, errors.New("player_id is required")
+	case data.PlayerID == reporterID:
+		return nil, errors.New("you cannot report yourself")
+	case reason == "":
+		return nil, errors.New("reason is required")
+	case !utf8.ValidString(reason) || utf8.RuneCountInString(reason) > maxReportReasonLength:
+		return nil, fmt.Errorf("reason must be valid text of at most %d characters", maxReportReasonLength)
+	case !utf8.ValidString(data.ChatExcerpt) || utf8.RuneCountInString(data.ChatExcerpt) > maxChatExcerptLength:
+		return nil, fmt.Errorf("chat_excerpt must be valid text of at most %d characters", maxChatExcerptLength)
+	}
+	if !r.allow(reporterID, now) {
+		metrics.Inc("reports_rate_limited")
+		return nil, fmt.Errorf("you may file at most %d reports per hour", r.perHour)
+	}
+
+	report := &store.PlayerReport{ReporterID: reporterID, ReportedID: data.PlayerID, Reason: reason, ChatExcerpt: data.ChatExcerpt}
+	if err := r.database.CreateReport(ctx, report); err != nil {
+		logrus.Errorf("Failed to file a report by %s: %v", reporterID, err)
+		return nil, errors.New("failed to file the report")
+	}
+	metrics.Inc("reports_filed")
+	logrus.Infof("Player %s reported %s: %s", reporterID, report.ReportedID, reason)
+
+	r.escalate(ctx, report.ReportedID, now)
+	return report, nil
+}
+
+// allow records a report by a reporter at now unless they filed
+// REPORTS_PER_HOUR within the last hour.
+func (r *Reports) allow(reporterID uuid.UUID, now time.Time) bool {
+	if r.perHour <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recent := r.filed[reporterID][:0]
+	for _, at := range r.filed[reporterID] {
+		if now.Sub(at) < time.Hour {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= r.perHour {
+		r.filed[reporterID] = recent
+		return false
+	}
+	r.filed[reporterID] = append(recent, now)
+	return true
+}
+
+// escalate marks the open reports of a player escalated once enough
+// different players reported them within the window.
+func (r *Reports) escalate(ctx context.Context, reportedID uuid.UUID, now time.Time) {
+	if r.escalateCount <= 0 {
+		return
+	}
+	reporters, err := r.database.CountReporters(ctx, reportedID, now.Add(-r.escalateWindow))
+	if err != nil {
+		logrus.Errorf("Failed to count reports against %s: %v", reportedID, err)
+		return
+	}
+	if reporters < r.escalateCount {
+		return
+	}
+
+	escalated, err := r.database.EscalateReports(ctx, reportedID)
+	if err != nil {
+		logrus.Errorf("Failed to escalate reports against %s: %v", reportedID, err)
+		return
+	}
+	if escalated == 0 {
+		return
+	}
+	metrics.Inc("reports_escalated")
+	logrus.Warnf("Escalated %d reports against %s, reported by %d players within %s", escalated, reportedID, reporters, r.escalateWindow)
+	RecordAdminAction(ctx, r.database, reportsActor, auditEscalateReports, reportedID.String(),
+		fmt.Sprintf("reported by %d players within %s", reporters, r.escalateWindow))
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"online-server-go/store"
+)
+
+// Roles of an account, from most to least trusted. Every account starts as
+// a player; owners give out the others with /admin/roles.
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RolePlayer    = store.DefaultRole
+)
+
+// Permission is something a staff role may do through the admin APIs or
+// in-game moderation commands.
+type Permission string
+
+const (
+	PermMute         Permission = "mute"          // mute and unmute players in game
+	PermKick         Permission = "kick"          // disconnect players
+	PermBan          Permission = "ban"           // ban and unban players
+	PermBroadcast    Permission = "broadcast"     // announce to every player
+	PermReports      Permission = "reports"       // review and resolve player reports
+	PermViewServer   Permission = "view_server"   // read rules, rooms, connections and players
+	PermManageServer Permission = "manage_server" // change rules, events, rooms, traces and maintenance
+	PermManageRoles  Permission = "manage_roles"  // give accounts a role
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleOwner:     {PermMute, PermKick, PermBan, PermBroadcast, PermReports, PermViewServer, PermManageServer, PermManageRoles},
+	RoleAdmin:     {PermMute, PermKick, PermBan, PermBroadcast, PermReports, PermViewServer, PermManageServer},
+	RoleModerator: {PermMute, PermKick, PermReports, PermViewServer},
+	RolePlayer:    nil,
+}
+
+var (
+	ErrStaffUnauthenticated = errors.New("invalid admin or staff token")
+	ErrStaffForbidden       = errors.New("your role does not allow this")
+)
+
+// ParseRole checks the name of a role.
+func ParseRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if _, known := rolePermissions[role]; !known {
+		return "", fmt.Errorf("role must be one of %s, %s, %s or %s", RoleOwner, RoleAdmin, RoleModerator, RolePlayer)
+	}
+	return role, nil
+}
+
+// RoleAllows reports whether a role grants a permission. Unknown roles grant
+// nothing.
+func RoleAllows(role string, perm Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Staff is who made an admin request: an account with a staff role, or an
+// operator holding ADMIN_TOKEN, who acts as an owner.
+type Staff struct {
+	Name string // the account's username, or the operator's actor name for ADMIN_TOKEN
+	Role string
+}
+
+// StaffAuthorizer checks the token of an admin request against ADMIN_TOKEN
+// and the tokens of staff accounts, so that moderators can act without the
+// full admin credentials.
+type StaffAuthorizer struct {
+	adminToken string
+	accounts   *Accounts
+}
+
+func NewStaffAuthorizer(adminToken string, accounts *Accounts) *StaffAuthorizer {
+	return &StaffAuthorizer{adminToken: adminToken, accounts: accounts}
+}
+
+// Enabled reports whether any token can be accepted.
+func (s *StaffAuthorizer) Enabled() bool {
+	return s.adminToken != "" || s.accounts != nil
+}
+
+// Authorize returns who a token belongs to if they hold perm, without a
+// Name for ADMIN_TOKEN. It fails with ErrStaffUnauthenticated or
+// ErrStaffForbidden, or a database error.
+func (s *StaffAuthorizer) Authorize(ctx context.Context, token string, perm Permission) (Staff, error) {
+	if token == "" {
+		return Staff{}, ErrStaffUnauthenticated
+	}
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+		return Staff{Role: RoleOwner}, nil
+	}
+	if s.accounts == nil {
+		return Staff{}, ErrStaffUnauthenticated
+	}
+
+	account, err := s.accounts.Authenticate(ctx, token)
+	if errors.Is(err, errInvalidToken) {
+		return Staff{}, ErrStaffUnauthenticated
+	}
+	if err != nil {
+		return Staff{}, err
+	}
+	if !RoleAllows(account.Role, perm) {
+		return Staff{}, ErrStaffForbidden
+	}
+	return Staff{Name: account.Username, Role: account.Role}, nil
+}
+
+type staffKey struct{}
+
+func WithStaff(ctx context.Context, staff Staff) context.Context {
+	return context.WithValue(ctx, staffKey{}, staff)
+}
+
+// StaffFrom returns who made an authorized admin request.
+func StaffFrom(ctx context.Context) Staff {
+	staff, _ := ctx.Value(staffKey{}).(Staff)
+	return staff
+}
@@ -0,0 +1,62 @@
+package game
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxMessageIDLength bounds the id a client may give a message, since it is
+// echoed back in the reply.
+const maxMessageIDLength = 64
+
+// Clients that want to match replies to their requests, such as a
+// LeaderboardRequest answered by a LeaderboardResponse, give the request an
+// id. Whatever the server sends the client in reply, errors included, then
+// carries it as correlation_id along with the time it was sent. Requests
+// without an id are answered as before.
+
+// requestID returns the id replies to request are correlated with, or "" if
+// there is no request, it has no id or one too long to echo.
+func requestID(request *GameMessage) string {
+	if request == nil || len(request.ID) > maxMessageIDLength {
+		return ""
+	}
+	return request.ID
+}
+
+// NewReply marks message as the reply to request.
+func NewReply(request *GameMessage, message GameMessage) GameMessage {
+	if id := requestID(request); id != "" {
+		message.CorrelationID = id
+		message.Timestamp = time.Now().UnixMilli()
+	}
+	return message
+}
+
+// RepliesTo returns a directory that marks the messages sent to requester as
+// replies to request, for handlers that answer through a PlayerDirectory.
+func RepliesTo(dir PlayerDirectory, requester uuid.UUID, request *GameMessage) PlayerDirectory {
+	if requestID(request) == "" {
+		return dir
+	}
+	return replyDirectory{dir: dir, requester: requester, request: request}
+}
+
+type replyDirectory struct {
+	dir       PlayerDirectory
+	requester uuid.UUID
+	request   *GameMessage
+}
+
+func (d replyDirectory) IsOnline(playerID uuid.UUID) bool {
+	return d.dir.IsOnline(playerID)
+}
+
+func (d replyDirectory) SendToPlayer(playerID uuid.UUID, message *GameMessage) bool {
+	if playerID == d.requester {
+		reply := NewReply(d.request, *message)
+		message = &reply
+	}
+	return d.dir.SendToPlayer(playerID, message)
+}
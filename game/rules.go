@@ -0,0 +1,215 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GameRules are the tunable numbers of the game. Fields missing from the
+// rules file keep their defaults.
+type GameRules struct {
+	PickupScore       int64   `json:"pickup_score"`
+	AttackDamage      float32 `json:"attack_damage"`
+	RespawnSeconds    float64 `json:"respawn_seconds"`     // 0 leaves killed players dead
+	RespawnHealth     float32 `json:"respawn_health"`      // health players come back with
+	KillScore         int64   `json:"kill_score"`          // awarded to the killer
+	DeathScorePenalty int64   `json:"death_score_penalty"` // taken from the killed player, down to zero
+	MaxMoveSpeed      float32 `json:"max_move_speed"`      // cap on steered velocities, in units per second
+	KnockbackSpeed    float32 `json:"knockback_speed"`     // impulse an attack gives its target, 0 for none
+
+	// Votes configures each type of vote players may start. A type given in
+	// the rules file replaces its default rule as a whole.
+	Votes map[string]VoteRule `json:"votes"`
+}
+
+func DefaultGameRules() GameRules {
+	return GameRules{
+		PickupScore:    10,
+		AttackDamage:   10,
+		RespawnSeconds: 5,
+		RespawnHealth:  MaxHealth,
+		KillScore:      25,
+		MaxMoveSpeed:   1000,
+		KnockbackSpeed: 300,
+		Votes:          defaultVoteRules(),
+	}
+}
+
+func (r GameRules) Validate() error {
+	switch {
+	case r.PickupScore < 0:
+		return fmt.Errorf("pickup_score must not be negative, got %d", r.PickupScore)
+	case r.AttackDamage < 0 || r.AttackDamage > MaxHealth:
+		return fmt.Errorf("attack_damage must be between 0 and %g, got %g", float32(MaxHealth), r.AttackDamage)
+	case r.RespawnSeconds < 0:
+		return fmt.Errorf("respawn_seconds must not be negative, got %g", r.RespawnSeconds)
+	case r.RespawnHealth <= 0 || r.RespawnHealth > MaxHealth:
+		return fmt.Errorf("respawn_health must be above 0 and at most %g, got %g", float32(MaxHealth), r.RespawnHealth)
+	case r.KillScore < 0:
+		return fmt.Errorf("kill_score must not be negative, got %d", r.KillScore)
+	case r.DeathScorePenalty < 0:
+		return fmt.Errorf("death_score_penalty must not be negative, got %d", r.DeathScorePenalty)
+	case r.MaxMoveSpeed <= 0:
+		return fmt.Errorf("max_move_speed must be positive, got %g", r.MaxMoveSpeed)
+	case r.KnockbackSpeed < 0:
+		return fmt.Errorf("knockback_speed must not be negative, got %g", r.KnockbackSpeed)
+	}
+	return validateVoteRules(r.Votes)
+}
+
+// RespawnDelay is how long a killed player stays dead, zero for good.
+func (r GameRules) RespawnDelay() time.Duration {
+	return time.Duration(r.RespawnSeconds * float64(time.Second))
+}
+
+// RespawnAt is when a player who died at diedAt comes back, zero if they
+// stay dead.
+func (r GameRules) RespawnAt(diedAt time.Time) time.Time {
+	if r.RespawnDelay() <= 0 {
+		return time.Time{}
+	}
+	return diedAt.Add(r.RespawnDelay())
+}
+
+// ApplyDamage reduces health by amount, never below zero, recording when it
+// reached zero for Respawn. It reports false if the player was already dead.
+func (p *Player) ApplyDamage(amount float32, now time.Time) (float32, bool) {
+	if p.Health <= 0 {
+		return p.Health, false
+	}
+	p.Health -= amount
+	if p.Health <= 0 {
+		p.Health = 0
+		p.diedAt = now
+	}
+	return p.Health, true
+}
+
+// Respawn restores a dead player to health once delay has passed since it
+// died. A zero delay never respawns.
+func (p *Player) Respawn(delay time.Duration, health float32, now time.Time) bool {
+	if p.Health > 0 || delay <= 0 || now.Sub(p.diedAt) < delay {
+		return false
+	}
+	p.Health = health
+	p.diedAt = time.Time{}
+	return true
+}
+
+// AddPoints changes a player's score by points, which may be negative, never
+// taking it below zero. It returns the points actually applied.
+func (p *Player) AddPoints(points int64) int64 {
+	if points < -int64(p.Score) {
+		points = -int64(p.Score)
+	}
+	p.Score = uint32(int64(p.Score) + points)
+	return points
+}
+
+// LoadGameRules reads and validates a JSON rules file. An empty path yields
+// the defaults.
+func LoadGameRules(path string) (GameRules, error) {
+	rules := DefaultGameRules()
+	if path == "" {
+		return rules, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GameRules{}, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	// Unknown fields are rejected so that a misspelled rule is not ignored
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return GameRules{}, fmt.Errorf("failed to parse rules file %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return GameRules{}, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Rules holds the rules in force. Reload swaps in the rules file's current
+// contents without touching connections; players are told with a
+// RulesChanged message.
+type Rules struct {
+	path string
+
+	mu        sync.RWMutex
+	current   GameRules
+	broadcast func(message *GameMessage)
+}
+
+// NewRules loads RULES_FILE, which the startup checks have validated.
+func NewRules(path string) *Rules {
+	current, err := LoadGameRules(path)
+	if err != nil {
+		logrus.Errorf("Failed to load game rules, using defaults: %v", err)
+		current = DefaultGameRules()
+	}
+	return &Rules{path: path, current: current}
+}
+
+// Attach sets how RulesChanged reaches every player. The function must take
+// its own locks.
+func (r *Rules) Attach(broadcast func(message *GameMessage)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.broadcast = broadcast
+}
+
+func (r *Rules) Current() GameRules {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Reload rereads the rules file. Invalid rules are rejected and the rules in
+// force stay unchanged.
+func (r *Rules) Reload() (GameRules, error) {
+	if r.path == "" {
+		return GameRules{}, fmt.Errorf("RULES_FILE is not set")
+	}
+	rules, err := LoadGameRules(r.path)
+	if err != nil {
+		metrics.Inc("rules_reload_failures")
+		return GameRules{}, err
+	}
+
+	r.mu.Lock()
+	changed := !reflect.DeepEqual(rules, r.current)
+	r.current = rules
+	broadcast := r.broadcast
+	r.mu.Unlock()
+
+	logrus.Infof("Game rules reloaded from %s: %+v", r.path, rules)
+	metrics.Inc("rules_reloads")
+	if changed && broadcast != nil {
+		message := NewRulesChangedMessage(rules)
+		broadcast(&message)
+	}
+	return rules, nil
+}
+
+// ReloadOnSignal reloads the rules whenever the process receives SIGHUP.
+func (r *Rules) ReloadOnSignal() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			if _, err := r.Reload(); err != nil {
+				logrus.Errorf("Failed to reload game rules: %v", err)
+			}
+		}
+	}()
+}
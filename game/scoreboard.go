@@ -0,0 +1,125 @@
+package game
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// Scoreboard ranks the participants of the current match for the
+// Scoreboard message, which is broadcast every interval during a match and
+// once more when it ends. The final one is saved as the match's results.
+//
+// A nil *Scoreboard, as on servers without matches, sends none.
+type Scoreboard struct {
+	database store.Store
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewScoreboard(database store.Store, interval time.Duration) *Scoreboard {
+	return &Scoreboard{
+		database: database,
+		interval: interval,
+		next:     time.Now().Add(interval),
+	}
+}
+
+// Due reports whether a periodic scoreboard is due at now, and if so
+// schedules the next one.
+func (s *Scoreboard) Due(now time.Time) bool {
+	if s == nil || s.interval <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Before(s.next) {
+		return false
+	}
+	s.next = now.Add(s.interval)
+	return true
+}
+
+// Build ranks the tallies by score, then kills, with equal results sharing
+// a rank. lookup names an online player and returns their ping; players who
+// have left are named from the database.
+func (s *Scoreboard) Build(ctx context.Context, scores []MatchScore, lookup func(playerID uuid.UUID) (string, time.Duration, bool)) []store.MatchResult {
+	if s == nil {
+		return nil
+	}
+
+	results := make([]store.MatchResult, 0, len(scores))
+	for _, score := range scores {
+		result := store.MatchResult{PlayerID: score.PlayerID, Score: score.Score, Kills: score.Kills, Deaths: score.Deaths}
+		if name, ping, online := lookup(score.PlayerID); online {
+			result.Name = name
+			result.PingMS = ping.Milliseconds()
+		} else if player, err := s.database.GetPlayer(ctx, score.PlayerID); err != nil {
+			logrus.Errorf("Failed to look up player %s: %v", score.PlayerID, err)
+		} else if player != nil {
+			result.Name = player.Name
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Kills != b.Kills {
+			return a.Kills > b.Kills
+		}
+		return a.Name < b.Name
+	})
+	for i := range results {
+		if i > 0 && results[i].Score == results[i-1].Score && results[i].Kills == results[i-1].Kills {
+			results[i].Rank = results[i-1].Rank
+		} else {
+			results[i].Rank = i + 1
+		}
+	}
+	return results
+}
+
+// SaveResults stores the final scoreboard of a match.
+func (s *Scoreboard) SaveResults(ctx context.Context, matchID *int64, results []store.MatchResult) {
+	if s == nil || matchID == nil || len(results) == 0 {
+		return
+	}
+	if err := s.database.SaveMatchResults(ctx, *matchID, results); err != nil {
+		logrus.Errorf("Failed to save results of match %s: %v", formatMatchID(matchID), err)
+	}
+}
+
+// handleMatchResults returns the final scoreboard of ?match_id.
+func (api *APIHandler) handleMatchResults(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.ParseInt(r.URL.Query().Get("match_id"), 10, 64)
+	if err != nil {
+		WriteJSONError(w, http.StatusBadRequest, "match_id must be an integer")
+		return
+	}
+
+	results, err := api.database.GetMatchResults(r.Context(), matchID)
+	if err != nil {
+		logrus.Errorf("Failed to load results of match %d: %v", matchID, err)
+		WriteJSONError(w, http.StatusInternalServerError, "failed to load match results")
+		return
+	}
+	if results == nil {
+		WriteJSONError(w, http.StatusNotFound, "no results for that match")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, ScoreboardData{MatchID: &matchID, Final: true, Results: results})
+}
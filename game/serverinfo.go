@@ -0,0 +1,64 @@
+package game
+
+import (
+	"net/http"
+)
+
+// ServerInfo describes an instance to launchers and server browsers.
+type ServerInfo struct {
+	Name             string   `json:"name"`
+	Map              string   `json:"map"`
+	Protocol         string   `json:"protocol"` // websocket, tcp or udp
+	Players          int      `json:"players"`
+	MaxPlayers       int      `json:"max_players"` // 0 for unlimited
+	TickRate         int      `json:"tick_rate"`   // GameState snapshots per second, 0 for none
+	ProtocolVersions []int    `json:"protocol_versions"`
+	Encodings        []string `json:"encodings"`
+	KeepaliveMs      int64    `json:"keepalive_ms,omitempty"` // how often UDP clients should send a Heartbeat
+}
+
+// ServerInfoHandler serves /api/server-info. UDP servers also answer
+// ServerInfoRequest packets with it.
+type ServerInfoHandler struct {
+	info ServerInfo // the fields that do not change
+	game GameAdmin
+}
+
+func NewServerInfoHandler(config *Config, protocol string, game GameAdmin) *ServerInfoHandler {
+	info := ServerInfo{
+		Name:             config.ServerName,
+		Map:              config.MapName,
+		Protocol:         protocol,
+		MaxPlayers:       config.MaxPlayers,
+		TickRate:         config.SnapshotRate,
+		ProtocolVersions: supportedProtocolVersions(config.MinProtocolVersion),
+		Encodings:        []string{"json", "msgpack"},
+	}
+	if info.Name == "" {
+		info.Name = config.ServerID
+	}
+	// UDP clients get GameState when they join and moves as they happen
+	if protocol == "udp" {
+		info.TickRate = 0
+		info.KeepaliveMs = config.UDPKeepaliveInterval.Milliseconds()
+	}
+	return &ServerInfoHandler{info: info, game: game}
+}
+
+func (h *ServerInfoHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/server-info", h.handleServerInfo)
+}
+
+// Info returns the server's current description.
+func (h *ServerInfoHandler) Info() ServerInfo {
+	info := h.info
+	info.Players = h.game.GetClientCount()
+	return info
+}
+
+func (h *ServerInfoHandler) handleServerInfo(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	WriteJSON(w, http.StatusOK, h.Info())
+}
@@ -0,0 +1,126 @@
+package game
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const (
+	MaxHealth    = 100.0
+	assistWindow = 10 * time.Second
+)
+
+// StatsTracker aggregates combat and match events into player_stats. It is
+// called with the game's lock held, so it writes on the game's PersistQueue.
+type StatsTracker struct {
+	database store.Store
+	persist  *PersistQueue
+
+	mu           sync.Mutex
+	recentDamage map[uuid.UUID]map[uuid.UUID]time.Time // victim -> attacker -> last hit
+}
+
+func NewStatsTracker(database store.Store, persist *PersistQueue) *StatsTracker {
+	return &StatsTracker{
+		database:     database,
+		persist:      persist,
+		recentDamage: make(map[uuid.UUID]map[uuid.UUID]time.Time),
+	}
+}
+
+func (st *StatsTracker) RecordDamage(attackerID, victimID uuid.UUID) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	attackers, exists := st.recentDamage[victimID]
+	if !exists {
+		attackers = make(map[uuid.UUID]time.Time)
+		st.recentDamage[victimID] = attackers
+	}
+	attackers[attackerID] = time.Now()
+}
+
+// RecordKill credits the killer, the victim's death, and an assist for every
+// other player that damaged the victim within the assist window.
+func (st *StatsTracker) RecordKill(ctx context.Context, killerID, victimID uuid.UUID) {
+	st.mu.Lock()
+	attackers := st.recentDamage[victimID]
+	delete(st.recentDamage, victimID)
+	st.mu.Unlock()
+
+	var assists []uuid.UUID
+	for attackerID, hitAt := range attackers {
+		if attackerID != killerID && time.Since(hitAt) <= assistWindow {
+			assists = append(assists, attackerID)
+		}
+	}
+
+	st.persist.Go(ctx, func(ctx context.Context) {
+		if err := st.database.AddPlayerStats(ctx, killerID, store.PlayerStatsDelta{Kills: 1}); err != nil {
+			logrus.Errorf("Failed to record kill for %s: %v", killerID, err)
+		}
+		if err := st.database.AddPlayerStats(ctx, victimID, store.PlayerStatsDelta{Deaths: 1}); err != nil {
+			logrus.Errorf("Failed to record death for %s: %v", victimID, err)
+		}
+		for _, attackerID := range assists {
+			if err := st.database.AddPlayerStats(ctx, attackerID, store.PlayerStatsDelta{Assists: 1}); err != nil {
+				logrus.Errorf("Failed to record assist for %s: %v", attackerID, err)
+			}
+		}
+	})
+}
+
+func (st *StatsTracker) RecordItemCollected(ctx context.Context, playerID uuid.UUID) {
+	st.persist.Go(ctx, func(ctx context.Context) {
+		if err := st.database.AddPlayerStats(ctx, playerID, store.PlayerStatsDelta{ItemsCollected: 1}); err != nil {
+			logrus.Errorf("Failed to record item pickup for %s: %v", playerID, err)
+		}
+	})
+}
+
+// Forget drops any pending assist bookkeeping for a player leaving the game.
+func (st *StatsTracker) Forget(playerID uuid.UUID) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.recentDamage, playerID)
+	for _, attackers := range st.recentDamage {
+		delete(attackers, playerID)
+	}
+}
+
+// GetStats returns the stored stats for a player, or zeroed stats if the
+// player has none recorded yet.
+func (st *StatsTracker) GetStats(ctx context.Context, playerID uuid.UUID) (*store.PlayerStats, error) {
+	stats, err := st.database.GetPlayerStats(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = &store.PlayerStats{PlayerID: playerID.String()}
+	}
+	return stats, nil
+}
+
+// ParseTargetID extracts an optional "target_id" from action data.
+func ParseTargetID(data interface{}) (uuid.UUID, bool) {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return uuid.Nil, false
+	}
+	targetIDStr, ok := fields["target_id"].(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	targetID, err := uuid.Parse(targetIDStr)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return targetID, true
+}
@@ -0,0 +1,127 @@
+package game
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OpenTelemetry tracing, exported over OTLP/gRPC when
+// OTEL_EXPORTER_OTLP_ENDPOINT is set. Each message from a client is a trace:
+// a "<transport> <type>" span from the moment it is read, a "handle <type>"
+// span once it is dispatched, which for WebSocket and TCP clients starts
+// after the wait for the game state lock, and spans for the database calls
+// and broadcasts it makes. Each game tick is a trace too, so a slow tick
+// shows which stage overran. The exporter and sampler are configured with
+// the standard OTEL_* variables. Without an endpoint nothing is recorded.
+var tracer = otel.Tracer("online-server-go")
+
+// noopSpan stands in for the spans that are not started.
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// InitTracing installs the OTLP exporter and returns a function that flushes
+// the spans still buffered.
+func InitTracing(config *Config) (func(context.Context) error, error) {
+	if config.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override these
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "online-server-go"),
+			attribute.String("service.instance.id", config.ServerID),
+			attribute.String("game.protocol", config.Protocol),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logrus.Warnf("OpenTelemetry: %v", err)
+	}))
+	return provider.Shutdown, nil
+}
+
+// StartMessageSpan starts the trace of a message read from a client. Its name
+// is set once the message is decoded, see NameMessageSpan.
+func StartMessageSpan(ctx context.Context, transport string, playerID uuid.UUID, size int) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("game.transport", transport),
+		attribute.Int("message.size", size),
+	}
+	if playerID != uuid.Nil {
+		attributes = append(attributes, attribute.String("player.id", playerID.String()))
+	}
+	return tracer.Start(ctx, transport+" message", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+func NameMessageSpan(span trace.Span, transport, messageType string) {
+	span.SetName(transport + " " + messageType)
+	span.SetAttributes(attribute.String("message.type", messageType))
+}
+
+// SetSpanPlayer tags the span of ctx with the player it is about, for
+// transports that learn who sent a message after reading it.
+func SetSpanPlayer(ctx context.Context, playerID uuid.UUID) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("player.id", playerID.String()))
+}
+
+// StartHandleSpan starts the span of dispatching a decoded message.
+func StartHandleSpan(ctx context.Context, messageType string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "handle "+messageType)
+}
+
+// StartBroadcastSpan starts the span of sending a message to many clients.
+func StartBroadcastSpan(ctx context.Context, messageType string, recipients int) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "broadcast "+messageType, trace.WithAttributes(attribute.Int("broadcast.recipients", recipients)))
+}
+
+// StartFlushSpan starts the span of handing the queued messages of a tick to
+// the clients' writers.
+func StartFlushSpan(ctx context.Context, clients int) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "flush outbound", trace.WithAttributes(attribute.Int("broadcast.recipients", clients)))
+}
+
+// StartTickSpan starts the trace of a game tick.
+func StartTickSpan(ctx context.Context, tick uint64) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "tick", trace.WithAttributes(attribute.Int64("game.tick", int64(tick))))
+}
+
+// EndTickSpan ends the span of a tick, flagging it if the tick overran, see
+// TickClock.
+func EndTickSpan(span trace.Span, overrun bool) {
+	if overrun {
+		span.SetAttributes(attribute.Bool("game.tick.overrun", true))
+	}
+	span.End()
+}
+
+// RecordSpanError marks the span of ctx failed if err is set.
+func RecordSpanError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,78 @@
+package game
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SimulationTick is the interval of the game loop, 60 ticks per second.
+	SimulationTick = 16 * time.Millisecond
+
+	// maxCatchUpTicks bounds the ticks run back to back after the game loop
+	// fell behind. Time beyond that is dropped rather than simulated, so a
+	// long stall does not keep the loop busy catching up.
+	maxCatchUpTicks = 5
+
+	// tickOverrunLogInterval is the shortest time between overrun warnings.
+	tickOverrunLogInterval = 10 * time.Second
+)
+
+// TickClock keeps the game loop on a fixed timestep. Every tick simulates
+// exactly one step, and a loop that fell behind runs the ticks it missed, so
+// simulated time keeps pace with the wall clock however long single ticks
+// take. It also measures ticks, counting those that took longer than a step.
+type TickClock struct {
+	step    time.Duration
+	simTime time.Time // the time simulated up to
+
+	overruns    int           // since the last warning
+	slowest     time.Duration // since the last warning
+	lastWarning time.Time
+}
+
+func NewTickClock(step time.Duration, start time.Time) *TickClock {
+	return &TickClock{step: step, simTime: start}
+}
+
+// Due returns how many ticks to run to catch up with now.
+func (c *TickClock) Due(now time.Time) int {
+	due := int(now.Sub(c.simTime) / c.step)
+	if due > maxCatchUpTicks {
+		dropped := due - maxCatchUpTicks
+		metrics.Add("ticks_dropped", int64(dropped))
+		c.simTime = c.simTime.Add(time.Duration(dropped) * c.step)
+		due = maxCatchUpTicks
+	}
+	if due > 1 {
+		metrics.Add("ticks_caught_up", int64(due-1))
+	}
+	return due
+}
+
+// Next advances the simulation by one step and returns the time to simulate
+// the tick at.
+func (c *TickClock) Next() time.Time {
+	c.simTime = c.simTime.Add(c.step)
+	return c.simTime
+}
+
+// Record measures a tick, returning whether it overran its step.
+func (c *TickClock) Record(elapsed time.Duration) bool {
+	metrics.SetGauge("tick_duration_ms", float64(elapsed.Microseconds())/1000)
+	if elapsed <= c.step {
+		return false
+	}
+
+	metrics.Inc("tick_overruns")
+	c.overruns++
+	if elapsed > c.slowest {
+		c.slowest = elapsed
+	}
+	if now := time.Now(); now.Sub(c.lastWarning) >= tickOverrunLogInterval {
+		logrus.Warnf("%d game ticks took longer than the %s timestep, the slowest %s", c.overruns, c.step, c.slowest)
+		c.overruns, c.slowest, c.lastWarning = 0, 0, now
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package game
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTraceEntries  = 1000
+	maxTraceEntries      = 10000
+	defaultTraceRate     = 50 // entries per second
+	maxTracePayloadBytes = 4096
+
+	// All players currently share a single world
+	DefaultRoom = "global"
+)
+
+type TraceEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // "in" or "out"
+	Transport string    `json:"transport"`
+	PlayerID  uuid.UUID `json:"player_id"`
+	Payload   string    `json:"payload"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// Trace is a size-limited ring buffer of captured messages for one target.
+type Trace struct {
+	Target    string    `json:"target"`
+	StartedAt time.Time `json:"started_at"`
+	Capacity  int       `json:"capacity"`
+	Rate      int       `json:"rate"`
+	Captured  int64     `json:"captured"`
+	Dropped   int64     `json:"dropped"`
+
+	entries    []TraceEntry
+	next       int
+	full       bool
+	tokens     float64
+	lastRefill time.Time
+}
+
+func (t *Trace) add(entry TraceEntry) {
+	// Token bucket refilled at Rate entries per second
+	now := entry.Time
+	t.tokens += now.Sub(t.lastRefill).Seconds() * float64(t.Rate)
+	if t.tokens > float64(t.Rate) {
+		t.tokens = float64(t.Rate)
+	}
+	t.lastRefill = now
+	if t.tokens < 1 {
+		t.Dropped++
+		return
+	}
+	t.tokens--
+
+	t.entries[t.next] = entry
+	t.next = (t.next + 1) % t.Capacity
+	if t.next == 0 {
+		t.full = true
+	}
+	t.Captured++
+}
+
+// Entries returns the buffered entries from oldest to newest.
+func (t *Trace) Entries() []TraceEntry {
+	if !t.full {
+		return append([]TraceEntry(nil), t.entries[:t.next]...)
+	}
+	out := make([]TraceEntry, 0, t.Capacity)
+	out = append(out, t.entries[t.next:]...)
+	return append(out, t.entries[:t.next]...)
+}
+
+// PacketTracer captures full inbound and outbound messages for selected
+// players or rooms, independent of the global log level.
+type PacketTracer struct {
+	mu     sync.Mutex
+	traces map[string]*Trace
+	active int32
+}
+
+var DefaultPacketTracer = NewPacketTracer()
+
+func NewPacketTracer() *PacketTracer {
+	return &PacketTracer{traces: make(map[string]*Trace)}
+}
+
+func PlayerTraceTarget(playerID uuid.UUID) string {
+	return "player:" + playerID.String()
+}
+
+func RoomTraceTarget(room string) string {
+	return "room:" + room
+}
+
+// Start begins (or restarts) a trace for target.
+func (pt *PacketTracer) Start(target string, capacity, rate int) (*Trace, error) {
+	if capacity <= 0 {
+		capacity = defaultTraceEntries
+	}
+	if capacity > maxTraceEntries {
+		return nil, fmt.Errorf("capacity must be at most %d", maxTraceEntries)
+	}
+	if rate <= 0 {
+		rate = defaultTraceRate
+	}
+
+	now := time.Now()
+	trace := &Trace{
+		Target:     target,
+		StartedAt:  now,
+		Capacity:   capacity,
+		Rate:       rate,
+		entries:    make([]TraceEntry, capacity),
+		tokens:     float64(rate),
+		lastRefill: now,
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.traces[target] = trace
+	atomic.StoreInt32(&pt.active, int32(len(pt.traces)))
+	return trace, nil
+}
+
+func (pt *PacketTracer) Stop(target string) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	_, exists := pt.traces[target]
+	delete(pt.traces, target)
+	atomic.StoreInt32(&pt.active, int32(len(pt.traces)))
+	return exists
+}
+
+// Snapshot returns a copy of the trace for target, including its entries.
+func (pt *PacketTracer) Snapshot(target string) (*Trace, []TraceEntry, bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	trace, exists := pt.traces[target]
+	if !exists {
+		return nil, nil, false
+	}
+	copied := *trace
+	return &copied, trace.Entries(), true
+}
+
+func (pt *PacketTracer) List() []Trace {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	list := make([]Trace, 0, len(pt.traces))
+	for _, trace := range pt.traces {
+		list = append(list, *trace)
+	}
+	return list
+}
+
+// Record captures a message if a trace is active for the player or room.
+// It is a single atomic load when tracing is off.
+func (pt *PacketTracer) Record(direction, transport string, playerID uuid.UUID, room string, payload []byte) {
+	if atomic.LoadInt32(&pt.active) == 0 {
+		return
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	playerTrace := pt.traces[PlayerTraceTarget(playerID)]
+	roomTrace := pt.traces[RoomTraceTarget(room)]
+	if playerTrace == nil && roomTrace == nil {
+		return
+	}
+
+	// MessagePack payloads are stored as JSON so traces stay readable
+	payload = readablePayload(payload)
+	entry := TraceEntry{
+		Time:      time.Now(),
+		Direction: direction,
+		Transport: transport,
+		PlayerID:  playerID,
+	}
+	if len(payload) > maxTracePayloadBytes {
+		entry.Payload = string(payload[:maxTracePayloadBytes])
+		entry.Truncated = true
+	} else {
+		entry.Payload = string(payload)
+	}
+
+	if playerTrace != nil {
+		playerTrace.add(entry)
+	}
+	if roomTrace != nil {
+		roomTrace.add(entry)
+	}
+}
@@ -0,0 +1,93 @@
+package game
+
+import (
+	"github.com/google/uuid"
+)
+
+// Visibility is the fog of war of competitive play: players are only told
+// where other players and entities are while they are within
+// VISIBILITY_RANGE of them, so that the positions of the rest cannot be
+// read off the wire. Teammates, and entities a player owns, are always
+// visible.
+//
+// A nil *Visibility, with VISIBILITY_RANGE set to 0, shows everything in a
+// zone.
+type Visibility struct {
+	rangeSq float32
+	world   WorldBounds
+}
+
+func NewVisibility(config *Config, world WorldBounds) *Visibility {
+	if config.VisibilityRange <= 0 {
+		return nil
+	}
+	radius := float32(config.VisibilityRange)
+	return &Visibility{rangeSq: radius * radius, world: world}
+}
+
+// Sees reports whether a viewer may be told of something of a team at x, y.
+func (v *Visibility) Sees(viewer Player, team string, x, y float32) bool {
+	if v == nil || (team != "" && team == viewer.Team) {
+		return true
+	}
+	return v.world.DistanceSq(viewer.X, viewer.Y, x, y) <= v.rangeSq
+}
+
+// SeesPlayer reports whether a viewer may be told where another player is.
+func (v *Visibility) SeesPlayer(viewer, other Player) bool {
+	return other.ID == viewer.ID || v.Sees(viewer, other.Team, other.X, other.Y)
+}
+
+// SeesEntity reports whether a viewer may be told of an entity.
+func (v *Visibility) SeesEntity(viewer Player, entity Entity) bool {
+	if v == nil || entity.Position == nil {
+		return true
+	}
+	if entity.Ownership != nil && entity.Ownership.OwnerID == viewer.ID {
+		return true
+	}
+	team := ""
+	if entity.Team != nil {
+		team = entity.Team.Name
+	}
+	return v.Sees(viewer, team, entity.Position.X, entity.Position.Y)
+}
+
+// Filter returns the players and entities of a snapshot the viewer may see.
+func (v *Visibility) Filter(viewer Player, players []Player, entities []Entity) ([]Player, []Entity) {
+	if v == nil {
+		return players, entities
+	}
+	visiblePlayers := make([]Player, 0, len(players))
+	for _, player := range players {
+		if v.SeesPlayer(viewer, player) {
+			visiblePlayers = append(visiblePlayers, player)
+		}
+	}
+	var visibleEntities []Entity
+	for _, entity := range entities {
+		if v.SeesEntity(viewer, entity) {
+			visibleEntities = append(visibleEntities, entity)
+		}
+	}
+	return visiblePlayers, visibleEntities
+}
+
+// HiddenSet holds the other players a viewer has been told are out of
+// sight, for transports that send moves but no regular snapshots to tell
+// the viewer with PlayerHidden when one goes out of sight.
+type HiddenSet map[uuid.UUID]bool
+
+// Update records whether the viewer sees a player and reports whether the
+// player has just gone out of sight.
+func (s HiddenSet) Update(playerID uuid.UUID, visible bool) bool {
+	if visible {
+		delete(s, playerID)
+		return false
+	}
+	if s[playerID] {
+		return false
+	}
+	s[playerID] = true
+	return true
+}
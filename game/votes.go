@@ -0,0 +1,255 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const errorCodeInvalidVote = "invalid_vote"
+
+// Types of votes players may start.
+const (
+	VoteNextMap      = "next_map"      // moves everyone to the zone named by the target
+	VoteKickPlayer   = "kick_player"   // kicks the player whose ID is the target
+	VoteRestartMatch = "restart_match" // ends the current match and starts the next
+)
+
+// voteCountdownInterval is how often a running vote is broadcast with the
+// time it has left.
+const voteCountdownInterval = 5 * time.Second
+
+// VoteTickInterval is how often the UDP server, which has no game loop,
+// checks whether the vote has ended.
+const VoteTickInterval = time.Second
+
+// VoteRule configures a type of vote. A vote passes if at least Quorum of
+// the players online voted and at least Threshold of those votes are yes.
+type VoteRule struct {
+	DurationSeconds float64 `json:"duration_seconds"` // 0 disables the type
+	Quorum          float64 `json:"quorum"`           // share of online players who must vote
+	Threshold       float64 `json:"threshold"`        // share of votes cast that must be yes
+}
+
+func defaultVoteRules() map[string]VoteRule {
+	return map[string]VoteRule{
+		VoteNextMap:      {DurationSeconds: 30, Quorum: 0.5, Threshold: 0.5},
+		VoteKickPlayer:   {DurationSeconds: 30, Quorum: 0.5, Threshold: 0.66},
+		VoteRestartMatch: {DurationSeconds: 30, Quorum: 0.5, Threshold: 0.5},
+	}
+}
+
+func (r VoteRule) validate(voteType string) error {
+	switch {
+	case r.DurationSeconds < 0:
+		return fmt.Errorf("votes.%s.duration_seconds must not be negative, got %g", voteType, r.DurationSeconds)
+	case r.Quorum <= 0 || r.Quorum > 1:
+		return fmt.Errorf("votes.%s.quorum must be above 0 and at most 1, got %g", voteType, r.Quorum)
+	case r.Threshold <= 0 || r.Threshold > 1:
+		return fmt.Errorf("votes.%s.threshold must be above 0 and at most 1, got %g", voteType, r.Threshold)
+	}
+	return nil
+}
+
+func (r VoteRule) duration() time.Duration {
+	return time.Duration(r.DurationSeconds * float64(time.Second))
+}
+
+// passes reports whether yes and no votes out of eligible players carry the
+// vote.
+func (r VoteRule) passes(yes, no, eligible int) bool {
+	cast := yes + no
+	if cast == 0 || eligible == 0 {
+		return false
+	}
+	return float64(cast) >= math.Ceil(r.Quorum*float64(eligible)) && float64(yes) >= r.Threshold*float64(cast)
+}
+
+// validateVoteRules checks the votes of the rules file, whose types must be
+// known.
+func validateVoteRules(rules map[string]VoteRule) error {
+	types := make([]string, 0, len(rules))
+	for voteType := range rules {
+		types = append(types, voteType)
+	}
+	sort.Strings(types)
+
+	known := defaultVoteRules()
+	for _, voteType := range types {
+		if _, ok := known[voteType]; !ok {
+			return fmt.Errorf("unknown vote type %q in votes", voteType)
+		}
+		if err := rules[voteType].validate(voteType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckVoteTarget checks the target of a vote a player wants to start and
+// returns it in canonical form. online tells whether a player is connected;
+// matches is nil on servers without matches.
+func CheckVoteTarget(voteType, target string, starter uuid.UUID, zones *Zones, online func(uuid.UUID) bool, matches *MatchTracker) (string, error) {
+	switch voteType {
+	case VoteKickPlayer:
+		playerID, err := uuid.Parse(target)
+		if err != nil || !online(playerID) {
+			return "", &InputError{Code: errorCodeInvalidVote, Message: "the target of a kick vote must be the ID of an online player"}
+		}
+		if playerID == starter {
+			return "", &InputError{Code: errorCodeInvalidVote, Message: "you cannot start a vote to kick yourself"}
+		}
+		return playerID.String(), nil
+	case VoteNextMap:
+		zone, ok := zones.Lookup(target)
+		if !ok {
+			return "", &InputError{Code: errorCodeInvalidVote, Message: fmt.Sprintf("there is no zone named %q", target)}
+		}
+		return zone.Name, nil
+	case VoteRestartMatch:
+		if matches == nil {
+			return "", &InputError{Code: errorCodeInvalidVote, Message: "there is no match to restart on this server"}
+		}
+		return "", nil
+	}
+	return "", &InputError{Code: errorCodeInvalidVote, Message: fmt.Sprintf("unknown vote type %q", voteType)}
+}
+
+type vote struct {
+	voteType  string
+	target    string
+	startedBy uuid.UUID
+	rule      VoteRule
+	endsAt    time.Time
+	ballots   map[uuid.UUID]bool
+	announced time.Time // when the countdown was last broadcast
+}
+
+// status counts the ballots of the eligible players.
+func (v *vote) status(eligible map[uuid.UUID]bool, now time.Time) VoteStatusData {
+	status := VoteStatusData{
+		Type:      v.voteType,
+		Target:    v.target,
+		StartedBy: v.startedBy,
+		Eligible:  len(eligible),
+		EndsAt:    v.endsAt.UnixMilli(),
+	}
+	for playerID, yes := range v.ballots {
+		if !eligible[playerID] {
+			continue
+		}
+		if yes {
+			status.Yes++
+		} else {
+			status.No++
+		}
+	}
+	if left := v.endsAt.Sub(now); left > 0 {
+		status.SecondsLeft = int(math.Ceil(left.Seconds()))
+	}
+	return status
+}
+
+// VoteManager runs the votes of a game server, one at a time. Every online
+// player may vote; the transport enforces the outcome.
+type VoteManager struct {
+	rules *Rules
+
+	mu     sync.Mutex
+	active *vote
+}
+
+func NewVoteManager(rules *Rules) *VoteManager {
+	return &VoteManager{rules: rules}
+}
+
+// Start opens a vote with the starter's ballot counted as yes. target must
+// have been checked with CheckVoteTarget.
+func (vm *VoteManager) Start(starter uuid.UUID, voteType, target string, eligible []uuid.UUID, now time.Time) (VoteStatusData, error) {
+	rules := vm.rules.Current().Votes
+	rule, ok := rules[voteType]
+	if !ok || rule.duration() <= 0 {
+		known := make([]string, 0, len(rules))
+		for name, rule := range rules {
+			if rule.duration() > 0 {
+				known = append(known, name)
+			}
+		}
+		sort.Strings(known)
+		return VoteStatusData{}, &InputError{Code: errorCodeInvalidVote, Message: fmt.Sprintf("unknown vote type %q (known: %s)", voteType, strings.Join(known, ", "))}
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.active != nil {
+		return VoteStatusData{}, &InputError{Code: errorCodeInvalidVote, Message: "a vote is already running"}
+	}
+
+	vm.active = &vote{
+		voteType:  voteType,
+		target:    target,
+		startedBy: starter,
+		rule:      rule,
+		endsAt:    now.Add(rule.duration()),
+		ballots:   map[uuid.UUID]bool{starter: true},
+		announced: now,
+	}
+	return vm.active.status(voteSet(eligible), now), nil
+}
+
+// Cast records or changes a player's ballot in the running vote.
+func (vm *VoteManager) Cast(playerID uuid.UUID, yes bool, eligible []uuid.UUID, now time.Time) (VoteStatusData, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.active == nil {
+		return VoteStatusData{}, &InputError{Code: errorCodeInvalidVote, Message: "no vote is running"}
+	}
+	vm.active.ballots[playerID] = yes
+	return vm.active.status(voteSet(eligible), now), nil
+}
+
+// Tick checks the running vote at now. It returns a VoteUpdate message when
+// a countdown broadcast is due, and the outcome once the vote has run its
+// time or every eligible player has voted.
+func (vm *VoteManager) Tick(eligible []uuid.UUID, now time.Time) (*GameMessage, *VoteEndedData) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.active == nil {
+		return nil, nil
+	}
+
+	players := voteSet(eligible)
+	status := vm.active.status(players, now)
+	if now.Before(vm.active.endsAt) && status.Yes+status.No < status.Eligible {
+		if now.Sub(vm.active.announced) < voteCountdownInterval {
+			return nil, nil
+		}
+		vm.active.announced = now
+		updateMessage := NewVoteUpdateMessage(status)
+		return &updateMessage, nil
+	}
+
+	ended := &VoteEndedData{
+		Type:     status.Type,
+		Target:   status.Target,
+		Yes:      status.Yes,
+		No:       status.No,
+		Eligible: status.Eligible,
+		Passed:   vm.active.rule.passes(status.Yes, status.No, status.Eligible),
+	}
+	vm.active = nil
+	return nil, ended
+}
+
+func voteSet(players []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(players))
+	for _, playerID := range players {
+		set[playerID] = true
+	}
+	return set
+}
@@ -0,0 +1,29 @@
+package game
+
+import (
+	"github.com/google/uuid"
+)
+
+// HandleWhisper delivers a private message from senderID to the target player,
+// who may be connected to another instance when dir is cluster-aware.
+func HandleWhisper(senderID uuid.UUID, senderName string, message *GameMessage, dir PlayerDirectory) {
+	var data WhisperRequestData
+	if err := DecodeMessageData(message.Data, &data); err != nil || data.TargetID == uuid.Nil || data.Message == "" {
+		errorMsg := NewErrorMessage("target_id and message are required")
+		dir.SendToPlayer(senderID, &errorMsg)
+		return
+	}
+
+	text, err := SanitizeChat(data.Message)
+	if err != nil {
+		errorMsg := NewInputErrorMessage(err)
+		dir.SendToPlayer(senderID, &errorMsg)
+		return
+	}
+
+	whisperMsg := NewWhisperMessage(senderID, senderName, text)
+	if !dir.SendToPlayer(data.TargetID, &whisperMsg) {
+		errorMsg := NewErrorMessage("Player is not online")
+		dir.SendToPlayer(senderID, &errorMsg)
+	}
+}
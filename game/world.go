@@ -0,0 +1,105 @@
+package game
+
+import (
+	"fmt"
+	"math"
+)
+
+// WorldBounds is the playfield, spanning [0, Width] by [0, Height]. A zero
+// dimension leaves that axis unbounded. Positions leaving the playfield are
+// clamped to its edge or, with Wrap, reenter on the opposite side.
+type WorldBounds struct {
+	Width  float32
+	Height float32
+	Wrap   bool
+}
+
+// ParseWorldEdge parses WORLD_EDGE into whether positions wrap. An empty
+// name means clamp.
+func ParseWorldEdge(name string) (bool, error) {
+	switch name {
+	case "", "clamp":
+		return false, nil
+	case "wrap":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown world edge %q, expected \"clamp\" or \"wrap\"", name)
+	}
+}
+
+// NewWorldBounds reads the playfield from the config, which the startup
+// checks have validated.
+func NewWorldBounds(config *Config) WorldBounds {
+	wrap, _ := ParseWorldEdge(config.WorldEdge)
+	return WorldBounds{
+		Width:  float32(config.WorldWidth),
+		Height: float32(config.WorldHeight),
+		Wrap:   wrap,
+	}
+}
+
+// Contains reports whether a position is on the playfield.
+func (b WorldBounds) Contains(x, y float32) bool {
+	return withinAxis(x, b.Width) && withinAxis(y, b.Height)
+}
+
+func withinAxis(v, size float32) bool {
+	return size == 0 || (v >= 0 && v <= size)
+}
+
+// Place returns the position moved onto the playfield.
+func (b WorldBounds) Place(x, y float32) (float32, float32) {
+	return b.placeAxis(x, b.Width), b.placeAxis(y, b.Height)
+}
+
+func (b WorldBounds) placeAxis(v, size float32) float32 {
+	if size == 0 || (v >= 0 && v <= size) {
+		return v
+	}
+	if b.Wrap {
+		v = float32(math.Mod(float64(v), float64(size)))
+		if v < 0 {
+			v += size
+		}
+		return v
+	}
+	if v < 0 {
+		return 0
+	}
+	return size
+}
+
+// DistanceSq is the squared distance between two positions, the shorter way
+// around on a playfield that wraps.
+func (b WorldBounds) DistanceSq(x1, y1, x2, y2 float32) float32 {
+	dx := b.axisDistance(x1-x2, b.Width)
+	dy := b.axisDistance(y1-y2, b.Height)
+	return dx*dx + dy*dy
+}
+
+func (b WorldBounds) axisDistance(d, size float32) float32 {
+	if d < 0 {
+		d = -d
+	}
+	if b.Wrap && size > 0 && size-d < d {
+		return size - d
+	}
+	return d
+}
+
+// Confine moves a player onto the playfield. A steered or pushed player
+// stops along an axis it is clamped on, so that it does not keep pushing
+// against the edge.
+func (b WorldBounds) Confine(p *Player) {
+	x, y := b.Place(p.X, p.Y)
+	if !b.Wrap {
+		if x != p.X {
+			p.VX, p.pushVX = 0, 0
+		}
+		if y != p.Y {
+			p.VY, p.pushVY = 0, 0
+		}
+		p.steered = p.steered && (p.VX != 0 || p.VY != 0)
+	}
+	p.X, p.Y = x, y
+}
@@ -0,0 +1,249 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WorldEventKind describes the effects of one kind of world event.
+type WorldEventKind struct {
+	Description      string
+	Duration         time.Duration
+	PointsMultiplier int64  // pickup points are multiplied while the event runs
+	BonusItem        string // extra item granted with every pickup while the event runs
+}
+
+var worldEventKinds = map[string]WorldEventKind{
+	"double_points": {
+		Description:      "Double points on every pickup",
+		Duration:         5 * time.Minute,
+		PointsMultiplier: 2,
+	},
+	"boss_spawn": {
+		Description:      "A boss has appeared; pickups also drop health potions",
+		Duration:         10 * time.Minute,
+		PointsMultiplier: 1,
+		BonusItem:        "health_potion",
+	},
+}
+
+// WorldEvent is a running world event.
+type WorldEvent struct {
+	Type             string    `json:"type"`
+	Description      string    `json:"description"`
+	PointsMultiplier int64     `json:"points_multiplier"`
+	BonusItem        string    `json:"bonus_item,omitempty"`
+	StartedAt        time.Time `json:"started_at"`
+	EndsAt           time.Time `json:"ends_at"`
+}
+
+// WorldEvents runs server-wide events, started by the game loop every
+// interval or by an operator through the admin API. Each start and end is
+// broadcast to all players as a WorldEvent message.
+type WorldEvents struct {
+	interval time.Duration // zero disables scheduled events
+	random   *SimRand
+
+	mu            sync.Mutex
+	active        map[string]*WorldEvent
+	nextScheduled time.Time
+	broadcast     func(message *GameMessage)
+}
+
+func NewWorldEvents(interval time.Duration, random *SimRand) *WorldEvents {
+	return &WorldEvents{
+		interval:      interval,
+		random:        random,
+		active:        make(map[string]*WorldEvent),
+		nextScheduled: time.Now().Add(interval),
+	}
+}
+
+// Attach sets how start and end announcements reach every player. The
+// function must take its own locks.
+func (we *WorldEvents) Attach(broadcast func(message *GameMessage)) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	we.broadcast = broadcast
+}
+
+// Start begins an event, or extends it if it is already running. A zero
+// duration uses the kind's default.
+func (we *WorldEvents) Start(eventType string, duration time.Duration) (WorldEvent, error) {
+	kind, known := worldEventKinds[eventType]
+	if !known {
+		return WorldEvent{}, fmt.Errorf("unknown world event %q", eventType)
+	}
+	if duration < 0 {
+		return WorldEvent{}, fmt.Errorf("duration must not be negative")
+	}
+	if duration == 0 {
+		duration = kind.Duration
+	}
+
+	we.mu.Lock()
+	now := time.Now()
+	event, running := we.active[eventType]
+	if !running {
+		event = &WorldEvent{
+			Type:             eventType,
+			Description:      kind.Description,
+			PointsMultiplier: kind.PointsMultiplier,
+			BonusItem:        kind.BonusItem,
+			StartedAt:        now,
+		}
+		we.active[eventType] = event
+	}
+	event.EndsAt = now.Add(duration)
+	started := *event
+	broadcast := we.broadcast
+	we.mu.Unlock()
+
+	logrus.Infof("World event %s running until %s", eventType, started.EndsAt.Format(time.RFC3339))
+	metrics.Inc("world_events_started")
+	we.announce(broadcast, "started", started)
+	return started, nil
+}
+
+// Stop ends a running event early.
+func (we *WorldEvents) Stop(eventType string) bool {
+	we.mu.Lock()
+	event, running := we.active[eventType]
+	if running {
+		delete(we.active, eventType)
+	}
+	broadcast := we.broadcast
+	we.mu.Unlock()
+
+	if !running {
+		return false
+	}
+	logrus.Infof("World event %s stopped", eventType)
+	we.announce(broadcast, "ended", *event)
+	return true
+}
+
+// Active returns the running events, oldest first.
+func (we *WorldEvents) Active() []WorldEvent {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+
+	events := make([]WorldEvent, 0, len(we.active))
+	for _, event := range we.active {
+		events = append(events, *event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartedAt.Before(events[j].StartedAt)
+	})
+	return events
+}
+
+// NextScheduled returns when the next scheduled event starts.
+func (we *WorldEvents) NextScheduled() time.Time {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	return we.nextScheduled
+}
+
+// Restore resumes events saved before a restart, skipping those that have
+// ended or are no longer known, and keeps the schedule of the next one. It
+// is called before players connect, so nothing is announced.
+func (we *WorldEvents) Restore(events []WorldEvent, nextScheduled time.Time) int {
+	now := time.Now()
+
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	restored := 0
+	for _, event := range events {
+		if _, known := worldEventKinds[event.Type]; !known || !now.Before(event.EndsAt) {
+			continue
+		}
+		event := event
+		we.active[event.Type] = &event
+		restored++
+	}
+	if we.interval > 0 && !nextScheduled.IsZero() {
+		we.nextScheduled = nextScheduled
+	}
+	return restored
+}
+
+// Tick ends expired events and starts the next scheduled one when due. It is
+// called from the game loop.
+func (we *WorldEvents) Tick() {
+	now := time.Now()
+
+	we.mu.Lock()
+	var ended []WorldEvent
+	for eventType, event := range we.active {
+		if !now.Before(event.EndsAt) {
+			ended = append(ended, *event)
+			delete(we.active, eventType)
+		}
+	}
+
+	var scheduled string
+	if we.interval > 0 && !now.Before(we.nextScheduled) {
+		we.nextScheduled = now.Add(we.interval)
+		var idle []string
+		for eventType := range worldEventKinds {
+			if _, running := we.active[eventType]; !running {
+				idle = append(idle, eventType)
+			}
+		}
+		if len(idle) > 0 {
+			sort.Strings(idle)
+			scheduled = idle[we.random.Intn(len(idle))]
+		}
+	}
+	broadcast := we.broadcast
+	we.mu.Unlock()
+
+	for _, event := range ended {
+		logrus.Infof("World event %s ended", event.Type)
+		we.announce(broadcast, "ended", event)
+	}
+	if scheduled != "" {
+		we.Start(scheduled, 0)
+	}
+}
+
+// ScalePoints applies the points multipliers of all running events.
+func (we *WorldEvents) ScalePoints(points int64) int64 {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+
+	for _, event := range we.active {
+		if event.PointsMultiplier > 1 {
+			points *= event.PointsMultiplier
+		}
+	}
+	return points
+}
+
+// BonusItems returns the extra items granted with a pickup.
+func (we *WorldEvents) BonusItems() []string {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+
+	var items []string
+	for _, event := range we.active {
+		if event.BonusItem != "" {
+			items = append(items, event.BonusItem)
+		}
+	}
+	sort.Strings(items)
+	return items
+}
+
+func (we *WorldEvents) announce(broadcast func(message *GameMessage), state string, event WorldEvent) {
+	if broadcast == nil {
+		return
+	}
+	message := NewWorldEventMessage(state, event)
+	broadcast(&message)
+}
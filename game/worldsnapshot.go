@@ -0,0 +1,99 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+// WorldSnapshot is the world state outside players that is kept across
+// restarts: the running world events, when the next scheduled one starts and
+// the entities of the world. Match rooms are not kept, since they close once
+// their players leave.
+type WorldSnapshot struct {
+	SavedAt     time.Time    `json:"saved_at"`
+	Events      []WorldEvent `json:"events"`
+	NextEventAt time.Time    `json:"next_event_at"`
+	Entities    []Entity     `json:"entities,omitempty"`
+}
+
+// WorldPersister saves the world snapshot of this server, keyed by
+// SERVER_ID, every WORLD_SNAPSHOT_INTERVAL and on shutdown, and restores it
+// at startup. A nil *WorldPersister keeps nothing.
+type WorldPersister struct {
+	database store.Store
+	events   *WorldEvents
+	entities *EntityRegistry
+	interval time.Duration
+}
+
+// NewWorldPersister returns nil when interval is 0.
+func NewWorldPersister(database store.Store, events *WorldEvents, entities *EntityRegistry, interval time.Duration) *WorldPersister {
+	if interval <= 0 {
+		return nil
+	}
+	return &WorldPersister{database: database, events: events, entities: entities, interval: interval}
+}
+
+// Restore loads the last snapshot saved, if any.
+func (wp *WorldPersister) Restore(ctx context.Context) error {
+	if wp == nil {
+		return nil
+	}
+
+	data, err := wp.database.GetWorldSnapshot(ctx)
+	if err != nil || data == nil {
+		return err
+	}
+	var snapshot WorldSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode world snapshot: %w", err)
+	}
+
+	events := wp.events.Restore(snapshot.Events, snapshot.NextEventAt)
+	entities := wp.entities.Restore(snapshot.Entities, time.Now())
+	logrus.Infof("Restored the world saved at %s: %d world events running, %d entities", snapshot.SavedAt.Format(time.RFC3339), events, entities)
+	return nil
+}
+
+// Save stores the current world state.
+func (wp *WorldPersister) Save(ctx context.Context) error {
+	if wp == nil {
+		return nil
+	}
+
+	snapshot := WorldSnapshot{
+		SavedAt:     time.Now().UTC(),
+		Events:      wp.events.Active(),
+		NextEventAt: wp.events.NextScheduled(),
+		Entities:    wp.entities.All(time.Now()),
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode world snapshot: %w", err)
+	}
+	return wp.database.SaveWorldSnapshot(ctx, data)
+}
+
+// Run saves the world every interval.
+func (wp *WorldPersister) Run() {
+	if wp == nil {
+		return
+	}
+
+	ticker := time.NewTicker(wp.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := wp.Save(context.Background()); err != nil {
+				logrus.Errorf("Failed to save world snapshot: %v", err)
+			}
+		}
+	}
+}
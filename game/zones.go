@@ -0,0 +1,198 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/store"
+)
+
+const maxZoneNameLength = 32
+
+const errorCodeInvalidZone = "invalid_zone"
+
+// Zone is a map of the server. Players only see the joins, leaves, moves and
+// snapshots of players in their own zone, move between zones with
+// ChangeZone and arrive at the zone's entry spawn point.
+type Zone struct {
+	Name        string       `json:"name"`
+	SpawnX      float32      `json:"spawn_x"`
+	SpawnY      float32      `json:"spawn_y"`
+	SpawnPoints []SpawnPoint `json:"spawn_points,omitempty"` // where killed players respawn, the entry spawn point if empty
+}
+
+type SpawnPoint struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+// RespawnPoint picks the spawn point farthest from the nearest of enemies,
+// so that a respawned player does not come back next to whoever killed
+// them.
+func (z Zone) RespawnPoint(enemies []Player) (float32, float32) {
+	if len(z.SpawnPoints) == 0 {
+		return z.SpawnX, z.SpawnY
+	}
+
+	best, bestDistance := z.SpawnPoints[0], -1.0
+	for _, point := range z.SpawnPoints {
+		nearest := math.Inf(1)
+		for _, enemy := range enemies {
+			dx, dy := float64(enemy.X-point.X), float64(enemy.Y-point.Y)
+			nearest = math.Min(nearest, dx*dx+dy*dy)
+		}
+		if nearest > bestDistance {
+			best, bestDistance = point, nearest
+		}
+	}
+	return best.X, best.Y
+}
+
+// LoadZones reads and validates the zones file. An empty path yields a
+// single zone named after the map, spawning players at the origin.
+func LoadZones(path, mapName string, world WorldBounds) ([]Zone, error) {
+	if path == "" {
+		return []Zone{{Name: mapName}}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zones file: %w", err)
+	}
+	var zones []Zone
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&zones); err != nil {
+		return nil, fmt.Errorf("failed to parse zones file %s: %w", path, err)
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("zones file %s lists no zones", path)
+	}
+
+	seen := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		if err := validateZoneName(zone.Name); err != nil {
+			return nil, err
+		}
+		if seen[zone.Name] {
+			return nil, fmt.Errorf("zone %s is listed twice", zone.Name)
+		}
+		seen[zone.Name] = true
+		if !world.Contains(zone.SpawnX, zone.SpawnY) {
+			return nil, fmt.Errorf("spawn point of zone %s is outside the world", zone.Name)
+		}
+		for _, point := range zone.SpawnPoints {
+			if !world.Contains(point.X, point.Y) {
+				return nil, fmt.Errorf("respawn point (%g, %g) of zone %s is outside the world", point.X, point.Y, zone.Name)
+			}
+		}
+	}
+	return zones, nil
+}
+
+func validateZoneName(name string) error {
+	if name == "" || len(name) > maxZoneNameLength {
+		return fmt.Errorf("zone name %q must be 1 to %d characters", name, maxZoneNameLength)
+	}
+	for _, r := range name {
+		if !isNameRune(r) && r != '-' {
+			return fmt.Errorf("zone name %q may only contain letters, digits, underscores and dashes", name)
+		}
+	}
+	return nil
+}
+
+// Zones are the zones of a server. The zone each player is in is saved on
+// their player record, so that they rejoin where they left.
+type Zones struct {
+	database store.Store
+	zones    []Zone // the first is where new players start
+}
+
+// NewZones loads ZONES_FILE, which the startup checks have validated.
+func NewZones(database store.Store, config *Config) *Zones {
+	zones, err := LoadZones(config.ZonesFile, config.MapName, NewWorldBounds(config))
+	if err != nil {
+		logrus.Errorf("Failed to load zones, using a single zone: %v", err)
+		zones = []Zone{{Name: config.MapName}}
+	}
+	return &Zones{database: database, zones: zones}
+}
+
+// Lookup returns the zone with a name.
+func (z *Zones) Lookup(name string) (Zone, bool) {
+	for _, zone := range z.zones {
+		if zone.Name == name {
+			return zone, true
+		}
+	}
+	return Zone{}, false
+}
+
+// Load puts a joining player at the spawn point of the zone they were last
+// in, or of the first zone. A player resumed from a replication primary
+// keeps their zone and position.
+func (z *Zones) Load(ctx context.Context, player *Player) {
+	if _, ok := z.Lookup(player.Zone); ok {
+		return
+	}
+
+	zone := z.zones[0]
+	saved, err := z.database.GetPlayerZone(ctx, player.ID)
+	if err != nil {
+		logrus.Errorf("Failed to load zone for %s: %v", player.ID, err)
+	} else if known, ok := z.Lookup(saved); ok {
+		zone = known
+	}
+	player.EnterZone(zone, time.Now())
+}
+
+// Enter checks that a player in zone current may move to the zone named
+// name, saves their new zone and returns it.
+func (z *Zones) Enter(ctx context.Context, playerID uuid.UUID, current, name string) (Zone, error) {
+	zone, ok := z.Lookup(name)
+	if !ok {
+		return Zone{}, &InputError{Code: errorCodeInvalidZone, Message: fmt.Sprintf("there is no zone named %q", name)}
+	}
+	if zone.Name == current {
+		return Zone{}, &InputError{Code: errorCodeInvalidZone, Message: "you are already in " + zone.Name}
+	}
+
+	if err := z.database.UpdatePlayerZone(ctx, playerID, zone.Name); err != nil {
+		logrus.Errorf("Failed to save zone of %s: %v", playerID, err)
+	}
+	return zone, nil
+}
+
+// Respawn puts a respawning player at the spawn point of their zone that is
+// farthest from the other living players in it.
+func (z *Zones) Respawn(player *Player, players []Player, now time.Time) {
+	zone, ok := z.Lookup(player.Zone)
+	if !ok {
+		player.EnterZone(z.zones[0], now)
+		return
+	}
+
+	var enemies []Player
+	for _, other := range players {
+		if other.ID != player.ID && other.Zone == zone.Name && other.Health > 0 {
+			enemies = append(enemies, other)
+		}
+	}
+	x, y := zone.RespawnPoint(enemies)
+	player.Teleport(x, y, now)
+}
+
+// EnterZone moves a player into a zone, at rest at its spawn point.
+func (p *Player) EnterZone(zone Zone, now time.Time) {
+	p.Zone = zone.Name
+	p.Teleport(zone.SpawnX, zone.SpawnY, now)
+}
@@ -0,0 +1,59 @@
+// Package gameplugin is what Go plugins customizing the game server build
+// against. A plugin is a main package built with
+//
+//	go build -buildmode=plugin -o greeter.so ./greeter
+//
+// from this module, with the same Go version and flags as the server, that
+// exports a Hooks variable:
+//
+//	package main
+//
+//	import "online-server-go/gameplugin"
+//
+//	var Hooks = gameplugin.Hooks{
+//		OnPlayerJoin: func(server gameplugin.Server, player gameplugin.Player) {
+//			server.Tell(player.ID, "Welcome, "+player.Name)
+//		},
+//	}
+//
+// The server loads every .so file in PLUGIN_DIR at startup and calls the
+// hooks of each plugin in file name order.
+package gameplugin
+
+// Player is a player as the server last knew them.
+type Player struct {
+	ID     string
+	Name   string
+	X, Y   float32
+	Health float32
+	Score  uint32
+	Level  int
+}
+
+// Server is what hooks may do to the game. Calls are queued and carried out
+// in order after the hook returns, so they take effect shortly after.
+type Server interface {
+	// Announce shows a message to every player.
+	Announce(message string)
+	// Tell shows a message to one player.
+	Tell(playerID, message string)
+	// Kick disconnects a player, telling them why.
+	Kick(playerID, message string)
+}
+
+// Hooks are the functions a plugin customizes the game with. Any of them may
+// be nil. Hooks run on the server's goroutines, concurrently with each other
+// and often while the game is locked, so they must guard their own state and
+// return quickly.
+type Hooks struct {
+	// OnPlayerJoin is called once a player has joined.
+	OnPlayerJoin func(server Server, player Player)
+	// OnAction is called before a PlayerAction such as "attack" or "pickup"
+	// is carried out. Returning false cancels it.
+	OnAction func(server Server, player Player, action string) bool
+	// OnChat is called before a chat message is sent, with the message as
+	// sanitized. It returns the message to send instead, and false to drop it.
+	OnChat func(server Server, player Player, message string) (string, bool)
+	// OnTick is called 60 times a second with the ticks so far.
+	OnTick func(server Server, tick uint64)
+}
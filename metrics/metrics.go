@@ -0,0 +1,129 @@
+// Package metrics is the in-process registry of named counters and gauges
+// the game server and its store count into.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics is a minimal in-process registry of named counters and gauges.
+type Metrics struct {
+	mu       sync.RWMutex
+	counters map[string]int64
+	gauges   map[string]float64
+}
+
+// Default is the registry of the process, served by the game's /metrics.
+var Default = New()
+
+func New() *Metrics {
+	return &Metrics{
+		counters: make(map[string]int64),
+		gauges:   make(map[string]float64),
+	}
+}
+
+func (m *Metrics) Inc(name string) {
+	m.Add(name, 1)
+}
+
+func (m *Metrics) Add(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += delta
+}
+
+func (m *Metrics) SetGauge(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = value
+}
+
+func (m *Metrics) Counter(name string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.counters[name]
+}
+
+func (m *Metrics) Gauge(name string) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.gauges[name]
+}
+
+// Snapshot returns a copy of all counters and gauges keyed by name.
+func (m *Metrics) Snapshot() map[string]float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]float64, len(m.counters)+len(m.gauges))
+	for name, value := range m.counters {
+		snapshot[name] = float64(value)
+	}
+	for name, value := range m.gauges {
+		snapshot[name] = value
+	}
+	return snapshot
+}
+
+// Names returns all registered metric names in sorted order.
+func (m *Metrics) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.counters)+len(m.gauges))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	for name := range m.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WritePrometheus writes every counter and gauge in the Prometheus text
+// exposition format, with names prefixed by "game_".
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.counters))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metric := prometheusName(name)
+		if _, err := fmt.Fprintf(w, "# TYPE %s counter\n%s %d\n", metric, metric, m.counters[name]); err != nil {
+			return err
+		}
+	}
+
+	names = names[:0]
+	for name := range m.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metric := prometheusName(name)
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n%s %g\n", metric, metric, m.gauges[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prometheusName turns a metric name into a valid Prometheus one.
+func prometheusName(name string) string {
+	return "game_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,13 @@
+-- Aggregated per-player statistics
+CREATE TABLE player_stats (
+    player_id TEXT PRIMARY KEY,
+    kills INTEGER NOT NULL DEFAULT 0,
+    deaths INTEGER NOT NULL DEFAULT 0,
+    assists INTEGER NOT NULL DEFAULT 0,
+    items_collected INTEGER NOT NULL DEFAULT 0,
+    playtime_seconds INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_player_stats_kills ON player_stats(kills DESC);
@@ -0,0 +1,27 @@
+-- Skill rating (ELO) for matchmaking and leaderboards
+ALTER TABLE players ADD COLUMN rating INTEGER NOT NULL DEFAULT 1200;
+
+-- Matches played on this server
+CREATE TABLE matches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    protocol TEXT NOT NULL DEFAULT 'websocket',
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    ended_at DATETIME,
+    player_count INTEGER NOT NULL DEFAULT 0
+);
+
+-- Rating changes per player per match
+CREATE TABLE rating_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    player_id TEXT NOT NULL,
+    match_id INTEGER,
+    old_rating INTEGER NOT NULL,
+    new_rating INTEGER NOT NULL,
+    placement INTEGER NOT NULL,
+    recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE,
+    FOREIGN KEY (match_id) REFERENCES matches(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_players_rating ON players(rating DESC);
+CREATE INDEX idx_rating_history_player ON rating_history(player_id);
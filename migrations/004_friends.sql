@@ -0,0 +1,13 @@
+-- Friend relationships, each row directed from requester to recipient
+CREATE TABLE friends (
+    player_id TEXT NOT NULL,
+    friend_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending' or 'accepted'
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    accepted_at DATETIME,
+    PRIMARY KEY (player_id, friend_id),
+    FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE,
+    FOREIGN KEY (friend_id) REFERENCES players(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_friends_friend ON friends(friend_id);
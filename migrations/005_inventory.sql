@@ -0,0 +1,9 @@
+-- Per-player inventory, one row per item type
+CREATE TABLE inventory (
+    player_id TEXT NOT NULL,
+    item_type TEXT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (player_id, item_type),
+    FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Persistent XP and level per player
+ALTER TABLE players ADD COLUMN xp INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE players ADD COLUMN level INTEGER NOT NULL DEFAULT 1;
+
+-- XP granted per action, editable without a rebuild
+CREATE TABLE xp_rules (
+    action TEXT PRIMARY KEY,
+    xp INTEGER NOT NULL
+);
+
+INSERT OR IGNORE INTO xp_rules (action, xp) VALUES ('pickup', 10);
+INSERT OR IGNORE INTO xp_rules (action, xp) VALUES ('hit', 2);
+INSERT OR IGNORE INTO xp_rules (action, xp) VALUES ('kill', 50);
+INSERT OR IGNORE INTO xp_rules (action, xp) VALUES ('match_played', 25);
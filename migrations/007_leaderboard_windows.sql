@@ -0,0 +1,12 @@
+-- Points earned per player per leaderboard window, rolled up as they are earned
+CREATE TABLE leaderboard_rollups (
+    period TEXT NOT NULL, -- 'daily', 'weekly', 'monthly' or 'all'
+    period_start TEXT NOT NULL, -- UTC day, week start (Monday) or month, 'all' for all-time
+    player_id TEXT NOT NULL,
+    score INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (period, period_start, player_id),
+    FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_leaderboard_rollups_rank ON leaderboard_rollups(period, period_start, score DESC);
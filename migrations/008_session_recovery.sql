@@ -0,0 +1,7 @@
+-- Record which server instance owns a session and why it was closed, so a
+-- restarted instance can close the sessions it left open.
+-- end_reason is 'disconnect', 'stale' or 'server_restart'
+ALTER TABLE game_sessions ADD COLUMN server_id TEXT;
+ALTER TABLE game_sessions ADD COLUMN end_reason TEXT;
+
+CREATE INDEX idx_game_sessions_open ON game_sessions(server_id, session_end);
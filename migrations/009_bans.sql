@@ -0,0 +1,7 @@
+-- Player bans enforced by every instance sharing the database
+CREATE TABLE bans (
+    player_id TEXT PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME -- NULL for a permanent ban
+);
@@ -0,0 +1,15 @@
+-- Attribute events and matches to the server instance that produced them,
+-- and let every instance sharing the database report its health
+ALTER TABLE player_events ADD COLUMN server_id TEXT;
+ALTER TABLE matches ADD COLUMN server_id TEXT;
+
+CREATE INDEX idx_player_events_server ON player_events(server_id, timestamp);
+CREATE INDEX idx_matches_server ON matches(server_id);
+
+CREATE TABLE server_instances (
+    server_id TEXT PRIMARY KEY,
+    protocol TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    last_heartbeat DATETIME NOT NULL,
+    players_online INTEGER NOT NULL DEFAULT 0
+);
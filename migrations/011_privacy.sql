@@ -0,0 +1,8 @@
+-- Per-player privacy choices, enforced when data is written
+CREATE TABLE player_privacy (
+    player_id TEXT PRIMARY KEY,
+    no_chat_log INTEGER NOT NULL DEFAULT 0, -- store chat without its content
+    no_ip_storage INTEGER NOT NULL DEFAULT 0, -- never store the client IP
+    anonymize_events INTEGER NOT NULL DEFAULT 0, -- log events without the player ID
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,29 @@
+-- Registered users. An account's ID is the player ID it plays as
+CREATE TABLE accounts (
+    id TEXT PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT, -- bcrypt, NULL for accounts that only log in with OAuth
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (id) REFERENCES players(id) ON DELETE CASCADE
+);
+
+-- OAuth provider identities that log in to an account
+CREATE TABLE account_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL, -- the provider's user ID
+    account_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject),
+    FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+-- Login tokens, stored as SHA-256 hashes
+CREATE TABLE auth_tokens (
+    token_hash TEXT PRIMARY KEY,
+    account_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_auth_tokens_account ON auth_tokens(account_id);
@@ -0,0 +1,7 @@
+-- World state outside players, saved by each server instance so that it
+-- survives restarts, see WorldPersister
+CREATE TABLE world_snapshots (
+    server_id TEXT PRIMARY KEY,
+    data TEXT NOT NULL, -- JSON WorldSnapshot
+    saved_at DATETIME NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Per-player preferences saved server-side, such as a name color or controls
+CREATE TABLE player_preferences (
+    player_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (player_id, name)
+);
@@ -0,0 +1,19 @@
+-- Guilds, which players create and join by tag
+CREATE TABLE guilds (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    tag TEXT NOT NULL UNIQUE COLLATE NOCASE, -- shown next to member names
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Guild membership. A player is in at most one guild
+CREATE TABLE guild_members (
+    player_id TEXT PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    rank TEXT NOT NULL DEFAULT 'member', -- leader, officer or member
+    joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE,
+    FOREIGN KEY (guild_id) REFERENCES guilds(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_guild_members_guild ON guild_members(guild_id);
@@ -0,0 +1,13 @@
+-- Mail players send each other, kept until the recipient deletes it
+CREATE TABLE mail (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sender_id TEXT NOT NULL,
+    recipient_id TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME, -- when MailReceived reached the recipient
+    read_at DATETIME
+);
+
+CREATE INDEX idx_mail_recipient ON mail(recipient_id, id);
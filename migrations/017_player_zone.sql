@@ -0,0 +1,3 @@
+-- The zone each player was last in, restored when they rejoin. Empty for
+-- the first zone of the server.
+ALTER TABLE players ADD COLUMN zone TEXT NOT NULL DEFAULT '';
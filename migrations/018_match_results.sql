@@ -0,0 +1,13 @@
+-- Final scoreboard of each match, one row per participant
+CREATE TABLE match_results (
+    match_id INTEGER NOT NULL,
+    player_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    rank INTEGER NOT NULL,
+    score INTEGER NOT NULL DEFAULT 0,
+    kills INTEGER NOT NULL DEFAULT 0,
+    deaths INTEGER NOT NULL DEFAULT 0,
+    ping_ms INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (match_id, player_id),
+    FOREIGN KEY (match_id) REFERENCES matches(id) ON DELETE CASCADE
+);
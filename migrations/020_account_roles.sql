@@ -0,0 +1,2 @@
+-- Staff role of each account: owner, admin, moderator or player
+ALTER TABLE accounts ADD COLUMN role TEXT NOT NULL DEFAULT 'player';
@@ -0,0 +1,16 @@
+-- Reports players file against each other, reviewed by staff
+CREATE TABLE player_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reporter_id TEXT NOT NULL,
+    reported_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    chat_excerpt TEXT NOT NULL DEFAULT '',
+    escalated BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME,
+    resolved_by TEXT NOT NULL DEFAULT '',
+    resolution TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_player_reports_reported ON player_reports(reported_id, created_at);
+CREATE INDEX idx_player_reports_open ON player_reports(resolved_at, escalated);
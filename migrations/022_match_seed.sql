@@ -0,0 +1,2 @@
+-- Seed of each match's simulation randomness, to replay it with DETERMINISTIC
+ALTER TABLE matches ADD COLUMN seed INTEGER;
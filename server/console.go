@@ -0,0 +1,341 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+)
+
+const consoleHelp = `Commands:
+  players                     list connected players
+  kick <player_id> [reason]   disconnect a player
+  say <message>               announce a message to every player
+  entities [zone]             list players and entities, of one zone or all
+  spawn <kind> <zone> <x> <y> add an npc, item or projectile to a zone
+  despawn <entity_id>         remove an entity
+  explode <zone> <x> <y> <radius> <speed>
+                              push players near a point away from it
+  reload-config               reload the game rules from RULES_FILE
+  stats                       show player counts and metrics
+  restart [<when> [message]]  show or schedule a maintenance restart, in a
+                              delay such as 15m or at an RFC 3339 time
+  restart cancel              call off the scheduled restart
+  shutdown                    kick everyone and stop the server
+  quit                        close this console session
+`
+
+// Console runs operator commands typed at the server: on stdin with
+// CONSOLE=true, and on a telnet session to CONSOLE_PORT, which is only bound
+// on localhost since sessions are not authenticated.
+type Console struct {
+	game     game.GameAdmin
+	rules    *game.Rules
+	shutdown func()
+	started  time.Time
+}
+
+func NewConsole(game game.GameAdmin, rules *game.Rules, shutdown func()) *Console {
+	return &Console{game: game, rules: rules, shutdown: shutdown, started: time.Now()}
+}
+
+// Serve runs commands read from r, one per line, until r ends or the
+// session quits.
+func (c *Console) Serve(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		if !c.Execute(scanner.Text(), w) {
+			return
+		}
+		fmt.Fprint(w, "> ")
+	}
+}
+
+// Listen serves console sessions over telnet on addr.
+func (c *Console) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for console sessions: %w", err)
+	}
+	logrus.Infof("Console listening on: %s", addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept console session: %w", err)
+		}
+		go func() {
+			defer conn.Close()
+			logrus.Infof("Console session opened from %s", conn.RemoteAddr())
+			c.Serve(conn, conn)
+			logrus.Infof("Console session from %s closed", conn.RemoteAddr())
+		}()
+	}
+}
+
+// Execute runs a single command, returning false when the session quits.
+func (c *Console) Execute(line string, w io.Writer) bool {
+	// Telnet clients end lines with \r\n
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	command, args := fields[0], fields[1:]
+
+	switch command {
+	case "help", "?":
+		fmt.Fprint(w, consoleHelp)
+	case "players", "list":
+		c.listPlayers(w)
+	case "kick":
+		c.kick(w, args)
+	case "say":
+		c.say(w, args)
+	case "entities":
+		c.listEntities(w, args)
+	case "spawn":
+		c.spawn(w, args)
+	case "despawn":
+		c.despawn(w, args)
+	case "explode":
+		c.explode(w, args)
+	case "reload-config":
+		rules, err := c.rules.Reload()
+		if err != nil {
+			fmt.Fprintf(w, "Failed to reload game rules: %v\n", err)
+			return true
+		}
+		fmt.Fprintf(w, "Game rules reloaded: %+v\n", rules)
+	case "stats":
+		c.stats(w)
+	case "restart":
+		c.restart(w, args)
+	case "shutdown":
+		fmt.Fprintln(w, "Shutting down")
+		logrus.Warn("Shutdown requested from the console")
+		c.shutdown()
+	case "quit", "exit":
+		return false
+	default:
+		fmt.Fprintf(w, "Unknown command %q, type help for the list\n", command)
+	}
+	return true
+}
+
+func (c *Console) listPlayers(w io.Writer) {
+	players := c.game.OnlinePlayers()
+	sort.Slice(players, func(i, j int) bool { return players[i].Name < players[j].Name })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tHEALTH\tSCORE\tLEVEL\tPOSITION")
+	for _, player := range players {
+		fmt.Fprintf(tw, "%s\t%s\t%.0f\t%d\t%d\t%.1f,%.1f\n", player.ID, player.Name, player.Health, player.Score, player.Level, player.X, player.Y)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d players online\n", len(players))
+}
+
+func (c *Console) kick(w io.Writer, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(w, "Usage: kick <player_id> [reason]")
+		return
+	}
+	playerID, err := uuid.Parse(args[0])
+	if err != nil {
+		fmt.Fprintf(w, "Invalid player ID %q\n", args[0])
+		return
+	}
+	reason := strings.Join(args[1:], " ")
+	if reason == "" {
+		reason = "kicked by an operator"
+	}
+
+	if !c.game.Kick(playerID, game.DisconnectKicked, reason) {
+		fmt.Fprintf(w, "Player %s is not online\n", playerID)
+		return
+	}
+	fmt.Fprintf(w, "Kicked %s\n", playerID)
+}
+
+func (c *Console) say(w io.Writer, args []string) {
+	message := strings.Join(args, " ")
+	if message == "" {
+		fmt.Fprintln(w, "Usage: say <message>")
+		return
+	}
+	announcement := game.NewAnnouncementMessage(message)
+	c.game.BroadcastAll(&announcement)
+	fmt.Fprintf(w, "Announced to %d players\n", c.game.GetClientCount())
+}
+
+func (c *Console) listEntities(w io.Writer, args []string) {
+	var entities []game.Entity
+	for _, player := range c.game.OnlinePlayers() {
+		entities = append(entities, player.Entity())
+	}
+	entities = append(entities, c.game.Entities().All(time.Now())...)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tZONE\tPOSITION\tHEALTH")
+	listed := 0
+	for _, entity := range entities {
+		if len(args) > 0 && entity.Zone != args[0] {
+			continue
+		}
+		position, health := "-", "-"
+		if entity.Position != nil {
+			position = fmt.Sprintf("%.1f,%.1f", entity.Position.X, entity.Position.Y)
+		}
+		if entity.Health != nil {
+			health = fmt.Sprintf("%.0f/%.0f", entity.Health.Current, entity.Health.Max)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entity.ID, entity.Kind, entity.Zone, position, health)
+		listed++
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d entities\n", listed)
+}
+
+func (c *Console) spawn(w io.Writer, args []string) {
+	if len(args) != 4 {
+		fmt.Fprintln(w, "Usage: spawn <kind> <zone> <x> <y>")
+		return
+	}
+	x, errX := strconv.ParseFloat(args[2], 32)
+	y, errY := strconv.ParseFloat(args[3], 32)
+	if errX != nil || errY != nil {
+		fmt.Fprintln(w, "Invalid position")
+		return
+	}
+
+	entity, err := c.game.Entities().Spawn(game.Entity{
+		Kind:     args[0],
+		Zone:     args[1],
+		Position: &game.Position{X: float32(x), Y: float32(y)},
+	})
+	if err != nil {
+		fmt.Fprintf(w, "Failed to spawn: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "Spawned %s %s\n", entity.Kind, entity.ID)
+}
+
+func (c *Console) despawn(w io.Writer, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(w, "Usage: despawn <entity_id>")
+		return
+	}
+	entityID, err := uuid.Parse(args[0])
+	if err != nil {
+		fmt.Fprintf(w, "Invalid entity ID %q\n", args[0])
+		return
+	}
+	if !c.game.Entities().Remove(entityID) {
+		fmt.Fprintf(w, "Entity %s does not exist\n", entityID)
+		return
+	}
+	fmt.Fprintf(w, "Removed %s\n", entityID)
+}
+
+func (c *Console) explode(w io.Writer, args []string) {
+	if len(args) != 5 {
+		fmt.Fprintln(w, "Usage: explode <zone> <x> <y> <radius> <speed>")
+		return
+	}
+	var values [4]float32
+	for i, arg := range args[1:] {
+		value, err := strconv.ParseFloat(arg, 32)
+		if err != nil || (i >= 2 && value < 0) {
+			fmt.Fprintf(w, "Invalid number %q\n", arg)
+			return
+		}
+		values[i] = float32(value)
+	}
+
+	pushed := c.game.Explode(args[0], values[0], values[1], values[2], values[3])
+	fmt.Fprintf(w, "Pushed %d players\n", pushed)
+}
+
+func (c *Console) stats(w io.Writer) {
+	fmt.Fprintf(w, "Uptime: %s\n", time.Since(c.started).Round(time.Second))
+	fmt.Fprintf(w, "Players online: %d\n", c.game.GetClientCount())
+	fmt.Fprintf(w, "Rooms: %d\n", len(c.game.Rooms()))
+
+	snapshot := metrics.Snapshot()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range metrics.Names() {
+		fmt.Fprintf(tw, "  %s\t%g\n", name, snapshot[name])
+	}
+	tw.Flush()
+}
+
+func (c *Console) restart(w io.Writer, args []string) {
+	maintenance := c.game.Maintenance()
+	if len(args) == 0 {
+		info, scheduled := maintenance.Scheduled()
+		if !scheduled {
+			fmt.Fprintln(w, "No restart is scheduled")
+			return
+		}
+		fmt.Fprintf(w, "Restart scheduled at %s, in %s\n", info.RestartAt.Format(time.RFC3339), time.Duration(info.SecondsLeft)*time.Second)
+		return
+	}
+
+	if args[0] == "cancel" {
+		if !maintenance.Cancel() {
+			fmt.Fprintln(w, "No restart is scheduled")
+			return
+		}
+		fmt.Fprintln(w, "Restart cancelled")
+		return
+	}
+
+	restartAt, err := game.ParseRestartTime(args[0], time.Now())
+	if err == nil {
+		err = maintenance.Schedule(restartAt, strings.Join(args[1:], " "))
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Failed to schedule the restart: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "Restart scheduled at %s\n", restartAt.Format(time.RFC3339))
+}
+
+// startConsole starts the console sessions enabled by CONSOLE and
+// CONSOLE_PORT. Shutting down from the console kicks every player of every
+// hosted game and exits.
+func startConsole(config *game.Config, game game.GameAdmin, rules *game.Rules) {
+	if !config.Console && config.ConsolePort == "" {
+		return
+	}
+
+	console := NewConsole(game, rules, func() {
+		shutdownServer("server is shutting down")
+	})
+
+	if config.Console {
+		go func() {
+			console.Serve(os.Stdin, os.Stdout)
+			logrus.Info("Console input closed")
+		}()
+	}
+	if config.ConsolePort != "" {
+		go func() {
+			if err := console.Listen(fmt.Sprintf("127.0.0.1:%s", config.ConsolePort)); err != nil {
+				logrus.Errorf("Console server error: %v", err)
+			}
+		}()
+	}
+}
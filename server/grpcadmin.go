@@ -0,0 +1,248 @@
+package server
+
+//go:generate protoc -I.. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative adminpb/admin.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"online-server-go/adminpb"
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+// AdminRPCServer serves adminpb.AdminService so that other backend services
+// can look up, kick, ban and message players programmatically.
+type AdminRPCServer struct {
+	adminpb.UnimplementedAdminServiceServer
+
+	staff    *game.StaffAuthorizer
+	database store.Store
+	game     game.GameAdmin
+}
+
+func NewAdminRPCServer(staff *game.StaffAuthorizer, database store.Store, game game.GameAdmin) *AdminRPCServer {
+	return &AdminRPCServer{staff: staff, database: database, game: game}
+}
+
+// rpcPermissions is what each admin RPC requires of the caller's role.
+var rpcPermissions = map[string]game.Permission{
+	adminpb.AdminService_GetPlayer_FullMethodName:   game.PermViewServer,
+	adminpb.AdminService_KickPlayer_FullMethodName:  game.PermKick,
+	adminpb.AdminService_BanPlayer_FullMethodName:   game.PermBan,
+	adminpb.AdminService_UnbanPlayer_FullMethodName: game.PermBan,
+	adminpb.AdminService_Broadcast_FullMethodName:   game.PermBroadcast,
+	adminpb.AdminService_GetMetrics_FullMethodName:  game.PermViewServer,
+}
+
+// Serve listens on every address of addrs and blocks serving the admin RPC
+// API, over TLS if tlsConfig is not nil, until one of them fails.
+func (s *AdminRPCServer) Serve(addrs []string, tlsConfig *tls.Config) error {
+	listeners, err := transport.ListenTCP(addrs)
+	if err != nil {
+		return fmt.Errorf("failed to listen for admin gRPC: %w", err)
+	}
+
+	options := []grpc.ServerOption{grpc.UnaryInterceptor(s.authorize)}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(options...)
+	adminpb.RegisterAdminServiceServer(server, s)
+
+	logrus.Infof("Admin gRPC listening on: %s", strings.Join(addrs, ", "))
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			errs <- server.Serve(listener)
+		}(listener)
+	}
+	return <-errs
+}
+
+// authorize accepts "authorization: Bearer <token>" or "x-admin-token: <token>"
+// metadata holding the method's permission, matching the admin HTTP API.
+func (s *AdminRPCServer) authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-admin-token"); len(values) > 0 {
+			token = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 && strings.HasPrefix(values[0], "Bearer ") {
+			token = strings.TrimPrefix(values[0], "Bearer ")
+		}
+	}
+
+	perm, known := rpcPermissions[info.FullMethod]
+	if !known {
+		perm = game.PermManageRoles // methods added without a permission are for owners only
+	}
+	staff, err := s.staff.Authorize(ctx, token, perm)
+	switch {
+	case errors.Is(err, game.ErrStaffUnauthenticated):
+		metrics.Inc("admin_unauthorized")
+		return nil, status.Error(codes.Unauthenticated, "invalid admin token")
+	case errors.Is(err, game.ErrStaffForbidden):
+		metrics.Inc("admin_forbidden")
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		logrus.Errorf("Failed to authorize admin RPC: %v", err)
+		return nil, status.Error(codes.Internal, "failed to authorize")
+	}
+	if staff.Name == "" {
+		staff.Name = game.RPCActor(ctx)
+	}
+	metrics.Inc("admin_rpc_calls")
+	return handler(game.WithStaff(ctx, staff), req)
+}
+
+func parsePlayerID(id string) (uuid.UUID, error) {
+	playerID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "player_id must be a valid UUID")
+	}
+	return playerID, nil
+}
+
+func (s *AdminRPCServer) GetPlayer(ctx context.Context, req *adminpb.GetPlayerRequest) (*adminpb.GetPlayerResponse, error) {
+	playerID, err := parsePlayerID(req.PlayerId)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.database.GetPlayer(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load player %s for admin RPC: %v", playerID, err)
+		return nil, status.Error(codes.Internal, "failed to load player")
+	}
+	live, online := s.game.OnlinePlayer(playerID)
+	if stored == nil && !online {
+		return nil, status.Error(codes.NotFound, "player not found")
+	}
+
+	player := &adminpb.Player{Id: playerID.String(), Online: online}
+	if stored != nil {
+		xp, level, err := s.database.GetPlayerProgress(ctx, playerID)
+		if err != nil {
+			logrus.Errorf("Failed to load progress of %s for admin RPC: %v", playerID, err)
+		}
+		player.Name = stored.Name
+		player.X = float32(stored.X)
+		player.Y = float32(stored.Y)
+		player.Health = float32(stored.Health)
+		player.Score = stored.Score
+		player.Rating = stored.Rating
+		player.Xp = xp
+		player.Level = int32(level)
+		player.LastSeenUnix = stored.LastSeenAt.Unix()
+	}
+	if online {
+		player.Name = live.Name
+		player.X = live.X
+		player.Y = live.Y
+		player.Health = live.Health
+		player.Score = int64(live.Score)
+		player.Xp = live.XP
+		player.Level = int32(live.Level)
+		player.LastSeenUnix = time.Now().Unix()
+	}
+
+	return &adminpb.GetPlayerResponse{Player: player}, nil
+}
+
+func (s *AdminRPCServer) KickPlayer(ctx context.Context, req *adminpb.KickPlayerRequest) (*adminpb.KickPlayerResponse, error) {
+	playerID, err := parsePlayerID(req.PlayerId)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "Kicked by an operator"
+	}
+	kicked := s.game.Kick(playerID, game.DisconnectKicked, reason)
+	if kicked {
+		game.RecordAdminAction(ctx, s.database, game.StaffFrom(ctx).Name, game.AuditKick, playerID.String(), reason)
+	}
+	return &adminpb.KickPlayerResponse{Kicked: kicked}, nil
+}
+
+func (s *AdminRPCServer) BanPlayer(ctx context.Context, req *adminpb.BanPlayerRequest) (*adminpb.BanPlayerResponse, error) {
+	playerID, err := parsePlayerID(req.PlayerId)
+	if err != nil {
+		return nil, err
+	}
+	if req.DurationSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration_seconds must not be negative")
+	}
+
+	ban := &store.Ban{PlayerID: playerID.String(), Reason: req.Reason, CreatedAt: time.Now()}
+	response := &adminpb.BanPlayerResponse{}
+	if req.DurationSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+		ban.ExpiresAt = &expiresAt
+		response.ExpiresUnix = expiresAt.Unix()
+	}
+
+	if err := s.database.BanPlayer(ctx, playerID, ban.Reason, ban.ExpiresAt); err != nil {
+		logrus.Errorf("Failed to ban %s: %v", playerID, err)
+		return nil, status.Error(codes.Internal, "failed to ban player")
+	}
+	game.RecordAdminAction(ctx, s.database, game.StaffFrom(ctx).Name, game.AuditBan, playerID.String(), ban.Reason)
+
+	response.Kicked = s.game.Kick(playerID, game.DisconnectBanned, ban.Message())
+	return response, nil
+}
+
+func (s *AdminRPCServer) UnbanPlayer(ctx context.Context, req *adminpb.UnbanPlayerRequest) (*adminpb.UnbanPlayerResponse, error) {
+	playerID, err := parsePlayerID(req.PlayerId)
+	if err != nil {
+		return nil, err
+	}
+
+	unbanned, err := s.database.UnbanPlayer(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to unban %s: %v", playerID, err)
+		return nil, status.Error(codes.Internal, "failed to unban player")
+	}
+	if unbanned {
+		logrus.Infof("Unbanned player %s", playerID)
+		game.RecordAdminAction(ctx, s.database, game.StaffFrom(ctx).Name, game.AuditUnban, playerID.String(), "")
+	}
+	return &adminpb.UnbanPlayerResponse{Unbanned: unbanned}, nil
+}
+
+func (s *AdminRPCServer) Broadcast(ctx context.Context, req *adminpb.BroadcastRequest) (*adminpb.BroadcastResponse, error) {
+	if strings.TrimSpace(req.Message) == "" {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+
+	recipients := s.game.GetClientCount()
+	announcement := game.NewAnnouncementMessage(req.Message)
+	s.game.BroadcastAll(&announcement)
+	game.RecordAdminAction(ctx, s.database, game.StaffFrom(ctx).Name, game.AuditBroadcast, "", req.Message)
+
+	logrus.Infof("Broadcast announcement to %d players", recipients)
+	return &adminpb.BroadcastResponse{Recipients: int32(recipients)}, nil
+}
+
+func (s *AdminRPCServer) GetMetrics(ctx context.Context, req *adminpb.GetMetricsRequest) (*adminpb.GetMetricsResponse, error) {
+	return &adminpb.GetMetricsResponse{
+		Metrics:       metrics.Snapshot(),
+		PlayersOnline: int32(s.game.GetClientCount()),
+	}, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"online-server-go/game"
+	"online-server-go/transport"
+)
+
+// Harness is a game served in process for integration scenarios, on a free
+// port of localhost with an in-memory SQLite database, so that scripted
+// clients can drive it through the same startup path as Run. It exits the
+// process like Run if the game cannot be hosted. TestIntegration runs the
+// scenarios.
+type Harness struct {
+	Protocol string
+	Addr     string   // IPv4 host:port clients connect to
+	Addrs    []string // every host:port served, IPv6 loopback too where available
+
+	close func() error
+}
+
+// StartHarness serves a game over protocol, "websocket", "udp" or "tcp",
+// with config otherwise as given. The port, database and side APIs of
+// config are replaced.
+func StartHarness(protocol string, config *game.Config) (*Harness, error) {
+	port, err := freePort(protocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	tenant, err := openInProcess(protocol, port, config)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := transport.BindAddrs(config, port)
+	harness := &Harness{Protocol: protocol, Addr: addrs[0], Addrs: addrs}
+	switch protocol {
+	case "udp":
+		server := tenant.startUDP(nil)
+		go server.Run()
+		harness.close = server.Close
+	case "tcp":
+		server := tenant.startTCP(nil)
+		go server.Run()
+		harness.close = server.Close
+	default:
+		listeners, err := transport.ListenTCP(addrs)
+		if err != nil {
+			return nil, err
+		}
+		server := transport.NewHTTPServer(harness.Addr, tenant.startWebSocket(), config, nil)
+		for _, listener := range listeners {
+			go server.Serve(listener)
+		}
+		harness.close = server.Close
+	}
+	return harness, nil
+}
+
+// Close stops serving clients. The game's background tasks run on until the
+// process exits.
+func (h *Harness) Close() error {
+	return h.close()
+}
+
+// openInProcess opens a game of protocol on port of the loopback addresses
+// with an in-memory SQLite database and without side APIs, for the harness
+// and the fuzz tests.
+func openInProcess(protocol, port string, config *game.Config) (*Tenant, error) {
+	config.Protocol = protocol
+	config.Port = port
+	config.BindHosts = loopbackHosts(protocol, port)
+	config.DatabaseURL = "sqlite::memory:"
+	config.TenantsFile = ""
+	config.GRPCPort = ""
+	config.APIPort = ""
+	config.Console = false
+	config.ConsolePort = ""
+
+	report := RunStartupChecks(config)
+	if report.Failed() {
+		report.Log()
+		return nil, errors.New("startup checks failed, see the log")
+	}
+	return openTenant("", "", config, report), nil
+}
+
+// loopbackHosts returns the loopback addresses protocol can listen on port
+// at, IPv4 first, and IPv6 unless the host has none or the port is taken.
+func loopbackHosts(protocol, port string) string {
+	addr := net.JoinHostPort("::1", port)
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp6", addr)
+		if err != nil {
+			return "127.0.0.1"
+		}
+		conn.Close()
+	}
+	listener, err := net.Listen("tcp6", addr)
+	if err != nil {
+		return "127.0.0.1"
+	}
+	listener.Close()
+	return "127.0.0.1,::1"
+}
+
+// freePort returns a port of localhost that protocol can listen on.
+func freePort(protocol string) (string, error) {
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		return strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port), nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,453 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"online-server-go/game"
+	"online-server-go/server"
+)
+
+type gameMessage struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type udpPacket struct {
+	Sequence  uint32      `json:"sequence"`
+	Timestamp int64       `json:"timestamp"`
+	Message   gameMessage `json:"message"`
+	Reliable  bool        `json:"reliable"`
+}
+
+const (
+	// udpClientTimeout is how soon the harness's UDP server removes a
+	// silent client, so that the disconnect scenario does not wait the
+	// default.
+	udpClientTimeout = 2 * time.Second
+	// stepTimeout bounds how long a scenario waits for each message.
+	stepTimeout = 5 * time.Second
+)
+
+// scenario drives a joined pair of clients and checks what they receive.
+type scenario struct {
+	name string
+	run  func(h *harness, a, b *client) error
+}
+
+var scenarios = []scenario{
+	{"join", scenarioJoin},
+	{"move", scenarioMove},
+	{"chat", scenarioChat},
+	{"room", scenarioRoom},
+	{"disconnect", scenarioDisconnect},
+}
+
+// TestIntegration serves the game in process on free ports of localhost
+// with an in-memory SQLite database, drives pairs of scripted clients
+// through join, move, chat, room and disconnect scenarios over WebSocket and
+// UDP, and fails if a client does not receive the broadcasts a scenario
+// expects. The second client of a pair joins over IPv6 where the host has a
+// loopback address for it, so that every scenario has an IPv4 and an IPv6
+// client. The server's other settings are taken from the environment:
+//
+//	go test ./server -run TestIntegration
+//	go test ./server -run 'TestIntegration/udp/chat' -v
+func TestIntegration(t *testing.T) {
+	for _, protocol := range []string{"websocket", "udp"} {
+		t.Run(protocol, func(t *testing.T) {
+			h, err := startHarness(protocol, stepTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.server.Close()
+			for _, s := range scenarios {
+				t.Run(s.name, func(t *testing.T) {
+					if err := h.run(s); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// harness is a game served in process for one protocol.
+type harness struct {
+	protocol string
+	server   *server.Harness
+	timeout  time.Duration
+}
+
+func startHarness(protocol string, timeout time.Duration) (*harness, error) {
+	if protocol != "websocket" && protocol != "udp" {
+		return nil, fmt.Errorf("unknown protocol %q, expected websocket or udp", protocol)
+	}
+	config := game.LoadConfig()
+	config.UDPClientTimeout = udpClientTimeout
+	config.UDPKeepaliveInterval = udpClientTimeout / 4
+	s, err := server.StartHarness(protocol, config)
+	if err != nil {
+		return nil, err
+	}
+	return &harness{protocol: protocol, server: s, timeout: timeout}, nil
+}
+
+// run joins a fresh pair of clients, a first over IPv4 and b over the last
+// address served, and runs a scenario with them.
+func (h *harness) run(s scenario) error {
+	a, err := h.connect(h.server.Addr)
+	if err != nil {
+		return fmt.Errorf("join a: %w", err)
+	}
+	defer a.Close()
+	b, err := h.connect(h.server.Addrs[len(h.server.Addrs)-1])
+	if err != nil {
+		return fmt.Errorf("join b: %w", err)
+	}
+	defer b.Close()
+
+	if _, err := a.Expect(h.timeout, "PlayerJoin of b", func(m gameMessage) bool {
+		return m.Type == "PlayerJoin" && m.Data["player_id"] == b.playerID.String()
+	}); err != nil {
+		return err
+	}
+	return s.run(h, a, b)
+}
+
+func (h *harness) connect(addr string) (*client, error) {
+	if h.protocol == "udp" {
+		return connectUDP(addr, h.timeout)
+	}
+	return connectWebSocket(addr, h.timeout)
+}
+
+// scenarioJoin checks that b is shown a snapshot with a in it.
+func scenarioJoin(h *harness, a, b *client) error {
+	_, err := b.Expect(h.timeout, "GameState with a", func(m gameMessage) bool {
+		return m.Type == "GameState" && playerAt(m, a.playerID, nil)
+	})
+	return err
+}
+
+// scenarioMove checks that b sees a move, in a PlayerMove or a snapshot.
+func scenarioMove(h *harness, a, b *client) error {
+	if err := a.Send("PlayerMove", map[string]interface{}{"player_id": a.playerID.String(), "x": 12.5, "y": -3.0}); err != nil {
+		return err
+	}
+	_, err := b.Expect(h.timeout, "a at (12.5, -3)", func(m gameMessage) bool {
+		at := []float64{12.5, -3}
+		switch m.Type {
+		case "PlayerMove":
+			return m.Data["player_id"] == a.playerID.String() && m.Data["x"] == at[0] && m.Data["y"] == at[1]
+		case "GameState":
+			return playerAt(m, a.playerID, at)
+		}
+		return false
+	})
+	return err
+}
+
+// scenarioChat checks that b receives a's chat.
+func scenarioChat(h *harness, a, b *client) error {
+	text := "integration " + uuid.NewString()[:8]
+	if err := a.Send("Chat", map[string]interface{}{"player_id": a.playerID.String(), "message": text}); err != nil {
+		return err
+	}
+	_, err := b.Expect(h.timeout, "Chat from a", func(m gameMessage) bool {
+		return m.Type == "Chat" && m.Data["player_id"] == a.playerID.String() && m.Data["message"] == text
+	})
+	return err
+}
+
+// scenarioRoom checks that only the owner of a room may pause and resume
+// it, and is told when it does.
+func scenarioRoom(h *harness, a, b *client) error {
+	if err := a.Send("CreateRoom", map[string]interface{}{"tick_divisor": 2}); err != nil {
+		return err
+	}
+	if _, err := a.Expect(h.timeout, "RoomCreated", func(m gameMessage) bool { return m.Type == "RoomCreated" }); err != nil {
+		return err
+	}
+
+	if err := b.Send("PauseRoom", nil); err != nil {
+		return err
+	}
+	// The refusal is an Error, which ends the wait
+	if refused, _ := b.Expect(h.timeout, "refusal", func(gameMessage) bool { return false }); refused.Type != "Error" {
+		return errors.New("b was not refused pausing a room it does not own")
+	}
+
+	for _, step := range []struct{ send, expect string }{{"PauseRoom", "RoomPaused"}, {"ResumeRoom", "RoomResumed"}} {
+		if err := a.Send(step.send, nil); err != nil {
+			return err
+		}
+		if _, err := a.Expect(h.timeout, step.expect, func(m gameMessage) bool { return m.Type == step.expect }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scenarioDisconnect checks that a is told when b goes away. A UDP client
+// just goes silent, so its leave waits for the server to time it out.
+func scenarioDisconnect(h *harness, a, b *client) error {
+	b.Close()
+	timeout := h.timeout
+	if h.protocol == "udp" {
+		timeout += udpClientTimeout
+	}
+	_, err := a.Expect(timeout, "PlayerLeave of b", func(m gameMessage) bool {
+		return m.Type == "PlayerLeave" && m.Data["player_id"] == b.playerID.String()
+	})
+	return err
+}
+
+// playerAt reports whether a GameState lists the player, at the position
+// if one is given.
+func playerAt(m gameMessage, playerID uuid.UUID, at []float64) bool {
+	players, _ := m.Data["players"].([]interface{})
+	for _, p := range players {
+		player, _ := p.(map[string]interface{})
+		if player["id"] != playerID.String() {
+			continue
+		}
+		return at == nil || (player["x"] == at[0] && player["y"] == at[1])
+	}
+	return false
+}
+
+// client is a scripted player. Everything the server sends it is kept in
+// order until a scenario expects it.
+type client struct {
+	playerID uuid.UUID
+	send     func(msg gameMessage) error
+	close    func()
+
+	mu        sync.Mutex
+	inbox     []gameMessage
+	readErr   error
+	arrived   chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient() *client {
+	return &client{arrived: make(chan struct{}, 1)}
+}
+
+func (c *client) Send(messageType string, data map[string]interface{}) error {
+	return c.send(gameMessage{Type: messageType, Data: data})
+}
+
+// Expect waits for a message matching match, skipping those before it. An
+// Error message fails the wait.
+func (c *client) Expect(timeout time.Duration, what string, match func(gameMessage) bool) (gameMessage, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		for len(c.inbox) > 0 {
+			m := c.inbox[0]
+			c.inbox = c.inbox[1:]
+			if m.Type == "Error" {
+				c.mu.Unlock()
+				return m, fmt.Errorf("waiting for %s: server error: %v", what, m.Data["message"])
+			}
+			if match(m) {
+				c.mu.Unlock()
+				return m, nil
+			}
+		}
+		readErr := c.readErr
+		c.mu.Unlock()
+		if readErr != nil {
+			return gameMessage{}, fmt.Errorf("waiting for %s: %w", what, readErr)
+		}
+
+		select {
+		case <-c.arrived:
+		case <-deadline.C:
+			return gameMessage{}, fmt.Errorf("no %s within %s", what, timeout)
+		}
+	}
+}
+
+func (c *client) Close() {
+	c.closeOnce.Do(c.close)
+}
+
+// deliver keeps a received message for Expect.
+func (c *client) deliver(m gameMessage) {
+	c.mu.Lock()
+	c.inbox = append(c.inbox, m)
+	c.mu.Unlock()
+	select {
+	case c.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// fail records why the client stopped receiving.
+func (c *client) fail(err error) {
+	c.mu.Lock()
+	c.readErr = err
+	c.mu.Unlock()
+	select {
+	case c.arrived <- struct{}{}:
+	default:
+	}
+}
+
+func connectWebSocket(addr string, timeout time.Duration) (*client, error) {
+	u := url.URL{Scheme: "ws", Host: addr, Path: "/"}
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient()
+	var writeMu sync.Mutex
+	c.send = func(msg gameMessage) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(msg)
+	}
+	c.close = func() { conn.Close() }
+	go func() {
+		for {
+			var msg gameMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				c.fail(err)
+				return
+			}
+			c.deliver(msg)
+		}
+	}()
+
+	// The server assigns the player ID and announces it in the first PlayerJoin
+	join, err := c.Expect(timeout, "own PlayerJoin", func(m gameMessage) bool { return m.Type == "PlayerJoin" })
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	playerID, _ := join.Data["player_id"].(string)
+	if c.playerID, err = uuid.Parse(playerID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("invalid player_id in PlayerJoin: %q", playerID)
+	}
+	return c, nil
+}
+
+func connectUDP(addr string, timeout time.Duration) (*client, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient()
+	c.playerID = uuid.New()
+	var writeMu sync.Mutex
+	var sequence uint32
+	write := func(packet udpPacket) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if packet.Reliable {
+			sequence++
+			packet.Sequence = sequence
+		}
+		if packet.Message.Type == "Heartbeat" {
+			// Acked by the sequence in its data
+			packet.Message.Data["sequence"] = packet.Sequence
+		}
+		packet.Timestamp = time.Now().UnixMilli()
+		data, err := json.Marshal(packet)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(data)
+		return err
+	}
+	c.send = func(msg gameMessage) error {
+		return write(udpPacket{Message: msg, Reliable: true})
+	}
+	heartbeat := func() error {
+		return c.Send("Heartbeat", map[string]interface{}{"player_id": c.playerID.String()})
+	}
+
+	// Heartbeats keep the client from timing out until it is closed
+	registered := make(chan struct{})
+	var registerOnce sync.Once
+	stop := make(chan struct{})
+	c.close = func() {
+		close(stop)
+		conn.Close()
+	}
+	go func() {
+		ticker := time.NewTicker(udpClientTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				heartbeat()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, err := conn.Read(buf)
+			if errors.Is(err, net.ErrClosed) {
+				c.fail(err)
+				return
+			}
+			if err != nil {
+				continue // ICMP errors while the server is not yet reading
+			}
+			var packet udpPacket
+			if err := json.Unmarshal(buf[:n], &packet); err != nil {
+				continue
+			}
+			if packet.Message.Type == "Ack" {
+				if sequence, _ := packet.Message.Data["sequence"].(float64); sequence == 1 {
+					registerOnce.Do(func() { close(registered) })
+				}
+				continue
+			}
+			// Acknowledged so that the server does not keep resending it
+			if packet.Reliable {
+				write(udpPacket{Message: gameMessage{Type: "Ack", Data: map[string]interface{}{"sequence": packet.Sequence}}})
+			}
+			c.deliver(packet.Message)
+		}
+	}()
+
+	// The first heartbeat registers the client. Its Ack comes after the
+	// messages sent to a new client, which stay in the inbox.
+	if err := heartbeat(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	select {
+	case <-registered:
+		return c, nil
+	case <-time.After(timeout):
+		c.Close()
+		return nil, fmt.Errorf("no Ack of the first Heartbeat within %s", timeout)
+	}
+}
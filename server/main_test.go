@@ -0,0 +1,21 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TestMain runs the tests from the repository root, where the migrations
+// the in-memory databases are created with are.
+func TestMain(m *testing.M) {
+	_, file, _, _ := runtime.Caller(0)
+	if err := os.Chdir(filepath.Join(filepath.Dir(file), "..")); err != nil {
+		panic(err)
+	}
+	logrus.SetLevel(logrus.ErrorLevel)
+	os.Exit(m.Run())
+}
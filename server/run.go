@@ -0,0 +1,169 @@
+// Package server runs the game server: the games of the process and its
+// tenants over the transport PROTOCOL selects, the admin gRPC API, the
+// operator console and the startup checks. cmd/gameserver runs it from
+// environment variables with Run; other programs can embed a game with
+// ws.NewGameServer.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+	"online-server-go/transport/udp"
+	"online-server-go/transport/ws"
+)
+
+// Run serves the games of config and its tenants until a listener fails,
+// exiting the process on any error like cmd/gameserver does. Embedders that
+// want to mount the game in their own HTTP service use ws.NewGameServer, an
+// http.Handler, instead.
+func Run(config *game.Config) {
+	port := config.Port
+	protocol := config.Protocol
+
+	game.SetCrashDumpDir(config.CrashDumpDir)
+
+	// Validate configuration and environment before touching the database
+	report := RunStartupChecks(config)
+	if report.Failed() {
+		report.Log()
+		logrus.Fatal("Refusing to start; fix the failed checks above")
+	}
+
+	tlsConfig, err := transport.NewTLSConfig(config)
+	if err != nil {
+		logrus.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	// The standalone router holds no game state and needs no database
+	if protocol == "router" {
+		addrs := transport.BindAddrs(config, port)
+		mux := http.NewServeMux()
+		// ALLOWED_ORIGINS was validated by the startup checks
+		origins, _ := transport.ParseOriginPolicy(config.AllowedOrigins)
+		transport.NewRouterServer(transport.NewRouter(config), origins).Register(mux)
+
+		report.Log()
+		logrus.Infof("Router listening on: %s", strings.Join(addrs, ", "))
+		if err := transport.ListenAndServe(transport.NewHTTPServer(addrs[0], mux, config, tlsConfig), addrs); err != nil {
+			logrus.Fatalf("Router server error: %v", err)
+		}
+		return
+	}
+
+	primary := openTenant("", "", config, report)
+	tenantConfigs, _ := LoadTenantConfigs(config)
+	for _, tenantConfig := range tenantConfigs {
+		config := tenantConfig.Config(config)
+		report := RunStartupChecks(config)
+		report.Tenant = tenantConfig.Name
+		if report.Failed() {
+			report.Log()
+			logrus.Fatalf("Refusing to start; fix the failed checks of tenant %s above", tenantConfig.Name)
+		}
+		openTenant(tenantConfig.Name, tenantConfig.Path, config, report)
+	}
+	defer func() {
+		for _, tenant := range hostedTenants {
+			tenant.close()
+		}
+	}()
+
+	game.ActiveChaos = game.NewChaos(config)
+	game.Shutdown = shutdownServer
+
+	shutdownTracing, err := game.InitTracing(config)
+	if err != nil {
+		logrus.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	switch protocol {
+	case "udp":
+		servers := make([]*udp.UDPGameServer, len(hostedTenants))
+		for i, tenant := range hostedTenants {
+			servers[i] = tenant.startUDP(tlsConfig)
+		}
+
+		serveAdminRPC(config, tlsConfig, primary.database, primary.accounts, primary.game)
+		startConsole(config, primary.game, primary.rules)
+
+		for i, server := range servers[1:] {
+			server, tenant := server, hostedTenants[i+1]
+			go func() {
+				if err := server.Run(); err != nil {
+					logrus.Fatalf("UDP server error of %s: %v", tenant.describe(), err)
+				}
+			}()
+		}
+		if err := servers[0].Run(); err != nil {
+			logrus.Fatalf("UDP server error: %v", err)
+		}
+
+	case "tcp":
+		servers := make([]*ws.TCPGameServer, len(hostedTenants))
+		for i, tenant := range hostedTenants {
+			servers[i] = tenant.startTCP(tlsConfig)
+		}
+
+		serveAdminRPC(config, tlsConfig, primary.database, primary.accounts, primary.game)
+		startConsole(config, primary.game, primary.rules)
+
+		for i, server := range servers[1:] {
+			server, tenant := server, hostedTenants[i+1]
+			go func() {
+				if err := server.Run(); err != nil {
+					logrus.Fatalf("TCP server error of %s: %v", tenant.describe(), err)
+				}
+			}()
+		}
+		if err := servers[0].Run(); err != nil {
+			logrus.Fatalf("TCP server error: %v", err)
+		}
+
+	default:
+		addrs := transport.BindAddrs(config, port)
+		mux := http.NewServeMux()
+		mux.Handle("/", primary.startWebSocket())
+		for _, tenant := range hostedTenants[1:] {
+			mux.Handle(tenant.Path+"/", http.StripPrefix(tenant.Path, tenant.startWebSocket()))
+			logrus.Infof("Serving %s under %s", tenant.describe(), tenant.Path)
+		}
+
+		serveAdminRPC(config, tlsConfig, primary.database, primary.accounts, primary.game)
+		startConsole(config, primary.game, primary.rules)
+
+		logrus.Infof("WebSocket server listening on: %s", strings.Join(addrs, ", "))
+		if err := transport.ListenAndServe(transport.NewHTTPServer(addrs[0], mux, config, tlsConfig), addrs); err != nil {
+			logrus.Fatalf("WebSocket server error: %v", err)
+		}
+	}
+}
+
+// serveAdminRPC starts the admin gRPC API in the background when GRPC_PORT is
+// set, on every BIND_ADDRESSES entry and over TLS when it is configured.
+// Like the admin HTTP API it accepts ADMIN_TOKEN and staff accounts.
+func serveAdminRPC(config *game.Config, tlsConfig *tls.Config, database store.Store, accounts *game.Accounts, gameAdmin game.GameAdmin) {
+	if config.GRPCPort == "" {
+		return
+	}
+	staff := game.NewStaffAuthorizer(config.AdminToken, accounts)
+	if !staff.Enabled() {
+		logrus.Warn("ADMIN_TOKEN is not set; admin gRPC API is disabled")
+		return
+	}
+
+	server := NewAdminRPCServer(staff, database, gameAdmin)
+	go func() {
+		if err := server.Serve(transport.BindAddrs(config, config.GRPCPort), tlsConfig); err != nil {
+			logrus.Errorf("Admin gRPC server error: %v", err)
+		}
+	}()
+}
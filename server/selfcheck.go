@@ -0,0 +1,592 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+	"online-server-go/transport/udp"
+)
+
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// StartupReport collects the results of the boot-time self-check so that
+// misconfiguration is reported before the first client connects.
+type StartupReport struct {
+	Tenant  string        `json:"tenant,omitempty"` // empty for the primary game
+	Results []CheckResult `json:"results"`
+}
+
+func (r *StartupReport) add(name, status, detail string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: status, Detail: detail})
+}
+
+func (r *StartupReport) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == checkFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Log prints one line per check followed by an overall verdict.
+func (r *StartupReport) Log() {
+	if r.Tenant != "" {
+		logrus.Infof("Startup self-check report of tenant %s:", r.Tenant)
+	} else {
+		logrus.Info("Startup self-check report:")
+	}
+	for _, result := range r.Results {
+		line := fmt.Sprintf("  [%-4s] %-12s %s", strings.ToUpper(result.Status), result.Name, result.Detail)
+		switch result.Status {
+		case checkFail:
+			logrus.Error(line)
+		case checkWarn:
+			logrus.Warn(line)
+		default:
+			logrus.Info(line)
+		}
+	}
+
+	if r.Failed() {
+		logrus.Error("Startup self-check failed")
+	} else {
+		logrus.Info("Startup self-check passed")
+	}
+}
+
+// RunStartupChecks validates configuration and the host environment. It runs
+// before the database is opened; CheckDatabase completes the report afterwards.
+func RunStartupChecks(config *game.Config) *StartupReport {
+	report := &StartupReport{}
+	report.checkConfig(config)
+	report.checkMigrations()
+	report.checkPort(config)
+	report.checkTLS(config)
+	report.checkOrigins(config)
+	report.checkChaos(config)
+	report.checkTracing(config)
+	report.checkTenants(config)
+	report.checkPlugins(config)
+	return report
+}
+
+func (r *StartupReport) checkConfig(config *game.Config) {
+	port, err := strconv.Atoi(config.Port)
+	if err != nil || port < 1 || port > 65535 {
+		r.add("config", checkFail, fmt.Sprintf("PORT must be a number between 1 and 65535, got %q", config.Port))
+		return
+	}
+
+	if config.GRPCPort != "" {
+		grpcPort, err := strconv.Atoi(config.GRPCPort)
+		if err != nil || grpcPort < 1 || grpcPort > 65535 || grpcPort == port {
+			r.add("config", checkFail, fmt.Sprintf("GRPC_PORT must be a port number other than PORT, got %q", config.GRPCPort))
+			return
+		}
+	}
+
+	if config.APIPort != "" {
+		apiPort, err := strconv.Atoi(config.APIPort)
+		if err != nil || apiPort < 1 || apiPort > 65535 || apiPort == port {
+			r.add("config", checkFail, fmt.Sprintf("API_PORT must be a port number other than PORT, got %q", config.APIPort))
+			return
+		}
+	}
+
+	if config.ConsolePort != "" {
+		consolePort, err := strconv.Atoi(config.ConsolePort)
+		if err != nil || consolePort < 1 || consolePort > 65535 || consolePort == port {
+			r.add("config", checkFail, fmt.Sprintf("CONSOLE_PORT must be a port number other than PORT, got %q", config.ConsolePort))
+			return
+		}
+	}
+
+	switch config.Protocol {
+	case "websocket", "udp", "tcp":
+	case "router":
+		if len(transport.ParseBackends(config.ShardBackends)) == 0 {
+			r.add("config", checkFail, "SHARD_BACKENDS is required when PROTOCOL=router")
+			return
+		}
+	default:
+		r.add("config", checkFail, fmt.Sprintf("PROTOCOL must be \"websocket\", \"udp\", \"tcp\" or \"router\", got %q", config.Protocol))
+		return
+	}
+
+	if config.DatabaseURL == "" {
+		r.add("config", checkFail, "DATABASE_URL must not be empty")
+		return
+	}
+
+	if config.MatchDuration <= 0 {
+		r.add("config", checkFail, fmt.Sprintf("MATCH_DURATION must be positive, got %s", config.MatchDuration))
+		return
+	}
+
+	if config.MatchRoomSize <= 0 {
+		r.add("config", checkFail, fmt.Sprintf("MATCH_ROOM_SIZE must be positive, got %d", config.MatchRoomSize))
+		return
+	}
+
+	if err := game.ParseGameMode(config.GameMode); err != nil {
+		r.add("config", checkFail, "GAME_MODE: "+err.Error())
+		return
+	}
+
+	if config.ScoreboardInterval < 0 {
+		r.add("config", checkFail, fmt.Sprintf("SCOREBOARD_INTERVAL must not be negative, got %s", config.ScoreboardInterval))
+		return
+	}
+
+	if config.AFKTimeout < 0 || config.AFKWarnAfter < 0 {
+		r.add("config", checkFail, fmt.Sprintf("AFK_WARN_AFTER and AFK_TIMEOUT must not be negative, got %s and %s", config.AFKWarnAfter, config.AFKTimeout))
+		return
+	}
+
+	if config.AFKTimeout > 0 && config.AFKWarnAfter >= config.AFKTimeout {
+		r.add("config", checkFail, fmt.Sprintf("AFK_WARN_AFTER must be shorter than AFK_TIMEOUT, got %s and %s", config.AFKWarnAfter, config.AFKTimeout))
+		return
+	}
+
+	if config.WorldEventInterval < 0 {
+		r.add("config", checkFail, fmt.Sprintf("WORLD_EVENT_INTERVAL must not be negative, got %s", config.WorldEventInterval))
+		return
+	}
+
+	if config.WorldSnapshotInterval < 0 {
+		r.add("config", checkFail, fmt.Sprintf("WORLD_SNAPSHOT_INTERVAL must not be negative, got %s", config.WorldSnapshotInterval))
+		return
+	}
+
+	if config.DBWriteBudget < 0 {
+		r.add("config", checkFail, fmt.Sprintf("DB_WRITE_BUDGET must not be negative, got %d", config.DBWriteBudget))
+		return
+	}
+
+	if config.DBQueryTimeout < 0 {
+		r.add("config", checkFail, fmt.Sprintf("DB_QUERY_TIMEOUT must not be negative, got %s", config.DBQueryTimeout))
+		return
+	}
+
+	if config.SnapshotRate < 1 {
+		r.add("config", checkFail, fmt.Sprintf("SNAPSHOT_RATE must be positive, got %d", config.SnapshotRate))
+		return
+	}
+
+	if config.WorldWidth < 0 || config.WorldHeight < 0 {
+		r.add("config", checkFail, fmt.Sprintf("WORLD_WIDTH and WORLD_HEIGHT must not be negative, got %g and %g", config.WorldWidth, config.WorldHeight))
+		return
+	}
+
+	if _, err := game.ParseWorldEdge(config.WorldEdge); err != nil {
+		r.add("config", checkFail, "WORLD_EDGE: "+err.Error())
+		return
+	}
+
+	if config.VisibilityRange < 0 {
+		r.add("config", checkFail, fmt.Sprintf("VISIBILITY_RANGE must not be negative, got %g", config.VisibilityRange))
+		return
+	}
+
+	if config.AntiCheatFlagScore < 0 || config.AntiCheatActionScore < 0 || config.AntiCheatMaxActionRate < 0 {
+		r.add("config", checkFail, fmt.Sprintf("ANTICHEAT_FLAG_SCORE, ANTICHEAT_ACTION_SCORE and ANTICHEAT_MAX_ACTIONS_PER_SECOND must not be negative, got %g, %g and %d", config.AntiCheatFlagScore, config.AntiCheatActionScore, config.AntiCheatMaxActionRate))
+		return
+	}
+
+	if _, err := game.ParseAntiCheatAction(config.AntiCheatAction); err != nil {
+		r.add("config", checkFail, "ANTICHEAT_ACTION: "+err.Error())
+		return
+	}
+
+	if config.ReportsPerHour < 0 || config.ReportEscalateCount < 0 {
+		r.add("config", checkFail, fmt.Sprintf("REPORTS_PER_HOUR and REPORT_ESCALATE_COUNT must not be negative, got %d and %d", config.ReportsPerHour, config.ReportEscalateCount))
+		return
+	}
+
+	if config.ReportEscalateCount > 0 && config.ReportEscalateWindow <= 0 {
+		r.add("config", checkFail, fmt.Sprintf("REPORT_ESCALATE_WINDOW must be positive, got %s", config.ReportEscalateWindow))
+		return
+	}
+
+	if _, err := game.LoadZones(config.ZonesFile, config.MapName, game.NewWorldBounds(config)); err != nil {
+		r.add("config", checkFail, "ZONES_FILE: "+err.Error())
+		return
+	}
+
+	if _, err := game.LoadGameRules(config.RulesFile); err != nil {
+		r.add("config", checkFail, "RULES_FILE: "+err.Error())
+		return
+	}
+
+	if _, err := game.LoadAbilities(config.AbilitiesFile); err != nil {
+		r.add("config", checkFail, "ABILITIES_FILE: "+err.Error())
+		return
+	}
+
+	if config.AuthTokenTTL <= 0 {
+		r.add("config", checkFail, fmt.Sprintf("AUTH_TOKEN_TTL must be positive, got %s", config.AuthTokenTTL))
+		return
+	}
+
+	if _, err := game.LoadOAuthProviders(config.OAuthProvidersFile); err != nil {
+		r.add("config", checkFail, "OAUTH_PROVIDERS_FILE: "+err.Error())
+		return
+	}
+
+	if config.MinProtocolVersion < game.LegacyProtocolVersion || config.MinProtocolVersion > game.CurrentProtocolVersion {
+		r.add("config", checkFail, fmt.Sprintf("MIN_PROTOCOL_VERSION must be between %d and %d, got %d", game.LegacyProtocolVersion, game.CurrentProtocolVersion, config.MinProtocolVersion))
+		return
+	}
+
+	if config.MaxPlayers < 0 || config.MaxQueuedPlayers < 0 {
+		r.add("config", checkFail, fmt.Sprintf("MAX_PLAYERS and MAX_QUEUED_PLAYERS must not be negative, got %d and %d", config.MaxPlayers, config.MaxQueuedPlayers))
+		return
+	}
+
+	if config.BandwidthCap < 0 {
+		r.add("config", checkFail, fmt.Sprintf("BANDWIDTH_CAP must not be negative, got %d", config.BandwidthCap))
+		return
+	}
+
+	if config.HTTPReadTimeout < 0 || config.HTTPWriteTimeout < 0 || config.HTTPIdleTimeout < 0 {
+		r.add("config", checkFail, fmt.Sprintf("HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT and HTTP_IDLE_TIMEOUT must not be negative, got %s, %s and %s", config.HTTPReadTimeout, config.HTTPWriteTimeout, config.HTTPIdleTimeout))
+		return
+	}
+
+	if config.SendQueueSize < 1 || config.SlowClientTimeout <= 0 {
+		r.add("config", checkFail, fmt.Sprintf("SEND_QUEUE_SIZE and SLOW_CLIENT_TIMEOUT must be positive, got %d and %s", config.SendQueueSize, config.SlowClientTimeout))
+		return
+	}
+
+	if config.MaxMessageSize < 1 || config.MaxMessageDepth < 1 || config.MaxMessageValues < 1 {
+		r.add("config", checkFail, fmt.Sprintf("MAX_MESSAGE_SIZE, MAX_MESSAGE_DEPTH and MAX_MESSAGE_VALUES must be positive, got %d, %d and %d", config.MaxMessageSize, config.MaxMessageDepth, config.MaxMessageValues))
+		return
+	}
+
+	if config.CompressionThreshold < 0 {
+		r.add("config", checkFail, fmt.Sprintf("COMPRESSION_THRESHOLD must not be negative, got %d", config.CompressionThreshold))
+		return
+	}
+
+	if config.UDPWorkers < 1 || config.UDPQueueSize < 1 {
+		r.add("config", checkFail, fmt.Sprintf("UDP_WORKERS and UDP_QUEUE_SIZE must be positive, got %d and %d", config.UDPWorkers, config.UDPQueueSize))
+		return
+	}
+	if config.UDPSockets < 1 {
+		r.add("config", checkFail, fmt.Sprintf("UDP_SOCKETS must be positive, got %d", config.UDPSockets))
+		return
+	}
+	if config.UDPSenders < 1 || config.UDPSendQueue < 1 {
+		r.add("config", checkFail, fmt.Sprintf("UDP_SENDERS and UDP_SEND_QUEUE must be positive, got %d and %d", config.UDPSenders, config.UDPSendQueue))
+		return
+	}
+	if config.UDPReadBatch < 1 || config.UDPReadBatch > udp.MaxUDPReadBatch {
+		r.add("config", checkFail, fmt.Sprintf("UDP_READ_BATCH must be between 1 and %d, got %d", udp.MaxUDPReadBatch, config.UDPReadBatch))
+		return
+	}
+	if config.UDPSockets > 1 && !udp.ReusePortSupported {
+		r.add("config", checkFail, "UDP_SOCKETS above 1 needs SO_REUSEPORT load balancing, which only Linux has")
+		return
+	}
+
+	if config.UDPClientTimeout <= 0 {
+		r.add("config", checkFail, fmt.Sprintf("UDP_CLIENT_TIMEOUT must be positive, got %s", config.UDPClientTimeout))
+		return
+	}
+	if config.UDPKeepaliveInterval <= 0 || config.UDPKeepaliveInterval >= config.UDPClientTimeout {
+		r.add("config", checkFail, fmt.Sprintf("UDP_KEEPALIVE_INTERVAL must be positive and shorter than UDP_CLIENT_TIMEOUT (%s), got %s", config.UDPClientTimeout, config.UDPKeepaliveInterval))
+		return
+	}
+
+	if _, err := udp.ParsePacketDropPolicy(config.UDPDropPolicy); err != nil {
+		r.add("config", checkFail, "UDP_DROP_POLICY: "+err.Error())
+		return
+	}
+
+	if _, err := udp.ParseUDPEncryptionMode(config.UDPEncryption); err != nil {
+		r.add("config", checkFail, "UDP_ENCRYPTION: "+err.Error())
+		return
+	}
+
+	switch config.ReplicationRole {
+	case "":
+	case "primary", "standby":
+		if config.ReplicationAddr == "" {
+			r.add("config", checkFail, fmt.Sprintf("REPLICATION_ADDR is required when REPLICATION_ROLE=%s", config.ReplicationRole))
+			return
+		}
+		if config.ReplicationSecret == "" {
+			r.add("config", checkFail, fmt.Sprintf("REPLICATION_SECRET is required when REPLICATION_ROLE=%s", config.ReplicationRole))
+			return
+		}
+	default:
+		r.add("config", checkFail, fmt.Sprintf("REPLICATION_ROLE must be \"primary\" or \"standby\", got %q", config.ReplicationRole))
+		return
+	}
+
+	if backends := transport.ParseBackends(config.ShardBackends); len(backends) > 0 && config.Protocol != "router" {
+		found := false
+		for _, backend := range backends {
+			found = found || backend == config.ShardSelf
+		}
+		if !found {
+			r.add("config", checkFail, fmt.Sprintf("SHARD_SELF %q must be one of SHARD_BACKENDS", config.ShardSelf))
+			return
+		}
+	}
+
+	if peers := transport.ParseBackends(config.RPCPeers); len(peers) > 0 {
+		found := false
+		for _, peer := range peers {
+			found = found || peer == config.RPCSelf
+		}
+		if !found {
+			r.add("config", checkFail, fmt.Sprintf("RPC_SELF %q must be one of RPC_PEERS", config.RPCSelf))
+			return
+		}
+		if config.RPCSecret == "" {
+			r.add("config", checkFail, "RPC_SECRET is required when RPC_PEERS is set")
+			return
+		}
+	}
+
+	features, err := game.ParseFeatures(config.ServerProfile, config.DisabledFeatures)
+	if err != nil {
+		r.add("config", checkFail, err.Error())
+		return
+	}
+
+	r.add("config", checkOK, fmt.Sprintf("protocol=%s port=%s database=%s features=%s", config.Protocol, config.Port, config.DatabaseURL, features))
+}
+
+func (r *StartupReport) checkTenants(config *game.Config) {
+	tenants, err := LoadTenantConfigs(config)
+	if err != nil {
+		r.add("tenants", checkFail, err.Error())
+		return
+	}
+	if len(tenants) == 0 {
+		r.add("tenants", checkOK, "none")
+		return
+	}
+
+	names := make([]string, len(tenants))
+	for i, tenant := range tenants {
+		names[i] = tenant.Name
+	}
+	r.add("tenants", checkOK, fmt.Sprintf("hosting %s", strings.Join(names, ", ")))
+}
+
+func (r *StartupReport) checkPlugins(config *game.Config) {
+	plugins, err := game.LoadPlugins(config.PluginDir)
+	if err != nil {
+		r.add("plugins", checkFail, err.Error())
+		return
+	}
+	if len(plugins) == 0 {
+		r.add("plugins", checkOK, "none")
+		return
+	}
+
+	names := make([]string, len(plugins))
+	for i, plugin := range plugins {
+		names[i] = plugin.Name
+	}
+	r.add("plugins", checkOK, fmt.Sprintf("loaded %s", strings.Join(names, ", ")))
+}
+
+func (r *StartupReport) checkMigrations() {
+	migrationFiles, err := store.ListMigrationFiles()
+	if err != nil {
+		r.add("migrations", checkFail, err.Error())
+		return
+	}
+	if len(migrationFiles) == 0 {
+		r.add("migrations", checkFail, fmt.Sprintf("no migration files found in %s/ (run from the repository root or copy the directory next to the binary)", store.MigrationsDir))
+		return
+	}
+
+	r.add("migrations", checkOK, fmt.Sprintf("%d files, latest %s", len(migrationFiles), filepath.Base(migrationFiles[len(migrationFiles)-1])))
+}
+
+func (r *StartupReport) checkPort(config *game.Config) {
+	addrs := transport.BindAddrs(config, config.Port)
+	for _, addr := range addrs {
+		if !r.checkAddr(config, addr) {
+			return
+		}
+	}
+	r.add("port", checkOK, fmt.Sprintf("%s is available", strings.Join(addrs, ", ")))
+}
+
+// checkAddr reports whether the game can listen on addr.
+func (r *StartupReport) checkAddr(config *game.Config, addr string) bool {
+	if config.Protocol == "udp" {
+		conn, err := net.ListenPacket(transport.ListenNetwork("udp", addr), addr)
+		if err != nil {
+			r.add("port", checkFail, fmt.Sprintf("UDP %s is not available: %v", addr, err))
+			return false
+		}
+		conn.Close()
+
+		// The HTTP API shares the port number over TCP
+		listener, err := net.Listen(transport.ListenNetwork("tcp", addr), addr)
+		if err != nil {
+			r.add("port", checkWarn, fmt.Sprintf("UDP %s is available but TCP (HTTP API) is not: %v", addr, err))
+			return false
+		}
+		listener.Close()
+	} else {
+		listener, err := net.Listen(transport.ListenNetwork("tcp", addr), addr)
+		if err != nil {
+			r.add("port", checkFail, fmt.Sprintf("TCP %s is not available: %v", addr, err))
+			return false
+		}
+		listener.Close()
+	}
+	return true
+}
+
+func (r *StartupReport) checkTLS(config *game.Config) {
+	if _, err := transport.ParseTrustedProxies(config.TrustedProxies); err != nil {
+		r.add("tls", checkFail, "TRUSTED_PROXIES: "+err.Error())
+		return
+	}
+
+	domains := transport.ParseBackends(config.TLSAutocertDomains)
+	if len(domains) > 0 {
+		if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+			r.add("tls", checkFail, "set either TLS_CERT_FILE and TLS_KEY_FILE or TLS_AUTOCERT_DOMAINS, not both")
+			return
+		}
+		if err := os.MkdirAll(config.TLSAutocertCacheDir, 0700); err != nil {
+			r.add("tls", checkFail, fmt.Sprintf("failed to create TLS_AUTOCERT_CACHE_DIR: %v", err))
+			return
+		}
+		r.add("tls", checkOK, fmt.Sprintf("Let's Encrypt certificates for %s", strings.Join(domains, ", ")))
+		return
+	}
+
+	if config.TLSCertFile == "" && config.TLSKeyFile == "" {
+		r.add("tls", checkOK, "not configured")
+		return
+	}
+	if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+		r.add("tls", checkFail, "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		return
+	}
+
+	cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		r.add("tls", checkFail, fmt.Sprintf("failed to load certificate: %v", err))
+		return
+	}
+
+	r.add("tls", checkOK, fmt.Sprintf("loaded %d certificate(s) from %s", len(cert.Certificate), config.TLSCertFile))
+}
+
+func (r *StartupReport) checkOrigins(config *game.Config) {
+	policy, err := transport.ParseOriginPolicy(config.AllowedOrigins)
+	if err != nil {
+		r.add("origins", checkFail, "ALLOWED_ORIGINS: "+err.Error())
+		return
+	}
+
+	switch {
+	case config.Protocol != "websocket" && config.Protocol != "router":
+		r.add("origins", checkOK, "no WebSocket upgrades with PROTOCOL="+config.Protocol)
+	case policy.AllowsAll():
+		r.add("origins", checkWarn, "WebSocket upgrades are accepted from any origin; set ALLOWED_ORIGINS to restrict them")
+	default:
+		r.add("origins", checkOK, "allowed: "+strings.Join(transport.ParseBackends(config.AllowedOrigins), ", "))
+	}
+}
+
+// checkTracing reports where traces are exported, if anywhere.
+func (r *StartupReport) checkTracing(config *game.Config) {
+	if config.OTLPEndpoint == "" {
+		r.add("tracing", checkOK, "disabled")
+		return
+	}
+	r.add("tracing", checkOK, "exporting OpenTelemetry traces to "+config.OTLPEndpoint)
+}
+
+// checkChaos validates fault injection settings and warns when any fault is
+// enabled, since chaos must never run in production.
+func (r *StartupReport) checkChaos(config *game.Config) {
+	rates := []struct {
+		name string
+		rate float64
+	}{
+		{"CHAOS_DB_ERROR_RATE", config.ChaosDBErrorRate},
+		{"CHAOS_LATENCY_RATE", config.ChaosLatencyRate},
+		{"CHAOS_DROP_BROADCAST_RATE", config.ChaosDropRate},
+		{"CHAOS_DISCONNECT_RATE", config.ChaosDisconnectRate},
+	}
+
+	var enabled []string
+	for _, setting := range rates {
+		if setting.rate < 0 || setting.rate > 1 {
+			r.add("chaos", checkFail, fmt.Sprintf("%s must be between 0 and 1, got %g", setting.name, setting.rate))
+			return
+		}
+		if setting.rate > 0 {
+			enabled = append(enabled, fmt.Sprintf("%s=%g", setting.name, setting.rate))
+		}
+	}
+	if config.ChaosLatency <= 0 && config.ChaosLatencyRate > 0 {
+		r.add("chaos", checkFail, fmt.Sprintf("CHAOS_LATENCY must be positive when CHAOS_LATENCY_RATE is set, got %s", config.ChaosLatency))
+		return
+	}
+
+	if len(enabled) == 0 {
+		r.add("chaos", checkOK, "disabled")
+		return
+	}
+	r.add("chaos", checkWarn, "fault injection enabled: "+strings.Join(enabled, " "))
+}
+
+// CheckDatabase verifies the schema is at the latest migration.
+func (r *StartupReport) CheckDatabase(database store.Store) {
+	version, err := database.SchemaVersion(context.Background())
+	if err != nil {
+		r.add("schema", checkFail, err.Error())
+		return
+	}
+
+	migrationFiles, err := store.ListMigrationFiles()
+	if err != nil || len(migrationFiles) == 0 {
+		r.add("schema", checkWarn, fmt.Sprintf("schema version %s, could not determine expected version", version))
+		return
+	}
+
+	expected := filepath.Base(migrationFiles[len(migrationFiles)-1])
+	if version != expected {
+		r.add("schema", checkFail, fmt.Sprintf("schema version %s does not match latest migration %s", version, expected))
+		return
+	}
+
+	r.add("schema", checkOK, fmt.Sprintf("schema version %s", version))
+}
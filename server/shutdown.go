@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+)
+
+// shutdownGrace is how long a shutdown waits after disconnecting everyone,
+// so that their sessions are ended before the database closes.
+const shutdownGrace = time.Second
+
+// shutdownServer disconnects every player of every hosted game, waits for
+// their sessions to end, saves the worlds, finishes the writes the games
+// queued, flushes and closes the databases and exits.
+func shutdownServer(message string) {
+	for _, tenant := range hostedTenants {
+		for _, player := range tenant.game.OnlinePlayers() {
+			tenant.game.Kick(player.ID, game.DisconnectShutdown, message)
+		}
+	}
+	time.Sleep(shutdownGrace)
+	for _, tenant := range hostedTenants {
+		if err := tenant.world.Save(context.Background()); err != nil {
+			logrus.Errorf("Failed to save world snapshot of %s: %v", tenant.describe(), err)
+		}
+		tenant.close()
+	}
+	os.Exit(0)
+}
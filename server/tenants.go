@@ -0,0 +1,300 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+	"online-server-go/transport/udp"
+	"online-server-go/transport/ws"
+)
+
+// Tenants are further games hosted by the process next to the primary one
+// the environment configures, each with its own database, rules, world and
+// players, for running dev, staging and small worlds on one machine.
+// TENANTS_FILE lists them:
+//
+//	[
+//	  {"name": "staging", "path": "/staging", "env": {"DATABASE_URL": "sqlite:staging.db"}},
+//	  {"name": "dev", "path": "/dev", "env": {"DATABASE_URL": "sqlite:dev.db", "RULES_FILE": "dev.json"}}
+//	]
+//
+// A tenant is configured by the variables in its env block alone, on top of
+// the defaults. With PROTOCOL=websocket tenants are served on PORT under
+// their path, HTTP API included. UDP and TCP tenants are served on the PORT
+// of their block instead and need no path. The protocol, TLS, tracing, fault
+// injection and crash dumps are settings of the process, taken from the
+// environment, and the console and the admin gRPC API serve the primary game
+// only. Shutting down from the console or a maintenance restart of any game
+// stops them all.
+type TenantConfig struct {
+	Name string            `json:"name"`
+	Path string            `json:"path,omitempty"`
+	Env  map[string]string `json:"env"`
+}
+
+// LoadTenantConfigs reads TENANTS_FILE and checks the tenants can be hosted
+// next to the primary game.
+func LoadTenantConfigs(config *game.Config) ([]TenantConfig, error) {
+	if config.TenantsFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(config.TenantsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TENANTS_FILE: %w", err)
+	}
+	var tenants []TenantConfig
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse TENANTS_FILE: %w", err)
+	}
+
+	names := make(map[string]bool)
+	used := map[string]bool{config.Port: true}
+	for _, tenant := range tenants {
+		if tenant.Name == "" || names[tenant.Name] {
+			return nil, fmt.Errorf("every tenant needs a name of its own, got %q", tenant.Name)
+		}
+		names[tenant.Name] = true
+
+		if config.Protocol == "websocket" {
+			if !strings.HasPrefix(tenant.Path, "/") || tenant.Path == "/" || strings.HasSuffix(tenant.Path, "/") || used[tenant.Path] {
+				return nil, fmt.Errorf("tenant %s needs a path of its own such as /%s, got %q", tenant.Name, tenant.Name, tenant.Path)
+			}
+			used[tenant.Path] = true
+			continue
+		}
+		port := tenant.Env["PORT"]
+		if _, err := strconv.Atoi(port); err != nil || used[port] {
+			return nil, fmt.Errorf("tenant %s needs a PORT of its own with PROTOCOL=%s, got %q", tenant.Name, config.Protocol, port)
+		}
+		used[port] = true
+	}
+	return tenants, nil
+}
+
+// Config returns the configuration of the tenant hosted by a process
+// configured with process.
+func (t TenantConfig) Config(process *game.Config) *game.Config {
+	config := game.LoadConfigFrom(func(key string) string { return t.Env[key] })
+	if t.Env["SERVER_ID"] == "" {
+		config.ServerID = process.ServerID + "/" + t.Name
+	}
+	if process.Protocol == "websocket" {
+		config.Port = process.Port
+	}
+
+	config.Protocol = process.Protocol
+	config.CrashDumpDir = process.CrashDumpDir
+	config.TLSCertFile, config.TLSKeyFile = process.TLSCertFile, process.TLSKeyFile
+	config.TLSAutocertDomains, config.TLSAutocertCacheDir = process.TLSAutocertDomains, process.TLSAutocertCacheDir
+	config.TLSAutocertEmail, config.TLSAutocertHTTPPort = process.TLSAutocertEmail, process.TLSAutocertHTTPPort
+	config.OTLPEndpoint = process.OTLPEndpoint
+	config.ChaosDBErrorRate, config.ChaosLatency, config.ChaosLatencyRate = process.ChaosDBErrorRate, process.ChaosLatency, process.ChaosLatencyRate
+	config.ChaosDropRate, config.ChaosDisconnectRate = process.ChaosDropRate, process.ChaosDisconnectRate
+	config.Console, config.ConsolePort, config.GRPCPort = false, "", ""
+	config.TenantsFile = ""
+	return config
+}
+
+// Tenant is a game hosted by the process.
+type Tenant struct {
+	Name string // empty for the primary game
+	Path string // where a WebSocket tenant is served
+
+	config      *game.Config
+	database    store.Store
+	replication *transport.Replication
+	router      *transport.Router
+	cluster     *transport.Cluster
+	events      *game.WorldEvents
+	entities    *game.EntityRegistry
+	rules       *game.Rules
+	accounts    *game.Accounts
+	plugins     *game.Plugins
+	random      *game.SimRand
+	world       *game.WorldPersister
+	game        game.GameAdmin
+}
+
+// hostedTenants are the games of the process, the primary one first, so
+// that a shutdown stops them all.
+var hostedTenants []*Tenant
+
+// openTenant opens the database of a game and sets up everything its server
+// needs, completing and logging its startup report. It exits the process if
+// the game cannot be hosted.
+func openTenant(name, path string, config *game.Config, report *StartupReport) *Tenant {
+	// The feature settings were validated by the startup checks
+	config.Features, _ = game.ParseFeatures(config.ServerProfile, config.DisabledFeatures)
+
+	database, err := store.NewDatabase(game.ChaosDriverName, config.DatabaseURL)
+	if err != nil {
+		logrus.Fatalf("Failed to initialize database: %v", err)
+	}
+	database.SetWriteBudget(store.NewWriteBudget(config.DBWriteBudget))
+	database.SetQueryTimeout(config.DBQueryTimeout)
+	database.SetServerID(config.ServerID)
+
+	logrus.Infof("Database initialized: %s", config.DatabaseURL)
+
+	report.CheckDatabase(database)
+	report.Log()
+	if report.Failed() {
+		logrus.Fatal("Refusing to start; fix the failed checks above")
+	}
+
+	// Sessions left open by a crash would otherwise count as active forever
+	if closed, err := database.CloseOrphanedSessions(context.Background()); err != nil {
+		logrus.Errorf("Failed to close orphaned sessions: %v", err)
+	} else if closed > 0 {
+		logrus.Warnf("Closed %d sessions left open by the previous run of %s", closed, config.ServerID)
+	}
+
+	replication, err := transport.NewReplication(config)
+	if err != nil {
+		logrus.Fatalf("Failed to start replication: %v", err)
+	}
+
+	cluster, err := transport.NewCluster(config)
+	if err != nil {
+		logrus.Fatalf("Failed to start cluster RPC: %v", err)
+	}
+
+	random := game.NewSimRand(config)
+	if config.Deterministic {
+		logrus.Warnf("Deterministic simulation from seed %d", config.Seed)
+	}
+	events := game.NewWorldEvents(config.WorldEventInterval, random)
+	entities := game.NewEntityRegistry(game.NewWorldBounds(config), random)
+	world := game.NewWorldPersister(database, events, entities, config.WorldSnapshotInterval)
+	if err := world.Restore(context.Background()); err != nil {
+		logrus.Errorf("Failed to restore the world: %v", err)
+	}
+	go world.Run()
+
+	rules := game.NewRules(config.RulesFile)
+	rules.ReloadOnSignal()
+
+	accounts, err := game.NewAccounts(database, config)
+	if err != nil {
+		logrus.Fatalf("Failed to load accounts: %v", err)
+	}
+
+	// The plugins were loaded by the startup checks, so this cannot fail
+	loaded, _ := game.LoadPlugins(config.PluginDir)
+
+	tenant := &Tenant{
+		Name:        name,
+		Path:        path,
+		config:      config,
+		database:    database,
+		replication: replication,
+		router:      transport.NewRouter(config),
+		cluster:     cluster,
+		events:      events,
+		entities:    entities,
+		rules:       rules,
+		accounts:    accounts,
+		plugins:     game.NewPlugins(loaded),
+		random:      random,
+		world:       world,
+	}
+	hostedTenants = append(hostedTenants, tenant)
+	return tenant
+}
+
+// registerAPI serves the HTTP API of the tenant's game on mux.
+func (t *Tenant) registerAPI(mux *http.ServeMux, info *game.ServerInfoHandler) {
+	game.NewAPIHandler(t.database).Register(mux)
+	game.NewAuthHandler(t.accounts).Register(mux)
+	game.NewAdminHandler(game.NewStaffAuthorizer(t.config.AdminToken, t.accounts), t.database, t.events, t.rules, t.game).Register(mux)
+	info.Register(mux)
+}
+
+// startUDP creates the UDP server of the tenant's game and serves its HTTP
+// API over TCP on the same port number. The caller runs the server.
+func (t *Tenant) startUDP(tlsConfig *tls.Config) *udp.UDPGameServer {
+	addrs := transport.BindAddrs(t.config, t.config.Port)
+	server, err := udp.NewUDPGameServer(addrs, t.database, t.config, t.replication, t.router, t.cluster, t.events, t.entities, t.rules, t.accounts, t.plugins, t.random)
+	if err != nil {
+		logrus.Fatalf("Failed to create UDP server of %s: %v", t.describe(), err)
+	}
+	t.game = server
+
+	mux := http.NewServeMux()
+	t.registerAPI(mux, server.ServerInfo())
+	go func() {
+		logrus.Infof("HTTP API of %s listening on: %s", t.describe(), strings.Join(addrs, ", "))
+		if err := transport.ListenAndServe(transport.NewHTTPServer(addrs[0], mux, t.config, tlsConfig), addrs); err != nil {
+			logrus.Errorf("HTTP API server error: %v", err)
+		}
+	}()
+
+	game.StartServerHeartbeat(t.database, t.config.Protocol, server)
+	logrus.Infof("Starting UDP game server of %s on %s", t.describe(), strings.Join(addrs, ", "))
+	return server
+}
+
+// startTCP creates the TCP server of the tenant's game and serves its HTTP
+// API on API_PORT, since the game owns PORT. The caller runs the server.
+func (t *Tenant) startTCP(tlsConfig *tls.Config) *ws.TCPGameServer {
+	server, err := ws.NewTCPGameServer(transport.BindAddrs(t.config, t.config.Port), t.database, t.config, t.replication, t.router, t.cluster, t.events, t.entities, t.rules, t.accounts, t.plugins, t.random)
+	if err != nil {
+		logrus.Fatalf("Failed to create TCP server of %s: %v", t.describe(), err)
+	}
+	t.game = server.Game()
+
+	if t.config.APIPort != "" {
+		apiAddrs := transport.BindAddrs(t.config, t.config.APIPort)
+		mux := http.NewServeMux()
+		t.registerAPI(mux, game.NewServerInfoHandler(t.config, t.config.Protocol, server.Game()))
+		go func() {
+			logrus.Infof("HTTP API of %s listening on: %s", t.describe(), strings.Join(apiAddrs, ", "))
+			if err := transport.ListenAndServe(transport.NewHTTPServer(apiAddrs[0], mux, t.config, tlsConfig), apiAddrs); err != nil {
+				logrus.Errorf("HTTP API server error: %v", err)
+			}
+		}()
+	} else {
+		logrus.Warnf("API_PORT is not set; HTTP API of %s is disabled with PROTOCOL=tcp", t.describe())
+	}
+
+	game.StartServerHeartbeat(t.database, t.config.Protocol, server.Game())
+	return server
+}
+
+// startWebSocket creates the WebSocket server of the tenant's game with its
+// HTTP API mounted. The caller serves it.
+func (t *Tenant) startWebSocket() *ws.GameServer {
+	server := ws.NewGameServer(t.database, t.config, t.replication, t.router, t.cluster, t.events, t.entities, t.rules, t.accounts, t.plugins, t.random)
+	t.game = server.Game()
+
+	t.registerAPI(server.Mux(), game.NewServerInfoHandler(t.config, t.config.Protocol, server.Game()))
+	game.StartServerHeartbeat(t.database, t.config.Protocol, server.Game())
+	return server
+}
+
+// close waits for the writes the game has queued and closes its database.
+func (t *Tenant) close() {
+	if t.game != nil {
+		t.game.Persistence().Close()
+	}
+	t.database.Close()
+}
+
+// describe names the tenant in log lines.
+func (t *Tenant) describe() string {
+	if t.Name == "" {
+		return "primary game"
+	}
+	return "tenant " + t.Name
+}
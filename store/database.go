@@ -0,0 +1,2934 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+)
+
+type Database struct {
+	db       *sql.DB
+	writer   *DBWriter
+	players  *PlayerCache
+	budget   *WriteBudget
+	serverID string
+
+	queryTimeout time.Duration
+
+	privacyMu sync.RWMutex
+	privacy   map[uuid.UUID]PrivacySettings
+
+	flushMu   sync.Mutex // orders player flushes and whole-row writes
+	stopFlush chan struct{}
+	flushDone chan struct{}
+}
+
+type DBPlayer struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	X          float64   `json:"x"`
+	Y          float64   `json:"y"`
+	Health     float64   `json:"health"`
+	Score      int64     `json:"score"`
+	Rating     int64     `json:"rating"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// PlayerState is the part of a player CreateOrUpdatePlayer writes.
+type PlayerState struct {
+	ID     uuid.UUID
+	Name   string
+	X, Y   float32
+	Health float32
+	Score  uint32
+}
+
+// sessionEndEvent is the SessionEnd message of the game, logged for the
+// sessions CloseOrphanedSessions ends.
+type sessionEndEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		SessionID int64  `json:"session_id"`
+		Reason    string `json:"reason"`
+	} `json:"data"`
+}
+
+func newSessionEndEvent(sessionID int64, reason string) *sessionEndEvent {
+	event := &sessionEndEvent{Type: "SessionEnd"}
+	event.Data.SessionID = sessionID
+	event.Data.Reason = reason
+	return event
+}
+
+type GameSession struct {
+	ID           int64      `json:"id"`
+	PlayerID     string     `json:"player_id"`
+	SessionStart time.Time  `json:"session_start"`
+	SessionEnd   *time.Time `json:"session_end,omitempty"`
+	Protocol     string     `json:"protocol"`
+	ClientIP     *string    `json:"client_ip,omitempty"`
+}
+
+type PlayerEvent struct {
+	ID        int64     `json:"id"`
+	PlayerID  string    `json:"player_id"`
+	SessionID *int64    `json:"session_id,omitempty"`
+	EventType string    `json:"event_type"`
+	EventData *string   `json:"event_data,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type ChatMessage struct {
+	ID        int64     `json:"id"`
+	PlayerID  string    `json:"player_id"`
+	SessionID *int64    `json:"session_id,omitempty"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type PlayerStats struct {
+	PlayerID        string    `json:"player_id"`
+	Kills           int64     `json:"kills"`
+	Deaths          int64     `json:"deaths"`
+	Assists         int64     `json:"assists"`
+	ItemsCollected  int64     `json:"items_collected"`
+	PlaytimeSeconds int64     `json:"playtime_seconds"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// PlayerStatsDelta holds increments applied to a player's aggregated stats.
+type PlayerStatsDelta struct {
+	Kills          int64
+	Deaths         int64
+	Assists        int64
+	ItemsCollected int64
+}
+
+type RatingChange struct {
+	PlayerID  uuid.UUID `json:"player_id"`
+	OldRating int64     `json:"old_rating"`
+	NewRating int64     `json:"new_rating"`
+	Placement int       `json:"placement"`
+	Room      string    `json:"room,omitempty"`
+}
+
+type RatingHistoryEntry struct {
+	ID         int64     `json:"id"`
+	PlayerID   string    `json:"player_id"`
+	MatchID    *int64    `json:"match_id,omitempty"`
+	OldRating  int64     `json:"old_rating"`
+	NewRating  int64     `json:"new_rating"`
+	Placement  int       `json:"placement"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// MatchResult is a player's line of a scoreboard, and of the final summary
+// saved when a match ends.
+type MatchResult struct {
+	Rank     int       `json:"rank"`
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+	Score    int64     `json:"score"`
+	Kills    int64     `json:"kills"`
+	Deaths   int64     `json:"deaths"`
+	PingMS   int64     `json:"ping_ms,omitempty"` // measured for UDP clients only
+}
+
+// Friendship is a friend relation as seen from one player.
+type Friendship struct {
+	FriendID string `json:"player_id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`   // "pending" or "accepted"
+	Incoming bool   `json:"incoming"` // true if the other player sent the request
+}
+
+// Mail is a message a player sent another, who need not be online.
+type Mail struct {
+	ID          int64      `json:"mail_id"`
+	SenderID    string     `json:"sender_id"`
+	SenderName  string     `json:"sender_name"`
+	RecipientID string     `json:"recipient_id"`
+	Subject     string     `json:"subject"`
+	Body        string     `json:"body"`
+	SentAt      time.Time  `json:"sent_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// Guild is a persistent group of players. Its tag is shown next to its
+// members' names.
+type Guild struct {
+	ID        string    `json:"guild_id"`
+	Name      string    `json:"name"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Guild ranks. Only the leader promotes, and promoting an officer makes them
+// leader in the promoter's place.
+const (
+	GuildRankLeader  = "leader"
+	GuildRankOfficer = "officer"
+	GuildRankMember  = "member"
+)
+
+type GuildMember struct {
+	PlayerID string    `json:"player_id"`
+	Name     string    `json:"name"`
+	Rank     string    `json:"rank"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// GuildScore is a guild's standing on the guild leaderboard, the sum of
+// its members' scores.
+type GuildScore struct {
+	GuildID string `json:"guild_id"`
+	Name    string `json:"name"`
+	Tag     string `json:"tag"`
+	Members int    `json:"members"`
+	Score   int64  `json:"score"`
+}
+
+type InventoryItem struct {
+	ItemType string `json:"item_type"`
+	Quantity int64  `json:"quantity"`
+}
+
+// LeaderboardPeriod identifies one leaderboard window, e.g. {"weekly", "2026-10-12"}.
+type LeaderboardPeriod struct {
+	Period string
+	Start  string
+}
+
+type WindowedScore struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+	Score    int64  `json:"score"`
+}
+
+type HighScore struct {
+	ID           int64     `json:"id"`
+	PlayerID     string    `json:"player_id"`
+	Score        int64     `json:"score"`
+	AchievedAt   time.Time `json:"achieved_at"`
+	GameDuration *int64    `json:"game_duration,omitempty"`
+}
+
+// NewDatabase opens databaseURL, a SQLite path optionally prefixed with
+// "sqlite:", with the database/sql driver of that name, such as "sqlite3",
+// and migrates it.
+func NewDatabase(driver, databaseURL string) (*Database, error) {
+	logrus.Infof("Connecting to database: %s", databaseURL)
+
+	var dbPath string
+	if strings.HasPrefix(databaseURL, "sqlite:") {
+		dbPath = strings.TrimPrefix(databaseURL, "sqlite:")
+	} else {
+		dbPath = databaseURL
+	}
+
+	if dbPath != ":memory:" {
+		parentDir := filepath.Dir(dbPath)
+		if parentDir != "." {
+			if err := os.MkdirAll(parentDir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create parent directory: %w", err)
+			}
+		}
+
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			file, err := os.Create(dbPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create database file: %w", err)
+			}
+			file.Close()
+		}
+	}
+
+	db, err := sql.Open(driver, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if dbPath == ":memory:" {
+		// Each connection to :memory: opens a database of its own
+		db.SetMaxOpenConns(1)
+	}
+
+	database := &Database{db: db, privacy: make(map[uuid.UUID]PrivacySettings)}
+	if err := database.runMigrations(); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	database.writer = NewDBWriter(db)
+	database.players = NewPlayerCache()
+	database.stopFlush = make(chan struct{})
+	database.flushDone = make(chan struct{})
+	go database.flushPlayersLoop()
+
+	logrus.Info("Database connection established and migrations completed")
+	return database, nil
+}
+
+// MigrationsDir holds the migrations, relative to the working directory.
+const MigrationsDir = "migrations"
+
+// ListMigrationFiles returns the migration files in the order they are applied.
+func ListMigrationFiles() ([]string, error) {
+	migrationFiles, err := filepath.Glob(filepath.Join(MigrationsDir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
+	}
+	sort.Strings(migrationFiles)
+	return migrationFiles, nil
+}
+
+// SetWriteBudget limits the rate of writes shared by all subsystems.
+func (d *Database) SetWriteBudget(budget *WriteBudget) {
+	d.budget = budget
+}
+
+// SetQueryTimeout bounds every query and write; zero leaves them bounded by
+// the caller's context alone.
+func (d *Database) SetQueryTimeout(timeout time.Duration) {
+	d.queryTimeout = timeout
+}
+
+// acquire takes a write from the budget, waiting for it no longer than the
+// query timeout.
+func (d *Database) acquire(ctx context.Context, priority WritePriority) error {
+	if d.budget == nil {
+		return nil
+	}
+	if d.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.queryTimeout)
+		defer cancel()
+	}
+	return d.budget.Acquire(ctx, priority)
+}
+
+// withTimeout also traces the call until it is cancelled, see startDBSpan.
+func (d *Database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, span := startDBSpan(ctx)
+	var cancel context.CancelFunc
+	if d.queryTimeout <= 0 {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, d.queryTimeout)
+	}
+	return ctx, func() {
+		cancel()
+		span.End()
+	}
+}
+
+// write runs fn through the single writer within the query timeout.
+func (d *Database) write(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	err := d.writer.Do(ctx, fn)
+	recordSpanError(ctx, err)
+	return err
+}
+
+// exec runs a write statement through the single writer.
+func (d *Database) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = tx.Exec(query, args...)
+		return err
+	})
+	if err != nil {
+		// result may still be set by the writer after a timeout
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetServerID tags sessions, events and matches created from now on with
+// this server instance.
+func (d *Database) SetServerID(serverID string) {
+	d.serverID = serverID
+}
+
+func (d *Database) runMigrations() error {
+	logrus.Info("Running database migrations...")
+
+	migrationFiles, err := ListMigrationFiles()
+	if err != nil {
+		return err
+	}
+	if len(migrationFiles) == 0 {
+		return fmt.Errorf("no migration files found in %s/", MigrationsDir)
+	}
+
+	if _, err := d.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, migrationFile := range migrationFiles {
+		version := filepath.Base(migrationFile)
+
+		var applied int
+		if err := d.db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		migrationSQL, err := ioutil.ReadFile(migrationFile)
+		if err != nil {
+			return fmt.Errorf("failed to read migration file: %w", err)
+		}
+
+		statements := strings.Split(string(migrationSQL), ";")
+		for _, statement := range statements {
+			statement = strings.TrimSpace(statement)
+			if statement != "" {
+				if _, err := d.db.Exec(statement); err != nil {
+					if !strings.Contains(err.Error(), "already exists") &&
+						!strings.Contains(err.Error(), "duplicate column name") {
+						logrus.Errorf("Migration error in %s: %v", migrationFile, err)
+						return err
+					}
+				}
+			}
+		}
+
+		if _, err := d.db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		logrus.Infof("Applied migration %s", version)
+	}
+
+	logrus.Info("Database migrations completed")
+	return nil
+}
+
+// SchemaVersion returns the most recently applied migration, or "" if none.
+func (d *Database) SchemaVersion(ctx context.Context) (string, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var version sql.NullString
+	if err := d.db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version.String, nil
+}
+
+func (d *Database) CreateOrUpdatePlayer(ctx context.Context, player PlayerState) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	// The whole row is written, superseding updates waiting for the flush
+	d.flushMu.Lock()
+	defer d.flushMu.Unlock()
+	d.players.Discard(player.ID)
+
+	query := `
+		INSERT INTO players (id, name, x, y, health, score, updated_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			x = excluded.x,
+			y = excluded.y,
+			health = excluded.health,
+			score = excluded.score,
+			updated_at = datetime('now'),
+			last_seen_at = datetime('now')
+	`
+
+	_, err := d.exec(ctx, query,
+		player.ID.String(),
+		player.Name,
+		player.X,
+		player.Y,
+		player.Health,
+		player.Score,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create/update player: %w", err)
+	}
+
+	logrus.Infof("Player %s (%s) created/updated in database", player.Name, player.ID)
+	return nil
+}
+
+// GetPlayer returns the player from the cache, loading it on first use.
+func (d *Database) GetPlayer(ctx context.Context, playerID uuid.UUID) (*DBPlayer, error) {
+	if player, cached := d.players.Get(playerID); cached {
+		return &player, nil
+	}
+
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, name, x, y, health, score, rating, created_at, updated_at, last_seen_at
+		FROM players WHERE id = ?
+	`
+
+	var player DBPlayer
+	row := d.db.QueryRowContext(ctx, query, playerID.String())
+
+	err := row.Scan(
+		&player.ID,
+		&player.Name,
+		&player.X,
+		&player.Y,
+		&player.Health,
+		&player.Score,
+		&player.Rating,
+		&player.CreatedAt,
+		&player.UpdatedAt,
+		&player.LastSeenAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player: %w", err)
+	}
+
+	player = d.players.Put(player)
+	return &player, nil
+}
+
+// UpdatePlayerPosition, UpdatePlayerScore and UpdatePlayerHealth update the
+// cached player without waiting; the database is written on the next flush.
+func (d *Database) UpdatePlayerPosition(ctx context.Context, playerID uuid.UUID, x, y float32) error {
+	px, py := float64(x), float64(y)
+	d.players.Update(playerID, func(write *pendingPlayerWrite) {
+		write.X, write.Y = &px, &py
+	})
+	return nil
+}
+
+func (d *Database) UpdatePlayerScore(ctx context.Context, playerID uuid.UUID, score uint32) error {
+	value := int64(score)
+	d.players.Update(playerID, func(write *pendingPlayerWrite) {
+		write.Score = &value
+	})
+	return nil
+}
+
+func (d *Database) UpdatePlayerHealth(ctx context.Context, playerID uuid.UUID, health float32) error {
+	value := float64(health)
+	d.players.Update(playerID, func(write *pendingPlayerWrite) {
+		write.Health = &value
+	})
+	return nil
+}
+
+// InvalidatePlayer drops a cached player, or every cached player for
+// uuid.Nil, after the rows were edited outside the server.
+func (d *Database) InvalidatePlayer(playerID uuid.UUID) {
+	if playerID == uuid.Nil {
+		d.players.InvalidateAll()
+		return
+	}
+	d.players.Invalidate(playerID)
+}
+
+func (d *Database) GetTopPlayers(ctx context.Context, limit int) ([]DBPlayer, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, name, x, y, health, score, rating, created_at, updated_at, last_seen_at
+		FROM players 
+		ORDER BY score DESC, updated_at DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top players: %w", err)
+	}
+	defer rows.Close()
+
+	var players []DBPlayer
+	for rows.Next() {
+		var player DBPlayer
+		err := rows.Scan(
+			&player.ID,
+			&player.Name,
+			&player.X,
+			&player.Y,
+			&player.Health,
+			&player.Score,
+			&player.Rating,
+			&player.CreatedAt,
+			&player.UpdatedAt,
+			&player.LastSeenAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan player: %w", err)
+		}
+		players = append(players, player)
+	}
+
+	return players, nil
+}
+
+func (d *Database) CreateSession(ctx context.Context, playerID uuid.UUID, protocol string, clientIP *string) (int64, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return 0, err
+	}
+
+	if d.privacyFor(ctx, playerID).NoIPStorage {
+		clientIP = nil
+	}
+
+	query := `
+		INSERT INTO game_sessions (player_id, protocol, client_ip, server_id)
+		VALUES (?, ?, ?, ?)
+	`
+
+	result, err := d.exec(ctx, query, playerID.String(), protocol, clientIP, d.serverID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get session ID: %w", err)
+	}
+
+	logrus.Infof("Created session %d for player %s (%s)", sessionID, playerID, protocol)
+	return sessionID, nil
+}
+
+func (d *Database) EndSession(ctx context.Context, sessionID int64) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE game_sessions 
+		SET session_end = datetime('now'), end_reason = 'disconnect'
+		WHERE id = ? AND session_end IS NULL
+	`
+
+	result, err := d.exec(ctx, query, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+
+	// Only accumulate playtime the first time a session is closed
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		if err := d.addSessionPlaytime(ctx, sessionID); err != nil {
+			logrus.Errorf("Failed to add session playtime: %v", err)
+		}
+	}
+
+	logrus.Infof("Ended session %d", sessionID)
+	return nil
+}
+
+// LogEvent records an event of a player in player_events, with eventData,
+// a message of the game, as JSON unless it is nil.
+func (d *Database) LogEvent(ctx context.Context, playerID uuid.UUID, sessionID *int64, eventType string, eventData interface{}) error {
+	// Movement logging is the first write class shed under load
+	priority := WriteNormal
+	if eventType == "move" {
+		priority = WriteBulk
+	}
+	if err := d.acquire(ctx, priority); errors.Is(err, errWriteShed) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var eventDataJSON *string
+	if eventData != nil {
+		data, err := json.Marshal(eventData)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event data: %w", err)
+		}
+		jsonStr := string(data)
+		eventDataJSON = &jsonStr
+	}
+
+	privacy := d.privacyFor(ctx, playerID)
+	if privacy.NoChatLog && eventType == "chat" {
+		eventDataJSON = nil
+	}
+	if privacy.AnonymizeEvents && eventDataJSON != nil {
+		anonymized, err := anonymizeEventData(*eventDataJSON, playerID)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize event data: %w", err)
+		}
+		eventDataJSON = &anonymized
+	}
+	if privacy.AnonymizeEvents {
+		// The session would identify the player as well
+		playerID = anonymousPlayerID
+		sessionID = nil
+	}
+
+	query := `
+		INSERT INTO player_events (player_id, session_id, event_type, event_data, server_id)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := d.exec(ctx, query, playerID.String(), sessionID, eventType, eventDataJSON, d.serverID)
+	if err != nil {
+		return fmt.Errorf("failed to log event: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetPlayerEvents(ctx context.Context, playerID uuid.UUID, limit int) ([]PlayerEvent, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, player_id, session_id, event_type, event_data, timestamp
+		FROM player_events 
+		WHERE player_id = ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, playerID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []PlayerEvent
+	for rows.Next() {
+		var event PlayerEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.PlayerID,
+			&event.SessionID,
+			&event.EventType,
+			&event.EventData,
+			&event.Timestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (d *Database) SaveChatMessage(ctx context.Context, playerID uuid.UUID, sessionID *int64, message string) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	if d.privacyFor(ctx, playerID).NoChatLog {
+		message = redactedChatMessage
+	}
+
+	query := `
+		INSERT INTO chat_messages (player_id, session_id, message)
+		VALUES (?, ?, ?)
+	`
+
+	_, err := d.exec(ctx, query, playerID.String(), sessionID, message)
+	if err != nil {
+		return fmt.Errorf("failed to save chat message: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetRecentChatMessages(ctx context.Context, limit int) ([]ChatMessage, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, player_id, session_id, message, timestamp
+		FROM chat_messages 
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []ChatMessage
+	for rows.Next() {
+		var message ChatMessage
+		err := rows.Scan(
+			&message.ID,
+			&message.PlayerID,
+			&message.SessionID,
+			&message.Message,
+			&message.Timestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (d *Database) SaveHighScore(ctx context.Context, playerID uuid.UUID, score uint32, gameDuration *uint32) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO high_scores (player_id, score, game_duration)
+		VALUES (?, ?, ?)
+	`
+
+	var duration *int64
+	if gameDuration != nil {
+		d := int64(*gameDuration)
+		duration = &d
+	}
+
+	_, err := d.exec(ctx, query, playerID.String(), score, duration)
+	if err != nil {
+		return fmt.Errorf("failed to save high score: %w", err)
+	}
+
+	logrus.Infof("Saved high score %d for player %s", score, playerID)
+	return nil
+}
+
+func (d *Database) GetHighScores(ctx context.Context, limit int) ([]HighScore, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT h.id, h.player_id, h.score, h.achieved_at, h.game_duration
+		FROM high_scores h
+		JOIN players p ON h.player_id = p.id
+		ORDER BY h.score DESC, h.achieved_at DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get high scores: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []HighScore
+	for rows.Next() {
+		var score HighScore
+		err := rows.Scan(
+			&score.ID,
+			&score.PlayerID,
+			&score.Score,
+			&score.AchievedAt,
+			&score.GameDuration,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan high score: %w", err)
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, nil
+}
+
+// AddLeaderboardPoints adds points to the player's rollup in every given
+// leaderboard window.
+func (d *Database) AddLeaderboardPoints(ctx context.Context, playerID uuid.UUID, points int64, periods []LeaderboardPeriod) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	return d.write(ctx, func(tx *sql.Tx) error {
+		for _, period := range periods {
+			if _, err := tx.Exec(`
+				INSERT INTO leaderboard_rollups (period, period_start, player_id, score)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT(period, period_start, player_id) DO UPDATE SET
+					score = score + excluded.score,
+					updated_at = datetime('now')
+			`, period.Period, period.Start, playerID.String(), points); err != nil {
+				return fmt.Errorf("failed to add leaderboard points: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (d *Database) GetWindowedLeaderboard(ctx context.Context, period LeaderboardPeriod, limit int) ([]WindowedScore, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT r.player_id, p.name, r.score
+		FROM leaderboard_rollups r
+		JOIN players p ON p.id = r.player_id
+		WHERE r.period = ? AND r.period_start = ?
+		ORDER BY r.score DESC, r.updated_at ASC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, period.Period, period.Start, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get windowed leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	scores := []WindowedScore{}
+	for rows.Next() {
+		var score WindowedScore
+		if err := rows.Scan(&score.PlayerID, &score.Name, &score.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard score: %w", err)
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, nil
+}
+
+func (d *Database) GetPlayerCount(ctx context.Context) (int64, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT COUNT(*) FROM players"
+	var count int64
+	row := d.db.QueryRowContext(ctx, query)
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get player count: %w", err)
+	}
+	return count, nil
+}
+
+func (d *Database) GetActiveSessionsCount(ctx context.Context) (int64, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT COUNT(*) FROM game_sessions WHERE session_end IS NULL"
+	var count int64
+	row := d.db.QueryRowContext(ctx, query)
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get active sessions count: %w", err)
+	}
+	return count, nil
+}
+
+func (d *Database) CleanupOldSessions(ctx context.Context, hours int) (int64, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE game_sessions 
+		SET session_end = datetime('now'), end_reason = 'stale'
+		WHERE session_end IS NULL 
+		AND datetime(session_start, '+' || ? || ' hours') < datetime('now')
+	`
+
+	result, err := d.exec(ctx, query, hours)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old sessions: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected > 0 {
+		logrus.Warnf("Cleaned up %d old sessions (older than %d hours)", affected, hours)
+	}
+
+	return affected, nil
+}
+
+// CloseOrphanedSessions closes the sessions this server left open when it
+// stopped without ending them, e.g. after a crash. Each session ends at its
+// last logged event, or at its start if it has none, with a
+// "server_restart" reason and a session_end event. It must run before the
+// server accepts players.
+func (d *Database) CloseOrphanedSessions(ctx context.Context) (int64, error) {
+	queryCtx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	rows, err := d.db.QueryContext(queryCtx, `
+		SELECT id, player_id FROM game_sessions
+		WHERE server_id = ? AND session_end IS NULL
+	`, d.serverID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find orphaned sessions: %w", err)
+	}
+
+	type orphan struct {
+		id       int64
+		playerID string
+	}
+	var orphans []orphan
+	for rows.Next() {
+		var o orphan
+		if err := rows.Scan(&o.id, &o.playerID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan orphaned session: %w", err)
+		}
+		orphans = append(orphans, o)
+	}
+	rows.Close()
+
+	query := `
+		UPDATE game_sessions
+		SET session_end = COALESCE(
+				(SELECT MAX(timestamp) FROM player_events WHERE session_id = game_sessions.id),
+				session_start),
+			end_reason = 'server_restart'
+		WHERE id = ? AND session_end IS NULL
+	`
+
+	var closed int64
+	for _, o := range orphans {
+		if err := d.acquire(ctx, WriteCritical); err != nil {
+			return closed, err
+		}
+		result, err := d.exec(ctx, query, o.id)
+		if err != nil {
+			return closed, fmt.Errorf("failed to close orphaned session %d: %w", o.id, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			continue
+		}
+		closed++
+
+		if err := d.addSessionPlaytime(ctx, o.id); err != nil {
+			logrus.Errorf("Failed to add session playtime: %v", err)
+		}
+
+		if playerID, err := uuid.Parse(o.playerID); err == nil {
+			sessionID := o.id
+			endEvent := newSessionEndEvent(sessionID, "server_restart")
+			if err := d.LogEvent(ctx, playerID, &sessionID, "session_end", endEvent); err != nil {
+				logrus.Errorf("Failed to log session end event: %v", err)
+			}
+		}
+	}
+
+	return closed, nil
+}
+
+func (d *Database) AddPlayerStats(ctx context.Context, playerID uuid.UUID, delta PlayerStatsDelta) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO player_stats (player_id, kills, deaths, assists, items_collected, updated_at)
+		VALUES (?, ?, ?, ?, ?, datetime('now'))
+		ON CONFLICT(player_id) DO UPDATE SET
+			kills = kills + excluded.kills,
+			deaths = deaths + excluded.deaths,
+			assists = assists + excluded.assists,
+			items_collected = items_collected + excluded.items_collected,
+			updated_at = datetime('now')
+	`
+
+	_, err := d.exec(ctx, query,
+		playerID.String(),
+		delta.Kills,
+		delta.Deaths,
+		delta.Assists,
+		delta.ItemsCollected,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update player stats: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) addSessionPlaytime(ctx context.Context, sessionID int64) error {
+	query := `
+		INSERT INTO player_stats (player_id, playtime_seconds, updated_at)
+		SELECT player_id,
+			MAX(0, CAST(strftime('%s', session_end) AS INTEGER) - CAST(strftime('%s', session_start) AS INTEGER)),
+			datetime('now')
+		FROM game_sessions
+		WHERE id = ? AND session_end IS NOT NULL
+		ON CONFLICT(player_id) DO UPDATE SET
+			playtime_seconds = playtime_seconds + excluded.playtime_seconds,
+			updated_at = datetime('now')
+	`
+
+	_, err := d.exec(ctx, query, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to add session playtime: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetPlayerStats(ctx context.Context, playerID uuid.UUID) (*PlayerStats, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT player_id, kills, deaths, assists, items_collected, playtime_seconds, updated_at
+		FROM player_stats WHERE player_id = ?
+	`
+
+	var stats PlayerStats
+	row := d.db.QueryRowContext(ctx, query, playerID.String())
+
+	err := row.Scan(
+		&stats.PlayerID,
+		&stats.Kills,
+		&stats.Deaths,
+		&stats.Assists,
+		&stats.ItemsCollected,
+		&stats.PlaytimeSeconds,
+		&stats.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player stats: %w", err)
+	}
+
+	return &stats, nil
+}
+
+func (d *Database) GetTopPlayerStats(ctx context.Context, orderBy string, limit int) ([]PlayerStats, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	columns := map[string]bool{
+		"kills":            true,
+		"deaths":           true,
+		"assists":          true,
+		"items_collected":  true,
+		"playtime_seconds": true,
+	}
+	if !columns[orderBy] {
+		return nil, fmt.Errorf("invalid stats column: %s", orderBy)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT player_id, kills, deaths, assists, items_collected, playtime_seconds, updated_at
+		FROM player_stats
+		ORDER BY %s DESC, updated_at DESC
+		LIMIT ?
+	`, orderBy)
+
+	rows, err := d.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top player stats: %w", err)
+	}
+	defer rows.Close()
+
+	var statsList []PlayerStats
+	for rows.Next() {
+		var stats PlayerStats
+		err := rows.Scan(
+			&stats.PlayerID,
+			&stats.Kills,
+			&stats.Deaths,
+			&stats.Assists,
+			&stats.ItemsCollected,
+			&stats.PlaytimeSeconds,
+			&stats.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan player stats: %w", err)
+		}
+		statsList = append(statsList, stats)
+	}
+
+	return statsList, nil
+}
+
+func (d *Database) CreateMatch(ctx context.Context, protocol string, seed int64) (int64, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO matches (protocol, server_id, seed)
+		VALUES (?, ?, ?)
+	`
+
+	result, err := d.exec(ctx, query, protocol, d.serverID, seed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create match: %w", err)
+	}
+
+	matchID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get match ID: %w", err)
+	}
+
+	return matchID, nil
+}
+
+func (d *Database) EndMatch(ctx context.Context, matchID int64, playerCount int) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE matches
+		SET ended_at = datetime('now'), player_count = ?
+		WHERE id = ? AND ended_at IS NULL
+	`
+
+	_, err := d.exec(ctx, query, playerCount, matchID)
+	if err != nil {
+		return fmt.Errorf("failed to end match: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetPlayerRating(ctx context.Context, playerID uuid.UUID) (int64, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT rating FROM players WHERE id = ?"
+
+	var rating int64
+	err := d.db.QueryRowContext(ctx, query, playerID.String()).Scan(&rating)
+	if err == sql.ErrNoRows {
+		return DefaultRating, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get player rating: %w", err)
+	}
+
+	return rating, nil
+}
+
+// ApplyRatingChanges stores new ratings and their history rows atomically.
+func (d *Database) ApplyRatingChanges(ctx context.Context, matchID *int64, changes []RatingChange) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, change := range changes {
+			d.players.Invalidate(change.PlayerID)
+		}
+	}()
+
+	return d.write(ctx, func(tx *sql.Tx) error {
+		for _, change := range changes {
+			if _, err := tx.Exec(
+				"UPDATE players SET rating = ?, updated_at = datetime('now') WHERE id = ?",
+				change.NewRating, change.PlayerID.String(),
+			); err != nil {
+				return fmt.Errorf("failed to update player rating: %w", err)
+			}
+
+			if _, err := tx.Exec(`
+				INSERT INTO rating_history (player_id, match_id, old_rating, new_rating, placement)
+				VALUES (?, ?, ?, ?, ?)
+			`, change.PlayerID.String(), matchID, change.OldRating, change.NewRating, change.Placement); err != nil {
+				return fmt.Errorf("failed to insert rating history: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (d *Database) GetRatingHistory(ctx context.Context, playerID uuid.UUID, limit int) ([]RatingHistoryEntry, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, player_id, match_id, old_rating, new_rating, placement, recorded_at
+		FROM rating_history
+		WHERE player_id = ?
+		ORDER BY recorded_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, playerID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rating history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []RatingHistoryEntry
+	for rows.Next() {
+		var entry RatingHistoryEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.PlayerID,
+			&entry.MatchID,
+			&entry.OldRating,
+			&entry.NewRating,
+			&entry.Placement,
+			&entry.RecordedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rating history: %w", err)
+		}
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// SaveMatchResults stores the final scoreboard of a match.
+func (d *Database) SaveMatchResults(ctx context.Context, matchID int64, results []MatchResult) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	return d.write(ctx, func(tx *sql.Tx) error {
+		for _, result := range results {
+			if _, err := tx.Exec(`
+				INSERT INTO match_results (match_id, player_id, name, rank, score, kills, deaths, ping_ms)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, matchID, result.PlayerID.String(), result.Name, result.Rank, result.Score, result.Kills, result.Deaths, result.PingMS); err != nil {
+				return fmt.Errorf("failed to save match result: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetMatchResults returns the final scoreboard of a match in rank order, or
+// nil if none was saved.
+func (d *Database) GetMatchResults(ctx context.Context, matchID int64) ([]MatchResult, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT rank, player_id, name, score, kills, deaths, ping_ms
+		FROM match_results
+		WHERE match_id = ?
+		ORDER BY rank, name
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, matchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get match results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []MatchResult
+	for rows.Next() {
+		var result MatchResult
+		var playerID string
+		if err := rows.Scan(&result.Rank, &playerID, &result.Name, &result.Score, &result.Kills, &result.Deaths, &result.PingMS); err != nil {
+			return nil, fmt.Errorf("failed to scan match result: %w", err)
+		}
+		result.PlayerID, _ = uuid.Parse(playerID)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func (d *Database) GetTopRatedPlayers(ctx context.Context, limit int) ([]DBPlayer, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, name, x, y, health, score, rating, created_at, updated_at, last_seen_at
+		FROM players
+		ORDER BY rating DESC, updated_at DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top rated players: %w", err)
+	}
+	defer rows.Close()
+
+	var players []DBPlayer
+	for rows.Next() {
+		var player DBPlayer
+		err := rows.Scan(
+			&player.ID,
+			&player.Name,
+			&player.X,
+			&player.Y,
+			&player.Health,
+			&player.Score,
+			&player.Rating,
+			&player.CreatedAt,
+			&player.UpdatedAt,
+			&player.LastSeenAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan player: %w", err)
+		}
+		players = append(players, player)
+	}
+
+	return players, nil
+}
+
+// CreateFriendRequest records a pending request from playerID to friendID.
+// If friendID already asked playerID, the existing request is accepted instead.
+// It returns the resulting status.
+func (d *Database) CreateFriendRequest(ctx context.Context, playerID, friendID uuid.UUID) (string, error) {
+	accepted, err := d.AcceptFriendRequest(ctx, friendID, playerID)
+	if err != nil {
+		return "", err
+	}
+	if accepted {
+		return "accepted", nil
+	}
+
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return "", err
+	}
+
+	query := `
+		INSERT INTO friends (player_id, friend_id, status)
+		VALUES (?, ?, 'pending')
+		ON CONFLICT(player_id, friend_id) DO NOTHING
+	`
+
+	_, err = d.exec(ctx, query, playerID.String(), friendID.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to create friend request: %w", err)
+	}
+
+	return "pending", nil
+}
+
+// AcceptFriendRequest accepts a pending request from requesterID to
+// recipientID and reports whether one existed.
+func (d *Database) AcceptFriendRequest(ctx context.Context, requesterID, recipientID uuid.UUID) (bool, error) {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE friends
+		SET status = 'accepted', accepted_at = datetime('now')
+		WHERE player_id = ? AND friend_id = ? AND status = 'pending'
+	`
+
+	result, err := d.exec(ctx, query, requesterID.String(), recipientID.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to accept friend request: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// DeleteFriendship removes any relation between the two players in either direction.
+func (d *Database) DeleteFriendship(ctx context.Context, playerID, friendID uuid.UUID) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM friends
+		WHERE (player_id = ? AND friend_id = ?) OR (player_id = ? AND friend_id = ?)
+	`
+
+	_, err := d.exec(ctx, query, playerID.String(), friendID.String(), friendID.String(), playerID.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete friendship: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetFriends(ctx context.Context, playerID uuid.UUID) ([]Friendship, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT f.friend_id, p.name, f.status, 0
+		FROM friends f JOIN players p ON p.id = f.friend_id
+		WHERE f.player_id = ?
+		UNION ALL
+		SELECT f.player_id, p.name, f.status, 1
+		FROM friends f JOIN players p ON p.id = f.player_id
+		WHERE f.friend_id = ?
+		ORDER BY 3, 2
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, playerID.String(), playerID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friends: %w", err)
+	}
+	defer rows.Close()
+
+	var friends []Friendship
+	for rows.Next() {
+		var friend Friendship
+		err := rows.Scan(
+			&friend.FriendID,
+			&friend.Name,
+			&friend.Status,
+			&friend.Incoming,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan friend: %w", err)
+		}
+		friends = append(friends, friend)
+	}
+
+	return friends, nil
+}
+
+func (d *Database) GetAcceptedFriendIDs(ctx context.Context, playerID uuid.UUID) ([]uuid.UUID, error) {
+	friends, err := d.GetFriends(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	for _, friend := range friends {
+		if friend.Status != "accepted" {
+			continue
+		}
+		if id, err := uuid.Parse(friend.FriendID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// AddInventoryItem adds quantity of an item to a player's inventory, capping
+// the stack at maxStack.
+func (d *Database) AddInventoryItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity, maxStack int64) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO inventory (player_id, item_type, quantity)
+		VALUES (?, ?, MIN(?, ?))
+		ON CONFLICT(player_id, item_type) DO UPDATE SET
+			quantity = MIN(quantity + excluded.quantity, ?),
+			updated_at = datetime('now')
+	`
+
+	_, err := d.exec(ctx, query, playerID.String(), itemType, quantity, maxStack, maxStack)
+	if err != nil {
+		return fmt.Errorf("failed to add inventory item: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveInventoryItem takes quantity of an item from a player's inventory and
+// reports false, leaving the inventory unchanged, if the player holds fewer.
+func (d *Database) RemoveInventoryItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity int64) (bool, error) {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE inventory
+		SET quantity = quantity - ?, updated_at = datetime('now')
+		WHERE player_id = ? AND item_type = ? AND quantity >= ?
+	`
+
+	result, err := d.exec(ctx, query, quantity, playerID.String(), itemType, quantity)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove inventory item: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	_, err = d.exec(ctx, `DELETE FROM inventory WHERE player_id = ? AND item_type = ? AND quantity <= 0`, playerID.String(), itemType)
+	if err != nil {
+		return true, fmt.Errorf("failed to delete empty inventory stack: %w", err)
+	}
+
+	return true, nil
+}
+
+func (d *Database) GetInventory(ctx context.Context, playerID uuid.UUID) ([]InventoryItem, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT item_type, quantity
+		FROM inventory
+		WHERE player_id = ? AND quantity > 0
+		ORDER BY item_type
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, playerID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %w", err)
+	}
+	defer rows.Close()
+
+	items := []InventoryItem{}
+	for rows.Next() {
+		var item InventoryItem
+		if err := rows.Scan(&item.ItemType, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan inventory item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetXPRules returns the XP granted per action.
+func (d *Database) GetXPRules(ctx context.Context) (map[string]int64, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT action, xp FROM xp_rules`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get xp rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make(map[string]int64)
+	for rows.Next() {
+		var action string
+		var xp int64
+		if err := rows.Scan(&action, &xp); err != nil {
+			return nil, fmt.Errorf("failed to scan xp rule: %w", err)
+		}
+		rules[action] = xp
+	}
+
+	return rules, nil
+}
+
+// GetPlayerProgress returns a player's XP and level, or the starting values
+// for unknown players.
+func (d *Database) GetPlayerProgress(ctx context.Context, playerID uuid.UUID) (int64, int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var xp int64
+	level := 1
+
+	err := d.db.QueryRowContext(ctx, `SELECT xp, level FROM players WHERE id = ?`, playerID.String()).Scan(&xp, &level)
+	if err == sql.ErrNoRows {
+		return 0, 1, nil
+	}
+	if err != nil {
+		return 0, 1, fmt.Errorf("failed to get player progress: %w", err)
+	}
+
+	return xp, level, nil
+}
+
+func (d *Database) UpdatePlayerProgress(ctx context.Context, playerID uuid.UUID, xp int64, level int) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE players
+		SET xp = ?, level = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`
+
+	_, err := d.exec(ctx, query, xp, level, playerID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update player progress: %w", err)
+	}
+
+	return nil
+}
+
+// GetPlayerZone returns the zone a player was last in, or "" for unknown
+// players.
+func (d *Database) GetPlayerZone(ctx context.Context, playerID uuid.UUID) (string, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var zone string
+	err := d.db.QueryRowContext(ctx, `SELECT zone FROM players WHERE id = ?`, playerID.String()).Scan(&zone)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get player zone: %w", err)
+	}
+
+	return zone, nil
+}
+
+func (d *Database) UpdatePlayerZone(ctx context.Context, playerID uuid.UUID, zone string) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE players
+		SET zone = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`
+
+	_, err := d.exec(ctx, query, zone, playerID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update player zone: %w", err)
+	}
+
+	return nil
+}
+
+// Ban is an active ban; ExpiresAt is nil for a permanent ban.
+type Ban struct {
+	PlayerID  string     `json:"player_id"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Message is the error shown to the banned player when they connect.
+func (b *Ban) Message() string {
+	text := "You are banned"
+	if b.Reason != "" {
+		text += ": " + b.Reason
+	}
+	if b.ExpiresAt != nil {
+		text += " (until " + b.ExpiresAt.UTC().Format(time.RFC3339) + ")"
+	}
+	return text
+}
+
+// BanPlayer bans a player, replacing any earlier ban. A nil expiresAt bans
+// permanently.
+func (d *Database) BanPlayer(ctx context.Context, playerID uuid.UUID, reason string, expiresAt *time.Time) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	var expires interface{}
+	if expiresAt != nil {
+		expires = expiresAt.UTC().Format("2006-01-02 15:04:05")
+	}
+
+	query := `
+		INSERT INTO bans (player_id, reason, created_at, expires_at)
+		VALUES (?, ?, datetime('now'), ?)
+		ON CONFLICT(player_id) DO UPDATE SET
+			reason = excluded.reason,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+	`
+
+	if _, err := d.exec(ctx, query, playerID.String(), reason, expires); err != nil {
+		return fmt.Errorf("failed to ban player: %w", err)
+	}
+
+	logrus.Warnf("Banned player %s: %s", playerID, reason)
+	return nil
+}
+
+// UnbanPlayer lifts a ban. It reports false if the player was not banned.
+func (d *Database) UnbanPlayer(ctx context.Context, playerID uuid.UUID) (bool, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return false, err
+	}
+
+	result, err := d.exec(ctx, "DELETE FROM bans WHERE player_id = ?", playerID.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to unban player: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// GetActiveBan returns the player's ban, or nil if they are not banned or
+// the ban has expired.
+func (d *Database) GetActiveBan(ctx context.Context, playerID uuid.UUID) (*Ban, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT player_id, reason, created_at, expires_at
+		FROM bans
+		WHERE player_id = ? AND (expires_at IS NULL OR expires_at > datetime('now'))
+	`
+
+	var ban Ban
+	var expiresAt sql.NullTime
+	err := d.db.QueryRowContext(ctx, query, playerID.String()).Scan(&ban.PlayerID, &ban.Reason, &ban.CreatedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ban: %w", err)
+	}
+
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	}
+	return &ban, nil
+}
+
+// AuditEntry is an admin action, recorded in admin_audit so that operators
+// sharing a server can tell who did what.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"` // the player, room or event acted on
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter selects entries of the audit log. Empty fields match any
+// entry.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Limit  int
+}
+
+// RecordAdminAction appends an admin action to the audit log.
+func (d *Database) RecordAdminAction(ctx context.Context, entry AuditEntry) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO admin_audit (actor, action, target, reason, created_at)
+		VALUES (?, ?, ?, ?, datetime('now'))
+	`
+
+	if _, err := d.exec(ctx, query, entry.Actor, entry.Action, entry.Target, entry.Reason); err != nil {
+		return fmt.Errorf("failed to record admin action: %w", err)
+	}
+	return nil
+}
+
+// GetAdminAudit returns the admin actions matching a filter, the latest
+// first.
+func (d *Database) GetAdminAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, actor, action, target, reason, created_at
+		FROM admin_audit
+		WHERE (? = '' OR actor = ?)
+			AND (? = '' OR action = ?)
+			AND (? = '' OR target = ?)
+			AND created_at >= ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	since := filter.Since.UTC().Format("2006-01-02 15:04:05")
+	rows, err := d.db.QueryContext(ctx, query,
+		filter.Actor, filter.Actor,
+		filter.Action, filter.Action,
+		filter.Target, filter.Target,
+		since, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin audit: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &entry.Reason, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan admin audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// PlayerReport is a player's complaint about another, filed in game with
+// ReportPlayer and reviewed by staff through /admin/reports.
+type PlayerReport struct {
+	ID          int64      `json:"id"`
+	ReporterID  uuid.UUID  `json:"reporter_id"`
+	ReportedID  uuid.UUID  `json:"reported_id"`
+	Reason      string     `json:"reason"`
+	ChatExcerpt string     `json:"chat_excerpt,omitempty"`
+	Escalated   bool       `json:"escalated"` // the reported player drew reports from several players quickly
+	CreatedAt   time.Time  `json:"created_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy  string     `json:"resolved_by,omitempty"`
+	Resolution  string     `json:"resolution,omitempty"`
+}
+
+// ReportFilter selects reports, the latest first. Empty fields match any
+// report.
+type ReportFilter struct {
+	ReportedID uuid.UUID
+	Open       bool // only unresolved reports
+	Escalated  bool // only escalated reports
+	Limit      int
+}
+
+// CreateReport stores a player report and sets its ID and creation time.
+func (d *Database) CreateReport(ctx context.Context, report *PlayerReport) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	report.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	query := `
+		INSERT INTO player_reports (reporter_id, reported_id, reason, chat_excerpt, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := d.exec(ctx, query, report.ReporterID.String(), report.ReportedID.String(), report.Reason, report.ChatExcerpt,
+		report.CreatedAt.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	report.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get report ID: %w", err)
+	}
+	return nil
+}
+
+// GetReports returns the reports matching a filter, the latest first.
+func (d *Database) GetReports(ctx context.Context, filter ReportFilter) ([]PlayerReport, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, reporter_id, reported_id, reason, chat_excerpt, escalated, created_at, resolved_at, resolved_by, resolution
+		FROM player_reports
+		WHERE (? = '' OR reported_id = ?)
+			AND (? = 0 OR resolved_at IS NULL)
+			AND (? = 0 OR escalated = 1)
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	reportedID := ""
+	if filter.ReportedID != uuid.Nil {
+		reportedID = filter.ReportedID.String()
+	}
+	rows, err := d.db.QueryContext(ctx, query, reportedID, reportedID, filter.Open, filter.Escalated, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []PlayerReport
+	for rows.Next() {
+		var report PlayerReport
+		var reporterID, reportedID string
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&report.ID, &reporterID, &reportedID, &report.Reason, &report.ChatExcerpt, &report.Escalated,
+			&report.CreatedAt, &resolvedAt, &report.ResolvedBy, &report.Resolution); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		report.ReporterID, _ = uuid.Parse(reporterID)
+		report.ReportedID, _ = uuid.Parse(reportedID)
+		if resolvedAt.Valid {
+			report.ResolvedAt = &resolvedAt.Time
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// ResolveReport closes an open report. It reports false if there is no such
+// open report.
+func (d *Database) ResolveReport(ctx context.Context, reportID int64, resolvedBy, resolution string) (bool, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE player_reports SET resolved_at = datetime('now'), resolved_by = ?, resolution = ?
+		WHERE id = ? AND resolved_at IS NULL
+	`
+
+	result, err := d.exec(ctx, query, resolvedBy, resolution, reportID)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve report: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// CountReporters returns how many different players reported a player since
+// the given time.
+func (d *Database) CountReporters(ctx context.Context, reportedID uuid.UUID, since time.Time) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := d.db.QueryRowContext(ctx,
+		"SELECT COUNT(DISTINCT reporter_id) FROM player_reports WHERE reported_id = ? AND created_at >= ?",
+		reportedID.String(), since.UTC().Format("2006-01-02 15:04:05"),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reporters: %w", err)
+	}
+	return count, nil
+}
+
+// EscalateReports marks the open reports of a player escalated and returns
+// how many were not already.
+func (d *Database) EscalateReports(ctx context.Context, reportedID uuid.UUID) (int64, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return 0, err
+	}
+
+	result, err := d.exec(ctx,
+		"UPDATE player_reports SET escalated = 1 WHERE reported_id = ? AND resolved_at IS NULL AND escalated = 0",
+		reportedID.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to escalate reports: %w", err)
+	}
+	escalated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return escalated, nil
+}
+
+// ServerInstance is the health of one server instance sharing the database.
+type ServerInstance struct {
+	ServerID       string    `json:"server_id"`
+	Protocol       string    `json:"protocol"`
+	StartedAt      time.Time `json:"started_at"`
+	LastHeartbeat  time.Time `json:"last_heartbeat"`
+	PlayersOnline  int64     `json:"players_online"`
+	OpenSessions   int64     `json:"open_sessions"`
+	Matches        int64     `json:"matches"`
+	EventsLastHour int64     `json:"events_last_hour"`
+	Healthy        bool      `json:"healthy"`
+}
+
+// RecordServerHeartbeat reports that this instance is alive.
+func (d *Database) RecordServerHeartbeat(ctx context.Context, protocol string, startedAt time.Time, playersOnline int) error {
+	query := `
+		INSERT INTO server_instances (server_id, protocol, started_at, last_heartbeat, players_online)
+		VALUES (?, ?, ?, datetime('now'), ?)
+		ON CONFLICT(server_id) DO UPDATE SET
+			protocol = excluded.protocol,
+			started_at = excluded.started_at,
+			last_heartbeat = excluded.last_heartbeat,
+			players_online = excluded.players_online
+	`
+
+	startedAtUTC := startedAt.UTC().Format("2006-01-02 15:04:05")
+	if _, err := d.exec(ctx, query, d.serverID, protocol, startedAtUTC, playersOnline); err != nil {
+		return fmt.Errorf("failed to record server heartbeat: %w", err)
+	}
+	return nil
+}
+
+// GetServerInstances lists every instance that has reported a heartbeat,
+// with the sessions, matches and events attributed to it.
+func (d *Database) GetServerInstances(ctx context.Context) ([]ServerInstance, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT s.server_id, s.protocol, s.started_at, s.last_heartbeat, s.players_online,
+			(SELECT COUNT(*) FROM game_sessions
+				WHERE server_id = s.server_id AND session_end IS NULL),
+			(SELECT COUNT(*) FROM matches WHERE server_id = s.server_id),
+			(SELECT COUNT(*) FROM player_events
+				WHERE server_id = s.server_id AND timestamp > datetime('now', '-1 hour'))
+		FROM server_instances s
+		ORDER BY s.server_id
+	`
+
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server instances: %w", err)
+	}
+	defer rows.Close()
+
+	var instances []ServerInstance
+	for rows.Next() {
+		var instance ServerInstance
+		err := rows.Scan(
+			&instance.ServerID, &instance.Protocol, &instance.StartedAt, &instance.LastHeartbeat,
+			&instance.PlayersOnline, &instance.OpenSessions, &instance.Matches, &instance.EventsLastHour,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan server instance: %w", err)
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// SaveWorldSnapshot stores this server's world snapshot, replacing the one
+// saved before.
+func (d *Database) SaveWorldSnapshot(ctx context.Context, data []byte) error {
+	query := `
+		INSERT INTO world_snapshots (server_id, data, saved_at)
+		VALUES (?, ?, datetime('now'))
+		ON CONFLICT(server_id) DO UPDATE SET
+			data = excluded.data,
+			saved_at = excluded.saved_at
+	`
+
+	if _, err := d.exec(ctx, query, d.serverID, string(data)); err != nil {
+		return fmt.Errorf("failed to save world snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetWorldSnapshot returns this server's last world snapshot, or nil if it
+// never saved one.
+func (d *Database) GetWorldSnapshot(ctx context.Context) ([]byte, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var data string
+	err := d.db.QueryRowContext(ctx, "SELECT data FROM world_snapshots WHERE server_id = ?", d.serverID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get world snapshot: %w", err)
+	}
+	return []byte(data), nil
+}
+
+// PrivacySettings are a player's choices about the data stored about them.
+// Database methods enforce them when writing, so every caller honours them.
+type PrivacySettings struct {
+	NoChatLog       bool `json:"no_chat_log"`
+	NoIPStorage     bool `json:"no_ip_storage"`
+	AnonymizeEvents bool `json:"anonymize_events"`
+}
+
+// anonymousPlayerID replaces the player ID in anonymized events.
+var anonymousPlayerID = uuid.Nil
+
+// redactedChatMessage is stored instead of chat content.
+const redactedChatMessage = "[redacted]"
+
+// anonymizeEventData replaces the player's ID in event data and drops player
+// names, which identify the player as well.
+func anonymizeEventData(eventData string, playerID uuid.UUID) (string, error) {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(eventData), &decoded); err != nil {
+		return "", err
+	}
+
+	var scrub func(value interface{}) interface{}
+	scrub = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			delete(v, "name")
+			for key, inner := range v {
+				v[key] = scrub(inner)
+			}
+		case []interface{}:
+			for i, inner := range v {
+				v[i] = scrub(inner)
+			}
+		case string:
+			if v == playerID.String() {
+				return anonymousPlayerID.String()
+			}
+		}
+		return value
+	}
+
+	data, err := json.Marshal(scrub(decoded))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// GetPrivacySettings returns the player's settings, which are all off for a
+// player who never changed them.
+func (d *Database) GetPrivacySettings(ctx context.Context, playerID uuid.UUID) (PrivacySettings, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT no_chat_log, no_ip_storage, anonymize_events
+		FROM player_privacy
+		WHERE player_id = ?
+	`
+
+	var settings PrivacySettings
+	err := d.db.QueryRowContext(ctx, query, playerID.String()).Scan(&settings.NoChatLog, &settings.NoIPStorage, &settings.AnonymizeEvents)
+	if err != nil && err != sql.ErrNoRows {
+		return PrivacySettings{}, fmt.Errorf("failed to get privacy settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetPrivacySettings stores the player's settings. They apply to data
+// written from now on.
+func (d *Database) SetPrivacySettings(ctx context.Context, playerID uuid.UUID, settings PrivacySettings) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO player_privacy (player_id, no_chat_log, no_ip_storage, anonymize_events, updated_at)
+		VALUES (?, ?, ?, ?, datetime('now'))
+		ON CONFLICT(player_id) DO UPDATE SET
+			no_chat_log = excluded.no_chat_log,
+			no_ip_storage = excluded.no_ip_storage,
+			anonymize_events = excluded.anonymize_events,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := d.exec(ctx, query, playerID.String(), settings.NoChatLog, settings.NoIPStorage, settings.AnonymizeEvents)
+	if err != nil {
+		return fmt.Errorf("failed to set privacy settings: %w", err)
+	}
+
+	d.privacyMu.Lock()
+	d.privacy[playerID] = settings
+	d.privacyMu.Unlock()
+	return nil
+}
+
+// privacyFor returns the player's settings from the cache, loading them on
+// first use.
+func (d *Database) privacyFor(ctx context.Context, playerID uuid.UUID) PrivacySettings {
+	d.privacyMu.RLock()
+	settings, cached := d.privacy[playerID]
+	d.privacyMu.RUnlock()
+	if cached {
+		return settings
+	}
+
+	settings, err := d.GetPrivacySettings(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to load privacy settings of %s: %v", playerID, err)
+		return settings
+	}
+
+	d.privacyMu.Lock()
+	d.privacy[playerID] = settings
+	d.privacyMu.Unlock()
+	return settings
+}
+
+// GetPreferences returns the player's preferences by name, which is empty
+// for a player who never set any.
+func (d *Database) GetPreferences(ctx context.Context, playerID uuid.UUID) (map[string]string, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT name, value FROM player_preferences WHERE player_id = ?", playerID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	defer rows.Close()
+
+	preferences := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan preference: %w", err)
+		}
+		preferences[name] = value
+	}
+
+	return preferences, rows.Err()
+}
+
+// SetPreferences replaces the player's preferences.
+func (d *Database) SetPreferences(ctx context.Context, playerID uuid.UUID, preferences map[string]string) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM player_preferences WHERE player_id = ?", playerID.String()); err != nil {
+			return err
+		}
+		for name, value := range preferences {
+			_, err := tx.Exec(
+				"INSERT INTO player_preferences (player_id, name, value, updated_at) VALUES (?, ?, ?, datetime('now'))",
+				playerID.String(), name, value,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set preferences: %w", err)
+	}
+	return nil
+}
+
+// Errors of guild writes that conflict with existing guilds and members,
+// which the game shows the player.
+var (
+	ErrGuildTaken     = errors.New("a guild with that name or tag already exists")
+	ErrAlreadyInGuild = errors.New("you are already in a guild")
+)
+
+// CreateGuild creates a guild led by leaderID. It returns ErrGuildTaken if
+// the name or tag is in use, ignoring case, and ErrAlreadyInGuild if the
+// leader is in a guild.
+func (d *Database) CreateGuild(ctx context.Context, guild *Guild, leaderID uuid.UUID) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"INSERT INTO guilds (id, name, tag, created_at) VALUES (?, ?, ?, datetime('now'))",
+			guild.ID, guild.Name, guild.Tag,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO guild_members (player_id, guild_id, rank, joined_at) VALUES (?, ?, ?, datetime('now'))",
+			leaderID.String(), guild.ID, GuildRankLeader,
+		)
+		return err
+	})
+	if guildErr := guildConstraintError(err); guildErr != nil {
+		return guildErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create guild: %w", err)
+	}
+
+	guild.CreatedAt = time.Now().UTC()
+	logrus.Infof("Guild %s [%s] created by %s", guild.Name, guild.Tag, leaderID)
+	return nil
+}
+
+// guildConstraintError translates the constraint violations of guild
+// writes into the errors players are shown.
+func guildConstraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+	switch {
+	case strings.Contains(sqliteErr.Error(), "guilds.name"), strings.Contains(sqliteErr.Error(), "guilds.tag"):
+		return ErrGuildTaken
+	case strings.Contains(sqliteErr.Error(), "guild_members.player_id"):
+		return ErrAlreadyInGuild
+	}
+	return nil
+}
+
+// GetGuildByTag returns the guild with a tag, ignoring case, or nil if
+// there is none.
+func (d *Database) GetGuildByTag(ctx context.Context, tag string) (*Guild, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var guild Guild
+	err := d.db.QueryRowContext(ctx,
+		"SELECT id, name, tag, created_at FROM guilds WHERE tag = ?", tag,
+	).Scan(&guild.ID, &guild.Name, &guild.Tag, &guild.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild: %w", err)
+	}
+	return &guild, nil
+}
+
+// GetPlayerGuild returns the player's guild and rank, or a nil guild if the
+// player is in none.
+func (d *Database) GetPlayerGuild(ctx context.Context, playerID uuid.UUID) (*Guild, string, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT g.id, g.name, g.tag, g.created_at, m.rank
+		FROM guild_members m JOIN guilds g ON g.id = m.guild_id
+		WHERE m.player_id = ?
+	`
+
+	var guild Guild
+	var rank string
+	err := d.db.QueryRowContext(ctx, query, playerID.String()).Scan(&guild.ID, &guild.Name, &guild.Tag, &guild.CreatedAt, &rank)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get player guild: %w", err)
+	}
+	return &guild, rank, nil
+}
+
+// GetGuildMembers lists a guild's members in the order they joined.
+func (d *Database) GetGuildMembers(ctx context.Context, guildID string) ([]GuildMember, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT m.player_id, COALESCE(p.name, ''), m.rank, m.joined_at
+		FROM guild_members m LEFT JOIN players p ON p.id = m.player_id
+		WHERE m.guild_id = ?
+		ORDER BY m.joined_at, m.rowid
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []GuildMember
+	for rows.Next() {
+		var member GuildMember
+		if err := rows.Scan(&member.PlayerID, &member.Name, &member.Rank, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan guild member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// AddGuildMember adds a player to a guild as a member. It returns
+// ErrAlreadyInGuild if the player is in a guild.
+func (d *Database) AddGuildMember(ctx context.Context, guildID string, playerID uuid.UUID) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	_, err := d.exec(ctx,
+		"INSERT INTO guild_members (player_id, guild_id, rank, joined_at) VALUES (?, ?, ?, datetime('now'))",
+		playerID.String(), guildID, GuildRankMember,
+	)
+	if guildErr := guildConstraintError(err); guildErr != nil {
+		return guildErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add guild member: %w", err)
+	}
+	return nil
+}
+
+// LeaveGuild removes a player from their guild and returns its ID, or ""
+// if the player was in none. A leaving leader is succeeded by the earliest
+// officer, or else the earliest member, and the last member to leave
+// disbands the guild.
+func (d *Database) LeaveGuild(ctx context.Context, playerID uuid.UUID) (string, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return "", err
+	}
+
+	var guildID string
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		var rank string
+		err := tx.QueryRow("SELECT guild_id, rank FROM guild_members WHERE player_id = ?", playerID.String()).Scan(&guildID, &rank)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM guild_members WHERE player_id = ?", playerID.String()); err != nil {
+			return err
+		}
+		if rank != GuildRankLeader {
+			return nil
+		}
+
+		var successor string
+		err = tx.QueryRow(`
+			SELECT player_id FROM guild_members
+			WHERE guild_id = ?
+			ORDER BY rank = 'officer' DESC, joined_at, rowid
+			LIMIT 1
+		`, guildID).Scan(&successor)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec("DELETE FROM guilds WHERE id = ?", guildID)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE guild_members SET rank = ? WHERE player_id = ?", GuildRankLeader, successor)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to leave guild: %w", err)
+	}
+	return guildID, nil
+}
+
+// SetGuildRanks changes the ranks of members of a guild together.
+func (d *Database) SetGuildRanks(ctx context.Context, guildID string, ranks map[uuid.UUID]string) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		for playerID, rank := range ranks {
+			_, err := tx.Exec("UPDATE guild_members SET rank = ? WHERE player_id = ? AND guild_id = ?", rank, playerID.String(), guildID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set guild ranks: %w", err)
+	}
+	return nil
+}
+
+// GetGuildLeaderboard ranks guilds by the sum of their members' scores.
+func (d *Database) GetGuildLeaderboard(ctx context.Context, limit int) ([]GuildScore, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT g.id, g.name, g.tag, COUNT(m.player_id), COALESCE(SUM(p.score), 0)
+		FROM guilds g
+		JOIN guild_members m ON m.guild_id = g.id
+		LEFT JOIN players p ON p.id = m.player_id
+		GROUP BY g.id
+		ORDER BY 5 DESC, g.created_at ASC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	scores := []GuildScore{}
+	for rows.Next() {
+		var score GuildScore
+		if err := rows.Scan(&score.GuildID, &score.Name, &score.Tag, &score.Members, &score.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan guild score: %w", err)
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, rows.Err()
+}
+
+// mailColumns are scanned by scanMail.
+const mailColumns = `m.id, m.sender_id, COALESCE(p.name, ''), m.recipient_id, m.subject, m.body, m.sent_at, m.read_at`
+
+func scanMail(rows *sql.Rows) ([]Mail, error) {
+	var mail []Mail
+	for rows.Next() {
+		var item Mail
+		var readAt sql.NullTime
+		err := rows.Scan(&item.ID, &item.SenderID, &item.SenderName, &item.RecipientID, &item.Subject, &item.Body, &item.SentAt, &readAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan mail: %w", err)
+		}
+		if readAt.Valid {
+			item.ReadAt = &readAt.Time
+		}
+		mail = append(mail, item)
+	}
+	return mail, rows.Err()
+}
+
+// SendMail stores mail for its recipient and sets its ID. It reports false,
+// storing nothing, if the recipient already holds maxMailbox messages.
+func (d *Database) SendMail(ctx context.Context, mail *Mail, maxMailbox int) (bool, error) {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return false, err
+	}
+
+	sent := false
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		var held int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM mail WHERE recipient_id = ?", mail.RecipientID).Scan(&held); err != nil {
+			return err
+		}
+		if held >= maxMailbox {
+			return nil
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO mail (sender_id, recipient_id, subject, body, sent_at) VALUES (?, ?, ?, ?, datetime('now'))",
+			mail.SenderID, mail.RecipientID, mail.Subject, mail.Body,
+		)
+		if err != nil {
+			return err
+		}
+		mail.ID, err = result.LastInsertId()
+		sent = err == nil
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	mail.SentAt = time.Now().UTC()
+	return sent, nil
+}
+
+// GetMail returns the player's newest mail first.
+func (d *Database) GetMail(ctx context.Context, playerID uuid.UUID, limit int) ([]Mail, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + mailColumns + `
+		FROM mail m LEFT JOIN players p ON p.id = m.sender_id
+		WHERE m.recipient_id = ?
+		ORDER BY m.id DESC
+		LIMIT ?
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, playerID.String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mail: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMail(rows)
+}
+
+// TakeUndeliveredMail returns the mail the player has not been sent a
+// MailReceived for, oldest first, and marks it delivered.
+func (d *Database) TakeUndeliveredMail(ctx context.Context, playerID uuid.UUID) ([]Mail, error) {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return nil, err
+	}
+
+	var mail []Mail
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			SELECT `+mailColumns+`
+			FROM mail m LEFT JOIN players p ON p.id = m.sender_id
+			WHERE m.recipient_id = ? AND m.delivered_at IS NULL
+			ORDER BY m.id
+		`, playerID.String())
+		if err != nil {
+			return err
+		}
+		mail, err = scanMail(rows)
+		rows.Close()
+		if err != nil || len(mail) == 0 {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE mail SET delivered_at = datetime('now') WHERE recipient_id = ? AND delivered_at IS NULL AND id <= ?", playerID.String(), mail[len(mail)-1].ID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to take undelivered mail: %w", err)
+	}
+	return mail, nil
+}
+
+// MarkMailDelivered records that MailReceived reached the recipient.
+func (d *Database) MarkMailDelivered(ctx context.Context, mailID int64) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	_, err := d.exec(ctx, "UPDATE mail SET delivered_at = datetime('now') WHERE id = ? AND delivered_at IS NULL", mailID)
+	if err != nil {
+		return fmt.Errorf("failed to mark mail delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkMailRead marks mail the player received as read. It reports false if
+// the player has no such mail.
+func (d *Database) MarkMailRead(ctx context.Context, playerID uuid.UUID, mailID int64) (bool, error) {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return false, err
+	}
+
+	result, err := d.exec(ctx,
+		"UPDATE mail SET read_at = COALESCE(read_at, datetime('now')), delivered_at = COALESCE(delivered_at, datetime('now')) WHERE id = ? AND recipient_id = ?",
+		mailID, playerID.String(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark mail read: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// DeleteMail deletes mail the player received. It reports false if the
+// player has no such mail.
+func (d *Database) DeleteMail(ctx context.Context, playerID uuid.UUID, mailID int64) (bool, error) {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return false, err
+	}
+
+	result, err := d.exec(ctx, "DELETE FROM mail WHERE id = ? AND recipient_id = ?", mailID, playerID.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to delete mail: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// Account is a registered user. Its ID is the player ID it plays as.
+type Account struct {
+	ID           uuid.UUID `json:"player_id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"` // empty for accounts that only log in with OAuth
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AccountIdentity is an OAuth provider's user linked to an account.
+type AccountIdentity struct {
+	Provider string
+	Subject  string
+}
+
+// ErrUsernameTaken rejects an account whose username is in use.
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// CreateAccount creates an account, its player row and, if identity is not
+// nil, its OAuth identity. It returns ErrUsernameTaken if the username is
+// in use, ignoring case.
+func (d *Database) CreateAccount(ctx context.Context, account *Account, identity *AccountIdentity) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	var passwordHash interface{}
+	if account.PasswordHash != "" {
+		passwordHash = account.PasswordHash
+	}
+
+	if account.Role == "" {
+		account.Role = DefaultRole
+	}
+
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO players (id, name, updated_at, last_seen_at)
+			VALUES (?, ?, datetime('now'), datetime('now'))
+			ON CONFLICT(id) DO NOTHING
+		`, account.ID.String(), account.Username)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO accounts (id, username, password_hash, role, created_at) VALUES (?, ?, ?, ?, datetime('now'))",
+			account.ID.String(), account.Username, passwordHash, account.Role,
+		)
+		if err != nil {
+			return err
+		}
+
+		if identity != nil {
+			_, err = tx.Exec(
+				"INSERT INTO account_identities (provider, subject, account_id, created_at) VALUES (?, ?, ?, datetime('now'))",
+				identity.Provider, identity.Subject, account.ID.String(),
+			)
+		}
+		return err
+	})
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "accounts.username") {
+		return ErrUsernameTaken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+
+	account.CreatedAt = time.Now().UTC()
+	d.players.Invalidate(account.ID)
+	logrus.Infof("Account %s created for player %s", account.Username, account.ID)
+	return nil
+}
+
+// GetAccount returns the account that owns a player ID, or nil if the
+// player has none.
+func (d *Database) GetAccount(ctx context.Context, accountID uuid.UUID) (*Account, error) {
+	return d.queryAccount(ctx, "WHERE id = ?", accountID.String())
+}
+
+// GetAccountByUsername returns the account, ignoring case, or nil if there
+// is none.
+func (d *Database) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	return d.queryAccount(ctx, "WHERE username = ?", username)
+}
+
+// GetAccountByIdentity returns the account the OAuth identity logs in to, or
+// nil if there is none.
+func (d *Database) GetAccountByIdentity(ctx context.Context, identity AccountIdentity) (*Account, error) {
+	return d.queryAccount(ctx,
+		"WHERE id = (SELECT account_id FROM account_identities WHERE provider = ? AND subject = ?)",
+		identity.Provider, identity.Subject,
+	)
+}
+
+// CreateAuthToken stores a login token by its hash.
+func (d *Database) CreateAuthToken(ctx context.Context, tokenHash string, accountID uuid.UUID, expiresAt time.Time) error {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO auth_tokens (token_hash, account_id, created_at, expires_at)
+		VALUES (?, ?, datetime('now'), ?)
+	`
+
+	if _, err := d.exec(ctx, query, tokenHash, accountID.String(), expiresAt.UTC().Format("2006-01-02 15:04:05")); err != nil {
+		return fmt.Errorf("failed to create auth token: %w", err)
+	}
+	return nil
+}
+
+// GetAccountByToken returns the account an unexpired token belongs to, or
+// nil if there is none.
+func (d *Database) GetAccountByToken(ctx context.Context, tokenHash string) (*Account, error) {
+	return d.queryAccount(ctx,
+		"WHERE id = (SELECT account_id FROM auth_tokens WHERE token_hash = ? AND expires_at > datetime('now'))",
+		tokenHash,
+	)
+}
+
+// DeleteAuthToken revokes a token and removes the account's expired ones.
+func (d *Database) DeleteAuthToken(ctx context.Context, tokenHash string) error {
+	if err := d.acquire(ctx, WriteNormal); err != nil {
+		return err
+	}
+
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			DELETE FROM auth_tokens
+			WHERE account_id = (SELECT account_id FROM auth_tokens WHERE token_hash = ?)
+				AND expires_at <= datetime('now')
+		`, tokenHash); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM auth_tokens WHERE token_hash = ?", tokenHash)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete auth token: %w", err)
+	}
+	return nil
+}
+
+// SetAccountRole changes the role of an account. It reports false if there
+// is no such account.
+func (d *Database) SetAccountRole(ctx context.Context, accountID uuid.UUID, role string) (bool, error) {
+	if err := d.acquire(ctx, WriteCritical); err != nil {
+		return false, err
+	}
+
+	result, err := d.exec(ctx, "UPDATE accounts SET role = ? WHERE id = ?", role, accountID.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to set account role: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (d *Database) queryAccount(ctx context.Context, where string, args ...interface{}) (*Account, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, password_hash, role, created_at FROM accounts " + where
+
+	var account Account
+	var id string
+	var passwordHash sql.NullString
+	err := d.db.QueryRowContext(ctx, query, args...).Scan(&id, &account.Username, &passwordHash, &account.Role, &account.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	account.ID, err = uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse account ID: %w", err)
+	}
+	account.PasswordHash = passwordHash.String
+	return &account, nil
+}
+
+func (d *Database) Close() error {
+	if d.writer != nil {
+		close(d.stopFlush)
+		<-d.flushDone
+		d.writer.Close()
+	}
+	return d.db.Close()
+}
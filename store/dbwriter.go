@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dbWriteQueueSize is how many writes may wait for the writer before
+	// callers block.
+	dbWriteQueueSize = 1024
+	// dbWriteBatchSize bounds the writes committed in one transaction.
+	dbWriteBatchSize = 128
+)
+
+var errDBWriterClosed = errors.New("database writer is closed")
+
+// DBWriter runs every write on one goroutine, so that SQLite never sees two
+// writers competing for its lock. Writes waiting in the queue are committed
+// together in one transaction, each inside a savepoint so that a failed
+// write is rolled back alone. Reads do not go through the writer.
+//
+// A write whose context ends while it is queued is skipped. Once it has
+// started it runs to completion: interrupting SQLite mid-statement would roll
+// back the whole batch, including other callers' writes.
+type DBWriter struct {
+	db       *sql.DB
+	requests chan *writeRequest
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type writeRequest struct {
+	ctx    context.Context
+	fn     func(tx *sql.Tx) error
+	result chan error
+}
+
+func NewDBWriter(db *sql.DB) *DBWriter {
+	w := &DBWriter{
+		db:       db,
+		requests: make(chan *writeRequest, dbWriteQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Do runs fn in the writer's transaction and waits until it is committed or
+// ctx ends. A write the caller stopped waiting for may still be committed.
+func (w *DBWriter) Do(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	request := &writeRequest{ctx: ctx, fn: fn, result: make(chan error, 1)}
+	select {
+	case w.requests <- request:
+	case <-w.stop:
+		return errDBWriterClosed
+	case <-ctx.Done():
+		metrics.Inc("db_write_timeouts")
+		return fmt.Errorf("failed to queue write: %w", ctx.Err())
+	}
+	metrics.SetGauge("db_write_queue_depth", float64(len(w.requests)))
+
+	select {
+	case err := <-request.result:
+		return err
+	case <-w.done:
+		return errDBWriterClosed
+	case <-ctx.Done():
+		metrics.Inc("db_write_timeouts")
+		return fmt.Errorf("write did not complete: %w", ctx.Err())
+	}
+}
+
+// Close commits the queued writes and stops the writer.
+func (w *DBWriter) Close() {
+	close(w.stop)
+	<-w.done
+}
+
+func (w *DBWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case request := <-w.requests:
+			w.commit(w.fill([]*writeRequest{request}))
+		case <-w.stop:
+			for len(w.requests) > 0 {
+				w.commit(w.fill(nil))
+			}
+			return
+		}
+	}
+}
+
+// fill adds the writes already queued to a batch.
+func (w *DBWriter) fill(batch []*writeRequest) []*writeRequest {
+	for len(batch) < dbWriteBatchSize {
+		select {
+		case request := <-w.requests:
+			batch = append(batch, request)
+		default:
+			metrics.SetGauge("db_write_queue_depth", float64(len(w.requests)))
+			return batch
+		}
+	}
+	metrics.SetGauge("db_write_queue_depth", float64(len(w.requests)))
+	return batch
+}
+
+func (w *DBWriter) commit(batch []*writeRequest) {
+	errs := make([]error, len(batch))
+	defer func() {
+		for i, request := range batch {
+			request.result <- errs[i]
+		}
+	}()
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin write transaction: %w", err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return
+	}
+
+	for i, request := range batch {
+		if err := request.ctx.Err(); err != nil {
+			errs[i] = fmt.Errorf("write skipped: %w", err)
+			continue
+		}
+		errs[i] = applyWrite(tx, request.fn)
+	}
+
+	if err := tx.Commit(); err != nil {
+		err = fmt.Errorf("failed to commit write transaction: %w", err)
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+		metrics.Inc("db_write_commit_failures")
+		return
+	}
+
+	metrics.Inc("db_write_batches")
+	metrics.Add("db_writes_batched", int64(len(batch)))
+	metrics.SetGauge("db_write_last_batch_size", float64(len(batch)))
+}
+
+// applyWrite runs fn inside a savepoint, undoing its writes if it fails.
+func applyWrite(tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.Exec("SAVEPOINT write"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		if _, rollbackErr := tx.Exec("ROLLBACK TO write"); rollbackErr != nil {
+			logrus.Errorf("Failed to roll back failed write: %v", rollbackErr)
+		}
+		if _, releaseErr := tx.Exec("RELEASE write"); releaseErr != nil {
+			logrus.Errorf("Failed to release savepoint: %v", releaseErr)
+		}
+		return err
+	}
+	if _, err := tx.Exec("RELEASE write"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,1452 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store that keeps everything in memory, for running game
+// logic without a database file. It follows the SQLite implementation's
+// rules, including privacy settings, but not its write budget. Nothing in it
+// waits, so contexts are ignored. It is safe for concurrent use.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	serverID  string
+	players   map[uuid.UUID]*memPlayer
+	sessions  map[int64]*memSession
+	events    []memEvent
+	chat      []ChatMessage
+	scores    []HighScore
+	rollups   map[LeaderboardPeriod]map[uuid.UUID]*memRollup
+	stats     map[uuid.UUID]*PlayerStats
+	matches   map[int64]string // match ID -> server ID
+	ratings   []RatingHistoryEntry
+	results   map[int64][]MatchResult // final scoreboard by match ID
+	friends   map[[2]uuid.UUID]string // (requester, recipient) -> status
+	inventory map[uuid.UUID]map[string]int64
+	xpRules   map[string]int64
+	bans      map[uuid.UUID]Ban
+	audit     []AuditEntry
+	reports   []*PlayerReport
+	instances map[string]ServerInstance
+	privacy   map[uuid.UUID]PrivacySettings
+	prefs     map[uuid.UUID]map[string]string
+	guilds    map[string]*Guild
+	members   map[uuid.UUID]*memGuildMember
+	mail      []*memMail
+	worlds    map[string][]byte // world snapshot by server ID
+	nextID    int64
+
+	accounts   map[uuid.UUID]*Account
+	identities map[AccountIdentity]uuid.UUID
+	tokens     map[string]memToken // keyed by token hash
+}
+
+type memPlayer struct {
+	DBPlayer
+	xp    int64
+	level int
+	zone  string
+}
+
+type memEvent struct {
+	PlayerEvent
+	serverID string
+}
+
+type memSession struct {
+	GameSession
+	serverID string
+}
+
+type memToken struct {
+	accountID uuid.UUID
+	expiresAt time.Time
+}
+
+type memGuildMember struct {
+	guildID string
+	rank    string
+	joined  int64 // from id(), orders members who joined in the same second
+	at      time.Time
+}
+
+type memMail struct {
+	Mail
+	delivered bool
+}
+
+type memRollup struct {
+	score   int64
+	updated time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	xpRules := make(map[string]int64, len(DefaultXPRules))
+	for action, xp := range DefaultXPRules {
+		xpRules[action] = xp
+	}
+	return &MemoryStore{
+		players:   make(map[uuid.UUID]*memPlayer),
+		sessions:  make(map[int64]*memSession),
+		rollups:   make(map[LeaderboardPeriod]map[uuid.UUID]*memRollup),
+		stats:     make(map[uuid.UUID]*PlayerStats),
+		matches:   make(map[int64]string),
+		friends:   make(map[[2]uuid.UUID]string),
+		inventory: make(map[uuid.UUID]map[string]int64),
+		xpRules:   xpRules,
+		bans:      make(map[uuid.UUID]Ban),
+		instances: make(map[string]ServerInstance),
+		privacy:   make(map[uuid.UUID]PrivacySettings),
+		prefs:     make(map[uuid.UUID]map[string]string),
+		results:   make(map[int64][]MatchResult),
+		guilds:    make(map[string]*Guild),
+		members:   make(map[uuid.UUID]*memGuildMember),
+		worlds:    make(map[string][]byte),
+
+		accounts:   make(map[uuid.UUID]*Account),
+		identities: make(map[AccountIdentity]uuid.UUID),
+		tokens:     make(map[string]memToken),
+	}
+}
+
+// SetServerID tags sessions and matches created from now on, like
+// Database.SetServerID.
+func (m *MemoryStore) SetServerID(serverID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.serverID = serverID
+}
+
+func (m *MemoryStore) id() int64 {
+	m.nextID++
+	return m.nextID
+}
+
+func (m *MemoryStore) SchemaVersion(ctx context.Context) (string, error) {
+	migrationFiles, err := ListMigrationFiles()
+	if err != nil || len(migrationFiles) == 0 {
+		return "", err
+	}
+	return filepath.Base(migrationFiles[len(migrationFiles)-1]), nil
+}
+
+func (m *MemoryStore) CreateOrUpdatePlayer(ctx context.Context, player PlayerState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	stored, exists := m.players[player.ID]
+	if !exists {
+		stored = &memPlayer{
+			DBPlayer: DBPlayer{ID: player.ID.String(), Rating: DefaultRating, CreatedAt: now},
+			level:    1,
+		}
+		m.players[player.ID] = stored
+	}
+	stored.Name = player.Name
+	stored.X, stored.Y = float64(player.X), float64(player.Y)
+	stored.Health = float64(player.Health)
+	stored.Score = int64(player.Score)
+	stored.UpdatedAt, stored.LastSeenAt = now, now
+	return nil
+}
+
+func (m *MemoryStore) GetPlayer(ctx context.Context, playerID uuid.UUID) (*DBPlayer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, exists := m.players[playerID]
+	if !exists {
+		return nil, nil
+	}
+	player := stored.DBPlayer
+	return &player, nil
+}
+
+// updatePlayer applies change to an existing player, like an UPDATE that
+// matches no row when the player is unknown.
+func (m *MemoryStore) updatePlayer(playerID uuid.UUID, change func(player *memPlayer)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, exists := m.players[playerID]; exists {
+		change(stored)
+		stored.UpdatedAt = time.Now().UTC()
+		stored.LastSeenAt = stored.UpdatedAt
+	}
+}
+
+func (m *MemoryStore) UpdatePlayerPosition(ctx context.Context, playerID uuid.UUID, x, y float32) error {
+	m.updatePlayer(playerID, func(player *memPlayer) {
+		player.X, player.Y = float64(x), float64(y)
+	})
+	return nil
+}
+
+func (m *MemoryStore) UpdatePlayerScore(ctx context.Context, playerID uuid.UUID, score uint32) error {
+	m.updatePlayer(playerID, func(player *memPlayer) {
+		player.Score = int64(score)
+	})
+	return nil
+}
+
+func (m *MemoryStore) UpdatePlayerHealth(ctx context.Context, playerID uuid.UUID, health float32) error {
+	m.updatePlayer(playerID, func(player *memPlayer) {
+		player.Health = float64(health)
+	})
+	return nil
+}
+
+// InvalidatePlayer does nothing; there is no cache in front of the players.
+func (m *MemoryStore) InvalidatePlayer(playerID uuid.UUID) {}
+
+// sortedPlayers returns up to limit players ordered by less.
+func (m *MemoryStore) sortedPlayers(limit int, less func(a, b *DBPlayer) bool) []DBPlayer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var players []DBPlayer
+	for _, stored := range m.players {
+		players = append(players, stored.DBPlayer)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return less(&players[i], &players[j])
+	})
+	if len(players) > limit {
+		players = players[:limit]
+	}
+	return players
+}
+
+func (m *MemoryStore) GetTopPlayers(ctx context.Context, limit int) ([]DBPlayer, error) {
+	return m.sortedPlayers(limit, func(a, b *DBPlayer) bool {
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}), nil
+}
+
+func (m *MemoryStore) GetPlayerCount(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.players)), nil
+}
+
+func (m *MemoryStore) CreateSession(ctx context.Context, playerID uuid.UUID, protocol string, clientIP *string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.privacy[playerID].NoIPStorage {
+		clientIP = nil
+	}
+	sessionID := m.id()
+	m.sessions[sessionID] = &memSession{
+		GameSession: GameSession{
+			ID:           sessionID,
+			PlayerID:     playerID.String(),
+			SessionStart: time.Now().UTC(),
+			Protocol:     protocol,
+			ClientIP:     clientIP,
+		},
+		serverID: m.serverID,
+	}
+	return sessionID, nil
+}
+
+func (m *MemoryStore) EndSession(ctx context.Context, sessionID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endSessionLocked(sessionID, time.Now().UTC())
+	return nil
+}
+
+// endSessionLocked closes an open session at end and adds its playtime.
+func (m *MemoryStore) endSessionLocked(sessionID int64, end time.Time) bool {
+	session, exists := m.sessions[sessionID]
+	if !exists || session.SessionEnd != nil {
+		return false
+	}
+	session.SessionEnd = &end
+
+	playerID, err := uuid.Parse(session.PlayerID)
+	if err != nil {
+		return true
+	}
+	if seconds := int64(end.Sub(session.SessionStart).Seconds()); seconds > 0 {
+		m.statsLocked(playerID).PlaytimeSeconds += seconds
+	}
+	return true
+}
+
+func (m *MemoryStore) GetActiveSessionsCount(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for _, session := range m.sessions {
+		if session.SessionEnd == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) CleanupOldSessions(ctx context.Context, hours int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	var closed int64
+	for _, session := range m.sessions {
+		if session.SessionEnd == nil && session.SessionStart.Add(time.Duration(hours)*time.Hour).Before(now) {
+			// Stale sessions are closed without adding playtime
+			session.SessionEnd = &now
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+func (m *MemoryStore) CloseOrphanedSessions(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	var orphans []*memSession
+	for _, session := range m.sessions {
+		if session.serverID == m.serverID && session.SessionEnd == nil {
+			orphans = append(orphans, session)
+		}
+	}
+
+	var closed []*memSession
+	for _, session := range orphans {
+		end := session.SessionStart
+		for _, event := range m.events {
+			if event.SessionID != nil && *event.SessionID == session.ID && event.Timestamp.After(end) {
+				end = event.Timestamp
+			}
+		}
+		if m.endSessionLocked(session.ID, end) {
+			closed = append(closed, session)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, session := range closed {
+		if playerID, err := uuid.Parse(session.PlayerID); err == nil {
+			sessionID := session.ID
+			m.LogEvent(ctx, playerID, &sessionID, "session_end", newSessionEndEvent(sessionID, "server_restart"))
+		}
+	}
+	return int64(len(closed)), nil
+}
+
+func (m *MemoryStore) LogEvent(ctx context.Context, playerID uuid.UUID, sessionID *int64, eventType string, eventData interface{}) error {
+	var eventDataJSON *string
+	if eventData != nil {
+		data, err := json.Marshal(eventData)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event data: %w", err)
+		}
+		jsonStr := string(data)
+		eventDataJSON = &jsonStr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	privacy := m.privacy[playerID]
+	if privacy.NoChatLog && eventType == "chat" {
+		eventDataJSON = nil
+	}
+	if privacy.AnonymizeEvents && eventDataJSON != nil {
+		anonymized, err := anonymizeEventData(*eventDataJSON, playerID)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize event data: %w", err)
+		}
+		eventDataJSON = &anonymized
+	}
+	if privacy.AnonymizeEvents {
+		playerID = anonymousPlayerID
+		sessionID = nil
+	}
+
+	m.events = append(m.events, memEvent{
+		PlayerEvent: PlayerEvent{
+			ID:        m.id(),
+			PlayerID:  playerID.String(),
+			SessionID: sessionID,
+			EventType: eventType,
+			EventData: eventDataJSON,
+			Timestamp: time.Now().UTC(),
+		},
+		serverID: m.serverID,
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetPlayerEvents(ctx context.Context, playerID uuid.UUID, limit int) ([]PlayerEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []PlayerEvent
+	for i := len(m.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if m.events[i].PlayerID == playerID.String() {
+			events = append(events, m.events[i].PlayerEvent)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) SaveChatMessage(ctx context.Context, playerID uuid.UUID, sessionID *int64, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.privacy[playerID].NoChatLog {
+		message = redactedChatMessage
+	}
+	m.chat = append(m.chat, ChatMessage{
+		ID:        m.id(),
+		PlayerID:  playerID.String(),
+		SessionID: sessionID,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetRecentChatMessages(ctx context.Context, limit int) ([]ChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []ChatMessage
+	for i := len(m.chat) - 1; i >= 0 && len(messages) < limit; i-- {
+		messages = append(messages, m.chat[i])
+	}
+	return messages, nil
+}
+
+func (m *MemoryStore) SaveHighScore(ctx context.Context, playerID uuid.UUID, score uint32, gameDuration *uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var duration *int64
+	if gameDuration != nil {
+		d := int64(*gameDuration)
+		duration = &d
+	}
+	m.scores = append(m.scores, HighScore{
+		ID:           m.id(),
+		PlayerID:     playerID.String(),
+		Score:        int64(score),
+		AchievedAt:   time.Now().UTC(),
+		GameDuration: duration,
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetHighScores(ctx context.Context, limit int) ([]HighScore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var scores []HighScore
+	for _, score := range m.scores {
+		if playerID, err := uuid.Parse(score.PlayerID); err == nil && m.players[playerID] != nil {
+			scores = append(scores, score)
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].AchievedAt.After(scores[j].AchievedAt)
+	})
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+func (m *MemoryStore) AddLeaderboardPoints(ctx context.Context, playerID uuid.UUID, points int64, periods []LeaderboardPeriod) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, period := range periods {
+		window, exists := m.rollups[period]
+		if !exists {
+			window = make(map[uuid.UUID]*memRollup)
+			m.rollups[period] = window
+		}
+		rollup, exists := window[playerID]
+		if !exists {
+			rollup = &memRollup{}
+			window[playerID] = rollup
+		}
+		rollup.score += points
+		rollup.updated = now
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetWindowedLeaderboard(ctx context.Context, period LeaderboardPeriod, limit int) ([]WindowedScore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type entry struct {
+		score   WindowedScore
+		updated time.Time
+	}
+	var entries []entry
+	for playerID, rollup := range m.rollups[period] {
+		player, exists := m.players[playerID]
+		if !exists {
+			continue
+		}
+		entries = append(entries, entry{
+			score:   WindowedScore{PlayerID: playerID.String(), Name: player.Name, Score: rollup.score},
+			updated: rollup.updated,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].score.Score != entries[j].score.Score {
+			return entries[i].score.Score > entries[j].score.Score
+		}
+		return entries[i].updated.Before(entries[j].updated)
+	})
+
+	scores := []WindowedScore{}
+	for _, entry := range entries {
+		if len(scores) == limit {
+			break
+		}
+		scores = append(scores, entry.score)
+	}
+	return scores, nil
+}
+
+// statsLocked returns the player's stats row, creating it if needed.
+func (m *MemoryStore) statsLocked(playerID uuid.UUID) *PlayerStats {
+	stats, exists := m.stats[playerID]
+	if !exists {
+		stats = &PlayerStats{PlayerID: playerID.String()}
+		m.stats[playerID] = stats
+	}
+	stats.UpdatedAt = time.Now().UTC()
+	return stats
+}
+
+func (m *MemoryStore) AddPlayerStats(ctx context.Context, playerID uuid.UUID, delta PlayerStatsDelta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.statsLocked(playerID)
+	stats.Kills += delta.Kills
+	stats.Deaths += delta.Deaths
+	stats.Assists += delta.Assists
+	stats.ItemsCollected += delta.ItemsCollected
+	return nil
+}
+
+func (m *MemoryStore) GetPlayerStats(ctx context.Context, playerID uuid.UUID) (*PlayerStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, exists := m.stats[playerID]
+	if !exists {
+		return nil, nil
+	}
+	copied := *stats
+	return &copied, nil
+}
+
+func (m *MemoryStore) GetTopPlayerStats(ctx context.Context, orderBy string, limit int) ([]PlayerStats, error) {
+	columns := map[string]func(stats *PlayerStats) int64{
+		"kills":            func(stats *PlayerStats) int64 { return stats.Kills },
+		"deaths":           func(stats *PlayerStats) int64 { return stats.Deaths },
+		"assists":          func(stats *PlayerStats) int64 { return stats.Assists },
+		"items_collected":  func(stats *PlayerStats) int64 { return stats.ItemsCollected },
+		"playtime_seconds": func(stats *PlayerStats) int64 { return stats.PlaytimeSeconds },
+	}
+	column, valid := columns[orderBy]
+	if !valid {
+		return nil, fmt.Errorf("invalid stats column: %s", orderBy)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var statsList []PlayerStats
+	for _, stats := range m.stats {
+		statsList = append(statsList, *stats)
+	}
+	sort.Slice(statsList, func(i, j int) bool {
+		if a, b := column(&statsList[i]), column(&statsList[j]); a != b {
+			return a > b
+		}
+		return statsList[i].UpdatedAt.After(statsList[j].UpdatedAt)
+	})
+	if len(statsList) > limit {
+		statsList = statsList[:limit]
+	}
+	return statsList, nil
+}
+
+func (m *MemoryStore) CreateMatch(ctx context.Context, protocol string, seed int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	matchID := m.id()
+	m.matches[matchID] = m.serverID
+	return matchID, nil
+}
+
+func (m *MemoryStore) EndMatch(ctx context.Context, matchID int64, playerCount int) error {
+	return nil
+}
+
+func (m *MemoryStore) GetPlayerRating(ctx context.Context, playerID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if player, exists := m.players[playerID]; exists {
+		return player.Rating, nil
+	}
+	return DefaultRating, nil
+}
+
+func (m *MemoryStore) ApplyRatingChanges(ctx context.Context, matchID *int64, changes []RatingChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		if player, exists := m.players[change.PlayerID]; exists {
+			player.Rating = change.NewRating
+			player.UpdatedAt = now
+		}
+		m.ratings = append(m.ratings, RatingHistoryEntry{
+			ID:         m.id(),
+			PlayerID:   change.PlayerID.String(),
+			MatchID:    matchID,
+			OldRating:  change.OldRating,
+			NewRating:  change.NewRating,
+			Placement:  change.Placement,
+			RecordedAt: now,
+		})
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetRatingHistory(ctx context.Context, playerID uuid.UUID, limit int) ([]RatingHistoryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var history []RatingHistoryEntry
+	for i := len(m.ratings) - 1; i >= 0 && len(history) < limit; i-- {
+		if m.ratings[i].PlayerID == playerID.String() {
+			history = append(history, m.ratings[i])
+		}
+	}
+	return history, nil
+}
+
+func (m *MemoryStore) SaveMatchResults(ctx context.Context, matchID int64, results []MatchResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[matchID] = append(m.results[matchID], results...)
+	return nil
+}
+
+func (m *MemoryStore) GetMatchResults(ctx context.Context, matchID int64) ([]MatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MatchResult(nil), m.results[matchID]...), nil
+}
+
+func (m *MemoryStore) GetTopRatedPlayers(ctx context.Context, limit int) ([]DBPlayer, error) {
+	return m.sortedPlayers(limit, func(a, b *DBPlayer) bool {
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}), nil
+}
+
+func (m *MemoryStore) CreateFriendRequest(ctx context.Context, playerID, friendID uuid.UUID) (string, error) {
+	accepted, err := m.AcceptFriendRequest(ctx, friendID, playerID)
+	if err != nil {
+		return "", err
+	}
+	if accepted {
+		return "accepted", nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]uuid.UUID{playerID, friendID}
+	if _, exists := m.friends[key]; !exists {
+		m.friends[key] = "pending"
+	}
+	return "pending", nil
+}
+
+func (m *MemoryStore) AcceptFriendRequest(ctx context.Context, requesterID, recipientID uuid.UUID) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]uuid.UUID{requesterID, recipientID}
+	if m.friends[key] != "pending" {
+		return false, nil
+	}
+	m.friends[key] = "accepted"
+	return true, nil
+}
+
+func (m *MemoryStore) DeleteFriendship(ctx context.Context, playerID, friendID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.friends, [2]uuid.UUID{playerID, friendID})
+	delete(m.friends, [2]uuid.UUID{friendID, playerID})
+	return nil
+}
+
+func (m *MemoryStore) GetFriends(ctx context.Context, playerID uuid.UUID) ([]Friendship, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var friends []Friendship
+	for key, status := range m.friends {
+		var friendID uuid.UUID
+		incoming := false
+		switch playerID {
+		case key[0]:
+			friendID = key[1]
+		case key[1]:
+			friendID, incoming = key[0], true
+		default:
+			continue
+		}
+		friend, exists := m.players[friendID]
+		if !exists {
+			continue
+		}
+		friends = append(friends, Friendship{
+			FriendID: friendID.String(),
+			Name:     friend.Name,
+			Status:   status,
+			Incoming: incoming,
+		})
+	}
+	sort.Slice(friends, func(i, j int) bool {
+		if friends[i].Status != friends[j].Status {
+			return friends[i].Status < friends[j].Status
+		}
+		return friends[i].Name < friends[j].Name
+	})
+	return friends, nil
+}
+
+func (m *MemoryStore) GetAcceptedFriendIDs(ctx context.Context, playerID uuid.UUID) ([]uuid.UUID, error) {
+	friends, err := m.GetFriends(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	for _, friend := range friends {
+		if friend.Status != "accepted" {
+			continue
+		}
+		if id, err := uuid.Parse(friend.FriendID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MemoryStore) AddInventoryItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity, maxStack int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items, exists := m.inventory[playerID]
+	if !exists {
+		items = make(map[string]int64)
+		m.inventory[playerID] = items
+	}
+	items[itemType] += quantity
+	if items[itemType] > maxStack {
+		items[itemType] = maxStack
+	}
+	return nil
+}
+
+func (m *MemoryStore) RemoveInventoryItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := m.inventory[playerID]
+	held, exists := items[itemType]
+	if !exists || held < quantity {
+		return false, nil
+	}
+	if held == quantity {
+		delete(items, itemType)
+	} else {
+		items[itemType] = held - quantity
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) GetInventory(ctx context.Context, playerID uuid.UUID) ([]InventoryItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := []InventoryItem{}
+	for itemType, quantity := range m.inventory[playerID] {
+		if quantity > 0 {
+			items = append(items, InventoryItem{ItemType: itemType, Quantity: quantity})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ItemType < items[j].ItemType
+	})
+	return items, nil
+}
+
+func (m *MemoryStore) GetXPRules(ctx context.Context) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make(map[string]int64, len(m.xpRules))
+	for action, xp := range m.xpRules {
+		rules[action] = xp
+	}
+	return rules, nil
+}
+
+func (m *MemoryStore) GetPlayerProgress(ctx context.Context, playerID uuid.UUID) (int64, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if player, exists := m.players[playerID]; exists {
+		return player.xp, player.level, nil
+	}
+	return 0, 1, nil
+}
+
+func (m *MemoryStore) UpdatePlayerProgress(ctx context.Context, playerID uuid.UUID, xp int64, level int) error {
+	m.updatePlayer(playerID, func(player *memPlayer) {
+		player.xp, player.level = xp, level
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetPlayerZone(ctx context.Context, playerID uuid.UUID) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if player, exists := m.players[playerID]; exists {
+		return player.zone, nil
+	}
+	return "", nil
+}
+
+func (m *MemoryStore) UpdatePlayerZone(ctx context.Context, playerID uuid.UUID, zone string) error {
+	m.updatePlayer(playerID, func(player *memPlayer) {
+		player.zone = zone
+	})
+	return nil
+}
+
+func (m *MemoryStore) BanPlayer(ctx context.Context, playerID uuid.UUID, reason string, expiresAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bans[playerID] = Ban{
+		PlayerID:  playerID.String(),
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	return nil
+}
+
+func (m *MemoryStore) UnbanPlayer(ctx context.Context, playerID uuid.UUID) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, banned := m.bans[playerID]
+	delete(m.bans, playerID)
+	return banned, nil
+}
+
+func (m *MemoryStore) GetActiveBan(ctx context.Context, playerID uuid.UUID) (*Ban, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ban, banned := m.bans[playerID]
+	if !banned || (ban.ExpiresAt != nil && !ban.ExpiresAt.After(time.Now())) {
+		return nil, nil
+	}
+	return &ban, nil
+}
+
+func (m *MemoryStore) RecordAdminAction(ctx context.Context, entry AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry.ID = m.id()
+	entry.CreatedAt = time.Now().UTC()
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+func (m *MemoryStore) GetAdminAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		entry := m.audit[i]
+		if (filter.Actor != "" && entry.Actor != filter.Actor) ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.Target != "" && entry.Target != filter.Target) ||
+			entry.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) CreateReport(ctx context.Context, report *PlayerReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report.ID = m.id()
+	report.CreatedAt = time.Now().UTC()
+	stored := *report
+	m.reports = append(m.reports, &stored)
+	return nil
+}
+
+func (m *MemoryStore) GetReports(ctx context.Context, filter ReportFilter) ([]PlayerReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reports []PlayerReport
+	for i := len(m.reports) - 1; i >= 0 && len(reports) < filter.Limit; i-- {
+		report := m.reports[i]
+		if (filter.ReportedID != uuid.Nil && report.ReportedID != filter.ReportedID) ||
+			(filter.Open && report.ResolvedAt != nil) ||
+			(filter.Escalated && !report.Escalated) {
+			continue
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+func (m *MemoryStore) ResolveReport(ctx context.Context, reportID int64, resolvedBy, resolution string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, report := range m.reports {
+		if report.ID == reportID && report.ResolvedAt == nil {
+			now := time.Now().UTC()
+			report.ResolvedAt = &now
+			report.ResolvedBy = resolvedBy
+			report.Resolution = resolution
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) CountReporters(ctx context.Context, reportedID uuid.UUID, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reporters := make(map[uuid.UUID]bool)
+	for _, report := range m.reports {
+		if report.ReportedID == reportedID && !report.CreatedAt.Before(since) {
+			reporters[report.ReporterID] = true
+		}
+	}
+	return len(reporters), nil
+}
+
+func (m *MemoryStore) EscalateReports(ctx context.Context, reportedID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var escalated int64
+	for _, report := range m.reports {
+		if report.ReportedID == reportedID && report.ResolvedAt == nil && !report.Escalated {
+			report.Escalated = true
+			escalated++
+		}
+	}
+	return escalated, nil
+}
+
+func (m *MemoryStore) RecordServerHeartbeat(ctx context.Context, protocol string, startedAt time.Time, playersOnline int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instances[m.serverID] = ServerInstance{
+		ServerID:      m.serverID,
+		Protocol:      protocol,
+		StartedAt:     startedAt.UTC(),
+		LastHeartbeat: time.Now().UTC(),
+		PlayersOnline: int64(playersOnline),
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetServerInstances(ctx context.Context) ([]ServerInstance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hourAgo := time.Now().Add(-time.Hour)
+	var instances []ServerInstance
+	for _, instance := range m.instances {
+		for _, session := range m.sessions {
+			if session.serverID == instance.ServerID && session.SessionEnd == nil {
+				instance.OpenSessions++
+			}
+		}
+		for _, serverID := range m.matches {
+			if serverID == instance.ServerID {
+				instance.Matches++
+			}
+		}
+		for _, event := range m.events {
+			if event.serverID == instance.ServerID && event.Timestamp.After(hourAgo) {
+				instance.EventsLastHour++
+			}
+		}
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ServerID < instances[j].ServerID
+	})
+	return instances, nil
+}
+
+func (m *MemoryStore) SaveWorldSnapshot(ctx context.Context, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.worlds[m.serverID] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *MemoryStore) GetWorldSnapshot(ctx context.Context) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.worlds[m.serverID], nil
+}
+
+func (m *MemoryStore) GetPrivacySettings(ctx context.Context, playerID uuid.UUID) (PrivacySettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.privacy[playerID], nil
+}
+
+func (m *MemoryStore) SetPrivacySettings(ctx context.Context, playerID uuid.UUID, settings PrivacySettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.privacy[playerID] = settings
+	return nil
+}
+
+func (m *MemoryStore) GetPreferences(ctx context.Context, playerID uuid.UUID) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	preferences := make(map[string]string, len(m.prefs[playerID]))
+	for name, value := range m.prefs[playerID] {
+		preferences[name] = value
+	}
+	return preferences, nil
+}
+
+func (m *MemoryStore) SetPreferences(ctx context.Context, playerID uuid.UUID, preferences map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := make(map[string]string, len(preferences))
+	for name, value := range preferences {
+		stored[name] = value
+	}
+	m.prefs[playerID] = stored
+	return nil
+}
+
+func (m *MemoryStore) CreateGuild(ctx context.Context, guild *Guild, leaderID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.guilds {
+		if strings.EqualFold(existing.Name, guild.Name) || strings.EqualFold(existing.Tag, guild.Tag) {
+			return ErrGuildTaken
+		}
+	}
+	if _, exists := m.members[leaderID]; exists {
+		return ErrAlreadyInGuild
+	}
+
+	guild.CreatedAt = time.Now().UTC()
+	stored := *guild
+	m.guilds[guild.ID] = &stored
+	m.members[leaderID] = &memGuildMember{guildID: guild.ID, rank: GuildRankLeader, joined: m.id(), at: guild.CreatedAt}
+	return nil
+}
+
+func (m *MemoryStore) GetGuildByTag(ctx context.Context, tag string) (*Guild, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, guild := range m.guilds {
+		if strings.EqualFold(guild.Tag, tag) {
+			found := *guild
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) GetPlayerGuild(ctx context.Context, playerID uuid.UUID) (*Guild, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	member, exists := m.members[playerID]
+	if !exists {
+		return nil, "", nil
+	}
+	guild := *m.guilds[member.guildID]
+	return &guild, member.rank, nil
+}
+
+func (m *MemoryStore) GetGuildMembers(ctx context.Context, guildID string) ([]GuildMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.guildMembers(guildID), nil
+}
+
+// guildMembers requires m.mu to be held by the caller.
+func (m *MemoryStore) guildMembers(guildID string) []GuildMember {
+	var ids []uuid.UUID
+	for playerID, member := range m.members {
+		if member.guildID == guildID {
+			ids = append(ids, playerID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return m.members[ids[i]].joined < m.members[ids[j]].joined })
+
+	members := make([]GuildMember, 0, len(ids))
+	for _, playerID := range ids {
+		member := GuildMember{PlayerID: playerID.String(), Rank: m.members[playerID].rank, JoinedAt: m.members[playerID].at}
+		if player, exists := m.players[playerID]; exists {
+			member.Name = player.Name
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+func (m *MemoryStore) AddGuildMember(ctx context.Context, guildID string, playerID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.members[playerID]; exists {
+		return ErrAlreadyInGuild
+	}
+	m.members[playerID] = &memGuildMember{guildID: guildID, rank: GuildRankMember, joined: m.id(), at: time.Now().UTC()}
+	return nil
+}
+
+func (m *MemoryStore) LeaveGuild(ctx context.Context, playerID uuid.UUID) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	member, exists := m.members[playerID]
+	if !exists {
+		return "", nil
+	}
+	delete(m.members, playerID)
+	if member.rank != GuildRankLeader {
+		return member.guildID, nil
+	}
+
+	remaining := m.guildMembers(member.guildID)
+	if len(remaining) == 0 {
+		delete(m.guilds, member.guildID)
+		return member.guildID, nil
+	}
+	successor := remaining[0]
+	for _, candidate := range remaining {
+		if candidate.Rank == GuildRankOfficer {
+			successor = candidate
+			break
+		}
+	}
+	m.members[uuid.MustParse(successor.PlayerID)].rank = GuildRankLeader
+	return member.guildID, nil
+}
+
+func (m *MemoryStore) SetGuildRanks(ctx context.Context, guildID string, ranks map[uuid.UUID]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for playerID, rank := range ranks {
+		if member, exists := m.members[playerID]; exists && member.guildID == guildID {
+			member.rank = rank
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetGuildLeaderboard(ctx context.Context, limit int) ([]GuildScore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scores := make([]GuildScore, 0, len(m.guilds))
+	for _, guild := range m.guilds {
+		score := GuildScore{GuildID: guild.ID, Name: guild.Name, Tag: guild.Tag}
+		for playerID, member := range m.members {
+			if member.guildID != guild.ID {
+				continue
+			}
+			score.Members++
+			if player, exists := m.players[playerID]; exists {
+				score.Score += player.Score
+			}
+		}
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return m.guilds[scores[i].GuildID].CreatedAt.Before(m.guilds[scores[j].GuildID].CreatedAt)
+	})
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+func (m *MemoryStore) SendMail(ctx context.Context, mail *Mail, maxMailbox int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held := 0
+	for _, stored := range m.mail {
+		if stored.RecipientID == mail.RecipientID {
+			held++
+		}
+	}
+	if held >= maxMailbox {
+		return false, nil
+	}
+
+	mail.ID = m.id()
+	mail.SentAt = time.Now().UTC()
+	m.mail = append(m.mail, &memMail{Mail: *mail})
+	return true, nil
+}
+
+// mailFor requires m.mu to be held by the caller. It returns copies with the
+// sender's current name, oldest first.
+func (m *MemoryStore) mailFor(playerID uuid.UUID, undelivered bool) []Mail {
+	var mail []Mail
+	for _, stored := range m.mail {
+		if stored.RecipientID != playerID.String() || undelivered && stored.delivered {
+			continue
+		}
+		item := stored.Mail
+		if senderID, err := uuid.Parse(item.SenderID); err == nil && m.players[senderID] != nil {
+			item.SenderName = m.players[senderID].Name
+		}
+		mail = append(mail, item)
+	}
+	return mail
+}
+
+func (m *MemoryStore) GetMail(ctx context.Context, playerID uuid.UUID, limit int) ([]Mail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mail := m.mailFor(playerID, false)
+	for i, j := 0, len(mail)-1; i < j; i, j = i+1, j-1 {
+		mail[i], mail[j] = mail[j], mail[i]
+	}
+	if len(mail) > limit {
+		mail = mail[:limit]
+	}
+	return mail, nil
+}
+
+func (m *MemoryStore) TakeUndeliveredMail(ctx context.Context, playerID uuid.UUID) ([]Mail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mail := m.mailFor(playerID, true)
+	for _, stored := range m.mail {
+		if stored.RecipientID == playerID.String() {
+			stored.delivered = true
+		}
+	}
+	return mail, nil
+}
+
+func (m *MemoryStore) MarkMailDelivered(ctx context.Context, mailID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stored := range m.mail {
+		if stored.ID == mailID {
+			stored.delivered = true
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) MarkMailRead(ctx context.Context, playerID uuid.UUID, mailID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stored := range m.mail {
+		if stored.ID == mailID && stored.RecipientID == playerID.String() {
+			if stored.ReadAt == nil {
+				readAt := time.Now().UTC()
+				stored.ReadAt = &readAt
+			}
+			stored.delivered = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) DeleteMail(ctx context.Context, playerID uuid.UUID, mailID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, stored := range m.mail {
+		if stored.ID == mailID && stored.RecipientID == playerID.String() {
+			m.mail = append(m.mail[:i], m.mail[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) CreateAccount(ctx context.Context, account *Account, identity *AccountIdentity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	username := strings.ToLower(account.Username)
+	for _, existing := range m.accounts {
+		if strings.ToLower(existing.Username) == username {
+			return ErrUsernameTaken
+		}
+	}
+	if _, exists := m.accounts[account.ID]; exists {
+		return fmt.Errorf("failed to create account: account %s already exists", account.ID)
+	}
+	if identity != nil {
+		if _, exists := m.identities[*identity]; exists {
+			return fmt.Errorf("failed to create account: identity %s/%s is already linked", identity.Provider, identity.Subject)
+		}
+	}
+
+	now := time.Now().UTC()
+	if _, exists := m.players[account.ID]; !exists {
+		m.players[account.ID] = &memPlayer{
+			DBPlayer: DBPlayer{
+				ID: account.ID.String(), Name: account.Username, Health: 100,
+				Rating: DefaultRating, CreatedAt: now, UpdatedAt: now, LastSeenAt: now,
+			},
+			level: 1,
+		}
+	}
+	if account.Role == "" {
+		account.Role = DefaultRole
+	}
+	account.CreatedAt = now
+	stored := *account
+	m.accounts[account.ID] = &stored
+	if identity != nil {
+		m.identities[*identity] = account.ID
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAccount(ctx context.Context, accountID uuid.UUID) (*Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.account(accountID), nil
+}
+
+func (m *MemoryStore) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	username = strings.ToLower(username)
+	for _, account := range m.accounts {
+		if strings.ToLower(account.Username) == username {
+			found := *account
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) GetAccountByIdentity(ctx context.Context, identity AccountIdentity) (*Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.account(m.identities[identity]), nil
+}
+
+func (m *MemoryStore) SetAccountRole(ctx context.Context, accountID uuid.UUID, role string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	account, exists := m.accounts[accountID]
+	if !exists {
+		return false, nil
+	}
+	account.Role = role
+	return true, nil
+}
+
+func (m *MemoryStore) CreateAuthToken(ctx context.Context, tokenHash string, accountID uuid.UUID, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[tokenHash] = memToken{accountID: accountID, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *MemoryStore) GetAccountByToken(ctx context.Context, tokenHash string) (*Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, exists := m.tokens[tokenHash]
+	if !exists || !token.expiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	return m.account(token.accountID), nil
+}
+
+func (m *MemoryStore) DeleteAuthToken(ctx context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, exists := m.tokens[tokenHash]
+	if !exists {
+		return nil
+	}
+	delete(m.tokens, tokenHash)
+	now := time.Now()
+	for hash, other := range m.tokens {
+		if other.accountID == token.accountID && !other.expiresAt.After(now) {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// account returns a copy of the account, or nil if there is none.
+func (m *MemoryStore) account(accountID uuid.UUID) *Account {
+	account, exists := m.accounts[accountID]
+	if !exists {
+		return nil
+	}
+	found := *account
+	return &found
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// playerFlushInterval is how often coalesced player updates are written.
+	playerFlushInterval = time.Second
+	// maxCachedPlayers bounds the cache; players beyond it are evicted in
+	// no particular order.
+	maxCachedPlayers = 10000
+)
+
+// PlayerCache keeps player rows in memory so that the game never waits on
+// disk to read them. Rows are filled on first read. Position, score and
+// health change on every move, pickup and hit, so those updates are applied
+// to the cached row at once and written to the database together every
+// playerFlushInterval, only the latest value of each field.
+type PlayerCache struct {
+	mu      sync.Mutex
+	players map[uuid.UUID]*DBPlayer
+	pending map[uuid.UUID]*pendingPlayerWrite
+}
+
+// pendingPlayerWrite holds the fields changed since the last flush; nil
+// fields are unchanged.
+type pendingPlayerWrite struct {
+	X, Y   *float64
+	Score  *int64
+	Health *float64
+}
+
+func NewPlayerCache() *PlayerCache {
+	return &PlayerCache{
+		players: make(map[uuid.UUID]*DBPlayer),
+		pending: make(map[uuid.UUID]*pendingPlayerWrite),
+	}
+}
+
+// Get returns a copy of the cached row.
+func (pc *PlayerCache) Get(playerID uuid.UUID) (DBPlayer, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	player, cached := pc.players[playerID]
+	if !cached {
+		metrics.Inc("player_cache_misses")
+		return DBPlayer{}, false
+	}
+	metrics.Inc("player_cache_hits")
+	return *player, true
+}
+
+// Put caches a row read from the database and returns it with the updates
+// still waiting for the flush applied, since the row predates them.
+func (pc *PlayerCache) Put(player DBPlayer) DBPlayer {
+	playerID, err := uuid.Parse(player.ID)
+	if err != nil {
+		return player
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if write, pending := pc.pending[playerID]; pending {
+		write.applyTo(&player)
+	}
+	if len(pc.players) >= maxCachedPlayers {
+		for evicted := range pc.players {
+			delete(pc.players, evicted)
+			break
+		}
+	}
+	cached := player
+	pc.players[playerID] = &cached
+	return player
+}
+
+// Invalidate drops a cached row so that the next read loads it again.
+func (pc *PlayerCache) Invalidate(playerID uuid.UUID) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.players, playerID)
+}
+
+// Discard drops a player's cached row and queued updates before the whole
+// row is written.
+func (pc *PlayerCache) Discard(playerID uuid.UUID) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.players, playerID)
+	delete(pc.pending, playerID)
+}
+
+func (pc *PlayerCache) InvalidateAll() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.players = make(map[uuid.UUID]*DBPlayer)
+}
+
+// Update applies a change to the cached row, if any, and queues it for the
+// next flush.
+func (pc *PlayerCache) Update(playerID uuid.UUID, change func(write *pendingPlayerWrite)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	write, pending := pc.pending[playerID]
+	if !pending {
+		write = &pendingPlayerWrite{}
+		pc.pending[playerID] = write
+	}
+	change(write)
+	if player, cached := pc.players[playerID]; cached {
+		write.applyTo(player)
+		player.UpdatedAt = time.Now().UTC()
+		player.LastSeenAt = player.UpdatedAt
+	}
+	metrics.SetGauge("player_cache_pending", float64(len(pc.pending)))
+}
+
+// takePending returns the queued updates and starts a new queue.
+func (pc *PlayerCache) takePending() map[uuid.UUID]*pendingPlayerWrite {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pending := pc.pending
+	pc.pending = make(map[uuid.UUID]*pendingPlayerWrite)
+	metrics.SetGauge("player_cache_pending", 0)
+	return pending
+}
+
+// restore requeues updates whose write failed, unless newer ones are queued.
+func (pc *PlayerCache) restore(failed map[uuid.UUID]*pendingPlayerWrite) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for playerID, old := range failed {
+		write, pending := pc.pending[playerID]
+		if !pending {
+			pc.pending[playerID] = old
+			continue
+		}
+		if write.X == nil {
+			write.X, write.Y = old.X, old.Y
+		}
+		if write.Score == nil {
+			write.Score = old.Score
+		}
+		if write.Health == nil {
+			write.Health = old.Health
+		}
+	}
+	metrics.SetGauge("player_cache_pending", float64(len(pc.pending)))
+}
+
+func (w *pendingPlayerWrite) applyTo(player *DBPlayer) {
+	if w.X != nil {
+		player.X, player.Y = *w.X, *w.Y
+	}
+	if w.Score != nil {
+		player.Score = *w.Score
+	}
+	if w.Health != nil {
+		player.Health = *w.Health
+	}
+}
+
+// positionOnly reports whether the write is movement, which counts as a bulk
+// write against the write budget.
+func (w *pendingPlayerWrite) positionOnly() bool {
+	return w.Score == nil && w.Health == nil
+}
+
+// flushPlayersLoop writes the coalesced player updates until Close.
+func (d *Database) flushPlayersLoop() {
+	defer close(d.flushDone)
+	ticker := time.NewTicker(playerFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.FlushPlayers()
+		case <-d.stopFlush:
+			d.FlushPlayers()
+			return
+		}
+	}
+}
+
+// FlushPlayers writes the queued player updates in one transaction.
+func (d *Database) FlushPlayers() {
+	d.flushMu.Lock()
+	defer d.flushMu.Unlock()
+
+	pending := d.players.takePending()
+	if len(pending) == 0 {
+		return
+	}
+
+	query := `
+		UPDATE players
+		SET x = COALESCE(?, x), y = COALESCE(?, y),
+			score = COALESCE(?, score), health = COALESCE(?, health),
+			updated_at = datetime('now'), last_seen_at = datetime('now')
+		WHERE id = ?
+	`
+
+	ctx := context.Background()
+	writes := make(map[uuid.UUID]*pendingPlayerWrite, len(pending))
+	for playerID, write := range pending {
+		if write.positionOnly() {
+			// Movement is dropped, as before caching, when the budget is low
+			if d.acquire(ctx, WriteBulk) != nil {
+				continue
+			}
+		} else if err := d.acquire(ctx, WriteNormal); err != nil {
+			// Scores and health are not lost to a budget that stays empty
+			logrus.Warnf("Flushing the update of player %s without budget: %v", playerID, err)
+		}
+		writes[playerID] = write
+	}
+
+	err := d.write(ctx, func(tx *sql.Tx) error {
+		for playerID, write := range writes {
+			if _, err := tx.Exec(query, write.X, write.Y, write.Score, write.Health, playerID.String()); err != nil {
+				return fmt.Errorf("failed to update player %s: %w", playerID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("Failed to flush player updates: %v", err)
+		metrics.Inc("player_cache_flush_failures")
+		d.players.restore(writes)
+		return
+	}
+	metrics.Add("player_cache_flushed", int64(len(writes)))
+}
@@ -0,0 +1,142 @@
+// Package store is the persistence of the game server: the Store interface
+// its subsystems use, Database on SQLite and MemoryStore in memory.
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	gamemetrics "online-server-go/metrics"
+)
+
+// metrics is the registry the store counts into, shared with the game.
+var metrics = gamemetrics.Default
+
+// Values records start with, which the game falls back to as well.
+const (
+	DefaultRating = 1200     // of a player without rated matches
+	DefaultRole   = "player" // of an account created without one
+)
+
+// DefaultXPRules are the XP rules a MemoryStore starts with, which the game
+// applies when the xp_rules table is empty or unreadable.
+var DefaultXPRules = map[string]int64{
+	"pickup":       10,
+	"hit":          2,
+	"kill":         50,
+	"match_played": 25,
+}
+
+// Store is the persistence the game servers and their subsystems use.
+// *Database implements it on SQLite; MemoryStore keeps everything in memory
+// so that game logic can run without a database file. Every call is bounded
+// by its context.
+type Store interface {
+	SchemaVersion(ctx context.Context) (string, error)
+
+	CreateOrUpdatePlayer(ctx context.Context, player PlayerState) error
+	GetPlayer(ctx context.Context, playerID uuid.UUID) (*DBPlayer, error)
+	UpdatePlayerPosition(ctx context.Context, playerID uuid.UUID, x, y float32) error
+	UpdatePlayerScore(ctx context.Context, playerID uuid.UUID, score uint32) error
+	UpdatePlayerHealth(ctx context.Context, playerID uuid.UUID, health float32) error
+	InvalidatePlayer(playerID uuid.UUID)
+	GetTopPlayers(ctx context.Context, limit int) ([]DBPlayer, error)
+	GetPlayerCount(ctx context.Context) (int64, error)
+
+	CreateSession(ctx context.Context, playerID uuid.UUID, protocol string, clientIP *string) (int64, error)
+	EndSession(ctx context.Context, sessionID int64) error
+	GetActiveSessionsCount(ctx context.Context) (int64, error)
+	CleanupOldSessions(ctx context.Context, hours int) (int64, error)
+	CloseOrphanedSessions(ctx context.Context) (int64, error)
+
+	LogEvent(ctx context.Context, playerID uuid.UUID, sessionID *int64, eventType string, eventData interface{}) error
+	GetPlayerEvents(ctx context.Context, playerID uuid.UUID, limit int) ([]PlayerEvent, error)
+	SaveChatMessage(ctx context.Context, playerID uuid.UUID, sessionID *int64, message string) error
+	GetRecentChatMessages(ctx context.Context, limit int) ([]ChatMessage, error)
+
+	SaveHighScore(ctx context.Context, playerID uuid.UUID, score uint32, gameDuration *uint32) error
+	GetHighScores(ctx context.Context, limit int) ([]HighScore, error)
+	AddLeaderboardPoints(ctx context.Context, playerID uuid.UUID, points int64, periods []LeaderboardPeriod) error
+	GetWindowedLeaderboard(ctx context.Context, period LeaderboardPeriod, limit int) ([]WindowedScore, error)
+
+	AddPlayerStats(ctx context.Context, playerID uuid.UUID, delta PlayerStatsDelta) error
+	GetPlayerStats(ctx context.Context, playerID uuid.UUID) (*PlayerStats, error)
+	GetTopPlayerStats(ctx context.Context, orderBy string, limit int) ([]PlayerStats, error)
+
+	CreateMatch(ctx context.Context, protocol string, seed int64) (int64, error)
+	EndMatch(ctx context.Context, matchID int64, playerCount int) error
+	GetPlayerRating(ctx context.Context, playerID uuid.UUID) (int64, error)
+	ApplyRatingChanges(ctx context.Context, matchID *int64, changes []RatingChange) error
+	GetRatingHistory(ctx context.Context, playerID uuid.UUID, limit int) ([]RatingHistoryEntry, error)
+	SaveMatchResults(ctx context.Context, matchID int64, results []MatchResult) error
+	GetMatchResults(ctx context.Context, matchID int64) ([]MatchResult, error)
+	GetTopRatedPlayers(ctx context.Context, limit int) ([]DBPlayer, error)
+
+	CreateFriendRequest(ctx context.Context, playerID, friendID uuid.UUID) (string, error)
+	AcceptFriendRequest(ctx context.Context, requesterID, recipientID uuid.UUID) (bool, error)
+	DeleteFriendship(ctx context.Context, playerID, friendID uuid.UUID) error
+	GetFriends(ctx context.Context, playerID uuid.UUID) ([]Friendship, error)
+	GetAcceptedFriendIDs(ctx context.Context, playerID uuid.UUID) ([]uuid.UUID, error)
+
+	AddInventoryItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity, maxStack int64) error
+	RemoveInventoryItem(ctx context.Context, playerID uuid.UUID, itemType string, quantity int64) (bool, error)
+	GetInventory(ctx context.Context, playerID uuid.UUID) ([]InventoryItem, error)
+
+	GetXPRules(ctx context.Context) (map[string]int64, error)
+	GetPlayerProgress(ctx context.Context, playerID uuid.UUID) (int64, int, error)
+	UpdatePlayerProgress(ctx context.Context, playerID uuid.UUID, xp int64, level int) error
+	GetPlayerZone(ctx context.Context, playerID uuid.UUID) (string, error)
+	UpdatePlayerZone(ctx context.Context, playerID uuid.UUID, zone string) error
+
+	BanPlayer(ctx context.Context, playerID uuid.UUID, reason string, expiresAt *time.Time) error
+	UnbanPlayer(ctx context.Context, playerID uuid.UUID) (bool, error)
+	GetActiveBan(ctx context.Context, playerID uuid.UUID) (*Ban, error)
+
+	RecordAdminAction(ctx context.Context, entry AuditEntry) error
+	GetAdminAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+
+	CreateReport(ctx context.Context, report *PlayerReport) error
+	GetReports(ctx context.Context, filter ReportFilter) ([]PlayerReport, error)
+	ResolveReport(ctx context.Context, reportID int64, resolvedBy, resolution string) (bool, error)
+	CountReporters(ctx context.Context, reportedID uuid.UUID, since time.Time) (int, error)
+	EscalateReports(ctx context.Context, reportedID uuid.UUID) (int64, error)
+
+	RecordServerHeartbeat(ctx context.Context, protocol string, startedAt time.Time, playersOnline int) error
+	GetServerInstances(ctx context.Context) ([]ServerInstance, error)
+	SaveWorldSnapshot(ctx context.Context, data []byte) error
+	GetWorldSnapshot(ctx context.Context) ([]byte, error)
+
+	GetPrivacySettings(ctx context.Context, playerID uuid.UUID) (PrivacySettings, error)
+	SetPrivacySettings(ctx context.Context, playerID uuid.UUID, settings PrivacySettings) error
+	GetPreferences(ctx context.Context, playerID uuid.UUID) (map[string]string, error)
+	SetPreferences(ctx context.Context, playerID uuid.UUID, preferences map[string]string) error
+
+	CreateGuild(ctx context.Context, guild *Guild, leaderID uuid.UUID) error
+	GetGuildByTag(ctx context.Context, tag string) (*Guild, error)
+	GetPlayerGuild(ctx context.Context, playerID uuid.UUID) (*Guild, string, error)
+	GetGuildMembers(ctx context.Context, guildID string) ([]GuildMember, error)
+	AddGuildMember(ctx context.Context, guildID string, playerID uuid.UUID) error
+	LeaveGuild(ctx context.Context, playerID uuid.UUID) (string, error)
+	SetGuildRanks(ctx context.Context, guildID string, ranks map[uuid.UUID]string) error
+	GetGuildLeaderboard(ctx context.Context, limit int) ([]GuildScore, error)
+
+	SendMail(ctx context.Context, mail *Mail, maxMailbox int) (bool, error)
+	GetMail(ctx context.Context, playerID uuid.UUID, limit int) ([]Mail, error)
+	TakeUndeliveredMail(ctx context.Context, playerID uuid.UUID) ([]Mail, error)
+	MarkMailDelivered(ctx context.Context, mailID int64) error
+	MarkMailRead(ctx context.Context, playerID uuid.UUID, mailID int64) (bool, error)
+	DeleteMail(ctx context.Context, playerID uuid.UUID, mailID int64) (bool, error)
+
+	CreateAccount(ctx context.Context, account *Account, identity *AccountIdentity) error
+	GetAccount(ctx context.Context, accountID uuid.UUID) (*Account, error)
+	GetAccountByUsername(ctx context.Context, username string) (*Account, error)
+	GetAccountByIdentity(ctx context.Context, identity AccountIdentity) (*Account, error)
+	SetAccountRole(ctx context.Context, accountID uuid.UUID, role string) (bool, error)
+	CreateAuthToken(ctx context.Context, tokenHash string, accountID uuid.UUID, expiresAt time.Time) error
+	GetAccountByToken(ctx context.Context, tokenHash string) (*Account, error)
+	DeleteAuthToken(ctx context.Context, tokenHash string) error
+
+	Close() error
+}
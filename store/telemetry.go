@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Database calls are traced as spans of the trace of the message or tick
+// making them, with the provider the game server installs.
+var tracer = otel.Tracer("online-server-go")
+
+// noopSpan stands in for the spans that are not started.
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// startDBSpan starts the span of a Database call, named after the exported
+// method being traced. Calls outside a recorded trace, such as background
+// flushes, start none.
+func startDBSpan(ctx context.Context) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, noopSpan
+	}
+	return tracer.Start(ctx, "db "+databaseCaller(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("db.system", "sqlite")))
+}
+
+// databaseMethodPrefix starts the function names of Database methods.
+var databaseMethodPrefix = reflect.TypeOf((*Database)(nil)).Elem().PkgPath() + ".(*Database)."
+
+// databaseCaller returns the name of the first exported Database method on
+// the stack, skipping the helpers shared by all of them.
+func databaseCaller() string {
+	var pcs [16]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if method, ok := strings.CutPrefix(frame.Function, databaseMethodPrefix); ok && method != "" && method[0] >= 'A' && method[0] <= 'Z' {
+			return method
+		}
+		if !more {
+			return "query"
+		}
+	}
+}
+
+// recordSpanError marks the span of ctx failed if err is set.
+func recordSpanError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type WritePriority int
+
+const (
+	// WriteCritical writes (sessions, players, matches, bans) always proceed
+	// immediately; they still draw from the budget.
+	WriteCritical WritePriority = iota
+	// WriteNormal writes wait for budget but are never dropped.
+	WriteNormal
+	// WriteBulk writes (movement logging) only run while the budget is above
+	// its reserve and are dropped otherwise.
+	WriteBulk
+)
+
+// errWriteShed is returned for a bulk write dropped to leave budget for
+// the others.
+var errWriteShed = errors.New("bulk write shed by the write budget")
+
+// bulkReserveFraction of the burst is held back from bulk writes so that
+// critical and normal writes always find budget after a bulk spike.
+const bulkReserveFraction = 0.5
+
+// WriteBudget is a token bucket shared by every subsystem writing to the
+// database. A nil *WriteBudget imposes no limit.
+type WriteBudget struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewWriteBudget returns nil when writesPerSecond is not positive.
+func NewWriteBudget(writesPerSecond int) *WriteBudget {
+	if writesPerSecond <= 0 {
+		return nil
+	}
+	return &WriteBudget{
+		rate:   float64(writesPerSecond),
+		burst:  float64(writesPerSecond),
+		tokens: float64(writesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// refill requires wb.mu to be held by the caller.
+func (wb *WriteBudget) refill() {
+	now := time.Now()
+	wb.tokens += now.Sub(wb.last).Seconds() * wb.rate
+	if wb.tokens > wb.burst {
+		wb.tokens = wb.burst
+	}
+	wb.last = now
+}
+
+// Acquire takes one write from the budget. Bulk writes that should be
+// dropped get errWriteShed; normal writes wait until budget is free or ctx
+// ends.
+func (wb *WriteBudget) Acquire(ctx context.Context, priority WritePriority) error {
+	if wb == nil {
+		return nil
+	}
+
+	for {
+		wb.mu.Lock()
+		wb.refill()
+
+		switch priority {
+		case WriteCritical:
+			// May overdraw by up to one burst, delaying lower classes instead
+			if wb.tokens > -wb.burst {
+				wb.tokens--
+			}
+			wb.mu.Unlock()
+			metrics.Inc("db_writes_critical")
+			return nil
+
+		case WriteBulk:
+			if wb.tokens-1 < wb.burst*bulkReserveFraction {
+				wb.mu.Unlock()
+				metrics.Inc("db_writes_shed")
+				return errWriteShed
+			}
+			wb.tokens--
+			wb.mu.Unlock()
+			metrics.Inc("db_writes_bulk")
+			return nil
+
+		default:
+			if wb.tokens >= 1 {
+				wb.tokens--
+				wb.mu.Unlock()
+				metrics.Inc("db_writes_normal")
+				return nil
+			}
+			wait := time.Duration((1 - wb.tokens) / wb.rate * float64(time.Second))
+			wb.mu.Unlock()
+			metrics.Inc("db_writes_delayed")
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				metrics.Inc("db_write_timeouts")
+				return fmt.Errorf("no write budget: %w", ctx.Err())
+			}
+		}
+	}
+}
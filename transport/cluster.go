@@ -0,0 +1,451 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+)
+
+const (
+	clusterSyncInterval = 10 * time.Second
+	clusterPeerTimeout  = 3 * clusterSyncInterval
+	clusterQueueSize    = 1024
+)
+
+// Cluster connects game server instances over net/rpc so that players on
+// different instances can message each other. Every instance tells its peers
+// which players it hosts; messages for a remote player are then forwarded to
+// the hosting instance. Calls are queued and sent asynchronously, so callers
+// holding game locks never wait on the network.
+//
+// Peers authenticate each other with RPC_SECRET when they connect, see
+// peerauth.go. Only the instances of RPC_PEERS are served, and calls only go
+// to a peer that proves it is the instance dialed. A peer may only report its own
+// players and deliver the messages that players send each other across
+// instances.
+//
+// A nil *Cluster only reaches local players.
+type Cluster struct {
+	self   string
+	secret string
+	peers  map[string]*rpcPeer
+
+	mu        sync.RWMutex
+	local     game.PlayerDirectory
+	players   func() []game.Player
+	hosts     map[uuid.UUID]string // remote player -> peer address
+	lastSync  map[string]time.Time // peer address -> last sync or presence call
+	peerHosts map[string]map[uuid.UUID]struct{}
+}
+
+type rpcPeer struct {
+	addr  string
+	calls chan rpcCall
+}
+
+type rpcCall struct {
+	method string
+	args   interface{}
+}
+
+// clusterForwarded are the message types peers may deliver to local
+// players: those that players address to players on any instance.
+var clusterForwarded = map[string]bool{
+	"Whisper":        true,
+	"FriendRequest":  true,
+	"FriendAccepted": true,
+	"FriendOnline":   true,
+	"FriendOffline":  true,
+	"MailReceived":   true,
+	"GuildChat":      true,
+	"GuildUpdate":    true,
+}
+
+// ClusterService is the net/rpc receiver exposed to peers, one per
+// connection, bound to the peer that authenticated on it.
+type ClusterService struct {
+	cluster *Cluster
+	peer    string
+}
+
+type ClusterSyncArgs struct {
+	From      string
+	PlayerIDs []string
+}
+
+type ClusterPresenceArgs struct {
+	From     string
+	PlayerID string
+	Online   bool
+}
+
+type ClusterDeliverArgs struct {
+	PlayerID string
+	Message  []byte // JSON-encoded GameMessage
+}
+
+// NewCluster starts the RPC listener and peer connections, or returns nil
+// when RPC_PEERS is not configured.
+func NewCluster(config *game.Config) (*Cluster, error) {
+	addrs := ParseBackends(config.RPCPeers)
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+
+	_, port, err := net.SplitHostPort(config.RPCSelf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RPC_SELF %q: %w", config.RPCSelf, err)
+	}
+
+	c := &Cluster{
+		self:      config.RPCSelf,
+		secret:    config.RPCSecret,
+		peers:     make(map[string]*rpcPeer),
+		hosts:     make(map[uuid.UUID]string),
+		lastSync:  make(map[string]time.Time),
+		peerHosts: make(map[string]map[uuid.UUID]struct{}),
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for cluster RPC: %w", err)
+	}
+	logrus.Infof("Cluster RPC listening on :%s as %s", port, c.self)
+
+	for _, addr := range addrs {
+		if addr == c.self {
+			continue
+		}
+		peer := &rpcPeer{addr: addr, calls: make(chan rpcCall, clusterQueueSize)}
+		c.peers[addr] = peer
+		go peer.run(c)
+	}
+	go c.accept(listener)
+	go c.syncLoop()
+
+	return c, nil
+}
+
+func (c *Cluster) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			logrus.Errorf("Cluster RPC accept error: %v", err)
+			return
+		}
+		go c.serve(conn)
+	}
+}
+
+// serve answers the calls of a peer once it has authenticated as one of
+// RPC_PEERS.
+func (c *Cluster) serve(conn net.Conn) {
+	peer, err := acceptPeer(conn, c.secret, c.self)
+	if err == nil {
+		if _, known := c.peers[peer]; !known {
+			err = fmt.Errorf("%q is not one of RPC_PEERS", peer)
+		}
+	}
+	if err != nil {
+		metrics.Inc("cluster_auth_failures")
+		logrus.Warnf("Cluster peer %s failed to authenticate: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("Cluster", &ClusterService{cluster: c, peer: peer}); err != nil {
+		logrus.Errorf("Failed to register cluster service: %v", err)
+		conn.Close()
+		return
+	}
+	server.ServeConn(conn)
+}
+
+// Attach sets the directory of locally connected players and the source of
+// the local player list. The directory must take its own locks.
+func (c *Cluster) Attach(local game.PlayerDirectory, players func() []game.Player) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local = local
+	c.players = players
+}
+
+// PlayerOnline announces a newly connected local player to all peers.
+func (c *Cluster) PlayerOnline(playerID uuid.UUID) {
+	c.announce(playerID, true)
+}
+
+// PlayerOffline announces a disconnected local player to all peers.
+func (c *Cluster) PlayerOffline(playerID uuid.UUID) {
+	c.announce(playerID, false)
+}
+
+func (c *Cluster) announce(playerID uuid.UUID, online bool) {
+	if c == nil {
+		return
+	}
+	args := &ClusterPresenceArgs{From: c.self, PlayerID: playerID.String(), Online: online}
+	for _, peer := range c.peers {
+		peer.enqueue(rpcCall{method: "Cluster.Presence", args: args})
+	}
+}
+
+// Directory wraps a local PlayerDirectory so that it also reaches players
+// hosted on peer instances.
+func (c *Cluster) Directory(local game.PlayerDirectory) game.PlayerDirectory {
+	if c == nil {
+		return local
+	}
+	return clusterDirectory{cluster: c, local: local}
+}
+
+// hostOf returns the peer hosting a remote player, ignoring peers that have
+// not synced recently.
+func (c *Cluster) hostOf(playerID uuid.UUID) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	host, exists := c.hosts[playerID]
+	if !exists || time.Since(c.lastSync[host]) > clusterPeerTimeout {
+		return "", false
+	}
+	return host, true
+}
+
+func (c *Cluster) forward(host string, playerID uuid.UUID, message *game.GameMessage) bool {
+	peer, exists := c.peers[host]
+	if !exists {
+		return false
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		logrus.Errorf("Failed to encode %s for cluster delivery: %v", message.Type, err)
+		return false
+	}
+	return peer.enqueue(rpcCall{
+		method: "Cluster.Deliver",
+		args:   &ClusterDeliverArgs{PlayerID: playerID.String(), Message: data},
+	})
+}
+
+// forgetPeer drops everything learned from a peer after a failed call.
+func (c *Cluster) forgetPeer(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for playerID := range c.peerHosts[addr] {
+		if c.hosts[playerID] == addr {
+			delete(c.hosts, playerID)
+		}
+	}
+	delete(c.peerHosts, addr)
+	delete(c.lastSync, addr)
+}
+
+func (c *Cluster) setHost(from string, playerID uuid.UUID, online bool) {
+	if online {
+		if previous, exists := c.hosts[playerID]; exists && previous != from {
+			delete(c.peerHosts[previous], playerID)
+		}
+		c.hosts[playerID] = from
+		if c.peerHosts[from] == nil {
+			c.peerHosts[from] = make(map[uuid.UUID]struct{})
+		}
+		c.peerHosts[from][playerID] = struct{}{}
+		return
+	}
+	if c.hosts[playerID] == from {
+		delete(c.hosts, playerID)
+	}
+	delete(c.peerHosts[from], playerID)
+}
+
+// syncLoop periodically sends the full local player list to every peer,
+// which also serves as a liveness signal.
+func (c *Cluster) syncLoop() {
+	ticker := time.NewTicker(clusterSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		c.sendSync()
+		<-ticker.C
+	}
+}
+
+func (c *Cluster) sendSync() {
+	c.mu.RLock()
+	players := c.players
+	c.mu.RUnlock()
+
+	args := &ClusterSyncArgs{From: c.self, PlayerIDs: []string{}}
+	if players != nil {
+		for _, player := range players() {
+			args.PlayerIDs = append(args.PlayerIDs, player.ID.String())
+		}
+	}
+	for _, peer := range c.peers {
+		peer.enqueue(rpcCall{method: "Cluster.Sync", args: args})
+	}
+}
+
+// Sync replaces the set of players hosted by the calling peer.
+func (s *ClusterService) Sync(args *ClusterSyncArgs, reply *bool) error {
+	if args.From != s.peer {
+		return fmt.Errorf("peer %s cannot sync for %s", s.peer, args.From)
+	}
+	c := s.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for playerID := range c.peerHosts[args.From] {
+		if c.hosts[playerID] == args.From {
+			delete(c.hosts, playerID)
+		}
+	}
+	delete(c.peerHosts, args.From)
+
+	for _, id := range args.PlayerIDs {
+		if playerID, err := uuid.Parse(id); err == nil {
+			c.setHost(args.From, playerID, true)
+		}
+	}
+	c.lastSync[args.From] = time.Now()
+	*reply = true
+	return nil
+}
+
+// Presence records a single player joining or leaving the calling peer.
+func (s *ClusterService) Presence(args *ClusterPresenceArgs, reply *bool) error {
+	if args.From != s.peer {
+		return fmt.Errorf("peer %s cannot report presence for %s", s.peer, args.From)
+	}
+	playerID, err := uuid.Parse(args.PlayerID)
+	if err != nil {
+		return fmt.Errorf("invalid player ID: %w", err)
+	}
+
+	c := s.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setHost(args.From, playerID, args.Online)
+	c.lastSync[args.From] = time.Now()
+	*reply = true
+	return nil
+}
+
+// Deliver sends a message to a player connected to this instance.
+func (s *ClusterService) Deliver(args *ClusterDeliverArgs, reply *bool) error {
+	playerID, err := uuid.Parse(args.PlayerID)
+	if err != nil {
+		return fmt.Errorf("invalid player ID: %w", err)
+	}
+	var message game.GameMessage
+	if err := json.Unmarshal(args.Message, &message); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+	if !clusterForwarded[message.Type] {
+		metrics.Inc("cluster_deliveries_refused")
+		return fmt.Errorf("peers may not deliver %s messages", message.Type)
+	}
+
+	s.cluster.mu.RLock()
+	local := s.cluster.local
+	s.cluster.mu.RUnlock()
+
+	*reply = local != nil && local.SendToPlayer(playerID, &message)
+	return nil
+}
+
+func (p *rpcPeer) enqueue(call rpcCall) bool {
+	select {
+	case p.calls <- call:
+		return true
+	default:
+		logrus.Warnf("Cluster queue to %s is full, dropping %s", p.addr, call.method)
+		return false
+	}
+}
+
+// run sends queued calls to the peer in order, reconnecting as needed.
+func (p *rpcPeer) run(c *Cluster) {
+	var client *rpc.Client
+	for call := range p.calls {
+		if client == nil {
+			var err error
+			client, err = c.dial(p.addr)
+			if err != nil {
+				logrus.Debugf("Cluster peer %s unreachable: %v", p.addr, err)
+				c.forgetPeer(p.addr)
+				continue
+			}
+			logrus.Infof("Cluster connected to peer %s", p.addr)
+		}
+
+		var reply bool
+		err := client.Call(call.method, call.args, &reply)
+		if _, rejected := err.(rpc.ServerError); rejected {
+			logrus.Warnf("Cluster peer %s rejected %s: %v", p.addr, call.method, err)
+		} else if err != nil {
+			logrus.Warnf("Cluster call %s to %s failed: %v", call.method, p.addr, err)
+			client.Close()
+			client = nil
+			c.forgetPeer(p.addr)
+		}
+	}
+}
+
+// dial connects to a peer, authenticates as this instance and checks that
+// the peer is the instance of addr.
+func (c *Cluster) dial(addr string) (*rpc.Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, peerAuthTimeout)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := dialPeer(conn, c.secret, c.self)
+	if err == nil && peer != addr {
+		err = fmt.Errorf("it answered as %q", peer)
+	}
+	if err != nil {
+		metrics.Inc("cluster_auth_failures")
+		logrus.Warnf("Cluster peer %s failed to authenticate: %v", addr, err)
+		conn.Close()
+		return nil, err
+	}
+	return rpc.NewClient(conn), nil
+}
+
+// clusterDirectory reaches local players directly and remote players
+// through their hosting peer.
+type clusterDirectory struct {
+	cluster *Cluster
+	local   game.PlayerDirectory
+}
+
+func (d clusterDirectory) IsOnline(playerID uuid.UUID) bool {
+	if d.local.IsOnline(playerID) {
+		return true
+	}
+	_, remote := d.cluster.hostOf(playerID)
+	return remote
+}
+
+func (d clusterDirectory) SendToPlayer(playerID uuid.UUID, message *game.GameMessage) bool {
+	if d.local.IsOnline(playerID) {
+		return d.local.SendToPlayer(playerID, message)
+	}
+	host, remote := d.cluster.hostOf(playerID)
+	if !remote {
+		return false
+	}
+	return d.cluster.forward(host, playerID, message)
+}
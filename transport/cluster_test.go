@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"net"
+	"testing"
+)
+
+// TestClusterDialChecksPeer checks that a peer holding RPC_SECRET is only
+// called if it proves to be the instance that was dialed.
+func TestClusterDialChecksPeer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	addr := listener.Addr().String()
+
+	c := &Cluster{self: "127.0.0.1:1", secret: "secret"}
+	for _, answer := range []string{addr, "127.0.0.1:2"} {
+		go func(answer string) {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			acceptPeer(conn, c.secret, answer)
+		}(answer)
+
+		client, err := c.dial(addr)
+		if answer == addr && err != nil {
+			t.Errorf("dial of a peer answering as itself: %v", err)
+		}
+		if answer != addr && err == nil {
+			t.Errorf("dial of a peer answering as %s succeeded", answer)
+		}
+		if client != nil {
+			client.Close()
+		}
+	}
+}
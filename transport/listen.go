@@ -0,0 +1,111 @@
+// Package transport is what the transports of packages ws and udp share:
+// listening on BIND_ADDRESSES, TLS, origin checks, routing players between
+// shards, and the authenticated peer links of replication and cluster RPC.
+package transport
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"online-server-go/game"
+)
+
+// The game and its HTTP API listen on PORT of each address in
+// BIND_ADDRESSES, or with one dual-stack socket on every IPv4 and IPv6
+// address when it is empty. A listed IP gets a socket of its own family
+// only, so that "0.0.0.0,::" opens an IPv4 and an IPv6 socket instead of two
+// dual-stack ones colliding on the port; IPv6 addresses may be bracketed.
+//
+// IPv4 clients of a dual-stack socket have IPv4-mapped addresses, which net
+// formats as dotted quads, so a client is keyed, logged and recorded in the
+// client_ip of its session the same whichever socket it reached. client_ip
+// holds the bare IP, without port or IPv6 zone.
+//
+// BindAddrs returns the host:port addresses to listen on port at.
+func BindAddrs(config *game.Config, port string) []string {
+	hosts := ParseBackends(config.BindHosts)
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	addrs := make([]string, len(hosts))
+	for i, host := range hosts {
+		addrs[i] = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	return addrs
+}
+
+// ListenNetwork narrows network, "tcp" or "udp", to the family of the host
+// of addr when it is an IP.
+func ListenNetwork(network, addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return network
+	}
+	if zone := strings.IndexByte(host, '%'); zone >= 0 {
+		host = host[:zone]
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return network
+	case ip.To4() != nil:
+		return network + "4"
+	default:
+		return network + "6"
+	}
+}
+
+// ListenTCP listens on every address of addrs, or on none if one fails.
+func ListenTCP(addrs []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		listener, err := net.Listen(ListenNetwork("tcp", addr), addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// ListenAndServe runs a server from NewHTTPServer on every address of addrs
+// until one of the listeners fails.
+func ListenAndServe(server *http.Server, addrs []string) error {
+	listeners, err := ListenTCP(addrs)
+	if err != nil {
+		return err
+	}
+	// Serve sets TLSConfig on first use, so decide before any of them runs
+	useTLS := server.TLSConfig != nil
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if !useTLS {
+				errs <- server.Serve(listener)
+			} else {
+				errs <- server.ServeTLS(listener, "", "")
+			}
+		}(listener)
+	}
+	return <-errs
+}
+
+// AddrIP returns the IP of a client address, without its port or IPv6 zone.
+func AddrIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	host, _, _ = strings.Cut(host, "%")
+	return host
+}
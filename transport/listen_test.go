@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"online-server-go/game"
+)
+
+func TestBindAddrs(t *testing.T) {
+	tests := []struct {
+		hosts string
+		want  []string
+	}{
+		{"", []string{":7350"}},
+		{"0.0.0.0,::", []string{"0.0.0.0:7350", "[::]:7350"}},
+		{" [::1] , 127.0.0.1", []string{"[::1]:7350", "127.0.0.1:7350"}},
+		{"2001:db8::1", []string{"[2001:db8::1]:7350"}},
+		{"fe80::1%eth0", []string{"[fe80::1%eth0]:7350"}},
+		{"[fe80::1%eth0]", []string{"[fe80::1%eth0]:7350"}},
+		{"localhost", []string{"localhost:7350"}},
+	}
+	for _, test := range tests {
+		got := BindAddrs(&game.Config{BindHosts: test.hosts}, "7350")
+		if !slices.Equal(got, test.want) {
+			t.Errorf("BindAddrs(%q) = %q, want %q", test.hosts, got, test.want)
+		}
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	tests := []struct {
+		network, addr, want string
+	}{
+		{"udp", ":7350", "udp"},
+		{"tcp", "0.0.0.0:7350", "tcp4"},
+		{"udp", "127.0.0.1:7350", "udp4"},
+		{"udp", "[::]:7350", "udp6"},
+		{"tcp", "[2001:db8::1]:7350", "tcp6"},
+		{"udp", "[fe80::1%eth0]:7350", "udp6"},
+		{"tcp", "[::ffff:127.0.0.1]:7350", "tcp4"},
+		{"tcp", "localhost:7350", "tcp"},
+		{"udp", "no port", "udp"},
+	}
+	for _, test := range tests {
+		if got := ListenNetwork(test.network, test.addr); got != test.want {
+			t.Errorf("ListenNetwork(%q, %q) = %q, want %q", test.network, test.addr, got, test.want)
+		}
+	}
+}
+
+func TestAddrIP(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7350}, "127.0.0.1"},
+		{&net.UDPAddr{IP: net.ParseIP("::ffff:10.0.0.1"), Port: 7350}, "10.0.0.1"},
+		{&net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 7350, Zone: "eth0"}, "fe80::1"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 7350}, "2001:db8::1"},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.1"), Port: 7350}, "10.0.0.1"},
+		{&net.IPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}, "fe80::1"},
+		{stringAddr("[fe80::1%eth0]:7350"), "fe80::1"},
+		{stringAddr("10.0.0.1:7350"), "10.0.0.1"},
+		{stringAddr("pipe"), "pipe"},
+	}
+	for _, test := range tests {
+		if got := AddrIP(test.addr); got != test.want {
+			t.Errorf("AddrIP(%v) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+// stringAddr is a net.Addr of a network the server does not know, which
+// AddrIP parses from its string.
+type stringAddr string
+
+func (a stringAddr) Network() string { return "test" }
+func (a stringAddr) String() string  { return string(a) }
@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OriginPolicy decides which browser origins may open WebSocket connections,
+// from the comma-separated ALLOWED_ORIGINS. An entry is a host such as
+// "game.example.com", optionally with a scheme ("https://game.example.com")
+// or a port ("localhost:3000"), and may hold wildcards ("*.example.com").
+// An entry without a scheme or port matches any. "*" allows every origin,
+// as does an empty list. Requests without an Origin header do not come from
+// a browser page and are always allowed.
+type OriginPolicy struct {
+	patterns []originPattern // nil allows every origin
+}
+
+type originPattern struct {
+	scheme string // empty for any
+	host   string // path.Match pattern
+	port   string // empty for any
+}
+
+func ParseOriginPolicy(value string) (*OriginPolicy, error) {
+	policy := &OriginPolicy{}
+	for _, entry := range ParseBackends(value) {
+		if entry == "*" {
+			return &OriginPolicy{}, nil
+		}
+
+		var pattern originPattern
+		if scheme, rest, found := strings.Cut(entry, "://"); found {
+			pattern.scheme, entry = strings.ToLower(scheme), rest
+		}
+		pattern.host = strings.ToLower(entry)
+		if host, port, err := net.SplitHostPort(entry); err == nil {
+			pattern.host, pattern.port = strings.ToLower(host), port
+		}
+		if pattern.host == "" || strings.Contains(pattern.host, "/") {
+			return nil, fmt.Errorf("%q is not an origin", entry)
+		}
+		if _, err := path.Match(pattern.host, ""); err != nil {
+			return nil, fmt.Errorf("%q is not a valid pattern: %w", entry, err)
+		}
+		policy.patterns = append(policy.patterns, pattern)
+	}
+	return policy, nil
+}
+
+// AllowsAll reports whether the policy accepts every origin.
+func (p *OriginPolicy) AllowsAll() bool {
+	return p == nil || len(p.patterns) == 0
+}
+
+// CheckOrigin is the upgrader's CheckOrigin. Rejected upgrades are logged
+// and counted.
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.AllowsAll() {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" {
+		host, port := strings.ToLower(u.Hostname()), u.Port()
+		for _, pattern := range p.patterns {
+			if pattern.matches(strings.ToLower(u.Scheme), host, port) {
+				return true
+			}
+		}
+	}
+
+	metrics.Inc("websocket_origin_rejected")
+	logrus.Warnf("Rejected WebSocket upgrade from %s with origin %q", r.RemoteAddr, origin)
+	return false
+}
+
+func (p originPattern) matches(scheme, host, port string) bool {
+	if p.scheme != "" && p.scheme != scheme {
+		return false
+	}
+	if p.port != "" && p.port != port {
+		return false
+	}
+	matched, _ := path.Match(p.host, host)
+	return matched
+}
@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"time"
+)
+
+// Instances talking to each other over TCP, a replication standby to its
+// primary and cluster peers to each other, prove to each other that they
+// hold a shared secret before either side trusts the connection. Each side
+// sends a random challenge and answers the other's with its name and an
+// HMAC of both challenges and the names so far under the secret, each
+// field of it prefixed with its u16 length:
+//
+//	accept  challenge A [32]byte
+//	dial    challenge B [32]byte, name length u16, name,
+//	        HMAC-SHA256(secret, "dial" || A || B || name)
+//	accept  name length u16, name,
+//	        HMAC-SHA256(secret, "accept" || A || B || dial name || name)
+//
+// Either side closes the connection on an answer that does not hold. The
+// labels keep an answer from being reflected back as the other side's. The
+// names are what each side claims to be, RPC_SELF for cluster peers, and
+// are trusted from then on since only holders of the secret can sign them.
+// The connection itself is not encrypted, so instances should talk over a
+// private network.
+const (
+	peerChallengeSize = 32
+	peerAuthTimeout   = 5 * time.Second
+)
+
+var errPeerRejected = errors.New("peer authentication rejected")
+
+// acceptPeer challenges the dialer of conn, answers its challenge as name
+// and returns the name the dialer proved, or an error after which conn must
+// be closed.
+func acceptPeer(conn net.Conn, secret, name string) (string, error) {
+	conn.SetDeadline(time.Now().Add(peerAuthTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	challenge, err := peerChallenge()
+	if err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(challenge); err != nil {
+		return "", err
+	}
+
+	theirs := make([]byte, peerChallengeSize)
+	if _, err := io.ReadFull(conn, theirs); err != nil {
+		return "", err
+	}
+	peer, err := readPeerAnswer(conn, peerSignature(secret, "dial", challenge, theirs))
+	if err != nil {
+		return "", err
+	}
+
+	if err := writePeerAnswer(conn, name, peerSignature(secret, "accept", challenge, theirs, peer)); err != nil {
+		return "", err
+	}
+	return string(peer), nil
+}
+
+// dialPeer answers the challenge of the accepting side of conn as name,
+// challenges it in turn and returns the name it proved.
+func dialPeer(conn net.Conn, secret, name string) (string, error) {
+	conn.SetDeadline(time.Now().Add(peerAuthTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	theirs := make([]byte, peerChallengeSize)
+	if _, err := io.ReadFull(conn, theirs); err != nil {
+		return "", err
+	}
+	challenge, err := peerChallenge()
+	if err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(challenge); err != nil {
+		return "", err
+	}
+	if err := writePeerAnswer(conn, name, peerSignature(secret, "dial", theirs, challenge)); err != nil {
+		return "", err
+	}
+
+	peer, err := readPeerAnswer(conn, peerSignature(secret, "accept", theirs, challenge, []byte(name)))
+	if err != nil {
+		// The accepting side closes the connection on a wrong answer
+		return "", errPeerRejected
+	}
+	return string(peer), nil
+}
+
+func peerChallenge() ([]byte, error) {
+	challenge := make([]byte, peerChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// writePeerAnswer sends name and its HMAC, signature with name added.
+func writePeerAnswer(conn net.Conn, name string, signature hash.Hash) error {
+	if len(name) > 0xFFFF {
+		return fmt.Errorf("peer name of %d bytes is too long", len(name))
+	}
+	writePeerField(signature, []byte(name))
+	answer := binary.BigEndian.AppendUint16(nil, uint16(len(name)))
+	answer = append(answer, name...)
+	answer = append(answer, signature.Sum(nil)...)
+	_, err := conn.Write(answer)
+	return err
+}
+
+// readPeerAnswer reads a name and its HMAC and returns the name if the HMAC
+// is signature with name added.
+func readPeerAnswer(conn net.Conn, signature hash.Hash) ([]byte, error) {
+	var size uint16
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, int(size)+sha256.Size)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	name, tag := answer[:size], answer[size:]
+	writePeerField(signature, name)
+	if !hmac.Equal(tag, signature.Sum(nil)) {
+		return nil, errPeerRejected
+	}
+	return name, nil
+}
+
+// peerSignature starts the HMAC of an answer with its label and the fields
+// before the answering side's name.
+func peerSignature(secret, label string, fields ...[]byte) hash.Hash {
+	mac := hmac.New(sha256.New, []byte(secret))
+	writePeerField(mac, []byte(label))
+	for _, field := range fields {
+		writePeerField(mac, field)
+	}
+	return mac
+}
+
+// writePeerField adds a length-prefixed field to an HMAC, so that no two
+// sequences of fields sign the same bytes.
+func writePeerField(mac hash.Hash, field []byte) {
+	mac.Write(binary.BigEndian.AppendUint16(nil, uint16(len(field))))
+	mac.Write(field)
+}
@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"net"
+	"testing"
+)
+
+// TestPeerAuth checks that each side of the handshake learns the other's
+// name only if both hold the secret.
+func TestPeerAuth(t *testing.T) {
+	tests := []struct {
+		name                     string
+		acceptSecret, dialSecret string
+		ok                       bool
+	}{
+		{"shared secret", "secret", "secret", true},
+		{"dialer without the secret", "secret", "guess", false},
+		{"acceptor without the secret", "guess", "secret", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			acceptConn, dialConn := net.Pipe()
+			defer dialConn.Close()
+
+			type result struct {
+				peer string
+				err  error
+			}
+			accepted := make(chan result, 1)
+			go func() {
+				peer, err := acceptPeer(acceptConn, test.acceptSecret, "primary")
+				if err != nil {
+					acceptConn.Close()
+				}
+				accepted <- result{peer, err}
+			}()
+			dialed, dialErr := dialPeer(dialConn, test.dialSecret, "standby")
+			if dialErr != nil {
+				dialConn.Close()
+			}
+			accept := <-accepted
+			acceptConn.Close()
+
+			if !test.ok {
+				if dialErr == nil {
+					t.Errorf("dialPeer proved %q, want an error", dialed)
+				}
+				return
+			}
+			if accept.err != nil || accept.peer != "standby" {
+				t.Errorf("acceptPeer = %q, %v, want standby", accept.peer, accept.err)
+			}
+			if dialErr != nil || dialed != "primary" {
+				t.Errorf("dialPeer = %q, %v, want primary", dialed, dialErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+)
+
+const (
+	replicationSnapshotInterval = time.Second
+	replicationReconnectDelay   = 2 * time.Second
+	replicaRetention            = 5 * time.Minute
+	replicationSendBuffer       = 1024
+)
+
+// ReplicationFrame is one newline-delimited JSON record on the stream from a
+// primary to its standbys.
+type ReplicationFrame struct {
+	Type      string        `json:"type"` // "snapshot", "update" or "remove"
+	Timestamp int64         `json:"timestamp"`
+	Players   []game.Player `json:"players,omitempty"`
+	PlayerID  uuid.UUID     `json:"player_id,omitempty"`
+}
+
+// Replication streams player state from a primary to hot standbys. On a
+// standby it keeps the last replicated state so clients that fail over can
+// resume with their position, health and score. A nil *Replication is a
+// no-op, which is the default when REPLICATION_ROLE is unset. The primary
+// and its standbys prove to each other that they hold REPLICATION_SECRET
+// before any state is streamed, see peerauth.go.
+type Replication struct {
+	role   string
+	secret string
+
+	// Primary side
+	listener  net.Listener
+	mu        sync.Mutex
+	followers map[net.Conn]chan []byte
+	snapshot  func() []game.Player
+
+	// Standby side
+	replicaMu     sync.Mutex
+	replica       map[uuid.UUID]game.Player
+	lastPrimaryAt time.Time
+}
+
+func NewReplication(config *game.Config) (*Replication, error) {
+	switch config.ReplicationRole {
+	case "":
+		return nil, nil
+	case "primary":
+		listener, err := net.Listen("tcp", config.ReplicationAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for standbys: %w", err)
+		}
+		r := &Replication{
+			role:      "primary",
+			secret:    config.ReplicationSecret,
+			listener:  listener,
+			followers: make(map[net.Conn]chan []byte),
+		}
+		logrus.Infof("Replication primary accepting standbys on %s", config.ReplicationAddr)
+		go r.acceptFollowers()
+		go r.snapshotLoop()
+		return r, nil
+	case "standby":
+		r := &Replication{
+			role:    "standby",
+			secret:  config.ReplicationSecret,
+			replica: make(map[uuid.UUID]game.Player),
+		}
+		logrus.Infof("Replication standby following primary at %s", config.ReplicationAddr)
+		go r.follow(config.ReplicationAddr)
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unknown replication role %q", config.ReplicationRole)
+	}
+}
+
+// SetSnapshotSource registers the function used to build periodic snapshots.
+func (r *Replication) SetSnapshotSource(source func() []game.Player) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshot = source
+}
+
+// PlayerUpdated streams the latest state of a player to standbys.
+func (r *Replication) PlayerUpdated(player game.Player) {
+	if r == nil || r.role != "primary" {
+		return
+	}
+	r.publish(ReplicationFrame{Type: "update", Players: []game.Player{player}})
+}
+
+// PlayerRemoved tells standbys a player left normally and must not be resumed.
+func (r *Replication) PlayerRemoved(playerID uuid.UUID) {
+	if r == nil || r.role != "primary" {
+		return
+	}
+	r.publish(ReplicationFrame{Type: "remove", PlayerID: playerID})
+}
+
+// Restore hands over the replicated state of a player reconnecting to a
+// standby. Each player can be restored once.
+func (r *Replication) Restore(playerID uuid.UUID) (game.Player, bool) {
+	if r == nil || r.role != "standby" {
+		return game.Player{}, false
+	}
+
+	r.replicaMu.Lock()
+	defer r.replicaMu.Unlock()
+
+	if time.Since(r.lastPrimaryAt) > replicaRetention {
+		return game.Player{}, false
+	}
+
+	player, exists := r.replica[playerID]
+	if exists {
+		delete(r.replica, playerID)
+		metrics.Inc("replication_restored_players")
+	}
+	return player, exists
+}
+
+func (r *Replication) publish(frame ReplicationFrame) {
+	frame.Timestamp = time.Now().UnixMilli()
+	data, err := json.Marshal(frame)
+	if err != nil {
+		logrus.Errorf("Failed to encode replication frame: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for conn, send := range r.followers {
+		select {
+		case send <- data:
+		default:
+			// A standby that can't keep up is dropped; it will reconnect and resync
+			logrus.Warnf("Replication standby %s is too slow, disconnecting", conn.RemoteAddr())
+			delete(r.followers, conn)
+			close(send)
+		}
+	}
+}
+
+func (r *Replication) acceptFollowers() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			logrus.Errorf("Replication accept error: %v", err)
+			return
+		}
+		go r.addFollower(conn)
+	}
+}
+
+// addFollower starts streaming to a standby once it has authenticated.
+func (r *Replication) addFollower(conn net.Conn) {
+	if _, err := acceptPeer(conn, r.secret, "primary"); err != nil {
+		metrics.Inc("replication_auth_failures")
+		logrus.Warnf("Replication standby %s failed to authenticate: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	send := make(chan []byte, replicationSendBuffer)
+	r.mu.Lock()
+	r.followers[conn] = send
+	r.mu.Unlock()
+
+	logrus.Infof("Replication standby connected: %s", conn.RemoteAddr())
+	go r.writeFollower(conn, send)
+	r.publishSnapshot()
+}
+
+func (r *Replication) writeFollower(conn net.Conn, send chan []byte) {
+	defer conn.Close()
+
+	for data := range send {
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write(data); err != nil {
+			logrus.Warnf("Replication standby %s disconnected: %v", conn.RemoteAddr(), err)
+			r.mu.Lock()
+			if _, exists := r.followers[conn]; exists {
+				delete(r.followers, conn)
+				close(send)
+			}
+			r.mu.Unlock()
+			// Drain so publishers never block on a closed writer
+			for range send {
+			}
+			return
+		}
+	}
+}
+
+func (r *Replication) snapshotLoop() {
+	ticker := time.NewTicker(replicationSnapshotInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.publishSnapshot()
+	}
+}
+
+func (r *Replication) publishSnapshot() {
+	r.mu.Lock()
+	source := r.snapshot
+	r.mu.Unlock()
+
+	if source == nil {
+		return
+	}
+	r.publish(ReplicationFrame{Type: "snapshot", Players: source()})
+}
+
+// follow keeps a connection to the primary open, applying frames as they
+// arrive and reconnecting after failures.
+func (r *Replication) follow(primaryAddr string) {
+	for {
+		conn, err := net.Dial("tcp", primaryAddr)
+		if err != nil {
+			logrus.Debugf("Replication primary %s unreachable: %v", primaryAddr, err)
+			time.Sleep(replicationReconnectDelay)
+			continue
+		}
+		if _, err := dialPeer(conn, r.secret, "standby"); err != nil {
+			logrus.Warnf("Replication primary %s failed to authenticate: %v", primaryAddr, err)
+			conn.Close()
+			time.Sleep(replicationReconnectDelay)
+			continue
+		}
+
+		logrus.Infof("Replication connected to primary %s", primaryAddr)
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var frame ReplicationFrame
+			if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+				logrus.Warnf("Invalid replication frame: %v", err)
+				continue
+			}
+			r.apply(&frame)
+		}
+		conn.Close()
+
+		r.replicaMu.Lock()
+		held := len(r.replica)
+		r.replicaMu.Unlock()
+		logrus.Warnf("Lost connection to replication primary; holding %d players for takeover", held)
+
+		time.Sleep(replicationReconnectDelay)
+	}
+}
+
+func (r *Replication) apply(frame *ReplicationFrame) {
+	r.replicaMu.Lock()
+	defer r.replicaMu.Unlock()
+
+	r.lastPrimaryAt = time.Now()
+
+	switch frame.Type {
+	case "snapshot":
+		r.replica = make(map[uuid.UUID]game.Player, len(frame.Players))
+		for _, player := range frame.Players {
+			r.replica[player.ID] = player
+		}
+	case "update":
+		for _, player := range frame.Players {
+			r.replica[player.ID] = player
+		}
+	case "remove":
+		delete(r.replica, frame.PlayerID)
+	}
+}
@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+)
+
+const hashRingReplicas = 100
+
+// HashRing maps keys onto backends with consistent hashing, so adding or
+// removing a backend only moves the keys that hashed to it.
+type HashRing struct {
+	hashes []uint32
+	owners map[uint32]string
+}
+
+func NewHashRing(backends []string, replicas int) *HashRing {
+	ring := &HashRing{owners: make(map[uint32]string)}
+	for _, backend := range backends {
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s#%d", backend, i)))
+			if _, taken := ring.owners[hash]; taken {
+				continue
+			}
+			ring.owners[hash] = backend
+			ring.hashes = append(ring.hashes, hash)
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// Lookup returns the backend owning key, or "" for an empty ring.
+func (r *HashRing) Lookup(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// Router decides which backend instance serves a player or room. A nil
+// *Router routes everything locally, so servers without SHARD_BACKENDS
+// behave as a single instance.
+type Router struct {
+	ring *HashRing
+	self string
+}
+
+// NewRouter returns nil when sharding is not configured.
+func NewRouter(config *game.Config) *Router {
+	backends := ParseBackends(config.ShardBackends)
+	if len(backends) == 0 {
+		return nil
+	}
+	logrus.Infof("Sharding enabled across %d backends (self=%q)", len(backends), config.ShardSelf)
+	return &Router{
+		ring: NewHashRing(backends, hashRingReplicas),
+		self: config.ShardSelf,
+	}
+}
+
+func ParseBackends(value string) []string {
+	var backends []string
+	for _, backend := range strings.Split(value, ",") {
+		if backend = strings.TrimSpace(backend); backend != "" {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// Route returns the backend owning key and whether that is this instance.
+func (r *Router) Route(key string) (string, bool) {
+	if r == nil {
+		return "", true
+	}
+	backend := r.ring.Lookup(key)
+	return backend, backend == r.self
+}
+
+// RoutingKey picks the key a connection request is routed by: a requested
+// room takes precedence over the player ID.
+func RoutingKey(room string, playerID uuid.UUID) string {
+	if room != "" {
+		return "room:" + room
+	}
+	return playerID.String()
+}
+
+// RouterServer is the standalone router mode (PROTOCOL=router). It holds no
+// game state and only answers with the backend a client should connect to.
+type RouterServer struct {
+	router   *Router
+	upgrader websocket.Upgrader
+}
+
+func NewRouterServer(router *Router, origins *OriginPolicy) *RouterServer {
+	return &RouterServer{
+		router:   router,
+		upgrader: websocket.Upgrader{CheckOrigin: origins.CheckOrigin},
+	}
+}
+
+func (rs *RouterServer) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/route", rs.handleRoute)
+	mux.HandleFunc("/", rs.handleConnection)
+}
+
+// resolve assigns a player ID if the client did not bring one and returns
+// the redirect for the request.
+func (rs *RouterServer) resolve(r *http.Request) (game.GameMessage, error) {
+	query := r.URL.Query()
+	playerID := uuid.New()
+	if value := query.Get("player_id"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			return game.GameMessage{}, fmt.Errorf("invalid player_id: %w", err)
+		}
+		playerID = parsed
+	}
+
+	room := query.Get("room")
+	backend, _ := rs.router.Route(RoutingKey(room, playerID))
+	return game.NewRedirectMessage(backend, playerID, room), nil
+}
+
+// handleRoute serves GET /route?player_id=&room= for HTTP clients and
+// UDP clients that look up their backend before connecting.
+func (rs *RouterServer) handleRoute(w http.ResponseWriter, r *http.Request) {
+	redirect, err := rs.resolve(r)
+	if err != nil {
+		game.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	game.WriteJSON(w, http.StatusOK, redirect.Data)
+}
+
+// handleConnection answers WebSocket clients with a Redirect and closes.
+func (rs *RouterServer) handleConnection(w http.ResponseWriter, r *http.Request) {
+	redirect, err := rs.resolve(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := rs.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.Errorf("WebSocket connection failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(redirect); err != nil {
+		logrus.Errorf("Failed to send redirect to %s: %v", r.RemoteAddr, err)
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "redirect"))
+}
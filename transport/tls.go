@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
+
+	"online-server-go/game"
+)
+
+// NewTLSConfig returns the TLS settings of the HTTP and WebSocket listeners,
+// or nil to serve plain HTTP. The certificate comes from TLS_CERT_FILE and
+// TLS_KEY_FILE, or is obtained from Let's Encrypt for TLS_AUTOCERT_DOMAINS.
+// Let's Encrypt validates the domains on PORT with TLS-ALPN-01, which needs
+// PORT to be reachable as 443, or with HTTP-01 on TLS_AUTOCERT_HTTP_PORT.
+func NewTLSConfig(config *game.Config) (*tls.Config, error) {
+	domains := ParseBackends(config.TLSAutocertDomains)
+	switch {
+	case config.TLSCertFile != "":
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		logrus.Infof("Serving TLS with the certificate from %s", config.TLSCertFile)
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+
+	case len(domains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(config.TLSAutocertCacheDir),
+			Email:      config.TLSAutocertEmail,
+		}
+		if config.TLSAutocertHTTPPort != "" {
+			addrs := BindAddrs(config, config.TLSAutocertHTTPPort)
+			go func() {
+				logrus.Infof("ACME HTTP challenges listening on: %s", strings.Join(addrs, ", "))
+				if err := ListenAndServe(NewHTTPServer(addrs[0], manager.HTTPHandler(nil), config, nil), addrs); err != nil {
+					logrus.Errorf("ACME HTTP challenge server error: %v", err)
+				}
+			}()
+		}
+		logrus.Infof("Serving TLS with Let's Encrypt certificates for %s", strings.Join(domains, ", "))
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, nil
+	}
+	return nil, nil
+}
+
+// NewHTTPServer returns a server of handler on addr with the HTTP_*_TIMEOUT
+// settings, serving HTTPS when tlsConfig is not nil. WebSockets are not
+// bound by the timeouts once upgraded, since net/http clears the deadlines
+// of a hijacked connection.
+func NewHTTPServer(addr string, handler http.Handler, config *game.Config, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: config.HTTPReadTimeout,
+		ReadTimeout:       config.HTTPReadTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+	}
+}
+
+// ParseTrustedProxies reads a comma-separated list of proxy IPs and CIDRs
+// whose X-Forwarded-For headers are believed.
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range ParseBackends(value) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// RequestClientIP is the address of the client behind r. When r comes from a
+// trusted proxy, X-Forwarded-For is walked from the right, past the trusted
+// proxies that appended to it, to the first address a client could not have
+// forged.
+func RequestClientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
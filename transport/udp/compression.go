@@ -0,0 +1,35 @@
+package udp
+
+import "online-server-go/game"
+
+// Messages to clients of COMPRESSION_THRESHOLD bytes or more, in practice
+// the GameState snapshots of big lobbies, are compressed for clients that
+// can take them. WebSocket clients negotiate permessage-deflate when
+// WS_COMPRESSION is set, and only frames over the threshold are deflated,
+// since small ones gain little for the CPU they cost. UDP clients that send
+// the "gzip" capability in their first Heartbeat receive such packets
+// gzipped in a compressed binary frame:
+//
+//	frame   magic u8 (0xC1), kind u8 (3), sequence u32 (0 when unreliable),
+//	        then the gzip of the JSON or MessagePack packet
+//
+// The sequence repeats the one of the packet inside. Clients decompress the
+// rest and handle the packet as if it had arrived as is. Packets that would
+// not shrink are sent uncompressed.
+const capabilityGzip = "gzip"
+
+// compress returns packet compressed if the client takes compressed frames,
+// the packet reaches threshold and compressing shrinks it. Otherwise it
+// returns packet.
+func (uc *UDPClient) compress(packet []byte, sequence uint32, threshold int) []byte {
+	if !uc.Gzip || threshold == 0 || len(packet) < threshold {
+		return packet
+	}
+	frame, err := game.CompressPacket(packet, sequence)
+	if err != nil || len(frame) >= len(packet) {
+		return packet
+	}
+	metrics.Inc("udp_packets_compressed")
+	metrics.Add("udp_compression_saved_bytes", int64(len(packet)-len(frame)))
+	return frame
+}
@@ -0,0 +1,109 @@
+package udp
+
+import "time"
+
+const (
+	// Congestion windows are in bytes of reliable data awaiting an ack.
+	initialSendWindow = 16 * 1024
+	minSendWindow     = 2 * 1024
+	maxSendWindow     = 256 * 1024
+	// sendWindowStep is roughly one packet, added per window of acks.
+	sendWindowStep = 1200
+
+	minThinInterval = 100 * time.Millisecond
+	maxThinInterval = time.Second
+)
+
+// SendBudget is a per-client congestion window for UDP. The window grows
+// while reliable packets are acknowledged and halves at most once per round
+// trip when they have to be resent. While a client is congested its move
+// updates are thinned, and retransmissions never exceed the window in one
+// round. The zero value is ready to use; it is not safe for concurrent use.
+type SendBudget struct {
+	window       int
+	inFlight     int
+	lastDecrease time.Time
+	lastMoveSend time.Time
+}
+
+func (b *SendBudget) Window() int {
+	if b.window == 0 {
+		return initialSendWindow
+	}
+	return b.window
+}
+
+func (b *SendBudget) InFlight() int {
+	return b.inFlight
+}
+
+// OnSend counts a reliable packet as in flight.
+func (b *SendBudget) OnSend(size int) {
+	b.inFlight += size
+}
+
+// OnAck releases an acknowledged packet. Acks of packets sent only once grow
+// the window additively.
+func (b *SendBudget) OnAck(size int, retried bool) {
+	b.inFlight -= size
+	if b.inFlight < 0 {
+		b.inFlight = 0
+	}
+	if retried {
+		return
+	}
+
+	window := b.Window()
+	window += sendWindowStep * size / window
+	if window > maxSendWindow {
+		window = maxSendWindow
+	}
+	b.window = window
+}
+
+// OnLoss halves the window when a packet had to be resent, at most once per
+// round trip so that one burst of losses counts once.
+func (b *SendBudget) OnLoss(now time.Time, srtt time.Duration) {
+	if srtt < minRTO {
+		srtt = minRTO
+	}
+	if now.Sub(b.lastDecrease) < srtt {
+		return
+	}
+	b.lastDecrease = now
+
+	window := b.Window() / 2
+	if window < minSendWindow {
+		window = minSendWindow
+	}
+	b.window = window
+}
+
+// Congested reports whether the window is full or has not recovered from a
+// loss.
+func (b *SendBudget) Congested() bool {
+	return b.inFlight >= b.Window() || b.Window() < initialSendWindow
+}
+
+// AllowMove reports whether a move update may be sent now. Uncongested
+// clients get every update; congested ones get one per thinning interval.
+func (b *SendBudget) AllowMove(now time.Time, srtt time.Duration) bool {
+	if b.Congested() && now.Sub(b.lastMoveSend) < thinInterval(srtt) {
+		return false
+	}
+	b.lastMoveSend = now
+	return true
+}
+
+// thinInterval spaces move updates to a congested client by two round
+// trips.
+func thinInterval(srtt time.Duration) time.Duration {
+	interval := 2 * srtt
+	if interval < minThinInterval {
+		return minThinInterval
+	}
+	if interval > maxThinInterval {
+		return maxThinInterval
+	}
+	return interval
+}
@@ -0,0 +1,127 @@
+package udp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"online-server-go/game"
+)
+
+// FuzzDeserializeUDPPacket feeds arbitrary bytes through the UDP path the
+// way a hostile client would send them, decoding them and handling them as
+// packets from a joined player, and fails on inputs whose handling panics.
+// Without -fuzz it runs its seeds, a message of every type clients send:
+//
+//	go test ./transport/udp -run '^$' -fuzz FuzzDeserializeUDPPacket -fuzztime 1m
+func FuzzDeserializeUDPPacket(f *testing.F) {
+	fuzz := newFuzzGame(f)
+	for _, seed := range fuzz.Seeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		game.DeserializeUDPPacket(data)
+		if err := fuzz.Packet(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// fuzzGame runs the same decoding and handlers as a served game, with an
+// in-memory database and a player that is joined again whenever an input
+// gets it disconnected.
+type fuzzGame struct {
+	udp  *UDPGameServer
+	addr *net.UDPAddr
+
+	playerID uuid.UUID
+}
+
+// newFuzzGame creates a UDP game configured from the environment. It listens
+// on a free port but is never run, since the fuzz test hands it its inputs
+// directly.
+func newFuzzGame(tb testing.TB) *fuzzGame {
+	f := &fuzzGame{
+		udp: newTestUDPServer(tb),
+		// Replies go to a port nobody reads
+		addr:     &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9},
+		playerID: uuid.New(),
+	}
+	tb.Cleanup(func() { f.udp.Close() })
+	return f
+}
+
+// Packet handles data as a UDP datagram from the joined player.
+func (f *fuzzGame) Packet(data []byte) (err error) {
+	if len(data) > maxUDPPacketSize {
+		// Reads truncate it to the read buffer
+		data = data[:maxUDPPacketSize]
+	}
+	f.udp.mu.RLock()
+	_, joined := f.udp.clients[f.addr.String()]
+	f.udp.mu.RUnlock()
+	if !joined {
+		f.udp.decodePacket(f.addr, f.joinPacket())
+	}
+
+	panics := metrics.Counter("handler_panics")
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		} else if metrics.Counter("handler_panics") != panics {
+			err = fmt.Errorf("handler panicked, see the log")
+		}
+	}()
+	f.udp.decodePacket(f.addr, data)
+	return nil
+}
+
+// Seeds returns a message of every type clients send wrapped in a reliable
+// UDP packet, in both JSON and MessagePack, with zero payloads naming the
+// fuzzed player where they have a player_id.
+func (f *fuzzGame) Seeds() [][]byte {
+	var seeds [][]byte
+	for i, spec := range game.ClientMessages() {
+		packet := game.UDPPacket{Sequence: uint32(i + 2), Message: f.seedMessage(spec), Reliable: true}
+		for _, encoding := range []game.Encoding{game.EncodingJSON, game.EncodingMsgpack} {
+			if data, err := encoding.Marshal(packet); err == nil {
+				seeds = append(seeds, data)
+			}
+		}
+	}
+	return seeds
+}
+
+// seedMessage builds a message of spec's type whose payload is the zero
+// payload with player_id filled in, since most handlers ignore messages
+// about other players.
+func (f *fuzzGame) seedMessage(spec game.MessageSpec) game.GameMessage {
+	message := game.GameMessage{Type: spec.Type}
+	if spec.Data == nil {
+		return message
+	}
+	var data map[string]interface{}
+	if raw, err := json.Marshal(spec.Data); err == nil && json.Unmarshal(raw, &data) == nil {
+		if _, ok := data["player_id"]; ok {
+			data["player_id"] = f.playerID.String()
+		}
+		message.Data = data
+	}
+	return message
+}
+
+// joinPacket is the Heartbeat a UDP client joins with.
+func (f *fuzzGame) joinPacket() []byte {
+	data, _ := json.Marshal(game.UDPPacket{
+		Sequence: 1,
+		Message: game.GameMessage{Type: "Heartbeat", Data: map[string]interface{}{
+			"player_id": f.playerID.String(),
+			"sequence":  1,
+		}},
+		Reliable: true,
+	})
+	return data
+}
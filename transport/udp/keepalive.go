@@ -0,0 +1,35 @@
+package udp
+
+import (
+	"net"
+
+	"online-server-go/game"
+)
+
+// NAT routers forget a UDP mapping after a silence, often of 30 seconds or
+// less, and the server's replies then stop reaching the client although it
+// keeps sending. Clients therefore send a Heartbeat every
+// UDP_KEEPALIVE_INTERVAL, which ServerInfo and AddressEcho advertise as
+// keepalive_ms, whether or not they have anything else to send.
+//
+// To diagnose connectivity, a client may send an AddressEchoRequest before
+// or after joining, like a STUN binding request. The server answers with an
+// AddressEcho holding the address and port the request arrived from, which
+// differ from the client's own when it is behind NAT, and which a client can
+// compare across requests to tell whether its NAT keeps one mapping per
+// socket. Like ServerInfoRequest, the request is answered in plaintext
+// before a handshake and only if it is padded to the length of the reply.
+// WebSocket and TCP clients have no use for it and are not answered.
+func (ugs *UDPGameServer) handleAddressEchoRequest(addr *net.UDPAddr, request *game.GameMessage, encoding game.Encoding, requestSize int) {
+	if !ugs.replyPadded(addr, game.NewReply(request, game.NewAddressEchoMessage(addr, ugs.keepalive)), encoding, requestSize) {
+		metrics.Inc("udp_address_echo_unpadded")
+		return
+	}
+	metrics.Inc("udp_address_echoes")
+}
+
+// unauthenticatedQuery reports whether a message type is answered for
+// anyone, client or not, before an encryption handshake.
+func unauthenticatedQuery(messageType string) bool {
+	return messageType == "ServerInfoRequest" || messageType == "AddressEchoRequest"
+}
@@ -0,0 +1,50 @@
+package udp
+
+import (
+	"context"
+	"net"
+
+	"online-server-go/transport"
+)
+
+// udpSocket is a socket the UDP server reads from, with the network it was
+// opened on, "udp" for dual-stack, "udp4" or "udp6", and the pool handling
+// what it reads.
+type udpSocket struct {
+	conn    *net.UDPConn
+	batch   batchConn // conn, reading and writing several datagrams per call
+	network string
+	pool    *PacketPool
+}
+
+// listenUDP opens perAddr sockets on every address of addrs, or none if one
+// fails. Sockets of one address share it with SO_REUSEPORT, and the kernel
+// hashes each source address to one of them, so a client's packets are
+// always read by the same socket and handled in order by its pool.
+func listenUDP(addrs []string, perAddr int) ([]udpSocket, error) {
+	var listen net.ListenConfig
+	if perAddr > 1 {
+		listen.Control = reusePort
+	}
+	sockets := make([]udpSocket, 0, len(addrs)*perAddr)
+	for _, addr := range addrs {
+		network := transport.ListenNetwork("udp", addr)
+		for i := 0; i < perAddr; i++ {
+			conn, err := listen.ListenPacket(context.Background(), network, addr)
+			if err != nil {
+				for _, s := range sockets {
+					s.conn.Close()
+				}
+				return nil, err
+			}
+			udpConn := conn.(*net.UDPConn)
+			sockets = append(sockets, udpSocket{conn: udpConn, batch: newBatchConn(udpConn), network: network})
+		}
+	}
+	return sockets, nil
+}
+
+// serves reports whether the socket can send to addr.
+func (s udpSocket) serves(addr *net.UDPAddr) bool {
+	return s.network == "udp" || (s.network == "udp4") == (addr.IP.To4() != nil)
+}
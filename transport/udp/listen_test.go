@@ -0,0 +1,42 @@
+package udp
+
+import (
+	"net"
+	"testing"
+
+	"online-server-go/game"
+	"online-server-go/transport"
+)
+
+// TestDualStackAddrIP checks that an IPv4 client of the dual-stack socket an
+// empty BIND_ADDRESSES opens gets the same client IP as on an IPv4 socket.
+func TestDualStackAddrIP(t *testing.T) {
+	sockets, err := listenUDP(transport.BindAddrs(&game.Config{}, "0"), 1)
+	if err != nil {
+		t.Skipf("no dual-stack socket: %v", err)
+	}
+	conn := sockets[0].conn
+	defer conn.Close()
+	if sockets[0].network != "udp" {
+		t.Fatalf("network = %q, want udp", sockets[0].network)
+	}
+
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	client, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 16)
+	_, from, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transport.AddrIP(from); got != "127.0.0.1" {
+		t.Errorf("AddrIP(%v) = %q, want 127.0.0.1", from, got)
+	}
+}
@@ -0,0 +1,62 @@
+package udp
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+// TestMain runs the tests from the repository root, where the migrations
+// the in-memory databases are created with are. Fuzzing workers start in
+// the directory the tests moved to, so the root is found from this file.
+func TestMain(m *testing.M) {
+	_, file, _, _ := runtime.Caller(0)
+	if err := os.Chdir(filepath.Join(filepath.Dir(file), "..", "..")); err != nil {
+		panic(err)
+	}
+	logrus.SetLevel(logrus.ErrorLevel)
+	os.Exit(m.Run())
+}
+
+// newTestUDPServer creates a UDP game configured from the environment on an
+// in-memory database, listening on a free loopback port. It is not run:
+// tests hand it their inputs directly.
+func newTestUDPServer(tb testing.TB) *UDPGameServer {
+	tb.Helper()
+	config := game.LoadConfig()
+	config.DatabaseURL = "sqlite::memory:"
+	config.Features, _ = game.ParseFeatures(config.ServerProfile, config.DisabledFeatures)
+
+	database, err := store.NewDatabase("sqlite3", config.DatabaseURL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	replication, err := transport.NewReplication(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cluster, err := transport.NewCluster(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	accounts, err := game.NewAccounts(database, config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	random := game.NewSimRand(config)
+	events := game.NewWorldEvents(config.WorldEventInterval, random)
+	entities := game.NewEntityRegistry(game.NewWorldBounds(config), random)
+	rules := game.NewRules(config.RulesFile)
+	ugs, err := NewUDPGameServer([]string{"127.0.0.1:0"}, database, config, replication, transport.NewRouter(config), cluster, events, entities, rules, accounts, nil, random)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return ugs
+}
@@ -0,0 +1,118 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+)
+
+// PacketDropPolicy decides which packet is discarded when the UDP work queue
+// is full.
+type PacketDropPolicy int
+
+const (
+	// DropNewest discards the packet that just arrived.
+	DropNewest PacketDropPolicy = iota
+	// DropOldest discards the longest-queued packet to make room, favouring
+	// fresh movement over stale input.
+	DropOldest
+)
+
+// ParsePacketDropPolicy parses UDP_DROP_POLICY. An empty name means DropNewest.
+func ParsePacketDropPolicy(name string) (PacketDropPolicy, error) {
+	switch name {
+	case "", "newest":
+		return DropNewest, nil
+	case "oldest":
+		return DropOldest, nil
+	default:
+		return DropNewest, fmt.Errorf("unknown drop policy %q, expected \"newest\" or \"oldest\"", name)
+	}
+}
+
+// maxUDPPacketSize is the MTU; longer datagrams are truncated when read.
+const maxUDPPacketSize = 1500
+
+type udpJob struct {
+	addr *net.UDPAddr
+	buf  []byte // read buffer owned by the pool
+	n    int
+}
+
+// PacketPool handles UDP packets on a fixed number of workers fed by a
+// bounded queue, so a packet flood cannot spawn unbounded goroutines. Read
+// buffers are recycled once a packet has been handled.
+type PacketPool struct {
+	queue  chan udpJob
+	free   chan []byte
+	policy PacketDropPolicy
+	handle func(addr *net.UDPAddr, data []byte)
+}
+
+// NewPacketPool starts the workers. handle must not keep data after it
+// returns.
+func NewPacketPool(workers, queueSize int, policy PacketDropPolicy, handle func(addr *net.UDPAddr, data []byte)) *PacketPool {
+	pool := &PacketPool{
+		queue:  make(chan udpJob, queueSize),
+		free:   make(chan []byte, workers+queueSize),
+		policy: policy,
+		handle: handle,
+	}
+	// One buffer per worker covers the steady state; bursts allocate more,
+	// which are kept up to the capacity of the queue
+	for i := 0; i < workers; i++ {
+		pool.free <- make([]byte, maxUDPPacketSize)
+		go pool.work()
+	}
+	return pool
+}
+
+func (p *PacketPool) work() {
+	for job := range p.queue {
+		metrics.SetGauge("udp_queue_depth", float64(len(p.queue)))
+		p.handle(job.addr, job.buf[:job.n])
+		p.Release(job.buf)
+	}
+}
+
+// ReadBuffer returns a buffer to read the next packet into.
+func (p *PacketPool) ReadBuffer() []byte {
+	select {
+	case buf := <-p.free:
+		return buf
+	default:
+		return make([]byte, maxUDPPacketSize)
+	}
+}
+
+// Release returns a read buffer to the pool.
+func (p *PacketPool) Release(buf []byte) {
+	select {
+	case p.free <- buf:
+	default:
+	}
+}
+
+// Submit queues a packet, dropping one according to the policy when the
+// queue is full. It never blocks the read loop.
+func (p *PacketPool) Submit(job udpJob) {
+	for {
+		select {
+		case p.queue <- job:
+			metrics.SetGauge("udp_queue_depth", float64(len(p.queue)))
+			return
+		default:
+		}
+
+		if p.policy == DropNewest {
+			metrics.Inc("udp_packets_dropped")
+			p.Release(job.buf)
+			return
+		}
+		select {
+		case dropped := <-p.queue:
+			metrics.Inc("udp_packets_dropped")
+			p.Release(dropped.buf)
+		default:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package udp
+
+// receiveWindowSize is how many sequences behind the newest one a packet may
+// arrive and still be handled. It covers several seconds of a client sending
+// at a high tick rate, longer than a retransmission normally takes.
+const receiveWindowSize = 1024
+
+type packetVerdict int
+
+const (
+	packetNew packetVerdict = iota
+	// packetDuplicate has been handled before, typically a retransmission
+	// whose ack was lost.
+	packetDuplicate
+	// packetStale is too old for the window to tell whether it was handled.
+	packetStale
+)
+
+// ReceiveWindow remembers which of the latest receiveWindowSize sequences of
+// a client have been received, so that retransmitted packets are handled
+// once. Sequences compare with wraparound. The zero value is ready to use;
+// it is not safe for concurrent use.
+type ReceiveWindow struct {
+	highest uint32
+	seen    [receiveWindowSize / 64]uint64
+	started bool
+}
+
+// Receive classifies a sequence and marks it as received.
+func (w *ReceiveWindow) Receive(sequence uint32) packetVerdict {
+	if !w.started {
+		w.started = true
+		w.highest = sequence
+		w.mark(sequence)
+		return packetNew
+	}
+
+	ahead := int32(sequence - w.highest)
+	if ahead > 0 {
+		// Forget the sequences that slide out of the window
+		if ahead >= receiveWindowSize {
+			w.seen = [receiveWindowSize / 64]uint64{}
+		} else {
+			for s := w.highest + 1; s != sequence; s++ {
+				w.clear(s)
+			}
+		}
+		w.highest = sequence
+		w.mark(sequence)
+		return packetNew
+	}
+
+	if -ahead >= receiveWindowSize {
+		return packetStale
+	}
+	if w.marked(sequence) {
+		return packetDuplicate
+	}
+	w.mark(sequence)
+	return packetNew
+}
+
+// Highest returns the newest sequence received.
+func (w *ReceiveWindow) Highest() uint32 {
+	return w.highest
+}
+
+// Ahead reports whether a sequence is newer than every one received, so
+// that it cannot have been received before. It is false until a sequence
+// has been received.
+func (w *ReceiveWindow) Ahead(sequence uint32) bool {
+	return w.started && int32(sequence-w.highest) > 0
+}
+
+func (w *ReceiveWindow) mark(sequence uint32) {
+	bit := sequence % receiveWindowSize
+	w.seen[bit/64] |= 1 << (bit % 64)
+}
+
+func (w *ReceiveWindow) clear(sequence uint32) {
+	bit := sequence % receiveWindowSize
+	w.seen[bit/64] &^= 1 << (bit % 64)
+}
+
+func (w *ReceiveWindow) marked(sequence uint32) bool {
+	bit := sequence % receiveWindowSize
+	return w.seen[bit/64]&(1<<(bit%64)) != 0
+}
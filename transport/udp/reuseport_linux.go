@@ -0,0 +1,23 @@
+package udp
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReusePortSupported reports whether the kernel balances datagrams among
+// sockets sharing a port, which UDP_SOCKETS relies on.
+const ReusePortSupported = true
+
+// reusePort is a net.ListenConfig Control setting SO_REUSEPORT.
+func reusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package udp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortSupported is false where SO_REUSEPORT, if any, does not balance
+// datagrams among the sockets sharing a port.
+const reusePortSupported = false
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("UDP_SOCKETS above 1 is only supported on Linux")
+}
@@ -0,0 +1,70 @@
+package udp
+
+import "time"
+
+const (
+	// initialRTO applies until a client's first round trip is measured.
+	initialRTO = 200 * time.Millisecond
+	minRTO     = 50 * time.Millisecond
+	maxRTO     = 2 * time.Second
+	// maxBackoff bounds the wait between retransmissions of one packet.
+	maxBackoff = 5 * time.Second
+	// maxRetransmits unacknowledged resends of a packet drop the client.
+	maxRetransmits = 8
+)
+
+// RTTEstimator keeps a smoothed round-trip time and derives the
+// retransmission timeout from it as in RFC 6298. The zero value is ready to
+// use. It is not safe for concurrent use.
+type RTTEstimator struct {
+	srtt    time.Duration
+	rttvar  time.Duration
+	rto     time.Duration
+	sampled bool
+}
+
+// Sample adds a measured round trip. Only packets that were never resent
+// may be sampled, since an ack for a resent packet is ambiguous.
+func (e *RTTEstimator) Sample(rtt time.Duration) {
+	if !e.sampled {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+		e.sampled = true
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		e.rttvar = (3*e.rttvar + delta) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+
+	e.rto = e.srtt + 4*e.rttvar
+	if e.rto < minRTO {
+		e.rto = minRTO
+	}
+	if e.rto > maxRTO {
+		e.rto = maxRTO
+	}
+}
+
+// SRTT returns the smoothed round-trip time, or 0 before the first sample.
+func (e *RTTEstimator) SRTT() time.Duration {
+	return e.srtt
+}
+
+// Timeout returns how long to wait for an ack of a packet that has been
+// resent the given number of times, doubling with every retransmission.
+func (e *RTTEstimator) Timeout(retries int) time.Duration {
+	timeout := initialRTO
+	if e.sampled {
+		timeout = e.rto
+	}
+	for i := 0; i < retries && timeout < maxBackoff; i++ {
+		timeout *= 2
+	}
+	if timeout > maxBackoff {
+		timeout = maxBackoff
+	}
+	return timeout
+}
@@ -0,0 +1,3098 @@
+// Package udp serves the game over UDP with UDPGameServer: reliable delivery
+// on top of datagrams, packet signing and encryption, batched reads and
+// writes, and congestion control.
+package udp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+type UDPClient struct {
+	ID          uuid.UUID
+	addr        atomic.Pointer[net.UDPAddr] // see Addr
+	Player      *game.Player
+	LastSeen    time.Time
+	Sequence    uint32
+	AckSequence uint32
+	PendingAcks map[uint32]*PendingPacket
+	SessionID   *int64
+	Encoding    game.Encoding // chosen by the client's first packet
+	BinaryMoves bool          // receives PlayerMove and GameState as binary frames
+	Gzip        bool          // receives large packets compressed, see compression.go
+	RTT         RTTEstimator
+	Budget      SendBudget
+	Received    ReceiveWindow
+	Quality     game.ConnectionQuality
+	Secret      []byte // signs the client's packets, see udpsign.go
+	signing     bool   // the client has signed a packet, so unsigned ones are refused
+	mu          sync.RWMutex
+
+	// deferredMoves holds the latest thinned move of each player, keyed by
+	// the moving player, until the client may be sent moves again
+	deferredMoves map[uuid.UUID][]byte
+
+	// hidden holds the players the client was told have gone out of sight
+	hidden game.HiddenSet
+
+	bandwidth *game.Bandwidth
+
+	// ProtocolVersion is the version declared by the client's first
+	// Heartbeat, see game/protocol.go
+	ProtocolVersion int
+}
+
+type PendingPacket struct {
+	Data      []byte    // serialized once and resent as is
+	SentAt    time.Time // first send, for measuring the round trip
+	Timestamp time.Time // last send
+	Retries   int
+}
+
+func NewUDPClient(id uuid.UUID, addr *net.UDPAddr, name string, sessionID *int64, encoding game.Encoding) *UDPClient {
+	player := game.NewPlayer(id, name)
+	client := &UDPClient{
+		ID:          id,
+		Player:      player,
+		LastSeen:    time.Now(),
+		Sequence:    0,
+		AckSequence: 0,
+		PendingAcks: make(map[uint32]*PendingPacket),
+		SessionID:   sessionID,
+		Encoding:    encoding,
+		bandwidth:   game.NewBandwidth("udp", 0),
+	}
+	client.addr.Store(addr)
+	return client
+}
+
+// Addr returns the address the client is sent to. It is read on every send
+// and swapped when the client migrates, see udpsign.go.
+func (uc *UDPClient) Addr() *net.UDPAddr {
+	return uc.addr.Load()
+}
+
+func (uc *UDPClient) UpdatePosition(x, y float32) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	now := time.Now()
+	uc.Player.MoveTo(x, y, now)
+	uc.LastSeen = now
+}
+
+// Steer sets the position and returns the velocity the player now moves at.
+// The UDP server has no game loop; snapshots extrapolate steered players.
+func (uc *UDPClient) Steer(x, y, vx, vy, maxSpeed float32) (float32, float32) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	now := time.Now()
+	uc.Player.Steer(x, y, vx, vy, maxSpeed, now)
+	uc.LastSeen = now
+	return uc.Player.VX, uc.Player.VY
+}
+
+func (uc *UDPClient) Stop() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.Stop(time.Now())
+}
+
+func (uc *UDPClient) UpdateHealth(health float32) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.Health = health
+}
+
+func (uc *UDPClient) AddScore(points uint32) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.Score += points
+}
+
+// AddPoints changes the score by points, never below zero, returning the
+// new score and the points applied.
+func (uc *UDPClient) AddPoints(points int64) (uint32, int64) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	applied := uc.Player.AddPoints(points)
+	return uc.Player.Score, applied
+}
+
+// ApplyDamage reduces health by amount, never below zero. It reports false
+// if the player was already dead.
+func (uc *UDPClient) ApplyDamage(amount float32) (float32, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.Player.ApplyDamage(amount, time.Now())
+}
+
+// Respawn brings a dead player back with health once delay has passed,
+// returning the respawned player.
+func (uc *UDPClient) Respawn(delay time.Duration, health float32, now time.Time) (game.Player, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if !uc.Player.Respawn(delay, health, now) {
+		return game.Player{}, false
+	}
+	return *uc.Player, true
+}
+
+// Push gives the player an impulse and returns the player.
+func (uc *UDPClient) Push(vx, vy float32, now time.Time) game.Player {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.Push(vx, vy, now)
+	return *uc.Player
+}
+
+// SettlePush moves a pushed player onto where its impulse has carried it by
+// now and returns that position. It reports false once no push is moving
+// the player.
+func (uc *UDPClient) SettlePush(world game.WorldBounds, now time.Time) (float32, float32, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if !uc.Player.Pushed() {
+		return uc.Player.X, uc.Player.Y, false
+	}
+	uc.Player.SettlePush(now)
+	world.Confine(uc.Player)
+	return uc.Player.X, uc.Player.Y, uc.Player.Pushed()
+}
+
+// PlaceRespawned moves a respawned player to the respawn point of their zone
+// farthest from players.
+func (uc *UDPClient) PlaceRespawned(zones *game.Zones, players []game.Player, now time.Time) game.Player {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	zones.Respawn(uc.Player, players, now)
+	return *uc.Player
+}
+
+// Heal restores up to amount health, capped at MaxHealth. It reports false if
+// the player is dead or already at full health.
+func (uc *UDPClient) Heal(amount float32) (float32, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.Player.Health <= 0 || uc.Player.Health >= game.MaxHealth {
+		return uc.Player.Health, false
+	}
+	uc.Player.Health += amount
+	if uc.Player.Health > game.MaxHealth {
+		uc.Player.Health = game.MaxHealth
+	}
+	return uc.Player.Health, true
+}
+
+// AddXP adds experience and recomputes the level. It returns the new XP
+// total and the level before and after.
+func (uc *UDPClient) AddXP(amount int64) (int64, int, int) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	oldLevel := uc.Player.Level
+	uc.Player.XP += amount
+	uc.Player.Level = game.LevelForXP(uc.Player.XP)
+	return uc.Player.XP, oldLevel, uc.Player.Level
+}
+
+func (uc *UDPClient) SetGuildTag(tag string) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.GuildTag = tag
+}
+
+func (uc *UDPClient) SetTeam(team string) game.Player {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.Team = team
+	return *uc.Player
+}
+
+// MarkActive records meaningful input from the player, see
+// Player.MarkActive.
+func (uc *UDPClient) MarkActive(now time.Time) (wasAFK, rejoin bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.Player.MarkActive(now)
+}
+
+// CheckIdle runs an AFK check of the player and returns the player with
+// what the server must do about it.
+func (uc *UDPClient) CheckIdle(afk *game.AFKPolicy, now time.Time) (game.Player, game.AFKStep) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	step := afk.Check(uc.Player, now)
+	return *uc.Player, step
+}
+
+// Spend starts the cooldown of an ability and pays its cost, see
+// Player.Spend, returning the player.
+func (uc *UDPClient) Spend(ability game.Ability, now time.Time) (game.Player, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	err := uc.Player.Spend(ability, now)
+	return *uc.Player, err
+}
+
+// PrivateState returns the PrivateState message due to the player, see
+// PrivateChannel.Update.
+func (uc *UDPClient) PrivateState(private *game.PrivateChannel, now time.Time) (game.GameMessage, bool) {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return private.Update(*uc.Player, now)
+}
+
+// Cooldowns returns when each ability is ready again for the player.
+func (uc *UDPClient) Cooldowns(abilities *game.Abilities) []game.AbilityCooldown {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return abilities.Cooldowns(*uc.Player)
+}
+
+// Zone returns the zone the player is in.
+func (uc *UDPClient) Zone() string {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.Player.Zone
+}
+
+// EnterZone moves the player into a zone and returns the player.
+func (uc *UDPClient) EnterZone(zone game.Zone) game.Player {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Player.EnterZone(zone, time.Now())
+	return *uc.Player
+}
+
+// PlayerSnapshot returns a copy of the player state.
+func (uc *UDPClient) PlayerSnapshot() game.Player {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return *uc.Player
+}
+
+func (uc *UDPClient) NextSequence() uint32 {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Sequence++
+	return uc.Sequence
+}
+
+// MarkSigning records that the client signs its packets.
+func (uc *UDPClient) MarkSigning() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.signing = true
+}
+
+// Signing reports whether the client has signed a packet.
+func (uc *UDPClient) Signing() bool {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.signing
+}
+
+// ReceivePacket classifies the sequence of an incoming packet and marks it as
+// received.
+func (uc *UDPClient) ReceivePacket(sequence uint32) packetVerdict {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	verdict := uc.Received.Receive(sequence)
+	if verdict == packetNew {
+		uc.Quality.OnReceive(sequence)
+	}
+	return verdict
+}
+
+// SequenceAhead reports whether a sequence is newer than every one received
+// from the client.
+func (uc *UDPClient) SequenceAhead(sequence uint32) bool {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.Received.Ahead(sequence)
+}
+
+func (uc *UDPClient) RecordPing(rtt time.Duration) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.Quality.OnPing(rtt)
+}
+
+// ReportQuality closes the current measurement interval. It also reports
+// whether the previous interval was degraded.
+func (uc *UDPClient) ReportQuality() (game.ConnectionQualityData, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	wasDegraded := uc.Quality.Last().Degraded
+	return uc.Quality.Report(uc.Received.Highest()), wasDegraded
+}
+
+func (uc *UDPClient) LastQuality() game.ConnectionQualityData {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.Quality.Last()
+}
+
+// IsTimeout reports whether the client has been silent for longer than
+// timeout.
+func (uc *UDPClient) IsTimeout(timeout time.Duration) bool {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return time.Since(uc.LastSeen) > timeout
+}
+
+func (uc *UDPClient) AddPendingAck(sequence uint32, data []byte) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	now := time.Now()
+	uc.PendingAcks[sequence] = &PendingPacket{
+		Data:      data,
+		SentAt:    now,
+		Timestamp: now,
+	}
+	uc.Budget.OnSend(len(data))
+	uc.Quality.OnReliableSent()
+}
+
+// RemovePendingAck handles an ack, measuring the round trip when the packet
+// was only sent once.
+func (uc *UDPClient) RemovePendingAck(sequence uint32) bool {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	pending, exists := uc.PendingAcks[sequence]
+	if exists {
+		delete(uc.PendingAcks, sequence)
+		if pending.Retries == 0 {
+			uc.RTT.Sample(time.Since(pending.SentAt))
+		}
+		uc.Budget.OnAck(len(pending.Data), pending.Retries > 0)
+	}
+	return exists
+}
+
+// DueRetransmits returns the pending packets whose retransmission timeout
+// has passed, up to the client's send window, and counts them as resent. It
+// reports false once a packet has been resent maxRetransmits times without
+// an ack.
+func (uc *UDPClient) DueRetransmits(now time.Time) ([][]byte, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	var due [][]byte
+	var size int
+	for _, pending := range uc.PendingAcks {
+		if now.Sub(pending.Timestamp) < uc.RTT.Timeout(pending.Retries) {
+			continue
+		}
+		if pending.Retries >= maxRetransmits {
+			return nil, false
+		}
+		// The rest waits for the next round rather than flooding a bad link
+		if len(due) > 0 && size+len(pending.Data) > uc.Budget.Window() {
+			continue
+		}
+		pending.Retries++
+		pending.Timestamp = now
+		due = append(due, pending.Data)
+		size += len(pending.Data)
+	}
+	if len(due) > 0 {
+		uc.Budget.OnLoss(now, uc.RTT.SRTT())
+		uc.Quality.OnRetransmits(len(due))
+	}
+	return due, true
+}
+
+// ShouldSendMove reports whether a move of moverID may be sent now. When it
+// may not, the caller hands the encoded move to DeferMove instead.
+func (uc *UDPClient) ShouldSendMove(moverID uuid.UUID, now time.Time) bool {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if uc.bandwidth.Thinning(now) || !uc.Budget.AllowMove(now, uc.RTT.SRTT()) {
+		return false
+	}
+	// The move being sent supersedes any thinned one
+	delete(uc.deferredMoves, moverID)
+	return true
+}
+
+func (uc *UDPClient) DeferMove(moverID uuid.UUID, data []byte) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if uc.deferredMoves == nil {
+		uc.deferredMoves = make(map[uuid.UUID][]byte)
+	}
+	uc.deferredMoves[moverID] = data
+}
+
+// TakeDeferredMoves returns the thinned moves once the client may be sent
+// moves again, so every player's latest position still arrives.
+func (uc *UDPClient) TakeDeferredMoves(now time.Time) [][]byte {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if len(uc.deferredMoves) == 0 || uc.bandwidth.Thinning(now) || !uc.Budget.AllowMove(now, uc.RTT.SRTT()) {
+		return nil
+	}
+	moves := make([][]byte, 0, len(uc.deferredMoves))
+	for moverID, data := range uc.deferredMoves {
+		moves = append(moves, data)
+		delete(uc.deferredMoves, moverID)
+	}
+	return moves
+}
+
+// TrackVisible records whether the client sees another player and reports
+// whether it must be told with PlayerHidden that the player went out of
+// sight.
+func (uc *UDPClient) TrackVisible(playerID uuid.UUID, visible bool) bool {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if uc.hidden == nil {
+		uc.hidden = make(game.HiddenSet)
+	}
+	return uc.hidden.Update(playerID, visible)
+}
+
+// SmoothedRTT returns the client's smoothed round-trip time, or 0 before
+// the first measurement.
+func (uc *UDPClient) SmoothedRTT() time.Duration {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.RTT.SRTT()
+}
+
+type UDPGameServer struct {
+	sockets      []udpSocket           // one per BIND_ADDRESSES entry, see listen.go
+	clients      map[string]*UDPClient // key: addr.String()
+	clientByID   map[uuid.UUID]string  // key: client ID, value: addr.String()
+	database     store.Store
+	stats        *game.StatsTracker
+	matches      *game.MatchTracker
+	matchmaker   *game.Matchmaker
+	scoreboard   *game.Scoreboard
+	friends      *game.FriendManager
+	parties      *game.PartyManager
+	guilds       *game.GuildManager
+	mailbox      *game.Mailbox
+	inventory    *game.InventoryManager
+	progression  *game.Progression
+	leaderboards *game.Leaderboards
+	persist      *game.PersistQueue
+	bus          *game.EventBus
+	plugins      *game.Plugins
+	maintenance  *game.Maintenance
+	replication  *transport.Replication
+	router       *transport.Router
+	cluster      *transport.Cluster
+	events       *game.WorldEvents
+	entities     *game.EntityRegistry
+	rules        *game.Rules
+	accounts     *game.Accounts
+	encryption   *UDPEncryption
+	capacity     *game.Capacity
+	info         *game.ServerInfoHandler
+	mustSign     bool // drop unsigned packets of registered clients
+	minVersion   int  // oldest protocol version accepted
+	features     game.Features
+	limits       game.MessageLimits
+	compressAt   int // COMPRESSION_THRESHOLD
+	world        game.WorldBounds
+	zones        *game.Zones
+	modes        *game.RoomModes
+	votes        *game.VoteManager
+	afk          *game.AFKPolicy
+	abilities    *game.Abilities
+	private      *game.PrivateChannel
+	visibility   *game.Visibility
+	anticheat    *game.AntiCheat
+	mutes        *game.Mutes
+	reports      *game.Reports
+	bandwidthCap int
+	timeout      time.Duration // UDP_CLIENT_TIMEOUT
+	readBatch    int           // UDP_READ_BATCH
+	senders      *udpSenders
+	keepalive    time.Duration // UDP_KEEPALIVE_INTERVAL
+	moveIndexes  *game.PlayerIndexTable
+	mu           sync.RWMutex
+	roster       atomic.Value // []*UDPClient, see publishRosterLocked
+}
+
+func NewUDPGameServer(addrs []string, database store.Store, config *game.Config, replication *transport.Replication, router *transport.Router, cluster *transport.Cluster, events *game.WorldEvents, entities *game.EntityRegistry, rules *game.Rules, accounts *game.Accounts, plugins *game.Plugins, random *game.SimRand) (*UDPGameServer, error) {
+	dropPolicy, err := ParsePacketDropPolicy(config.UDPDropPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	encryption, err := NewUDPEncryption(config)
+	if err != nil {
+		return nil, err
+	}
+
+	sockets, err := listenUDP(addrs, config.UDPSockets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on UDP: %w", err)
+	}
+
+	logrus.Infof("UDP Game server listening on: %s", strings.Join(addrs, ", "))
+	if config.UDPSockets > 1 {
+		logrus.Infof("Sharding UDP packets across %d sockets per address with SO_REUSEPORT", config.UDPSockets)
+	}
+
+	var matches *game.MatchTracker
+	var matchmaker *game.Matchmaker
+	var scoreboard *game.Scoreboard
+	if config.Features.Matchmaking {
+		matches = game.NewMatchTracker(database, "udp", config.MatchDuration, random)
+		matchmaker = game.NewMatchmaker(database, config.MatchRoomSize, config.GameMode)
+		scoreboard = game.NewScoreboard(database, config.ScoreboardInterval)
+	}
+
+	persist := game.NewPersistQueue()
+	leaderboards := game.NewLeaderboards(database)
+	anticheat := game.NewAntiCheat(config, rules, game.NewWorldBounds(config))
+	zones := game.NewZones(database, config)
+	abilities := game.NewAbilities(config)
+	private := game.NewPrivateChannel(rules, abilities)
+	server := &UDPGameServer{
+		sockets:      sockets,
+		clients:      make(map[string]*UDPClient),
+		clientByID:   make(map[uuid.UUID]string),
+		database:     database,
+		stats:        game.NewStatsTracker(database, persist),
+		matches:      matches,
+		matchmaker:   matchmaker,
+		scoreboard:   scoreboard,
+		friends:      game.NewFriendManager(database),
+		parties:      game.NewPartyManager(matchmaker, matches),
+		guilds:       game.NewGuildManager(database),
+		mailbox:      game.NewMailbox(database),
+		inventory:    game.NewInventoryManager(database, events, private),
+		progression:  game.NewProgression(database),
+		leaderboards: leaderboards,
+		persist:      persist,
+		bus:          game.NewGameEventBus(database, config.Features, persist, leaderboards, anticheat),
+		anticheat:    anticheat,
+		mutes:        game.NewMutes(),
+		reports:      game.NewReports(database, config),
+		bandwidthCap: config.BandwidthCap,
+		timeout:      config.UDPClientTimeout,
+		readBatch:    config.UDPReadBatch,
+		keepalive:    config.UDPKeepaliveInterval,
+		plugins:      plugins,
+		replication:  replication,
+		router:       router,
+		cluster:      cluster,
+		events:       events,
+		entities:     entities,
+		rules:        rules,
+		accounts:     accounts,
+		encryption:   encryption,
+		capacity:     game.NewCapacity(config.MaxPlayers, 0),
+		features:     config.Features,
+		limits:       game.NewMessageLimits(config),
+		compressAt:   config.CompressionThreshold,
+		mustSign:     config.UDPRequireSignatures,
+		minVersion:   config.MinProtocolVersion,
+		world:        game.NewWorldBounds(config),
+		zones:        zones,
+		modes:        game.NewRoomModes(config, game.NewModeField(config, zones)),
+		votes:        game.NewVoteManager(rules),
+		afk:          game.NewAFKPolicy(config),
+		abilities:    abilities,
+		private:      private,
+		visibility:   game.NewVisibility(config, game.NewWorldBounds(config)),
+		moveIndexes:  game.NewPlayerIndexTable(),
+	}
+
+	server.info = game.NewServerInfoHandler(config, "udp", server)
+	server.maintenance = game.NewMaintenance(server)
+	// Each socket has its own share of the workers, so that sockets sharing
+	// a port are read and handled in parallel
+	workers, queueSize := max(config.UDPWorkers/len(sockets), 1), max(config.UDPQueueSize/len(sockets), 1)
+	for i := range server.sockets {
+		server.sockets[i].pool = NewPacketPool(workers, queueSize, dropPolicy, server.decodePacket)
+	}
+	server.senders = newUDPSenders(config.UDPSenders, config.UDPSendQueue, server.writeBatch)
+
+	replication.SetSnapshotSource(server.snapshotPlayers)
+	cluster.Attach(server, server.snapshotPlayers)
+	events.Attach(server.BroadcastAll)
+	entities.Attach(server.broadcastEntityChange)
+	rules.Attach(server.BroadcastAll)
+	plugins.Attach(server)
+	server.bus.Subscribe(plugins.HandleEvent)
+
+	// Start background tasks
+	go server.startHeartbeatTask()
+	go server.startWorldEventTask()
+	go server.startRespawnTask()
+	go server.startCleanupTask()
+	go server.startReliabilityTask()
+	go server.startMatchTask()
+	go server.startModeTask()
+	go server.startVoteTask()
+	go server.startPrivateStateTask()
+	if server.afk != nil {
+		go server.startAFKTask()
+	}
+	if server.anticheat != nil {
+		go server.startAntiCheatTask()
+	}
+
+	return server, nil
+}
+
+// Run handles packets until the sockets are closed.
+func (ugs *UDPGameServer) Run() error {
+	var wg sync.WaitGroup
+	for _, socket := range ugs.sockets[1:] {
+		wg.Add(1)
+		go func(socket udpSocket) {
+			defer wg.Done()
+			socket.read(ugs.readBatch)
+		}(socket)
+	}
+	ugs.sockets[0].read(ugs.readBatch)
+	wg.Wait()
+	return nil
+}
+
+// Close closes the sockets, which ends Run, and stops the senders.
+func (ugs *UDPGameServer) Close() error {
+	for _, socket := range ugs.sockets {
+		socket.conn.Close()
+	}
+	ugs.senders.Close()
+	return nil
+}
+
+// read hands the packets arriving on the socket to its pool until the
+// socket is closed, batch at a time when batch is above 1.
+func (s udpSocket) read(batch int) {
+	if batch > 1 {
+		s.readBatches(batch)
+		return
+	}
+	for {
+		buf := s.pool.ReadBuffer()
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			s.pool.Release(buf)
+			return
+		}
+		if err != nil {
+			s.pool.Release(buf)
+			logrus.Errorf("UDP recv error: %v", err)
+			continue
+		}
+		s.pool.Submit(udpJob{addr: addr, buf: buf, n: n})
+	}
+}
+
+// decodePacket runs on a worker of the packet pool. data is only valid until
+// it returns. A panic anywhere from opening the datagram to handling it
+// disconnects the client instead of the whole server.
+func (ugs *UDPGameServer) decodePacket(addr *net.UDPAddr, data []byte) {
+	defer game.RecoverHandler("udp", addr.String(), data, func() {
+		ugs.disconnectClient(context.Background(), addr.String())
+	})
+
+	ctx, span := game.StartMessageSpan(context.Background(), "udp", uuid.Nil, len(data))
+	defer span.End()
+
+	buf := game.GetBuffer()
+	defer game.PutBuffer(buf)
+	data, ok := ugs.openDatagram(addr, data, buf)
+	if !ok {
+		return
+	}
+	data, ok = ugs.verifyDatagram(addr, data)
+	if !ok {
+		return
+	}
+
+	packet, err := game.DeserializeUDPPacket(data)
+	if err != nil {
+		logrus.Warnf("Failed to deserialize packet from %s", addr)
+		game.RecordSpanError(ctx, err)
+		return
+	}
+	game.NameMessageSpan(span, "udp", packet.Message.Type)
+	if err := ugs.limits.Check(&packet.Message); err != nil {
+		logrus.Warnf("Rejected %s from %s: %v", packet.Message.Type, addr, err)
+		game.RecordSpanError(ctx, err)
+		ugs.rejectInput(addr, &packet.Message, packet.Sequence, err)
+		return
+	}
+	ugs.handlePacket(ctx, addr, packet, data)
+}
+
+func (ugs *UDPGameServer) handlePacket(ctx context.Context, addr *net.UDPAddr, packet *game.UDPPacket, raw []byte) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if exists {
+		game.SetSpanPlayer(ctx, client.ID)
+		game.DefaultPacketTracer.Record("in", "udp", client.ID, game.DefaultRoom, raw)
+		client.bandwidth.Received(len(raw))
+		if !ugs.acceptSequence(client, addr, packet) {
+			return
+		}
+	}
+
+	if !ugs.features.Allows(packet.Message.Type) {
+		ugs.rejectDisabled(addr, &packet.Message, packet.Sequence)
+		return
+	}
+
+	if exists && game.ChatMessage(packet.Message.Type) {
+		if err := ugs.mutes.Check(client.ID, time.Now()); err != nil {
+			ugs.sendAck(addr, packet.Sequence)
+			errorMsg := game.NewReply(&packet.Message, game.NewInputErrorMessage(err))
+			ugs.sendReliableToClient(client, &errorMsg)
+			return
+		}
+	}
+
+	if exists && game.MeaningfulInput(packet.Message.Type) {
+		ugs.markActive(ctx, client)
+	}
+
+	if exists && game.PausableMessage(packet.Message.Type) && ugs.matchmaker.Paused(client.ID) {
+		ugs.sendAck(addr, packet.Sequence)
+		errorMsg := game.NewReply(&packet.Message, game.NewErrorMessage(packet.Message.Type+" is not allowed while your room is paused"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	if exists && game.PausableMessage(packet.Message.Type) && client.PlayerSnapshot().Health <= 0 {
+		ugs.sendAck(addr, packet.Sequence)
+		errorMsg := game.NewReply(&packet.Message, game.NewErrorMessage(packet.Message.Type+" is not allowed while you are dead"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	if game.ActiveChaos.Disconnect() {
+		logrus.Warnf("Chaos: disconnecting UDP client %s", addr)
+		ugs.disconnectClient(ctx, addr.String())
+		return
+	}
+	game.ActiveChaos.Delay()
+
+	ctx, span := game.StartHandleSpan(ctx, packet.Message.Type)
+	defer span.End()
+
+	switch packet.Message.Type {
+	case "Heartbeat":
+		if data, ok := packet.Message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil {
+					if sequence, ok := data["sequence"].(float64); ok {
+						binaryMoves := hasCapability(data, game.CapabilityBinaryMoves)
+						gzip := hasCapability(data, capabilityGzip)
+						sentAt, _ := data["sent_at"].(float64)
+						token, _ := data["token"].(string)
+						version := game.LegacyProtocolVersion
+						if declared, ok := data["protocol_version"].(float64); ok {
+							version = int(declared)
+						}
+						ugs.handleHeartbeat(ctx, addr, playerID, uint32(sequence), int64(sentAt), token, version, packet.Encoding, binaryMoves, gzip)
+					}
+				}
+			}
+		}
+	case "Ack":
+		if data, ok := packet.Message.Data.(map[string]interface{}); ok {
+			if sequence, ok := data["sequence"].(float64); ok {
+				sentAt, _ := data["sent_at"].(float64)
+				ugs.handleAck(addr, uint32(sequence), int64(sentAt))
+			}
+		}
+	case "PlayerMove":
+		if data, ok := packet.Message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil {
+					if x, ok := data["x"].(float64); ok {
+						if y, ok := data["y"].(float64); ok {
+							vx, vy, steering := game.MoveVelocity(data)
+							ugs.handlePlayerMove(ctx, addr, playerID, float32(x), float32(y), vx, vy, steering, packet.Sequence)
+						}
+					}
+				}
+			}
+		}
+	case "PlayerAction":
+		if data, ok := packet.Message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil {
+					if action, ok := data["action"].(string); ok {
+						ugs.handlePlayerAction(ctx, addr, playerID, action, data["data"], packet.Sequence)
+					}
+				}
+			}
+		}
+	case "Chat":
+		if data, ok := packet.Message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil {
+					if message, ok := data["message"].(string); ok {
+						ugs.handleChat(ctx, addr, playerID, message, packet.Sequence)
+					}
+				}
+			}
+		}
+	case "PlayerStatsRequest":
+		var targetID *uuid.UUID
+		if data, ok := packet.Message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil {
+					targetID = &playerID
+				}
+			}
+		}
+		ugs.handlePlayerStatsRequest(ctx, addr, &packet.Message, targetID, packet.Sequence)
+	case "FriendAdd", "FriendAccept", "FriendRemove", "FriendListRequest":
+		ugs.handleFriendMessage(ctx, addr, &packet.Message, packet.Sequence)
+	case "Whisper":
+		ugs.handleWhisper(addr, &packet.Message, packet.Sequence)
+	case "PrivacySettings":
+		ugs.handlePrivacySettings(ctx, addr, &packet.Message, packet.Sequence)
+	case "GetPreferences", "SetPreferences":
+		ugs.handlePreferences(ctx, addr, &packet.Message, packet.Sequence)
+	case "LeaderboardRequest":
+		ugs.handleLeaderboardRequest(ctx, addr, &packet.Message, packet.Sequence)
+	case "PartyInvite", "PartyAccept", "PartyLeave", "PartyChat":
+		ugs.handlePartyMessage(ctx, addr, &packet.Message, packet.Sequence)
+	case "MailSend", "MailListRequest", "MailRead", "MailDelete":
+		ugs.handleMailMessage(ctx, addr, &packet.Message, packet.Sequence)
+	case "GuildCreate", "GuildJoin", "GuildLeave", "GuildPromote", "GuildInfoRequest", "GuildChat", "GuildLeaderboardRequest":
+		ugs.handleGuildMessage(ctx, addr, &packet.Message, packet.Sequence)
+	case "ChangeZone":
+		ugs.handleChangeZone(ctx, addr, &packet.Message, packet.Sequence)
+	case "CreateRoom":
+		ugs.handleCreateRoom(ctx, addr, &packet.Message, packet.Sequence)
+	case "PauseRoom", "ResumeRoom":
+		ugs.handleOwnerPauseRoom(addr, &packet.Message, packet.Sequence)
+	case "StartVote":
+		ugs.handleStartVote(addr, &packet.Message, packet.Sequence)
+	case "CastVote":
+		ugs.handleCastVote(addr, &packet.Message, packet.Sequence)
+	case "UseAbility":
+		ugs.handleUseAbility(ctx, addr, &packet.Message, packet.Sequence)
+	case "Moderate":
+		ugs.handleModerate(ctx, addr, &packet.Message, packet.Sequence)
+	case "ReportPlayer":
+		ugs.handleReportPlayer(ctx, addr, &packet.Message, packet.Sequence)
+	case "UseItem", "DropItem":
+		ugs.handleInventoryMessage(ctx, addr, &packet.Message, packet.Sequence)
+	case "ServerInfoRequest":
+		ugs.handleServerInfoRequest(addr, &packet.Message, packet.Encoding, len(raw))
+	case "AddressEchoRequest":
+		ugs.handleAddressEchoRequest(addr, &packet.Message, packet.Encoding, len(raw))
+	}
+}
+
+// acceptSequence drops packets of a registered client that were already
+// handled or are too old to tell, so that retransmissions are not applied
+// twice. Sequence 0 marks unsequenced packets such as acks. Reliable packets
+// are acked again since the client resends them until an ack arrives.
+func (ugs *UDPGameServer) acceptSequence(client *UDPClient, addr *net.UDPAddr, packet *game.UDPPacket) bool {
+	if packet.Sequence == 0 {
+		return true
+	}
+
+	switch client.ReceivePacket(packet.Sequence) {
+	case packetDuplicate:
+		metrics.Inc("udp_duplicate_packets")
+	case packetStale:
+		metrics.Inc("udp_stale_packets")
+		logrus.Debugf("Dropped stale packet %d from %s", packet.Sequence, addr)
+	default:
+		return true
+	}
+	if packet.Reliable {
+		ugs.sendAck(addr, packet.Sequence)
+	}
+	return false
+}
+
+// hasCapability reports whether a Heartbeat lists a capability.
+func hasCapability(data map[string]interface{}, name string) bool {
+	capabilities, _ := data["capabilities"].([]interface{})
+	for _, capability := range capabilities {
+		if capability == name {
+			return true
+		}
+	}
+	return false
+}
+
+// handleHeartbeat registers a new client or refreshes a known one. The ack
+// echoes sentAt so that clients can measure their ping.
+func (ugs *UDPGameServer) handleHeartbeat(ctx context.Context, addr *net.UDPAddr, playerID uuid.UUID, sequence uint32, sentAt int64, token string, version int, encoding game.Encoding, binaryMoves, gzip bool) {
+	client, joined, refuse := ugs.registerHeartbeat(ctx, addr, playerID, token, version, encoding, binaryMoves, gzip)
+	if refuse != nil {
+		refuse()
+		return
+	}
+
+	if joined {
+		ugs.welcome(ctx, client)
+	} else if client != nil {
+		// Update last seen for existing client
+		client.mu.Lock()
+		client.LastSeen = time.Now()
+		client.AckSequence = sequence
+		client.mu.Unlock()
+	}
+
+	// Send ACK
+	ugs.writeAck(addr, game.NewAckMessage(sequence, sentAt))
+}
+
+// registerHeartbeat is the part of handleHeartbeat done under ugs.mu. It
+// returns the client of addr and whether it has just joined, or, if the
+// heartbeat is refused, what to tell the sender once the lock is released.
+func (ugs *UDPGameServer) registerHeartbeat(ctx context.Context, addr *net.UDPAddr, playerID uuid.UUID, token string, version int, encoding game.Encoding, binaryMoves, gzip bool) (client *UDPClient, joined bool, refuse func()) {
+	ugs.mu.Lock()
+	defer ugs.mu.Unlock()
+
+	addrStr := addr.String()
+	if client, exists := ugs.clients[addrStr]; exists {
+		return client, false, nil
+	}
+
+	// Version 1 clients cannot sign, so they are refused when signing is required
+	minVersion := ugs.minVersion
+	if ugs.mustSign && minVersion < 2 {
+		minVersion = 2
+	}
+	if upgrade := game.CheckProtocolVersion(version, minVersion); upgrade != nil {
+		return nil, false, func() { ugs.sendUpgradeRequired(addr, playerID, upgrade, version, encoding) }
+	}
+
+	// Players owned by another shard are told where to reconnect
+	if backend, local := ugs.router.Route(playerID.String()); !local {
+		return nil, false, func() { ugs.sendRedirect(addr, backend, playerID, encoding) }
+	}
+
+	// Logged-in clients send their token and use their account's ID
+	account, err := ugs.accounts.AuthenticateConnection(ctx, token)
+	if err == nil {
+		err = ugs.accounts.AuthorizePlayerID(ctx, account, playerID)
+	}
+	// The player is online from another address, which is not handed over
+	if _, online := ugs.clientByID[playerID]; err == nil && online {
+		err = game.ErrPlayerOnline
+		if account != nil {
+			err = game.ErrAccountOnline
+		}
+	}
+	if err != nil {
+		return nil, false, func() { ugs.sendAuthError(addr, playerID, err, encoding) }
+	}
+
+	if ban, err := ugs.database.GetActiveBan(ctx, playerID); err != nil {
+		logrus.Errorf("Failed to check ban for %s: %v", playerID, err)
+	} else if ban != nil {
+		return nil, false, func() { ugs.sendRefused(addr, playerID, game.DisconnectBanned, ban.Message(), version, encoding) }
+	}
+
+	if refusal := ugs.maintenance.Refusal(); refusal != "" {
+		return nil, false, func() { ugs.sendRefused(addr, playerID, game.DisconnectMaintenance, refusal, version, encoding) }
+	}
+
+	secret, err := newUDPSecret()
+	if err != nil {
+		logrus.Errorf("Failed to register UDP player %s: %v", playerID, err)
+		return nil, false, func() {}
+	}
+
+	if !ugs.capacity.TryAcquire() {
+		return nil, false, func() { ugs.sendServerFull(addr, playerID, version, encoding) }
+	}
+
+	clientName := fmt.Sprintf("Player_%s", playerID.String()[:8])
+	if account != nil {
+		clientName = account.Username
+	}
+
+	// Create session in database
+	var sessionID *int64
+	ipStr := transport.AddrIP(addr)
+	if id, err := ugs.database.CreateSession(ctx, playerID, "udp", &ipStr); err != nil {
+		logrus.Errorf("Failed to create UDP session: %v", err)
+		sessionID = nil
+	} else {
+		sessionID = &id
+	}
+
+	client = NewUDPClient(playerID, addr, clientName, sessionID, encoding)
+	client.BinaryMoves = binaryMoves
+	client.Gzip = gzip
+	client.Secret = secret
+	client.ProtocolVersion = version
+	client.bandwidth.SetCap(ugs.bandwidthCap)
+
+	// Clients failing over from a replication primary resume their player
+	if player, ok := ugs.replication.Restore(playerID); ok {
+		*client.Player = player
+		logrus.Infof("Resuming replicated UDP player %s (%s)", player.Name, playerID)
+	} else if account != nil {
+		ugs.accounts.LoadPlayer(ctx, account, client.Player)
+	}
+	ugs.progression.Load(ctx, client.Player)
+	ugs.guilds.Load(ctx, client.Player)
+	ugs.zones.Load(ctx, client.Player)
+	ugs.bus.Publish(ctx, game.PlayerJoined{Player: *client.Player, SessionID: sessionID})
+
+	ugs.clients[addrStr] = client
+	ugs.clientByID[playerID] = addrStr
+	ugs.publishRosterLocked()
+	ugs.matches.AddParticipant(playerID, ugs.matchmaker.Assign(ctx, playerID))
+	ugs.replication.PlayerUpdated(*client.Player)
+	return client, true, nil
+}
+
+// welcome sends a client that has just joined its session secret, the game
+// and its zone, and announces it to the other players.
+func (ugs *UDPGameServer) welcome(ctx context.Context, client *UDPClient) {
+	player := client.PlayerSnapshot()
+	playerID, addr := client.ID, client.Addr()
+	logrus.Infof("New UDP client connected: %s (%s) with session %v", player.Name, addr, client.SessionID)
+
+	secretMessage := game.NewSessionSecretMessage(playerID, client.Secret)
+	ugs.sendReliableToClient(client, &secretMessage)
+
+	// Binary clients learn the new player's index before its moves
+	if index, ok := ugs.moveIndexes.Assign(playerID); ok {
+		indexMessage := game.NewPlayerIndexMessage([]game.PlayerIndexEntry{{Index: index, PlayerID: playerID, Name: player.Name}})
+		for _, other := range ugs.recipients([]uuid.UUID{playerID}) {
+			if other.BinaryMoves {
+				ugs.sendReliableToClient(other, &indexMessage)
+			}
+		}
+	}
+
+	// Send join message to the other clients in the zone
+	joinMsg := game.NewPlayerJoinMessage(playerID, player.Name)
+	ugs.broadcastZoneReliable(ctx, player.Zone, &joinMsg, playerID)
+	zoneMessage := game.NewZoneChangedMessage(player.Zone, player.X, player.Y)
+	ugs.sendReliableToClient(client, &zoneMessage)
+
+	// Send current game state to new client
+	ugs.sendGameStateToClient(addr)
+	ugs.inventory.SendInventory(ctx, playerID, ugs)
+	ugs.mailbox.Deliver(ctx, playerID, ugs)
+	for _, event := range ugs.events.Active() {
+		eventMessage := game.NewWorldEventMessage("started", event)
+		ugs.sendReliableToClient(client, &eventMessage)
+	}
+	rulesMessage := game.NewRulesChangedMessage(ugs.rules.Current())
+	ugs.sendReliableToClient(client, &rulesMessage)
+	cooldownsMessage := game.NewAbilityCooldownsMessage(client.Cooldowns(ugs.abilities))
+	ugs.sendReliableToClient(client, &cooldownsMessage)
+
+	ugs.cluster.PlayerOnline(playerID)
+	ugs.friends.NotifyPresence(ctx, playerID, player.Name, true, ugs.cluster.Directory(ugs))
+}
+
+// handleAck settles a reliable packet. Acks of the server's heartbeats echo
+// their send time, which gives the client's ping.
+func (ugs *UDPGameServer) handleAck(addr *net.UDPAddr, sequence uint32, sentAt int64) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+	client.RemovePendingAck(sequence)
+	if sentAt > 0 {
+		if rtt := time.Since(time.UnixMilli(sentAt)); rtt >= 0 {
+			client.RecordPing(rtt)
+		}
+	}
+}
+
+func (ugs *UDPGameServer) handlePlayerMove(ctx context.Context, addr *net.UDPAddr, playerID uuid.UUID, x, y, vx, vy float32, steering bool, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if exists && client.ID == playerID {
+		// Moves are not trusted while the server is pushing the player
+		if pushedX, pushedY, pushed := client.SettlePush(ugs.world, time.Now()); pushed {
+			metrics.Inc("position_corrections")
+			correction := game.NewPositionCorrectionMessage(playerID, pushedX, pushedY)
+			ugs.sendReliableToClient(client, &correction)
+			ugs.sendAck(addr, sequence)
+			return
+		}
+
+		from := ugs.snapshotPlayer(client, time.Now())
+		if !ugs.world.Contains(x, y) {
+			metrics.Inc("position_corrections")
+			x, y = ugs.world.Place(x, y)
+			correction := game.NewPositionCorrectionMessage(playerID, x, y)
+			ugs.sendReliableToClient(client, &correction)
+		}
+
+		if steering {
+			vx, vy = client.Steer(x, y, vx, vy, ugs.rules.Current().MaxMoveSpeed)
+		} else {
+			client.UpdatePosition(x, y)
+		}
+		ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+
+		// Move events are logged less often for UDP to avoid spam
+		ugs.bus.Publish(ctx, game.PlayerMoved{PlayerID: playerID, SessionID: client.SessionID, X: x, Y: y, VX: vx, VY: vy, FromX: from.X, FromY: from.Y, Logged: sequence%10 == 0})
+
+		// Send ACK
+		ugs.sendAck(addr, sequence)
+
+		// Broadcast move to other clients (unreliable for performance)
+		ugs.broadcastMove(client.PlayerSnapshot(), x, y, vx, vy)
+	}
+}
+
+func (ugs *UDPGameServer) handlePlayerAction(ctx context.Context, addr *net.UDPAddr, playerID uuid.UUID, action string, data interface{}, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if exists && client.ID == playerID {
+		if !ugs.plugins.Action(client.PlayerSnapshot(), action) {
+			ugs.sendAck(addr, sequence)
+			return
+		}
+
+		switch action {
+		case "attack":
+			logrus.Infof("Player %s performed attack", playerID)
+
+			// Log attack event
+			if err := ugs.database.LogEvent(ctx, playerID, client.SessionID, "attack", nil); err != nil {
+				logrus.Errorf("Failed to log UDP attack event: %v", err)
+			}
+
+			hit := false
+			if targetID, ok := game.ParseTargetID(data); ok && targetID != playerID && !ugs.anticheat.ShadowBanned(playerID) {
+				hit = ugs.applyAttack(ctx, client, targetID, ugs.rules.Current().AttackDamage)
+			}
+			ugs.bus.Publish(ctx, game.PlayerActed{PlayerID: playerID, SessionID: client.SessionID, Action: "attack", Hit: hit})
+
+		case "pickup":
+			ugs.bus.Publish(ctx, game.PlayerActed{PlayerID: playerID, SessionID: client.SessionID, Action: "pickup"})
+			ugs.bus.Publish(ctx, game.ItemPickedUp{PlayerID: playerID, SessionID: client.SessionID, EntityID: game.PickupEntity(data)})
+			if ugs.anticheat.ShadowBanned(playerID) {
+				break
+			}
+
+			points := ugs.events.ScalePoints(ugs.rules.Current().PickupScore)
+			client.AddScore(uint32(points))
+			newScore := client.Player.Score
+			ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+			logrus.Infof("Player %s picked up item, score: %d", playerID, newScore)
+			ugs.bus.Publish(ctx, game.ScoreChanged{PlayerID: playerID, SessionID: client.SessionID, Score: newScore, Points: points, Reason: "pickup"})
+
+			ugs.stats.RecordItemCollected(ctx, playerID)
+			ugs.matches.AddPoints(playerID, points)
+			ugs.inventory.Pickup(ctx, playerID, data, ugs)
+			ugs.awardXP(ctx, client, "pickup")
+
+		default:
+			arena := ugs.modeArena(ctx, game.RoomOf(ugs.matchmaker, playerID), time.Now())
+			if !ugs.modes.PlayerAction(arena, client.PlayerSnapshot(), action, data) {
+				logrus.Infof("Unknown action: %s from player %s", action, playerID)
+			}
+		}
+
+		// Send ACK
+		ugs.sendAck(addr, sequence)
+	}
+}
+
+// applyAttack deals damage to the target of an attacker in the same zone and
+// reports whether it did.
+func (ugs *UDPGameServer) applyAttack(ctx context.Context, attacker *UDPClient, targetID uuid.UUID, damage float32) bool {
+	target, exists := ugs.getClientByID(targetID)
+	if !exists || target.Zone() != attacker.Zone() {
+		return false
+	}
+
+	newHealth, applied := target.ApplyDamage(damage)
+	if !applied {
+		return false
+	}
+	ugs.replication.PlayerUpdated(target.PlayerSnapshot())
+
+	if err := ugs.database.UpdatePlayerHealth(ctx, targetID, newHealth); err != nil {
+		logrus.Errorf("Failed to update UDP player health in database: %v", err)
+	}
+
+	ugs.stats.RecordDamage(attacker.ID, targetID)
+	ugs.awardXP(ctx, attacker, "hit")
+
+	if newHealth > 0 {
+		now := time.Now()
+		from := attacker.PlayerSnapshot().SnapshotAt(now)
+		if vx, vy := game.KnockbackPush(target.PlayerSnapshot().SnapshotAt(now), from.X, from.Y, ugs.rules.Current().KnockbackSpeed); vx != 0 || vy != 0 {
+			ugs.push(ctx, target, vx, vy, now)
+		}
+	}
+
+	if newHealth <= 0 {
+		logrus.Infof("Player %s killed player %s", attacker.ID, targetID)
+		ugs.stats.RecordKill(ctx, attacker.ID, targetID)
+		ugs.matches.RecordKill(attacker.ID)
+		ugs.matches.RecordDeath(targetID)
+		ugs.awardXP(ctx, attacker, "kill")
+
+		if err := ugs.database.LogEvent(ctx, attacker.ID, attacker.SessionID, "kill", nil); err != nil {
+			logrus.Errorf("Failed to log UDP kill event: %v", err)
+		}
+		if err := ugs.database.LogEvent(ctx, targetID, target.SessionID, "death", nil); err != nil {
+			logrus.Errorf("Failed to log UDP death event: %v", err)
+		}
+
+		rules := ugs.rules.Current()
+		ugs.scorePoints(ctx, attacker, ugs.events.ScalePoints(rules.KillScore), "kill_bonus")
+		ugs.scorePoints(ctx, target, -rules.DeathScorePenalty, "death_penalty")
+		diedMessage := game.NewPlayerDiedMessage(targetID, attacker.ID, rules.RespawnAt(time.Now()))
+		ugs.broadcastZoneReliable(ctx, target.Zone(), &diedMessage)
+	}
+	return true
+}
+
+// push gives a player an impulse and tells the players in its zone.
+func (ugs *UDPGameServer) push(ctx context.Context, client *UDPClient, vx, vy float32, now time.Time) {
+	player := client.Push(vx, vy, now)
+	ugs.replication.PlayerUpdated(player)
+	pushedMessage := game.NewPlayerPushedMessage(player, now)
+	ugs.broadcastReliableTo(ctx, &pushedMessage, ugs.visibleRecipients(player, now))
+}
+
+// Explode pushes the living players of a zone within radius of x, y away
+// from it, and returns how many were pushed.
+func (ugs *UDPGameServer) Explode(zone string, x, y, radius, speed float32) int {
+	now := time.Now()
+	pushed := 0
+	for _, client := range ugs.zoneRecipients(zone, nil) {
+		player := client.PlayerSnapshot().SnapshotAt(now)
+		if player.Health <= 0 {
+			continue
+		}
+		if vx, vy, inReach := game.ExplosionPush(player, x, y, radius, speed); inReach {
+			ugs.push(context.Background(), client, vx, vy, now)
+			pushed++
+		}
+	}
+	return pushed
+}
+
+// scorePoints applies a score change from the rules, which may be negative.
+func (ugs *UDPGameServer) scorePoints(ctx context.Context, client *UDPClient, points int64, reason string) {
+	if points == 0 {
+		return
+	}
+	newScore, applied := client.AddPoints(points)
+	if applied == 0 {
+		return
+	}
+	ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+	ugs.bus.Publish(ctx, game.ScoreChanged{PlayerID: client.ID, SessionID: client.SessionID, Score: newScore, Points: applied, Reason: reason})
+	ugs.matches.AddPoints(client.ID, applied)
+}
+
+// handleUseAbility checks that a client may use the ability it asked for,
+// starts its cooldown and applies its effect. The client is sent its
+// cooldowns in reply.
+func (ugs *UDPGameServer) handleUseAbility(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	var data game.UseAbilityData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil || data.Ability == "" {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("ability is required"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	now := time.Now()
+	target := func(playerID uuid.UUID) (game.Player, bool) {
+		targetClient, exists := ugs.getClientByID(playerID)
+		if !exists {
+			return game.Player{}, false
+		}
+		return ugs.snapshotPlayer(targetClient, now), true
+	}
+	ability, x, y, err := ugs.abilities.Check(ugs.snapshotPlayer(client, now), data, target)
+	var player game.Player
+	if err == nil {
+		player, err = client.Spend(ability, now)
+	}
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+	if ability.Cost > 0 {
+		ugs.replication.PlayerUpdated(player)
+		if err := ugs.database.UpdatePlayerHealth(ctx, client.ID, player.Health); err != nil {
+			logrus.Errorf("Failed to update UDP player health in database: %v", err)
+		}
+	}
+	logrus.Infof("UDP player %s used %s", client.ID, ability.Name)
+
+	hit := false
+	if !ugs.anticheat.ShadowBanned(client.ID) {
+		usedMessage := game.NewAbilityUsedMessage(client.ID, ability, data.TargetID, x, y)
+		ugs.broadcastReliableTo(ctx, &usedMessage, ugs.visibleRecipients(ugs.snapshotPlayer(client, now), now))
+		switch ability.Effect {
+		case game.AbilityDamage:
+			hit = ugs.applyAttack(ctx, client, *data.TargetID, ability.Amount)
+		case game.AbilityHeal:
+			if newHealth, healed := client.Heal(ability.Amount); healed {
+				ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+				if err := ugs.database.UpdatePlayerHealth(ctx, client.ID, newHealth); err != nil {
+					logrus.Errorf("Failed to update UDP player health in database: %v", err)
+				}
+			}
+		case game.AbilityBlast:
+			ugs.Explode(player.Zone, x, y, ability.Radius, ability.Amount)
+		}
+	}
+	ugs.bus.Publish(ctx, game.PlayerActed{PlayerID: client.ID, SessionID: client.SessionID, Action: "ability", Hit: hit})
+
+	cooldownsMessage := game.NewReply(message, game.NewAbilityCooldownsMessage(client.Cooldowns(ugs.abilities)))
+	ugs.sendReliableToClient(client, &cooldownsMessage)
+}
+
+func (ugs *UDPGameServer) handlePlayerStatsRequest(ctx context.Context, addr *net.UDPAddr, request *game.GameMessage, targetID *uuid.UUID, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	playerID := client.ID
+	if targetID != nil {
+		playerID = *targetID
+	}
+
+	stats, err := ugs.stats.GetStats(ctx, playerID)
+	if err != nil {
+		logrus.Errorf("Failed to get UDP player stats for %s: %v", playerID, err)
+		errorMsg := game.NewReply(request, game.NewErrorMessage("Failed to load player stats"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	statsMsg := game.NewReply(request, game.NewPlayerStatsMessage(playerID, stats))
+	ugs.sendReliableToClient(client, &statsMsg)
+}
+
+func (ugs *UDPGameServer) handleFriendMessage(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	ugs.friends.HandleMessage(ctx, client.ID, client.Player.Name, message, game.RepliesTo(ugs.cluster.Directory(ugs), client.ID, message))
+}
+
+func (ugs *UDPGameServer) handleLeaderboardRequest(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	ugs.leaderboards.HandleRequest(ctx, client.ID, message, game.RepliesTo(ugs, client.ID, message))
+}
+
+func (ugs *UDPGameServer) handlePrivacySettings(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	game.HandlePrivacySettings(ctx, client.ID, message, ugs.database, game.RepliesTo(ugs, client.ID, message))
+}
+
+func (ugs *UDPGameServer) handleReportPlayer(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	ugs.reports.HandleMessage(ctx, client.ID, message, game.RepliesTo(ugs, client.ID, message))
+}
+
+func (ugs *UDPGameServer) handlePreferences(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	game.HandlePreferences(ctx, client.ID, message, ugs.database, game.RepliesTo(ugs, client.ID, message))
+}
+
+func (ugs *UDPGameServer) handleWhisper(addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	game.HandleWhisper(client.ID, client.Player.Name, message, game.RepliesTo(ugs.cluster.Directory(ugs), client.ID, message))
+}
+
+func (ugs *UDPGameServer) handlePartyMessage(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	ugs.parties.HandleMessage(ctx, client.ID, client.Player.Name, message, game.RepliesTo(ugs, client.ID, message))
+}
+
+func (ugs *UDPGameServer) handleMailMessage(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	ugs.mailbox.HandleMessage(ctx, client.ID, message, game.RepliesTo(ugs.cluster.Directory(ugs), client.ID, message))
+}
+
+func (ugs *UDPGameServer) handleGuildMessage(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	setTag := func(tag string) {
+		client.SetGuildTag(tag)
+		ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+	}
+	ugs.guilds.HandleMessage(ctx, client.ID, client.Player.Name, message, setTag, game.RepliesTo(ugs.cluster.Directory(ugs), client.ID, message))
+}
+
+// handleChangeZone hands a client over to another zone: players in the old
+// zone see them leave, players in the new one see them join at its spawn
+// point.
+func (ugs *UDPGameServer) handleChangeZone(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	var data game.ChangeZoneData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil || data.Zone == "" {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("zone is required"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	from := client.Zone()
+	zone, err := ugs.zones.Enter(ctx, client.ID, from, data.Zone)
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+	ugs.handOff(ctx, client, from, zone, message)
+}
+
+// handOff moves a client from one zone to another and tells it with a
+// ZoneChanged message in reply to request, which may be nil.
+func (ugs *UDPGameServer) handOff(ctx context.Context, client *UDPClient, from string, zone game.Zone, request *game.GameMessage) {
+	player := client.EnterZone(zone)
+	ugs.replication.PlayerUpdated(player)
+	logrus.Infof("UDP player %s moved from zone %s to %s", client.ID, from, zone.Name)
+
+	leaveMessage := game.NewPlayerLeaveMessage(client.ID)
+	ugs.broadcastZoneReliable(ctx, from, &leaveMessage, client.ID)
+	joinMessage := game.NewPlayerJoinMessage(client.ID, player.Name)
+	ugs.broadcastZoneReliable(ctx, zone.Name, &joinMessage, client.ID)
+
+	zoneMessage := game.NewReply(request, game.NewZoneChangedMessage(zone.Name, zone.SpawnX, zone.SpawnY))
+	ugs.sendReliableToClient(client, &zoneMessage)
+	ugs.sendGameStateToClient(client.Addr())
+}
+
+func (ugs *UDPGameServer) handleInventoryMessage(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	heal := func(amount float32) (float32, bool) {
+		newHealth, healed := client.Heal(amount)
+		if healed {
+			ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+			if err := ugs.database.UpdatePlayerHealth(ctx, client.ID, newHealth); err != nil {
+				logrus.Errorf("Failed to update UDP player health in database: %v", err)
+			}
+		}
+		return newHealth, healed
+	}
+	ugs.inventory.HandleMessage(ctx, client.ID, message, heal, game.RepliesTo(ugs, client.ID, message))
+}
+
+// rejectDisabled answers a message for a feature turned off on this server.
+func (ugs *UDPGameServer) rejectDisabled(addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	errorMsg := game.NewReply(message, game.NewErrorMessage(message.Type+" is disabled on this server"))
+	ugs.sendReliableToClient(client, &errorMsg)
+}
+
+// rejectInput acks a registered client's packet and tells it why its
+// message was refused.
+func (ugs *UDPGameServer) rejectInput(addr *net.UDPAddr, message *game.GameMessage, sequence uint32, err error) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	ugs.sendAck(addr, sequence)
+	errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+	ugs.sendReliableToClient(client, &errorMsg)
+}
+
+func (ugs *UDPGameServer) handleChat(ctx context.Context, addr *net.UDPAddr, playerID uuid.UUID, message string, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if exists && client.ID == playerID {
+		message, err := game.SanitizeChat(message)
+		if err != nil {
+			ugs.sendAck(addr, sequence)
+			errorMsg := game.NewInputErrorMessage(err)
+			ugs.sendReliableToClient(client, &errorMsg)
+			return
+		}
+		message, send := ugs.plugins.Chat(client.PlayerSnapshot(), message)
+		if !send {
+			ugs.sendAck(addr, sequence)
+			return
+		}
+
+		chatMsg := game.NewChatMessage(playerID, message)
+		if ugs.anticheat.ShadowBanned(playerID) {
+			ugs.sendAck(addr, sequence)
+			ugs.sendReliableToClient(client, &chatMsg)
+			return
+		}
+		ugs.bus.Publish(ctx, game.ChatSent{PlayerID: playerID, SessionID: client.SessionID, Message: message})
+
+		// Send ACK
+		ugs.sendAck(addr, sequence)
+
+		// Broadcast chat message (reliable)
+		ugs.broadcastReliable(ctx, &chatMsg, playerID)
+	}
+}
+
+// sendAck is not traced; traces capture only game messages.
+func (ugs *UDPGameServer) sendAck(addr *net.UDPAddr, sequence uint32) {
+	ugs.writeAck(addr, game.NewAckMessage(sequence, 0))
+}
+
+func (ugs *UDPGameServer) writeAck(addr *net.UDPAddr, ackMessage game.GameMessage) {
+	packet := game.NewUDPPacket(0, ackMessage, false)
+	packet.Encoding = ugs.encodingFor(addr)
+
+	if err := ugs.writePacket(packet, addr, uuid.Nil); err != nil {
+		logrus.Errorf("Failed to send ACK to %s: %v", addr, err)
+	}
+}
+
+// encodingFor returns the encoding of the client at addr, or JSON if there
+// is none. It takes the read lock itself.
+func (ugs *UDPGameServer) encodingFor(addr *net.UDPAddr) game.Encoding {
+	ugs.mu.RLock()
+	defer ugs.mu.RUnlock()
+	if client, exists := ugs.clients[addr.String()]; exists {
+		return client.Encoding
+	}
+	return game.EncodingJSON
+}
+
+// sendRedirect answers an unregistered client, so it is sent unreliably.
+func (ugs *UDPGameServer) sendRedirect(addr *net.UDPAddr, backend string, playerID uuid.UUID, encoding game.Encoding) {
+	redirectMessage := game.NewRedirectMessage(backend, playerID, "")
+	packet := game.NewUDPPacket(0, redirectMessage, false)
+	packet.Encoding = encoding
+
+	logrus.Infof("Redirecting UDP player %s (%s) to %s", playerID, addr, backend)
+	if err := ugs.writePacket(packet, addr, uuid.Nil); err != nil {
+		logrus.Errorf("Failed to send redirect to %s: %v", addr, err)
+	}
+}
+
+// sendRefused turns away a client that is banned or arrives during
+// maintenance. Since it is resent on every heartbeat it is not recorded as a
+// disconnect event.
+func (ugs *UDPGameServer) sendRefused(addr *net.UDPAddr, playerID uuid.UUID, reason, message string, version int, encoding game.Encoding) {
+	disconnect := game.NewDisconnectMessage(reason, message)
+	packet := game.NewUDPPacket(0, *game.DowngradeMessage(&disconnect, version), false)
+	packet.Encoding = encoding
+
+	logrus.Infof("Refusing UDP player %s (%s): %s", playerID, addr, message)
+	if err := ugs.writePacket(packet, addr, uuid.Nil); err != nil {
+		logrus.Errorf("Failed to send Disconnect to %s: %v", addr, err)
+	}
+}
+
+// handleServerInfoRequest answers a server browser, which need not have
+// joined. The reply is only sent if it is no longer than the request, so
+// that spoofed requests cannot amplify traffic towards a victim. Browsers
+// pad their request, with trailing spaces after JSON for example.
+func (ugs *UDPGameServer) handleServerInfoRequest(addr *net.UDPAddr, request *game.GameMessage, encoding game.Encoding, requestSize int) {
+	if !ugs.replyPadded(addr, game.NewReply(request, game.NewServerInfoMessage(ugs.info.Info())), encoding, requestSize) {
+		metrics.Inc("udp_server_info_unpadded")
+	}
+}
+
+// replyPadded sends an unreliable reply to a request from addr, which need
+// not be a client, unless the reply is longer than the request. It reports
+// whether the request was padded enough to be answered.
+func (ugs *UDPGameServer) replyPadded(addr *net.UDPAddr, reply game.GameMessage, encoding game.Encoding, requestSize int) bool {
+	packet := game.NewUDPPacket(0, reply, false)
+	packet.Encoding = encoding
+
+	buf := game.GetBuffer()
+	defer game.PutBuffer(buf)
+	if err := packet.SerializeTo(buf); err != nil {
+		logrus.Errorf("Failed to encode %s: %v", reply.Type, err)
+		return true
+	}
+	if buf.Len() > requestSize {
+		logrus.Debugf("Ignored request for %s of %d bytes from %s, smaller than the %d byte reply", reply.Type, requestSize, addr, buf.Len())
+		return false
+	}
+	if err := ugs.write(buf.Bytes(), addr); err != nil {
+		logrus.Errorf("Failed to send %s to %s: %v", reply.Type, addr, err)
+	}
+	return true
+}
+
+// sendServerFull refuses a client over MAX_PLAYERS. It is sent again for
+// every heartbeat, so the client can keep trying at its own pace.
+func (ugs *UDPGameServer) sendServerFull(addr *net.UDPAddr, playerID uuid.UUID, version int, encoding game.Encoding) {
+	serverFull := game.NewServerFullMessage(0, ugs.capacity.Max())
+	packet := game.NewUDPPacket(0, *game.DowngradeMessage(&serverFull, version), false)
+	packet.Encoding = encoding
+
+	logrus.Debugf("Refusing UDP player %s (%s): server is full", playerID, addr)
+	if err := ugs.writePacket(packet, addr, uuid.Nil); err != nil {
+		logrus.Errorf("Failed to send ServerFull to %s: %v", addr, err)
+	}
+}
+
+// sendUpgradeRequired refuses a client speaking an unsupported protocol
+// version. It is resent on every heartbeat like auth errors.
+func (ugs *UDPGameServer) sendUpgradeRequired(addr *net.UDPAddr, playerID uuid.UUID, upgrade *game.GameMessage, version int, encoding game.Encoding) {
+	packet := game.NewUDPPacket(0, *game.DowngradeMessage(upgrade, version), false)
+	packet.Encoding = encoding
+
+	logrus.Debugf("Refusing UDP player %s (%s): unsupported protocol version %d", playerID, addr, version)
+	if err := ugs.writePacket(packet, addr, uuid.Nil); err != nil {
+		logrus.Errorf("Failed to send UpgradeRequired to %s: %v", addr, err)
+	}
+}
+
+// sendAuthError tells a client its heartbeat was refused by the account
+// checks. It is resent on every heartbeat until the client fixes it.
+func (ugs *UDPGameServer) sendAuthError(addr *net.UDPAddr, playerID uuid.UUID, err error, encoding game.Encoding) {
+	errorMsg := game.NewErrorMessage(game.AuthErrorMessage(err))
+	packet := game.NewUDPPacket(0, errorMsg, false)
+	packet.Encoding = encoding
+
+	logrus.Infof("Rejecting UDP player %s (%s): %v", playerID, addr, err)
+	if err := ugs.writePacket(packet, addr, uuid.Nil); err != nil {
+		logrus.Errorf("Failed to send authentication error to %s: %v", addr, err)
+	}
+}
+
+func (ugs *UDPGameServer) sendReliableToClient(client *UDPClient, message *game.GameMessage) {
+	ugs.sendReliable(client, game.NewBroadcastPayload(message))
+}
+
+func (ugs *UDPGameServer) sendUnreliableToClient(client *UDPClient, message *game.GameMessage) {
+	ugs.sendUnreliable(client, game.NewBroadcastPayload(message))
+}
+
+func (ugs *UDPGameServer) sendReliable(client *UDPClient, payload *game.BroadcastPayload) {
+	data, err := ugs.queueReliable(client, payload)
+	if err != nil {
+		logrus.Errorf("Failed to encode %s for %s: %v", payload.Message.Type, client.Addr(), err)
+		return
+	}
+	if data == nil {
+		return
+	}
+	game.DefaultPacketTracer.Record("out", "udp", client.ID, game.DefaultRoom, data)
+	client.bandwidth.Sent(len(data), 1, time.Now())
+	if err := ugs.write(data, client.Addr()); err != nil {
+		logrus.Errorf("Failed to send %s to %s: %v", payload.Message.Type, client.Addr(), err)
+	}
+}
+
+// queueReliable registers a reliable packet for retransmission until it is
+// acknowledged and returns it serialized. The packet outlives the call, so it
+// is not built in a pooled buffer. Messages the client's protocol version has
+// no equivalent of are not sent, and nil is returned.
+func (ugs *UDPGameServer) queueReliable(client *UDPClient, payload *game.BroadcastPayload) ([]byte, error) {
+	if payload = game.DowngradePayload(payload, client.ProtocolVersion); payload == nil {
+		return nil, nil
+	}
+
+	message, err := payload.Encoded(client.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	sequence := client.NextSequence()
+	if err := client.Encoding.EncodeUDPPacket(&buf, sequence, time.Now().UnixMilli(), message, true); err != nil {
+		return nil, err
+	}
+	data := client.compress(buf.Bytes(), sequence, ugs.compressAt)
+	client.AddPendingAck(sequence, data)
+	return data, nil
+}
+
+// sendUnreliable sends a message once. Snapshots and moves, which a later
+// one supersedes, are skipped while the client is over BANDWIDTH_CAP.
+func (ugs *UDPGameServer) sendUnreliable(client *UDPClient, payload *game.BroadcastPayload) {
+	if payload = game.DowngradePayload(payload, client.ProtocolVersion); payload == nil {
+		return
+	}
+	now := time.Now()
+	if game.CoalesceKey(&payload.Message) != "" && client.bandwidth.Thinning(now) {
+		client.bandwidth.Thinned(1)
+		return
+	}
+
+	message, err := payload.Encoded(client.Encoding)
+	if err != nil {
+		logrus.Errorf("Failed to encode %s for %s: %v", payload.Message.Type, client.Addr(), err)
+		return
+	}
+
+	buf := game.GetBuffer()
+	defer game.PutBuffer(buf)
+	if err := client.Encoding.EncodeUDPPacket(buf, 0, now.UnixMilli(), message, false); err != nil {
+		logrus.Errorf("Failed to encode %s for %s: %v", payload.Message.Type, client.Addr(), err)
+		return
+	}
+	data := client.compress(buf.Bytes(), 0, ugs.compressAt)
+	game.DefaultPacketTracer.Record("out", "udp", client.ID, game.DefaultRoom, data)
+	client.bandwidth.Sent(len(data), 1, now)
+	if err := ugs.write(data, client.Addr()); err != nil {
+		logrus.Errorf("Failed to send %s to %s: %v", payload.Message.Type, client.Addr(), err)
+	}
+}
+
+// writePacket serializes a packet into a pooled buffer and sends it. Packets
+// to a player are traced; pass uuid.Nil for an address that has not joined.
+func (ugs *UDPGameServer) writePacket(packet *game.UDPPacket, addr *net.UDPAddr, playerID uuid.UUID) error {
+	buf := game.GetBuffer()
+	defer game.PutBuffer(buf)
+	if err := packet.SerializeTo(buf); err != nil {
+		return err
+	}
+	if playerID != uuid.Nil {
+		game.DefaultPacketTracer.Record("out", "udp", playerID, game.DefaultRoom, buf.Bytes())
+	}
+	return ugs.write(buf.Bytes(), addr)
+}
+
+// write queues a datagram to addr on its sender, see udpsender.go. data is
+// copied, so the caller may reuse it.
+func (ugs *UDPGameServer) write(data []byte, addr *net.UDPAddr) error {
+	return ugs.senders.Send(data, addr)
+}
+
+// writeNow sends a datagram, sealed if addr has an encrypted session. Packets
+// kept for retransmission stay in plaintext and are sealed afresh each time.
+func (ugs *UDPGameServer) writeNow(data []byte, addr *net.UDPAddr) error {
+	session := ugs.encryption.Session(addr.String())
+	if session == nil {
+		_, err := ugs.socketFor(addr).conn.WriteToUDP(data, addr)
+		return err
+	}
+
+	buf := game.GetBuffer()
+	defer game.PutBuffer(buf)
+	sealed, err := session.Seal(buf.AvailableBuffer(), data)
+	if err != nil {
+		return err
+	}
+	_, err = ugs.socketFor(addr).conn.WriteToUDP(sealed, addr)
+	return err
+}
+
+// socketFor returns the socket to send to addr from, the first that serves
+// its family. With several addresses of a family bound, replies leave from
+// the first whichever the client sent to.
+func (ugs *UDPGameServer) socketFor(addr *net.UDPAddr) udpSocket {
+	for _, socket := range ugs.sockets {
+		if socket.serves(addr) {
+			return socket
+		}
+	}
+	return ugs.sockets[0]
+}
+
+// publishRosterLocked replaces the roster with the client registered for
+// each player. The roster is never modified once published, so broadcasts
+// and background tasks read it and write to the network without ugs.mu. It
+// requires ugs.mu to be held for writing.
+func (ugs *UDPGameServer) publishRosterLocked() {
+	roster := make([]*UDPClient, 0, len(ugs.clientByID))
+	for _, addrStr := range ugs.clientByID {
+		if client, exists := ugs.clients[addrStr]; exists {
+			roster = append(roster, client)
+		}
+	}
+	ugs.roster.Store(roster)
+}
+
+// rosterClients returns the connected clients as of the last change. It
+// needs no lock.
+func (ugs *UDPGameServer) rosterClients() []*UDPClient {
+	roster, _ := ugs.roster.Load().([]*UDPClient)
+	return roster
+}
+
+// recipients returns every connected client except the excluded players.
+// Clients are targeted by player ID and reached at their current address, so
+// exclusion keeps working when players share an address or change it.
+func (ugs *UDPGameServer) recipients(exclude []uuid.UUID) []*UDPClient {
+	roster := ugs.rosterClients()
+	clients := make([]*UDPClient, 0, len(roster))
+	for _, client := range roster {
+		if !containsPlayer(exclude, client.ID) {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+func containsPlayer(playerIDs []uuid.UUID, playerID uuid.UUID) bool {
+	for _, id := range playerIDs {
+		if id == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneRecipients returns the connected clients in a zone except the
+// excluded players.
+func (ugs *UDPGameServer) zoneRecipients(zone string, exclude []uuid.UUID) []*UDPClient {
+	var clients []*UDPClient
+	for _, client := range ugs.recipients(exclude) {
+		if client.Zone() == zone {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// visibleRecipients returns the connected clients in a player's zone who
+// can see the player as of now.
+func (ugs *UDPGameServer) visibleRecipients(subject game.Player, now time.Time) []*UDPClient {
+	recipients := ugs.zoneRecipients(subject.Zone, nil)
+	if ugs.visibility == nil {
+		return recipients
+	}
+	visible := recipients[:0]
+	for _, client := range recipients {
+		if ugs.visibility.SeesPlayer(ugs.snapshotPlayer(client, now), subject) {
+			visible = append(visible, client)
+		}
+	}
+	return visible
+}
+
+// snapshotPlayer returns a client's player as of now, kept within the world.
+func (ugs *UDPGameServer) snapshotPlayer(client *UDPClient, now time.Time) game.Player {
+	player := client.PlayerSnapshot().SnapshotAt(now)
+	ugs.world.Confine(&player)
+	return player
+}
+
+// broadcastReliable sends a message to every client except the excluded
+// players.
+func (ugs *UDPGameServer) broadcastReliable(ctx context.Context, message *game.GameMessage, exclude ...uuid.UUID) {
+	ugs.broadcastReliableTo(ctx, message, ugs.recipients(exclude))
+}
+
+// broadcastZoneReliable sends a message to every client in a zone except the
+// excluded players.
+func (ugs *UDPGameServer) broadcastZoneReliable(ctx context.Context, zone string, message *game.GameMessage, exclude ...uuid.UUID) {
+	ugs.broadcastReliableTo(ctx, message, ugs.zoneRecipients(zone, exclude))
+}
+
+func (ugs *UDPGameServer) broadcastReliableTo(ctx context.Context, message *game.GameMessage, recipients []*UDPClient) {
+	payload := game.NewBroadcastPayload(message)
+	_, span := game.StartBroadcastSpan(ctx, message.Type, len(recipients))
+	defer span.End()
+	for _, client := range recipients {
+		if game.ActiveChaos.DropBroadcast() {
+			// Still awaiting an ack, so retransmission has to recover it
+			ugs.queueReliable(client, payload)
+			continue
+		}
+		ugs.sendReliable(client, payload)
+	}
+}
+
+// broadcastUnreliable sends a message without acknowledgement to every client
+// except the excluded players.
+func (ugs *UDPGameServer) broadcastUnreliable(message *game.GameMessage, exclude ...uuid.UUID) {
+	payload := game.NewBroadcastPayload(message)
+	for _, client := range ugs.recipients(exclude) {
+		if game.ActiveChaos.DropBroadcast() {
+			continue
+		}
+		ugs.sendUnreliable(client, payload)
+	}
+}
+
+// broadcastMove sends a move to every other client in the mover's zone, as a
+// binary frame to clients that negotiated binary moves. Binary frames carry
+// no velocity. In fog of war, clients that cannot see the mover are told
+// once with PlayerHidden instead.
+func (ugs *UDPGameServer) broadcastMove(mover game.Player, x, y, vx, vy float32) {
+	playerID := mover.ID
+	mover.X, mover.Y = x, y
+	moveMessage := game.NewPlayerMoveMessage(playerID, x, y, vx, vy)
+	payload := game.NewBroadcastPayload(&moveMessage)
+
+	var frame []byte
+	if index, ok := ugs.moveIndexes.Lookup(playerID); ok {
+		frame = game.EncodeBinaryMove(index, x, y)
+	}
+
+	now := time.Now()
+	for _, client := range ugs.zoneRecipients(mover.Zone, []uuid.UUID{playerID}) {
+		if ugs.visibility != nil {
+			visible := ugs.visibility.SeesPlayer(ugs.snapshotPlayer(client, now), mover)
+			if client.TrackVisible(playerID, visible) {
+				hiddenMessage := game.NewPlayerHiddenMessage(playerID)
+				ugs.sendReliableToClient(client, &hiddenMessage)
+			}
+			if !visible {
+				continue
+			}
+		}
+		if game.ActiveChaos.DropBroadcast() {
+			continue
+		}
+		if !client.ShouldSendMove(playerID, now) {
+			ugs.deferMove(client, playerID, payload, frame)
+			continue
+		}
+		if !client.BinaryMoves || frame == nil {
+			ugs.sendUnreliable(client, payload)
+			continue
+		}
+		game.DefaultPacketTracer.Record("out", "udp", client.ID, game.DefaultRoom, frame)
+		client.bandwidth.Sent(len(frame), 1, now)
+		if err := ugs.write(frame, client.Addr()); err != nil {
+			logrus.Errorf("Failed to send binary move to %s: %v", client.Addr(), err)
+		}
+	}
+}
+
+// deferMove keeps a thinned move for a congested client until the
+// reliability task flushes it.
+func (ugs *UDPGameServer) deferMove(client *UDPClient, moverID uuid.UUID, payload *game.BroadcastPayload, frame []byte) {
+	metrics.Inc("udp_moves_thinned")
+	if client.BinaryMoves && frame != nil {
+		client.DeferMove(moverID, frame)
+		return
+	}
+
+	message, err := payload.Encoded(client.Encoding)
+	if err != nil {
+		logrus.Errorf("Failed to encode %s for %s: %v", payload.Message.Type, client.Addr(), err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := client.Encoding.EncodeUDPPacket(&buf, 0, time.Now().UnixMilli(), message, false); err != nil {
+		logrus.Errorf("Failed to encode %s for %s: %v", payload.Message.Type, client.Addr(), err)
+		return
+	}
+	client.DeferMove(moverID, buf.Bytes())
+}
+
+func (ugs *UDPGameServer) sendGameStateToClient(addr *net.UDPAddr) {
+	now := time.Now()
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	if !exists {
+		ugs.mu.RUnlock()
+		return
+	}
+	zone := client.Zone()
+	var players []game.Player
+	for _, other := range ugs.clients {
+		player := other.PlayerSnapshot().SnapshotAt(now)
+		if player.Zone != zone {
+			continue
+		}
+		ugs.world.Confine(&player)
+		players = append(players, player)
+	}
+	ugs.mu.RUnlock()
+	entities := ugs.entities.InZone(zone, now)
+	players, entities = ugs.visibility.Filter(ugs.snapshotPlayer(client, now), players, entities)
+	if !client.BinaryMoves {
+		gameStateMessage := game.NewGameStateMessage(players, entities, 0, now)
+		ugs.sendReliableToClient(client, &gameStateMessage)
+		return
+	}
+
+	// Binary snapshots only carry players
+	for _, entity := range entities {
+		spawnedMessage := game.NewEntitySpawnedMessage(entity)
+		ugs.sendReliableToClient(client, &spawnedMessage)
+	}
+
+	// The whole index table first, so the snapshot can be decoded
+	entries := make([]game.PlayerIndexEntry, 0, len(players))
+	for _, player := range players {
+		if index, ok := ugs.moveIndexes.Lookup(player.ID); ok {
+			entries = append(entries, game.PlayerIndexEntry{Index: index, PlayerID: player.ID, Name: player.Name})
+		}
+	}
+	indexMessage := game.NewPlayerIndexMessage(entries)
+	ugs.sendReliableToClient(client, &indexMessage)
+
+	sequence := client.NextSequence()
+	frame := game.EncodeBinaryGameState(sequence, players, ugs.moveIndexes)
+	client.AddPendingAck(sequence, frame)
+	game.DefaultPacketTracer.Record("out", "udp", client.ID, game.DefaultRoom, frame)
+	if err := ugs.write(frame, addr); err != nil {
+		logrus.Errorf("Failed to send binary game state to %s: %v", addr, err)
+	}
+}
+
+func (ugs *UDPGameServer) startHeartbeatTask() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ugs.entities.Prune(time.Now())
+			for _, client := range ugs.rosterClients() {
+				ugs.reportQuality(client)
+
+				// Sent reliably so that the ack measures the round trip of
+				// clients that are otherwise quiet
+				heartbeat := game.NewHeartbeatMessage(client.ID, 0, time.Now().UnixMilli())
+				ugs.sendReliableToClient(client, &heartbeat)
+			}
+		}
+	}
+}
+
+// reportQuality sends a client the quality of its link since the last
+// heartbeat and logs when the link degrades or recovers.
+func (ugs *UDPGameServer) reportQuality(client *UDPClient) {
+	report, wasDegraded := client.ReportQuality()
+	switch {
+	case report.Degraded && !wasDegraded:
+		metrics.Inc("udp_connections_degraded")
+		logrus.Warnf("Connection of %s (%s) degraded: ping %.0fms, jitter %.0fms, loss %.1f%%, retransmits %.1f%%",
+			client.ID, client.Addr(), report.PingMs, report.JitterMs, report.PacketLoss*100, report.RetransmitRate*100)
+	case !report.Degraded && wasDegraded:
+		logrus.Infof("Connection of %s (%s) recovered: ping %.0fms, loss %.1f%%", client.ID, client.Addr(), report.PingMs, report.PacketLoss*100)
+	}
+
+	message := game.NewConnectionQualityMessage(report)
+	ugs.sendUnreliableToClient(client, &message)
+}
+
+func (ugs *UDPGameServer) startCleanupTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(ugs.timeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ugs.mu.Lock()
+			var toRemove []string
+			var clientIDs []uuid.UUID
+			var removed []*UDPClient
+
+			// Check for timed out clients
+			for addrStr, client := range ugs.clients {
+				if client.IsTimeout(ugs.timeout) {
+					toRemove = append(toRemove, addrStr)
+					clientIDs = append(clientIDs, client.ID)
+					removed = append(removed, client)
+				}
+			}
+
+			// Remove timed out clients
+			for i, addrStr := range toRemove {
+				clientID := clientIDs[i]
+				delete(ugs.clients, addrStr)
+				delete(ugs.clientByID, clientID)
+				ugs.capacity.Release()
+				ugs.stats.Forget(clientID)
+				ugs.private.Forget(clientID)
+				ugs.anticheat.Forget(clientID)
+				ugs.moveIndexes.Release(clientID)
+				ugs.replication.PlayerRemoved(clientID)
+				logrus.Infof("Removed timed out UDP client: %s (%s)", clientID, addrStr)
+			}
+			if len(toRemove) > 0 {
+				ugs.publishRosterLocked()
+			}
+			ugs.mu.Unlock()
+			ugs.encryption.Sweep()
+
+			for _, client := range removed {
+				ugs.notifyDisconnect(ctx, client, game.DisconnectTimeout, "connection timed out")
+				ugs.endSession(ctx, client)
+				ugs.parties.Leave(client.ID, ugs)
+				ugs.matchmaker.Remove(client.ID)
+				ugs.cluster.PlayerOffline(client.ID)
+				ugs.friends.NotifyPresence(ctx, client.ID, client.Player.Name, false, ugs.cluster.Directory(ugs))
+			}
+		}
+	}
+}
+
+// startReliabilityTask resends unacknowledged packets once the client's
+// retransmission timeout has passed, drops clients that stop acking and
+// delivers the moves thinned for congested clients.
+func (ugs *UDPGameServer) startReliabilityTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(25 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, client := range ugs.rosterClients() {
+				due, ok := client.DueRetransmits(now)
+				if !ok {
+					metrics.Inc("udp_retransmit_drops")
+					logrus.Warnf("Dropping UDP client %s (%s): no ack after %d retransmissions (srtt %s)",
+						client.ID, client.Addr(), maxRetransmits, client.SmoothedRTT())
+					ugs.dropClient(ctx, client, game.DisconnectTimeout, "connection timed out")
+					continue
+				}
+				if client.bandwidth.Exceeded() {
+					logrus.Warnf("Dropping UDP client %s (%s): over BANDWIDTH_CAP", client.ID, client.Addr())
+					ugs.dropClient(ctx, client, game.DisconnectBandwidth, "bandwidth cap exceeded")
+					continue
+				}
+
+				for _, data := range due {
+					metrics.Inc("udp_retransmits")
+					game.DefaultPacketTracer.Record("out", "udp", client.ID, game.DefaultRoom, data)
+					client.bandwidth.Sent(len(data), 1, now)
+					if err := ugs.write(data, client.Addr()); err != nil {
+						logrus.Errorf("Failed to resend packet to %s: %v", client.Addr(), err)
+					}
+				}
+
+				for _, data := range client.TakeDeferredMoves(now) {
+					game.DefaultPacketTracer.Record("out", "udp", client.ID, game.DefaultRoom, data)
+					client.bandwidth.Sent(len(data), 1, now)
+					if err := ugs.write(data, client.Addr()); err != nil {
+						logrus.Errorf("Failed to send thinned move to %s: %v", client.Addr(), err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (ugs *UDPGameServer) startWorldEventTask() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ugs.events.Tick()
+		}
+	}
+}
+
+// startRespawnTask brings back dead players once the respawn delay in the
+// rules has passed.
+func (ugs *UDPGameServer) startRespawnTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rules := ugs.rules.Current()
+			delay := rules.RespawnDelay()
+			if delay <= 0 {
+				continue
+			}
+			now := time.Now()
+			roster := ugs.rosterClients()
+			for _, client := range roster {
+				if _, respawned := client.Respawn(delay, rules.RespawnHealth, now); !respawned {
+					continue
+				}
+				players := make([]game.Player, 0, len(roster))
+				for _, other := range roster {
+					players = append(players, other.PlayerSnapshot())
+				}
+				player := client.PlaceRespawned(ugs.zones, players, now)
+				logrus.Infof("Player %s respawned", client.ID)
+				ugs.replication.PlayerUpdated(player)
+				if err := ugs.database.UpdatePlayerHealth(ctx, client.ID, player.Health); err != nil {
+					logrus.Errorf("Failed to update UDP player health in database: %v", err)
+				}
+				respawnMessage := game.NewPlayerRespawnMessage(player)
+				ugs.broadcastReliableTo(ctx, &respawnMessage, ugs.visibleRecipients(player, now))
+			}
+		}
+	}
+}
+
+func (ugs *UDPGameServer) startMatchTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if ugs.matches.Expired() {
+				ugs.endMatch(ctx)
+			} else if ugs.scoreboard.Due(time.Now()) {
+				ugs.broadcastScoreboard(ctx)
+			}
+		}
+	}
+}
+
+func (ugs *UDPGameServer) endMatch(ctx context.Context) {
+	matchID, results, scores := ugs.matches.EndAndRestart(ctx)
+	scoreboard := ugs.scoreboard.Build(ctx, scores, ugs.scoreboardPlayer)
+	ugs.scoreboard.SaveResults(ctx, matchID, scoreboard)
+	ugs.bus.Publish(ctx, game.MatchEnded{MatchID: matchID, Results: results})
+	matchEndedMessage := game.NewMatchEndedMessage(matchID, results)
+
+	ugs.mu.RLock()
+	var players []*UDPClient
+	for _, client := range ugs.clients {
+		ugs.matches.AddParticipant(client.ID, ugs.matchmaker.Assign(ctx, client.ID))
+		players = append(players, client)
+	}
+	ugs.mu.RUnlock()
+
+	ugs.broadcastReliable(ctx, &matchEndedMessage)
+	if ugs.scoreboard != nil {
+		scoreboardMessage := game.NewScoreboardMessage(matchID, true, scoreboard)
+		ugs.broadcastReliable(ctx, &scoreboardMessage)
+	}
+	for _, client := range players {
+		ugs.awardXP(ctx, client, "match_played")
+	}
+}
+
+// startModeTask plays the game mode of each room, counting its own ticks
+// for the rooms' tick divisors.
+func (ugs *UDPGameServer) startModeTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(game.ModeTickInterval)
+	defer ticker.Stop()
+
+	var tick uint64
+	for {
+		select {
+		case now := <-ticker.C:
+			tick++
+			arena := func(room string) game.ModeArena {
+				return ugs.modeArena(ctx, room, now)
+			}
+			ugs.modes.Tick(ugs.modes.Rooms(ugs.matchmaker), tick, arena, now)
+		}
+	}
+}
+
+func (ugs *UDPGameServer) modeArena(ctx context.Context, room string, now time.Time) udpArena {
+	var members []*UDPClient
+	for _, client := range ugs.rosterClients() {
+		if game.RoomOf(ugs.matchmaker, client.ID) == room {
+			members = append(members, client)
+		}
+	}
+	return udpArena{ugs: ugs, ctx: ctx, room: room, now: now, members: members}
+}
+
+// udpArena is a room as its game mode sees it, with the members it had when
+// the arena was made.
+type udpArena struct {
+	ugs     *UDPGameServer
+	ctx     context.Context
+	room    string
+	now     time.Time
+	members []*UDPClient
+}
+
+func (a udpArena) Room() string {
+	return a.room
+}
+
+func (a udpArena) Players() []game.Player {
+	var players []game.Player
+	for _, client := range a.members {
+		player := client.PlayerSnapshot().SnapshotAt(a.now)
+		if player.Zone == a.ugs.modes.Field().Zone {
+			a.ugs.world.Confine(&player)
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+func (a udpArena) SetTeam(playerID uuid.UUID, team string) {
+	if client, exists := a.ugs.getClientByID(playerID); exists {
+		a.ugs.replication.PlayerUpdated(client.SetTeam(team))
+	}
+}
+
+func (a udpArena) AwardPoints(playerID uuid.UUID, points int64, reason string) {
+	if client, exists := a.ugs.getClientByID(playerID); exists {
+		a.ugs.scorePoints(a.ctx, client, points, reason)
+	}
+}
+
+func (a udpArena) Announce(message *game.GameMessage) {
+	a.ugs.broadcastReliableTo(a.ctx, message, a.members)
+}
+
+// handleCreateRoom opens a match room playing the requested game mode and
+// moves the client into it.
+func (ugs *UDPGameServer) handleCreateRoom(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	var data game.CreateRoomData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("invalid CreateRoom data"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	room, mode, err := ugs.matchmaker.Create(ctx, client.ID, data.Mode, data.TickDivisor)
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+	ugs.matches.AddParticipant(client.ID, room)
+	logrus.Infof("UDP player %s created room %s playing %q", client.ID, room, mode)
+
+	createdMessage := game.NewReply(message, game.NewRoomCreatedMessage(room, mode))
+	ugs.sendReliableToClient(client, &createdMessage)
+}
+
+// handleOwnerPauseRoom pauses or resumes the room of a client that owns it.
+func (ugs *UDPGameServer) handleOwnerPauseRoom(addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	roomID, owner := ugs.matchmaker.OwnedRoom(client.ID)
+	if !owner {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("Only the owner of a room can pause or resume it"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+	if err := ugs.PauseRoom(roomID, message.Type == "PauseRoom"); err != nil {
+		errorMsg := game.NewReply(message, game.NewErrorMessage(err.Error()))
+		ugs.sendReliableToClient(client, &errorMsg)
+	}
+}
+
+// handleStartVote starts the vote a client asked for, which the starter's
+// ballot counts for.
+func (ugs *UDPGameServer) handleStartVote(addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	var data game.StartVoteData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil || data.Type == "" {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("vote type is required"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	target, err := game.CheckVoteTarget(data.Type, data.Target, client.ID, ugs.zones, ugs.IsOnline, ugs.matches)
+	var status game.VoteStatusData
+	if err == nil {
+		status, err = ugs.votes.Start(client.ID, data.Type, target, ugs.voters(), time.Now())
+	}
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+	logrus.Infof("UDP player %s started a %s vote on %q", client.ID, data.Type, target)
+	ugs.announceVote(client, message, status)
+}
+
+// handleModerate carries out a Moderate command of a staff member.
+func (ugs *UDPGameServer) handleModerate(ctx context.Context, addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	moderation, err := game.CheckModeration(ctx, ugs.database, client.ID, message, time.Now())
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	target, online := ugs.getClientByID(moderation.Target)
+	switch moderation.Action {
+	case game.ModerateMute:
+		ugs.mutes.Mute(moderation.Target, moderation.Until)
+		if online {
+			muted := game.NewMutedMessage(moderation.Until, moderation.Reason)
+			ugs.sendReliableToClient(target, &muted)
+		}
+	case game.ModerateUnmute:
+		if !ugs.mutes.Unmute(moderation.Target, time.Now()) {
+			errorMsg := game.NewReply(message, game.NewErrorMessage("player is not muted"))
+			ugs.sendReliableToClient(client, &errorMsg)
+			return
+		}
+		if online {
+			unmuted := game.NewMutedMessage(time.Time{}, "")
+			ugs.sendReliableToClient(target, &unmuted)
+		}
+	case game.ModerateKick:
+		if !ugs.Kick(moderation.Target, game.DisconnectKicked, moderation.Reason) {
+			errorMsg := game.NewReply(message, game.NewErrorMessage("player is not online"))
+			ugs.sendReliableToClient(client, &errorMsg)
+			return
+		}
+	}
+
+	moderation.Record(ctx, ugs.database)
+	reply := moderation.Reply(message)
+	ugs.sendReliableToClient(client, &reply)
+}
+
+// handleCastVote records a client's ballot in the running vote.
+func (ugs *UDPGameServer) handleCastVote(addr *net.UDPAddr, message *game.GameMessage, sequence uint32) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Send ACK
+	ugs.sendAck(addr, sequence)
+
+	var data game.CastVoteData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("invalid CastVote data"))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+
+	status, err := ugs.votes.Cast(client.ID, data.Yes, ugs.voters(), time.Now())
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		ugs.sendReliableToClient(client, &errorMsg)
+		return
+	}
+	ugs.announceVote(client, message, status)
+}
+
+// announceVote sends everyone the standing of the vote, as a reply to the
+// client whose request changed it.
+func (ugs *UDPGameServer) announceVote(client *UDPClient, request *game.GameMessage, status game.VoteStatusData) {
+	updateMessage := game.NewVoteUpdateMessage(status)
+	ugs.broadcastReliable(context.Background(), &updateMessage, client.ID)
+	replyMessage := game.NewReply(request, updateMessage)
+	ugs.sendReliableToClient(client, &replyMessage)
+}
+
+// voters returns the IDs of the players online, who may all vote.
+func (ugs *UDPGameServer) voters() []uuid.UUID {
+	roster := ugs.rosterClients()
+	voters := make([]uuid.UUID, 0, len(roster))
+	for _, client := range roster {
+		voters = append(voters, client.ID)
+	}
+	return voters
+}
+
+// startVoteTask broadcasts the countdown of the running vote and ends it
+// once it is due.
+func (ugs *UDPGameServer) startVoteTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(game.VoteTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			update, ended := ugs.votes.Tick(ugs.voters(), now)
+			if update != nil {
+				ugs.broadcastReliable(ctx, update)
+			}
+			if ended != nil {
+				ugs.endVote(ctx, *ended)
+			}
+		}
+	}
+}
+
+// startPrivateStateTask sends each client the private state that changed
+// since its last PrivateState.
+func (ugs *UDPGameServer) startPrivateStateTask() {
+	ticker := time.NewTicker(game.PrivateStateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, client := range ugs.rosterClients() {
+				if privateMessage, changed := client.PrivateState(ugs.private, now); changed {
+					ugs.sendReliableToClient(client, &privateMessage)
+				}
+			}
+		}
+	}
+}
+
+// startAFKTask warns players who have gone idle and removes those idle for
+// too long from the match, or the server.
+func (ugs *UDPGameServer) startAFKTask() {
+	ctx := context.Background()
+	ticker := time.NewTicker(game.AFKCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, client := range ugs.rosterClients() {
+				player, step := client.CheckIdle(ugs.afk, now)
+				switch step {
+				case game.AFKWarn:
+					logrus.Infof("UDP player %s is AFK", client.ID)
+					warningMessage := game.NewAFKWarningMessage(ugs.afk.RemovalAt(player), ugs.afk.Kick())
+					ugs.sendReliableToClient(client, &warningMessage)
+					afkMessage := game.NewPlayerAFKMessage(client.ID, true, false)
+					ugs.broadcastZoneReliable(ctx, player.Zone, &afkMessage, client.ID)
+
+				case game.AFKRemove:
+					afkMessage := game.NewPlayerAFKMessage(client.ID, true, true)
+					if err := ugs.database.LogEvent(ctx, client.ID, client.SessionID, "afk_kick", &afkMessage); err != nil {
+						logrus.Errorf("Failed to log afk_kick event: %v", err)
+					}
+					if ugs.afk.Kick() {
+						ugs.Kick(client.ID, game.DisconnectAFK, "disconnected for being idle")
+						continue
+					}
+					logrus.Infof("UDP player %s was taken out of the match for being idle", client.ID)
+					ugs.matchmaker.Remove(client.ID)
+					ugs.matches.RemoveParticipant(client.ID)
+					ugs.broadcastZoneReliable(ctx, player.Zone, &afkMessage)
+				}
+			}
+		}
+	}
+}
+
+// startAntiCheatTask kicks the players the anti-cheat decided to kick.
+func (ugs *UDPGameServer) startAntiCheatTask() {
+	ticker := time.NewTicker(game.AntiCheatSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, playerID := range ugs.anticheat.Sweep(now) {
+				ugs.Kick(playerID, game.DisconnectCheating, "disconnected by the anti-cheat")
+			}
+		}
+	}
+}
+
+// markActive records meaningful input from a client, bringing them back
+// from AFK and into a match if they had been taken out.
+func (ugs *UDPGameServer) markActive(ctx context.Context, client *UDPClient) {
+	wasAFK, rejoin := client.MarkActive(time.Now())
+	if !wasAFK {
+		return
+	}
+	if rejoin {
+		ugs.matches.AddParticipant(client.ID, ugs.matchmaker.Assign(ctx, client.ID))
+	}
+	logrus.Infof("UDP player %s is back from AFK", client.ID)
+	afkMessage := game.NewPlayerAFKMessage(client.ID, false, false)
+	ugs.broadcastZoneReliable(ctx, client.Zone(), &afkMessage)
+}
+
+// endVote announces the outcome of a vote and enforces it if it passed.
+func (ugs *UDPGameServer) endVote(ctx context.Context, ended game.VoteEndedData) {
+	logrus.Infof("The UDP %s vote on %q ended with %d yes and %d no of %d players, passed: %t", ended.Type, ended.Target, ended.Yes, ended.No, ended.Eligible, ended.Passed)
+	endedMessage := game.NewVoteEndedMessage(ended)
+	ugs.broadcastReliable(ctx, &endedMessage)
+	if !ended.Passed {
+		return
+	}
+
+	switch ended.Type {
+	case game.VoteKickPlayer:
+		if playerID, err := uuid.Parse(ended.Target); err == nil {
+			ugs.Kick(playerID, game.DisconnectKicked, "kicked by a vote")
+		}
+	case game.VoteNextMap:
+		for _, client := range ugs.rosterClients() {
+			from := client.Zone()
+			if zone, err := ugs.zones.Enter(ctx, client.ID, from, ended.Target); err == nil {
+				ugs.handOff(ctx, client, from, zone, nil)
+			}
+		}
+	case game.VoteRestartMatch:
+		ugs.endMatch(ctx)
+	}
+}
+
+// broadcastScoreboard sends everyone the standings of the current match,
+// unreliably since the next one supersedes it.
+func (ugs *UDPGameServer) broadcastScoreboard(ctx context.Context) {
+	scoreboard := ugs.scoreboard.Build(ctx, ugs.matches.Scores(), ugs.scoreboardPlayer)
+	if len(scoreboard) == 0 {
+		return
+	}
+	scoreboardMessage := game.NewScoreboardMessage(ugs.matches.CurrentMatchID(), false, scoreboard)
+	ugs.broadcastUnreliable(&scoreboardMessage)
+}
+
+// scoreboardPlayer names an online player on the scoreboard with their
+// smoothed round-trip time.
+func (ugs *UDPGameServer) scoreboardPlayer(playerID uuid.UUID) (string, time.Duration, bool) {
+	client, exists := ugs.getClientByID(playerID)
+	if !exists {
+		return "", 0, false
+	}
+	return client.PlayerSnapshot().Name, client.SmoothedRTT(), true
+}
+
+// awardXP grants XP for an action and broadcasts LevelUp on a new level.
+func (ugs *UDPGameServer) awardXP(ctx context.Context, client *UDPClient, action string) {
+	level, leveledUp := ugs.progression.Award(ctx, client.ID, action, client.AddXP)
+	ugs.replication.PlayerUpdated(client.PlayerSnapshot())
+
+	if leveledUp {
+		levelUpMessage := game.NewLevelUpMessage(client.ID, level, client.PlayerSnapshot().XP)
+		ugs.broadcastReliable(ctx, &levelUpMessage)
+	}
+}
+
+func (ugs *UDPGameServer) getClientByID(playerID uuid.UUID) (*UDPClient, bool) {
+	ugs.mu.RLock()
+	defer ugs.mu.RUnlock()
+
+	addrStr, exists := ugs.clientByID[playerID]
+	if !exists {
+		return nil, false
+	}
+	client, exists := ugs.clients[addrStr]
+	return client, exists
+}
+
+// disconnectClient drops the client registered at addrStr, if any.
+func (ugs *UDPGameServer) disconnectClient(ctx context.Context, addrStr string) {
+	ugs.mu.Lock()
+	client, exists := ugs.clients[addrStr]
+	if !exists {
+		ugs.mu.Unlock()
+		return
+	}
+
+	delete(ugs.clients, addrStr)
+	delete(ugs.clientByID, client.ID)
+	ugs.capacity.Release()
+	ugs.publishRosterLocked()
+	ugs.stats.Forget(client.ID)
+	ugs.private.Forget(client.ID)
+	ugs.anticheat.Forget(client.ID)
+	ugs.moveIndexes.Release(client.ID)
+	ugs.replication.PlayerRemoved(client.ID)
+	ugs.mu.Unlock()
+
+	logrus.Warnf("Disconnected UDP client: %s (%s)", client.ID, addrStr)
+	ugs.endSession(ctx, client)
+	ugs.parties.Leave(client.ID, ugs)
+	ugs.matchmaker.Remove(client.ID)
+	ugs.cluster.PlayerOffline(client.ID)
+	ugs.friends.NotifyPresence(ctx, client.ID, client.Player.Name, false, ugs.cluster.Directory(ugs))
+}
+
+// endSession tells the zone of a removed client that it left, publishes
+// its leave and closes its session.
+func (ugs *UDPGameServer) endSession(ctx context.Context, client *UDPClient) {
+	leaveMsg := game.NewPlayerLeaveMessage(client.ID)
+	ugs.broadcastZoneReliable(ctx, client.Zone(), &leaveMsg, client.ID)
+	ugs.bus.Publish(ctx, game.PlayerLeft{PlayerID: client.ID, SessionID: client.SessionID})
+
+	if client.SessionID != nil {
+		if err := ugs.database.EndSession(ctx, *client.SessionID); err != nil {
+			logrus.Errorf("Failed to end UDP session: %v", err)
+		}
+	}
+}
+
+// PauseRoom freezes or resumes a match room: members stop where they are,
+// their gameplay messages are refused and they are told the room's state.
+func (ugs *UDPGameServer) PauseRoom(roomID string, paused bool) error {
+	members, err := ugs.matchmaker.SetPaused(roomID, paused)
+	if err != nil {
+		return err
+	}
+
+	message := game.NewRoomPauseMessage(roomID, paused)
+	for _, playerID := range members {
+		client, exists := ugs.getClientByID(playerID)
+		if !exists {
+			continue
+		}
+		if paused {
+			client.Stop()
+		}
+		ugs.sendReliableToClient(client, &message)
+	}
+
+	logrus.Infof("%s match room %s with %d players", message.Type, roomID, len(members))
+	return nil
+}
+
+// Rooms lists the match rooms for the admin API.
+func (ugs *UDPGameServer) Rooms() []game.RoomInfo {
+	return ugs.matchmaker.Rooms()
+}
+
+// Bandwidth returns the traffic of every connected player.
+func (ugs *UDPGameServer) Bandwidth() []game.PlayerBandwidth {
+	clients := ugs.rosterClients()
+	bandwidth := make([]game.PlayerBandwidth, 0, len(clients))
+	for _, client := range clients {
+		bandwidth = append(bandwidth, game.PlayerBandwidth{
+			PlayerID:       client.ID,
+			Name:           client.Player.Name,
+			Protocol:       "udp",
+			BandwidthStats: client.bandwidth.Stats(),
+		})
+	}
+	return bandwidth
+}
+
+// ConnectionQualities implements ConnectionQualitySource.
+func (ugs *UDPGameServer) ConnectionQualities() []game.PlayerConnectionQuality {
+	clients := ugs.rosterClients()
+	qualities := make([]game.PlayerConnectionQuality, 0, len(clients))
+	for _, client := range clients {
+		qualities = append(qualities, game.PlayerConnectionQuality{
+			PlayerID:              client.ID,
+			Name:                  client.Player.Name,
+			Addr:                  client.Addr().String(),
+			ConnectionQualityData: client.LastQuality(),
+		})
+	}
+	return qualities
+}
+
+// IsOnline implements PlayerDirectory.
+func (ugs *UDPGameServer) IsOnline(playerID uuid.UUID) bool {
+	_, exists := ugs.getClientByID(playerID)
+	return exists
+}
+
+// SendToPlayer implements PlayerDirectory using reliable delivery.
+func (ugs *UDPGameServer) SendToPlayer(playerID uuid.UUID, message *game.GameMessage) bool {
+	client, exists := ugs.getClientByID(playerID)
+	if !exists {
+		return false
+	}
+	ugs.sendReliableToClient(client, message)
+	return true
+}
+
+func (ugs *UDPGameServer) snapshotPlayers() []game.Player {
+	ugs.mu.RLock()
+	defer ugs.mu.RUnlock()
+
+	players := make([]game.Player, 0, len(ugs.clients))
+	for _, client := range ugs.clients {
+		players = append(players, client.PlayerSnapshot())
+	}
+	return players
+}
+
+// BroadcastAll sends a message reliably to every client.
+func (ugs *UDPGameServer) BroadcastAll(message *game.GameMessage) {
+	ugs.broadcastReliable(context.Background(), message)
+}
+
+// OnlinePlayer returns a copy of a connected player's state.
+func (ugs *UDPGameServer) OnlinePlayer(playerID uuid.UUID) (game.Player, bool) {
+	client, exists := ugs.getClientByID(playerID)
+	if !exists {
+		return game.Player{}, false
+	}
+	return client.PlayerSnapshot(), true
+}
+
+// OnlinePlayers returns a copy of every connected player's state.
+func (ugs *UDPGameServer) OnlinePlayers() []game.Player {
+	roster := ugs.rosterClients()
+	players := make([]game.Player, 0, len(roster))
+	for _, client := range roster {
+		players = append(players, client.PlayerSnapshot())
+	}
+	return players
+}
+
+// Kick sends a player a Disconnect message with the reason, one of the
+// disconnect reasons, and drops their client.
+func (ugs *UDPGameServer) Kick(playerID uuid.UUID, reason, message string) bool {
+	client, exists := ugs.getClientByID(playerID)
+	if !exists {
+		return false
+	}
+
+	logrus.Warnf("Kicking UDP player %s (%s): %s", playerID, reason, message)
+	ugs.dropClient(context.Background(), client, reason, message)
+	return true
+}
+
+// dropClient sends a client a Disconnect message, records why it was
+// dropped and disconnects it.
+func (ugs *UDPGameServer) dropClient(ctx context.Context, client *UDPClient, reason, message string) {
+	ugs.notifyDisconnect(ctx, client, reason, message)
+	ugs.disconnectClient(ctx, client.Addr().String())
+}
+
+// notifyDisconnect sends a removed client a Disconnect message, unreliably
+// since it will not be resent, and records it.
+func (ugs *UDPGameServer) notifyDisconnect(ctx context.Context, client *UDPClient, reason, message string) {
+	disconnect := game.NewDisconnectMessage(reason, message)
+	ugs.sendUnreliableToClient(client, &disconnect)
+	game.LogDisconnect(ctx, ugs.database, client.ID, client.SessionID, &disconnect)
+}
+
+// Maintenance returns the scheduler of maintenance restarts.
+func (ugs *UDPGameServer) Maintenance() *game.Maintenance {
+	return ugs.maintenance
+}
+
+// Entities returns the NPCs, items and projectiles of the world.
+func (ugs *UDPGameServer) Entities() *game.EntityRegistry {
+	return ugs.entities
+}
+
+// AntiCheat returns the anti-cheat, nil if disabled.
+func (ugs *UDPGameServer) AntiCheat() *game.AntiCheat {
+	return ugs.anticheat
+}
+
+func (ugs *UDPGameServer) Persistence() *game.PersistQueue {
+	return ugs.persist
+}
+
+// ServerInfo returns the handler describing the server, which its ping
+// replies and HTTP API share.
+func (ugs *UDPGameServer) ServerInfo() *game.ServerInfoHandler {
+	return ugs.info
+}
+
+// broadcastEntityChange tells a zone of an entity spawned or removed, since
+// there is no game loop to send it the next snapshot. In fog of war, a
+// spawned entity is only announced to the players who can see it.
+func (ugs *UDPGameServer) broadcastEntityChange(zone string, message *game.GameMessage) {
+	entity, spawned := message.Data.(game.Entity)
+	if !spawned || ugs.visibility == nil {
+		ugs.broadcastZoneReliable(context.Background(), zone, message)
+		return
+	}
+	now := time.Now()
+	var recipients []*UDPClient
+	for _, client := range ugs.zoneRecipients(zone, nil) {
+		if ugs.visibility.SeesEntity(ugs.snapshotPlayer(client, now), entity) {
+			recipients = append(recipients, client)
+		}
+	}
+	ugs.broadcastReliableTo(context.Background(), message, recipients)
+}
+
+func (ugs *UDPGameServer) GetClientCount() int {
+	return len(ugs.rosterClients())
+}
@@ -0,0 +1,115 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"online-server-go/game"
+)
+
+// BenchmarkBroadcast broadcasts a move to 100 UDP clients. Each broadcast
+// encodes the move once and queues it to the senders for the address stored
+// on every client, and is timed until the senders have taken it all.
+func BenchmarkBroadcast(b *testing.B) {
+	ugs := newBenchUDPServer(b, 100)
+	move := game.NewPlayerMoveMessage(uuid.New(), 10, 20, 1, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ugs.broadcastUnreliable(&move)
+		drainSenders(ugs)
+	}
+}
+
+// BenchmarkUDPRosterBroadcast broadcasts a move to 1,000 UDP clients while
+// joins and moves keep taking ugs.mu. It reports the longest any of them
+// waited for the lock.
+func BenchmarkUDPRosterBroadcast(b *testing.B) {
+	ugs := newBenchUDPServer(b, 1000)
+	move := game.NewPlayerMoveMessage(uuid.New(), 10, 20, 1, 0)
+	contention := contendLock(&ugs.mu)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ugs.broadcastUnreliable(&move)
+		drainSenders(ugs)
+	}
+	b.StopTimer()
+	contention.Report(b)
+}
+
+// newBenchUDPServer opens a UDP game with players clients registered
+// directly, each at its own loopback address on a port nobody reads.
+func newBenchUDPServer(tb testing.TB, players int) *UDPGameServer {
+	ugs := newTestUDPServer(tb)
+	tb.Cleanup(func() {
+		// Its background tasks outlive Close, so leave them nobody to send to
+		ugs.mu.Lock()
+		clear(ugs.clients)
+		clear(ugs.clientByID)
+		ugs.publishRosterLocked()
+		ugs.mu.Unlock()
+		ugs.Close()
+	})
+
+	ugs.mu.Lock()
+	defer ugs.mu.Unlock()
+	for i := 0; i < players; i++ {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 1, byte(i>>8), byte(i)), Port: 9}
+		client := NewUDPClient(uuid.New(), addr, fmt.Sprintf("Bench_%d", i), nil, game.EncodingJSON)
+		ugs.clients[addr.String()] = client
+		ugs.clientByID[client.ID] = addr.String()
+	}
+	ugs.publishRosterLocked()
+	return ugs
+}
+
+// drainSenders waits until the senders have taken every queued datagram, so
+// that broadcasts are not dropped for outpacing them.
+func drainSenders(ugs *UDPGameServer) {
+	for ugs.senders.depth() > 0 {
+		runtime.Gosched()
+	}
+}
+
+// lockContention takes a lock for writing every millisecond, the way
+// handlers of joins and moves do, and records the longest it waited.
+type lockContention struct {
+	stop    chan struct{}
+	done    chan struct{}
+	longest time.Duration
+}
+
+func contendLock(mu *sync.RWMutex) *lockContention {
+	c := &lockContention{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			started := time.Now()
+			mu.Lock()
+			c.longest = max(c.longest, time.Since(started))
+			mu.Unlock()
+		}
+	}()
+	return c
+}
+
+// Report stops taking the lock and reports the longest wait on b.
+func (c *lockContention) Report(b *testing.B) {
+	close(c.stop)
+	<-c.done
+	b.ReportMetric(float64(c.longest.Nanoseconds()), "max-lock-wait-ns")
+}
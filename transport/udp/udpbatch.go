@@ -0,0 +1,213 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// MaxUDPReadBatch bounds UDP_READ_BATCH, since every message of a batch
+// holds a read buffer while the socket waits.
+const MaxUDPReadBatch = 1024
+
+// batchConn reads and writes several datagrams per system call, with
+// recvmmsg and sendmmsg on Linux and one at a time elsewhere. The ipv4 and
+// ipv6 packet conns share the message type and only differ in the socket
+// options they set, so either serves a socket of any family.
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+func newBatchConn(conn *net.UDPConn) batchConn {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		return ipv4.NewPacketConn(conn)
+	}
+	return ipv6.NewPacketConn(conn)
+}
+
+// readBatches reads up to batch datagrams per call into pool buffers and
+// hands them to the pool, until the socket is closed. Under load one call
+// returns many datagrams, which amortizes the system call over them; when
+// traffic is light it returns as soon as one arrives.
+func (s udpSocket) readBatches(batch int) {
+	messages := make([]ipv4.Message, batch)
+	for i := range messages {
+		messages[i].Buffers = [][]byte{s.pool.ReadBuffer()}
+	}
+	release := func() {
+		for _, m := range messages {
+			s.pool.Release(m.Buffers[0])
+		}
+	}
+
+	for {
+		n, err := s.batch.ReadBatch(messages, 0)
+		if errors.Is(err, net.ErrClosed) {
+			release()
+			return
+		}
+		if err != nil {
+			logrus.Errorf("UDP recv error: %v", err)
+			continue
+		}
+		metrics.Inc("udp_read_batches")
+
+		for i := 0; i < n; i++ {
+			m := &messages[i]
+			addr, ok := m.Addr.(*net.UDPAddr)
+			if !ok {
+				continue
+			}
+			s.pool.Submit(udpJob{addr: addr, buf: m.Buffers[0], n: m.N})
+			m.Buffers[0] = s.pool.ReadBuffer()
+		}
+	}
+}
+
+// writeBatch sends datagrams like writeNow, those leaving from one socket in
+// one system call where batching is supported. Elsewhere x/net sends a batch
+// one datagram at a time and cannot send to IPv4 from a dual-stack socket,
+// so writeNow is used. A datagram that fails does not hold back the others;
+// the first error is returned.
+func (ugs *UDPGameServer) writeBatch(datagrams []udpDatagram) error {
+	var first error
+	fail := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+	if len(datagrams) == 1 || runtime.GOOS != "linux" {
+		for _, d := range datagrams {
+			if err := ugs.writeNow(d.data, d.addr); err != nil {
+				fail(err)
+			}
+		}
+		return first
+	}
+
+	messages := make([]ipv4.Message, 0, len(datagrams))
+	sockets := make([]udpSocket, 0, len(datagrams))
+	for _, d := range datagrams {
+		data := d.data
+		if session := ugs.encryption.Session(d.addr.String()); session != nil {
+			sealed, err := session.Seal(nil, data)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			data = sealed
+		}
+		messages = append(messages, ipv4.Message{Buffers: [][]byte{data}, Addr: d.addr})
+		sockets = append(sockets, ugs.socketFor(d.addr))
+	}
+
+	for start := 0; start < len(messages); {
+		end := start + 1
+		for end < len(messages) && sockets[end].conn == sockets[start].conn {
+			end++
+		}
+		if err := sockets[start].writeAll(messages[start:end]); err != nil {
+			fail(err)
+		}
+		start = end
+	}
+	return first
+}
+
+// writeAll sends messages with as few calls as the kernel allows. A message
+// the kernel refuses is skipped.
+func (s udpSocket) writeAll(messages []ipv4.Message) error {
+	var first error
+	for len(messages) > 0 {
+		n, err := s.batch.WriteBatch(messages, 0)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			n = 1
+		}
+		messages = messages[n:]
+	}
+	return first
+}
+
+// UDPReadResult is how a read loop fared in MeasureUDPReads.
+type UDPReadResult struct {
+	Batch    int
+	Sent     int
+	Received int
+	Elapsed  time.Duration // spent reading and handling, without the sends
+}
+
+// MeasureUDPReads measures how fast the server's read loop drains a loopback
+// socket, batch datagrams per call, into a packet pool whose handler does
+// nothing. The datagrams are sent burst at a time while nothing reads, so
+// that the sender does not compete with the reader for the CPU, and each
+// burst is then read from the socket buffer. Datagrams a burst overflowed
+// the buffer with are missing from Received. BenchmarkUDPReads and
+// cmd/udpbench compare batch sizes with it.
+func MeasureUDPReads(batch, packets, burst, size int) (UDPReadResult, error) {
+	var received, last atomic.Int64
+	pool := NewPacketPool(1, burst, DropNewest, func(addr *net.UDPAddr, data []byte) {
+		received.Add(1)
+		last.Store(time.Now().UnixNano())
+	})
+
+	result := UDPReadResult{Batch: batch}
+	payload := make([]byte, size)
+	for result.Sent < packets {
+		n := min(burst, packets-result.Sent)
+		elapsed, err := measureBurst(pool, batch, n, payload, &received, &last)
+		if err != nil {
+			return result, err
+		}
+		result.Sent += n
+		result.Elapsed += elapsed
+	}
+	result.Received = int(received.Load())
+	return result, nil
+}
+
+// measureBurst fills a fresh socket with n datagrams, then reads them and
+// returns how long it took until the last one was handled.
+func measureBurst(pool *PacketPool, batch, n int, payload []byte, received, last *atomic.Int64) (time.Duration, error) {
+	sockets, err := listenUDP([]string{"127.0.0.1:0"}, 1)
+	if err != nil {
+		return 0, err
+	}
+	socket := sockets[0]
+	socket.pool = pool
+	defer socket.conn.Close()
+	socket.conn.SetReadBuffer(8 << 20)
+
+	sender, err := net.DialUDP("udp", nil, socket.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		return 0, err
+	}
+	defer sender.Close()
+	for i := 0; i < n; i++ {
+		sender.Write(payload)
+	}
+
+	before := received.Load()
+	start := time.Now()
+	last.Store(start.UnixNano())
+	go socket.read(batch)
+
+	// Wait for the burst, or for the reader to go quiet on what it lost
+	for quiet := 0; received.Load()-before < int64(n) && quiet < 5; {
+		count := received.Load()
+		time.Sleep(2 * time.Millisecond)
+		if received.Load() == count {
+			quiet++
+		}
+	}
+	return time.Unix(0, last.Load()).Sub(start), nil
+}
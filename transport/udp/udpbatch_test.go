@@ -0,0 +1,26 @@
+package udp
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkUDPReads drains b.N datagrams from a loopback socket with the
+// single-read loop (batch=1) and with recvmmsg batches of UDP_READ_BATCH
+// sizes. ns/datagram counts only reading and handling them; sending the
+// bursts is not timed.
+func BenchmarkUDPReads(b *testing.B) {
+	for _, batch := range []int{1, 8, 32, 128} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			result, err := MeasureUDPReads(batch, b.N, 2048, 64)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if result.Received == 0 {
+				b.Fatal("no datagram was received")
+			}
+			b.ReportMetric(float64(result.Elapsed.Nanoseconds())/float64(result.Received), "ns/datagram")
+			b.ReportMetric(float64(result.Sent-result.Received)/float64(result.Sent), "lost/datagram")
+		})
+	}
+}
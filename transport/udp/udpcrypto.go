@@ -0,0 +1,469 @@
+package udp
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"online-server-go/game"
+)
+
+// UDP clients may encrypt their traffic with UDP_ENCRYPTION=optional, and
+// must with UDP_ENCRYPTION=required. The server has a long-term X25519 key
+// whose public half clients are given out of band (it is logged at startup),
+// so that only the real server can complete a handshake. Before its first
+// Heartbeat a client sends a ClientHello with a fresh X25519 key and gets a
+// ServerHello with the server's fresh key:
+//
+//	ClientHello  magic u8 (0xC2), kind u8 (1), client key [32], zero padding to 64 bytes
+//	ServerHello  magic u8 (0xC2), kind u8 (2), server key [32]
+//
+// Both sides then compute
+//
+//	secret = X25519(server ephemeral, client key) || X25519(server static, client key)
+//	c2s, s2c = HKDF-SHA256(secret, salt = client key || server key, info = "udp c2s" / "udp s2c")
+//
+// and every datagram of the session, in either direction, is sealed with
+// ChaCha20-Poly1305 under its direction's key:
+//
+//	sealed  magic u8 (0xC3), epoch u8, counter u32, ciphertext and tag
+//
+// The header is the additional data and the nonce is the epoch byte, seven
+// zero bytes and the counter. Counters start at 1 and grow by one for every
+// datagram sent, so a receiver drops any counter it has seen or that is more
+// than receiveWindowSize behind. A sender moves to the next epoch, whose key
+// is HKDF-SHA256(current key, info = "udp rotate"), every
+// udpKeyRotationPackets datagrams or udpKeyRotationInterval; receivers
+// follow and keep the previous key for datagrams still in flight.
+//
+// 0xC2 and 0xC3 are MessagePack's false and true, which never start a
+// packet, and cannot start JSON or a binary frame. The handshake is resent
+// by the client until a ServerHello arrives; a new ClientHello replaces the
+// session of its address only once the session has been quiet for
+// udpRehandshakeAfter, so spoofed hellos cannot cut off a playing client.
+const (
+	udpHandshakeMagic byte = 0xC2
+	udpSealedMagic    byte = 0xC3
+
+	udpClientHello byte = 1
+	udpServerHello byte = 2
+
+	udpClientHelloSize = 64 // padded so that the reply is never larger
+	udpServerHelloSize = 34
+	udpSealedHeader    = 6
+
+	udpKeyRotationPackets  = 1 << 20
+	udpKeyRotationInterval = 10 * time.Minute
+	udpRehandshakeAfter    = 5 * time.Second
+	// udpSessionIdle is how long a session lives without an authenticated
+	// datagram, longer than the client timeout.
+	udpSessionIdle = time.Minute
+)
+
+var (
+	errUDPDecrypt = errors.New("datagram failed authentication")
+	errUDPReplay  = errors.New("datagram was replayed or is too old")
+)
+
+// UDPEncryptionMode is the UDP_ENCRYPTION setting.
+type UDPEncryptionMode int
+
+const (
+	UDPEncryptionOff UDPEncryptionMode = iota
+	UDPEncryptionOptional
+	UDPEncryptionRequired
+)
+
+// ParseUDPEncryptionMode parses UDP_ENCRYPTION. An empty name means off.
+func ParseUDPEncryptionMode(name string) (UDPEncryptionMode, error) {
+	switch name {
+	case "", "off":
+		return UDPEncryptionOff, nil
+	case "optional":
+		return UDPEncryptionOptional, nil
+	case "required":
+		return UDPEncryptionRequired, nil
+	default:
+		return UDPEncryptionOff, fmt.Errorf("unknown mode %q, expected \"off\", \"optional\" or \"required\"", name)
+	}
+}
+
+// UDPEncryption holds the server key and the encrypted sessions by client
+// address. A nil *UDPEncryption leaves all traffic in plaintext.
+type UDPEncryption struct {
+	mode UDPEncryptionMode
+	key  *ecdh.PrivateKey
+
+	mu       sync.RWMutex
+	sessions map[string]*udpSession
+}
+
+// NewUDPEncryption loads the server key from UDP_ENCRYPTION_KEY_FILE,
+// creating it on first use. It returns nil when encryption is off.
+func NewUDPEncryption(config *game.Config) (*UDPEncryption, error) {
+	mode, err := ParseUDPEncryptionMode(config.UDPEncryption)
+	if err != nil || mode == UDPEncryptionOff {
+		return nil, err
+	}
+
+	key, err := loadUDPKey(config.UDPEncryptionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("UDP encryption is %s, server public key %s", config.UDPEncryption, hex.EncodeToString(key.PublicKey().Bytes()))
+
+	return &UDPEncryption{
+		mode:     mode,
+		key:      key,
+		sessions: make(map[string]*udpSession),
+	}, nil
+}
+
+// loadUDPKey reads a hex X25519 private key, generating and saving one if
+// the file does not exist.
+func loadUDPKey(path string) (*ecdh.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate UDP key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to save UDP key: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Bytes())+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to save UDP key: %w", err)
+		}
+		logrus.Infof("Generated UDP encryption key in %s", path)
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read UDP key: %w", err)
+	}
+
+	raw, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UDP key %s: %w", path, err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UDP key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// Required reports whether plaintext datagrams are refused.
+func (e *UDPEncryption) Required() bool {
+	return e != nil && e.mode == UDPEncryptionRequired
+}
+
+// Session returns the encrypted session of an address, or nil.
+func (e *UDPEncryption) Session(addr string) *udpSession {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.sessions[addr]
+}
+
+// Handshake answers a ClientHello, starting a session for addr.
+func (e *UDPEncryption) Handshake(addr string, hello []byte) ([]byte, error) {
+	if len(hello) != udpClientHelloSize || hello[1] != udpClientHello {
+		return nil, fmt.Errorf("malformed ClientHello of %d bytes", len(hello))
+	}
+	clientKey, err := ecdh.X25519().NewPublicKey(hello[2:34])
+	if err != nil {
+		return nil, fmt.Errorf("invalid client key: %w", err)
+	}
+
+	e.mu.RLock()
+	existing := e.sessions[addr]
+	e.mu.RUnlock()
+	if existing != nil && existing.Active(udpRehandshakeAfter) {
+		return nil, fmt.Errorf("session of %s is still active", addr)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate handshake key: %w", err)
+	}
+	ephemeralSecret, err := ephemeral.ECDH(clientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree on a key: %w", err)
+	}
+	staticSecret, err := e.key.ECDH(clientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree on a key: %w", err)
+	}
+
+	c2s, s2c, err := udpSessionKeys(append(ephemeralSecret, staticSecret...), clientKey.Bytes(), ephemeral.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	session, err := newUDPSession(s2c, c2s)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.sessions[addr] = session
+	e.mu.Unlock()
+	metrics.Inc("udp_handshakes")
+
+	reply := make([]byte, 0, udpServerHelloSize)
+	reply = append(reply, udpHandshakeMagic, udpServerHello)
+	return append(reply, ephemeral.PublicKey().Bytes()...), nil
+}
+
+// Sweep ends the sessions that have been quiet for udpSessionIdle.
+func (e *UDPEncryption) Sweep() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for addr, session := range e.sessions {
+		if !session.Active(udpSessionIdle) {
+			delete(e.sessions, addr)
+		}
+	}
+	metrics.SetGauge("udp_encrypted_sessions", float64(len(e.sessions)))
+}
+
+// udpSessionKeys derives the client-to-server and server-to-client keys.
+func udpSessionKeys(secret, clientKey, serverKey []byte) ([]byte, []byte, error) {
+	salt := append(append([]byte{}, clientKey...), serverKey...)
+	c2s := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte("udp c2s")), c2s); err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	s2c := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte("udp s2c")), s2c); err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return c2s, s2c, nil
+}
+
+func isUDPHandshake(data []byte) bool {
+	return len(data) > 1 && data[0] == udpHandshakeMagic
+}
+
+func isUDPSealed(data []byte) bool {
+	return len(data) > 0 && data[0] == udpSealedMagic
+}
+
+// udpSession seals and opens the datagrams of one client. It is safe for
+// concurrent use.
+type udpSession struct {
+	mu sync.Mutex
+
+	send        udpKey
+	sent        uint32 // counter of the last datagram sent
+	sentInEpoch int
+	rotatedAt   time.Time
+
+	recv     udpKey
+	received ReceiveWindow
+	lastSeen time.Time // last authenticated datagram
+	created  time.Time
+}
+
+// udpKey is one direction's key at an epoch, with the previous epoch's for
+// datagrams still in flight.
+type udpKey struct {
+	epoch uint8
+	key   []byte
+	aead  cipher.AEAD
+	prev  cipher.AEAD
+}
+
+func newUDPSession(sendKey, recvKey []byte) (*udpSession, error) {
+	send, err := chacha20poly1305.New(sendKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	recv, err := chacha20poly1305.New(recvKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	now := time.Now()
+	return &udpSession{
+		send:      udpKey{key: sendKey, aead: send},
+		recv:      udpKey{key: recvKey, aead: recv},
+		rotatedAt: now,
+		created:   now,
+	}, nil
+}
+
+// next derives the key of the following epoch.
+func (k udpKey) next() (udpKey, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, k.key, nil, []byte("udp rotate")), key); err != nil {
+		return udpKey{}, fmt.Errorf("failed to derive key: %w", err)
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return udpKey{}, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return udpKey{epoch: k.epoch + 1, key: key, aead: aead, prev: k.aead}, nil
+}
+
+// Active reports whether the session authenticated a datagram within d, or
+// was created within d.
+func (s *udpSession) Active(d time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastSeen) < d || time.Since(s.created) < d
+}
+
+// Seal appends the sealed datagram to dst.
+func (s *udpSession) Seal(dst, plaintext []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sentInEpoch >= udpKeyRotationPackets || time.Since(s.rotatedAt) >= udpKeyRotationInterval {
+		next, err := s.send.next()
+		if err != nil {
+			return nil, err
+		}
+		s.send, s.sentInEpoch, s.rotatedAt = next, 0, time.Now()
+		metrics.Inc("udp_key_rotations")
+	}
+	s.sent++
+	s.sentInEpoch++
+
+	var header [udpSealedHeader]byte
+	header[0], header[1] = udpSealedMagic, s.send.epoch
+	binary.BigEndian.PutUint32(header[2:], s.sent)
+	dst = append(dst, header[:]...)
+	return s.send.aead.Seal(dst, udpNonce(header[:]), plaintext, header[:]), nil
+}
+
+// Open authenticates and decrypts a sealed datagram, appending the
+// plaintext to dst.
+func (s *udpSession) Open(dst, sealed []byte) ([]byte, error) {
+	if len(sealed) < udpSealedHeader+chacha20poly1305.Overhead {
+		return nil, errUDPDecrypt
+	}
+	header, ciphertext := sealed[:udpSealedHeader], sealed[udpSealedHeader:]
+	epoch, counter := header[1], binary.BigEndian.Uint32(header[2:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var aead cipher.AEAD
+	var next udpKey
+	switch epoch {
+	case s.recv.epoch:
+		aead = s.recv.aead
+	case s.recv.epoch - 1:
+		aead = s.recv.prev
+	case s.recv.epoch + 1:
+		var err error
+		if next, err = s.recv.next(); err != nil {
+			return nil, err
+		}
+		aead = next.aead
+	}
+	if aead == nil {
+		return nil, errUDPDecrypt
+	}
+
+	plaintext, err := aead.Open(dst, udpNonce(header), ciphertext, header)
+	if err != nil {
+		return nil, errUDPDecrypt
+	}
+	if s.received.Receive(counter) != packetNew {
+		return nil, errUDPReplay
+	}
+	if next.aead != nil {
+		s.recv = next
+	}
+	s.lastSeen = time.Now()
+	return plaintext, nil
+}
+
+func udpNonce(header []byte) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	nonce[0] = header[1]
+	copy(nonce[8:], header[2:udpSealedHeader])
+	return nonce
+}
+
+// openDatagram answers handshakes and decrypts sealed datagrams into buf. It
+// returns the plaintext packet, or false if there is nothing more to handle.
+func (ugs *UDPGameServer) openDatagram(addr *net.UDPAddr, data []byte, buf *bytes.Buffer) ([]byte, bool) {
+	if ugs.encryption == nil {
+		return data, true
+	}
+	addrStr := addr.String()
+
+	switch {
+	case isUDPHandshake(data):
+		reply, err := ugs.encryption.Handshake(addrStr, data)
+		if err != nil {
+			metrics.Inc("udp_handshake_failures")
+			logrus.Debugf("Ignored ClientHello from %s: %v", addr, err)
+			return nil, false
+		}
+		if _, err := ugs.socketFor(addr).conn.WriteToUDP(reply, addr); err != nil {
+			logrus.Errorf("Failed to send ServerHello to %s: %v", addr, err)
+		}
+		return nil, false
+
+	case isUDPSealed(data):
+		session := ugs.encryption.Session(addrStr)
+		if session == nil {
+			metrics.Inc("udp_decrypt_failures")
+			return nil, false
+		}
+		plaintext, err := session.Open(buf.AvailableBuffer(), data)
+		if err != nil {
+			metrics.Inc("udp_decrypt_failures")
+			logrus.Debugf("Dropped datagram from %s: %v", addr, err)
+			return nil, false
+		}
+		return plaintext, true
+	}
+
+	// Once a session exists its address only speaks ciphertext, or anyone
+	// could spoof plaintext from it. Server browsers query in plaintext, and
+	// clients probe their address before they handshake.
+	session := ugs.encryption.Session(addrStr)
+	if ugs.encryption.Required() || session != nil {
+		packet, err := game.DeserializeUDPPacket(data)
+		if err == nil && unauthenticatedQuery(packet.Message.Type) && session == nil {
+			return data, true
+		}
+		metrics.Inc("udp_plaintext_rejected")
+		if err == nil && packet.Message.Type == "Heartbeat" && session == nil {
+			errorMsg := game.NewErrorMessage("this server requires encrypted UDP")
+			reply := game.NewUDPPacket(0, errorMsg, false)
+			reply.Encoding = packet.Encoding
+			if err := ugs.writePacket(reply, addr, uuid.Nil); err != nil {
+				logrus.Errorf("Failed to send encryption error to %s: %v", addr, err)
+			}
+		}
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,124 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSendBatch bounds how many queued datagrams a sender writes at once.
+const maxSendBatch = 64
+
+// errSendQueueFull is returned for a datagram its sender has no room for.
+var errSendQueueFull = errors.New("send queue full")
+
+// udpDatagram is a datagram waiting for its sender.
+type udpDatagram struct {
+	data []byte
+	addr *net.UDPAddr
+}
+
+// udpSenders writes the datagrams of the UDP server on UDP_SENDERS
+// goroutines, so that the goroutine producing a datagram, which may be
+// handling a packet or broadcasting under the server lock, never waits for
+// the socket. A client's address always hashes to the same sender, so its
+// datagrams leave in the order they were queued, and a sender writes what
+// has queued up with one sendmmsg call. A slow or failing send only delays
+// the clients sharing its sender. When a sender's queue of UDP_SEND_QUEUE
+// datagrams is full, new ones are dropped: an unreliable one is superseded
+// by the next update and a reliable one is retransmitted.
+type udpSenders struct {
+	queues []chan udpDatagram
+	write  func([]udpDatagram) error
+
+	mu     sync.RWMutex // held for reading while queueing
+	closed bool
+}
+
+func newUDPSenders(count, queueSize int, write func([]udpDatagram) error) *udpSenders {
+	s := &udpSenders{queues: make([]chan udpDatagram, count), write: write}
+	for i := range s.queues {
+		s.queues[i] = make(chan udpDatagram, queueSize)
+		go s.run(s.queues[i])
+	}
+	return s
+}
+
+// Send queues a copy of data to addr.
+func (s *udpSenders) Send(data []byte, addr *net.UDPAddr) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return net.ErrClosed
+	}
+
+	queue := s.queues[senderShard(addr, len(s.queues))]
+	select {
+	case queue <- udpDatagram{data: append([]byte(nil), data...), addr: addr}:
+		return nil
+	default:
+		metrics.Inc("udp_send_queue_drops")
+		return errSendQueueFull
+	}
+}
+
+// Close stops the senders once they have written what is queued. Sends
+// after Close return net.ErrClosed.
+func (s *udpSenders) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, queue := range s.queues {
+		close(queue)
+	}
+}
+
+// depth returns the length of the fullest queue.
+func (s *udpSenders) depth() int {
+	depth := 0
+	for _, queue := range s.queues {
+		depth = max(depth, len(queue))
+	}
+	return depth
+}
+
+// run writes the datagrams of one queue, as many as are waiting at a time.
+func (s *udpSenders) run(queue chan udpDatagram) {
+	batch := make([]udpDatagram, 0, maxSendBatch)
+	for d := range queue {
+		batch = append(batch[:0], d)
+	drain:
+		for len(batch) < maxSendBatch {
+			select {
+			case d, ok := <-queue:
+				if !ok {
+					// Closed, the outer range ends after this batch
+					break drain
+				}
+				batch = append(batch, d)
+			default:
+				break drain
+			}
+		}
+
+		metrics.SetGauge("udp_send_queue_depth", float64(s.depth()))
+		if err := s.write(batch); err != nil && !errors.Is(err, net.ErrClosed) {
+			metrics.Inc("udp_send_errors")
+			logrus.Errorf("Failed to send UDP datagrams: %v", err)
+		}
+	}
+}
+
+// senderShard hashes an address to one of count senders.
+func senderShard(addr *net.UDPAddr, count int) int {
+	hash := uint32(addr.Port)
+	for _, b := range addr.IP.To16() {
+		hash = hash*31 + uint32(b)
+	}
+	return int(hash % uint32(count))
+}
@@ -0,0 +1,160 @@
+package udp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+)
+
+// Every UDP client is sent a SessionSecret when it registers. From then on
+// the client may sign its datagrams, and must with UDP_REQUIRE_SIGNATURES:
+//
+//	signed  magic u8 (0xC1), tag [16], packet
+//
+// The tag is HMAC-SHA256(secret, packet) truncated to 16 bytes. The packet
+// holds its sequence, which the receive window accepts once, so a signed
+// packet cannot be replayed, and only the client the secret was issued to
+// can sign for its address. Once a client has signed a packet its unsigned
+// ones are dropped, and a Heartbeat from another address for a player who is
+// online is refused, so neither a spoofed address nor a spoofed player ID
+// takes over a connected client.
+//
+// 0xC1 is never used by MessagePack and cannot start JSON.
+//
+// The signature is also what lets a client keep its session when its address
+// changes, as a phone's does when it switches networks. A signed reliable
+// packet from an address with no client, naming a player who is online and
+// signed with that player's secret, moves the client to the new address with
+// its sequences, pending packets and session intact, provided its sequence is
+// newer than any received from the client so that a captured packet cannot
+// be replayed from elsewhere. A client that has sent nothing since joining
+// has no sequence to compare with and cannot move yet. With UDP_ENCRYPTION the client handshakes from
+// the new address first. Any other signed datagram from an unknown address
+// is handled as unsigned, so a client that timed out rejoins with its next
+// Heartbeat and is sent a new secret. The secret travels in the clear unless
+// UDP_ENCRYPTION is on, so signing alone only stops attackers who cannot see
+// the client's traffic.
+const (
+	udpSignedMagic   byte = 0xC1
+	udpSignatureSize      = 16
+	udpSecretSize         = 32
+)
+
+func newUDPSecret() ([]byte, error) {
+	secret := make([]byte, udpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate UDP secret: %w", err)
+	}
+	return secret, nil
+}
+
+func udpSignature(secret, packet []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(packet)
+	return mac.Sum(nil)[:udpSignatureSize]
+}
+
+// SignUDPPacket appends the signed datagram of a serialized packet to dst.
+func SignUDPPacket(dst, secret, packet []byte) []byte {
+	dst = append(dst, udpSignedMagic)
+	dst = append(dst, udpSignature(secret, packet)...)
+	return append(dst, packet...)
+}
+
+// verifyDatagram checks the signature of a datagram against the secret of
+// the client at addr and returns the packet inside it, or false if the
+// datagram is dropped.
+func (ugs *UDPGameServer) verifyDatagram(addr *net.UDPAddr, data []byte) ([]byte, bool) {
+	ugs.mu.RLock()
+	client, exists := ugs.clients[addr.String()]
+	ugs.mu.RUnlock()
+
+	if len(data) > 0 && data[0] == udpSignedMagic {
+		if len(data) < 1+udpSignatureSize {
+			metrics.Inc("udp_signature_failures")
+			return nil, false
+		}
+		tag, packet := data[1:1+udpSignatureSize], data[1+udpSignatureSize:]
+		if !exists {
+			if client := ugs.migratingClient(tag, packet); client != nil {
+				ugs.migrateClient(client, addr)
+				client.MarkSigning()
+			}
+			return packet, true
+		}
+		if !hmac.Equal(tag, udpSignature(client.Secret, packet)) {
+			metrics.Inc("udp_signature_failures")
+			logrus.Debugf("Dropped datagram from %s with a bad signature", addr)
+			return nil, false
+		}
+		client.MarkSigning()
+		return packet, true
+	}
+
+	if exists && (ugs.mustSign || client.Signing()) {
+		metrics.Inc("udp_unsigned_rejected")
+		return nil, false
+	}
+	return data, true
+}
+
+// migratingClient returns the client a signed datagram from an unknown
+// address comes from, if it is a reliable packet naming an online player,
+// signed with that player's secret and newer than anything the client sent.
+func (ugs *UDPGameServer) migratingClient(tag, data []byte) *UDPClient {
+	packet, err := game.DeserializeUDPPacket(data)
+	if err != nil || !packet.Reliable {
+		return nil
+	}
+	fields, ok := packet.Message.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	playerIDStr, ok := fields["player_id"].(string)
+	if !ok {
+		return nil
+	}
+	playerID, err := uuid.Parse(playerIDStr)
+	if err != nil {
+		return nil
+	}
+
+	ugs.mu.RLock()
+	client, exists := ugs.clients[ugs.clientByID[playerID]]
+	ugs.mu.RUnlock()
+	if !exists || !hmac.Equal(tag, udpSignature(client.Secret, data)) {
+		return nil
+	}
+	if !client.SequenceAhead(packet.Sequence) {
+		metrics.Inc("udp_migrations_replayed")
+		return nil
+	}
+	return client
+}
+
+// migrateClient moves a client to a new address.
+func (ugs *UDPGameServer) migrateClient(client *UDPClient, addr *net.UDPAddr) {
+	ugs.mu.Lock()
+	old := client.Addr().String()
+	if ugs.clients[old] != client {
+		// Removed or moved meanwhile
+		ugs.mu.Unlock()
+		return
+	}
+	delete(ugs.clients, old)
+	ugs.clients[addr.String()] = client
+	ugs.clientByID[client.ID] = addr.String()
+	client.addr.Store(addr)
+	ugs.publishRosterLocked()
+	ugs.mu.Unlock()
+
+	metrics.Inc("udp_migrations")
+	logrus.Infof("UDP client %s moved from %s to %s", client.ID, old, addr)
+}
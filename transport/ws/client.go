@@ -0,0 +1,504 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+// maxOutboxMessages bounds the messages queued for a client between
+// flushes, including those held back while its write pump is behind.
+const maxOutboxMessages = 256
+
+var errOutboxFull = errors.New("outbound queue is full")
+
+// SendQueuePolicy bounds how far a WebSocket or TCP client may fall behind.
+// Frames wait for the write pump in a queue of Size; while it is full,
+// messages stay in the outbox, where newer snapshots and moves replace
+// older ones. A client whose queue stays full for SlowTimeout is
+// disconnected.
+type SendQueuePolicy struct {
+	Size        int
+	SlowTimeout time.Duration
+}
+
+func NewSendQueuePolicy(config *game.Config) SendQueuePolicy {
+	return SendQueuePolicy{Size: config.SendQueueSize, SlowTimeout: config.SlowClientTimeout}
+}
+
+// Lifecycle of a Client. Disconnect moves an open client to disconnecting,
+// whichever path ends it first; closeClient moves it to closed once its read
+// loop has stopped.
+type clientState int
+
+const (
+	clientOpen          clientState = iota
+	clientDisconnecting             // its last messages are being written
+	clientClosed                    // removed from the game, its session ended
+)
+
+type Client struct {
+	ID     uuid.UUID
+	Addr   net.Addr
+	Player *game.Player
+	Conn   game.ClientConn
+	Send   chan []byte
+
+	// SessionID is nil when the session could not be created
+	SessionID *int64
+
+	// Batching clients receive each tick's messages as one Batch message
+	Batching bool
+
+	// ProtocolVersion is the version the client declared, see game/protocol.go
+	ProtocolVersion int
+
+	outMu       sync.Mutex
+	outbox      []*game.BroadcastPayload
+	sendClosed  bool
+	bandwidth   *game.Bandwidth
+	slowTimeout time.Duration
+	slowSince   time.Time // when the send queue filled up, zero while it keeps up
+
+	state             clientState
+	disconnectReason  string
+	disconnectMessage *game.GameMessage // the Disconnect message it was sent, nil if none
+	connOnce          sync.Once
+	connClosed        chan struct{} // closed by closeConn
+
+	// closeReason goes in the close frame once the write pump stops
+	closeReason string
+}
+
+func NewClient(id uuid.UUID, addr net.Addr, name string, conn game.ClientConn, queue SendQueuePolicy) *Client {
+	player := game.NewPlayer(id, name)
+	return &Client{
+		ID:     id,
+		Addr:   addr,
+		Player: player,
+		Conn:   conn,
+		Send:   make(chan []byte, queue.Size),
+
+		ProtocolVersion: game.LegacyProtocolVersion,
+		bandwidth:       game.NewBandwidth(conn.Protocol(), 0),
+		slowTimeout:     queue.SlowTimeout,
+		connClosed:      make(chan struct{}),
+	}
+}
+
+// SendMessage queues a message for the next Flush. A queued GameState, or
+// PlayerMove of the same player, is superseded by the newer message.
+func (c *Client) SendMessage(message *game.GameMessage) error {
+	return c.SendPayload(game.NewBroadcastPayload(message))
+}
+
+// SendPayload queues a message that may be shared with other clients, so it
+// is encoded once per encoding rather than once per client.
+func (c *Client) SendPayload(payload *game.BroadcastPayload) error {
+	if payload = game.DowngradePayload(payload, c.ProtocolVersion); payload == nil {
+		return nil
+	}
+
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+
+	if c.sendClosed {
+		return websocket.ErrCloseSent
+	}
+
+	if key := game.CoalesceKey(&payload.Message); key != "" {
+		for i := range c.outbox {
+			if game.CoalesceKey(&c.outbox[i].Message) == key {
+				c.outbox = append(c.outbox[:i], c.outbox[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(c.outbox) >= maxOutboxMessages && !c.dropOldestUpdateLocked() {
+		return errOutboxFull
+	}
+
+	c.outbox = append(c.outbox, payload)
+	return nil
+}
+
+// dropOldestUpdateLocked makes room in a full outbox by dropping its oldest
+// snapshot or move, and reports false if it holds none. It requires
+// c.outMu to be held.
+func (c *Client) dropOldestUpdateLocked() bool {
+	for i := range c.outbox {
+		if game.CoalesceKey(&c.outbox[i].Message) != "" {
+			c.outbox = append(c.outbox[:i], c.outbox[i+1:]...)
+			metrics.Inc("send_updates_dropped")
+			return true
+		}
+	}
+	return false
+}
+
+// Flush hands the queued messages to the write pump, as a single Batch
+// message for batching clients. Messages the send queue has no room for
+// stay in the outbox for the next flush. A client that stays too slow for
+// its SendQueuePolicy, or goes too far over BANDWIDTH_CAP, is disconnected.
+func (c *Client) Flush() {
+	if reason, text := c.flush(); reason != "" {
+		disconnect := game.NewDisconnectMessage(reason, text)
+		c.Disconnect(reason, &disconnect)
+	}
+}
+
+// flush does the work of Flush, returning the reason and message to
+// disconnect the client with if it must be dropped. Only an open client is
+// dropped; one already disconnecting just has its last messages flushed.
+func (c *Client) flush() (reason, text string) {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+
+	if len(c.outbox) == 0 || c.sendClosed {
+		return "", ""
+	}
+	now := time.Now()
+	open := c.state == clientOpen
+	if open && !c.slowSince.IsZero() && now.Sub(c.slowSince) >= c.slowTimeout {
+		logrus.Warnf("Client %s has not kept up for %s; disconnecting", c.ID, now.Sub(c.slowSince).Round(time.Millisecond))
+		metrics.Inc("slow_client_drops")
+		return game.DisconnectSlow, "too slow to keep up"
+	}
+
+	payloads := c.outbox
+	c.outbox = nil
+	if c.bandwidth.Thinning(now) {
+		payloads = c.thin(payloads)
+	}
+	pending := payloads // what is kept if the send queue fills up
+	perFrame := 1       // messages in each frame
+	if c.Batching && len(payloads) > 1 {
+		perFrame = len(payloads)
+		messages := make([]game.GameMessage, len(payloads))
+		for i, payload := range payloads {
+			messages[i] = payload.Message
+		}
+		batch := game.NewBatchMessage(messages)
+		payloads = []*game.BroadcastPayload{game.NewBroadcastPayload(&batch)}
+	}
+
+	for i, payload := range payloads {
+		data, err := payload.Encoded(c.Conn.Encoding())
+		if err != nil {
+			logrus.Errorf("Failed to encode %s for client %s: %v", payload.Message.Type, c.ID, err)
+			continue
+		}
+
+		select {
+		case c.Send <- data:
+		default:
+			if perFrame == 1 {
+				pending = payloads[i:]
+			}
+			c.fallBehindLocked(pending, now)
+			return "", ""
+		}
+		game.DefaultPacketTracer.Record("out", c.Conn.Protocol(), c.ID, game.DefaultRoom, data)
+
+		if c.bandwidth.Sent(len(data), perFrame, now) && open {
+			logrus.Warnf("Client %s went over BANDWIDTH_CAP; disconnecting", c.ID)
+			return game.DisconnectBandwidth, "bandwidth cap exceeded"
+		}
+	}
+	c.slowSince = time.Time{}
+	return "", ""
+}
+
+// fallBehindLocked keeps the messages the send queue had no room for, ahead
+// of anything queued later, and starts timing how long the client has been
+// too slow. It requires c.outMu to be held.
+func (c *Client) fallBehindLocked(pending []*game.BroadcastPayload, now time.Time) {
+	c.outbox = append(append([]*game.BroadcastPayload(nil), pending...), c.outbox...)
+	if c.slowSince.IsZero() {
+		c.slowSince = now
+		metrics.Inc("slow_clients")
+	}
+}
+
+// closeSendLocked stops the write pump once it has written what was handed
+// to it. Send is closed here and nowhere else, at most once. It requires
+// c.outMu to be held.
+func (c *Client) closeSendLocked() {
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.Send)
+	}
+}
+
+// thin drops the unreliable updates of a client over its bandwidth cap:
+// the snapshots and moves that a later one supersedes.
+func (c *Client) thin(payloads []*game.BroadcastPayload) []*game.BroadcastPayload {
+	kept := payloads[:0]
+	for _, payload := range payloads {
+		if game.CoalesceKey(&payload.Message) == "" {
+			kept = append(kept, payload)
+		}
+	}
+	if thinned := len(payloads) - len(kept); thinned > 0 {
+		c.bandwidth.Thinned(thinned)
+	}
+	return kept
+}
+
+// Disconnect ends the client's connection, whichever path ends it: a kick,
+// a slow or over-cap client, or its read loop stopping. Only the first call
+// has an effect, and it reports whether this call was it. The Disconnect
+// message, if any, is sent after whatever is queued, then the write pump
+// stops and closes the connection; a client that does not take it in time
+// is closed regardless.
+func (c *Client) Disconnect(reason string, message *game.GameMessage) bool {
+	c.outMu.Lock()
+	if c.state != clientOpen {
+		c.outMu.Unlock()
+		return false
+	}
+	c.state = clientDisconnecting
+	c.disconnectReason = reason
+	c.disconnectMessage = message
+	if message != nil {
+		if payload := game.DowngradePayload(game.NewBroadcastPayload(message), c.ProtocolVersion); payload != nil {
+			// Past the outbox limit too, since it is the last message
+			c.outbox = append(c.outbox, payload)
+		}
+		if data, ok := message.Data.(game.DisconnectData); ok {
+			c.closeReason = data.Message
+		}
+	}
+	c.outMu.Unlock()
+	metrics.Inc("disconnects_" + reason)
+
+	c.Flush()
+	c.outMu.Lock()
+	c.closeSendLocked()
+	c.outMu.Unlock()
+	time.AfterFunc(game.DisconnectGrace, c.closeConn)
+	return true
+}
+
+// closeConn closes the connection once, whichever of the write pump, the
+// grace timer and closeClient gets there first.
+func (c *Client) closeConn() {
+	c.connOnce.Do(func() {
+		c.Conn.Close()
+		close(c.connClosed)
+	})
+}
+
+// markClosed moves a client to closed, returning why it was disconnected,
+// and reports false if it already was closed.
+func (c *Client) markClosed() (reason string, message *game.GameMessage, ok bool) {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	if c.state == clientClosed {
+		return "", nil, false
+	}
+	c.state = clientClosed
+	return c.disconnectReason, c.disconnectMessage, true
+}
+
+func (c *Client) UpdatePosition(x, y float32) {
+	c.Player.MoveTo(x, y, time.Now())
+}
+
+// Steer sets the position and returns the velocity the player now moves at.
+func (c *Client) Steer(x, y, vx, vy, maxSpeed float32) (float32, float32) {
+	c.Player.Steer(x, y, vx, vy, maxSpeed, time.Now())
+	return c.Player.VX, c.Player.VY
+}
+
+func (c *Client) UpdateHealth(health float32) {
+	c.Player.Health = health
+}
+
+// ApplyDamage reduces health by amount, never below zero. It reports false
+// if the player was already dead.
+func (c *Client) ApplyDamage(amount float32) (float32, bool) {
+	return c.Player.ApplyDamage(amount, time.Now())
+}
+
+// Heal restores up to amount health, capped at MaxHealth. It reports false if
+// the player is dead or already at full health.
+func (c *Client) Heal(amount float32) (float32, bool) {
+	if c.Player.Health <= 0 || c.Player.Health >= game.MaxHealth {
+		return c.Player.Health, false
+	}
+	c.Player.Health += amount
+	if c.Player.Health > game.MaxHealth {
+		c.Player.Health = game.MaxHealth
+	}
+	return c.Player.Health, true
+}
+
+// AddXP adds experience and recomputes the level. It returns the new XP
+// total and the level before and after.
+func (c *Client) AddXP(amount int64) (int64, int, int) {
+	oldLevel := c.Player.Level
+	c.Player.XP += amount
+	c.Player.Level = game.LevelForXP(c.Player.XP)
+	return c.Player.XP, oldLevel, c.Player.Level
+}
+
+func (c *Client) AddScore(points uint32) {
+	c.Player.Score += points
+}
+
+func HandleClientMessages(ctx context.Context, client *Client, gameState *GameState, database store.Store) {
+	var sessionIDPtr *int64
+	reason := game.DisconnectLeft
+	defer func() { closeClient(ctx, client, gameState, database, sessionIDPtr, reason) }()
+
+	clientName := client.Player.Name
+	clientAddr := client.Addr.String()
+	clientIP := transport.AddrIP(client.Addr)
+
+	// Create game session in database
+	sessionID, err := database.CreateSession(ctx, client.ID, client.Conn.Protocol(), &clientIP)
+	if err != nil {
+		logrus.Errorf("Failed to create session: %v", err)
+		sessionIDPtr = nil
+	} else {
+		sessionIDPtr = &sessionID
+	}
+
+	gameState.AddClient(ctx, client, sessionIDPtr)
+	logrus.Infof("Client %s (%s) connected with session %v", clientName, clientAddr, sessionIDPtr)
+
+	// Start writer goroutine
+	go client.WritePump()
+
+	// Read messages from client
+	for {
+		message, err := client.Conn.ReadFrame()
+		if game.TooLarge(err) {
+			logrus.Warnf("Disconnecting client %s (%s): %v", clientName, clientAddr, err)
+			var disconnect *game.GameMessage
+			if client.Conn.Protocol() != "websocket" {
+				// WebSocket clients were already sent close code 1009
+				disconnectMsg := game.NewDisconnectMessage(game.DisconnectTooLarge, game.ErrMessageTooLarge.Error())
+				disconnect = &disconnectMsg
+			}
+			client.Disconnect(game.DisconnectTooLarge, disconnect)
+			// The stream cannot be read past the message, so wait for the
+			// write pump to send the Disconnect instead of closing now
+			<-client.connClosed
+			break
+		}
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				logrus.Errorf("Connection error from %s: %v", clientAddr, err)
+			}
+			break
+		}
+
+		if logrus.IsLevelEnabled(logrus.DebugLevel) {
+			logrus.Debugf("Received raw message from %s: %s", clientAddr, game.RedactPayload(message))
+		}
+		client.bandwidth.Received(len(message))
+
+		if !handleClientMessage(ctx, client, gameState, message, sessionIDPtr) {
+			logrus.Warnf("Disconnecting client %s (%s) after handler panic", clientName, clientAddr)
+			reason = game.DisconnectError
+			break
+		}
+
+		if game.ActiveChaos.Disconnect() {
+			logrus.Warnf("Chaos: disconnecting client %s (%s)", clientName, clientAddr)
+			reason = game.DisconnectChaos
+			break
+		}
+	}
+}
+
+// closeClient tears a client down once its read loop has stopped. It
+// disconnects the client with reason unless something else already did,
+// closes the connection, removes the client from the game, which tells the
+// other players it left, records the Disconnect it was sent and ends its
+// session. It does all of this once.
+func closeClient(ctx context.Context, client *Client, gameState *GameState, database store.Store, sessionID *int64, reason string) {
+	client.Disconnect(reason, nil)
+	client.closeConn()
+
+	reason, message, ok := client.markClosed()
+	if !ok {
+		return
+	}
+	gameState.RemoveClient(ctx, client.ID)
+	if message != nil {
+		game.LogDisconnect(ctx, database, client.ID, sessionID, message)
+	}
+	if sessionID != nil {
+		if err := database.EndSession(ctx, *sessionID); err != nil {
+			logrus.Errorf("Failed to end session: %v", err)
+		}
+	}
+
+	logrus.Infof("Client %s (%s) disconnected: %s", client.Player.Name, client.Addr, reason)
+}
+
+// handleClientMessage decodes and dispatches a single message. It returns
+// false if handling panicked and the client should be disconnected.
+func handleClientMessage(ctx context.Context, client *Client, gameState *GameState, message []byte, sessionID *int64) (ok bool) {
+	ok = true
+	defer game.RecoverHandler(client.Conn.Protocol(), client.Addr.String(), message, func() { ok = false })
+
+	game.DefaultPacketTracer.Record("in", client.Conn.Protocol(), client.ID, game.DefaultRoom, message)
+
+	ctx, span := game.StartMessageSpan(ctx, client.Conn.Protocol(), client.ID, len(message))
+	defer span.End()
+
+	var gameMsg game.GameMessage
+	if err := client.Conn.Encoding().Unmarshal(message, &gameMsg); err != nil {
+		logrus.Warnf("Invalid message format from %s: %s", client.Addr, game.RedactPayload(message))
+		game.RecordSpanError(ctx, err)
+		return true
+	}
+	game.NameMessageSpan(span, client.Conn.Protocol(), gameMsg.Type)
+	if err := gameState.limits.Check(&gameMsg); err != nil {
+		logrus.Warnf("Rejected %s from %s: %v", gameMsg.Type, client.Addr, err)
+		game.RecordSpanError(ctx, err)
+		errorMsg := game.NewReply(&gameMsg, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return true
+	}
+
+	game.ActiveChaos.Delay()
+	gameState.HandleMessage(ctx, client.ID, &gameMsg, sessionID)
+	return true
+}
+
+func (c *Client) WritePump() {
+	defer c.closeConn()
+
+	for {
+		select {
+		case message, ok := <-c.Send:
+			if !ok {
+				c.outMu.Lock()
+				reason := c.closeReason
+				c.outMu.Unlock()
+				c.Conn.WriteClose(reason)
+				return
+			}
+
+			if err := c.Conn.WriteFrame(message); err != nil {
+				logrus.Errorf("Failed to write message: %v", err)
+				return
+			}
+		}
+	}
+}
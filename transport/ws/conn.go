@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"online-server-go/game"
+)
+
+// wsConn adapts a WebSocket connection to ClientConn. JSON is sent in text
+// frames and MessagePack in binary frames.
+type wsConn struct {
+	conn       *websocket.Conn
+	encoding   game.Encoding
+	compressAt int // frames from this many bytes are deflated if the client negotiated it, 0 for none
+}
+
+// newWSConn reads messages of up to maxSize bytes. Past that, the
+// connection is closed rather than buffering the message whole.
+func newWSConn(conn *websocket.Conn, encoding game.Encoding, maxSize, compressAt int) *wsConn {
+	conn.SetReadLimit(int64(maxSize))
+	return &wsConn{conn: conn, encoding: encoding, compressAt: compressAt}
+}
+
+func (c *wsConn) ReadFrame() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return nil, game.ErrMessageTooLarge
+	}
+	return message, err
+}
+
+func (c *wsConn) WriteFrame(data []byte) error {
+	c.conn.EnableWriteCompression(c.compressAt > 0 && len(data) >= c.compressAt)
+	if c.encoding == game.EncodingMsgpack {
+		return c.conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// WriteClose sends a close frame, using the policy violation code when the
+// server is dropping the client for a reason.
+func (c *wsConn) WriteClose(reason string) error {
+	code := websocket.CloseNormalClosure
+	if reason != "" {
+		code = websocket.ClosePolicyViolation
+	}
+	return c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *wsConn) Protocol() string {
+	return "websocket"
+}
+
+func (c *wsConn) Encoding() game.Encoding {
+	return c.encoding
+}
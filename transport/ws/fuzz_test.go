@@ -0,0 +1,129 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"online-server-go/game"
+)
+
+// FuzzHandleClientMessage feeds arbitrary bytes through the WebSocket path
+// the way a hostile client would send them, as messages from a joined
+// player, and fails on inputs whose handling panics. Without -fuzz it runs
+// its seeds, a message of every type clients send:
+//
+//	go test ./transport/ws -run '^$' -fuzz FuzzHandleClientMessage -fuzztime 1m
+func FuzzHandleClientMessage(f *testing.F) {
+	fuzz := newFuzzGame(f)
+	for _, seed := range fuzz.Seeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := fuzz.Message(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// fuzzGame runs the same decoding and handlers as a served game, with an
+// in-memory database and a player that is joined again whenever an input
+// gets it disconnected.
+type fuzzGame struct {
+	server *GameServer
+	client *Client
+
+	playerID uuid.UUID
+}
+
+// newFuzzGame creates a WebSocket game configured from the environment that
+// is never listened on, since the fuzz test hands it its inputs directly.
+func newFuzzGame(tb testing.TB) *fuzzGame {
+	f := &fuzzGame{server: newTestGameServer(tb), playerID: uuid.New()}
+	f.join()
+	return f
+}
+
+// Message handles data as a WebSocket message from the joined player.
+func (f *fuzzGame) Message(data []byte) (err error) {
+	gs := f.server.gameState
+	if len(data) > gs.limits.Size {
+		// The connection refuses it before it is handled
+		return nil
+	}
+	if !f.clientOpen() {
+		closeClient(context.Background(), f.client, gs, f.server.database, nil, game.DisconnectLeft)
+		f.join()
+	}
+
+	panics := metrics.Counter("handler_panics")
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		} else if metrics.Counter("handler_panics") != panics {
+			err = fmt.Errorf("handler panicked, see the log")
+		}
+	}()
+	handleClientMessage(context.Background(), f.client, gs, data, nil)
+	return nil
+}
+
+// Seeds returns a message of every type clients send, in JSON, with zero
+// payloads naming the fuzzed player where they have a player_id.
+func (f *fuzzGame) Seeds() [][]byte {
+	var seeds [][]byte
+	for _, spec := range game.ClientMessages() {
+		if data, err := json.Marshal(f.seedMessage(spec)); err == nil {
+			seeds = append(seeds, data)
+		}
+	}
+	return seeds
+}
+
+// seedMessage builds a message of spec's type whose payload is the zero
+// payload with player_id filled in, since most handlers ignore messages
+// about other players.
+func (f *fuzzGame) seedMessage(spec game.MessageSpec) game.GameMessage {
+	message := game.GameMessage{Type: spec.Type}
+	if spec.Data == nil {
+		return message
+	}
+	var data map[string]interface{}
+	if raw, err := json.Marshal(spec.Data); err == nil && json.Unmarshal(raw, &data) == nil {
+		if _, ok := data["player_id"]; ok {
+			data["player_id"] = f.playerID.String()
+		}
+		message.Data = data
+	}
+	return message
+}
+
+// join adds a fresh client for the fuzzed player, whose messages are
+// written nowhere.
+func (f *fuzzGame) join() {
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	f.client = NewClient(f.playerID, addr, "Fuzzer", discardConn{}, f.server.sendQueue)
+	f.server.gameState.AddClient(context.Background(), f.client, nil)
+	go f.client.WritePump()
+}
+
+func (f *fuzzGame) clientOpen() bool {
+	f.client.outMu.Lock()
+	defer f.client.outMu.Unlock()
+	return f.client.state == clientOpen
+}
+
+// discardConn is a ClientConn that drops what it is sent and never
+// receives anything.
+type discardConn struct{}
+
+func (discardConn) ReadFrame() ([]byte, error)     { return nil, net.ErrClosed }
+func (discardConn) WriteFrame(data []byte) error   { return nil }
+func (discardConn) WriteClose(reason string) error { return nil }
+func (discardConn) Close() error                   { return nil }
+func (discardConn) Protocol() string               { return "websocket" }
+func (discardConn) Encoding() game.Encoding        { return game.EncodingJSON }
@@ -0,0 +1,1577 @@
+package ws
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+type GameState struct {
+	clients      map[uuid.UUID]*Client
+	mu           sync.RWMutex
+	roster       atomic.Value // []*Client, see publishRosterLocked
+	tickRate     time.Duration
+	database     store.Store
+	stats        *game.StatsTracker
+	matches      *game.MatchTracker
+	matchmaker   *game.Matchmaker
+	scoreboard   *game.Scoreboard
+	friends      *game.FriendManager
+	parties      *game.PartyManager
+	guilds       *game.GuildManager
+	mailbox      *game.Mailbox
+	inventory    *game.InventoryManager
+	progression  *game.Progression
+	leaderboards *game.Leaderboards
+	persist      *game.PersistQueue
+	bus          *game.EventBus
+	plugins      *game.Plugins
+	maintenance  *game.Maintenance
+	replication  *transport.Replication
+	cluster      *transport.Cluster
+	events       *game.WorldEvents
+	entities     *game.EntityRegistry
+	rules        *game.Rules
+	features     game.Features
+	limits       game.MessageLimits
+	world        game.WorldBounds
+	zones        *game.Zones
+	modes        *game.RoomModes
+	votes        *game.VoteManager
+	afk          *game.AFKPolicy
+	abilities    *game.Abilities
+	private      *game.PrivateChannel
+	visibility   *game.Visibility
+	anticheat    *game.AntiCheat
+	mutes        *game.Mutes
+	reports      *game.Reports
+	bandwidthCap int
+
+	// stateDirty is set when a move changes the game state, which is then
+	// broadcast with the next snapshot
+	stateDirty int32
+
+	tick          uint64 // simulation ticks so far, read atomically
+	snapshotEvery uint64 // ticks between snapshots, from SNAPSHOT_RATE
+	// lastSnapshotMoving keeps snapshots going until every player is shown
+	// at rest. It is only used by the game loop.
+	lastSnapshotMoving bool
+}
+
+// snapshotEvery converts a snapshot rate per second into simulation ticks,
+// sending a snapshot every tick when the rate exceeds the tick rate.
+func snapshotEvery(tick time.Duration, rate int) uint64 {
+	every := time.Second / time.Duration(rate) / tick
+	if every < 1 {
+		return 1
+	}
+	return uint64(every)
+}
+
+func NewGameState(protocol string, database store.Store, config *game.Config, replication *transport.Replication, cluster *transport.Cluster, events *game.WorldEvents, entities *game.EntityRegistry, rules *game.Rules, plugins *game.Plugins, random *game.SimRand) *GameState {
+	var matches *game.MatchTracker
+	var matchmaker *game.Matchmaker
+	var scoreboard *game.Scoreboard
+	if config.Features.Matchmaking {
+		matches = game.NewMatchTracker(database, protocol, config.MatchDuration, random)
+		matchmaker = game.NewMatchmaker(database, config.MatchRoomSize, config.GameMode)
+		scoreboard = game.NewScoreboard(database, config.ScoreboardInterval)
+	}
+
+	persist := game.NewPersistQueue()
+	leaderboards := game.NewLeaderboards(database)
+	anticheat := game.NewAntiCheat(config, rules, game.NewWorldBounds(config))
+	zones := game.NewZones(database, config)
+	abilities := game.NewAbilities(config)
+	private := game.NewPrivateChannel(rules, abilities)
+	gameState := &GameState{
+		clients:      make(map[uuid.UUID]*Client),
+		tickRate:     game.SimulationTick,
+		database:     database,
+		stats:        game.NewStatsTracker(database, persist),
+		matches:      matches,
+		matchmaker:   matchmaker,
+		scoreboard:   scoreboard,
+		friends:      game.NewFriendManager(database),
+		parties:      game.NewPartyManager(matchmaker, matches),
+		guilds:       game.NewGuildManager(database),
+		mailbox:      game.NewMailbox(database),
+		inventory:    game.NewInventoryManager(database, events, private),
+		progression:  game.NewProgression(database),
+		leaderboards: leaderboards,
+		persist:      persist,
+		bus:          game.NewGameEventBus(database, config.Features, persist, leaderboards, anticheat),
+		anticheat:    anticheat,
+		mutes:        game.NewMutes(),
+		reports:      game.NewReports(database, config),
+		bandwidthCap: config.BandwidthCap,
+		plugins:      plugins,
+		replication:  replication,
+		cluster:      cluster,
+		events:       events,
+		entities:     entities,
+		rules:        rules,
+		features:     config.Features,
+		limits:       game.NewMessageLimits(config),
+		world:        game.NewWorldBounds(config),
+		zones:        zones,
+		modes:        game.NewRoomModes(config, game.NewModeField(config, zones)),
+		votes:        game.NewVoteManager(rules),
+		afk:          game.NewAFKPolicy(config),
+		abilities:    abilities,
+		private:      private,
+		visibility:   game.NewVisibility(config, game.NewWorldBounds(config)),
+
+		snapshotEvery: snapshotEvery(game.SimulationTick, config.SnapshotRate),
+	}
+
+	gameState.maintenance = game.NewMaintenance(gameState)
+	replication.SetSnapshotSource(gameState.snapshotPlayers)
+	cluster.Attach(gameState, gameState.snapshotPlayers)
+	events.Attach(gameState.BroadcastAll)
+	entities.Attach(gameState.entitiesChanged)
+	rules.Attach(gameState.BroadcastAll)
+	plugins.Attach(gameState)
+	gameState.bus.Subscribe(plugins.HandleEvent)
+
+	// Start game loop
+	go gameState.gameLoop()
+
+	return gameState
+}
+
+func (gs *GameState) AddClient(ctx context.Context, client *Client, sessionID *int64) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	clientID := client.ID
+	clientName := client.Player.Name
+	client.SessionID = sessionID
+	client.bandwidth.SetCap(gs.bandwidthCap)
+
+	gs.progression.Load(ctx, client.Player)
+	gs.guilds.Load(ctx, client.Player)
+	gs.zones.Load(ctx, client.Player)
+	gs.bus.Publish(ctx, game.PlayerJoined{Player: *client.Player, SessionID: sessionID})
+
+	gs.clients[clientID] = client
+	gs.publishRosterLocked()
+	gs.matches.AddParticipant(clientID, gs.matchmaker.Assign(ctx, clientID))
+	gs.replication.PlayerUpdated(*client.Player)
+
+	joinMessage := game.NewPlayerJoinMessage(clientID, clientName)
+
+	logrus.Infof("Sending PlayerJoin message: %+v", joinMessage)
+
+	// Send join message to new client itself
+	if err := client.SendMessage(&joinMessage); err != nil {
+		logrus.Errorf("Failed to send PlayerJoin to new client %s: %v", clientID, err)
+	}
+
+	// Broadcast join message to the other clients in the zone
+	gs.broadcastZoneLocked(client.Player.Zone, &joinMessage, &clientID)
+	zoneMessage := game.NewZoneChangedMessage(client.Player.Zone, client.Player.X, client.Player.Y)
+	client.SendMessage(&zoneMessage)
+	gs.sendGameStateToClient(clientID)
+	gs.inventory.SendInventory(ctx, clientID, lockedDirectory{gs})
+	gs.mailbox.Deliver(ctx, clientID, lockedDirectory{gs})
+	for _, event := range gs.events.Active() {
+		eventMessage := game.NewWorldEventMessage("started", event)
+		client.SendMessage(&eventMessage)
+	}
+	rulesMessage := game.NewRulesChangedMessage(gs.rules.Current())
+	client.SendMessage(&rulesMessage)
+	cooldownsMessage := game.NewAbilityCooldownsMessage(gs.abilities.Cooldowns(*client.Player))
+	client.SendMessage(&cooldownsMessage)
+	gs.cluster.PlayerOnline(clientID)
+	gs.friends.NotifyPresence(ctx, clientID, clientName, true, gs.cluster.Directory(lockedDirectory{gs}))
+
+	logrus.Infof("Player %s joined the game", clientID)
+}
+
+func (gs *GameState) RemoveClient(ctx context.Context, clientID uuid.UUID) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	if client, exists := gs.clients[clientID]; exists {
+		delete(gs.clients, clientID)
+		gs.publishRosterLocked()
+		gs.stats.Forget(clientID)
+		gs.private.Forget(clientID)
+		gs.anticheat.Forget(clientID)
+		gs.parties.Leave(clientID, lockedDirectory{gs})
+		gs.matchmaker.Remove(clientID)
+		gs.replication.PlayerRemoved(clientID)
+		gs.bus.Publish(ctx, game.PlayerLeft{PlayerID: clientID, SessionID: client.SessionID})
+
+		leaveMessage := game.NewPlayerLeaveMessage(clientID)
+		gs.broadcastZoneLocked(client.Player.Zone, &leaveMessage, nil)
+		gs.cluster.PlayerOffline(clientID)
+		gs.friends.NotifyPresence(ctx, clientID, client.Player.Name, false, gs.cluster.Directory(lockedDirectory{gs}))
+
+		logrus.Infof("Player %s left the game", clientID)
+	}
+}
+
+func (gs *GameState) HandleMessage(ctx context.Context, clientID uuid.UUID, message *game.GameMessage, sessionID *int64) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	client, exists := gs.clients[clientID]
+	if !exists {
+		return
+	}
+
+	ctx, span := game.StartHandleSpan(ctx, message.Type)
+	defer span.End()
+
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		logrus.Debugf("Received message from client %s: %s", clientID, game.RedactMessage(message))
+	}
+
+	if !gs.features.Allows(message.Type) {
+		errorMsg := game.NewReply(message, game.NewErrorMessage(message.Type+" is disabled on this server"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	if game.ChatMessage(message.Type) {
+		if err := gs.mutes.Check(clientID, time.Now()); err != nil {
+			errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+			client.SendMessage(&errorMsg)
+			return
+		}
+	}
+
+	if game.MeaningfulInput(message.Type) {
+		gs.markActiveLocked(ctx, client)
+	}
+
+	if game.PausableMessage(message.Type) && gs.matchmaker.Paused(clientID) {
+		errorMsg := game.NewReply(message, game.NewErrorMessage(message.Type+" is not allowed while your room is paused"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	if game.PausableMessage(message.Type) && client.Player.Health <= 0 {
+		errorMsg := game.NewReply(message, game.NewErrorMessage(message.Type+" is not allowed while you are dead"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	switch message.Type {
+	case "PlayerMove":
+		if data, ok := message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil && playerID == clientID {
+					if x, ok := data["x"].(float64); ok {
+						if y, ok := data["y"].(float64); ok {
+							logrus.Infof("Processing PlayerMove: player_id=%s, x=%f, y=%f", playerID, x, y)
+
+							if client.Player.Pushed() {
+								gs.correctPushed(client)
+								return
+							}
+							from := gs.snapshotPlayer(client, time.Now())
+							moveX, moveY := gs.placeMove(client, float32(x), float32(y))
+
+							var vx, vy float32
+							if inputX, inputY, steering := game.MoveVelocity(data); steering {
+								vx, vy = client.Steer(moveX, moveY, inputX, inputY, gs.rules.Current().MaxMoveSpeed)
+							} else {
+								client.UpdatePosition(moveX, moveY)
+							}
+							gs.replication.PlayerUpdated(*client.Player)
+							logrus.Infof("Updated player %s position to (%f, %f)", playerID, moveX, moveY)
+
+							gs.bus.Publish(ctx, game.PlayerMoved{PlayerID: playerID, SessionID: sessionID, X: moveX, Y: moveY, VX: vx, VY: vy, FromX: from.X, FromY: from.Y, Logged: true})
+
+							moveMsg := game.NewPlayerMoveMessage(playerID, moveX, moveY, vx, vy)
+							gs.broadcastVisibleLocked(*client.Player, &moveMsg, &clientID)
+							atomic.StoreInt32(&gs.stateDirty, 1)
+						}
+					}
+				} else {
+					logrus.Infof("PlayerMove rejected: player_id %s != client_id %s", playerIDStr, clientID)
+				}
+			}
+		}
+
+	case "PlayerAction":
+		if data, ok := message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil && playerID == clientID {
+					if action, ok := data["action"].(string); ok && gs.plugins.Action(*client.Player, action) {
+						gs.handlePlayerAction(ctx, clientID, action, data["data"], sessionID)
+					}
+				}
+			}
+		}
+
+	case "Chat":
+		if data, ok := message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil && playerID == clientID {
+					if messageStr, ok := data["message"].(string); ok {
+						messageStr, err := game.SanitizeChat(messageStr)
+						if err != nil {
+							errorMsg := game.NewInputErrorMessage(err)
+							client.SendMessage(&errorMsg)
+							return
+						}
+						messageStr, send := gs.plugins.Chat(*client.Player, messageStr)
+						if !send {
+							return
+						}
+
+						chatMsg := game.NewChatMessage(playerID, messageStr)
+						if gs.anticheat.ShadowBanned(playerID) {
+							client.SendMessage(&chatMsg)
+							return
+						}
+						gs.bus.Publish(ctx, game.ChatSent{PlayerID: playerID, SessionID: sessionID, Message: messageStr})
+						gs.broadcastMessage(&chatMsg, nil)
+					}
+				}
+			}
+		}
+
+	case "FriendAdd", "FriendAccept", "FriendRemove", "FriendListRequest":
+		gs.friends.HandleMessage(ctx, clientID, client.Player.Name, message, game.RepliesTo(gs.cluster.Directory(lockedDirectory{gs}), clientID, message))
+
+	case "LeaderboardRequest":
+		gs.leaderboards.HandleRequest(ctx, clientID, message, game.RepliesTo(lockedDirectory{gs}, clientID, message))
+
+	case "Whisper":
+		game.HandleWhisper(clientID, client.Player.Name, message, game.RepliesTo(gs.cluster.Directory(lockedDirectory{gs}), clientID, message))
+
+	case "PrivacySettings":
+		game.HandlePrivacySettings(ctx, clientID, message, gs.database, game.RepliesTo(lockedDirectory{gs}, clientID, message))
+
+	case "GetPreferences", "SetPreferences":
+		game.HandlePreferences(ctx, clientID, message, gs.database, game.RepliesTo(lockedDirectory{gs}, clientID, message))
+
+	case "PartyInvite", "PartyAccept", "PartyLeave", "PartyChat":
+		gs.parties.HandleMessage(ctx, clientID, client.Player.Name, message, game.RepliesTo(lockedDirectory{gs}, clientID, message))
+
+	case "MailSend", "MailListRequest", "MailRead", "MailDelete":
+		gs.mailbox.HandleMessage(ctx, clientID, message, game.RepliesTo(gs.cluster.Directory(lockedDirectory{gs}), clientID, message))
+
+	case "GuildCreate", "GuildJoin", "GuildLeave", "GuildPromote", "GuildInfoRequest", "GuildChat", "GuildLeaderboardRequest":
+		setTag := func(tag string) {
+			client.Player.GuildTag = tag
+			gs.replication.PlayerUpdated(*client.Player)
+			atomic.StoreInt32(&gs.stateDirty, 1)
+		}
+		gs.guilds.HandleMessage(ctx, clientID, client.Player.Name, message, setTag, game.RepliesTo(gs.cluster.Directory(lockedDirectory{gs}), clientID, message))
+
+	case "ChangeZone":
+		gs.changeZone(ctx, client, message)
+
+	case "CreateRoom":
+		gs.createRoom(ctx, client, message)
+
+	case "PauseRoom", "ResumeRoom":
+		gs.ownerPauseRoom(client, message)
+
+	case "StartVote":
+		gs.startVote(client, message)
+
+	case "CastVote":
+		gs.castVote(client, message)
+
+	case "UseAbility":
+		gs.useAbility(ctx, client, message, sessionID)
+
+	case "Moderate":
+		gs.moderate(ctx, client, message)
+
+	case "ReportPlayer":
+		gs.reports.HandleMessage(ctx, clientID, message, game.RepliesTo(lockedDirectory{gs}, clientID, message))
+
+	case "UseItem", "DropItem":
+		heal := func(amount float32) (float32, bool) {
+			newHealth, healed := client.Heal(amount)
+			if healed {
+				gs.replication.PlayerUpdated(*client.Player)
+				if err := gs.database.UpdatePlayerHealth(ctx, clientID, newHealth); err != nil {
+					logrus.Errorf("Failed to update player health in database: %v", err)
+				}
+			}
+			return newHealth, healed
+		}
+		gs.inventory.HandleMessage(ctx, clientID, message, heal, game.RepliesTo(lockedDirectory{gs}, clientID, message))
+
+	case "PlayerStatsRequest":
+		// Default to the requesting player's own stats
+		targetID := clientID
+		if data, ok := message.Data.(map[string]interface{}); ok {
+			if playerIDStr, ok := data["player_id"].(string); ok {
+				if playerID, err := uuid.Parse(playerIDStr); err == nil {
+					targetID = playerID
+				}
+			}
+		}
+
+		stats, err := gs.stats.GetStats(ctx, targetID)
+		if err != nil {
+			logrus.Errorf("Failed to get player stats for %s: %v", targetID, err)
+			errorMsg := game.NewReply(message, game.NewErrorMessage("Failed to load player stats"))
+			client.SendMessage(&errorMsg)
+			return
+		}
+
+		statsMsg := game.NewReply(message, game.NewPlayerStatsMessage(targetID, stats))
+		if err := client.SendMessage(&statsMsg); err != nil {
+			logrus.Errorf("Failed to send player stats to client %s: %v", clientID, err)
+		}
+	}
+}
+
+func (gs *GameState) handlePlayerAction(ctx context.Context, clientID uuid.UUID, action string, data interface{}, sessionID *int64) {
+	client := gs.clients[clientID]
+
+	switch action {
+	case "attack":
+		logrus.Infof("Player %s performed attack", clientID)
+
+		// Log attack event
+		if err := gs.database.LogEvent(ctx, clientID, sessionID, "attack", nil); err != nil {
+			logrus.Errorf("Failed to log attack event: %v", err)
+		}
+
+		hit := false
+		if targetID, ok := game.ParseTargetID(data); ok && targetID != clientID && !gs.anticheat.ShadowBanned(clientID) {
+			hit = gs.applyAttack(ctx, clientID, targetID, gs.rules.Current().AttackDamage, sessionID)
+		}
+		gs.bus.Publish(ctx, game.PlayerActed{PlayerID: clientID, SessionID: sessionID, Action: "attack", Hit: hit})
+
+	case "pickup":
+		gs.bus.Publish(ctx, game.PlayerActed{PlayerID: clientID, SessionID: sessionID, Action: "pickup"})
+		gs.bus.Publish(ctx, game.ItemPickedUp{PlayerID: clientID, SessionID: sessionID, EntityID: game.PickupEntity(data)})
+		if gs.anticheat.ShadowBanned(clientID) {
+			return
+		}
+
+		points := gs.events.ScalePoints(gs.rules.Current().PickupScore)
+		client.AddScore(uint32(points))
+		newScore := client.Player.Score
+		gs.replication.PlayerUpdated(*client.Player)
+		logrus.Infof("Player %s picked up item, score: %d", clientID, newScore)
+		gs.bus.Publish(ctx, game.ScoreChanged{PlayerID: clientID, SessionID: sessionID, Score: newScore, Points: points, Reason: "pickup"})
+
+		gs.stats.RecordItemCollected(ctx, clientID)
+		gs.matches.AddPoints(clientID, points)
+		gs.inventory.Pickup(ctx, clientID, data, lockedDirectory{gs})
+		gs.awardXP(ctx, client, "pickup")
+
+	default:
+		arena := gs.modeArena(ctx, game.RoomOf(gs.matchmaker, clientID), time.Now())
+		if !gs.modes.PlayerAction(arena, *client.Player, action, data) {
+			logrus.Infof("Unknown action: %s from player %s", action, clientID)
+		}
+	}
+}
+
+// applyAttack deals damage to the target of an attacker in the same zone and
+// reports whether it did.
+func (gs *GameState) applyAttack(ctx context.Context, attackerID, targetID uuid.UUID, damage float32, sessionID *int64) bool {
+	target, exists := gs.clients[targetID]
+	if !exists || target.Player.Zone != gs.clients[attackerID].Player.Zone {
+		return false
+	}
+
+	newHealth, applied := target.ApplyDamage(damage)
+	if !applied {
+		return false
+	}
+	gs.replication.PlayerUpdated(*target.Player)
+
+	if err := gs.database.UpdatePlayerHealth(ctx, targetID, newHealth); err != nil {
+		logrus.Errorf("Failed to update player health in database: %v", err)
+	}
+
+	gs.stats.RecordDamage(attackerID, targetID)
+	attacker := gs.clients[attackerID]
+	gs.awardXP(ctx, attacker, "hit")
+
+	if newHealth > 0 {
+		now := time.Now()
+		from := attacker.Player.SnapshotAt(now)
+		if vx, vy := game.KnockbackPush(target.Player.SnapshotAt(now), from.X, from.Y, gs.rules.Current().KnockbackSpeed); vx != 0 || vy != 0 {
+			gs.pushLocked(target, vx, vy, now)
+		}
+	}
+
+	if newHealth <= 0 {
+		logrus.Infof("Player %s killed player %s", attackerID, targetID)
+		gs.stats.RecordKill(ctx, attackerID, targetID)
+		gs.matches.RecordKill(attackerID)
+		gs.matches.RecordDeath(targetID)
+		gs.awardXP(ctx, attacker, "kill")
+
+		if err := gs.database.LogEvent(ctx, attackerID, sessionID, "kill", nil); err != nil {
+			logrus.Errorf("Failed to log kill event: %v", err)
+		}
+		if err := gs.database.LogEvent(ctx, targetID, nil, "death", nil); err != nil {
+			logrus.Errorf("Failed to log death event: %v", err)
+		}
+
+		rules := gs.rules.Current()
+		gs.scorePoints(ctx, attacker, gs.events.ScalePoints(rules.KillScore), "kill_bonus")
+		gs.scorePoints(ctx, target, -rules.DeathScorePenalty, "death_penalty")
+		diedMessage := game.NewPlayerDiedMessage(targetID, attackerID, rules.RespawnAt(time.Now()))
+		gs.broadcastZoneLocked(target.Player.Zone, &diedMessage, nil)
+	}
+	return true
+}
+
+// scorePoints applies a score change from the rules, which may be negative.
+// It requires gs.mu to be held by the caller.
+func (gs *GameState) scorePoints(ctx context.Context, client *Client, points int64, reason string) {
+	if points == 0 {
+		return
+	}
+	applied := client.Player.AddPoints(points)
+	if applied == 0 {
+		return
+	}
+	gs.replication.PlayerUpdated(*client.Player)
+	gs.bus.Publish(ctx, game.ScoreChanged{PlayerID: client.ID, SessionID: client.SessionID, Score: client.Player.Score, Points: applied, Reason: reason})
+	gs.matches.AddPoints(client.ID, applied)
+}
+
+// publishRosterLocked replaces the roster with a copy of gs.clients. The
+// roster is never modified once published, so broadcasts and the tick flush
+// read it without gs.mu. It requires gs.mu to be held for writing.
+func (gs *GameState) publishRosterLocked() {
+	roster := make([]*Client, 0, len(gs.clients))
+	for _, client := range gs.clients {
+		roster = append(roster, client)
+	}
+	gs.roster.Store(roster)
+}
+
+// rosterClients returns the connected clients as of the last change. It
+// needs no lock.
+func (gs *GameState) rosterClients() []*Client {
+	roster, _ := gs.roster.Load().([]*Client)
+	return roster
+}
+
+// broadcastMessage queues a message for every client. It reads the roster,
+// so callers may or may not hold gs.mu.
+func (gs *GameState) broadcastMessage(message *game.GameMessage, exclude *uuid.UUID) {
+	payload := game.NewBroadcastPayload(message)
+	for _, client := range gs.rosterClients() {
+		if exclude != nil && *exclude == client.ID {
+			continue
+		}
+		if game.ActiveChaos.DropBroadcast() {
+			continue
+		}
+		// A client removed since the roster was read has closed its queue
+		if err := client.SendPayload(payload); err != nil && err != websocket.ErrCloseSent {
+			logrus.Errorf("Failed to send message to client %s: %v", client.ID, err)
+		}
+	}
+}
+
+// broadcastZoneLocked queues a message for every client in a zone. It
+// requires gs.mu to be held by the caller.
+func (gs *GameState) broadcastZoneLocked(zone string, message *game.GameMessage, exclude *uuid.UUID) {
+	payload := game.NewBroadcastPayload(message)
+	for _, client := range gs.clients {
+		if client.Player.Zone != zone || (exclude != nil && *exclude == client.ID) {
+			continue
+		}
+		if game.ActiveChaos.DropBroadcast() {
+			continue
+		}
+		if err := client.SendPayload(payload); err != nil && err != websocket.ErrCloseSent {
+			logrus.Errorf("Failed to send message to client %s: %v", client.ID, err)
+		}
+	}
+}
+
+// broadcastVisibleLocked sends a message that gives away where a player is
+// to the players of its zone who can see it. It requires gs.mu to be held
+// by the caller.
+func (gs *GameState) broadcastVisibleLocked(subject game.Player, message *game.GameMessage, exclude *uuid.UUID) {
+	if gs.visibility == nil {
+		gs.broadcastZoneLocked(subject.Zone, message, exclude)
+		return
+	}
+
+	payload := game.NewBroadcastPayload(message)
+	now := time.Now()
+	for _, client := range gs.clients {
+		if client.Player.Zone != subject.Zone || (exclude != nil && *exclude == client.ID) {
+			continue
+		}
+		if !gs.visibility.SeesPlayer(gs.snapshotPlayer(client, now), subject) || game.ActiveChaos.DropBroadcast() {
+			continue
+		}
+		if err := client.SendPayload(payload); err != nil && err != websocket.ErrCloseSent {
+			logrus.Errorf("Failed to send message to client %s: %v", client.ID, err)
+		}
+	}
+}
+
+// BroadcastAll sends a message to every client without taking gs.mu.
+func (gs *GameState) BroadcastAll(message *game.GameMessage) {
+	gs.broadcastMessage(message, nil)
+}
+
+func (gs *GameState) sendGameStateToClient(clientID uuid.UUID) {
+	recipient, exists := gs.clients[clientID]
+	if !exists {
+		return
+	}
+
+	now := time.Now()
+	var players []game.Player
+	for _, client := range gs.clients {
+		if client.Player.Zone == recipient.Player.Zone {
+			players = append(players, gs.snapshotPlayer(client, now))
+		}
+	}
+	entities := gs.entities.InZone(recipient.Player.Zone, now)
+	players, entities = gs.visibility.Filter(gs.snapshotPlayer(recipient, now), players, entities)
+
+	gameStateMessage := game.NewGameStateMessage(players, entities, atomic.LoadUint64(&gs.tick), now)
+	if err := recipient.SendMessage(&gameStateMessage); err != nil {
+		logrus.Errorf("Failed to send game state to client %s: %v", clientID, err)
+	}
+}
+
+func (gs *GameState) gameLoop() {
+	ctx := context.Background()
+	ticker := time.NewTicker(gs.tickRate)
+	defer ticker.Stop()
+
+	clock := game.NewTickClock(gs.tickRate, time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			// The ticker drops ticks while one overruns, which are made up here
+			for due := clock.Due(time.Now()); due > 0; due-- {
+				gs.runTick(ctx, clock)
+			}
+		}
+	}
+}
+
+// runTick runs and measures one tick of the simulation.
+func (gs *GameState) runTick(ctx context.Context, clock *game.TickClock) {
+	tick := atomic.AddUint64(&gs.tick, 1)
+	ctx, span := game.StartTickSpan(ctx, tick)
+	started := time.Now()
+	gs.updateGameState(ctx, clock.Next())
+	game.EndTickSpan(span, clock.Record(time.Since(started)))
+}
+
+// updateGameState simulates the game at now, which is the tick's place on
+// the fixed timestep rather than the wall clock.
+func (gs *GameState) updateGameState(ctx context.Context, now time.Time) {
+	gs.advancePlayers(now)
+	gs.respawnPlayers(ctx, now)
+	gs.runModes(ctx, now)
+	gs.runVotes(ctx, now)
+	if gs.afk.Due(now) {
+		gs.checkIdle(ctx, now)
+	}
+	if tick := atomic.LoadUint64(&gs.tick); tick%uint64(game.AntiCheatSweepInterval/gs.tickRate) == 0 {
+		for _, playerID := range gs.anticheat.Sweep(now) {
+			gs.Kick(playerID, game.DisconnectCheating, "disconnected by the anti-cheat")
+		}
+	}
+
+	// Game logic updates
+	// Example: NPC movement, item spawning, timer updates, etc.
+	gs.events.Tick()
+	gs.entities.Prune(now)
+	if gs.matches.Expired() {
+		gs.endMatch(ctx)
+	} else if gs.scoreboard.Due(now) {
+		gs.broadcastScoreboard(ctx)
+	}
+
+	if atomic.LoadUint64(&gs.tick)%gs.snapshotEvery == 0 {
+		gs.sendPrivateState(now)
+	}
+	gs.flushOutbound(ctx)
+}
+
+// sendPrivateState queues for each client the private state that changed
+// since its last PrivateState.
+func (gs *GameState) sendPrivateState(now time.Time) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	for _, client := range gs.clients {
+		if privateMessage, changed := gs.private.Update(*client.Player, now); changed {
+			client.SendMessage(&privateMessage)
+		}
+	}
+}
+
+// advancePlayers moves steered and pushed players along their velocity,
+// except in rooms that do not run on this tick. The snapshots that follow
+// keep going while any player is shown in motion.
+func (gs *GameState) advancePlayers(now time.Time) {
+	frozen := gs.matchmaker.Frozen(atomic.LoadUint64(&gs.tick))
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	for _, client := range gs.clients {
+		if frozen[client.ID] {
+			continue
+		}
+		client.Player.Advance(now)
+		gs.world.Confine(client.Player)
+	}
+}
+
+// respawnPlayers restores players whose respawn delay has passed, away from
+// the other players in their zone.
+func (gs *GameState) respawnPlayers(ctx context.Context, now time.Time) {
+	rules := gs.rules.Current()
+	delay := rules.RespawnDelay()
+	if delay <= 0 {
+		return
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	for clientID, client := range gs.clients {
+		if !client.Player.Respawn(delay, rules.RespawnHealth, now) {
+			continue
+		}
+		players := make([]game.Player, 0, len(gs.clients))
+		for _, other := range gs.clients {
+			players = append(players, *other.Player)
+		}
+		gs.zones.Respawn(client.Player, players, now)
+		logrus.Infof("Player %s respawned", clientID)
+		gs.replication.PlayerUpdated(*client.Player)
+		if err := gs.database.UpdatePlayerHealth(ctx, clientID, client.Player.Health); err != nil {
+			logrus.Errorf("Failed to update player health in database: %v", err)
+		}
+		respawnMessage := game.NewPlayerRespawnMessage(*client.Player)
+		gs.broadcastVisibleLocked(*client.Player, &respawnMessage, nil)
+		atomic.StoreInt32(&gs.stateDirty, 1)
+	}
+}
+
+// pushLocked gives a player an impulse and tells the players in its zone.
+// It requires gs.mu to be held for writing.
+func (gs *GameState) pushLocked(client *Client, vx, vy float32, now time.Time) {
+	client.Player.Push(vx, vy, now)
+	gs.replication.PlayerUpdated(*client.Player)
+	pushedMessage := game.NewPlayerPushedMessage(*client.Player, now)
+	gs.broadcastVisibleLocked(*client.Player, &pushedMessage, nil)
+	atomic.StoreInt32(&gs.stateDirty, 1)
+}
+
+// correctPushed answers a move from a player that is still being pushed
+// with a PositionCorrection to where the push has carried it.
+func (gs *GameState) correctPushed(client *Client) {
+	client.Player.Advance(time.Now())
+	gs.world.Confine(client.Player)
+
+	metrics.Inc("position_corrections")
+	correction := game.NewPositionCorrectionMessage(client.ID, client.Player.X, client.Player.Y)
+	if err := client.SendMessage(&correction); err != nil {
+		logrus.Errorf("Failed to send position correction to client %s: %v", client.ID, err)
+	}
+}
+
+// Explode pushes the living players of a zone within radius of x, y away
+// from it, and returns how many were pushed.
+func (gs *GameState) Explode(zone string, x, y, radius, speed float32) int {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.explodeLocked(zone, x, y, radius, speed)
+}
+
+// explodeLocked is Explode for callers holding gs.mu for writing.
+func (gs *GameState) explodeLocked(zone string, x, y, radius, speed float32) int {
+	now := time.Now()
+	pushed := 0
+	for _, client := range gs.clients {
+		if client.Player.Zone != zone || client.Player.Health <= 0 {
+			continue
+		}
+		if vx, vy, inReach := game.ExplosionPush(client.Player.SnapshotAt(now), x, y, radius, speed); inReach {
+			gs.pushLocked(client, vx, vy, now)
+			pushed++
+		}
+	}
+	return pushed
+}
+
+// checkIdle warns players who have gone idle and removes those idle for too
+// long from the match, or the server.
+func (gs *GameState) checkIdle(ctx context.Context, now time.Time) {
+	gs.mu.Lock()
+	var kicks []uuid.UUID
+	for _, client := range gs.clients {
+		switch gs.afk.Check(client.Player, now) {
+		case game.AFKWarn:
+			logrus.Infof("Player %s is AFK", client.ID)
+			warningMessage := game.NewAFKWarningMessage(gs.afk.RemovalAt(*client.Player), gs.afk.Kick())
+			client.SendMessage(&warningMessage)
+			afkMessage := game.NewPlayerAFKMessage(client.ID, true, false)
+			gs.broadcastZoneLocked(client.Player.Zone, &afkMessage, &client.ID)
+			atomic.StoreInt32(&gs.stateDirty, 1)
+
+		case game.AFKRemove:
+			afkMessage := game.NewPlayerAFKMessage(client.ID, true, true)
+			if err := gs.database.LogEvent(ctx, client.ID, client.SessionID, "afk_kick", &afkMessage); err != nil {
+				logrus.Errorf("Failed to log afk_kick event: %v", err)
+			}
+			if gs.afk.Kick() {
+				kicks = append(kicks, client.ID)
+				continue
+			}
+			logrus.Infof("Player %s was taken out of the match for being idle", client.ID)
+			gs.matchmaker.Remove(client.ID)
+			gs.matches.RemoveParticipant(client.ID)
+			gs.broadcastZoneLocked(client.Player.Zone, &afkMessage, nil)
+			atomic.StoreInt32(&gs.stateDirty, 1)
+		}
+	}
+	gs.mu.Unlock()
+
+	for _, playerID := range kicks {
+		gs.Kick(playerID, game.DisconnectAFK, "disconnected for being idle")
+	}
+}
+
+// markActiveLocked records meaningful input from a client, bringing them
+// back from AFK and into a match if they had been taken out. It requires
+// gs.mu to be held for writing.
+func (gs *GameState) markActiveLocked(ctx context.Context, client *Client) {
+	wasAFK, rejoin := client.Player.MarkActive(time.Now())
+	if !wasAFK {
+		return
+	}
+	if rejoin {
+		gs.matches.AddParticipant(client.ID, gs.matchmaker.Assign(ctx, client.ID))
+	}
+	logrus.Infof("Player %s is back from AFK", client.ID)
+	afkMessage := game.NewPlayerAFKMessage(client.ID, false, false)
+	gs.broadcastZoneLocked(client.Player.Zone, &afkMessage, nil)
+	atomic.StoreInt32(&gs.stateDirty, 1)
+}
+
+// runModes plays the game mode of each room.
+func (gs *GameState) runModes(ctx context.Context, now time.Time) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	arena := func(room string) game.ModeArena {
+		return gs.modeArena(ctx, room, now)
+	}
+	gs.modes.Tick(gs.modes.Rooms(gs.matchmaker), atomic.LoadUint64(&gs.tick), arena, now)
+}
+
+func (gs *GameState) modeArena(ctx context.Context, room string, now time.Time) gameArena {
+	return gameArena{gs: gs, ctx: ctx, room: room, now: now}
+}
+
+// gameArena is a room as its game mode sees it. Its methods require gs.mu to
+// be held for writing.
+type gameArena struct {
+	gs   *GameState
+	ctx  context.Context
+	room string
+	now  time.Time
+}
+
+func (a gameArena) Room() string {
+	return a.room
+}
+
+func (a gameArena) members() []*Client {
+	var members []*Client
+	for _, client := range a.gs.clients {
+		if game.RoomOf(a.gs.matchmaker, client.ID) == a.room {
+			members = append(members, client)
+		}
+	}
+	return members
+}
+
+func (a gameArena) Players() []game.Player {
+	var players []game.Player
+	for _, client := range a.members() {
+		if client.Player.Zone == a.gs.modes.Field().Zone {
+			players = append(players, a.gs.snapshotPlayer(client, a.now))
+		}
+	}
+	return players
+}
+
+func (a gameArena) SetTeam(playerID uuid.UUID, team string) {
+	client, exists := a.gs.clients[playerID]
+	if !exists {
+		return
+	}
+	client.Player.Team = team
+	a.gs.replication.PlayerUpdated(*client.Player)
+	atomic.StoreInt32(&a.gs.stateDirty, 1)
+}
+
+func (a gameArena) AwardPoints(playerID uuid.UUID, points int64, reason string) {
+	if client, exists := a.gs.clients[playerID]; exists {
+		a.gs.scorePoints(a.ctx, client, points, reason)
+	}
+}
+
+func (a gameArena) Announce(message *game.GameMessage) {
+	for _, client := range a.members() {
+		if err := client.SendMessage(message); err != nil {
+			logrus.Errorf("Failed to send %s to client %s: %v", message.Type, client.ID, err)
+		}
+	}
+}
+
+// createRoom opens a match room playing the requested game mode and moves
+// the client into it.
+func (gs *GameState) createRoom(ctx context.Context, client *Client, message *game.GameMessage) {
+	var data game.CreateRoomData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("invalid CreateRoom data"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	room, mode, err := gs.matchmaker.Create(ctx, client.ID, data.Mode, data.TickDivisor)
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return
+	}
+	gs.matches.AddParticipant(client.ID, room)
+	logrus.Infof("Player %s created room %s playing %q", client.ID, room, mode)
+
+	createdMessage := game.NewReply(message, game.NewRoomCreatedMessage(room, mode))
+	client.SendMessage(&createdMessage)
+}
+
+// ownerPauseRoom pauses or resumes the room of a client that owns it. It
+// requires gs.mu to be held for writing.
+func (gs *GameState) ownerPauseRoom(client *Client, message *game.GameMessage) {
+	roomID, owner := gs.matchmaker.OwnedRoom(client.ID)
+	if !owner {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("Only the owner of a room can pause or resume it"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+	if err := gs.pauseRoomLocked(roomID, message.Type == "PauseRoom"); err != nil {
+		errorMsg := game.NewReply(message, game.NewErrorMessage(err.Error()))
+		client.SendMessage(&errorMsg)
+	}
+}
+
+// useAbility checks that a client may use the ability it asked for, starts
+// its cooldown and applies its effect. The client is sent its cooldowns in
+// reply. It requires gs.mu to be held for writing.
+func (gs *GameState) useAbility(ctx context.Context, client *Client, message *game.GameMessage, sessionID *int64) {
+	var data game.UseAbilityData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil || data.Ability == "" {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("ability is required"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	now := time.Now()
+	target := func(playerID uuid.UUID) (game.Player, bool) {
+		targetClient, exists := gs.clients[playerID]
+		if !exists {
+			return game.Player{}, false
+		}
+		return gs.snapshotPlayer(targetClient, now), true
+	}
+	ability, x, y, err := gs.abilities.Check(gs.snapshotPlayer(client, now), data, target)
+	if err == nil {
+		err = client.Player.Spend(ability, now)
+	}
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return
+	}
+	if ability.Cost > 0 {
+		gs.replication.PlayerUpdated(*client.Player)
+		if err := gs.database.UpdatePlayerHealth(ctx, client.ID, client.Player.Health); err != nil {
+			logrus.Errorf("Failed to update player health in database: %v", err)
+		}
+	}
+	logrus.Infof("Player %s used %s", client.ID, ability.Name)
+
+	hit := false
+	if !gs.anticheat.ShadowBanned(client.ID) {
+		usedMessage := game.NewAbilityUsedMessage(client.ID, ability, data.TargetID, x, y)
+		gs.broadcastVisibleLocked(*client.Player, &usedMessage, nil)
+		switch ability.Effect {
+		case game.AbilityDamage:
+			hit = gs.applyAttack(ctx, client.ID, *data.TargetID, ability.Amount, sessionID)
+		case game.AbilityHeal:
+			if newHealth, healed := client.Heal(ability.Amount); healed {
+				gs.replication.PlayerUpdated(*client.Player)
+				if err := gs.database.UpdatePlayerHealth(ctx, client.ID, newHealth); err != nil {
+					logrus.Errorf("Failed to update player health in database: %v", err)
+				}
+			}
+		case game.AbilityBlast:
+			gs.explodeLocked(client.Player.Zone, x, y, ability.Radius, ability.Amount)
+		}
+	}
+	gs.bus.Publish(ctx, game.PlayerActed{PlayerID: client.ID, SessionID: sessionID, Action: "ability", Hit: hit})
+	atomic.StoreInt32(&gs.stateDirty, 1)
+
+	cooldownsMessage := game.NewReply(message, game.NewAbilityCooldownsMessage(gs.abilities.Cooldowns(*client.Player)))
+	client.SendMessage(&cooldownsMessage)
+}
+
+// placeMove returns a reported position moved onto the playfield and sends
+// the client a PositionCorrection if it was outside.
+func (gs *GameState) placeMove(client *Client, x, y float32) (float32, float32) {
+	if gs.world.Contains(x, y) {
+		return x, y
+	}
+
+	metrics.Inc("position_corrections")
+	x, y = gs.world.Place(x, y)
+	correction := game.NewPositionCorrectionMessage(client.ID, x, y)
+	if err := client.SendMessage(&correction); err != nil {
+		logrus.Errorf("Failed to send position correction to client %s: %v", client.ID, err)
+	}
+	return x, y
+}
+
+// flushOutbound broadcasts a snapshot every snapshotEvery ticks if the game
+// state changed since the last one, and sends every client the messages
+// queued for it, so that any number of updates costs each client at most one
+// GameState per snapshot. Encoding and handing frames to the writers happens
+// outside gs.mu.
+func (gs *GameState) flushOutbound(ctx context.Context) {
+	if atomic.LoadUint64(&gs.tick)%gs.snapshotEvery == 0 {
+		if atomic.SwapInt32(&gs.stateDirty, 0) == 1 || gs.lastSnapshotMoving {
+			_, span := game.StartBroadcastSpan(ctx, "GameState", len(gs.rosterClients()))
+			gs.lastSnapshotMoving = gs.broadcastGameState()
+			span.End()
+		}
+	}
+
+	roster := gs.rosterClients()
+	_, span := game.StartFlushSpan(ctx, len(roster))
+	defer span.End()
+	for _, client := range roster {
+		client.Flush()
+	}
+}
+
+func (gs *GameState) endMatch(ctx context.Context) {
+	matchID, results, scores := gs.matches.EndAndRestart(ctx)
+	matchEndedMessage := game.NewMatchEndedMessage(matchID, results)
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	scoreboard := gs.scoreboard.Build(ctx, scores, gs.scoreboardPlayer)
+	gs.scoreboard.SaveResults(ctx, matchID, scoreboard)
+	gs.bus.Publish(ctx, game.MatchEnded{MatchID: matchID, Results: results})
+
+	// Everyone still connected takes part in the next match
+	for clientID := range gs.clients {
+		gs.matches.AddParticipant(clientID, gs.matchmaker.Assign(ctx, clientID))
+	}
+	gs.broadcastMessage(&matchEndedMessage, nil)
+	if gs.scoreboard != nil {
+		scoreboardMessage := game.NewScoreboardMessage(matchID, true, scoreboard)
+		gs.broadcastMessage(&scoreboardMessage, nil)
+	}
+
+	for _, client := range gs.clients {
+		gs.awardXP(ctx, client, "match_played")
+	}
+}
+
+// broadcastScoreboard sends everyone the standings of the current match.
+func (gs *GameState) broadcastScoreboard(ctx context.Context) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	scoreboard := gs.scoreboard.Build(ctx, gs.matches.Scores(), gs.scoreboardPlayer)
+	if len(scoreboard) == 0 {
+		return
+	}
+	scoreboardMessage := game.NewScoreboardMessage(gs.matches.CurrentMatchID(), false, scoreboard)
+	gs.broadcastMessage(&scoreboardMessage, nil)
+}
+
+// scoreboardPlayer names an online player on the scoreboard. WebSocket and
+// TCP clients have no measured ping. It requires gs.mu to be held by the
+// caller.
+func (gs *GameState) scoreboardPlayer(playerID uuid.UUID) (string, time.Duration, bool) {
+	client, exists := gs.clients[playerID]
+	if !exists {
+		return "", 0, false
+	}
+	return client.Player.Name, 0, true
+}
+
+// awardXP grants XP for an action and broadcasts LevelUp on a new level.
+// It requires gs.mu to be held by the caller.
+func (gs *GameState) awardXP(ctx context.Context, client *Client, action string) {
+	level, leveledUp := gs.progression.Award(ctx, client.ID, action, client.AddXP)
+	gs.replication.PlayerUpdated(*client.Player)
+
+	if leveledUp {
+		levelUpMessage := game.NewLevelUpMessage(client.ID, level, client.Player.XP)
+		gs.broadcastMessage(&levelUpMessage, nil)
+	}
+}
+
+// snapshotPlayer returns a client's player as sent in a snapshot taken at
+// now.
+func (gs *GameState) snapshotPlayer(client *Client, now time.Time) game.Player {
+	player := client.Player.SnapshotAt(now)
+	gs.world.Confine(&player)
+	return player
+}
+
+// broadcastGameState reports whether any player or entity is shown in
+// motion.
+func (gs *GameState) broadcastGameState() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.broadcastGameStateLocked()
+}
+
+// broadcastGameStateLocked sends each zone the snapshot of its players and
+// entities, or each player what they can see of it in fog of war. It
+// requires gs.mu to be held by the caller.
+func (gs *GameState) broadcastGameStateLocked() bool {
+	now := time.Now()
+	zones := make(map[string][]game.Player)
+	moving := false
+	for _, client := range gs.clients {
+		player := gs.snapshotPlayer(client, now)
+		moving = moving || player.Moving()
+		zones[player.Zone] = append(zones[player.Zone], player)
+	}
+
+	for zone, players := range zones {
+		entities := gs.entities.InZone(zone, now)
+		for _, entity := range entities {
+			moving = moving || entity.Moving()
+		}
+		if gs.visibility == nil {
+			gameStateMessage := game.NewGameStateMessage(players, entities, atomic.LoadUint64(&gs.tick), now)
+			gs.broadcastZoneLocked(zone, &gameStateMessage, nil)
+			continue
+		}
+		for _, client := range gs.clients {
+			if client.Player.Zone != zone {
+				continue
+			}
+			visiblePlayers, visibleEntities := gs.visibility.Filter(gs.snapshotPlayer(client, now), players, entities)
+			gameStateMessage := game.NewGameStateMessage(visiblePlayers, visibleEntities, atomic.LoadUint64(&gs.tick), now)
+			if err := client.SendMessage(&gameStateMessage); err != nil {
+				logrus.Errorf("Failed to send game state to client %s: %v", client.ID, err)
+			}
+		}
+	}
+	return moving
+}
+
+// changeZone hands a client over to another zone: players in the old zone
+// see them leave, players in the new one see them join at its spawn point.
+func (gs *GameState) changeZone(ctx context.Context, client *Client, message *game.GameMessage) {
+	var data game.ChangeZoneData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil || data.Zone == "" {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("zone is required"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	from := client.Player.Zone
+	zone, err := gs.zones.Enter(ctx, client.ID, from, data.Zone)
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return
+	}
+	gs.handOffLocked(client, from, zone, message)
+}
+
+// handOffLocked moves a client from one zone to another and tells it with a
+// ZoneChanged message in reply to request, which may be nil. It requires
+// gs.mu to be held for writing.
+func (gs *GameState) handOffLocked(client *Client, from string, zone game.Zone, request *game.GameMessage) {
+	client.Player.EnterZone(zone, time.Now())
+	gs.replication.PlayerUpdated(*client.Player)
+	logrus.Infof("Player %s moved from zone %s to %s", client.ID, from, zone.Name)
+
+	leaveMessage := game.NewPlayerLeaveMessage(client.ID)
+	gs.broadcastZoneLocked(from, &leaveMessage, &client.ID)
+	joinMessage := game.NewPlayerJoinMessage(client.ID, client.Player.Name)
+	gs.broadcastZoneLocked(zone.Name, &joinMessage, &client.ID)
+
+	zoneMessage := game.NewReply(request, game.NewZoneChangedMessage(zone.Name, zone.SpawnX, zone.SpawnY))
+	client.SendMessage(&zoneMessage)
+	gs.sendGameStateToClient(client.ID)
+	atomic.StoreInt32(&gs.stateDirty, 1)
+}
+
+// startVote starts the vote a client asked for, which the starter's ballot
+// counts for. It requires gs.mu to be held by the caller.
+func (gs *GameState) startVote(client *Client, message *game.GameMessage) {
+	var data game.StartVoteData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil || data.Type == "" {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("vote type is required"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	online := func(playerID uuid.UUID) bool {
+		_, exists := gs.clients[playerID]
+		return exists
+	}
+	target, err := game.CheckVoteTarget(data.Type, data.Target, client.ID, gs.zones, online, gs.matches)
+	var status game.VoteStatusData
+	if err == nil {
+		status, err = gs.votes.Start(client.ID, data.Type, target, gs.voters(), time.Now())
+	}
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return
+	}
+	logrus.Infof("Player %s started a %s vote on %q", client.ID, data.Type, target)
+	gs.announceVote(client, message, status)
+}
+
+// castVote records a client's ballot in the running vote. It requires gs.mu
+// to be held by the caller.
+func (gs *GameState) castVote(client *Client, message *game.GameMessage) {
+	var data game.CastVoteData
+	if err := game.DecodeMessageData(message.Data, &data); err != nil {
+		errorMsg := game.NewReply(message, game.NewErrorMessage("invalid CastVote data"))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	status, err := gs.votes.Cast(client.ID, data.Yes, gs.voters(), time.Now())
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return
+	}
+	gs.announceVote(client, message, status)
+}
+
+// announceVote sends everyone the standing of the vote, as a reply to the
+// client whose request changed it.
+func (gs *GameState) announceVote(client *Client, request *game.GameMessage, status game.VoteStatusData) {
+	updateMessage := game.NewVoteUpdateMessage(status)
+	gs.broadcastMessage(&updateMessage, &client.ID)
+	replyMessage := game.NewReply(request, updateMessage)
+	client.SendMessage(&replyMessage)
+}
+
+// voters returns the IDs of the players online, who may all vote.
+func (gs *GameState) voters() []uuid.UUID {
+	roster := gs.rosterClients()
+	voters := make([]uuid.UUID, 0, len(roster))
+	for _, client := range roster {
+		voters = append(voters, client.ID)
+	}
+	return voters
+}
+
+// runVotes broadcasts the countdown of the running vote and ends it once it
+// is due.
+func (gs *GameState) runVotes(ctx context.Context, now time.Time) {
+	update, ended := gs.votes.Tick(gs.voters(), now)
+	if update != nil {
+		gs.broadcastMessage(update, nil)
+	}
+	if ended != nil {
+		gs.endVote(ctx, *ended)
+	}
+}
+
+// endVote announces the outcome of a vote and enforces it if it passed.
+func (gs *GameState) endVote(ctx context.Context, ended game.VoteEndedData) {
+	logrus.Infof("The %s vote on %q ended with %d yes and %d no of %d players, passed: %t", ended.Type, ended.Target, ended.Yes, ended.No, ended.Eligible, ended.Passed)
+	endedMessage := game.NewVoteEndedMessage(ended)
+	gs.broadcastMessage(&endedMessage, nil)
+	if !ended.Passed {
+		return
+	}
+
+	switch ended.Type {
+	case game.VoteKickPlayer:
+		if playerID, err := uuid.Parse(ended.Target); err == nil {
+			gs.Kick(playerID, game.DisconnectKicked, "kicked by a vote")
+		}
+	case game.VoteNextMap:
+		gs.moveEveryone(ctx, ended.Target)
+	case game.VoteRestartMatch:
+		gs.endMatch(ctx)
+	}
+}
+
+// moveEveryone hands every player over to a zone, which those already
+// there stay in.
+func (gs *GameState) moveEveryone(ctx context.Context, name string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	for _, client := range gs.clients {
+		from := client.Player.Zone
+		if zone, err := gs.zones.Enter(ctx, client.ID, from, name); err == nil {
+			gs.handOffLocked(client, from, zone, nil)
+		}
+	}
+}
+
+// PauseRoom freezes or resumes a match room: members stop where they are,
+// their gameplay messages are refused and they are told the room's state.
+func (gs *GameState) PauseRoom(roomID string, paused bool) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.pauseRoomLocked(roomID, paused)
+}
+
+// pauseRoomLocked is PauseRoom with gs.mu held for writing.
+func (gs *GameState) pauseRoomLocked(roomID string, paused bool) error {
+	members, err := gs.matchmaker.SetPaused(roomID, paused)
+	if err != nil {
+		return err
+	}
+
+	message := game.NewRoomPauseMessage(roomID, paused)
+	now := time.Now()
+	for _, playerID := range members {
+		client, exists := gs.clients[playerID]
+		if !exists {
+			continue
+		}
+		if paused {
+			client.Player.Stop(now)
+		}
+		if err := client.SendMessage(&message); err != nil {
+			logrus.Errorf("Failed to send %s to client %s: %v", message.Type, playerID, err)
+		}
+	}
+	atomic.StoreInt32(&gs.stateDirty, 1)
+
+	logrus.Infof("%s match room %s with %d players", message.Type, roomID, len(members))
+	return nil
+}
+
+// Rooms lists the match rooms for the admin API.
+func (gs *GameState) Rooms() []game.RoomInfo {
+	return gs.matchmaker.Rooms()
+}
+
+// lockedDirectory exposes the connected clients as a PlayerDirectory for use
+// while gs.mu is already held.
+type lockedDirectory struct {
+	gs *GameState
+}
+
+func (d lockedDirectory) IsOnline(playerID uuid.UUID) bool {
+	_, exists := d.gs.clients[playerID]
+	return exists
+}
+
+func (d lockedDirectory) SendToPlayer(playerID uuid.UUID, message *game.GameMessage) bool {
+	client, exists := d.gs.clients[playerID]
+	if !exists {
+		return false
+	}
+	if err := client.SendMessage(message); err != nil {
+		logrus.Errorf("Failed to send %s to client %s: %v", message.Type, playerID, err)
+		return false
+	}
+	return true
+}
+
+// SendToPlayer implements PlayerDirectory for callers not holding gs.mu.
+func (gs *GameState) SendToPlayer(playerID uuid.UUID, message *game.GameMessage) bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return lockedDirectory{gs}.SendToPlayer(playerID, message)
+}
+
+func (gs *GameState) snapshotPlayers() []game.Player {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	players := make([]game.Player, 0, len(gs.clients))
+	for _, client := range gs.clients {
+		players = append(players, *client.Player)
+	}
+	return players
+}
+
+func (gs *GameState) IsOnline(playerID uuid.UUID) bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	_, exists := gs.clients[playerID]
+	return exists
+}
+
+// OnlinePlayer returns a copy of a connected player's state.
+func (gs *GameState) OnlinePlayer(playerID uuid.UUID) (game.Player, bool) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	client, exists := gs.clients[playerID]
+	if !exists {
+		return game.Player{}, false
+	}
+	return *client.Player, true
+}
+
+// OnlinePlayers returns a copy of every connected player's state.
+func (gs *GameState) OnlinePlayers() []game.Player {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	players := make([]game.Player, 0, len(gs.clients))
+	for _, client := range gs.clients {
+		players = append(players, *client.Player)
+	}
+	return players
+}
+
+// Kick sends a player a Disconnect message with the reason, one of the
+// disconnect reasons, and closes their connection. The read loop then
+// removes the client as usual.
+func (gs *GameState) Kick(playerID uuid.UUID, reason, message string) bool {
+	gs.mu.RLock()
+	client, exists := gs.clients[playerID]
+	gs.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+	gs.kickClient(client, reason, message)
+	return true
+}
+
+// kickClient disconnects a client as Kick does. It takes no lock, so that
+// it may be called with gs.mu held.
+func (gs *GameState) kickClient(client *Client, reason, message string) {
+	logrus.Warnf("Kicking player %s (%s): %s", client.ID, reason, message)
+	disconnect := game.NewDisconnectMessage(reason, message)
+	client.Disconnect(reason, &disconnect)
+}
+
+// moderate carries out a Moderate command of a staff member. It requires
+// gs.mu to be held by the caller.
+func (gs *GameState) moderate(ctx context.Context, client *Client, message *game.GameMessage) {
+	moderation, err := game.CheckModeration(ctx, gs.database, client.ID, message, time.Now())
+	if err != nil {
+		errorMsg := game.NewReply(message, game.NewInputErrorMessage(err))
+		client.SendMessage(&errorMsg)
+		return
+	}
+
+	target, online := gs.clients[moderation.Target]
+	switch moderation.Action {
+	case game.ModerateMute:
+		gs.mutes.Mute(moderation.Target, moderation.Until)
+		if online {
+			muted := game.NewMutedMessage(moderation.Until, moderation.Reason)
+			target.SendMessage(&muted)
+		}
+	case game.ModerateUnmute:
+		if !gs.mutes.Unmute(moderation.Target, time.Now()) {
+			errorMsg := game.NewReply(message, game.NewErrorMessage("player is not muted"))
+			client.SendMessage(&errorMsg)
+			return
+		}
+		if online {
+			unmuted := game.NewMutedMessage(time.Time{}, "")
+			target.SendMessage(&unmuted)
+		}
+	case game.ModerateKick:
+		if !online {
+			errorMsg := game.NewReply(message, game.NewErrorMessage("player is not online"))
+			client.SendMessage(&errorMsg)
+			return
+		}
+		gs.kickClient(target, game.DisconnectKicked, moderation.Reason)
+	}
+
+	moderation.Record(ctx, gs.database)
+	reply := moderation.Reply(message)
+	client.SendMessage(&reply)
+}
+
+// Bandwidth returns the traffic of every connected player.
+func (gs *GameState) Bandwidth() []game.PlayerBandwidth {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	bandwidth := make([]game.PlayerBandwidth, 0, len(gs.clients))
+	for _, client := range gs.clients {
+		bandwidth = append(bandwidth, game.PlayerBandwidth{
+			PlayerID:       client.ID,
+			Name:           client.Player.Name,
+			Protocol:       client.Conn.Protocol(),
+			BandwidthStats: client.bandwidth.Stats(),
+		})
+	}
+	return bandwidth
+}
+
+// Maintenance returns the scheduler of maintenance restarts.
+func (gs *GameState) Maintenance() *game.Maintenance {
+	return gs.maintenance
+}
+
+// Entities returns the NPCs, items and projectiles of the world.
+func (gs *GameState) Entities() *game.EntityRegistry {
+	return gs.entities
+}
+
+// AntiCheat returns the anti-cheat, nil if disabled.
+func (gs *GameState) AntiCheat() *game.AntiCheat {
+	return gs.anticheat
+}
+
+func (gs *GameState) Persistence() *game.PersistQueue {
+	return gs.persist
+}
+
+// entitiesChanged marks the game state dirty, so that the next snapshot of
+// the zone shows the entity spawned or removed.
+func (gs *GameState) entitiesChanged(zone string, message *game.GameMessage) {
+	atomic.StoreInt32(&gs.stateDirty, 1)
+}
+
+func (gs *GameState) GetClientCount() int {
+	return len(gs.rosterClients())
+}
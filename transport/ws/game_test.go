@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"online-server-go/game"
+)
+
+// BenchmarkGameStateFlush broadcasts a move to 1,000 WebSocket clients and
+// flushes it to their writers, as a tick does, while joins and moves keep
+// taking gs.mu. It reports the longest any of them waited for the lock.
+func BenchmarkGameStateFlush(b *testing.B) {
+	gs := newBenchGameState(b, 1000)
+	move := game.NewPlayerMoveMessage(uuid.New(), 10, 20, 1, 0)
+	contention := contendLock(&gs.mu)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gs.BroadcastAll(&move)
+		for _, client := range gs.rosterClients() {
+			client.Flush()
+		}
+	}
+	b.StopTimer()
+	contention.Report(b)
+}
+
+// newBenchGameState opens a WebSocket game with players clients whose
+// frames are written nowhere. Its game loop cannot be stopped, so the
+// clients are dropped when the benchmark ends to leave it idle.
+func newBenchGameState(tb testing.TB, players int) *GameState {
+	server := newTestGameServer(tb)
+	gs := server.gameState
+	for i := 0; i < players; i++ {
+		addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000 + i}
+		client := NewClient(uuid.New(), addr, fmt.Sprintf("Bench_%d", i), discardConn{}, server.sendQueue)
+		gs.AddClient(context.Background(), client, nil)
+		go client.WritePump()
+		if i%100 == 0 {
+			// Everyone is told of every join, faster than ticks flush it
+			for _, client := range gs.rosterClients() {
+				client.Flush()
+			}
+		}
+	}
+	tb.Cleanup(func() {
+		gs.mu.Lock()
+		defer gs.mu.Unlock()
+		clear(gs.clients)
+		gs.publishRosterLocked()
+	})
+	return gs
+}
+
+// lockContention takes a lock for writing every millisecond, the way
+// handlers of joins and moves do, and records the longest it waited.
+type lockContention struct {
+	stop    chan struct{}
+	done    chan struct{}
+	longest time.Duration
+}
+
+func contendLock(mu *sync.RWMutex) *lockContention {
+	c := &lockContention{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			started := time.Now()
+			mu.Lock()
+			c.longest = max(c.longest, time.Since(started))
+			mu.Unlock()
+		}
+	}()
+	return c
+}
+
+// Report stops taking the lock and reports the longest wait on b.
+func (c *lockContention) Report(b *testing.B) {
+	close(c.stop)
+	<-c.done
+	b.ReportMetric(float64(c.longest.Nanoseconds()), "max-lock-wait-ns")
+}
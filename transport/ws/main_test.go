@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+// TestMain runs the tests from the repository root, where the migrations
+// the in-memory databases are created with are. Fuzzing workers start in
+// the directory the tests moved to, so the root is found from this file.
+func TestMain(m *testing.M) {
+	_, file, _, _ := runtime.Caller(0)
+	if err := os.Chdir(filepath.Join(filepath.Dir(file), "..", "..")); err != nil {
+		panic(err)
+	}
+	logrus.SetLevel(logrus.ErrorLevel)
+	os.Exit(m.Run())
+}
+
+// newTestGameServer creates a WebSocket game configured from the environment
+// on an in-memory database. It is not served: tests hand it their inputs
+// directly.
+func newTestGameServer(tb testing.TB) *GameServer {
+	tb.Helper()
+	config := game.LoadConfig()
+	config.DatabaseURL = "sqlite::memory:"
+	config.Features, _ = game.ParseFeatures(config.ServerProfile, config.DisabledFeatures)
+
+	database, err := store.NewDatabase("sqlite3", config.DatabaseURL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	replication, err := transport.NewReplication(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cluster, err := transport.NewCluster(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	accounts, err := game.NewAccounts(database, config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	random := game.NewSimRand(config)
+	events := game.NewWorldEvents(config.WorldEventInterval, random)
+	entities := game.NewEntityRegistry(game.NewWorldBounds(config), random)
+	rules := game.NewRules(config.RulesFile)
+	return NewGameServer(database, config, replication, transport.NewRouter(config), cluster, events, entities, rules, accounts, nil, random)
+}
@@ -0,0 +1,6 @@
+package ws
+
+import gamemetrics "online-server-go/metrics"
+
+// metrics is the registry the game counts into, shared with the store.
+var metrics = gamemetrics.Default
@@ -0,0 +1,231 @@
+// Package ws serves the game to WebSocket clients with GameServer, an
+// http.Handler, and to clients framing the same messages over plain TCP with
+// TCPGameServer. Both play in a GameState, which runs the game loop.
+package ws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+type GameServer struct {
+	gameState *GameState
+	database  store.Store
+	router    *transport.Router
+	accounts  *game.Accounts
+	capacity  *game.Capacity
+	sendQueue SendQueuePolicy
+	proxies   []*net.IPNet // trusted to report client addresses in X-Forwarded-For
+	upgrader  websocket.Upgrader
+	mux       *http.ServeMux
+
+	compressAt         int // COMPRESSION_THRESHOLD
+	minProtocolVersion int
+}
+
+func NewGameServer(database store.Store, config *game.Config, replication *transport.Replication, router *transport.Router, cluster *transport.Cluster, events *game.WorldEvents, entities *game.EntityRegistry, rules *game.Rules, accounts *game.Accounts, plugins *game.Plugins, random *game.SimRand) *GameServer {
+	gameState := NewGameState("websocket", database, config, replication, cluster, events, entities, rules, plugins, random)
+	// TRUSTED_PROXIES and ALLOWED_ORIGINS were validated by the startup checks
+	proxies, _ := transport.ParseTrustedProxies(config.TrustedProxies)
+	origins, _ := transport.ParseOriginPolicy(config.AllowedOrigins)
+	logrus.Info("Game server initialized")
+
+	server := &GameServer{
+		gameState: gameState,
+		database:  database,
+		router:    router,
+		accounts:  accounts,
+		capacity:  game.NewCapacity(config.MaxPlayers, config.MaxQueuedPlayers),
+		sendQueue: NewSendQueuePolicy(config),
+		proxies:   proxies,
+		upgrader:  websocket.Upgrader{CheckOrigin: origins.CheckOrigin, EnableCompression: config.WSCompression},
+		mux:       http.NewServeMux(),
+
+		compressAt:         config.CompressionThreshold,
+		minProtocolVersion: config.MinProtocolVersion,
+	}
+	server.mux.HandleFunc("/", server.HandleConnection)
+	return server
+}
+
+// ServeHTTP serves WebSocket connections to the game at / and whatever was
+// mounted next to them with Handle, such as the HTTP API, so that the game
+// can be served under a path of a larger service.
+func (gs *GameServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gs.mux.ServeHTTP(w, r)
+}
+
+// Handle mounts handler on the game server's mux, next to the WebSocket
+// endpoint.
+func (gs *GameServer) Handle(pattern string, handler http.Handler) {
+	gs.mux.Handle(pattern, handler)
+}
+
+func (gs *GameServer) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	remoteAddr := &net.TCPAddr{IP: transport.RequestClientIP(r, gs.proxies)}
+	clientAddr := remoteAddr.IP.String()
+	logrus.Infof("New connection from: %s", clientAddr)
+
+	conn, err := gs.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.Errorf("WebSocket connection failed: %v", err)
+		return
+	}
+
+	// Clients opt into MessagePack with ?encoding=msgpack
+	encoding, err := game.ParseEncoding(r.URL.Query().Get("encoding"))
+	ws := newWSConn(conn, encoding, gs.gameState.limits.Size, gs.compressAt)
+	if err != nil {
+		if err := game.WriteMessage(ws, game.NewErrorMessage(err.Error())); err != nil {
+			logrus.Errorf("Failed to send encoding error to %s: %v", clientAddr, err)
+		}
+		conn.Close()
+		return
+	}
+
+	// Clients declare their protocol version with ?protocol_version=
+	version := game.ParseProtocolVersion(r.URL.Query().Get("protocol_version"))
+	if upgrade := game.CheckProtocolVersion(version, gs.minProtocolVersion); upgrade != nil {
+		logrus.Infof("Refusing %s: unsupported protocol version %q", clientAddr, r.URL.Query().Get("protocol_version"))
+		if err := game.WriteMessageFor(ws, version, *upgrade); err != nil {
+			logrus.Errorf("Failed to send UpgradeRequired to %s: %v", clientAddr, err)
+		}
+		conn.Close()
+		return
+	}
+
+	clientID := uuid.New()
+	clientName := "Player_" + clientID.String()[:8]
+
+	// Logged-in clients play as their account with ?token=
+	account, err := gs.accounts.AuthenticateConnection(r.Context(), r.URL.Query().Get("token"))
+	if err == nil && account != nil && gs.gameState.IsOnline(account.ID) {
+		err = game.ErrAccountOnline
+	}
+	if err != nil {
+		if err := game.WriteMessage(ws, game.NewErrorMessage(game.AuthErrorMessage(err))); err != nil {
+			logrus.Errorf("Failed to send authentication error to %s: %v", clientAddr, err)
+		}
+		conn.Close()
+		return
+	}
+	if account != nil {
+		clientID = account.ID
+		clientName = account.Username
+	}
+
+	// Clients failing over from a replication primary resume their player
+	var restored *game.Player
+	if resumeID, err := uuid.Parse(r.URL.Query().Get("resume_id")); err == nil &&
+		gs.accounts.AuthorizePlayerID(r.Context(), account, resumeID) == nil {
+		if player, ok := gs.gameState.replication.Restore(resumeID); ok {
+			clientID = player.ID
+			clientName = player.Name
+			restored = &player
+			logrus.Infof("Resuming replicated player %s (%s)", clientName, clientID)
+		}
+	}
+
+	// Clients redirected by a shard router reconnect with their assigned ID
+	if gs.router != nil && restored == nil && account == nil {
+		if playerID, err := uuid.Parse(r.URL.Query().Get("player_id")); err == nil && !gs.gameState.IsOnline(playerID) &&
+			gs.accounts.AuthorizePlayerID(r.Context(), nil, playerID) == nil {
+			clientID = playerID
+			clientName = "Player_" + clientID.String()[:8]
+		}
+	}
+
+	room := r.URL.Query().Get("room")
+	if backend, local := gs.router.Route(transport.RoutingKey(room, clientID)); !local {
+		logrus.Infof("Redirecting %s (%s) to %s", clientAddr, clientID, backend)
+		redirect := game.NewRedirectMessage(backend, clientID, room)
+		if err := game.WriteMessage(ws, redirect); err != nil {
+			logrus.Errorf("Failed to send redirect to %s: %v", clientAddr, err)
+		}
+		conn.Close()
+		return
+	}
+
+	if ban, err := gs.database.GetActiveBan(r.Context(), clientID); err != nil {
+		logrus.Errorf("Failed to check ban for %s: %v", clientID, err)
+	} else if ban != nil {
+		logrus.Infof("Rejecting banned player %s (%s)", clientID, clientAddr)
+		game.RefuseConnection(r.Context(), gs.database, ws, version, clientID, game.DisconnectBanned, ban.Message())
+		conn.Close()
+		return
+	}
+
+	if refusal := gs.gameState.maintenance.Refusal(); refusal != "" {
+		logrus.Infof("Refusing %s (%s) during maintenance", clientAddr, clientID)
+		game.RefuseConnection(r.Context(), gs.database, ws, version, clientID, game.DisconnectMaintenance, refusal)
+		conn.Close()
+		return
+	}
+
+	// Over MAX_PLAYERS, clients wait here for a slot
+	if err := gs.capacity.Admit(ws, clientAddr, version); err != nil {
+		if errors.Is(err, game.ErrServerFull) {
+			game.RefuseConnection(r.Context(), gs.database, ws, version, clientID, game.DisconnectServerFull, "server is full")
+		}
+		conn.Close()
+		return
+	}
+
+	client := NewClient(clientID, remoteAddr, clientName, ws, gs.sendQueue)
+	client.ProtocolVersion = version
+	// Clients that understand Batch messages opt in with ?batch=true
+	client.Batching, _ = strconv.ParseBool(r.URL.Query().Get("batch"))
+	if restored != nil {
+		*client.Player = *restored
+	} else if account != nil {
+		gs.accounts.LoadPlayer(r.Context(), account, client.Player)
+	}
+
+	clientCountBefore := gs.gameState.GetClientCount()
+
+	// Handle client messages in a separate goroutine. The connection outlives
+	// r, whose context ends when this handler returns.
+	go func() {
+		defer gs.capacity.Release()
+		HandleClientMessages(context.Background(), client, gs.gameState, gs.database)
+	}()
+
+	clientCountAfter := gs.gameState.GetClientCount()
+	logrus.Infof(
+		"Client %s connected. Active clients: %d -> %d",
+		clientAddr, clientCountBefore, clientCountAfter+1, // +1 because client is added in HandleClientMessages
+	)
+}
+
+func (gs *GameServer) GetActiveClients() int {
+	return gs.gameState.GetClientCount()
+}
+
+// Game returns the game state the server's connections play in.
+func (gs *GameServer) Game() *GameState {
+	return gs.gameState
+}
+
+// Mux returns the routes served besides the game's own, for mounting APIs.
+func (gs *GameServer) Mux() *http.ServeMux {
+	return gs.mux
+}
+
+// Clone returns a copy that shares the game state, database and routes of
+// gs, so that multiple goroutines can handle connections. Copying the whole
+// struct keeps fields added later from being left out.
+func (gs *GameServer) Clone() *GameServer {
+	clone := *gs
+	return &clone
+}
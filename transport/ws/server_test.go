@@ -0,0 +1,22 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCloneServesHTTP checks that a clone serves what was mounted on the
+// game server it was cloned from.
+func TestCloneServesHTTP(t *testing.T) {
+	gs := newTestGameServer(t)
+	gs.Handle("/ping", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	gs.Clone().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	if got := recorder.Body.String(); got != "pong" {
+		t.Errorf("clone served %q, want pong", got)
+	}
+}
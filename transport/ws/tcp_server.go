@@ -0,0 +1,263 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"online-server-go/game"
+	"online-server-go/store"
+	"online-server-go/transport"
+)
+
+const tcpWriteTimeout = 10 * time.Second
+
+// tcpAuthTimeout is how long a client has to send its Authenticate frame.
+const tcpAuthTimeout = 10 * time.Second
+
+// TCPGameServer serves clients that cannot speak WebSocket over plain TCP.
+// Every message is a JSON GameMessage prefixed with its length as a 4-byte
+// big-endian integer. Connections are handled by the same GameState as
+// WebSocket clients.
+type TCPGameServer struct {
+	listeners []net.Listener
+	gameState *GameState
+	database  store.Store
+	router    *transport.Router
+	accounts  *game.Accounts
+	capacity  *game.Capacity
+	sendQueue SendQueuePolicy
+
+	minProtocolVersion int
+}
+
+func NewTCPGameServer(addrs []string, database store.Store, config *game.Config, replication *transport.Replication, router *transport.Router, cluster *transport.Cluster, events *game.WorldEvents, entities *game.EntityRegistry, rules *game.Rules, accounts *game.Accounts, plugins *game.Plugins, random *game.SimRand) (*TCPGameServer, error) {
+	listeners, err := transport.ListenTCP(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on TCP: %w", err)
+	}
+
+	logrus.Infof("TCP Game server listening on: %s", strings.Join(addrs, ", "))
+
+	return &TCPGameServer{
+		listeners: listeners,
+		gameState: NewGameState("tcp", database, config, replication, cluster, events, entities, rules, plugins, random),
+		database:  database,
+		router:    router,
+		accounts:  accounts,
+		capacity:  game.NewCapacity(config.MaxPlayers, config.MaxQueuedPlayers),
+		sendQueue: NewSendQueuePolicy(config),
+
+		minProtocolVersion: config.MinProtocolVersion,
+	}, nil
+}
+
+// Run accepts connections until one of the listeners fails.
+func (ts *TCPGameServer) Run() error {
+	errs := make(chan error, len(ts.listeners))
+	for _, listener := range ts.listeners {
+		go func(listener net.Listener) {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					errs <- fmt.Errorf("failed to accept TCP connection: %w", err)
+					return
+				}
+				go ts.handleConnection(conn)
+			}
+		}(listener)
+	}
+	return <-errs
+}
+
+// Close stops accepting connections.
+func (ts *TCPGameServer) Close() error {
+	for _, listener := range ts.listeners {
+		listener.Close()
+	}
+	return nil
+}
+
+// Game returns the game state the server's connections play in.
+func (ts *TCPGameServer) Game() *GameState {
+	return ts.gameState
+}
+
+func (ts *TCPGameServer) handleConnection(conn net.Conn) {
+	clientAddr := conn.RemoteAddr()
+	logrus.Infof("New TCP connection from: %s", clientAddr)
+
+	ctx := context.Background()
+	tcp := newTCPConn(conn, ts.gameState.limits.Size)
+	clientID := uuid.New()
+	clientName := "Player_" + clientID.String()[:8]
+
+	account, version, err := ts.authenticate(ctx, tcp)
+	if upgrade := game.CheckProtocolVersion(version, ts.minProtocolVersion); err == nil && upgrade != nil {
+		logrus.Infof("Refusing %s: unsupported protocol version %d", clientAddr, version)
+		ts.writeAndClose(tcp, version, *upgrade)
+		return
+	}
+	if err == nil && account != nil && ts.gameState.IsOnline(account.ID) {
+		err = game.ErrAccountOnline
+	}
+	if err != nil {
+		ts.writeAndClose(tcp, version, game.NewErrorMessage(game.AuthErrorMessage(err)))
+		return
+	}
+	if account != nil {
+		clientID = account.ID
+		clientName = account.Username
+	}
+
+	if backend, local := ts.router.Route(transport.RoutingKey("", clientID)); !local {
+		logrus.Infof("Redirecting %s (%s) to %s", clientAddr, clientID, backend)
+		ts.writeAndClose(tcp, version, game.NewRedirectMessage(backend, clientID, ""))
+		return
+	}
+
+	if ban, err := ts.database.GetActiveBan(ctx, clientID); err != nil {
+		logrus.Errorf("Failed to check ban for %s: %v", clientID, err)
+	} else if ban != nil {
+		logrus.Infof("Rejecting banned player %s (%s)", clientID, clientAddr)
+		game.RefuseConnection(ctx, ts.database, tcp, version, clientID, game.DisconnectBanned, ban.Message())
+		tcp.Close()
+		return
+	}
+
+	if refusal := ts.gameState.maintenance.Refusal(); refusal != "" {
+		logrus.Infof("Refusing %s (%s) during maintenance", clientAddr, clientID)
+		game.RefuseConnection(ctx, ts.database, tcp, version, clientID, game.DisconnectMaintenance, refusal)
+		tcp.Close()
+		return
+	}
+
+	// Over MAX_PLAYERS, clients wait here for a slot
+	if err := ts.capacity.Admit(tcp, clientAddr.String(), version); err != nil {
+		if errors.Is(err, game.ErrServerFull) {
+			game.RefuseConnection(ctx, ts.database, tcp, version, clientID, game.DisconnectServerFull, "server is full")
+		}
+		tcp.Close()
+		return
+	}
+
+	client := NewClient(clientID, clientAddr, clientName, tcp, ts.sendQueue)
+	client.ProtocolVersion = version
+	if account != nil {
+		ts.accounts.LoadPlayer(ctx, account, client.Player)
+	}
+	defer ts.capacity.Release()
+	HandleClientMessages(ctx, client, ts.gameState, ts.database)
+}
+
+// authenticate reads the Authenticate frame that clients must send first
+// when AUTH_REQUIRED is set or MIN_PROTOCOL_VERSION retires version 1, and
+// returns the protocol version it declares. Otherwise TCP clients may play
+// anonymously at version 1: they wait for their PlayerJoin before sending
+// anything, so there is no first frame to wait for.
+func (ts *TCPGameServer) authenticate(ctx context.Context, tcp *tcpConn) (*store.Account, int, error) {
+	if !ts.accounts.Required() && ts.minProtocolVersion <= game.LegacyProtocolVersion {
+		return nil, game.LegacyProtocolVersion, nil
+	}
+
+	tcp.conn.SetReadDeadline(time.Now().Add(tcpAuthTimeout))
+	defer tcp.conn.SetReadDeadline(time.Time{})
+	frame, err := tcp.ReadFrame()
+	var message game.GameMessage
+	if err == nil {
+		err = tcp.Encoding().Unmarshal(frame, &message)
+	}
+	if err != nil || message.Type != "Authenticate" {
+		// Without the frame the client speaks version 1
+		_, err := ts.accounts.AuthenticateConnection(ctx, "")
+		return nil, game.LegacyProtocolVersion, err
+	}
+	data, _ := message.Data.(map[string]interface{})
+	token, _ := data["token"].(string)
+	version := game.LegacyProtocolVersion
+	if declared, ok := data["protocol_version"].(float64); ok {
+		version = int(declared)
+	}
+	account, err := ts.accounts.AuthenticateConnection(ctx, token)
+	return account, version, err
+}
+
+// writeAndClose sends a single message to a connection that never joins.
+func (ts *TCPGameServer) writeAndClose(tcp *tcpConn, version int, message game.GameMessage) {
+	if err := game.WriteMessageFor(tcp, version, message); err != nil {
+		logrus.Errorf("Failed to send %s over TCP: %v", message.Type, err)
+	}
+	tcp.Close()
+}
+
+// tcpConn implements ClientConn with length-prefixed frames.
+type tcpConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	maxSize int // bytes a frame may have, so a bad length prefix cannot make the server allocate unbounded memory
+
+	writeMu sync.Mutex
+}
+
+func newTCPConn(conn net.Conn, maxSize int) *tcpConn {
+	return &tcpConn{conn: conn, reader: bufio.NewReader(conn), maxSize: maxSize}
+}
+
+func (c *tcpConn) ReadFrame() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > uint32(c.maxSize) {
+		return nil, fmt.Errorf("%w: frame of %d bytes exceeds the %d byte limit", game.ErrMessageTooLarge, size, c.maxSize)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (c *tcpConn) WriteFrame(data []byte) error {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// WriteClose does nothing, since TCP has no close frame. Clients learn why
+// they were disconnected from the Disconnect message sent before.
+func (c *tcpConn) WriteClose(reason string) error {
+	return nil
+}
+
+func (c *tcpConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *tcpConn) Protocol() string {
+	return "tcp"
+}
+
+// Encoding is always JSON; TCP clients do not negotiate an encoding.
+func (c *tcpConn) Encoding() game.Encoding {
+	return game.EncodingJSON
+}